@@ -119,3 +119,27 @@ func TestSequenceHeaderContext(t *testing.T) {
 	shCtx.Unpack(goldenSh)
 	aac.Log.Debugf("%+v", shCtx)
 }
+
+func TestStreamMuxConfig(t *testing.T) {
+	// 44100 双声道 AAC-LC
+	asc := []byte{0x12, 0x10}
+	smc, err := aac.MakeStreamMuxConfig(asc)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []byte{0x40, 0x00, 0x24, 0x20, 0x3f, 0xc0}, smc)
+	ret, err := aac.ParseStreamMuxConfig(smc)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, asc, ret)
+
+	// HE-AAC显式信令，asc为4字节
+	asc = []byte{0x2b, 0x92, 0x08, 0x00}
+	smc, err = aac.MakeStreamMuxConfig(asc)
+	assert.Equal(t, nil, err)
+	ret, err = aac.ParseStreamMuxConfig(smc)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, asc, ret)
+
+	_, err = aac.ParseStreamMuxConfig([]byte{0x40})
+	assert.IsNotNil(t, err)
+	_, err = aac.ParseStreamMuxConfig([]byte{0xc0, 0x00, 0x24, 0x20, 0x3f, 0xc0})
+	assert.IsNotNil(t, err)
+}
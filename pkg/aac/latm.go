@@ -0,0 +1,153 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package aac
+
+import (
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/naza/pkg/nazabits"
+	"github.com/q191201771/naza/pkg/nazaerrors"
+)
+
+// latm.go
+//
+// rfc3016 MP4A-LATM中，sdp的a=fmtp中的config为StreamMuxConfig，asc嵌在其中，并且没有按字节对齐
+//
+// <ISO_IEC_14496-3.pdf>
+// <1.7.3.1 StreamMuxConfig>
+// --------------------------------------------------------
+// audioMuxVersion           [1b] 只支持0
+// allStreamsSameTimeFraming [1b]
+// numSubFrames              [6b]
+// numProgram                [4b] 只支持0，即1个program
+// numLayer                  [3b] 只支持0，即1个layer
+// AudioSpecificConfig       [变长]
+// frameLengthType           [3b] 只支持0，即帧长可变
+// latmBufferFullness        [8b]
+// otherDataPresent          [1b]
+// crcCheckPresent           [1b]
+
+const streamMuxConfigHeaderBits = 15 // audioMuxVersion到numLayer
+
+// ParseStreamMuxConfig 从StreamMuxConfig中获取asc
+//
+// @return asc: 内存块为独立新申请
+func ParseStreamMuxConfig(smc []byte) (asc []byte, err error) {
+	br := nazabits.NewBitReader(smc)
+	audioMuxVersion, _ := br.ReadBits8(1)
+	_, _ = br.ReadBits8(1)
+	_, _ = br.ReadBits8(6)
+	numProgram, _ := br.ReadBits8(4)
+	numLayer, _ := br.ReadBits8(3)
+	if err = br.Err(); err != nil {
+		return nil, nazaerrors.Wrap(base.ErrShortBuffer)
+	}
+	if audioMuxVersion != 0 || numProgram != 0 || numLayer != 0 {
+		return nil, nazaerrors.Wrap(base.ErrAacStreamMuxConfig, "unsupported")
+	}
+
+	// 先计算asc的长度，再逐位拷贝
+	ascBr := br
+	n, err := ascBitLength(&ascBr)
+	if err != nil {
+		return nil, err
+	}
+	asc = make([]byte, (n+7)/8)
+	bw := nazabits.NewBitWriter(asc)
+	for i := uint(0); i < n; i++ {
+		bit, _ := br.ReadBit()
+		bw.WriteBit(bit)
+	}
+	return asc, nil
+}
+
+// MakeStreamMuxConfig 使用asc生成StreamMuxConfig，numSubFrames为0，也即每个audioMuxElement包含一帧
+//
+// @return smc: 内存块为独立新申请
+func MakeStreamMuxConfig(asc []byte) (smc []byte, err error) {
+	ascBr := nazabits.NewBitReader(asc)
+	n, err := ascBitLength(&ascBr)
+	if err != nil {
+		return nil, err
+	}
+
+	smc = make([]byte, (streamMuxConfigHeaderBits+n+3+8+1+1+7)/8)
+	bw := nazabits.NewBitWriter(smc)
+	bw.WriteBits8(1, 0) // audioMuxVersion
+	bw.WriteBits8(1, 1) // allStreamsSameTimeFraming
+	bw.WriteBits8(6, 0) // numSubFrames
+	bw.WriteBits8(4, 0) // numProgram
+	bw.WriteBits8(3, 0) // numLayer
+	br := nazabits.NewBitReader(asc)
+	for i := uint(0); i < n; i++ {
+		bit, _ := br.ReadBit()
+		bw.WriteBit(bit)
+	}
+	bw.WriteBits8(3, 0)    // frameLengthType
+	bw.WriteBits8(8, 0xFF) // latmBufferFullness
+	bw.WriteBits8(1, 0)    // otherDataPresent
+	bw.WriteBits8(1, 0)    // crcCheckPresent
+	return smc, nil
+}
+
+// ascBitLength 计算asc所占的位数
+//
+// <ISO_IEC_14496-3.pdf>
+// <1.6.2.1 AudioSpecificConfig>
+// <4.4.1 GASpecificConfig>
+//
+// 只支持常见的AAC类型，不支持channelConfiguration为0时的program_config_element，以及extensionFlag为1时的扩展字段
+func ascBitLength(br *nazabits.BitReader) (n uint, err error) {
+	readObjectType := func() uint8 {
+		aot, _ := br.ReadBits8(5)
+		n += 5
+		if aot == 31 {
+			ext, _ := br.ReadBits8(6)
+			aot = 32 + ext
+			n += 6
+		}
+		return aot
+	}
+	readSamplingFrequency := func() {
+		index, _ := br.ReadBits8(4)
+		n += 4
+		if index == 0xF {
+			_ = br.SkipBits(24)
+			n += 24
+		}
+	}
+
+	aot := readObjectType()
+	readSamplingFrequency()
+	_, _ = br.ReadBits8(4) // channelConfiguration
+	n += 4
+
+	// SBR、PS的显式信令
+	if aot == 5 || aot == 29 {
+		readSamplingFrequency()
+		aot = readObjectType()
+	}
+
+	switch aot {
+	case 1, 2, 3, 4, 6, 7, 17, 19, 20, 21, 22, 23:
+		_, _ = br.ReadBit() // frameLengthFlag
+		dependsOnCoreCoder, _ := br.ReadBit()
+		n += 2
+		if dependsOnCoreCoder == 1 {
+			_ = br.SkipBits(14) // coreCoderDelay
+			n += 14
+		}
+		_, _ = br.ReadBit() // extensionFlag
+		n++
+	}
+
+	if br.Err() != nil {
+		return 0, nazaerrors.Wrap(base.ErrShortBuffer)
+	}
+	return n, nil
+}
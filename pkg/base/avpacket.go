@@ -40,6 +40,8 @@ func (a AvPacketPt) ReadableString() string {
 		return "h265"
 	case AvPacketPtAac:
 		return "aac"
+	case AvPacketPtMp2:
+		return "mpa"
 	}
 	return ""
 }
@@ -58,7 +60,7 @@ type AvPacket struct {
 }
 
 func (packet *AvPacket) IsAudio() bool {
-	return packet.PayloadType == AvPacketPtAac || packet.PayloadType == AvPacketPtG711A || packet.PayloadType == AvPacketPtG711U || packet.PayloadType == AvPacketPtOpus
+	return packet.PayloadType == AvPacketPtAac || packet.PayloadType == AvPacketPtG711A || packet.PayloadType == AvPacketPtG711U || packet.PayloadType == AvPacketPtOpus || packet.PayloadType == AvPacketPtMp2
 }
 
 func (packet *AvPacket) IsVideo() bool {
@@ -86,6 +86,10 @@ func NewBasicSessionStat(sessionType SessionType, remoteAddr string) BasicSessio
 		s.stat.SessionId = GenUkPsPubSession()
 		s.stat.BaseType = SessionBaseTypePubStr
 		s.stat.Protocol = SessionProtocolPsStr
+	case SessionTypePsPush:
+		s.stat.SessionId = GenUkPsPushSession()
+		s.stat.BaseType = SessionBaseTypePushStr
+		s.stat.Protocol = SessionProtocolPsStr
 	case SessionTypeHlsSub:
 		s.stat.SessionId = GenUkHlsSubSession()
 		s.stat.BaseType = SessionBaseTypeSubStr
@@ -118,6 +122,11 @@ func (s *BasicSessionStat) AddWriteBytes(n int) {
 	s.currConnStat.WroteBytesSum.Add(uint64(n))
 }
 
+// GetReadBytesSum 与 GetStat 不同，可以在多个协程中调用
+func (s *BasicSessionStat) GetReadBytesSum() uint64 {
+	return s.currConnStat.ReadBytesSum.Load()
+}
+
 func (s *BasicSessionStat) UpdateStat(intervalSec uint32) {
 	s.updateStat(s.currConnStat.ReadBytesSum.Load(), s.currConnStat.WroteBytesSum.Load(), s.stat.BaseType, intervalSec)
 }
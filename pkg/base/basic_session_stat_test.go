@@ -139,6 +139,19 @@ func TestNewBasicSessionStat(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "ps_push",
+			args: args{
+				sessionType: SessionTypePsPush,
+			},
+			want: BasicSessionStat{
+				stat: StatSession{
+					SessionId: GenUkPsPushSession(),
+					BaseType:  SessionBaseTypePushStr,
+					Protocol:  SessionProtocolPsStr,
+				},
+			},
+		},
 		{
 			name: "ts_sub",
 			args: args{
@@ -22,7 +22,10 @@ var (
 
 // ----- pkg/aac -------------------------------------------------------------------------------------------------------
 
-var ErrSamplingFrequencyIndex = errors.New("lal.aac: invalid sampling frequency index")
+var (
+	ErrSamplingFrequencyIndex = errors.New("lal.aac: invalid sampling frequency index")
+	ErrAacStreamMuxConfig     = errors.New("lal.aac: invalid stream mux config")
+)
 
 // ----- pkg/aac -------------------------------------------------------------------------------------------------------
 
@@ -47,6 +50,13 @@ var ErrHevc = errors.New("lal.hevc: fxxk")
 
 var ErrHls = errors.New("lal.hls: fxxk")
 var ErrHlsSessionNotFound = errors.New("lal.hls: hls session not found")
+var ErrHlsKey = errors.New("lal.hls: invalid hls key")
+var ErrHlsS3 = errors.New("lal.hls: s3 request failed")
+var ErrHlsRendition = errors.New("lal.hls: invalid rendition")
+
+// ----- pkg/mpa -------------------------------------------------------------------------------------------------------
+
+var ErrMpa = errors.New("lal.mpa: invalid mpeg audio frame header")
 
 // ----- pkg/rtmp ------------------------------------------------------------------------------------------------------
 
@@ -55,8 +65,12 @@ var (
 	ErrAmfTooShort    = errors.New("lal.rtmp: too short to unmarshal amf0 data")
 	ErrAmfNotExist    = errors.New("lal.rtmp: not exist")
 
+	ErrAmfInvalidReference = errors.New("lal.rtmp: invalid amf3 reference")
+	ErrAmfTooComplex       = errors.New("lal.rtmp: amf3 data too deep or too large")
+
 	ErrRtmpShortBuffer   = errors.New("lal.rtmp: buffer too short")
 	ErrRtmpUnexpectedMsg = errors.New("lal.rtmp: unexpected msg")
+	ErrRtmpAuth          = errors.New("lal.rtmp: auth failed")
 )
 
 func NewErrAmfInvalidType(b byte) error {
@@ -69,7 +83,13 @@ func NewErrRtmpShortBuffer(need, actual int, msg string) error {
 
 // ----- pkg/rtprtcp ---------------------------------------------------------------------------------------------------
 
-var ErrRtpRtcpShortBuffer = errors.New("lal.rtprtcp: buffer too short")
+var (
+	ErrRtpRtcpShortBuffer     = errors.New("lal.rtprtcp: buffer too short")
+	ErrSrtpUnsupportedProfile = errors.New("lal.rtprtcp: unsupported srtp profile")
+	ErrSrtpAuthFailed         = errors.New("lal.rtprtcp: srtp authentication failed")
+	ErrSrtpReplayed           = errors.New("lal.rtprtcp: srtp packet replayed")
+	ErrRtpExtensionInvalid    = errors.New("lal.rtprtcp: invalid rtp header extension")
+)
 
 // ----- pkg/rtsp ------------------------------------------------------------------------------------------------------
 
@@ -77,6 +97,8 @@ var (
 	ErrRtsp                     = errors.New("lal.rtsp: fxxk")
 	ErrRtspClosedByObserver     = errors.New("lal.rtsp: close by observer")
 	ErrRtspUnsupportedTransport = errors.New("lal.rtsp: unsupported Transport")
+	ErrRtspScaleNotSupported    = errors.New("lal.rtsp: scale not supported")
+	ErrRtspNotImplemented       = errors.New("lal.rtsp: not implemented")
 )
 
 // ----- pkg/sdp -------------------------------------------------------------------------------------------------------
@@ -88,6 +110,7 @@ var ErrSdp = errors.New("lal.sdp: fxxk")
 var (
 	ErrDupInStream      = errors.New("lal.logic: in stream already exist at group")
 	ErrDisposedInStream = errors.New("lal.logic: in stream already disposed")
+	ErrGroupNotFound    = errors.New("lal.logic: group not found")
 
 	ErrSimpleAuthParamNotFound = errors.New("lal.logic: simple auth failed since url param lal_secret not found")
 	ErrSimpleAuthFailed        = errors.New("lal.logic: simple auth failed since url param lal_secret invalid")
@@ -96,7 +119,12 @@ var (
 // ----- pkg/gb28181 ---------------------------------------------------------------------------------------------------
 
 var (
-	ErrGb28181 = errors.New("lal.gb28181: fxxk")
+	ErrGb28181               = errors.New("lal.gb28181: fxxk")
+	ErrGb28181DeviceNotFound = errors.New("lal.gb28181: device not found")
 )
 
+// ----- pkg/onvif -----------------------------------------------------------------------------------------------------
+
+var ErrOnvif = errors.New("lal.onvif: fxxk")
+
 // ---------------------------------------------------------------------------------------------------------------------
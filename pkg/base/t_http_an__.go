@@ -24,6 +24,7 @@ const (
 	AudioCodecG711U = "PCMU"
 	AudioCodecG711A = "PCMA"
 	AudioCodecOpus  = "OPUS"
+	AudioCodecMp3   = "MP3"
 
 	// VideoCodecAvc StatGroup.VideoCodec
 	VideoCodecAvc  = "H264"
@@ -75,6 +76,18 @@ type StatSession struct {
 	ReadBitrateKbits  int    `json:"read_bitrate_kbits"`
 	WriteBitrateKbits int    `json:"write_bitrate_kbits"`
 
+	// 以下为rtp输入的统计（目前只有gb28181的PubSession），根据rtp包的seq计算，其他类型的session为0
+	RecvPackets      uint64 `json:"recv_packets"`
+	LostPackets      uint64 `json:"lost_packets"`
+	ReorderedPackets uint64 `json:"reordered_packets"`
+
+	// 以下为rtcp nack重传的统计（目前只有rtsp UDP模式的session），其他类型的session为0
+	NackRequestedPackets     uint64 `json:"nack_requested_packets"`     // 输入类型的session为向对端请求重传的包数，输出类型的session为对端请求重传的包数
+	NackRetransmittedPackets uint64 `json:"nack_retransmitted_packets"` // 只有输出类型的session有效，实际重传的包数
+
+	// 通过fec包恢复的包数（目前只有rtsp输入类型的session），其他类型的session为0
+	FecRecoveredPackets uint64 `json:"fec_recovered_packets"`
+
 	typ SessionType
 }
 
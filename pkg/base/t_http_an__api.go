@@ -44,6 +44,64 @@ type ApiCtrlStartRtpPubReq struct {
 	TimeoutMs       int    `json:"timeout_ms"`
 	IsTcpFlag       int    `json:"is_tcp_flag"`
 	DebugDumpPacket string `json:"debug_dump_packet"`
+
+	// RemoteAddr 不为空时，使用TCP主动模式，由lalserver连接该地址（设备的媒体端口）接收流，此时忽略Port和IsTcpFlag
+	RemoteAddr string `json:"remote_addr"`
+}
+
+type ApiCtrlStopRtpPubReq struct {
+	StreamName string `json:"stream_name"`
+	SessionId  string `json:"session_id"` // 为空时结束该流的rtp pub session
+}
+
+type ApiCtrlStartRtpPushReq struct {
+	StreamName string `json:"stream_name"`
+	Mode       string `json:"mode"`        // udp, tcp_active, tcp_passive，默认udp
+	RemoteAddr string `json:"remote_addr"` // 上级平台的收流地址，tcp_passive时不需要
+	LocalPort  int    `json:"local_port"`  // 为0时内部自动选择
+	Ssrc       uint32 `json:"ssrc"`
+}
+
+type ApiCtrlGb28181InviteReq struct {
+	DeviceId   string `json:"device_id"`
+	ChannelId  string `json:"channel_id"`
+	StreamName string `json:"stream_name"` // 为空时使用channel_id
+	IsTcpFlag  int    `json:"is_tcp_flag"`
+	TimeoutMs  int    `json:"timeout_ms"` // 同 ApiCtrlStartRtpPubReq.TimeoutMs
+
+	// IsTcpActiveFlag 为1时使用TCP主动模式，由lalserver连接设备的媒体端口，需要同时设置IsTcpFlag
+	IsTcpActiveFlag int `json:"is_tcp_active_flag"`
+}
+
+// ApiCtrlGb28181PlaybackReq 历史视频回放以及下载
+type ApiCtrlGb28181PlaybackReq struct {
+	DeviceId        string `json:"device_id"`
+	ChannelId       string `json:"channel_id"`
+	StreamName      string `json:"stream_name"` // 为空时使用`{device_id}_{channel_id}_{playback|download}_{start_time}_{end_time}`
+	StartTime       int64  `json:"start_time"`  // unix时间戳，单位秒
+	EndTime         int64  `json:"end_time"`
+	IsTcpFlag       int    `json:"is_tcp_flag"`
+	IsTcpActiveFlag int    `json:"is_tcp_active_flag"`
+	TimeoutMs       int    `json:"timeout_ms"`
+	DownloadSpeed   int    `json:"download_speed"` // 下载倍速，仅下载时有效
+}
+
+type ApiCtrlGb28181PlaybackScaleReq struct {
+	StreamName string  `json:"stream_name"`
+	Scale      float64 `json:"scale"` // 播放倍速，比如0.5、1、2、4
+}
+
+type ApiCtrlGb28181ByeReq struct {
+	StreamName string `json:"stream_name"`
+}
+
+// ApiCtrlOnvifDiscoverReq 通过WS-Discovery搜索局域网内的ONVIF设备
+type ApiCtrlOnvifDiscoverReq struct {
+	TimeoutMs        int    `json:"timeout_ms"` // 等待设备响应的时间，为0时使用默认值
+	Username         string `json:"username"`
+	Password         string `json:"password"`
+	AutoPullFlag     bool   `json:"auto_pull_flag"` // 是否对每个设备的第一个profile自动发起RTSP拉流
+	StreamNamePrefix string `json:"stream_name_prefix"`
 }
 
 type ApiCtrlAddIpBlacklistReq struct {
@@ -51,6 +109,38 @@ type ApiCtrlAddIpBlacklistReq struct {
 	DurationSec int    `json:"duration_sec"`
 }
 
+type ApiCtrlAddAbrLadderReq struct {
+	Name           string             `json:"name"`
+	StreamNameList []string           `json:"stream_name_list"`
+	AudioList      []AbrRenditionInfo `json:"audio_list"`
+	SubtitleList   []AbrRenditionInfo `json:"subtitle_list"`
+}
+
+// AbrRenditionInfo ABR master playlist中的多语言音频或字幕
+type AbrRenditionInfo struct {
+	Name     string `json:"name"`
+	Language string `json:"language"`
+	Default  bool   `json:"default"`
+
+	// StreamName
+	//
+	// 音频: 音频来源的流，为空表示使用variant中自带的音频
+	// 字幕: 驱动字幕切片的视频流，字幕通过 /api/ctrl/push_subtitle 推送给该流
+	StreamName string `json:"stream_name"`
+}
+
+type ApiCtrlPushSubtitleReq struct {
+	StreamName string `json:"stream_name"`
+	Language   string `json:"language"`
+	StartMs    uint64 `json:"start_ms"`
+	EndMs      uint64 `json:"end_ms"`
+	Text       string `json:"text"`
+}
+
+type ApiCtrlDelAbrLadderReq struct {
+	Name string `json:"name"`
+}
+
 // ----- response ------------------------------------------------------------------------------------------------------
 
 const (
@@ -59,15 +149,27 @@ const (
 
 	ErrorCodePageNotFound = 404
 
-	ErrorCodeGroupNotFound   = 1001
-	DespGroupNotFound        = "group not found"
-	ErrorCodeParamMissing    = 1002
-	DespParamMissing         = "param missing"
-	ErrorCodeSessionNotFound = 1003
-	DespSessionNotFound      = "session not found"
+	ErrorCodeGroupNotFound     = 1001
+	DespGroupNotFound          = "group not found"
+	ErrorCodeParamMissing      = 1002
+	DespParamMissing           = "param missing"
+	ErrorCodeSessionNotFound   = 1003
+	DespSessionNotFound        = "session not found"
+	ErrorCodeAbrLadderNotFound = 1004
+	DespAbrLadderNotFound      = "abr ladder not found"
+	ErrorCodeParamInvalid      = 1005
+	DespParamInvalid           = "param invalid"
 
 	ErrorCodeStartRelayPullFail = 2001
 	ErrorCodeListenUdpPortFail  = 2002
+	ErrorCodeHlsNotEnable       = 2003
+	DespHlsNotEnable            = "hls not enable"
+	ErrorCodeGb28181NotEnable   = 2004
+	DespGb28181NotEnable        = "gb28181 not enable"
+	ErrorCodeGb28181InviteFail  = 2005
+	ErrorCodeStartRtpPushFail   = 2006
+	ErrorCodeGb28181ScaleFail   = 2007
+	ErrorCodeOnvifDiscoverFail  = 2008
 )
 
 type ApiRespBasic struct {
@@ -127,6 +229,123 @@ type ApiCtrlStartRtpPubResp struct {
 	} `json:"data"`
 }
 
+type ApiCtrlStopRtpPubResp struct {
+	ApiRespBasic
+	Data struct {
+		SessionId string `json:"session_id"`
+	} `json:"data"`
+}
+
+type ApiStatAllRtpPubResp struct {
+	ApiRespBasic
+	Data struct {
+		RtpPubs []StatRtpPub `json:"rtp_pubs"`
+	} `json:"data"`
+}
+
+// StatRtpPub 通过 /api/ctrl/start_rtp_pub 创建的 gb28181.PubSession
+type StatRtpPub struct {
+	StatSession
+
+	StreamName string `json:"stream_name"`
+	Mode       string `json:"mode"` // udp, tcp_active, tcp_passive
+	LocalPort  int    `json:"local_port"`
+}
+
+type ApiCtrlStartRtpPushResp struct {
+	ApiRespBasic
+	Data struct {
+		StreamName string `json:"stream_name"`
+		SessionId  string `json:"session_id"`
+		LocalPort  int    `json:"local_port"`
+	} `json:"data"`
+}
+
+type ApiCtrlGb28181InviteResp struct {
+	ApiRespBasic
+	Data struct {
+		StreamName string `json:"stream_name"`
+		SessionId  string `json:"session_id"`
+		Port       int    `json:"port"`
+		CallId     string `json:"call_id"`
+	} `json:"data"`
+}
+
+type ApiCtrlGb28181PlaybackScaleResp struct {
+	ApiRespBasic
+}
+
+type ApiCtrlGb28181ByeResp struct {
+	ApiRespBasic
+}
+
+type ApiStatGb28181DevicesResp struct {
+	ApiRespBasic
+	Data struct {
+		Devices []StatGb28181Device `json:"devices"`
+	} `json:"data"`
+}
+
+// StatGb28181Device 注册到 gb28181.SipServer 的设备
+type StatGb28181Device struct {
+	DeviceId      string               `json:"device_id"`
+	Transport     string               `json:"transport"` // UDP或TCP
+	RemoteAddr    string               `json:"remote_addr"`
+	RegisterTime  string               `json:"register_time"`
+	KeepaliveTime string               `json:"keepalive_time"`
+	Channels      []StatGb28181Channel `json:"channels"`
+}
+
+type StatGb28181Channel struct {
+	ChannelId    string `json:"channel_id"`
+	Name         string `json:"name"`
+	Manufacturer string `json:"manufacturer"`
+	Model        string `json:"model"`
+	Status       string `json:"status"`
+}
+
+type ApiCtrlOnvifDiscoverResp struct {
+	ApiRespBasic
+	Data struct {
+		Devices []StatOnvifDevice `json:"devices"`
+	} `json:"data"`
+}
+
+type StatOnvifDevice struct {
+	EndpointAddress string             `json:"endpoint_address"`
+	XAddr           string             `json:"xaddr"`
+	Name            string             `json:"name"`
+	Hardware        string             `json:"hardware"`
+	Profiles        []StatOnvifProfile `json:"profiles"`
+	Error           string             `json:"error"` // 查询profile失败时的错误信息
+}
+
+type StatOnvifProfile struct {
+	Token      string `json:"token"`
+	Name       string `json:"name"`
+	Encoding   string `json:"encoding"`
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	StreamUri  string `json:"stream_uri"`
+	StreamName string `json:"stream_name"` // 自动拉流时的流名称
+	SessionId  string `json:"session_id"`  // 自动拉流时的拉流session id
+}
+
 type ApiCtrlAddIpBlacklistResp struct {
 	ApiRespBasic
 }
+
+type ApiCtrlAddAbrLadderResp struct {
+	ApiRespBasic
+	Data struct {
+		Name string `json:"name"`
+	} `json:"data"`
+}
+
+type ApiCtrlDelAbrLadderResp struct {
+	ApiRespBasic
+}
+
+type ApiCtrlPushSubtitleResp struct {
+	ApiRespBasic
+}
@@ -33,6 +33,7 @@ const (
 	// RtmpTypeIdWinAckSize 见 RtmpTypeIdAck
 	RtmpTypeIdWinAckSize         uint8 = 5
 	RtmpTypeIdBandwidth          uint8 = 6
+	RtmpTypeIdDataMessageAmf3    uint8 = 15
 	RtmpTypeIdCommandMessageAmf3 uint8 = 17
 	RtmpTypeIdCommandMessageAmf0 uint8 = 20
 	RtmpTypeIdAggregateMessage   uint8 = 22
@@ -115,10 +116,12 @@ const (
 	//     AACPacketType UI8
 	//     Data          UI8[n]
 	// 注意，视频的CodecId是后4位，音频是前4位
+	RtmpSoundFormatMp3   uint8 = 2
 	RtmpSoundFormatG711A uint8 = 7
 	RtmpSoundFormatG711U uint8 = 8
 	RtmpSoundFormatAac   uint8 = 10
 	RtmpSoundFormatOpus  uint8 = 13
+	RtmpSoundFormatMp38k uint8 = 14 // MP3 8-Khz
 
 	RtmpAacPacketTypeSeqHeader = 0
 	RtmpAacPacketTypeRaw       = 1
@@ -244,6 +247,15 @@ func (msg RtmpMsg) AudioCodecId() uint8 {
 	return msg.Payload[0] >> 4
 }
 
+// IsMp3 SoundFormat为2或14（MP3 8-Khz）的音频，mp3的音频tag中不存在seq header
+func (msg RtmpMsg) IsMp3() bool {
+	if msg.Header.MsgTypeId != RtmpTypeIdAudio {
+		return false
+	}
+	id := msg.AudioCodecId()
+	return id == RtmpSoundFormatMp3 || id == RtmpSoundFormatMp38k
+}
+
 func (msg RtmpMsg) Clone() (ret RtmpMsg) {
 	ret.Header = msg.Header
 	ret.Payload = make([]byte, len(msg.Payload))
@@ -23,6 +23,7 @@ const (
 	UkPreFlvPullSession             = SessionProtocolFlvStr + SessionBaseTypePullStr      // "FLVPULL"
 	UkPreTsSubSession               = SessionProtocolTsStr + SessionBaseTypePubSubStr     // "TSSUB"
 	UkPrePsPubSession               = SessionProtocolPsStr + SessionBaseTypePubStr        // "PSPUB"
+	UkPrePsPushSession              = SessionProtocolPsStr + SessionBaseTypePushStr       // "PSPUSH"
 	UkPreHlsSubSession              = SessionProtocolHlsStr + SessionBaseTypeSubStr       // "HLSSUB"
 
 	UkPreRtspServerCommandSession = "RTSPSRVCMD" // 这个不暴露给上层
@@ -92,6 +93,10 @@ func GenUkPsPubSession() string {
 	return siUkPsPubSession.GenUniqueKey()
 }
 
+func GenUkPsPushSession() string {
+	return siUkPsPushSession.GenUniqueKey()
+}
+
 func GenUkGroup() string {
 	return siUkGroup.GenUniqueKey()
 }
@@ -118,6 +123,7 @@ var (
 	siUkTsSubSession             *unique.SingleGenerator
 	siUkFlvPullSession           *unique.SingleGenerator
 	siUkPsPubSession             *unique.SingleGenerator
+	siUkPsPushSession            *unique.SingleGenerator
 	siUkHlsSubSession            *unique.SingleGenerator
 
 	siUkGroup              *unique.SingleGenerator
@@ -139,6 +145,7 @@ func init() {
 	siUkTsSubSession = unique.NewSingleGenerator(UkPreTsSubSession)
 	siUkFlvPullSession = unique.NewSingleGenerator(UkPreFlvPullSession)
 	siUkPsPubSession = unique.NewSingleGenerator(UkPrePsPubSession)
+	siUkPsPushSession = unique.NewSingleGenerator(UkPrePsPushSession)
 	siUkHlsSubSession = unique.NewSingleGenerator(UkPreHlsSubSession)
 
 	siUkGroup = unique.NewSingleGenerator(UkPreGroup)
@@ -230,7 +230,7 @@ func initial() {
 	LalRtspResponseOptionsTmpl = "RTSP/1.0 200 OK\r\n" +
 		"Server: " + LalRtspOptionsResponseServer + "\r\n" +
 		"CSeq: %s\r\n" +
-		"Public: DESCRIBE, ANNOUNCE, SETUP, PLAY, PAUSE, RECORD, TEARDOWN\r\n" +
+		"Public: OPTIONS, DESCRIBE, ANNOUNCE, SETUP, PLAY, PAUSE, RECORD, TEARDOWN, GET_PARAMETER, SET_PARAMETER\r\n" +
 		"\r\n"
 
 	LalDefaultConfFilenameList = []string{
@@ -14,8 +14,6 @@ import (
 	"github.com/q191201771/naza/pkg/nazanet"
 )
 
-// TODO(chef): [feat] http api stop_rtp_pub 202207
-// TODO(chef): [feat] http api /api/stat/all_rtp_pub，不过这个可以用已有的all_group代替 202207
 // TODO(chef): [perf] 优化ps解析，内存块 202207
 // TODO(chef): [opt] avpkt转rtmp时，可能需要接一个缓存队列 202208
 
@@ -23,11 +21,31 @@ var (
 	Log = nazalog.GetGlobalLogger()
 )
 
+// 媒体流的传输方式，用于 PubSession 和 PushSession
+const (
+	// MediaModeUdp udp
+	MediaModeUdp = "udp"
+
+	// MediaModeTcpActive 本端主动连接对端
+	MediaModeTcpActive = "tcp_active"
+
+	// MediaModeTcpPassive 本端监听端口，等待对端连接
+	MediaModeTcpPassive = "tcp_passive"
+)
+
 // ErrGb28181 TODO(chef): [refactor] move to pkg base 202207
 var ErrGb28181 = errors.New("lal.gb28181: fxxk")
 
-// TODO(chef): [opt] 除了队列长度，还可以通过时长控制 202209
-var maxUnpackRtpListSize = 1024
+var (
+	// maxUnpackRtpListSize jitter buffer中缓存的最大包数量
+	maxUnpackRtpListSize = 1024
+
+	// unpackJitterBufferMs jitter buffer中缓存的最大时长，根据rtp时间戳计算，超过时放弃等待丢失的包
+	unpackJitterBufferMs = 300
+
+	// maxUnpackSsrcNum 音频和视频可能使用不同的ssrc
+	maxUnpackSsrcNum = 2
+)
 
 var (
 	defaultPubSessionPortMin = uint16(30000) // 注意，udp和tcp都使用这个端口范围
@@ -0,0 +1,306 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package gb28181
+
+import (
+	"github.com/q191201771/lal/pkg/avc"
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/lal/pkg/h2645"
+	"github.com/q191201771/lal/pkg/mpegts"
+	"github.com/q191201771/lal/pkg/rtprtcp"
+	"github.com/q191201771/naza/pkg/bele"
+)
+
+// pack.go
+//
+// PsPacker 将音视频帧打包成ps(Program Stream)流，是 PsUnpacker 的逆过程
+// RtpPsPacker 将ps流切分成rtp包
+
+const (
+	psMuxRate = 6106 // 单位50字节/秒，和常见设备保持一致即可，接收端一般不关心
+
+	psVideoBufferSizeBound = 1024 // P-STD_buffer_size_bound，视频单位1024字节
+	psAudioBufferSizeBound = 32   // P-STD_buffer_size_bound，音频单位128字节
+
+	// DefaultRtpPsMaxPayloadSize rtp包中ps数据的最大长度，加上rtp头以及tcp的2字节长度后，不超过常见的MTU
+	DefaultRtpPsMaxPayloadSize = 1400
+
+	// RtpPsPayloadType GB28181中ps流的rtp payload type
+	RtpPsPayloadType = 96
+)
+
+// PsPacker
+//
+// 每一帧都打包一个pack header。
+// 视频关键帧，或者音视频的格式发生变化时，额外打包system header以及PSM。
+// 一帧数据超过 MaxPesLen 时，切分成多个pes包，每个pes包都携带时间戳。
+type PsPacker struct {
+	videoStreamType uint8 // 0表示还没有该类型的流
+	audioStreamType uint8
+	psmVersion      uint8
+	psmChangedFlag  bool
+
+	buf []byte
+}
+
+func NewPsPacker() *PsPacker {
+	return &PsPacker{}
+}
+
+// Pack
+//
+// @param pkt: 格式和 PsUnpacker.WithOnAvPacket 回调的格式相同。
+//
+//	视频为AnnexB格式的h264、h265，音频为携带adts头的AAC，或者G711A、G711U。
+//	Timestamp和Pts的单位为毫秒。
+//
+// @return ps: 一帧数据对应的ps流。内存块在下次调用 Pack 前有效。
+//
+//	如果 pkt 的类型不支持，返回nil以及错误。
+func (p *PsPacker) Pack(pkt base.AvPacket) (ps []byte, err error) {
+	var streamType uint8
+	var streamId uint8
+	keyFlag := false
+	switch pkt.PayloadType {
+	case base.AvPacketPtAvc:
+		streamType, streamId = StreamTypeH264, StreamIdVideo
+		keyFlag = isKeyFrameAnnexb(true, pkt.Payload)
+	case base.AvPacketPtHevc:
+		streamType, streamId = StreamTypeH265, StreamIdVideo
+		keyFlag = isKeyFrameAnnexb(false, pkt.Payload)
+	case base.AvPacketPtAac:
+		streamType, streamId = StreamTypeAAC, StreamIdAudio
+	case base.AvPacketPtG711A:
+		streamType, streamId = StreamTypeG711A, StreamIdAudio
+	case base.AvPacketPtG711U:
+		streamType, streamId = StreamTypeG711U, StreamIdAudio
+	default:
+		return nil, ErrGb28181
+	}
+
+	if streamId == StreamIdVideo {
+		if p.videoStreamType != streamType {
+			p.videoStreamType = streamType
+			p.psmChangedFlag = true
+		}
+	} else {
+		if p.audioStreamType != streamType {
+			p.audioStreamType = streamType
+			p.psmChangedFlag = true
+		}
+	}
+
+	// 单位转换成90kHz
+	dts := uint64(pkt.Timestamp) * 90
+	pts := uint64(pkt.Pts) * 90
+
+	p.buf = p.buf[0:0]
+	p.packPackHeader(dts)
+	if keyFlag || p.psmChangedFlag {
+		p.packSystemHeader()
+		p.packPsm()
+		p.psmChangedFlag = false
+	}
+
+	payload := pkt.Payload
+	for len(payload) > 0 {
+		// 3字节的pes头固定部分，以及10字节的pts和dts
+		n := MaxPesLen - 3 - 10
+		if n > len(payload) {
+			n = len(payload)
+		}
+		p.packPes(streamId, pts, dts, payload[:n])
+		payload = payload[n:]
+	}
+	return p.buf, nil
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+func (p *PsPacker) packPackHeader(scr uint64) {
+	// 2.5.3.3 Pack layer of Program Stream
+	// Table 2-33 - Program Stream pack header
+	//
+	// 按MPEG-2打包，SCR extension固定为0，不打包stuffing
+	b := p.grow(PsHeaderlen)
+	bele.BePutUint32(b, psPackStartCodePackHeader)
+	b[4] = 0x40 | uint8((scr>>27)&0x38) | 0x04 | uint8((scr>>28)&0x03)
+	b[5] = uint8(scr >> 20)
+	b[6] = uint8((scr>>12)&0xf8) | 0x04 | uint8((scr>>13)&0x03)
+	b[7] = uint8(scr >> 5)
+	b[8] = uint8((scr<<3)&0xf8) | 0x04
+	b[9] = 0x01
+	rate := uint32(psMuxRate)
+	b[10] = uint8(rate >> 14)
+	b[11] = uint8(rate >> 6)
+	b[12] = uint8(rate<<2) | 0x03
+	b[13] = 0xf8
+}
+
+func (p *PsPacker) packSystemHeader() {
+	// 2.5.3.5 System header
+	// Table 2-32 - Program Stream system header
+	b := p.grow(SysHeaderlen)
+	bele.BePutUint32(b, psPackStartCodeSystemHeader)
+	bele.BePutUint16(b[4:], uint16(SysHeaderlen-6))
+	rate := uint32(psMuxRate)
+	b[6] = 0x80 | uint8(rate>>15)
+	b[7] = uint8(rate >> 7)
+	b[8] = uint8(rate<<1) | 0x01
+	b[9] = 0x04  // audio_bound=1, fixed_flag=0, CSPS_flag=0
+	b[10] = 0xe1 // system_audio_lock_flag=1, system_video_lock_flag=1, video_bound=1
+	b[11] = 0x7f // packet_rate_restriction_flag=0
+	b[12] = StreamIdVideo
+	b[13] = 0xe0 | uint8(psVideoBufferSizeBound>>8) // P-STD_buffer_bound_scale=1
+	b[14] = uint8(psVideoBufferSizeBound & 0xff)
+	b[15] = StreamIdAudio
+	b[16] = 0xc0 | uint8(psAudioBufferSizeBound>>8) // P-STD_buffer_bound_scale=0
+	b[17] = uint8(psAudioBufferSizeBound)
+}
+
+func (p *PsPacker) packPsm() {
+	// 2.5.4 Program Stream map
+	// Table 2-35 - Program Stream map
+	var esNum int
+	if p.videoStreamType != 0 {
+		esNum++
+	}
+	if p.audioStreamType != 0 {
+		esNum++
+	}
+
+	start := len(p.buf)
+	b := p.grow(6 + 6 + esNum*4 + 4)
+	bele.BePutUint32(b, psPackStartCodeProgramStreamMap)
+	bele.BePutUint16(b[4:], uint16(len(b)-6))
+	b[6] = 0x80 | (p.psmVersion & 0x1f) // current_next_indicator=1
+	b[7] = 0xff
+	bele.BePutUint16(b[8:], 0) // program_stream_info_length
+	bele.BePutUint16(b[10:], uint16(esNum*4))
+	i := 12
+	if p.videoStreamType != 0 {
+		b[i] = p.videoStreamType
+		b[i+1] = StreamIdVideo
+		bele.BePutUint16(b[i+2:], 0)
+		i += 4
+	}
+	if p.audioStreamType != 0 {
+		b[i] = p.audioStreamType
+		b[i+1] = StreamIdAudio
+		bele.BePutUint16(b[i+2:], 0)
+		i += 4
+	}
+	crc := mpegts.CalcCrc32(0xffffffff, p.buf[start:start+i])
+	bele.BePutUint32(b[i:], crc)
+
+	p.psmVersion++
+}
+
+func (p *PsPacker) packPes(streamId uint8, pts, dts uint64, payload []byte) {
+	phdl := 5
+	ptsDtsFlag := uint8(0x80)
+	if pts != dts {
+		phdl = 10
+		ptsDtsFlag = 0xc0
+	}
+
+	b := p.grow(9 + phdl + len(payload))
+	b[0], b[1], b[2] = 0, 0, 1
+	b[3] = streamId
+	bele.BePutUint16(b[4:], uint16(3+phdl+len(payload)))
+	b[6] = 0x80 // '10', 其他flag都为0
+	b[7] = ptsDtsFlag
+	b[8] = uint8(phdl)
+	if phdl == 5 {
+		mpegts.PackPts(b[9:], 0x02, pts)
+	} else {
+		mpegts.PackPts(b[9:], 0x03, pts)
+		mpegts.PackPts(b[14:], 0x01, dts)
+	}
+	copy(b[9+phdl:], payload)
+}
+
+// grow 在 p.buf 尾部追加n字节，并返回追加的这部分内存
+func (p *PsPacker) grow(n int) []byte {
+	l := len(p.buf)
+	if cap(p.buf)-l < n {
+		nb := make([]byte, l, 2*cap(p.buf)+n)
+		copy(nb, p.buf)
+		p.buf = nb
+	}
+	p.buf = p.buf[:l+n]
+	return p.buf[l:]
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// RtpPsPacker 将ps流切分成rtp包，一帧数据对应的多个rtp包使用相同的时间戳，最后一个rtp包设置mark位
+type RtpPsPacker struct {
+	ssrc           uint32
+	seq            uint16
+	maxPayloadSize int
+}
+
+func NewRtpPsPacker(ssrc uint32) *RtpPsPacker {
+	return &RtpPsPacker{
+		ssrc:           ssrc,
+		maxPayloadSize: DefaultRtpPsMaxPayloadSize,
+	}
+}
+
+// Pack
+//
+// @param ps:          一帧数据对应的ps流，也即 PsPacker.Pack 的返回值
+// @param timestampMs: 单位毫秒，内部转换成90kHz
+//
+// @return pkts: 内存块为独立新申请，函数调用结束后，内部不持有这些内存块
+func (r *RtpPsPacker) Pack(ps []byte, timestampMs int64) (pkts []rtprtcp.RtpPacket) {
+	h := rtprtcp.MakeDefaultRtpHeader()
+	h.PacketType = RtpPsPayloadType
+	h.Timestamp = uint32(timestampMs * 90)
+	h.Ssrc = r.ssrc
+	for len(ps) > 0 {
+		n := r.maxPayloadSize
+		if n >= len(ps) {
+			n = len(ps)
+			h.Mark = 1
+		}
+		h.Seq = r.seq
+		r.seq++
+		pkts = append(pkts, rtprtcp.MakeRtpPacket(h, ps[:n]))
+		ps = ps[n:]
+	}
+	return
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// isKeyFrameAnnexb 判断AnnexB格式的一帧数据是否包含关键帧
+func isKeyFrameAnnexb(isH264 bool, b []byte) (ret bool) {
+	_ = avc.IterateNaluAnnexb(b, func(nal []byte) {
+		if len(nal) == 0 {
+			return
+		}
+		typ := h2645.ParseNaluType(isH264, nal[0])
+		if isH264 {
+			ret = ret || typ == h2645.H264NaluTypeIdrSlice
+		} else {
+			ret = ret || h2645.H265IsIrapNalu(typ)
+		}
+	})
+	return
+}
+
+// isKeyFrameOrParamSetNalu 关键帧，或者vps、sps、pps
+func isKeyFrameOrParamSetNalu(isH264 bool, typ uint8) bool {
+	if isH264 {
+		return typ == h2645.H264NaluTypeIdrSlice || typ == h2645.H264NaluTypeSps || typ == h2645.H264NaluTypePps
+	}
+	return h2645.H265IsIrapNalu(typ) || typ == h2645.H265NaluTypeVps || typ == h2645.H265NaluTypeSps || typ == h2645.H265NaluTypePps
+}
@@ -0,0 +1,185 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package gb28181
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/lal/pkg/rtprtcp"
+	"github.com/q191201771/naza/pkg/assert"
+	"github.com/q191201771/naza/pkg/bele"
+)
+
+var (
+	testSps  = []byte{0, 0, 0, 1, 0x67, 0x64, 0x00, 0x1f, 0xac}
+	testPps  = []byte{0, 0, 0, 1, 0x68, 0xee, 0x3c, 0x80}
+	testAdts = []byte{0xff, 0xf1, 0x50, 0x80, 0x01, 0x3f, 0xfc, 0x21, 0x10, 0x04}
+)
+
+func makeTestNalu(typ uint8, size int) []byte {
+	b := make([]byte, size)
+	copy(b, []byte{0, 0, 0, 1, typ})
+	for i := 5; i < size; i++ {
+		b[i] = byte(i%250) + 2 // 避免出现start code
+	}
+	return b
+}
+
+func makeTestIdr() []byte {
+	var b []byte
+	b = append(b, testSps...)
+	b = append(b, testPps...)
+	b = append(b, makeTestNalu(0x65, 3000)...)
+	return b
+}
+
+// TestPsPacker 打包后再通过 PsUnpacker 解析，结果应该和输入一致
+func TestPsPacker(t *testing.T) {
+	idr := makeTestIdr()
+	big := makeTestNalu(0x41, 100*1024) // 超过一个pes包的最大长度
+	in := []base.AvPacket{
+		{PayloadType: base.AvPacketPtAvc, Timestamp: 0, Pts: 0, Payload: idr},
+		{PayloadType: base.AvPacketPtAac, Timestamp: 10, Pts: 10, Payload: testAdts},
+		{PayloadType: base.AvPacketPtAvc, Timestamp: 40, Pts: 80, Payload: big},
+		{PayloadType: base.AvPacketPtAac, Timestamp: 50, Pts: 50, Payload: testAdts},
+		{PayloadType: base.AvPacketPtAvc, Timestamp: 80, Pts: 120, Payload: makeTestNalu(0x41, 100)},
+		{PayloadType: base.AvPacketPtAac, Timestamp: 90, Pts: 90, Payload: testAdts},
+		{PayloadType: base.AvPacketPtAvc, Timestamp: 120, Pts: 160, Payload: makeTestNalu(0x41, 100)},
+	}
+
+	var out []base.AvPacket
+	unpacker := NewPsUnpacker().WithOnAvPacket(func(packet *base.AvPacket) {
+		p := *packet
+		p.Payload = append([]byte(nil), packet.Payload...)
+		out = append(out, p)
+	})
+
+	packer := NewPsPacker()
+	rtpPacker := NewRtpPsPacker(0x12345678)
+	var rtpPktNum int
+	for _, pkt := range in {
+		ps, err := packer.Pack(pkt)
+		assert.Equal(t, nil, err)
+		pkts := rtpPacker.Pack(ps, pkt.Timestamp)
+		for i, rtpPkt := range pkts {
+			h, err := rtprtcp.ParseRtpHeader(rtpPkt.Raw)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, uint32(0x12345678), h.Ssrc)
+			assert.Equal(t, uint8(RtpPsPayloadType), h.PacketType)
+			assert.Equal(t, uint32(pkt.Timestamp*90), h.Timestamp)
+			assert.Equal(t, uint16(rtpPktNum), h.Seq)
+			assert.Equal(t, i == len(pkts)-1, h.Mark == 1)
+			assert.Equal(t, true, len(rtpPkt.Raw) <= rtprtcp.RtpFixedHeaderLength+DefaultRtpPsMaxPayloadSize)
+			rtpPktNum++
+			assert.Equal(t, nil, unpacker.FeedRtpPacket(rtpPkt.Raw))
+		}
+	}
+
+	// unpacker在收到下一帧时才回调上一帧，所以音频和视频的最后一帧都不会回调
+	// 视频按nalu回调
+	assert.Equal(t, 7, len(out))
+	assert.Equal(t, testSps, out[0].Payload)
+	assert.Equal(t, testPps, out[1].Payload)
+	assert.Equal(t, idr[len(testSps)+len(testPps):], out[2].Payload)
+	assert.Equal(t, base.AvPacketPtAac, out[3].PayloadType)
+	assert.Equal(t, testAdts, out[3].Payload)
+	assert.Equal(t, int64(10), out[3].Timestamp)
+	assert.Equal(t, big, out[4].Payload)
+	assert.Equal(t, int64(80), out[4].Pts)
+	assert.Equal(t, testAdts, out[5].Payload)
+	assert.Equal(t, base.AvPacketPtAvc, out[6].PayloadType)
+	assert.Equal(t, int64(120), out[6].Pts)
+
+	// 不支持的类型
+	_, err := packer.Pack(base.AvPacket{PayloadType: base.AvPacketPtOpus, Payload: []byte{1}})
+	assert.IsNotNil(t, err)
+}
+
+func TestPushSession(t *testing.T) {
+	// udp
+	uconn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.Equal(t, nil, err)
+	defer uconn.Close()
+
+	session := NewPushSession().WithStreamName("test110").WithSsrc(1)
+	_, err = session.Start(MediaModeUdp, uconn.LocalAddr().String(), 0)
+	assert.Equal(t, nil, err)
+	go session.RunLoop()
+
+	// 关键帧之前的视频被丢弃
+	session.FeedAvPacket(base.AvPacket{PayloadType: base.AvPacketPtAvc, Payload: makeTestNalu(0x41, 100)})
+	session.FeedAvPacket(base.AvPacket{PayloadType: base.AvPacketPtAvc, Timestamp: 40, Pts: 40, Payload: makeTestIdr()})
+	buf := make([]byte, 1500)
+	_ = uconn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := uconn.Read(buf)
+	assert.Equal(t, nil, err)
+	h, err := rtprtcp.ParseRtpHeader(buf[:n])
+	assert.Equal(t, nil, err)
+	assert.Equal(t, uint32(1), h.Ssrc)
+	assert.Equal(t, uint32(40*90), h.Timestamp)
+	assert.Equal(t, uint16(0), h.Seq)
+	assert.Equal(t, uint32(psPackStartCodePackHeader), bele.BeUint32(buf[rtprtcp.RtpFixedHeaderLength:]))
+	assert.Equal(t, "test110", session.StreamName())
+	assert.Equal(t, base.SessionProtocolPsStr, session.GetStat().Protocol)
+	assert.Equal(t, base.SessionBaseTypePushStr, session.GetStat().BaseType)
+	assert.Equal(t, nil, session.Dispose())
+
+	// tcp passive
+	session = NewPushSession()
+	port, err := session.Start(MediaModeTcpPassive, "", 0)
+	assert.Equal(t, nil, err)
+	runLoopDone := make(chan struct{})
+	go func() {
+		_ = session.RunLoop()
+		close(runLoopDone)
+	}()
+	readAlive, writeAlive := session.IsAlive()
+	assert.Equal(t, true, readAlive && writeAlive)
+
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	assert.Equal(t, nil, err)
+	defer conn.Close()
+	for {
+		// 等待accept
+		session.mutex.Lock()
+		connected := session.tcpConn != nil
+		session.mutex.Unlock()
+		if connected {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	session.FeedAvPacket(base.AvPacket{PayloadType: base.AvPacketPtG711A, Timestamp: 20, Pts: 20, Payload: []byte{1, 2, 3}})
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	lb := make([]byte, 2)
+	_, err = io.ReadFull(conn, lb)
+	assert.Equal(t, nil, err)
+	b := make([]byte, bele.BeUint16(lb))
+	_, err = io.ReadFull(conn, b)
+	assert.Equal(t, nil, err)
+	h, err = rtprtcp.ParseRtpHeader(b)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, uint8(1), h.Mark)
+	assert.Equal(t, true, bytes.HasSuffix(b, []byte{1, 2, 3}))
+
+	// 对端断开后，RunLoop退出
+	conn.Close()
+	select {
+	case <-runLoopDone:
+	case <-time.After(2 * time.Second):
+		t.Fatal("run loop not exit")
+	}
+}
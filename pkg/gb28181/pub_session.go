@@ -18,8 +18,11 @@ import (
 	"io"
 	"net"
 	"sync"
+	"time"
 )
 
+const pubSessionDialTimeoutMs = 5000
+
 type OnReadPacket func(b []byte)
 
 type PubSession struct {
@@ -29,13 +32,17 @@ type PubSession struct {
 
 	hookOnReadPacket OnReadPacket
 
-	isTcpFlag bool
-
-	disposeOnce sync.Once
-	udpConn     *nazanet.UdpConnection
-	listener    net.Listener
-	tcpConn     net.Conn
-	sessionStat base.BasicSessionStat
+	isTcpFlag  bool
+	remoteAddr string // tcp主动模式时，对端（设备）的媒体地址
+	localPort  int
+
+	disposeOnce  sync.Once
+	udpConn      *nazanet.UdpConnection
+	listener     net.Listener
+	mutex        sync.Mutex // 保护tcpConn和disposedFlag，tcpConn在RunLoop的协程中设置
+	tcpConn      net.Conn
+	disposedFlag bool
+	sessionStat  base.BasicSessionStat
 }
 
 func NewPubSession() *PubSession {
@@ -74,20 +81,59 @@ func (session *PubSession) WithHookReadPacket(fn OnReadPacket) *PubSession {
 func (session *PubSession) Listen(port int, isTcpFlag bool) (int, error) {
 	session.isTcpFlag = isTcpFlag
 
+	var err error
 	if isTcpFlag {
-		return session.listenTcp(port)
+		session.localPort, err = session.listenTcp(port)
+	} else {
+		session.localPort, err = session.listenUdp(port)
 	}
-	return session.listenUdp(port)
+	return session.localPort, err
+}
+
+// Connect TCP主动模式，由本端连接对端（设备）的媒体端口，和 Listen 二选一
+//
+// 非阻塞函数，实际的连接在 RunLoop 中建立，连接失败时 RunLoop 返回错误
+func (session *PubSession) Connect(remoteAddr string) {
+	session.isTcpFlag = true
+	session.remoteAddr = remoteAddr
+	session.sessionStat.SetRemoteAddr(remoteAddr)
 }
 
 // RunLoop 阻塞函数
 func (session *PubSession) RunLoop() error {
+	if session.remoteAddr != "" {
+		return session.runLoopTcpActive()
+	}
 	if session.isTcpFlag {
 		return session.runLoopTcp()
 	}
 	return session.runLoopUdp()
 }
 
+// Mode 见 MediaModeUdp 等
+func (session *PubSession) Mode() string {
+	if session.remoteAddr != "" {
+		return MediaModeTcpActive
+	}
+	if session.isTcpFlag {
+		return MediaModeTcpPassive
+	}
+	return MediaModeUdp
+}
+
+// GetRtpPubStat 注意，可以在其他协程中调用
+func (session *PubSession) GetRtpPubStat() base.StatRtpPub {
+	session.mutex.Lock()
+	localPort := session.localPort
+	session.mutex.Unlock()
+	return base.StatRtpPub{
+		StatSession: session.GetStat(),
+		StreamName:  session.StreamName(),
+		Mode:        session.Mode(),
+		LocalPort:   localPort,
+	}
+}
+
 // ----- IServerSessionLifecycle ---------------------------------------------------------------------------------------
 
 func (session *PubSession) Dispose() error {
@@ -132,7 +178,12 @@ func (session *PubSession) UpdateStat(intervalSec uint32) {
 }
 
 func (session *PubSession) GetStat() base.StatSession {
-	return session.sessionStat.GetStat()
+	stat := session.sessionStat.GetStat()
+	rtpStat := session.unpacker.GetRtpStat()
+	stat.RecvPackets = rtpStat.RecvPackets
+	stat.LostPackets = rtpStat.LostPackets
+	stat.ReorderedPackets = rtpStat.ReorderedPackets
+	return stat
 }
 
 func (session *PubSession) IsAlive() (readAlive, writeAlive bool) {
@@ -203,32 +254,60 @@ func (session *PubSession) runLoopTcp() error {
 			return err
 		}
 
+		session.mutex.Lock()
 		if session.tcpConn != nil {
 			nazalog.Warnf("[%s] tcp conn already exist, close the prev. err=%+v", session.UniqueKey(), err)
 			session.tcpConn.Close()
 			// TODO(chef): [fix] reset unpack 202209
 		}
-
 		session.tcpConn = conn
+		session.mutex.Unlock()
 
-		go func() {
-			lb := make([]byte, 2)
-			buf := nazabytes.NewBuffer(1500) // 初始1500，如果不够会扩容
-			for {
-				if _, rErr := io.ReadFull(conn, lb); rErr != nil {
-					nazalog.Debugf("[%s] read failed. err=%+v", session.UniqueKey(), rErr)
-					break
-				}
-				length := int(bele.BeUint16(lb))
-				b := buf.ReserveBytes(length)
-				if _, rErr := io.ReadFull(conn, b); rErr != nil {
-					nazalog.Debugf("[%s] read failed. err=%+v", session.UniqueKey(), rErr)
-					break
-				}
-
-				session.feedPacket(b)
-			}
-		}()
+		go session.runTcpReadLoop(conn)
+	}
+}
+
+func (session *PubSession) runLoopTcpActive() error {
+	conn, err := net.DialTimeout("tcp", session.remoteAddr, pubSessionDialTimeoutMs*time.Millisecond)
+	if err != nil {
+		nazalog.Warnf("[%s] connect failed. raddr=%s, err=%+v", session.UniqueKey(), session.remoteAddr, err)
+		_ = session.dispose(err)
+		return err
+	}
+
+	session.mutex.Lock()
+	if session.disposedFlag {
+		session.mutex.Unlock()
+		conn.Close()
+		return base.ErrSessionNotStarted
+	}
+	session.tcpConn = conn
+	session.localPort = conn.LocalAddr().(*net.TCPAddr).Port
+	session.mutex.Unlock()
+
+	Log.Infof("[%s] connect succ. raddr=%s", session.UniqueKey(), session.remoteAddr)
+	err = session.runTcpReadLoop(conn)
+	_ = session.dispose(err)
+	return err
+}
+
+// runTcpReadLoop 读取RFC4571格式的rtp包
+func (session *PubSession) runTcpReadLoop(conn net.Conn) error {
+	lb := make([]byte, 2)
+	buf := nazabytes.NewBuffer(1500) // 初始1500，如果不够会扩容
+	for {
+		if _, rErr := io.ReadFull(conn, lb); rErr != nil {
+			nazalog.Debugf("[%s] read failed. err=%+v", session.UniqueKey(), rErr)
+			return rErr
+		}
+		length := int(bele.BeUint16(lb))
+		b := buf.ReserveBytes(length)
+		if _, rErr := io.ReadFull(conn, b); rErr != nil {
+			nazalog.Debugf("[%s] read failed. err=%+v", session.UniqueKey(), rErr)
+			return rErr
+		}
+
+		session.feedPacket(b)
 	}
 }
 
@@ -246,11 +325,19 @@ func (session *PubSession) dispose(err error) error {
 	session.disposeOnce.Do(func() {
 		Log.Infof("[%s] lifecycle dispose gb28181 PubSession. err=%+v", session.UniqueKey(), err)
 		if session.isTcpFlag {
-			if session.tcpConn == nil {
+			session.mutex.Lock()
+			session.disposedFlag = true
+			tcpConn := session.tcpConn
+			session.mutex.Unlock()
+
+			if session.listener != nil {
+				_ = session.listener.Close()
+			}
+			if tcpConn == nil {
 				retErr = base.ErrSessionNotStarted
 				return
 			}
-			retErr = session.tcpConn.Close()
+			retErr = tcpConn.Close()
 		} else {
 			if session.udpConn == nil {
 				retErr = base.ErrSessionNotStarted
@@ -0,0 +1,111 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package gb28181
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/naza/pkg/assert"
+	"github.com/q191201771/naza/pkg/bele"
+)
+
+// TestPubSessionTcpActive 模拟设备监听媒体端口，PubSession 主动连接并接收RFC4571格式的ps over rtp
+func TestPubSessionTcpActive(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Equal(t, nil, err)
+	defer ln.Close()
+
+	var (
+		mutex sync.Mutex
+		pkts  []base.AvPacket
+	)
+	session := NewPubSession().WithStreamName("test110").WithOnAvPacket(func(packet *base.AvPacket) {
+		mutex.Lock()
+		pkts = append(pkts, *packet)
+		mutex.Unlock()
+	})
+	session.Connect(ln.Addr().String())
+	assert.Equal(t, MediaModeTcpActive, session.Mode())
+	runLoopDone := make(chan error, 1)
+	go func() {
+		runLoopDone <- session.RunLoop()
+	}()
+
+	conn, err := ln.Accept()
+	assert.Equal(t, nil, err)
+
+	packer := NewPsPacker()
+	rtpPacker := NewRtpPsPacker(1)
+	for _, pkt := range []base.AvPacket{
+		{PayloadType: base.AvPacketPtAvc, Timestamp: 0, Pts: 0, Payload: makeTestIdr()},
+		{PayloadType: base.AvPacketPtAvc, Timestamp: 40, Pts: 40, Payload: makeTestNalu(0x41, 100)},
+	} {
+		ps, _ := packer.Pack(pkt)
+		for _, rtpPkt := range rtpPacker.Pack(ps, pkt.Timestamp) {
+			lb := make([]byte, 2)
+			bele.BePutUint16(lb, uint16(len(rtpPkt.Raw)))
+			_, err = conn.Write(append(lb, rtpPkt.Raw...))
+			assert.Equal(t, nil, err)
+		}
+	}
+
+	// 收到第二帧时回调第一帧的sps、pps、idr
+	for i := 0; ; i++ {
+		mutex.Lock()
+		n := len(pkts)
+		mutex.Unlock()
+		if n == 3 {
+			break
+		}
+		if i > 200 {
+			t.Fatal("recv timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stat := session.GetRtpPubStat()
+	assert.Equal(t, "test110", stat.StreamName)
+	assert.Equal(t, MediaModeTcpActive, stat.Mode)
+	assert.Equal(t, conn.RemoteAddr().(*net.TCPAddr).Port, stat.LocalPort)
+	assert.Equal(t, uint64(0), stat.LostPackets)
+	assert.Equal(t, true, stat.RecvPackets > 0)
+	assert.Equal(t, base.SessionProtocolPsStr, stat.Protocol)
+
+	// 对端断开后，RunLoop退出
+	conn.Close()
+	select {
+	case <-runLoopDone:
+	case <-time.After(2 * time.Second):
+		t.Fatal("run loop not exit")
+	}
+
+	// 连接失败时RunLoop返回错误
+	session = NewPubSession()
+	session.Connect(ln.Addr().String())
+	ln.Close()
+	assert.IsNotNil(t, session.RunLoop())
+
+	// 没有建立连接时Dispose，监听的端口被关闭，RunLoop退出
+	session = NewPubSession()
+	_, err = session.Listen(0, true)
+	assert.Equal(t, nil, err)
+	go func() {
+		runLoopDone <- session.RunLoop()
+	}()
+	_ = session.Dispose()
+	select {
+	case <-runLoopDone:
+	case <-time.After(2 * time.Second):
+		t.Fatal("run loop not exit")
+	}
+}
@@ -0,0 +1,312 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package gb28181
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/naza/pkg/bele"
+	"github.com/q191201771/naza/pkg/connection"
+	"github.com/q191201771/naza/pkg/nazaerrors"
+	"github.com/q191201771/naza/pkg/nazanet"
+)
+
+// push_session.go
+//
+// PushSession 将group中的音视频数据打包成ps over rtp，发送给上级平台（级联）
+
+const (
+	pushSessionTcpWriteChanSize = 1024
+	pushSessionDialTimeoutMs    = 5000
+)
+
+type PushSession struct {
+	streamName string
+
+	psPacker  *PsPacker
+	rtpPacker *RtpPsPacker
+
+	mode             string
+	waitKeyFrameFlag bool // 视频从关键帧开始发送
+
+	mutex       sync.Mutex // 保护tcpConn，被动模式下由RunLoop协程设置
+	udpConn     *nazanet.UdpConnection
+	raddr       *net.UDPAddr
+	listener    net.Listener
+	tcpConn     connection.Connection
+	disposeOnce sync.Once
+	sessionStat base.BasicSessionStat
+}
+
+func NewPushSession() *PushSession {
+	return &PushSession{
+		psPacker:         NewPsPacker(),
+		rtpPacker:        NewRtpPsPacker(0),
+		waitKeyFrameFlag: true,
+		sessionStat:      base.NewBasicSessionStat(base.SessionTypePsPush, ""),
+	}
+}
+
+func (session *PushSession) WithStreamName(streamName string) *PushSession {
+	session.streamName = streamName
+	return session
+}
+
+// WithSsrc 上级平台通过INVITE的SDP中的`y=`字段指定
+func (session *PushSession) WithSsrc(ssrc uint32) *PushSession {
+	session.rtpPacker = NewRtpPsPacker(ssrc)
+	return session
+}
+
+// Start 非阻塞函数
+//
+// @param mode:       见 MediaModeUdp 等，tcp主动模式时连接 remoteAddr
+// @param remoteAddr: 对端地址，被动模式时不需要
+// @param localPort:  本端端口，为0时内部自动选择
+//
+// @return 本端端口
+func (session *PushSession) Start(mode string, remoteAddr string, localPort int) (int, error) {
+	session.mode = mode
+	switch mode {
+	case MediaModeUdp:
+		return session.startUdp(remoteAddr, localPort)
+	case MediaModeTcpActive:
+		return session.startTcpActive(remoteAddr, localPort)
+	case MediaModeTcpPassive:
+		return session.startTcpPassive(localPort)
+	}
+	return -1, nazaerrors.Wrap(ErrGb28181, "invalid mode: "+mode)
+}
+
+// RunLoop 阻塞函数，对端断开连接或者调用 Dispose 后返回
+//
+// 注意，对端发送过来的数据（比如rtcp）直接丢弃
+func (session *PushSession) RunLoop() error {
+	var err error
+	switch session.mode {
+	case MediaModeUdp:
+		err = session.udpConn.RunLoop(func(b []byte, raddr *net.UDPAddr, err error) bool {
+			return !(len(b) == 0 && err != nil)
+		})
+	case MediaModeTcpActive:
+		err = session.runLoopTcpRead(session.tcpConn)
+	case MediaModeTcpPassive:
+		var conn net.Conn
+		conn, err = session.listener.Accept()
+		// 只接受一个连接
+		_ = session.listener.Close()
+		if err != nil {
+			break
+		}
+		Log.Infof("[%s] accept tcp conn. raddr=%s", session.UniqueKey(), conn.RemoteAddr().String())
+		c := session.newTcpConn(conn)
+		session.mutex.Lock()
+		session.tcpConn = c
+		session.sessionStat.SetRemoteAddr(conn.RemoteAddr().String())
+		session.mutex.Unlock()
+		err = session.runLoopTcpRead(c)
+	}
+	_ = session.dispose(err)
+	return err
+}
+
+// FeedAvPacket
+//
+// @param pkt: 见 PsPacker.Pack 的注释，内部不持有该内存块
+func (session *PushSession) FeedAvPacket(pkt base.AvPacket) {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	if session.udpConn == nil && session.tcpConn == nil {
+		// 被动模式，对端还没有连接
+		return
+	}
+
+	if pkt.IsVideo() && session.waitKeyFrameFlag {
+		if !isKeyFrameAnnexb(pkt.PayloadType == base.AvPacketPtAvc, pkt.Payload) {
+			return
+		}
+		session.waitKeyFrameFlag = false
+	}
+
+	ps, err := session.psPacker.Pack(pkt)
+	if err != nil {
+		return
+	}
+	for _, rtpPkt := range session.rtpPacker.Pack(ps, pkt.Timestamp) {
+		if session.udpConn != nil {
+			err = session.udpConn.Write2Addr(rtpPkt.Raw, session.raddr)
+		} else {
+			// RFC4571，2字节的长度
+			lb := make([]byte, 2)
+			bele.BePutUint16(lb, uint16(len(rtpPkt.Raw)))
+			_, err = session.tcpConn.Write(append(lb, rtpPkt.Raw...))
+		}
+		if err != nil {
+			Log.Warnf("[%s] write failed. err=%+v", session.UniqueKey(), err)
+			return
+		}
+		session.sessionStat.AddWriteBytes(len(rtpPkt.Raw))
+	}
+}
+
+// ----- IClientSessionLifecycle ---------------------------------------------------------------------------------------
+
+func (session *PushSession) Dispose() error {
+	return session.dispose(nil)
+}
+
+// ----- ISessionUrlContext --------------------------------------------------------------------------------------------
+
+func (session *PushSession) Url() string {
+	Log.Warnf("[%s] PushSession.Url() is not implemented", session.UniqueKey())
+	return "invalid"
+}
+
+func (session *PushSession) AppName() string {
+	Log.Warnf("[%s] PushSession.AppName() is not implemented", session.UniqueKey())
+	return "invalid"
+}
+
+func (session *PushSession) StreamName() string {
+	return session.streamName
+}
+
+func (session *PushSession) RawQuery() string {
+	Log.Warnf("[%s] PushSession.RawQuery() is not implemented", session.UniqueKey())
+	return "invalid"
+}
+
+// ----- IObject -------------------------------------------------------------------------------------------------------
+
+func (session *PushSession) UniqueKey() string {
+	return session.sessionStat.UniqueKey()
+}
+
+// ----- ISessionStat --------------------------------------------------------------------------------------------------
+
+func (session *PushSession) UpdateStat(intervalSec uint32) {
+	session.sessionStat.UpdateStat(intervalSec)
+}
+
+func (session *PushSession) GetStat() base.StatSession {
+	return session.sessionStat.GetStat()
+}
+
+// IsAlive
+//
+// 注意，被动模式下，对端还没有连接时，认为是活跃的
+func (session *PushSession) IsAlive() (readAlive, writeAlive bool) {
+	session.mutex.Lock()
+	waiting := session.mode == MediaModeTcpPassive && session.tcpConn == nil
+	session.mutex.Unlock()
+	if waiting {
+		return true, true
+	}
+	return session.sessionStat.IsAlive()
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+func (session *PushSession) startUdp(remoteAddr string, localPort int) (int, error) {
+	var err error
+	if session.raddr, err = net.ResolveUDPAddr("udp", remoteAddr); err != nil {
+		return -1, err
+	}
+
+	var uconn *net.UDPConn
+	var laddr string
+	if localPort == 0 {
+		if uconn, _, err = defaultUdpConnPoll.Acquire(); err != nil {
+			return -1, err
+		}
+		localPort = uconn.LocalAddr().(*net.UDPAddr).Port
+	} else {
+		laddr = fmt.Sprintf(":%d", localPort)
+	}
+
+	session.udpConn, err = nazanet.NewUdpConnection(func(option *nazanet.UdpConnectionOption) {
+		option.LAddr = laddr
+		option.Conn = uconn
+	})
+	if err != nil {
+		return -1, err
+	}
+	session.sessionStat.SetRemoteAddr(remoteAddr)
+	return localPort, nil
+}
+
+func (session *PushSession) startTcpActive(remoteAddr string, localPort int) (int, error) {
+	dialer := net.Dialer{Timeout: pushSessionDialTimeoutMs * time.Millisecond}
+	if localPort != 0 {
+		dialer.LocalAddr = &net.TCPAddr{Port: localPort}
+	}
+	conn, err := dialer.Dial("tcp", remoteAddr)
+	if err != nil {
+		return -1, err
+	}
+	session.tcpConn = session.newTcpConn(conn)
+	session.sessionStat.SetRemoteAddr(remoteAddr)
+	return conn.LocalAddr().(*net.TCPAddr).Port, nil
+}
+
+func (session *PushSession) startTcpPassive(localPort int) (int, error) {
+	var err error
+	if localPort == 0 {
+		for i := defaultPubSessionPortMin; i < defaultPubSessionPortMax; i++ {
+			if session.listener, err = net.Listen("tcp", fmt.Sprintf(":%d", i)); err == nil {
+				return int(i), nil
+			}
+		}
+		return -1, err
+	}
+
+	session.listener, err = net.Listen("tcp", fmt.Sprintf(":%d", localPort))
+	return localPort, err
+}
+
+func (session *PushSession) newTcpConn(conn net.Conn) connection.Connection {
+	return connection.New(conn, func(option *connection.Option) {
+		option.WriteChanSize = pushSessionTcpWriteChanSize
+	})
+}
+
+func (session *PushSession) runLoopTcpRead(conn connection.Connection) error {
+	buf := make([]byte, 1500)
+	for {
+		if _, err := conn.Read(buf); err != nil {
+			return err
+		}
+	}
+}
+
+func (session *PushSession) dispose(err error) error {
+	var retErr error
+	session.disposeOnce.Do(func() {
+		Log.Infof("[%s] lifecycle dispose gb28181 PushSession. err=%+v", session.UniqueKey(), err)
+
+		session.mutex.Lock()
+		defer session.mutex.Unlock()
+
+		if session.listener != nil {
+			_ = session.listener.Close()
+		}
+		if session.tcpConn != nil {
+			retErr = session.tcpConn.Close()
+		}
+		if session.udpConn != nil {
+			retErr = session.udpConn.Dispose()
+		}
+	})
+	return retErr
+}
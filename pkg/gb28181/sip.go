@@ -0,0 +1,306 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package gb28181
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/naza/pkg/nazaerrors"
+)
+
+// sip.go
+//
+// SIP消息的解析与打包（RFC3261），只实现了GB28181信令需要的部分
+
+const (
+	SipMethodRegister = "REGISTER"
+	SipMethodMessage  = "MESSAGE"
+	SipMethodInvite   = "INVITE"
+	SipMethodAck      = "ACK"
+	SipMethodBye      = "BYE"
+	SipMethodCancel   = "CANCEL"
+	SipMethodOptions  = "OPTIONS"
+	SipMethodInfo     = "INFO"
+
+	sipVersion = "SIP/2.0"
+
+	sipMaxMessageSize = 64 * 1024
+
+	// 读取TCP流时，单行以及所有header（包含起始行）的最大长度
+	sipMaxLineSize   = 4096
+	sipMaxHeaderSize = 16 * 1024
+)
+
+type sipHeader struct {
+	name  string
+	value string
+}
+
+// SipMessage 请求或响应
+type SipMessage struct {
+	// 请求
+	Method     string
+	RequestUri string
+
+	// 响应
+	StatusCode int
+	Reason     string
+
+	headers []sipHeader // 保持原始顺序，Via可能有多个
+	Body    []byte
+}
+
+func NewSipRequest(method, requestUri string) *SipMessage {
+	return &SipMessage{
+		Method:     method,
+		RequestUri: requestUri,
+	}
+}
+
+// NewSipResponse 根据请求构造响应，拷贝Via、From、To、Call-ID、CSeq
+func NewSipResponse(req *SipMessage, statusCode int, reason string) *SipMessage {
+	resp := &SipMessage{
+		StatusCode: statusCode,
+		Reason:     reason,
+	}
+	for _, h := range req.headers {
+		switch h.name {
+		case "Via", "From", "To", "Call-ID", "CSeq":
+			resp.headers = append(resp.headers, h)
+		}
+	}
+	return resp
+}
+
+func (m *SipMessage) IsRequest() bool {
+	return m.Method != ""
+}
+
+// Header 获取第一个名称匹配的header，名称不区分大小写，并且支持紧凑形式（比如`v`对应`Via`）
+func (m *SipMessage) Header(name string) string {
+	name = canonicalSipHeaderName(name)
+	for _, h := range m.headers {
+		if h.name == name {
+			return h.value
+		}
+	}
+	return ""
+}
+
+// SetHeader 替换所有同名的header
+func (m *SipMessage) SetHeader(name, value string) {
+	name = canonicalSipHeaderName(name)
+	for i := range m.headers {
+		if m.headers[i].name == name {
+			m.headers[i].value = value
+			m.delHeaderFrom(name, i+1)
+			return
+		}
+	}
+	m.headers = append(m.headers, sipHeader{name: name, value: value})
+}
+
+func (m *SipMessage) AddHeader(name, value string) {
+	m.headers = append(m.headers, sipHeader{name: canonicalSipHeaderName(name), value: value})
+}
+
+// CSeq 解析CSeq header
+func (m *SipMessage) CSeq() (seq int, method string) {
+	items := strings.Fields(m.Header("CSeq"))
+	if len(items) != 2 {
+		return 0, ""
+	}
+	seq, _ = strconv.Atoi(items[0])
+	return seq, items[1]
+}
+
+// Pack 序列化，Content-Length 根据 Body 自动设置
+func (m *SipMessage) Pack() []byte {
+	var buf bytes.Buffer
+	if m.IsRequest() {
+		buf.WriteString(fmt.Sprintf("%s %s %s\r\n", m.Method, m.RequestUri, sipVersion))
+	} else {
+		buf.WriteString(fmt.Sprintf("%s %d %s\r\n", sipVersion, m.StatusCode, m.Reason))
+	}
+	for _, h := range m.headers {
+		if h.name == "Content-Length" {
+			continue
+		}
+		buf.WriteString(fmt.Sprintf("%s: %s\r\n", h.name, h.value))
+	}
+	buf.WriteString(fmt.Sprintf("Content-Length: %d\r\n\r\n", len(m.Body)))
+	buf.Write(m.Body)
+	return buf.Bytes()
+}
+
+// ParseSipMessage 解析一个完整的SIP消息（比如一个UDP包）
+func ParseSipMessage(b []byte) (*SipMessage, error) {
+	return ReadSipMessage(bufio.NewReader(bytes.NewReader(b)))
+}
+
+// ReadSipMessage 从流中读取一个SIP消息（TCP），使用Content-Length分割
+func ReadSipMessage(r *bufio.Reader) (*SipMessage, error) {
+	var m SipMessage
+	remain := sipMaxHeaderSize
+
+	// 跳过消息之间的空行（比如TCP的keepalive `\r\n\r\n`）
+	var line string
+	for line == "" {
+		l, err := readSipLine(r, &remain)
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(l)
+	}
+
+	items := strings.SplitN(line, " ", 3)
+	if len(items) != 3 {
+		return nil, nazaerrors.Wrap(base.ErrGb28181, line)
+	}
+	if items[0] == sipVersion {
+		code, err := strconv.Atoi(items[1])
+		if err != nil {
+			return nil, nazaerrors.Wrap(base.ErrGb28181, line)
+		}
+		m.StatusCode = code
+		m.Reason = items[2]
+	} else {
+		if items[2] != sipVersion {
+			return nil, nazaerrors.Wrap(base.ErrGb28181, line)
+		}
+		m.Method = items[0]
+		m.RequestUri = items[1]
+	}
+
+	for {
+		l, err := readSipLine(r, &remain)
+		if err != nil {
+			return nil, err
+		}
+		l = strings.TrimRight(l, "\r\n")
+		if l == "" {
+			break
+		}
+		index := strings.Index(l, ":")
+		if index == -1 {
+			return nil, nazaerrors.Wrap(base.ErrGb28181, l)
+		}
+		m.AddHeader(strings.TrimSpace(l[:index]), strings.TrimSpace(l[index+1:]))
+	}
+
+	if cl := m.Header("Content-Length"); cl != "" {
+		n, err := strconv.Atoi(cl)
+		if err != nil || n < 0 || n > sipMaxMessageSize {
+			return nil, nazaerrors.Wrap(base.ErrGb28181, "invalid content length: "+cl)
+		}
+		m.Body = make([]byte, n)
+		if _, err = io.ReadFull(r, m.Body); err != nil {
+			return nil, err
+		}
+	}
+	return &m, nil
+}
+
+// readSipLine 读取一行，超过 sipMaxLineSize 或者超过 remain 时返回错误，避免恶意的TCP连接无限制的占用内存
+func readSipLine(r *bufio.Reader, remain *int) (string, error) {
+	var line []byte
+	for {
+		b, err := r.ReadSlice('\n')
+		line = append(line, b...)
+		if len(line) > sipMaxLineSize || len(line) > *remain {
+			return "", nazaerrors.Wrap(base.ErrGb28181, "sip header too large")
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		*remain -= len(line)
+		return string(line), nil
+	}
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// getSipHeaderParam 获取header中`;`分隔的参数，比如From中的tag，Via中的branch
+func getSipHeaderParam(value, key string) string {
+	for _, item := range strings.Split(value, ";")[1:] {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if strings.EqualFold(kv[0], key) {
+			if len(kv) == 2 {
+				return kv[1]
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// getSipUriUser 获取From、To等header中uri的user部分，比如`<sip:34020000001320000001@3402000000>;tag=xxx`中的34020000001320000001
+func getSipUriUser(value string) string {
+	index := strings.Index(value, "sip:")
+	if index == -1 {
+		return ""
+	}
+	s := value[index+len("sip:"):]
+	if end := strings.IndexAny(s, "@>;"); end != -1 {
+		s = s[:end]
+	}
+	return s
+}
+
+func canonicalSipHeaderName(name string) string {
+	switch strings.ToLower(name) {
+	case "v", "via":
+		return "Via"
+	case "f", "from":
+		return "From"
+	case "t", "to":
+		return "To"
+	case "i", "call-id":
+		return "Call-ID"
+	case "m", "contact":
+		return "Contact"
+	case "l", "content-length":
+		return "Content-Length"
+	case "c", "content-type":
+		return "Content-Type"
+	case "cseq":
+		return "CSeq"
+	case "www-authenticate":
+		return "WWW-Authenticate"
+	}
+	// 首字母以及`-`后的字母大写
+	b := []byte(strings.ToLower(name))
+	upper := true
+	for i := range b {
+		if upper && b[i] >= 'a' && b[i] <= 'z' {
+			b[i] -= 'a' - 'A'
+		}
+		upper = b[i] == '-'
+	}
+	return string(b)
+}
+
+func (m *SipMessage) delHeaderFrom(name string, from int) {
+	headers := m.headers[:from]
+	for _, h := range m.headers[from:] {
+		if h.name != name {
+			headers = append(headers, h)
+		}
+	}
+	m.headers = headers
+}
@@ -0,0 +1,997 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package gb28181
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/naza/pkg/nazaerrors"
+	"github.com/q191201771/naza/pkg/nazamd5"
+)
+
+// sip_server.go
+//
+// GB28181 SIP信令服务（UAS），配合 PubSession 使用，使得lalserver不依赖外部的SIP平台就可以从摄像头拉流
+//
+// - REGISTER: 支持digest鉴权（ SipServerConfig.Password 为空时不鉴权），注册成功后自动查询设备目录（Catalog）
+// - MESSAGE: 处理Keepalive以及Catalog响应，超过 SipServerConfig.KeepaliveTimeoutMs 没有收到心跳的设备会被移除
+// - INVITE/BYE: 由上层调用 SipServer.Invite 和 SipServer.Bye ，SDP中的媒体地址指向 PubSession 监听的端口
+//
+// 同一个地址同时监听UDP和TCP
+
+type SipServerConfig struct {
+	Addr     string // 比如 ":5060"
+	ServerId string // 20位的SIP服务器编码，比如 34020000002000000001
+	Realm    string // 10位的SIP域，比如 3402000000
+	Password string // 为空时不鉴权
+
+	// LocalIp 写入Via、Contact以及SDP中的IP，需要设备能访问到
+	// 为空时，根据访问设备的路由自动选择
+	LocalIp string
+
+	KeepaliveTimeoutMs   int // 默认180000
+	TransactionTimeoutMs int // 请求等待响应的超时时间，默认5000
+}
+
+// InviteParam
+type InviteParam struct {
+	DeviceId  string
+	ChannelId string
+	MediaIp   string // 为空时使用 SipServerConfig.LocalIp 的规则
+	MediaPort int    // PubSession 监听的端口，TCP主动模式时不需要
+	IsTcpFlag bool   // 为true时，使用TCP被动模式（设备主动连接 MediaPort ）
+
+	// IsTcpActiveFlag 为true时，使用TCP主动模式（本端连接设备在SDP应答中给出的媒体地址），需要同时设置 IsTcpFlag
+	IsTcpActiveFlag bool
+
+	// 以下用于历史视频的回放以及下载
+	SessionName   string // 见 InviteSessionNamePlay 等，为空时表示实时流
+	StartTime     int64  // unix时间戳，单位秒
+	EndTime       int64
+	DownloadSpeed int // 下载倍速，仅 InviteSessionNameDownload 时有效，为0时不指定
+}
+
+// SDP中的`s=`字段，GB28181附录F
+const (
+	InviteSessionNamePlay     = "Play"     // 实时流
+	InviteSessionNamePlayback = "Playback" // 历史视频回放，可以通过 SipServer.SetScale 控制倍速
+	InviteSessionNameDownload = "Download" // 历史视频下载
+)
+
+// InviteResult
+type InviteResult struct {
+	CallId string // 用于 Bye
+
+	// 设备在SDP应答中给出的媒体地址，TCP主动模式时本端连接该地址
+	MediaIp   string
+	MediaPort int
+}
+
+// OnSipBye dialog被动结束时回调，包括设备发送BYE，以及历史视频回放、下载结束（MediaStatus 121）
+type OnSipBye func(callId string)
+
+const (
+	defaultSipKeepaliveTimeoutMs   = 180000
+	defaultSipTransactionTimeoutMs = 5000
+	defaultSipRegisterExpires      = 3600
+
+	sipNonceExpire      = 5 * time.Minute
+	sipMaxNonceNum      = 4096
+	sipRetransmitT1     = 500 * time.Millisecond
+	sipCheckInterval    = 10 * time.Second
+	sipContentTypeSdp   = "APPLICATION/SDP"
+	sipContentTypeXml   = "Application/MANSCDP+xml"
+	sipBranchMagic      = "z9hG4bK"
+	sipDateFormat       = "2006-01-02T15:04:05.000"
+	sipTransportUdp     = "UDP"
+	sipTransportTcp     = "TCP"
+	sipManscdpCatalog   = "Catalog"
+	sipManscdpKeepalive = "Keepalive"
+
+	sipManscdpMediaStatus        = "MediaStatus"
+	sipManscdpNotifyTypeMediaEnd = "121" // 历史视频回放或下载结束
+	sipContentTypeMansrtsp       = "Application/MANSRTSP"
+)
+
+type SipServer struct {
+	config SipServerConfig
+
+	onBye OnSipBye
+
+	udpConn     *net.UDPConn
+	tcpListener net.Listener
+	disposeOnce sync.Once
+	exitChan    chan struct{}
+
+	mutex        sync.Mutex
+	devices      map[string]*sipDevice       // device id -> device
+	nonces       map[string]time.Time        // nonce -> 下发时间
+	transactions map[string]chan *SipMessage // transactionKey -> 响应
+	dialogs      map[string]*sipDialog       // call id -> dialog
+	sn           int                         // MANSCDP的SN
+	ssrcSeq      int                         // SSRC的后4位
+}
+
+type sipTransport struct {
+	isTcp   bool
+	udpAddr *net.UDPAddr
+	tcpConn net.Conn
+	tcpMu   *sync.Mutex
+}
+
+type sipDevice struct {
+	id            string
+	transport     sipTransport
+	registerTime  time.Time
+	keepaliveTime time.Time
+	expires       time.Duration
+	channels      []base.StatGb28181Channel
+}
+
+type sipDialog struct {
+	deviceId    string
+	requestUri  string
+	from        string
+	to          string
+	cseq        int
+	historyFlag bool // 历史视频回放或下载
+	infoCseq    int  // MANSRTSP的CSeq
+}
+
+func NewSipServer(config SipServerConfig) *SipServer {
+	if config.KeepaliveTimeoutMs <= 0 {
+		config.KeepaliveTimeoutMs = defaultSipKeepaliveTimeoutMs
+	}
+	if config.TransactionTimeoutMs <= 0 {
+		config.TransactionTimeoutMs = defaultSipTransactionTimeoutMs
+	}
+	return &SipServer{
+		config:       config,
+		exitChan:     make(chan struct{}),
+		devices:      make(map[string]*sipDevice),
+		nonces:       make(map[string]time.Time),
+		transactions: make(map[string]chan *SipMessage),
+		dialogs:      make(map[string]*sipDialog),
+	}
+}
+
+// WithOnBye 设备主动发送BYE时回调
+func (s *SipServer) WithOnBye(onBye OnSipBye) *SipServer {
+	s.onBye = onBye
+	return s
+}
+
+func (s *SipServer) Listen() (err error) {
+	addr, err := net.ResolveUDPAddr("udp", s.config.Addr)
+	if err != nil {
+		return err
+	}
+	if s.udpConn, err = net.ListenUDP("udp", addr); err != nil {
+		return err
+	}
+	if s.tcpListener, err = net.Listen("tcp", s.config.Addr); err != nil {
+		_ = s.udpConn.Close()
+		return err
+	}
+	Log.Infof("start gb28181 sip server listen. addr=%s", s.config.Addr)
+	return nil
+}
+
+// RunLoop 阻塞函数
+func (s *SipServer) RunLoop() error {
+	go s.runCheckLoop()
+	go s.runTcpAcceptLoop()
+
+	buf := make([]byte, sipMaxMessageSize)
+	for {
+		n, raddr, err := s.udpConn.ReadFromUDP(buf)
+		if err != nil {
+			return err
+		}
+		m, err := ParseSipMessage(buf[:n])
+		if err != nil {
+			Log.Warnf("parse sip message failed. raddr=%s, err=%+v", raddr.String(), err)
+			continue
+		}
+		s.handleMessage(m, sipTransport{udpAddr: raddr})
+	}
+}
+
+func (s *SipServer) Dispose() {
+	s.disposeOnce.Do(func() {
+		close(s.exitChan)
+		if s.udpConn != nil {
+			_ = s.udpConn.Close()
+		}
+		if s.tcpListener != nil {
+			_ = s.tcpListener.Close()
+		}
+	})
+}
+
+// LocalPort 实际监听的端口，监听端口为0时可以通过这个接口获取
+func (s *SipServer) LocalPort() int {
+	return s.udpConn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// GetDevices 获取所有已注册的设备
+func (s *SipServer) GetDevices() (devices []base.StatGb28181Device) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, d := range s.devices {
+		devices = append(devices, base.StatGb28181Device{
+			DeviceId:      d.id,
+			Transport:     d.transport.name(),
+			RemoteAddr:    d.transport.remoteAddr(),
+			RegisterTime:  d.registerTime.Format(sipDateFormat),
+			KeepaliveTime: d.keepaliveTime.Format(sipDateFormat),
+			Channels:      append([]base.StatGb28181Channel{}, d.channels...),
+		})
+	}
+	return
+}
+
+// QueryCatalog 向设备查询目录，非阻塞，结果通过 GetDevices 获取
+func (s *SipServer) QueryCatalog(deviceId string) error {
+	s.mutex.Lock()
+	d, ok := s.devices[deviceId]
+	if !ok {
+		s.mutex.Unlock()
+		return nazaerrors.Wrap(base.ErrGb28181DeviceNotFound, deviceId)
+	}
+	s.sn++
+	sn := s.sn
+	transport := d.transport
+	s.mutex.Unlock()
+
+	body := fmt.Sprintf("<?xml version=\"1.0\" encoding=\"GB2312\"?>\r\n<Query>\r\n<CmdType>%s</CmdType>\r\n<SN>%d</SN>\r\n<DeviceID>%s</DeviceID>\r\n</Query>\r\n",
+		sipManscdpCatalog, sn, deviceId)
+	req := s.newRequest(SipMethodMessage, deviceId, transport, newSipCallId(), 1)
+	req.AddHeader("Content-Type", sipContentTypeXml)
+	req.Body = []byte(body)
+
+	go func() {
+		resp, err := s.request(transport, req)
+		if err != nil {
+			Log.Warnf("query catalog failed. device=%s, err=%+v", deviceId, err)
+			return
+		}
+		if resp.StatusCode != 200 {
+			Log.Warnf("query catalog failed. device=%s, status=%d", deviceId, resp.StatusCode)
+		}
+	}()
+	return nil
+}
+
+// Invite 阻塞直到设备回复或超时
+func (s *SipServer) Invite(param InviteParam) (result InviteResult, err error) {
+	s.mutex.Lock()
+	d, ok := s.devices[param.DeviceId]
+	if !ok {
+		s.mutex.Unlock()
+		return result, nazaerrors.Wrap(base.ErrGb28181DeviceNotFound, param.DeviceId)
+	}
+	transport := d.transport
+	historyFlag := param.SessionName == InviteSessionNamePlayback || param.SessionName == InviteSessionNameDownload
+	ssrc := s.makeSsrc(historyFlag)
+	s.mutex.Unlock()
+
+	mediaIp := param.MediaIp
+	if mediaIp == "" {
+		mediaIp = s.localIp(transport)
+	}
+
+	callId := newSipCallId()
+	req := s.newRequest(SipMethodInvite, param.ChannelId, transport, callId, 1)
+	req.AddHeader("Content-Type", sipContentTypeSdp)
+	req.AddHeader("Subject", fmt.Sprintf("%s:%s,%s:0", param.ChannelId, ssrc, s.config.ServerId))
+	req.Body = packInviteSdp(param, mediaIp, ssrc)
+
+	resp, err := s.request(transport, req)
+	if err != nil {
+		return result, err
+	}
+	if resp.StatusCode != 200 {
+		return result, nazaerrors.Wrap(base.ErrGb28181, fmt.Sprintf("invite failed. status=%d %s", resp.StatusCode, resp.Reason))
+	}
+	result.CallId = callId
+	result.MediaIp, result.MediaPort = parseSdpMediaAddr(resp.Body)
+	if param.IsTcpActiveFlag && (result.MediaIp == "" || result.MediaPort == 0) {
+		err = nazaerrors.Wrap(base.ErrGb28181, "invalid answer sdp: "+string(resp.Body))
+	}
+
+	// ACK使用响应中的To（带tag）
+	ack := s.newRequest(SipMethodAck, param.ChannelId, transport, callId, 1)
+	ack.SetHeader("From", req.Header("From"))
+	ack.SetHeader("To", resp.Header("To"))
+	if sErr := s.send(transport, ack); sErr != nil {
+		return result, sErr
+	}
+
+	s.mutex.Lock()
+	s.dialogs[callId] = &sipDialog{
+		deviceId:    param.DeviceId,
+		requestUri:  req.RequestUri,
+		from:        req.Header("From"),
+		to:          resp.Header("To"),
+		cseq:        1,
+		historyFlag: historyFlag,
+	}
+	s.mutex.Unlock()
+	if err != nil {
+		// 对端的应答无法使用，但是dialog已经建立，由调用方决定是否 Bye
+		return result, err
+	}
+	Log.Infof("gb28181 invite succ. device=%s, channel=%s, session=%s, media=%s:%d, tcp=%v, active=%v, answer=%s:%d, call id=%s",
+		param.DeviceId, param.ChannelId, param.SessionName, mediaIp, param.MediaPort, param.IsTcpFlag, param.IsTcpActiveFlag,
+		result.MediaIp, result.MediaPort, callId)
+	return result, nil
+}
+
+// Bye 阻塞直到设备回复或超时
+func (s *SipServer) Bye(callId string) error {
+	s.mutex.Lock()
+	dialog, ok := s.dialogs[callId]
+	if !ok {
+		s.mutex.Unlock()
+		return nazaerrors.Wrap(base.ErrGb28181, "dialog not found: "+callId)
+	}
+	delete(s.dialogs, callId)
+	d, ok := s.devices[dialog.deviceId]
+	if !ok {
+		s.mutex.Unlock()
+		return nazaerrors.Wrap(base.ErrGb28181DeviceNotFound, dialog.deviceId)
+	}
+	transport := d.transport
+	s.mutex.Unlock()
+
+	req := s.newRequest(SipMethodBye, getSipUriUser(dialog.to), transport, callId, dialog.cseq+1)
+	req.RequestUri = dialog.requestUri
+	req.SetHeader("From", dialog.from)
+	req.SetHeader("To", dialog.to)
+	resp, err := s.request(transport, req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != 200 {
+		return nazaerrors.Wrap(base.ErrGb28181, fmt.Sprintf("bye failed. status=%d %s", resp.StatusCode, resp.Reason))
+	}
+	return nil
+}
+
+// SetScale 历史视频回放时，通过MANSRTSP控制倍速，阻塞直到设备回复或超时
+//
+// @param scale: 播放倍速，比如0.5、1、2、4
+func (s *SipServer) SetScale(callId string, scale float64) error {
+	s.mutex.Lock()
+	dialog, ok := s.dialogs[callId]
+	if !ok {
+		s.mutex.Unlock()
+		return nazaerrors.Wrap(base.ErrGb28181, "dialog not found: "+callId)
+	}
+	if !dialog.historyFlag {
+		s.mutex.Unlock()
+		return nazaerrors.Wrap(base.ErrGb28181, "not a playback dialog: "+callId)
+	}
+	d, ok := s.devices[dialog.deviceId]
+	if !ok {
+		s.mutex.Unlock()
+		return nazaerrors.Wrap(base.ErrGb28181DeviceNotFound, dialog.deviceId)
+	}
+	transport := d.transport
+	dialog.cseq++
+	dialog.infoCseq++
+	cseq, infoCseq := dialog.cseq, dialog.infoCseq
+	s.mutex.Unlock()
+
+	req := s.newRequest(SipMethodInfo, getSipUriUser(dialog.to), transport, callId, cseq)
+	req.RequestUri = dialog.requestUri
+	req.SetHeader("From", dialog.from)
+	req.SetHeader("To", dialog.to)
+	req.AddHeader("Content-Type", sipContentTypeMansrtsp)
+	req.Body = []byte(fmt.Sprintf("PLAY MANSRTSP/1.0\r\nCSeq: %d\r\nScale: %s\r\n",
+		infoCseq, strconv.FormatFloat(scale, 'f', 1, 64)))
+	resp, err := s.request(transport, req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != 200 {
+		return nazaerrors.Wrap(base.ErrGb28181, fmt.Sprintf("info failed. status=%d %s", resp.StatusCode, resp.Reason))
+	}
+	return nil
+}
+
+// ----- private -------------------------------------------------------------------------------------------------------
+
+func (s *SipServer) runTcpAcceptLoop() {
+	for {
+		conn, err := s.tcpListener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			transport := sipTransport{isTcp: true, tcpConn: conn, tcpMu: &sync.Mutex{}}
+			r := bufio.NewReader(conn)
+			for {
+				m, err := ReadSipMessage(r)
+				if err != nil {
+					Log.Debugf("sip tcp conn closed. raddr=%s, err=%+v", conn.RemoteAddr().String(), err)
+					_ = conn.Close()
+					return
+				}
+				s.handleMessage(m, transport)
+			}
+		}()
+	}
+}
+
+// runCheckLoop 移除心跳超时以及注册过期的设备，清理过期的nonce
+func (s *SipServer) runCheckLoop() {
+	t := time.NewTicker(sipCheckInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.exitChan:
+			return
+		case now := <-t.C:
+			s.mutex.Lock()
+			for id, d := range s.devices {
+				if now.Sub(d.keepaliveTime) > time.Duration(s.config.KeepaliveTimeoutMs)*time.Millisecond ||
+					now.Sub(d.registerTime) > d.expires {
+					Log.Infof("gb28181 device offline. device=%s", id)
+					delete(s.devices, id)
+				}
+			}
+			for nonce, t := range s.nonces {
+				if now.Sub(t) > sipNonceExpire {
+					delete(s.nonces, nonce)
+				}
+			}
+			s.mutex.Unlock()
+		}
+	}
+}
+
+func (s *SipServer) handleMessage(m *SipMessage, transport sipTransport) {
+	if !m.IsRequest() {
+		s.onResponse(m)
+		return
+	}
+
+	switch m.Method {
+	case SipMethodRegister:
+		s.onRegister(m, transport)
+	case SipMethodMessage:
+		s.onMessage(m, transport)
+	case SipMethodBye:
+		s.onRecvBye(m, transport)
+	case SipMethodAck:
+		// noop
+	case SipMethodOptions:
+		s.reply(m, transport, 200, "OK")
+	default:
+		s.reply(m, transport, 405, "Method Not Allowed")
+	}
+}
+
+func (s *SipServer) onRegister(req *SipMessage, transport sipTransport) {
+	deviceId := getSipUriUser(req.Header("From"))
+	if deviceId == "" {
+		s.reply(req, transport, 400, "Bad Request")
+		return
+	}
+
+	// Expires非法时直接拒绝，避免被当作0（也即注销）处理
+	expires := defaultSipRegisterExpires
+	if v := req.Header("Expires"); v != "" {
+		var err error
+		expires, err = strconv.Atoi(v)
+		if err != nil || expires < 0 {
+			s.reply(req, transport, 400, "Bad Request")
+			return
+		}
+	}
+
+	if s.config.Password != "" && !s.checkAuth(req, deviceId) {
+		nonce := randomHex(16)
+		s.addNonce(nonce)
+
+		resp := NewSipResponse(req, 401, "Unauthorized")
+		resp.AddHeader("WWW-Authenticate", fmt.Sprintf("Digest realm=\"%s\",nonce=\"%s\",algorithm=MD5", s.config.Realm, nonce))
+		_ = s.send(transport, resp)
+		return
+	}
+
+	resp := NewSipResponse(req, 200, "OK")
+	resp.SetHeader("To", addSipTag(req.Header("To")))
+	resp.AddHeader("Date", time.Now().Format(sipDateFormat))
+	resp.AddHeader("Expires", strconv.Itoa(expires))
+
+	s.mutex.Lock()
+	if expires == 0 {
+		delete(s.devices, deviceId)
+		s.mutex.Unlock()
+		Log.Infof("gb28181 device unregister. device=%s", deviceId)
+		_ = s.send(transport, resp)
+		return
+	}
+
+	now := time.Now()
+	d, exist := s.devices[deviceId]
+	if !exist {
+		d = &sipDevice{id: deviceId}
+		s.devices[deviceId] = d
+	}
+	d.transport = transport
+	d.registerTime = now
+	d.keepaliveTime = now
+	d.expires = time.Duration(expires) * time.Second
+	s.mutex.Unlock()
+
+	Log.Infof("gb28181 device register. device=%s, transport=%s, raddr=%s, expires=%d",
+		deviceId, transport.name(), transport.remoteAddr(), expires)
+	_ = s.send(transport, resp)
+
+	if !exist {
+		_ = s.QueryCatalog(deviceId)
+	}
+}
+
+func (s *SipServer) onMessage(req *SipMessage, transport sipTransport) {
+	deviceId := getSipUriUser(req.Header("From"))
+
+	var body manscdpMessage
+	decoder := xml.NewDecoder(bytes.NewReader(req.Body))
+	decoder.CharsetReader = charsetReaderAsUtf8
+	if err := decoder.Decode(&body); err != nil {
+		Log.Warnf("decode manscdp failed. device=%s, err=%+v", deviceId, err)
+		s.reply(req, transport, 400, "Bad Request")
+		return
+	}
+
+	s.mutex.Lock()
+	d, ok := s.devices[deviceId]
+	if !ok {
+		s.mutex.Unlock()
+		// 未注册的设备（比如lalserver重启后），回复403使设备重新注册
+		s.reply(req, transport, 403, "Forbidden")
+		return
+	}
+	var mediaEndCallId string
+	switch body.CmdType {
+	case sipManscdpMediaStatus:
+		if body.NotifyType == sipManscdpNotifyTypeMediaEnd {
+			mediaEndCallId = s.findHistoryDialog(req.Header("Call-ID"), deviceId)
+		}
+	case sipManscdpKeepalive:
+		d.keepaliveTime = time.Now()
+		d.transport = transport
+	case sipManscdpCatalog:
+		// 通道较多时设备会分多个消息回复，按通道编码去重合并
+		for _, item := range body.DeviceList.Items {
+			channel := base.StatGb28181Channel{
+				ChannelId:    item.DeviceId,
+				Name:         item.Name,
+				Manufacturer: item.Manufacturer,
+				Model:        item.Model,
+				Status:       item.Status,
+			}
+			replaced := false
+			for i := range d.channels {
+				if d.channels[i].ChannelId == channel.ChannelId {
+					d.channels[i] = channel
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				d.channels = append(d.channels, channel)
+			}
+		}
+	}
+	s.mutex.Unlock()
+
+	s.reply(req, transport, 200, "OK")
+
+	if mediaEndCallId != "" {
+		// 回放或下载结束，由本端结束dialog。
+		// 注意，BYE的响应也是由当前协程读取，所以需要在新的协程中发送
+		Log.Infof("gb28181 media end. device=%s, call id=%s", deviceId, mediaEndCallId)
+		go func() {
+			if err := s.Bye(mediaEndCallId); err != nil {
+				Log.Warnf("gb28181 bye failed. call id=%s, err=%+v", mediaEndCallId, err)
+			}
+			if s.onBye != nil {
+				s.onBye(mediaEndCallId)
+			}
+		}()
+	}
+}
+
+// findHistoryDialog MediaStatus通常在dialog内发送，按Call-ID匹配。
+// 部分设备使用新的Call-ID，此时如果该设备只有一个回放或下载的dialog，则匹配该dialog
+//
+// 注意，调用方需要持有锁
+func (s *SipServer) findHistoryDialog(callId, deviceId string) string {
+	if dialog, ok := s.dialogs[callId]; ok && dialog.historyFlag {
+		return callId
+	}
+	var ret string
+	for id, dialog := range s.dialogs {
+		if dialog.deviceId == deviceId && dialog.historyFlag {
+			if ret != "" {
+				return ""
+			}
+			ret = id
+		}
+	}
+	return ret
+}
+
+func (s *SipServer) onRecvBye(req *SipMessage, transport sipTransport) {
+	callId := req.Header("Call-ID")
+	s.mutex.Lock()
+	_, ok := s.dialogs[callId]
+	delete(s.dialogs, callId)
+	s.mutex.Unlock()
+
+	if !ok {
+		s.reply(req, transport, 481, "Call/Transaction Does Not Exist")
+		return
+	}
+	s.reply(req, transport, 200, "OK")
+
+	Log.Infof("gb28181 bye from device. call id=%s", callId)
+	if s.onBye != nil {
+		s.onBye(callId)
+	}
+}
+
+func (s *SipServer) onResponse(resp *SipMessage) {
+	seq, method := resp.CSeq()
+	key := transactionKey(resp.Header("Call-ID"), seq, method)
+
+	s.mutex.Lock()
+	ch, ok := s.transactions[key]
+	s.mutex.Unlock()
+	if !ok {
+		Log.Debugf("sip response without transaction. key=%s, status=%d", key, resp.StatusCode)
+		return
+	}
+	select {
+	case ch <- resp:
+	default:
+	}
+}
+
+// checkAuth RFC2617 digest鉴权
+//
+// username必须是设备ID，uri必须和请求的Request-URI一致，realm使用配置中的值，
+// nonce鉴权成功后即失效，避免截获的Authorization被重放
+func (s *SipServer) checkAuth(req *SipMessage, deviceId string) bool {
+	auth := req.Header("Authorization")
+	if !strings.HasPrefix(auth, "Digest ") {
+		return false
+	}
+	params := parseDigestParams(auth[len("Digest "):])
+	if params["username"] != deviceId || params["uri"] != req.RequestUri {
+		return false
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	nonce := params["nonce"]
+	t, ok := s.nonces[nonce]
+	if !ok || time.Since(t) > sipNonceExpire {
+		return false
+	}
+
+	ha1 := nazamd5.Md5([]byte(deviceId + ":" + s.config.Realm + ":" + s.config.Password))
+	ha2 := nazamd5.Md5([]byte(req.Method + ":" + params["uri"]))
+	var expected string
+	if params["qop"] != "" {
+		expected = nazamd5.Md5([]byte(ha1 + ":" + nonce + ":" + params["nc"] + ":" + params["cnonce"] + ":" + params["qop"] + ":" + ha2))
+	} else {
+		expected = nazamd5.Md5([]byte(ha1 + ":" + nonce + ":" + ha2))
+	}
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToLower(params["response"]))) != 1 {
+		return false
+	}
+	delete(s.nonces, nonce)
+	return true
+}
+
+// addNonce 数量达到上限时，先清理过期的nonce，如果依然达到上限，则随机淘汰一个
+func (s *SipServer) addNonce(nonce string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.nonces) >= sipMaxNonceNum {
+		now := time.Now()
+		for k, t := range s.nonces {
+			if now.Sub(t) > sipNonceExpire {
+				delete(s.nonces, k)
+			}
+		}
+		for k := range s.nonces {
+			if len(s.nonces) < sipMaxNonceNum {
+				break
+			}
+			delete(s.nonces, k)
+		}
+	}
+	s.nonces[nonce] = time.Now()
+}
+
+// request 发送请求并等待最终响应，UDP时按RFC3261的方式重传
+func (s *SipServer) request(transport sipTransport, req *SipMessage) (*SipMessage, error) {
+	seq, method := req.CSeq()
+	key := transactionKey(req.Header("Call-ID"), seq, method)
+	ch := make(chan *SipMessage, 8)
+
+	s.mutex.Lock()
+	s.transactions[key] = ch
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.transactions, key)
+		s.mutex.Unlock()
+	}()
+
+	if err := s.send(transport, req); err != nil {
+		return nil, err
+	}
+
+	timeout := time.NewTimer(time.Duration(s.config.TransactionTimeoutMs) * time.Millisecond)
+	defer timeout.Stop()
+	interval := sipRetransmitT1
+	retransmit := time.NewTimer(interval)
+	defer retransmit.Stop()
+	for {
+		select {
+		case resp := <-ch:
+			if resp.StatusCode >= 200 {
+				return resp, nil
+			}
+			// 收到临时响应后不再重传
+			retransmit.Stop()
+		case <-retransmit.C:
+			if !transport.isTcp {
+				_ = s.send(transport, req)
+				interval *= 2
+				retransmit.Reset(interval)
+			}
+		case <-timeout.C:
+			return nil, nazaerrors.Wrap(base.ErrGb28181, "sip transaction timeout: "+key)
+		case <-s.exitChan:
+			return nil, base.ErrSessionNotStarted
+		}
+	}
+}
+
+func (s *SipServer) reply(req *SipMessage, transport sipTransport, statusCode int, reason string) {
+	resp := NewSipResponse(req, statusCode, reason)
+	resp.SetHeader("To", addSipTag(req.Header("To")))
+	_ = s.send(transport, resp)
+}
+
+func (s *SipServer) send(transport sipTransport, m *SipMessage) error {
+	b := m.Pack()
+	if transport.isTcp {
+		transport.tcpMu.Lock()
+		defer transport.tcpMu.Unlock()
+		_, err := transport.tcpConn.Write(b)
+		return err
+	}
+	_, err := s.udpConn.WriteToUDP(b, transport.udpAddr)
+	return err
+}
+
+func (s *SipServer) newRequest(method, target string, transport sipTransport, callId string, cseq int) *SipMessage {
+	localIp := s.localIp(transport)
+	localPort := s.LocalPort()
+
+	req := NewSipRequest(method, fmt.Sprintf("sip:%s@%s", target, transport.remoteAddr()))
+	req.AddHeader("Via", fmt.Sprintf("%s/%s %s:%d;rport;branch=%s%s", sipVersion, transport.name(), localIp, localPort, sipBranchMagic, randomHex(8)))
+	req.AddHeader("From", fmt.Sprintf("<sip:%s@%s>;tag=%s", s.config.ServerId, s.config.Realm, randomHex(4)))
+	req.AddHeader("To", fmt.Sprintf("<sip:%s@%s>", target, s.config.Realm))
+	req.AddHeader("Call-ID", callId)
+	req.AddHeader("CSeq", fmt.Sprintf("%d %s", cseq, method))
+	req.AddHeader("Contact", fmt.Sprintf("<sip:%s@%s:%d>", s.config.ServerId, localIp, localPort))
+	req.AddHeader("Max-Forwards", "70")
+	req.AddHeader("User-Agent", base.LalLibraryName)
+	return req
+}
+
+func (s *SipServer) localIp(transport sipTransport) string {
+	if s.config.LocalIp != "" {
+		return s.config.LocalIp
+	}
+	if transport.isTcp {
+		return transport.tcpConn.LocalAddr().(*net.TCPAddr).IP.String()
+	}
+	// 不会真正发送数据，只是利用路由选择本地地址
+	conn, err := net.DialUDP("udp", nil, transport.udpAddr)
+	if err != nil {
+		return "127.0.0.1"
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String()
+}
+
+// makeSsrc GB28181附录F，10位十进制数，第1位0表示实时流、1表示历史流，第2到6位为SIP域的第4到8位，后4位为序号
+//
+// 注意，调用方需要持有锁
+func (s *SipServer) makeSsrc(historyFlag bool) string {
+	s.ssrcSeq = (s.ssrcSeq + 1) % 10000
+	domain := "00000"
+	if len(s.config.Realm) >= 8 {
+		domain = s.config.Realm[3:8]
+	}
+	first := 0
+	if historyFlag {
+		first = 1
+	}
+	return fmt.Sprintf("%d%s%04d", first, domain, s.ssrcSeq)
+}
+
+func (t sipTransport) name() string {
+	if t.isTcp {
+		return sipTransportTcp
+	}
+	return sipTransportUdp
+}
+
+func (t sipTransport) remoteAddr() string {
+	if t.isTcp {
+		return t.tcpConn.RemoteAddr().String()
+	}
+	return t.udpAddr.String()
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// manscdpMessage Keepalive、Catalog等MANSCDP消息，根节点可能是Notify、Response等，这里不区分
+type manscdpMessage struct {
+	CmdType    string `xml:"CmdType"`
+	SN         int    `xml:"SN"`
+	DeviceId   string `xml:"DeviceID"`
+	SumNum     int    `xml:"SumNum"`
+	NotifyType string `xml:"NotifyType"` // MediaStatus
+	DeviceList struct {
+		Items []struct {
+			DeviceId     string `xml:"DeviceID"`
+			Name         string `xml:"Name"`
+			Manufacturer string `xml:"Manufacturer"`
+			Model        string `xml:"Model"`
+			Status       string `xml:"Status"`
+		} `xml:"Item"`
+	} `xml:"DeviceList"`
+}
+
+// charsetReaderAsUtf8 设备通常使用GB2312编码，这里不做转换，只将非法的UTF-8字符替换掉，保证ASCII字段可以正常解析
+func charsetReaderAsUtf8(charset string, input io.Reader) (io.Reader, error) {
+	b, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	return strings.NewReader(strings.ToValidUTF8(string(b), "?")), nil
+}
+
+func packInviteSdp(param InviteParam, mediaIp string, ssrc string) []byte {
+	proto := "RTP/AVP"
+	if param.IsTcpFlag {
+		proto = "TCP/RTP/AVP"
+	}
+	sessionName := param.SessionName
+	if sessionName == "" {
+		sessionName = InviteSessionNamePlay
+	}
+	var sb strings.Builder
+	sb.WriteString("v=0\r\n")
+	sb.WriteString(fmt.Sprintf("o=%s 0 0 IN IP4 %s\r\n", param.ChannelId, mediaIp))
+	sb.WriteString(fmt.Sprintf("s=%s\r\n", sessionName))
+	if sessionName != InviteSessionNamePlay {
+		// 历史视频使用`u=`指定通道，`t=`指定时间范围
+		sb.WriteString(fmt.Sprintf("u=%s:0\r\n", param.ChannelId))
+	}
+	sb.WriteString(fmt.Sprintf("c=IN IP4 %s\r\n", mediaIp))
+	if sessionName != InviteSessionNamePlay {
+		sb.WriteString(fmt.Sprintf("t=%d %d\r\n", param.StartTime, param.EndTime))
+	} else {
+		sb.WriteString("t=0 0\r\n")
+	}
+	sb.WriteString(fmt.Sprintf("m=video %d %s 96 97 98\r\n", param.MediaPort, proto))
+	sb.WriteString("a=recvonly\r\n")
+	sb.WriteString("a=rtpmap:96 PS/90000\r\n")
+	sb.WriteString("a=rtpmap:97 MPEG4/90000\r\n")
+	sb.WriteString("a=rtpmap:98 H264/90000\r\n")
+	if param.IsTcpFlag {
+		if param.IsTcpActiveFlag {
+			sb.WriteString("a=setup:active\r\n")
+		} else {
+			sb.WriteString("a=setup:passive\r\n")
+		}
+		sb.WriteString("a=connection:new\r\n")
+	}
+	if sessionName == InviteSessionNameDownload && param.DownloadSpeed > 0 {
+		sb.WriteString(fmt.Sprintf("a=downloadspeed:%d\r\n", param.DownloadSpeed))
+	}
+	sb.WriteString(fmt.Sprintf("y=%s\r\n", ssrc))
+	return []byte(sb.String())
+}
+
+// parseSdpMediaAddr 解析SDP中的`c=`以及第一个`m=`，获取媒体地址
+func parseSdpMediaAddr(sdp []byte) (ip string, port int) {
+	for _, line := range strings.Split(string(sdp), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "c=") && ip == "" {
+			// c=IN IP4 192.168.1.64
+			if items := strings.Fields(line[2:]); len(items) == 3 {
+				ip = items[2]
+			}
+		} else if strings.HasPrefix(line, "m=") && port == 0 {
+			// m=video 15060 TCP/RTP/AVP 96
+			if items := strings.Fields(line[2:]); len(items) >= 2 {
+				port, _ = strconv.Atoi(items[1])
+			}
+		}
+	}
+	return
+}
+
+var digestParamRegexp = regexp.MustCompile(`(\w+)=("([^"]*)"|[^,\s]*)`)
+
+func parseDigestParams(s string) map[string]string {
+	params := make(map[string]string)
+	for _, m := range digestParamRegexp.FindAllStringSubmatch(s, -1) {
+		if strings.HasPrefix(m[2], "\"") {
+			params[m[1]] = m[3]
+		} else {
+			params[m[1]] = m[2]
+		}
+	}
+	return params
+}
+
+func transactionKey(callId string, cseq int, method string) string {
+	// ACK和CANCEL不会单独成为一个事务，这里不需要特殊处理
+	return fmt.Sprintf("%s %d %s", callId, cseq, method)
+}
+
+func addSipTag(to string) string {
+	if getSipHeaderParam(to, "tag") != "" {
+		return to
+	}
+	return to + ";tag=" + randomHex(4)
+}
+
+func newSipCallId() string {
+	return randomHex(16)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
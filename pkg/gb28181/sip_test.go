@@ -0,0 +1,283 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package gb28181
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/q191201771/naza/pkg/assert"
+	"github.com/q191201771/naza/pkg/nazamd5"
+)
+
+func TestSipMessage(t *testing.T) {
+	raw := "REGISTER sip:34020000002000000001@3402000000 SIP/2.0\r\n" +
+		"Via: SIP/2.0/UDP 192.168.1.64:5060;rport;branch=z9hG4bK1\r\n" +
+		"f: <sip:34020000001320000001@3402000000>;tag=1\r\n" +
+		"To: <sip:34020000001320000001@3402000000>\r\n" +
+		"Call-ID: 100\r\n" +
+		"CSeq: 1 REGISTER\r\n" +
+		"Expires: 3600\r\n" +
+		"Content-Length: 4\r\n" +
+		"\r\n" +
+		"body"
+	m, err := ParseSipMessage([]byte(raw))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, SipMethodRegister, m.Method)
+	assert.Equal(t, "34020000001320000001", getSipUriUser(m.Header("From")))
+	assert.Equal(t, "1", getSipHeaderParam(m.Header("from"), "tag"))
+	seq, method := m.CSeq()
+	assert.Equal(t, 1, seq)
+	assert.Equal(t, SipMethodRegister, method)
+	assert.Equal(t, []byte("body"), m.Body)
+
+	resp := NewSipResponse(m, 200, "OK")
+	m2, err := ParseSipMessage(resp.Pack())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 200, m2.StatusCode)
+	assert.Equal(t, "100", m2.Header("Call-ID"))
+	assert.Equal(t, "", m2.Header("Expires"))
+	assert.Equal(t, "0", m2.Header("Content-Length"))
+
+	// header过长
+	_, err = ParseSipMessage([]byte("REGISTER sip:a@b SIP/2.0\r\nX: " + strings.Repeat("a", sipMaxLineSize) + "\r\n\r\n"))
+	assert.IsNotNil(t, err)
+	_, err = ParseSipMessage([]byte("REGISTER sip:a@b SIP/2.0\r\n" + strings.Repeat("X: "+strings.Repeat("a", 1000)+"\r\n", sipMaxHeaderSize/1000+1) + "\r\n"))
+	assert.IsNotNil(t, err)
+}
+
+// TestSipServer 模拟一个UDP设备，完成注册（digest鉴权）、目录查询、心跳、INVITE以及BYE
+func TestSipServer(t *testing.T) {
+	server := NewSipServer(SipServerConfig{
+		Addr:                 "127.0.0.1:0",
+		ServerId:             "34020000002000000001",
+		Realm:                "3402000000",
+		Password:             "12345678",
+		TransactionTimeoutMs: 2000,
+	})
+	byeCh := make(chan string, 1)
+	server.WithOnBye(func(callId string) {
+		byeCh <- callId
+	})
+	assert.Equal(t, nil, server.Listen())
+	go server.RunLoop()
+	defer server.Dispose()
+
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: server.LocalPort()})
+	assert.Equal(t, nil, err)
+	defer conn.Close()
+
+	deviceId := "34020000001320000001"
+	recv := func() *SipMessage {
+		buf := make([]byte, sipMaxMessageSize)
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, err := conn.Read(buf)
+		assert.Equal(t, nil, err)
+		m, err := ParseSipMessage(buf[:n])
+		assert.Equal(t, nil, err)
+		return m
+	}
+	send := func(m *SipMessage) {
+		_, err := conn.Write(m.Pack())
+		assert.Equal(t, nil, err)
+	}
+	newReq := func(method string, cseq int) *SipMessage {
+		req := NewSipRequest(method, "sip:34020000002000000001@3402000000")
+		req.AddHeader("Via", "SIP/2.0/UDP "+conn.LocalAddr().String()+";rport;branch=z9hG4bK"+randomHex(4))
+		req.AddHeader("From", fmt.Sprintf("<sip:%s@3402000000>;tag=abc", deviceId))
+		req.AddHeader("To", fmt.Sprintf("<sip:%s@3402000000>", deviceId))
+		req.AddHeader("Call-ID", "device-call-"+method)
+		req.AddHeader("CSeq", fmt.Sprintf("%d %s", cseq, method))
+		return req
+	}
+
+	// 注册，第一次没有鉴权信息
+	send(newReq(SipMethodRegister, 1))
+	resp := recv()
+	assert.Equal(t, 401, resp.StatusCode)
+	params := parseDigestParams(strings.TrimPrefix(resp.Header("WWW-Authenticate"), "Digest "))
+	assert.Equal(t, "3402000000", params["realm"])
+
+	uri := "sip:34020000002000000001@3402000000"
+	ha1 := nazamd5.Md5([]byte(deviceId + ":3402000000:12345678"))
+	ha2 := nazamd5.Md5([]byte("REGISTER:" + uri))
+	req := newReq(SipMethodRegister, 2)
+	req.AddHeader("Authorization", fmt.Sprintf(`Digest username="%s", realm="3402000000", nonce="%s", uri="%s", response="%s", algorithm=MD5`,
+		deviceId, params["nonce"], uri, nazamd5.Md5([]byte(ha1+":"+params["nonce"]+":"+ha2))))
+	send(req)
+	resp = recv()
+	assert.Equal(t, 200, resp.StatusCode)
+
+	// 注册成功后，服务端主动查询目录
+	query := recv()
+	assert.Equal(t, SipMethodMessage, query.Method)
+	assert.Equal(t, true, strings.Contains(string(query.Body), "<CmdType>Catalog</CmdType>"))
+	send(NewSipResponse(query, 200, "OK"))
+
+	catalog := newReq(SipMethodMessage, 3)
+	catalog.Body = []byte(`<?xml version="1.0" encoding="GB2312"?>
+<Response><CmdType>Catalog</CmdType><SN>1</SN><DeviceID>34020000001320000001</DeviceID><SumNum>1</SumNum>
+<DeviceList Num="1"><Item><DeviceID>34020000001310000001</DeviceID><Name>Camera 01</Name><Status>ON</Status></Item></DeviceList>
+</Response>`)
+	send(catalog)
+	assert.Equal(t, 200, recv().StatusCode)
+
+	keepalive := newReq(SipMethodMessage, 4)
+	keepalive.Body = []byte(`<?xml version="1.0"?><Notify><CmdType>Keepalive</CmdType><SN>2</SN><DeviceID>34020000001320000001</DeviceID><Status>OK</Status></Notify>`)
+	send(keepalive)
+	assert.Equal(t, 200, recv().StatusCode)
+
+	// nonce只能使用一次，重放的Authorization需要重新鉴权
+	send(req)
+	assert.Equal(t, 401, recv().StatusCode)
+
+	// username和设备ID不一致
+	send(newReq(SipMethodRegister, 6))
+	params = parseDigestParams(strings.TrimPrefix(recv().Header("WWW-Authenticate"), "Digest "))
+	other := "34020000001320000002"
+	ha1Other := nazamd5.Md5([]byte(other + ":3402000000:12345678"))
+	req = newReq(SipMethodRegister, 7)
+	req.AddHeader("Authorization", fmt.Sprintf(`Digest username="%s", realm="3402000000", nonce="%s", uri="%s", response="%s", algorithm=MD5`,
+		other, params["nonce"], uri, nazamd5.Md5([]byte(ha1Other+":"+params["nonce"]+":"+ha2))))
+	send(req)
+	assert.Equal(t, 401, recv().StatusCode)
+
+	// Expires非法
+	req = newReq(SipMethodRegister, 8)
+	req.AddHeader("Expires", "abc")
+	send(req)
+	assert.Equal(t, 400, recv().StatusCode)
+
+	devices := server.GetDevices()
+	assert.Equal(t, 1, len(devices))
+	assert.Equal(t, deviceId, devices[0].DeviceId)
+	assert.Equal(t, 1, len(devices[0].Channels))
+	assert.Equal(t, "34020000001310000001", devices[0].Channels[0].ChannelId)
+	assert.Equal(t, "Camera 01", devices[0].Channels[0].Name)
+
+	// INVITE
+	type inviteRet struct {
+		result InviteResult
+		err    error
+	}
+	inviteCh := make(chan inviteRet, 1)
+	go func() {
+		result, err := server.Invite(InviteParam{
+			DeviceId:  deviceId,
+			ChannelId: "34020000001310000001",
+			MediaIp:   "127.0.0.1",
+			MediaPort: 30000,
+		})
+		inviteCh <- inviteRet{result, err}
+	}()
+	invite := recv()
+	assert.Equal(t, SipMethodInvite, invite.Method)
+	sdp := string(invite.Body)
+	assert.Equal(t, true, strings.Contains(sdp, "m=video 30000 RTP/AVP 96 97 98\r\n"))
+	assert.Equal(t, true, strings.Contains(sdp, "c=IN IP4 127.0.0.1\r\n"))
+	assert.Equal(t, true, strings.Contains(sdp, "y=0200000001\r\n"))
+	send(NewSipResponse(invite, 100, "Trying"))
+	ok := NewSipResponse(invite, 200, "OK")
+	ok.SetHeader("To", invite.Header("To")+";tag=device")
+	ok.AddHeader("Content-Type", sipContentTypeSdp)
+	ok.Body = []byte("v=0\r\no=34020000001310000001 0 0 IN IP4 192.168.1.64\r\ns=Play\r\nc=IN IP4 192.168.1.64\r\nt=0 0\r\nm=video 15060 RTP/AVP 96\r\na=sendonly\r\ny=0200000001\r\n")
+	send(ok)
+	ack := recv()
+	assert.Equal(t, SipMethodAck, ack.Method)
+	assert.Equal(t, "device", getSipHeaderParam(ack.Header("To"), "tag"))
+	ret := <-inviteCh
+	assert.Equal(t, nil, ret.err)
+	assert.Equal(t, invite.Header("Call-ID"), ret.result.CallId)
+	assert.Equal(t, "192.168.1.64", ret.result.MediaIp)
+	assert.Equal(t, 15060, ret.result.MediaPort)
+
+	// 服务端BYE
+	byeDoneCh := make(chan error, 1)
+	go func() {
+		byeDoneCh <- server.Bye(ret.result.CallId)
+	}()
+	bye := recv()
+	assert.Equal(t, SipMethodBye, bye.Method)
+	seq, _ := bye.CSeq()
+	assert.Equal(t, 2, seq)
+	send(NewSipResponse(bye, 200, "OK"))
+	assert.Equal(t, nil, <-byeDoneCh)
+
+	// 设备BYE，dialog已经不存在
+	send(newReq(SipMethodBye, 5))
+	assert.Equal(t, 481, recv().StatusCode)
+	select {
+	case <-byeCh:
+		t.Fatal("unexpected bye callback")
+	default:
+	}
+
+	// 历史视频回放
+	go func() {
+		result, err := server.Invite(InviteParam{
+			DeviceId:    deviceId,
+			ChannelId:   "34020000001310000001",
+			MediaIp:     "127.0.0.1",
+			MediaPort:   30002,
+			SessionName: InviteSessionNamePlayback,
+			StartTime:   1700000000,
+			EndTime:     1700003600,
+		})
+		inviteCh <- inviteRet{result, err}
+	}()
+	invite = recv()
+	sdp = string(invite.Body)
+	assert.Equal(t, true, strings.Contains(sdp, "s=Playback\r\n"))
+	assert.Equal(t, true, strings.Contains(sdp, "u=34020000001310000001:0\r\n"))
+	assert.Equal(t, true, strings.Contains(sdp, "t=1700000000 1700003600\r\n"))
+	assert.Equal(t, true, strings.Contains(sdp, "y=1200000002\r\n"))
+	ok = NewSipResponse(invite, 200, "OK")
+	ok.SetHeader("To", invite.Header("To")+";tag=device")
+	send(ok)
+	assert.Equal(t, SipMethodAck, recv().Method)
+	ret = <-inviteCh
+	assert.Equal(t, nil, ret.err)
+
+	// 倍速
+	scaleDoneCh := make(chan error, 1)
+	go func() {
+		scaleDoneCh <- server.SetScale(ret.result.CallId, 2)
+	}()
+	info := recv()
+	assert.Equal(t, SipMethodInfo, info.Method)
+	assert.Equal(t, ret.result.CallId, info.Header("Call-ID"))
+	assert.Equal(t, sipContentTypeMansrtsp, info.Header("Content-Type"))
+	assert.Equal(t, "PLAY MANSRTSP/1.0\r\nCSeq: 1\r\nScale: 2.0\r\n", string(info.Body))
+	send(NewSipResponse(info, 200, "OK"))
+	assert.Equal(t, nil, <-scaleDoneCh)
+
+	// 回放结束，服务端回复后发送BYE，并回调
+	mediaStatus := newReq(SipMethodMessage, 6)
+	mediaStatus.SetHeader("Call-ID", ret.result.CallId)
+	mediaStatus.Body = []byte(`<?xml version="1.0"?><Notify><CmdType>MediaStatus</CmdType><SN>3</SN><DeviceID>34020000001310000001</DeviceID><NotifyType>121</NotifyType></Notify>`)
+	send(mediaStatus)
+	assert.Equal(t, 200, recv().StatusCode)
+	bye = recv()
+	assert.Equal(t, SipMethodBye, bye.Method)
+	seq, _ = bye.CSeq()
+	assert.Equal(t, 3, seq)
+	send(NewSipResponse(bye, 200, "OK"))
+	select {
+	case callId := <-byeCh:
+		assert.Equal(t, ret.result.CallId, callId)
+	case <-time.After(2 * time.Second):
+		t.Fatal("bye callback timeout")
+	}
+
+	// dialog不存在
+	assert.IsNotNil(t, server.SetScale("not-exist", 2))
+}
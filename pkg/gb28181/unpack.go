@@ -11,6 +11,7 @@ package gb28181
 import (
 	"bytes"
 	"encoding/hex"
+	"sync"
 
 	"github.com/q191201771/lal/pkg/base"
 	"github.com/q191201771/lal/pkg/h2645"
@@ -23,9 +24,14 @@ import (
 )
 
 // PsUnpacker 解析ps(Program Stream)流
+//
+// 每个ssrc对应一个独立的jitter buffer以及ps流缓存，用于支持音频和视频使用不同ssrc（不同rtp会话）的设备。
+// jitter buffer同时受包数量（maxUnpackRtpListSize）和时长（unpackJitterBufferMs）限制，
+// 超过限制时放弃等待丢失的包。丢包后只清除该ssrc承载的音频或视频的缓存，视频从下一个关键帧开始回调。
 type PsUnpacker struct {
-	list     rtprtcp.RtpPacketList
-	buf      *nazabytes.Buffer
+	streams  []*psRtpStream
+	stream   *psRtpStream      // 当前正在解析的流，直接调用 FeedRtpBody 时为nil
+	buf      *nazabytes.Buffer // 当前正在解析的流的缓存
 	audioBuf []byte
 	videoBuf []byte
 
@@ -45,11 +51,42 @@ type PsUnpacker struct {
 	onAvPacket base.OnAvPacketFunc
 
 	waitSpsFlag bool
+	waitIdrFlag bool // 丢包后等待关键帧
 
 	feedPacketCount     int
 	feedBodyCount       int
 	onAvPacketWrapCount int
 	onAvPacketCount     int
+
+	statMutex   sync.Mutex // 保护streams的增删以及统计，统计可能被其他协程读取
+	removedStat RtpStat    // 被淘汰的ssrc的统计
+}
+
+// psRtpStream 一个ssrc对应的rtp流
+type psRtpStream struct {
+	ssrc        uint32
+	list        rtprtcp.RtpPacketList
+	buf         *nazabytes.Buffer
+	lastRtpts   uint32 // seq最大的包的时间戳，用于计算jitter buffer中缓存的时长
+	activeIndex int    // 最后一次收到包时的 PsUnpacker.feedPacketCount ，用于淘汰不活跃的ssrc
+
+	// 流中是否解析到过音频、视频的pes，丢包时只清除对应的缓存
+	hasAudio bool
+	hasVideo bool
+
+	// 丢包、乱序统计，参考RFC3550 A.3
+	baseSeq   int32
+	maxSeq    int32
+	cycles    uint32
+	received  uint64
+	reordered uint64
+}
+
+// RtpStat 接收rtp包的统计信息
+type RtpStat struct {
+	RecvPackets      uint64 // 收到的rtp包数量
+	LostPackets      uint64 // 根据seq计算，期望收到的数量减去实际收到的数量
+	ReorderedPackets uint64 // seq比之前收到的最大seq小的包的数量
 }
 
 func NewPsUnpacker() *PsUnpacker {
@@ -61,7 +98,6 @@ func NewPsUnpacker() *PsUnpacker {
 		preAudioRtpts: -1,
 		waitSpsFlag:   true,
 	}
-	p.list.InitMaxSize(maxUnpackRtpListSize)
 
 	return p
 }
@@ -86,14 +122,8 @@ func (p *PsUnpacker) WithOnAvPacket(onAvPacket base.OnAvPacketFunc) *PsUnpacker
 //
 // @param b: rtp包，注意，包含rtp包头部分，内部不持有该内存块
 func (p *PsUnpacker) FeedRtpPacket(b []byte) error {
-	// TODO(chef): [opt] 当前遇到的场景都是，音频和视频共用一个ssrc，并且音频和视频的seq是打在一起的，是否存在两个ssrc的情况？ 202209
-
 	p.feedPacketCount++
 
-	//defer func() {
-	//	nazalog.Debugf("<<<<<<<<<< PsUnpacker. list=%s", p.list.DebugString())
-	//}()
-
 	ipkt, err := rtprtcp.ParseRtpPacket(b)
 	if err != nil {
 		nazalog.Errorf("PsUnpacker ParseRtpPacket failed. b=%s, err=%+v",
@@ -104,6 +134,11 @@ func (p *PsUnpacker) FeedRtpPacket(b []byte) error {
 	//nazalog.Debugf(">>>>>>>>>> PsUnpacker FeedRtpPacket. h=%+v, len=%d, body=%s",
 	//	ipkt.Header, len(ipkt.Raw), hex.Dump(nazabytes.Prefix(ipkt.Raw[12:], 8)))
 
+	stream := p.getOrCreateStream(ipkt.Header.Ssrc)
+	stream.activeIndex = p.feedPacketCount
+	p.stream = stream
+	p.buf = stream.buf
+
 	var isStartPositionFn = func(pkt rtprtcp.RtpPacket) bool {
 		body := pkt.Body()
 		return len(body) > 4 && bytes.Compare(body[0:3], []byte{0, 0, 1}) == 0
@@ -111,32 +146,39 @@ func (p *PsUnpacker) FeedRtpPacket(b []byte) error {
 
 	// 处理丢包、乱序、重复
 
+	// 记录seq最大的包的时间戳
+	if stream.maxSeq == -1 || rtprtcp.CompareSeq(ipkt.Header.Seq, uint16(stream.maxSeq)) > 0 {
+		stream.lastRtpts = ipkt.Header.Timestamp
+	}
+	p.updateRtpStat(stream, ipkt.Header.Seq)
+
 	// 过期了直接丢掉
-	if p.list.IsStale(ipkt.Header.Seq) {
+	if stream.list.IsStale(ipkt.Header.Seq) {
 		//nazalog.Debugf("PsUnpacker NOTICE stale, drop. %d", ipkt.Header.Seq)
 		return ErrGb28181
 	}
 	// 插入队列
 	//nazalog.Debugf("PsUnpacker FeedRtpPacket insert. %d", ipkt.Header.Seq)
-	p.list.Insert(ipkt)
+	stream.list.Insert(ipkt)
 	for {
 		// 循环判断头部是否是顺序的
 
-		if p.list.IsFirstSequential() {
+		if stream.list.IsFirstSequential() {
 			// 如果头一个是顺序的，取出来，喂入解析器
 
-			opkt := p.list.PopFirst()
-			p.list.SetDoneSeq(opkt.Header.Seq)
+			opkt := stream.list.PopFirst()
+			stream.list.SetDoneSeq(opkt.Header.Seq)
 			//nazalog.Debugf("PsUnpacker FeedRtpBody. %d", opkt.Header.Seq)
 			errFeedRtpBody := p.FeedRtpBody(opkt.Body(), opkt.Header.Timestamp)
 			if errFeedRtpBody != nil {
-				p.list.Reset()
+				// 缓存在 FeedRtpBody 中已经清除
+				stream.list.Reset()
 			}
 		} else {
 			// 不是顺序的，如果还没达到容器阈值，就先缓存在容器中，直接退出了
 			// 注意，如果队列为空，也会走到这，然后通过!full退出
 
-			if !p.list.Full() {
+			if !stream.full() {
 				//nazalog.Debugf("PsUnpacker exit check !full.")
 				break
 			}
@@ -147,11 +189,11 @@ func (p *PsUnpacker) FeedRtpPacket(b []byte) error {
 			//
 			// 再丢弃连续的，直到下一个可解析帧位置
 			// 因为不连续的话，没法判断和正在丢弃的是否同一帧的，可以给个机会看后续是否能收到
-			prev := p.list.PopFirst()
+			prev := stream.list.PopFirst()
 			//nazalog.Debugf("PsUnpacker NOTICE drop. %d", prev.Header.Seq)
 
-			for p.list.Size > 0 {
-				curr := p.list.PeekFirst()
+			for stream.list.Size > 0 {
+				curr := stream.list.PeekFirst()
 				if rtprtcp.SubSeq(curr.Header.Seq, prev.Header.Seq) != 1 {
 					//nazalog.Debugf("PsUnpacker exit drop !sequential.")
 					break
@@ -161,18 +203,16 @@ func (p *PsUnpacker) FeedRtpPacket(b []byte) error {
 					//nazalog.Debugf("PsUnpacker exit drop start.")
 
 					// 注意，这里需要设置done seq，确保这个seq在以后的判断中可被使用
-					p.list.SetDoneSeq(curr.Header.Seq - 1)
+					stream.list.SetDoneSeq(curr.Header.Seq - 1)
 					break
 				}
 
-				prev = p.list.PopFirst()
+				prev = stream.list.PopFirst()
 				//nazalog.Debugf("PsUnpacker NOTICE drop. %d", prev.Header.Seq)
 			}
 
-			// 注意，缓存的数据也需要清除
-			p.buf.Reset()
-			p.audioBuf = nil
-			p.videoBuf = nil
+			// 注意，缓存的数据也需要清除，不完整的帧不再回调，视频从下一个关键帧开始回调
+			p.resetFrameCache(stream)
 		}
 	}
 
@@ -234,11 +274,9 @@ func (p *PsUnpacker) FeedRtpBody(rtpBody []byte, rtpts uint32) error {
 			consumed = parsePackStreamBody(rb, i)
 		default:
 			// TODO(chef): [opt] 所有code都处理后，不符合格式的code可以考虑重置unpacker，重新处理新喂入的数据 202207
-			nazalog.Errorf("unknown ps code, reset all cache buffer. %d,%d,%d, %s",
+			nazalog.Errorf("unknown ps code, reset cache buffer. %d,%d,%d, %s",
 				p.buf.Len(), len(p.audioBuf), len(p.videoBuf), hex.Dump(nazabytes.Prefix(rb[i-4:], 32)))
-			p.buf.Reset()
-			p.audioBuf = nil
-			p.videoBuf = nil
+			p.resetFrameCache(p.stream)
 			return base.ErrGb28181
 		}
 
@@ -256,9 +294,129 @@ func (p *PsUnpacker) FeedRtpBody(rtpBody []byte, rtpts uint32) error {
 	return nil
 }
 
+// GetRtpStat 所有ssrc的统计之和，注意，可以在其他协程中调用
+func (p *PsUnpacker) GetRtpStat() (stat RtpStat) {
+	p.statMutex.Lock()
+	defer p.statMutex.Unlock()
+
+	stat = p.removedStat
+	for _, stream := range p.streams {
+		stat.add(stream.getRtpStat())
+	}
+	return
+}
+
 func (p *PsUnpacker) Dispose() {
 	nazalog.Debugf("PsUnpacker Dispose. (%d, %d, %d, %d, %d)",
-		p.feedPacketCount, p.feedBodyCount, p.list.Size, p.onAvPacketWrapCount, p.onAvPacketCount)
+		p.feedPacketCount, p.feedBodyCount, len(p.streams), p.onAvPacketWrapCount, p.onAvPacketCount)
+}
+
+// resetFrameCache 丢弃stream的缓存，以及stream承载的音频或视频的帧缓存，之后收到的第一帧按整个流的第一帧处理
+//
+// 音频和视频使用不同ssrc时，一个ssrc丢包不影响另一个ssrc。stream为nil时清除所有缓存
+func (p *PsUnpacker) resetFrameCache(stream *psRtpStream) {
+	p.buf.Reset()
+	if stream == nil || stream.hasAudio {
+		p.audioBuf = nil
+		p.preAudioPts = -1
+		p.preAudioRtpts = -1
+	}
+	if stream == nil || stream.hasVideo {
+		p.videoBuf = nil
+		p.preVideoPts = -1
+		p.preVideoRtpts = -1
+		p.waitIdrFlag = true
+	}
+}
+
+// getOrCreateStream 超过 maxUnpackSsrcNum 时，淘汰最不活跃的ssrc（比如设备重启后ssrc发生变化）
+func (p *PsUnpacker) getOrCreateStream(ssrc uint32) *psRtpStream {
+	for _, stream := range p.streams {
+		if stream.ssrc == ssrc {
+			return stream
+		}
+	}
+
+	p.statMutex.Lock()
+	defer p.statMutex.Unlock()
+
+	if len(p.streams) >= maxUnpackSsrcNum {
+		oldest := 0
+		for i := range p.streams {
+			if p.streams[i].activeIndex < p.streams[oldest].activeIndex {
+				oldest = i
+			}
+		}
+		nazalog.Infof("PsUnpacker remove inactive ssrc. ssrc=%d, new=%d", p.streams[oldest].ssrc, ssrc)
+		p.removedStat.add(p.streams[oldest].getRtpStat())
+		p.streams = append(p.streams[:oldest], p.streams[oldest+1:]...)
+	}
+
+	stream := &psRtpStream{
+		ssrc:    ssrc,
+		buf:     nazabytes.NewBuffer(psBufInitSize),
+		baseSeq: -1,
+		maxSeq:  -1,
+	}
+	stream.list.InitMaxSize(maxUnpackRtpListSize)
+	p.streams = append(p.streams, stream)
+	return stream
+}
+
+// updateRtpStat 参考 rtprtcp.RrProducer
+func (p *PsUnpacker) updateRtpStat(stream *psRtpStream, seq uint16) {
+	p.statMutex.Lock()
+	defer p.statMutex.Unlock()
+
+	stream.received++
+	if stream.baseSeq == -1 {
+		stream.baseSeq = int32(seq)
+		stream.maxSeq = int32(seq)
+		return
+	}
+
+	switch rtprtcp.CompareSeq(seq, uint16(stream.maxSeq)) {
+	case 1:
+		if seq < uint16(stream.maxSeq) {
+			stream.cycles++
+		}
+		stream.maxSeq = int32(seq)
+	case -1:
+		stream.reordered++
+	}
+}
+
+// full 缓存的包数量或者时长超过阈值
+func (stream *psRtpStream) full() bool {
+	if stream.list.Full() {
+		return true
+	}
+	if stream.list.Size == 0 {
+		return false
+	}
+	// 注意，使用int32处理时间戳回绕
+	duration := int32(stream.lastRtpts - stream.list.PeekFirst().Header.Timestamp)
+	return duration > int32(unpackJitterBufferMs*90)
+}
+
+// getRtpStat 注意，调用方需要持有 PsUnpacker.statMutex
+func (stream *psRtpStream) getRtpStat() (stat RtpStat) {
+	stat.RecvPackets = stream.received
+	stat.ReorderedPackets = stream.reordered
+	if stream.baseSeq != -1 {
+		expected := uint64(stream.cycles)<<16 | uint64(stream.maxSeq)
+		expected = expected - uint64(stream.baseSeq) + 1
+		if expected > stream.received {
+			stat.LostPackets = expected - stream.received
+		}
+	}
+	return
+}
+
+func (s *RtpStat) add(other RtpStat) {
+	s.RecvPackets += other.RecvPackets
+	s.LostPackets += other.LostPackets
+	s.ReorderedPackets += other.ReorderedPackets
 }
 
 func (p *PsUnpacker) parsePsm(rb []byte, index int) int {
@@ -342,6 +500,14 @@ func (p *PsUnpacker) parsePsm(rb []byte, index int) int {
 }
 
 func (p *PsUnpacker) parseAvStream(code int, rtpts uint32, rb []byte, index int) int {
+	if p.stream != nil {
+		if code == psPackStartCodeAudioStream {
+			p.stream.hasAudio = true
+		} else {
+			p.stream.hasVideo = true
+		}
+	}
+
 	i := index
 
 	// 注意，由于length是两字节，所以存在一个帧分成多个pes包的情况
@@ -557,6 +723,14 @@ func (p *PsUnpacker) onAvPacketWrap(packet *base.AvPacket) {
 		typ := h2645.ParseNaluType(packet.PayloadType == base.AvPacketPtAvc, packet.Payload[4])
 		//nazalog.Debugf("PsUnpacker onAvPacketWrap. type=%d", typ)
 		// TODO(chef): [opt] 等待sps等信息再开始回调，这个逻辑不完整简化了 202209
+		if p.waitIdrFlag {
+			// 丢包后，丢弃视频直到关键帧。关键帧前面的vps、sps、pps也需要回调
+			if isKeyFrameOrParamSetNalu(packet.PayloadType == base.AvPacketPtAvc, typ) {
+				p.waitIdrFlag = false
+			} else {
+				return
+			}
+		}
 		if p.waitSpsFlag {
 			if packet.PayloadType == base.AvPacketPtAvc {
 				if typ == h2645.H264NaluTypeSps || typ == h2645.H264NaluTypePps {
@@ -15,9 +15,12 @@ import (
 
 	"github.com/q191201771/lal/pkg/base"
 	"github.com/q191201771/lal/pkg/hevc"
+	"github.com/q191201771/lal/pkg/rtprtcp"
 	"github.com/q191201771/naza/pkg/nazamd5"
 
 	"github.com/q191201771/lal/pkg/avc"
+	"github.com/q191201771/naza/pkg/assert"
+	"github.com/q191201771/naza/pkg/bele"
 	"github.com/q191201771/naza/pkg/nazabytes"
 	"github.com/q191201771/naza/pkg/nazalog"
 )
@@ -184,9 +187,159 @@ func TestRtpLossPacket(t *testing.T) {
 		{128, 96, 0, 20, 6, 203, 152, 224, 53, 182, 117, 59, 0, 0, 1, 186, 68, 108, 188, 88, 116, 1, 2, 143, 99, 254, 255, 255, 0, 0, 120, 124},
 		{128, 96, 0, 21, 6, 203, 152, 224, 53, 182, 117, 59, 0, 0, 1, 186, 68, 108, 188, 88, 116, 1, 2, 143, 99, 254, 255, 255, 0, 0, 120, 124},
 	}
+	defer func(v int) {
+		maxUnpackRtpListSize = v
+	}(maxUnpackRtpListSize)
 	maxUnpackRtpListSize = 10
 	unpacker := NewPsUnpacker()
 	for _, rtp := range rtpPacks {
 		unpacker.FeedRtpPacket(rtp)
 	}
+
+	// seq 4和5丢失
+	stat := unpacker.GetRtpStat()
+	assert.Equal(t, uint64(19), stat.RecvPackets)
+	assert.Equal(t, uint64(2), stat.LostPackets)
+	assert.Equal(t, uint64(0), stat.ReorderedPackets)
+
+	// 乱序以及seq回绕
+	unpacker = NewPsUnpacker()
+	for _, seq := range []uint16{65533, 65535, 65534, 0, 2, 1, 3} {
+		rtp := append([]byte(nil), rtpPacks[0]...)
+		bele.BePutUint16(rtp[2:], seq)
+		unpacker.FeedRtpPacket(rtp)
+	}
+	stat = unpacker.GetRtpStat()
+	assert.Equal(t, uint64(7), stat.RecvPackets)
+	assert.Equal(t, uint64(0), stat.LostPackets)
+	assert.Equal(t, uint64(2), stat.ReorderedPackets)
+}
+
+// TestPsUnpackerJitterBuffer 音频和视频使用不同的ssrc，以及丢包后丢弃视频直到下一个关键帧
+func TestPsUnpackerJitterBuffer(t *testing.T) {
+	var out []base.AvPacket
+	unpacker := NewPsUnpacker().WithOnAvPacket(func(packet *base.AvPacket) {
+		p := *packet
+		p.Payload = append([]byte(nil), packet.Payload...)
+		out = append(out, p)
+	})
+
+	videoPacker, audioPacker := NewPsPacker(), NewPsPacker()
+	videoRtpPacker, audioRtpPacker := NewRtpPsPacker(1), NewRtpPsPacker(2)
+	packVideo := func(ts int64, payload []byte) [][]byte {
+		ps, err := videoPacker.Pack(base.AvPacket{PayloadType: base.AvPacketPtAvc, Timestamp: ts, Pts: ts, Payload: payload})
+		assert.Equal(t, nil, err)
+		var ret [][]byte
+		for _, pkt := range videoRtpPacker.Pack(ps, ts) {
+			ret = append(ret, pkt.Raw)
+		}
+		return ret
+	}
+	feedAudio := func(ts int64) {
+		ps, err := audioPacker.Pack(base.AvPacket{PayloadType: base.AvPacketPtAac, Timestamp: ts, Pts: ts, Payload: testAdts})
+		assert.Equal(t, nil, err)
+		for _, pkt := range audioRtpPacker.Pack(ps, ts) {
+			_ = unpacker.FeedRtpPacket(pkt.Raw)
+		}
+	}
+	feed := func(pkts [][]byte) {
+		for _, pkt := range pkts {
+			_ = unpacker.FeedRtpPacket(pkt)
+		}
+	}
+
+	feed(packVideo(0, makeTestIdr()))
+	feedAudio(0)
+	feed(packVideo(40, makeTestNalu(0x41, 100)))
+	feedAudio(40)
+	// 丢失P帧中间的一个包
+	lossFrame := packVideo(80, makeTestNalu(0x41, 3000))
+	assert.Equal(t, true, len(lossFrame) >= 3)
+	feed(lossFrame[:1])
+	feed(lossFrame[2:])
+	feedAudio(80)
+	// 丢包之后，关键帧之前的P帧被丢弃，超过jitter buffer时长后放弃等待
+	for ts := int64(120); ts <= 120+int64(unpackJitterBufferMs); ts += 40 {
+		feed(packVideo(ts, makeTestNalu(0x41, 100)))
+	}
+	idrTs := int64(120 + unpackJitterBufferMs + 40)
+	feed(packVideo(idrTs, makeTestIdr()))
+	feed(packVideo(idrTs+40, makeTestNalu(0x41, 100)))
+
+	var videoTs, audioTs []int64
+	for _, pkt := range out {
+		if pkt.IsVideo() {
+			if len(videoTs) == 0 || videoTs[len(videoTs)-1] != pkt.Timestamp {
+				videoTs = append(videoTs, pkt.Timestamp)
+			}
+		} else {
+			audioTs = append(audioTs, pkt.Timestamp)
+		}
+	}
+	// 视频的最后一帧以及音频的最后一帧不会回调。
+	// 注意，40的帧在收到下一帧的完整pes包时才回调，由于下一帧丢包，所以和丢包的帧一起被丢弃了
+	assert.Equal(t, []int64{0, idrTs}, videoTs)
+	assert.Equal(t, []int64{0, 40}, audioTs)
+
+	stat := unpacker.GetRtpStat()
+	assert.Equal(t, uint64(1), stat.LostPackets)
+	assert.Equal(t, uint64(0), stat.ReorderedPackets)
+}
+
+// TestPsUnpackerAudioLoss 音频和视频使用不同的ssrc，只有音频丢包时，视频不受影响
+func TestPsUnpackerAudioLoss(t *testing.T) {
+	var out []base.AvPacket
+	unpacker := NewPsUnpacker().WithOnAvPacket(func(packet *base.AvPacket) {
+		p := *packet
+		p.Payload = append([]byte(nil), packet.Payload...)
+		out = append(out, p)
+	})
+
+	videoPacker, audioPacker := NewPsPacker(), NewPsPacker()
+	videoRtpPacker, audioRtpPacker := NewRtpPsPacker(1), NewRtpPsPacker(2)
+	feed := func(pkts []rtprtcp.RtpPacket) {
+		for _, pkt := range pkts {
+			_ = unpacker.FeedRtpPacket(pkt.Raw)
+		}
+	}
+	packVideo := func(ts int64, payload []byte) []rtprtcp.RtpPacket {
+		ps, err := videoPacker.Pack(base.AvPacket{PayloadType: base.AvPacketPtAvc, Timestamp: ts, Pts: ts, Payload: payload})
+		assert.Equal(t, nil, err)
+		return videoRtpPacker.Pack(ps, ts)
+	}
+	packAudio := func(ts int64) []rtprtcp.RtpPacket {
+		ps, err := audioPacker.Pack(base.AvPacket{PayloadType: base.AvPacketPtAac, Timestamp: ts, Pts: ts, Payload: testAdts})
+		assert.Equal(t, nil, err)
+		return audioRtpPacker.Pack(ps, ts)
+	}
+
+	feed(packVideo(0, makeTestIdr()))
+	feed(packAudio(0))
+	// 丢失一帧音频，之后的音频超过jitter buffer时长后放弃等待，之后继续发送一段时间
+	_ = packAudio(40)
+	var expectedVideoTs []int64
+	for ts := int64(40); ts <= 80+int64(unpackJitterBufferMs)+200; ts += 40 {
+		expectedVideoTs = append(expectedVideoTs, ts-40)
+		feed(packVideo(ts, makeTestNalu(0x41, 3000)))
+		feed(packAudio(ts + 40))
+	}
+
+	var videoTs []int64
+	audioNum := 0
+	for _, pkt := range out {
+		if pkt.IsVideo() {
+			if len(videoTs) == 0 || videoTs[len(videoTs)-1] != pkt.Timestamp {
+				videoTs = append(videoTs, pkt.Timestamp)
+			}
+		} else {
+			audioNum++
+		}
+	}
+	// 视频的最后一帧不会回调，其他帧都不受音频丢包影响
+	assert.Equal(t, expectedVideoTs, videoTs)
+	// 放弃等待后，音频恢复回调
+	assert.Equal(t, true, audioNum > 1)
+
+	stat := unpacker.GetRtpStat()
+	assert.Equal(t, true, stat.LostPackets >= 1)
 }
@@ -0,0 +1,239 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package hls
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/lal/pkg/hevc"
+	"github.com/q191201771/naza/pkg/nazaerrors"
+)
+
+// abr.go
+//
+// 多码率（ABR）的master playlist
+//
+// 将多个流（比如同一个源转码出来的1080p、720p、480p）绑定为一个ABR ladder，生成一个master m3u8，
+// 播放器根据带宽在多个variant之间切换。
+//
+// 假设ladder名称="test110_abr"，url_pattern="/hls/"
+// 则以下两种请求都可以获取master playlist:
+// http://127.0.0.1:8080/hls/test110_abr.m3u8
+// http://127.0.0.1:8080/hls/test110_abr/master.m3u8
+//
+// 注意，为了播放器能无缝切换，各variant的切片边界需要对齐，见 MuxerConfig.AlignFragmentFlag
+//
+// 另外支持多语言的音频以及字幕（`EXT-X-MEDIA`），见 AbrRendition 以及 subtitle.go
+//
+// 注意，每个多语言音频需要来自一个单独的流（也即单独的group），暂不支持从同一个输入流的多个音频PID中拆分出多语言音频
+
+const masterM3u8FileName = "master.m3u8"
+
+// AbrVariant master playlist中的一个variant，对应一个流
+type AbrVariant struct {
+	StreamName string
+	Bandwidth  int    // 单位bit/s，写入BANDWIDTH
+	Width      int    // 为0时不写入RESOLUTION
+	Height     int    //
+	Codecs     string // RFC6381格式，为空时不写入CODECS，比如"avc1.64001F,mp4a.40.2"
+}
+
+const (
+	AbrRenditionTypeAudio     = "AUDIO"
+	AbrRenditionTypeSubtitles = "SUBTITLES"
+
+	abrAudioGroupId     = "aud"
+	abrSubtitlesGroupId = "subs"
+)
+
+// AbrRendition master playlist中的一个`EXT-X-MEDIA`，比如多语言的音频、字幕
+//
+// 同一类型的rendition属于同一个GROUP-ID，所有variant都引用该GROUP-ID
+type AbrRendition struct {
+	Type     string // AbrRenditionTypeAudio 或 AbrRenditionTypeSubtitles
+	Name     string
+	Language string // 为空时不写入LANGUAGE
+	Default  bool
+	Uri      string // 为空表示该rendition包含在variant中（比如和视频一起复用在TS中的音频），只有音频可以为空
+}
+
+var renditionLanguageRegexp = regexp.MustCompile(`^[A-Za-z0-9-]{1,16}$`)
+
+// CheckRenditionLanguage 检查rendition的language，只允许字母、数字和`-`，比如"en"、"zh-Hans"
+//
+// 字幕的language会拼接到文件路径中，见 SubtitleStreamName
+func CheckRenditionLanguage(language string) error {
+	if !renditionLanguageRegexp.MatchString(language) {
+		return nazaerrors.Wrap(base.ErrHlsRendition, language)
+	}
+	return nil
+}
+
+// CheckRenditionName 检查rendition的name，name写入master playlist的引号中，所以不能包含引号以及换行
+func CheckRenditionName(name string) error {
+	if name == "" || strings.ContainsAny(name, "\"\r\n") {
+		return nazaerrors.Wrap(base.ErrHlsRendition, name)
+	}
+	return nil
+}
+
+// IMasterPlaylistProvider
+//
+// ServerHandler 收到master playlist请求时，通过该接口获取master playlist的内容
+type IMasterPlaylistProvider interface {
+	// GetMasterPlaylist
+	//
+	// @return exist: 如果ladder不存在，返回false
+	//
+	GetMasterPlaylist(ladderName string) (content []byte, exist bool)
+}
+
+// PackMasterPlaylist
+//
+// variant的uri使用相对路径`<streamName>/playlist.m3u8`
+//
+// @param renditions: 可以为nil
+func PackMasterPlaylist(variants []AbrVariant, renditions []AbrRendition) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("#EXTM3U\n")
+	buf.WriteString("#EXT-X-VERSION:3\n")
+	buf.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+
+	var hasAudio, hasSubtitles bool
+	for _, r := range renditions {
+		var groupId string
+		switch r.Type {
+		case AbrRenditionTypeAudio:
+			groupId = abrAudioGroupId
+			hasAudio = true
+		case AbrRenditionTypeSubtitles:
+			groupId = abrSubtitlesGroupId
+			hasSubtitles = true
+		default:
+			Log.Warnf("invalid abr rendition type. rendition=%+v", r)
+			continue
+		}
+
+		buf.WriteString(fmt.Sprintf("#EXT-X-MEDIA:TYPE=%s,GROUP-ID=\"%s\",NAME=\"%s\"", r.Type, groupId, r.Name))
+		if r.Language != "" {
+			buf.WriteString(fmt.Sprintf(",LANGUAGE=\"%s\"", r.Language))
+		}
+		if r.Default {
+			buf.WriteString(",DEFAULT=YES,AUTOSELECT=YES")
+		} else {
+			buf.WriteString(",DEFAULT=NO,AUTOSELECT=YES")
+		}
+		if r.Uri != "" {
+			buf.WriteString(fmt.Sprintf(",URI=\"%s\"", r.Uri))
+		}
+		buf.WriteString("\n")
+	}
+
+	for _, v := range variants {
+		buf.WriteString(fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%d", v.Bandwidth))
+		if v.Width != 0 && v.Height != 0 {
+			buf.WriteString(fmt.Sprintf(",RESOLUTION=%dx%d", v.Width, v.Height))
+		}
+		if v.Codecs != "" {
+			buf.WriteString(fmt.Sprintf(",CODECS=\"%s\"", v.Codecs))
+		}
+		if hasAudio {
+			buf.WriteString(fmt.Sprintf(",AUDIO=\"%s\"", abrAudioGroupId))
+		}
+		if hasSubtitles {
+			buf.WriteString(fmt.Sprintf(",SUBTITLES=\"%s\"", abrSubtitlesGroupId))
+		}
+		buf.WriteString(fmt.Sprintf("\n%s\n", MakeRenditionUri(v.StreamName)))
+	}
+	return buf.Bytes()
+}
+
+// MakeAvcCodecs
+//
+// @param sps: 包含nal header，比如 67 64 00 1f ...
+//
+// @return 比如 avc1.64001F
+func MakeAvcCodecs(sps []byte) string {
+	if len(sps) < 4 {
+		return ""
+	}
+	return fmt.Sprintf("avc1.%02X%02X%02X", sps[1], sps[2], sps[3])
+}
+
+// MakeHevcCodecs
+//
+// ISO_IEC_14496-15 Annex E.3
+//
+// @param ctx: hevc.ParseSps 的结果
+//
+// @return 比如 hvc1.1.6.L93.B0
+func MakeHevcCodecs(ctx *hevc.Context) string {
+	var sb strings.Builder
+	sb.WriteString("hvc1.")
+	if ctx.GeneralProfileSpace > 0 {
+		sb.WriteByte('A' + ctx.GeneralProfileSpace - 1)
+	}
+	sb.WriteString(fmt.Sprintf("%d", ctx.GeneralProfileIdc))
+
+	// general_profile_compatibility_flags按位倒序
+	var reversed uint32
+	flags := ctx.GeneralProfileCompatibilityFlags
+	for i := 0; i < 32; i++ {
+		reversed = (reversed << 1) | (flags & 1)
+		flags >>= 1
+	}
+	sb.WriteString(fmt.Sprintf(".%X", reversed))
+
+	if ctx.GeneralTierFlag == 0 {
+		sb.WriteString(".L")
+	} else {
+		sb.WriteString(".H")
+	}
+	sb.WriteString(fmt.Sprintf("%d", ctx.GeneralLevelIdc))
+
+	// general_constraint_indicator_flags共6个字节，末尾为0的字节省略
+	cif := ctx.GeneralConstraintIndicatorFlags
+	var cifBytes []byte
+	for i := 5; i >= 0; i-- {
+		cifBytes = append(cifBytes, uint8(cif>>(uint(i)*8)))
+	}
+	for len(cifBytes) > 0 && cifBytes[len(cifBytes)-1] == 0 {
+		cifBytes = cifBytes[:len(cifBytes)-1]
+	}
+	for _, b := range cifBytes {
+		sb.WriteString(fmt.Sprintf(".%X", b))
+	}
+	return sb.String()
+}
+
+// MakeAacCodecs
+//
+// @return 比如 mp4a.40.2
+func MakeAacCodecs(asc []byte) string {
+	if len(asc) < 1 {
+		return ""
+	}
+	return fmt.Sprintf("mp4a.40.%d", asc[0]>>3)
+}
+
+// MakeRenditionUri variant以及rendition的uri，使用相对路径`<streamName>/playlist.m3u8`
+func MakeRenditionUri(streamName string) string {
+	return fmt.Sprintf("%s/%s", streamName, playlistM3u8FileName)
+}
+
+// MakeMp3Codecs
+//
+// @return mp4a.40.34
+func MakeMp3Codecs() string {
+	return "mp4a.40.34"
+}
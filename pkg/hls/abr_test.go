@@ -0,0 +1,107 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package hls_test
+
+import (
+	"testing"
+
+	"github.com/q191201771/lal/pkg/hevc"
+	"github.com/q191201771/lal/pkg/hls"
+	"github.com/q191201771/naza/pkg/assert"
+)
+
+func TestPackMasterPlaylist(t *testing.T) {
+	golden := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-STREAM-INF:BANDWIDTH=2048000,RESOLUTION=1280x720,CODECS="avc1.64001F,mp4a.40.2"
+test110_720p/playlist.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=512000
+test110_360p/playlist.m3u8
+`
+	variants := []hls.AbrVariant{
+		{
+			StreamName: "test110_720p",
+			Bandwidth:  2048000,
+			Width:      1280,
+			Height:     720,
+			Codecs:     "avc1.64001F,mp4a.40.2",
+		},
+		{
+			StreamName: "test110_360p",
+			Bandwidth:  512000,
+		},
+	}
+	assert.Equal(t, golden, string(hls.PackMasterPlaylist(variants, nil)))
+}
+
+func TestPackMasterPlaylistWithRenditions(t *testing.T) {
+	golden := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="English",LANGUAGE="en",DEFAULT=YES,AUTOSELECT=YES
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="Espanol",LANGUAGE="es",DEFAULT=NO,AUTOSELECT=YES,URI="test110_es/playlist.m3u8"
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English",LANGUAGE="en",DEFAULT=NO,AUTOSELECT=YES,URI="test110_sub_en/playlist.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=2048000,AUDIO="aud",SUBTITLES="subs"
+test110/playlist.m3u8
+`
+	variants := []hls.AbrVariant{
+		{
+			StreamName: "test110",
+			Bandwidth:  2048000,
+		},
+	}
+	subtitleStreamName, err := hls.SubtitleStreamName("test110", "en")
+	assert.Equal(t, nil, err)
+	renditions := []hls.AbrRendition{
+		{
+			Type:     hls.AbrRenditionTypeAudio,
+			Name:     "English",
+			Language: "en",
+			Default:  true,
+		},
+		{
+			Type:     hls.AbrRenditionTypeAudio,
+			Name:     "Espanol",
+			Language: "es",
+			Uri:      hls.MakeRenditionUri("test110_es"),
+		},
+		{
+			Type:     hls.AbrRenditionTypeSubtitles,
+			Name:     "English",
+			Language: "en",
+			Uri:      hls.MakeRenditionUri(subtitleStreamName),
+		},
+	}
+	assert.Equal(t, golden, string(hls.PackMasterPlaylist(variants, renditions)))
+}
+
+func TestMakeCodecs(t *testing.T) {
+	assert.Equal(t, "avc1.64001F", hls.MakeAvcCodecs([]byte{0x67, 0x64, 0x00, 0x1f, 0xac}))
+	assert.Equal(t, "", hls.MakeAvcCodecs([]byte{0x67}))
+	assert.Equal(t, "mp4a.40.2", hls.MakeAacCodecs([]byte{0x12, 0x10}))
+	assert.Equal(t, "mp4a.40.34", hls.MakeMp3Codecs())
+
+	sps := []byte{
+		0x42, 0x01,
+		0x01,
+		0x01,
+		0x60, 0x00, 0x00, 0x03, 0x00,
+		0x90, 0x00, 0x00, 0x03, 0x00, 0x00, 0x03, 0x00,
+		0x3f,
+		0xa0, 0x05, 0x02, 0x01, 0x71, 0xf2, 0xe5, 0xba, 0x4a, 0x4c, 0x2f, 0x01, 0x01, 0x00, 0x00, 0x03, 0x00, 0x01, 0x00, 0x00, 0x03, 0x00, 0x0f, 0x08,
+	}
+	ctx := hevc.Context{
+		GeneralProfileCompatibilityFlags: 0xffffffff,
+		GeneralConstraintIndicatorFlags:  0xffffffffffff,
+	}
+	err := hevc.ParseSps(sps, &ctx)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "hvc1.1.6.L63.90", hls.MakeHevcCodecs(&ctx))
+}
@@ -0,0 +1,226 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package hls
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/naza/pkg/nazaerrors"
+)
+
+// encrypt.go
+//
+// HLS加密
+//
+// 开启 MuxerConfig.EncryptFlag 后，m3u8中每个切片前写入
+// `#EXT-X-KEY:METHOD=<method>,URI="<streamName>-key-<keyIndex>.key",IV=0x<切片序号>`。
+//
+// MuxerConfig.EncryptMethod 决定加密方式：
+//   - AES-128: 每个TS切片使用AES-128-CBC（PKCS7填充）整体加密
+//   - SAMPLE-AES: 只加密音视频的部分数据，TS格式本身不加密，见 sample_aes.go
+//
+// 密钥由 KeyProvider 提供，每 MuxerConfig.KeyRotateFragmentNum 个切片轮换一次（keyIndex加1）。
+// Muxer 在后台协程中提前获取下一个密钥，不阻塞切片的生成。
+//
+// 获取到的密钥由 Muxer 登记，播放器请求密钥时，ServerHandler 只回复已登记的密钥。
+// CleanupModeAsap 时，切片文件删除后，不再被任何切片使用的密钥随之删除；其他模式下，密钥在 Muxer 销毁时删除。
+
+const (
+	KeySize = 16
+
+	keyFileType = "key"
+)
+
+const (
+	EncryptMethodAes128    = "AES-128"
+	EncryptMethodSampleAes = "SAMPLE-AES"
+)
+
+// IKeyProvider
+//
+// 每个streamName的每个keyIndex，Muxer 只调用一次。
+// 注意，调用发生在后台协程中，内部实现可以阻塞，但需要是协程安全的
+type IKeyProvider interface {
+	GetKey(streamName string, keyIndex int) (key []byte, err error)
+}
+
+// KeyProvider 为nil时，开启了 MuxerConfig.EncryptFlag 的流不会生成切片，避免输出未加密的切片
+var KeyProvider IKeyProvider
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// activeKeys 正在被切片使用的密钥，key为密钥文件名
+var activeKeys = keyStore{
+	keys: make(map[string]keyItem),
+}
+
+type keyItem struct {
+	owner *Muxer
+	key   []byte
+}
+
+type keyStore struct {
+	mu   sync.Mutex
+	keys map[string]keyItem
+}
+
+func (s *keyStore) add(filename string, owner *Muxer, key []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[filename] = keyItem{owner: owner, key: key}
+}
+
+// remove 只删除 owner 自己登记的密钥，避免同名的流重新推流时，旧的 Muxer 删除新的 Muxer 的密钥
+func (s *keyStore) remove(filename string, owner *Muxer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if item, ok := s.keys[filename]; ok && item.owner == owner {
+		delete(s.keys, filename)
+	}
+}
+
+func (s *keyStore) get(filename string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.keys[filename]
+	return item.key, ok
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// MemoryKeyProvider 随机生成密钥
+//
+// 注意，密钥只保存在 Muxer 登记的内存中，进程重启后密钥丢失，之前生成的加密切片无法再解密
+type MemoryKeyProvider struct {
+}
+
+func NewMemoryKeyProvider() *MemoryKeyProvider {
+	return &MemoryKeyProvider{}
+}
+
+func (p *MemoryKeyProvider) GetKey(streamName string, keyIndex int) ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// StaticFileKeyProvider 所有流使用同一个文件中的密钥，不支持轮换
+//
+// 文件内容为16字节的二进制密钥，或者32个字符的hex字符串
+type StaticFileKeyProvider struct {
+	key []byte
+}
+
+func NewStaticFileKeyProvider(filename string) (*StaticFileKeyProvider, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	key, err := parseKey(content)
+	if err != nil {
+		return nil, err
+	}
+	return &StaticFileKeyProvider{
+		key: key,
+	}, nil
+}
+
+func (p *StaticFileKeyProvider) GetKey(streamName string, keyIndex int) ([]byte, error) {
+	return p.key, nil
+}
+
+// HttpKeyProvider 通过HTTP回调业务方的密钥服务获取密钥
+//
+// 请求格式为 GET <url>?stream_name=<streamName>&key_index=<keyIndex>
+// 回复的body为16字节的二进制密钥，或者32个字符的hex字符串
+type HttpKeyProvider struct {
+	url    string
+	client http.Client
+}
+
+func NewHttpKeyProvider(url string, timeoutMs int) *HttpKeyProvider {
+	return &HttpKeyProvider{
+		url: url,
+		client: http.Client{
+			Timeout: time.Duration(timeoutMs) * time.Millisecond,
+		},
+	}
+}
+
+func (p *HttpKeyProvider) GetKey(streamName string, keyIndex int) ([]byte, error) {
+	u, err := url.Parse(p.url)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("stream_name", streamName)
+	q.Set("key_index", strconv.Itoa(keyIndex))
+	u.RawQuery = q.Encode()
+
+	resp, err := p.client.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nazaerrors.Wrap(base.ErrHlsKey, fmt.Sprintf("status code=%d", resp.StatusCode))
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return parseKey(body)
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// makeKeyFileName 格式为<streamName>-key-<keyIndex>.key，和TS文件名一样包含两个`-`，路由时可以按同样的规则解析出流名称
+func makeKeyFileName(streamName string, keyIndex int) string {
+	return fmt.Sprintf("%s-key-%d.%s", streamName, keyIndex, keyFileType)
+}
+
+// makeExtXKey
+//
+// @param method: EncryptMethodXxx
+//
+// @param fragmentId: 切片序号，同时作为IV
+func makeExtXKey(method string, streamName string, keyIndex int, fragmentId int) string {
+	return fmt.Sprintf("#EXT-X-KEY:METHOD=%s,URI=\"%s\",IV=0x%s\n", method, makeKeyFileName(streamName, keyIndex), hex.EncodeToString(makeIv(fragmentId)))
+}
+
+func makeIv(fragmentId int) []byte {
+	iv := make([]byte, KeySize)
+	binary.BigEndian.PutUint64(iv[8:], uint64(fragmentId))
+	return iv
+}
+
+func parseKey(b []byte) ([]byte, error) {
+	if len(b) == KeySize {
+		return b, nil
+	}
+	s := strings.TrimSpace(string(b))
+	if len(s) == KeySize*2 {
+		return hex.DecodeString(s)
+	}
+	return nil, nazaerrors.Wrap(base.ErrHlsKey, fmt.Sprintf("len=%d", len(b)))
+}
@@ -0,0 +1,350 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package hls_test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/q191201771/lal/pkg/aac"
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/lal/pkg/hls"
+	"github.com/q191201771/lal/pkg/mpegts"
+	"github.com/q191201771/naza/pkg/assert"
+)
+
+func TestFragmentEncrypt(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	iv := make([]byte, hls.KeySize)
+	iv[15] = 7
+
+	for _, n := range []int{0, 15, 16, 188 * 7, 188*7 + 1} {
+		plain := make([]byte, n)
+		for i := range plain {
+			plain[i] = byte(i)
+		}
+
+		filename := filepath.Join(t.TempDir(), "test110-1620540712084-7.ts")
+		var f hls.Fragment
+		assert.Equal(t, nil, f.OpenEncryptedFile(filename, key, iv))
+		// 分多次写入，验证不足一个block时的缓存逻辑
+		for i := 0; i < n; i += 100 {
+			end := i + 100
+			if end > n {
+				end = n
+			}
+			assert.Equal(t, nil, f.WriteFile(plain[i:end]))
+		}
+		assert.Equal(t, nil, f.CloseFile())
+
+		content, err := hls.ReadFile(filename)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, (n/aes.BlockSize+1)*aes.BlockSize, len(content))
+
+		block, _ := aes.NewCipher(key)
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(content, content)
+		padding := int(content[len(content)-1])
+		assert.Equal(t, bytes.Repeat([]byte{byte(padding)}, padding), content[len(content)-padding:])
+		assert.Equal(t, plain, content[:len(content)-padding])
+	}
+}
+
+func TestKeyProvider(t *testing.T) {
+	mp := hls.NewMemoryKeyProvider()
+	k0, err := mp.GetKey("test110", 0)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, hls.KeySize, len(k0))
+	k1, _ := mp.GetKey("test110", 1)
+	assert.Equal(t, false, bytes.Equal(k0, k1))
+
+	filename := filepath.Join(t.TempDir(), "hls.key")
+	_ = os.WriteFile(filename, []byte("30313233343536373839616263646566\n"), 0666)
+	fp, err := hls.NewStaticFileKeyProvider(filename)
+	assert.Equal(t, nil, err)
+	k, _ := fp.GetKey("test110", 3)
+	assert.Equal(t, []byte("0123456789abcdef"), k)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "test110", r.URL.Query().Get("stream_name"))
+		if r.URL.Query().Get("key_index") != "2" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte("0123456789abcdef"))
+	}))
+	defer srv.Close()
+	hp := hls.NewHttpKeyProvider(srv.URL+"/key", 1000)
+	k, err = hp.GetKey("test110", 2)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []byte("0123456789abcdef"), k)
+	_, err = hp.GetKey("test110", 3)
+	assert.IsNotNil(t, err)
+}
+
+// TestMuxerEncryptWithoutKeyProvider 开启加密但没有密钥来源时，不生成未加密的切片
+func TestMuxerEncryptWithoutKeyProvider(t *testing.T) {
+	config := hls.MuxerConfig{
+		OutPath:            t.TempDir(),
+		FragmentDurationMs: 1000,
+		FragmentNum:        2,
+		EncryptFlag:        true,
+	}
+	m := hls.NewMuxer("test110", &config, nil)
+	m.Start()
+	m.FeedPatPmt(append(mpegts.PackPat(), mpegts.PackPmt(int(base.RtmpCodecIdAvc), int(base.RtmpSoundFormatAac))...))
+	for i := 0; i < 3; i++ {
+		frame := newTestVideoFrame(uint64(i) * 90000)
+		m.FeedMpegts(frame.Pack(), frame, true)
+	}
+	m.Dispose()
+
+	_, err := hls.ReadFile(filepath.Join(m.OutPath(), "playlist.m3u8"))
+	assert.IsNotNil(t, err)
+}
+
+func TestMuxerEncrypt(t *testing.T) {
+	hls.KeyProvider = hls.NewMemoryKeyProvider()
+	defer func() {
+		hls.KeyProvider = nil
+	}()
+
+	config := hls.MuxerConfig{
+		OutPath:              t.TempDir(),
+		FragmentDurationMs:   1000,
+		FragmentNum:          2,
+		DeleteThreshold:      1,
+		CleanupMode:          hls.CleanupModeAsap,
+		EncryptFlag:          true,
+		KeyRotateFragmentNum: 1,
+	}
+	m := hls.NewMuxer("test110", &config, nil)
+	m.Start()
+	m.FeedPatPmt(append(mpegts.PackPat(), mpegts.PackPmt(int(base.RtmpCodecIdAvc), int(base.RtmpSoundFormatAac))...))
+
+	// 每帧开启一个新的切片，使用一个新的密钥。密钥在后台协程获取，每次等待一会
+	for i := 0; i < 6; i++ {
+		time.Sleep(50 * time.Millisecond)
+		frame := newTestVideoFrame(uint64(i) * 90000)
+		m.FeedMpegts(frame.Pack(), frame, true)
+	}
+
+	sh := hls.NewServerHandler(config.OutPath, "/hls/", "", 0, nil)
+	getKey := func(keyIndex int) (int, []byte) {
+		resp := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", fmt.Sprintf("/hls/test110-key-%d.key", keyIndex), nil)
+		req.Host = "127.0.0.1:8080"
+		sh.ServeHTTP(resp, req)
+		return resp.Code, resp.Body.Bytes()
+	}
+
+	// 切片0和1已经删除，对应的密钥不再回复
+	for _, keyIndex := range []int{0, 1, 100} {
+		code, _ := getKey(keyIndex)
+		assert.Equal(t, http.StatusNotFound, code)
+	}
+
+	playlist, err := hls.ReadFile(filepath.Join(m.OutPath(), "playlist.m3u8"))
+	assert.Equal(t, nil, err)
+	lines := strings.Split(string(playlist), "\n")
+	var keyLines []string
+	var tsFiles []string
+	for _, line := range lines {
+		if strings.HasPrefix(line, "#EXT-X-KEY") {
+			keyLines = append(keyLines, line)
+		} else if strings.HasSuffix(line, ".ts") {
+			tsFiles = append(tsFiles, line)
+		}
+	}
+	assert.Equal(t, 2, len(tsFiles))
+	assert.Equal(t, `#EXT-X-KEY:METHOD=AES-128,URI="test110-key-3.key",IV=0x00000000000000000000000000000003`, keyLines[0])
+
+	// 使用回复的密钥解密切片
+	code, key := getKey(3)
+	assert.Equal(t, http.StatusOK, code)
+	content, err := hls.ReadFile(filepath.Join(m.OutPath(), tsFiles[0]))
+	assert.Equal(t, nil, err)
+	block, _ := aes.NewCipher(key)
+	iv := make([]byte, hls.KeySize)
+	iv[15] = 3
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(content, content)
+	assert.Equal(t, mpegts.PackPat(), content[:188])
+
+	// 当前切片使用的密钥
+	code, _ = getKey(5)
+	assert.Equal(t, http.StatusOK, code)
+
+	m.Dispose()
+	for _, keyIndex := range []int{2, 3, 4, 5} {
+		code, _ = getKey(keyIndex)
+		assert.Equal(t, http.StatusNotFound, code)
+	}
+}
+
+func TestMuxerSampleAes(t *testing.T) {
+	hls.KeyProvider = hls.NewMemoryKeyProvider()
+	defer func() {
+		hls.KeyProvider = nil
+	}()
+
+	config := hls.MuxerConfig{
+		OutPath:            t.TempDir(),
+		FragmentDurationMs: 1000,
+		FragmentNum:        3,
+		EncryptFlag:        true,
+		EncryptMethod:      hls.EncryptMethodSampleAes,
+	}
+	m := hls.NewMuxer("test110", &config, nil)
+	m.Start()
+	m.FeedPatPmt(append(mpegts.PackPat(), mpegts.PackPmt(int(base.RtmpCodecIdAvc), int(base.RtmpSoundFormatAac))...))
+	time.Sleep(50 * time.Millisecond)
+
+	// 获取到asc之前不开启切片
+	video := newTestVideoFrame(0)
+	m.FeedMpegts(video.Pack(), video, true)
+	_, err := hls.ReadFile(filepath.Join(m.OutPath(), "playlist.m3u8"))
+	assert.IsNotNil(t, err)
+
+	ascCtx, _ := aac.NewAscContext([]byte{0x12, 0x10})
+	var adts []byte
+	for _, n := range []int{10, 16, 17, 100} {
+		adts = append(adts, ascCtx.PackAdtsHeader(n)...)
+		for i := 0; i < n; i++ {
+			adts = append(adts, byte(i+1))
+		}
+	}
+	audio := &mpegts.Frame{
+		Pts: 90000,
+		Dts: 90000,
+		Pid: mpegts.PidAudio,
+		Sid: mpegts.StreamIdAudio,
+		Raw: adts,
+	}
+	m.FeedMpegts(audio.Pack(), audio, false)
+	video = newTestVideoFrame(90000)
+	m.FeedMpegts(video.Pack(), video, true)
+	m.FeedMpegts(audio.Pack(), audio, false)
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/hls/test110-key-0.key", nil)
+	req.Host = "127.0.0.1:8080"
+	hls.NewServerHandler(config.OutPath, "/hls/", "", 0, nil).ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	key := resp.Body.Bytes()
+	m.Dispose()
+
+	playlist, err := hls.ReadFile(filepath.Join(m.OutPath(), "playlist.m3u8"))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, strings.Contains(string(playlist), "#EXT-X-VERSION:5\n"))
+	assert.Equal(t, true, strings.Contains(string(playlist), `#EXT-X-KEY:METHOD=SAMPLE-AES,URI="test110-key-0.key",IV=0x00000000000000000000000000000000`))
+
+	// 切片本身不加密
+	var tsFile string
+	for _, line := range strings.Split(string(playlist), "\n") {
+		if strings.HasSuffix(line, ".ts") {
+			tsFile = line
+		}
+	}
+	content, err := hls.ReadFile(filepath.Join(m.OutPath(), tsFile))
+	assert.Equal(t, nil, err)
+	pmt := mpegts.ParsePmt(content[188+5:])
+	assert.Equal(t, uint8(0xdb), pmt.SearchPid(mpegts.PidVideo).StreamType)
+	assert.Equal(t, uint8(0xcf), pmt.SearchPid(mpegts.PidAudio).StreamType)
+	assert.Equal(t, true, bytes.Contains(content[188:376], []byte("zavc")))
+	assert.Equal(t, true, bytes.Contains(content[188:376], []byte("aacd")))
+	assert.Equal(t, true, bytes.Contains(content[188:376], []byte{'a', 'p', 'a', 'd', 'z', 'a', 'a', 'c', 0, 0, 1, 2, 0x12, 0x10}))
+
+	// 按SAMPLE-AES的规则解密后和原始数据一致
+	block, _ := aes.NewCipher(key)
+	iv := make([]byte, hls.KeySize)
+
+	videoPes := readTestPesPayloads(content, mpegts.PidVideo)
+	assert.Equal(t, 1, len(videoPes))
+	assert.Equal(t, false, bytes.Equal(video.Raw, videoPes[0]))
+	nal := removeTestEmulationPrevention(videoPes[0][4:])
+	dec := cipher.NewCBCDecrypter(block, iv)
+	for pos := 32; pos < len(nal); pos += 144 {
+		if len(nal)-pos > 16 {
+			dec.CryptBlocks(nal[pos:pos+16], nal[pos:pos+16])
+			pos += 16
+		}
+	}
+	assert.Equal(t, video.Raw[4:], nal)
+
+	audioPes := readTestPesPayloads(content, mpegts.PidAudio)
+	assert.Equal(t, 1, len(audioPes))
+	assert.Equal(t, false, bytes.Equal(adts, audioPes[0]))
+	pos := 0
+	for _, n := range []int{10, 16, 17, 100} {
+		// 帧长度小于等于16字节时不加密
+		frame := audioPes[0][pos+7 : pos+7+n]
+		if n > 16 {
+			enc := frame[16 : 16+(n-16)/16*16]
+			cipher.NewCBCDecrypter(block, iv).CryptBlocks(enc, enc)
+		}
+		pos += 7 + n
+	}
+	assert.Equal(t, adts, audioPes[0])
+}
+
+// removeTestEmulationPrevention 测试中使用的数据只包含0x000003形式的防竞争字节
+func removeTestEmulationPrevention(nal []byte) []byte {
+	return bytes.ReplaceAll(nal, []byte{0, 0, 3}, []byte{0, 0})
+}
+
+func newTestVideoFrame(dts uint64) *mpegts.Frame {
+	raw := []byte{0, 0, 0, 1, 0x65}
+	for i := 0; i < 500; i++ {
+		raw = append(raw, byte(i%250+1))
+	}
+	return &mpegts.Frame{
+		Pts: dts,
+		Dts: dts,
+		Pid: mpegts.PidVideo,
+		Sid: mpegts.StreamIdVideo,
+		Key: true,
+		Raw: raw,
+	}
+}
+
+// readTestPesPayloads 从TS数据中取出指定PID的所有PES的负载
+func readTestPesPayloads(content []byte, pid uint16) (ret [][]byte) {
+	var pes []byte
+	for i := 0; i+188 <= len(content); i += 188 {
+		packet := content[i : i+188]
+		h := mpegts.ParseTsPacketHeader(packet)
+		if h.Pid != pid {
+			continue
+		}
+		pos := 4
+		if h.Adaptation&0x2 != 0 {
+			pos += 1 + int(packet[4])
+		}
+		if h.PayloadUnitStart == 1 {
+			if pes != nil {
+				ret = append(ret, pes)
+			}
+			pes = nil
+			pos += 9 + int(packet[pos+8])
+		}
+		pes = append(pes, packet[pos:]...)
+	}
+	if pes != nil {
+		ret = append(ret, pes)
+	}
+	return
+}
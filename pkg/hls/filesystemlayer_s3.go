@@ -0,0 +1,714 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package hls
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/naza/pkg/filesystemlayer"
+	"github.com/q191201771/naza/pkg/nazaerrors"
+)
+
+// filesystemlayer_s3.go
+//
+// 将HLS文件（m3u8、ts等）上传到S3兼容的对象存储（比如AWS S3、MinIO），使得CDN可以直接从对象存储回源
+//
+// - 所有文件操作先在本地缓存（磁盘或内存，也即原来的 fslCtx）上执行，读操作只读本地缓存，所以lalserver自身依然可以提供HLS服务
+// - 写操作完成后，异步上传到对象存储，失败时重试
+// - 同一个目录（也即同一个流）下的文件按操作顺序串行上传，不同目录之间并行
+// - 超过 S3Config.MultipartThresholdBytes 的文件使用分片上传
+// - 每个worker的任务队列长度有上限（ S3Config.UploadQueueSize ），队列满时丢弃最早的任务，丢弃数量见 FslS3.Stat
+// - 删除过期的fragment（ CleanupModeAsap ），以及流结束后删除整个目录（ RemoveAll ），同样会删除对象存储中的文件
+// - m3u8使用先写`.bak`再rename的方式保证原子性，对象存储的PUT本身就是原子的，所以`.bak`文件只存在于本地缓存
+//
+// 对象存储中的key为 S3Config.KeyPrefix + 本地文件相对于 rootPath（也即hls的out_path）的路径，比如 live/test110/playlist.m3u8
+//
+// 开启 S3Config.UploadRecordFlag 后，flv、ts录制文件在录制结束后也会上传，见 UploadRecordFile ，
+// key为 S3Config.RecordKeyPrefix + 文件名。录制文件从磁盘分片读取上传，不会整体读入内存
+//
+// 请求使用path-style（<endpoint>/<bucket>/<key>）以及AWS Signature Version 4签名
+
+const FslTypeS3 filesystemlayer.FslType = 3
+
+// S3Config
+//
+// 各字段为0值时，使用默认值
+type S3Config struct {
+	Enable    bool   `json:"enable"`
+	Endpoint  string `json:"endpoint"` // 比如 http://127.0.0.1:9000
+	Region    string `json:"region"`   // 默认 us-east-1
+	Bucket    string `json:"bucket"`
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+	KeyPrefix string `json:"key_prefix"`
+
+	UploadWorkerNum         int `json:"upload_worker_num"`         // 并行上传的协程数量，默认4
+	MaxRetry                int `json:"max_retry"`                 // 默认3
+	TimeoutMs               int `json:"timeout_ms"`                // 单个请求的超时时间，默认10000
+	MultipartThresholdBytes int `json:"multipart_threshold_bytes"` // 默认16MB
+	PartSizeBytes           int `json:"part_size_bytes"`           // 默认8MB，注意，S3要求除最后一个分片以外，分片大小不能小于5MB
+	UploadQueueSize         int `json:"upload_queue_size"`         // 每个worker的任务队列长度，默认64
+
+	UploadRecordFlag bool   `json:"upload_record_flag"` // 录制文件（flv、ts）结束后是否上传
+	RecordKeyPrefix  string `json:"record_key_prefix"`  // 录制文件的key前缀
+}
+
+const (
+	defaultS3Region                  = "us-east-1"
+	defaultS3UploadWorkerNum         = 4
+	defaultS3MaxRetry                = 3
+	defaultS3TimeoutMs               = 10000
+	defaultS3MultipartThresholdBytes = 16 * 1024 * 1024
+	defaultS3PartSizeBytes           = 8 * 1024 * 1024
+	defaultS3UploadQueueSize         = 64
+
+	s3RetryBaseInterval = 200 * time.Millisecond
+)
+
+// UseS3Storage 在当前的本地存储（见 SetUseMemoryAsDiskFlag ）之上，增加对象存储
+//
+// @param rootPath: hls的out_path
+func UseS3Storage(config S3Config, rootPath string) {
+	fslCtx = NewFslS3(config, rootPath, fslCtx)
+}
+
+// DisposeS3Storage 如果使用了对象存储，停止所有的上传协程，未完成的任务被丢弃
+func DisposeS3Storage() {
+	if f, ok := fslCtx.(*FslS3); ok {
+		f.Dispose()
+	}
+}
+
+// GetS3Stat 如果没有使用对象存储，ok返回false
+func GetS3Stat() (stat S3Stat, ok bool) {
+	if f, ok := fslCtx.(*FslS3); ok {
+		return f.Stat(), true
+	}
+	return stat, false
+}
+
+// UploadRecordFile 录制文件关闭后调用，如果使用了对象存储并且开启了 S3Config.UploadRecordFlag ，则异步上传该文件
+func UploadRecordFile(filename string) {
+	if f, ok := fslCtx.(*FslS3); ok && f.config.UploadRecordFlag {
+		f.UploadFile(filename, f.config.RecordKeyPrefix+filepath.Base(filename))
+	}
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+var _ filesystemlayer.IFileSystemLayer = &FslS3{}
+
+type FslS3 struct {
+	config   S3Config
+	rootPath string // const after init, 已经转换为`/`分隔
+	local    filesystemlayer.IFileSystemLayer
+	client   http.Client
+	workers  []*s3Worker
+	wg       sync.WaitGroup // 未完成的任务
+
+	ctx        context.Context // Dispose 时取消，用于中断正在进行的请求以及重试等待
+	cancel     context.CancelFunc
+	workerWg   sync.WaitGroup
+	disposeMu  sync.Mutex
+	disposed   bool
+	droppedNum uint64
+	failedNum  uint64
+}
+
+type S3Stat struct {
+	PendingTaskNum int    // 队列中等待处理的任务数量
+	DroppedTaskNum uint64 // 因队列满或 Dispose 被丢弃的任务数量
+	FailedTaskNum  uint64 // 重试后依然失败的任务数量
+}
+
+type s3Op int
+
+const (
+	s3OpPut s3Op = iota + 1
+	s3OpDelete
+	s3OpDeletePrefix
+	s3OpPutFile // 从磁盘读取文件上传
+)
+
+type s3Task struct {
+	op       s3Op
+	key      string // s3OpDeletePrefix时为前缀
+	data     []byte
+	filename string // s3OpPutFile时使用
+}
+
+type s3Worker struct {
+	tasks chan s3Task
+}
+
+func NewFslS3(config S3Config, rootPath string, local filesystemlayer.IFileSystemLayer) *FslS3 {
+	if config.Region == "" {
+		config.Region = defaultS3Region
+	}
+	if config.UploadWorkerNum <= 0 {
+		config.UploadWorkerNum = defaultS3UploadWorkerNum
+	}
+	if config.MaxRetry <= 0 {
+		config.MaxRetry = defaultS3MaxRetry
+	}
+	if config.TimeoutMs <= 0 {
+		config.TimeoutMs = defaultS3TimeoutMs
+	}
+	if config.MultipartThresholdBytes <= 0 {
+		config.MultipartThresholdBytes = defaultS3MultipartThresholdBytes
+	}
+	if config.PartSizeBytes <= 0 {
+		config.PartSizeBytes = defaultS3PartSizeBytes
+	}
+	if config.UploadQueueSize <= 0 {
+		config.UploadQueueSize = defaultS3UploadQueueSize
+	}
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+
+	f := &FslS3{
+		config:   config,
+		rootPath: path.Clean(filepath.ToSlash(rootPath)),
+		local:    local,
+		client: http.Client{
+			Timeout: time.Duration(config.TimeoutMs) * time.Millisecond,
+		},
+	}
+	f.ctx, f.cancel = context.WithCancel(context.Background())
+	for i := 0; i < config.UploadWorkerNum; i++ {
+		w := &s3Worker{
+			tasks: make(chan s3Task, config.UploadQueueSize),
+		}
+		f.workers = append(f.workers, w)
+		f.workerWg.Add(1)
+		go f.runWorker(w)
+	}
+	Log.Infof("use s3 storage. endpoint=%s, bucket=%s, prefix=%s, root=%s", config.Endpoint, config.Bucket, config.KeyPrefix, rootPath)
+	return f
+}
+
+// Flush 阻塞直到当前所有的上传、删除任务处理完成（包含失败、丢弃的情况）
+func (f *FslS3) Flush() {
+	f.wg.Wait()
+}
+
+// Dispose 中断正在进行的任务，丢弃队列中的任务，并等待所有worker协程退出。之后新增的任务直接丢弃
+func (f *FslS3) Dispose() {
+	f.disposeMu.Lock()
+	if f.disposed {
+		f.disposeMu.Unlock()
+		return
+	}
+	f.disposed = true
+	f.disposeMu.Unlock()
+
+	f.cancel()
+	f.workerWg.Wait()
+
+	var n int
+	for _, w := range f.workers {
+		for len(w.tasks) > 0 {
+			<-w.tasks
+			f.wg.Done()
+			n++
+		}
+	}
+	atomic.AddUint64(&f.droppedNum, uint64(n))
+	Log.Infof("dispose s3 storage. discarded task=%d", n)
+}
+
+// UploadFile 异步上传磁盘上的文件（不经过本地缓存），上传时才读取文件内容
+func (f *FslS3) UploadFile(filename string, key string) {
+	f.addTask(s3Task{op: s3OpPutFile, key: key, filename: filename})
+}
+
+func (f *FslS3) Stat() S3Stat {
+	var pending int
+	for _, w := range f.workers {
+		pending += len(w.tasks)
+	}
+	return S3Stat{
+		PendingTaskNum: pending,
+		DroppedTaskNum: atomic.LoadUint64(&f.droppedNum),
+		FailedTaskNum:  atomic.LoadUint64(&f.failedNum),
+	}
+}
+
+func (f *FslS3) Type() filesystemlayer.FslType {
+	return FslTypeS3
+}
+
+func (f *FslS3) Create(name string) (filesystemlayer.IFile, error) {
+	fp, err := f.local.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return &s3File{
+		fsl:  f,
+		name: name,
+		fp:   fp,
+	}, nil
+}
+
+func (f *FslS3) Rename(oldpath string, newpath string) error {
+	if err := f.local.Rename(oldpath, newpath); err != nil {
+		return err
+	}
+	if err := f.upload(newpath); err != nil {
+		return err
+	}
+	if !isLocalOnlyFile(oldpath) {
+		f.addTask(s3Task{op: s3OpDelete, key: f.objectKey(oldpath)})
+	}
+	return nil
+}
+
+func (f *FslS3) MkdirAll(path string, perm uint32) error {
+	// 对象存储没有目录的概念
+	return f.local.MkdirAll(path, perm)
+}
+
+func (f *FslS3) Remove(name string) error {
+	err := f.local.Remove(name)
+	if !isLocalOnlyFile(name) {
+		f.addTask(s3Task{op: s3OpDelete, key: f.objectKey(name)})
+	}
+	return err
+}
+
+func (f *FslS3) RemoveAll(path string) error {
+	err := f.local.RemoveAll(path)
+	f.addTask(s3Task{op: s3OpDeletePrefix, key: f.objectKey(path) + "/"})
+	return err
+}
+
+func (f *FslS3) ReadFile(filename string) ([]byte, error) {
+	return f.local.ReadFile(filename)
+}
+
+func (f *FslS3) WriteFile(filename string, data []byte, perm uint32) error {
+	if err := f.local.WriteFile(filename, data, perm); err != nil {
+		return err
+	}
+	if isLocalOnlyFile(filename) {
+		return nil
+	}
+	f.addTask(s3Task{op: s3OpPut, key: f.objectKey(filename), data: data})
+	return nil
+}
+
+// ----- private -------------------------------------------------------------------------------------------------------
+
+type s3File struct {
+	fsl  *FslS3
+	name string
+	fp   filesystemlayer.IFile
+}
+
+func (sf *s3File) Write(b []byte) (int, error) {
+	return sf.fp.Write(b)
+}
+
+// Close 文件写完后再整体上传
+func (sf *s3File) Close() error {
+	if err := sf.fp.Close(); err != nil {
+		return err
+	}
+	return sf.fsl.upload(sf.name)
+}
+
+func isLocalOnlyFile(name string) bool {
+	return strings.HasSuffix(name, ".bak")
+}
+
+func (f *FslS3) upload(name string) error {
+	if isLocalOnlyFile(name) {
+		return nil
+	}
+	data, err := f.local.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	f.addTask(s3Task{op: s3OpPut, key: f.objectKey(name), data: data})
+	return nil
+}
+
+func (f *FslS3) objectKey(name string) string {
+	p := path.Clean(filepath.ToSlash(name))
+	p = strings.TrimPrefix(strings.TrimPrefix(p, f.rootPath), "/")
+	return f.config.KeyPrefix + p
+}
+
+// addTask 同一个目录下的任务分配到同一个worker，保证顺序
+//
+// 队列满时丢弃该worker中最早的任务，避免对象存储不可用时内存无限增长
+func (f *FslS3) addTask(task s3Task) {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(path.Dir(strings.TrimSuffix(task.key, "/") + "/x")))
+	w := f.workers[int(h.Sum32()%uint32(len(f.workers)))]
+
+	// 持有锁，保证 Dispose 清空队列后不会再有任务入队
+	f.disposeMu.Lock()
+	defer f.disposeMu.Unlock()
+	if f.disposed {
+		atomic.AddUint64(&f.droppedNum, 1)
+		return
+	}
+
+	f.wg.Add(1)
+	for {
+		select {
+		case w.tasks <- task:
+			return
+		default:
+		}
+
+		select {
+		case old := <-w.tasks:
+			atomic.AddUint64(&f.droppedNum, 1)
+			Log.Warnf("s3 task queue full, drop oldest task. op=%d, key=%s", old.op, old.key)
+			f.wg.Done()
+		default:
+		}
+	}
+}
+
+func (f *FslS3) runWorker(w *s3Worker) {
+	defer f.workerWg.Done()
+	for {
+		var task s3Task
+		select {
+		case <-f.ctx.Done():
+			return
+		case task = <-w.tasks:
+		}
+		if f.ctx.Err() != nil {
+			// select在多个case都满足时随机选择，Dispose 之后取出的任务同样丢弃
+			atomic.AddUint64(&f.droppedNum, 1)
+			f.wg.Done()
+			return
+		}
+
+		var err error
+		for i := 0; i <= f.config.MaxRetry; i++ {
+			if i != 0 {
+				select {
+				case <-f.ctx.Done():
+				case <-time.After(s3RetryBaseInterval * time.Duration(1<<uint(i-1))):
+				}
+			}
+			if f.ctx.Err() != nil {
+				err = f.ctx.Err()
+				break
+			}
+			if err = f.doTask(task); err == nil {
+				break
+			}
+			Log.Warnf("s3 task failed. op=%d, key=%s, retry=%d, err=%+v", task.op, task.key, i, err)
+		}
+		if err != nil {
+			atomic.AddUint64(&f.failedNum, 1)
+			Log.Errorf("s3 task failed finally. op=%d, key=%s, err=%+v", task.op, task.key, err)
+		}
+		f.wg.Done()
+	}
+}
+
+func (f *FslS3) doTask(task s3Task) error {
+	switch task.op {
+	case s3OpPut:
+		if len(task.data) > f.config.MultipartThresholdBytes {
+			return f.multipartUpload(task.key, bytes.NewReader(task.data), int64(len(task.data)))
+		}
+		_, _, err := f.request(http.MethodPut, task.key, nil, task.data)
+		return err
+	case s3OpPutFile:
+		return f.uploadFile(task.key, task.filename)
+	case s3OpDelete:
+		_, _, err := f.request(http.MethodDelete, task.key, nil, nil)
+		return err
+	case s3OpDeletePrefix:
+		keys, err := f.listObjects(task.key)
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if _, _, err = f.request(http.MethodDelete, k, nil, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// uploadFile 上传磁盘上的文件，大文件分片读取上传
+func (f *FslS3) uploadFile(key string, filename string) error {
+	fp, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	fi, err := fp.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() > int64(f.config.MultipartThresholdBytes) {
+		return f.multipartUpload(key, fp, fi.Size())
+	}
+	data, err := io.ReadAll(fp)
+	if err != nil {
+		return err
+	}
+	_, _, err = f.request(http.MethodPut, key, nil, data)
+	return err
+}
+
+// multipartUpload 分片上传，每次只从r中读取一个分片的数据
+func (f *FslS3) multipartUpload(key string, r io.ReaderAt, size int64) error {
+	_, body, err := f.request(http.MethodPost, key, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return err
+	}
+	var initResult struct {
+		UploadId string `xml:"UploadId"`
+	}
+	if err = xml.Unmarshal(body, &initResult); err != nil {
+		return err
+	}
+	uploadId := initResult.UploadId
+
+	type part struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	}
+	var complete struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []part   `xml:"Part"`
+	}
+
+	partSize := int64(f.config.PartSizeBytes)
+	buf := make([]byte, partSize)
+	for i := int64(0); i*partSize < size; i++ {
+		n := size - i*partSize
+		if n > partSize {
+			n = partSize
+		}
+		if _, err = r.ReadAt(buf[:n], i*partSize); err != nil && err != io.EOF {
+			_, _, _ = f.request(http.MethodDelete, key, url.Values{"uploadId": {uploadId}}, nil)
+			return err
+		}
+		partNumber := int(i + 1)
+		q := url.Values{
+			"partNumber": {strconv.Itoa(partNumber)},
+			"uploadId":   {uploadId},
+		}
+		header, _, err := f.request(http.MethodPut, key, q, buf[:n])
+		if err != nil {
+			_, _, _ = f.request(http.MethodDelete, key, url.Values{"uploadId": {uploadId}}, nil)
+			return err
+		}
+		complete.Parts = append(complete.Parts, part{PartNumber: partNumber, ETag: header.Get("ETag")})
+	}
+
+	completeBody, err := xml.Marshal(complete)
+	if err != nil {
+		return err
+	}
+	if _, _, err = f.request(http.MethodPost, key, url.Values{"uploadId": {uploadId}}, completeBody); err != nil {
+		_, _, _ = f.request(http.MethodDelete, key, url.Values{"uploadId": {uploadId}}, nil)
+		return err
+	}
+	return nil
+}
+
+func (f *FslS3) listObjects(prefix string) (keys []string, err error) {
+	var token string
+	for {
+		q := url.Values{
+			"list-type": {"2"},
+			"prefix":    {prefix},
+		}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		_, body, err := f.request(http.MethodGet, "", q, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		if err = xml.Unmarshal(body, &result); err != nil {
+			return nil, err
+		}
+		for _, c := range result.Contents {
+			keys = append(keys, c.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// request
+//
+// @param key: 为空时表示对bucket操作
+func (f *FslS3) request(method string, key string, query url.Values, body []byte) (http.Header, []byte, error) {
+	rawPath := "/" + f.config.Bucket
+	if key != "" {
+		rawPath += "/" + key
+	}
+	u, err := url.Parse(f.config.Endpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	u.Path = rawPath
+	u.RawPath = s3UriEncode(rawPath, false)
+	u.RawQuery = s3CanonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(f.ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	s3SignV4(req, body, f.config.AccessKey, f.config.SecretKey, f.config.Region, time.Now())
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, nil, nazaerrors.Wrap(base.ErrHlsS3, fmt.Sprintf("method=%s, key=%s, status=%d, body=%s", method, key, resp.StatusCode, respBody))
+	}
+	return resp.Header, respBody, nil
+}
+
+// ----- AWS Signature Version 4 ---------------------------------------------------------------------------------------
+
+// s3SignV4 对请求签名，签名的header为host以及req.Header中的所有字段
+//
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func s3SignV4(req *http.Request, body []byte, accessKey, secretKey, region string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	headers := map[string]string{
+		"host": req.URL.Host,
+	}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	var names []string
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	signingKey := hmacSha256([]byte("AWS4"+secretKey), date)
+	signingKey = hmacSha256(signingKey, region)
+	signingKey = hmacSha256(signingKey, "s3")
+	signingKey = hmacSha256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+// s3CanonicalQuery key按字典序排序，key和value都做uri编码
+func s3CanonicalQuery(query url.Values) string {
+	var keys []string
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var items []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			items = append(items, s3UriEncode(k, true)+"="+s3UriEncode(v, true))
+		}
+	}
+	return strings.Join(items, "&")
+}
+
+// s3UriEncode 除了RFC3986中的unreserved字符，其他字符都编码
+func s3UriEncode(s string, encodeSlash bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			sb.WriteByte(c)
+		} else {
+			sb.WriteString(fmt.Sprintf("%%%02X", c))
+		}
+	}
+	return sb.String()
+}
+
+func sha256Hex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+	return h.Sum(nil)
+}
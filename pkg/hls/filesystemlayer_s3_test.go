@@ -0,0 +1,321 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package hls_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/q191201771/lal/pkg/hls"
+	"github.com/q191201771/naza/pkg/assert"
+	"github.com/q191201771/naza/pkg/filesystemlayer"
+)
+
+// fakeS3 模拟MinIO，只实现了path-style下的PUT、GET、DELETE、ListObjectsV2以及分片上传
+type fakeS3 struct {
+	t      *testing.T
+	bucket string
+
+	mu         sync.Mutex
+	objects    map[string][]byte
+	uploads    map[string]map[int][]byte
+	failPutNum int // 前n次PUT返回500，用于测试重试
+	putCount   int
+	partCount  int
+
+	hold    chan struct{} // 不为nil时，PUT阻塞直到close或者请求被取消
+	entered chan struct{}
+}
+
+func newFakeS3(t *testing.T, bucket string) *fakeS3 {
+	return &fakeS3{
+		t:       t,
+		bucket:  bucket,
+		objects: make(map[string][]byte),
+		uploads: make(map[string]map[int][]byte),
+	}
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.hold != nil && r.Method == http.MethodPut {
+		s.entered <- struct{}{}
+		select {
+		case <-s.hold:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=ak/") || r.Header.Get("x-amz-date") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	p := strings.TrimPrefix(r.URL.Path, "/")
+	if p == s.bucket {
+		s.list(w, r)
+		return
+	}
+	key := strings.TrimPrefix(p, s.bucket+"/")
+	body, _ := io.ReadAll(r.Body)
+	q := r.URL.Query()
+
+	switch {
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		uploadId := fmt.Sprintf("upload-%d", len(s.uploads))
+		s.uploads[uploadId] = make(map[int][]byte)
+		_, _ = fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", uploadId)
+	case r.Method == http.MethodPut && q.Has("uploadId"):
+		var n int
+		_, _ = fmt.Sscanf(q.Get("partNumber"), "%d", &n)
+		s.uploads[q.Get("uploadId")][n] = body
+		s.partCount++
+		w.Header().Set("ETag", fmt.Sprintf("\"etag-%d\"", n))
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		parts := s.uploads[q.Get("uploadId")]
+		var buf bytes.Buffer
+		for i := 1; i <= len(parts); i++ {
+			buf.Write(parts[i])
+		}
+		s.objects[key] = buf.Bytes()
+		delete(s.uploads, q.Get("uploadId"))
+	case r.Method == http.MethodPut:
+		s.putCount++
+		if s.putCount <= s.failPutNum {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		s.objects[key] = body
+	case r.Method == http.MethodGet:
+		b, ok := s.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(b)
+	case r.Method == http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// list 每页最多返回2个，用于测试翻页
+func (s *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	var keys []string
+	for k := range s.objects {
+		if strings.HasPrefix(k, prefix) && k > r.URL.Query().Get("continuation-token") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	type content struct {
+		Key string `xml:"Key"`
+	}
+	var result struct {
+		XMLName               xml.Name  `xml:"ListBucketResult"`
+		Contents              []content `xml:"Contents"`
+		IsTruncated           bool      `xml:"IsTruncated"`
+		NextContinuationToken string    `xml:"NextContinuationToken,omitempty"`
+	}
+	for i, k := range keys {
+		if i == 2 {
+			result.IsTruncated = true
+			result.NextContinuationToken = keys[1]
+			break
+		}
+		result.Contents = append(result.Contents, content{Key: k})
+	}
+	b, _ := xml.Marshal(result)
+	_, _ = w.Write(b)
+}
+
+func (s *fakeS3) get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.objects[key]
+	return b, ok
+}
+
+func (s *fakeS3) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.objects)
+}
+
+func TestFslS3(t *testing.T) {
+	fake := newFakeS3(t, "lal")
+	fake.failPutNum = 1
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	fsl := hls.NewFslS3(hls.S3Config{
+		Endpoint:                srv.URL,
+		Bucket:                  "lal",
+		AccessKey:               "ak",
+		SecretKey:               "sk",
+		KeyPrefix:               "hls/",
+		MaxRetry:                2,
+		MultipartThresholdBytes: 1024,
+		PartSizeBytes:           400,
+	}, "/tmp/lal/hls/", filesystemlayer.NewFslMemory())
+	assert.Equal(t, hls.FslTypeS3, fsl.Type())
+
+	// 文件close后上传，第一次PUT失败，重试成功
+	fp, err := fsl.Create("/tmp/lal/hls/test110/test110-1620540712084-0.ts")
+	assert.Equal(t, nil, err)
+	_, _ = fp.Write([]byte("ts0"))
+	assert.Equal(t, nil, fp.Close())
+
+	// `.bak`只存在本地，rename后上传
+	assert.Equal(t, nil, fsl.WriteFile("/tmp/lal/hls/test110/playlist.m3u8.bak", []byte("m3u8"), 0666))
+	assert.Equal(t, nil, fsl.Rename("/tmp/lal/hls/test110/playlist.m3u8.bak", "/tmp/lal/hls/test110/playlist.m3u8"))
+
+	// 分片上传
+	big := make([]byte, 2000)
+	for i := range big {
+		big[i] = byte(i)
+	}
+	assert.Equal(t, nil, fsl.WriteFile("/tmp/lal/hls/test110/record.m3u8", big, 0666))
+	fsl.Flush()
+
+	b, ok := fake.get("hls/test110/test110-1620540712084-0.ts")
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []byte("ts0"), b)
+	b, _ = fake.get("hls/test110/playlist.m3u8")
+	assert.Equal(t, []byte("m3u8"), b)
+	_, ok = fake.get("hls/test110/playlist.m3u8.bak")
+	assert.Equal(t, false, ok)
+	b, _ = fake.get("hls/test110/record.m3u8")
+	assert.Equal(t, big, b)
+	assert.Equal(t, 5, fake.partCount)
+	assert.Equal(t, 3, fake.count())
+
+	// 读操作走本地缓存
+	b, err = fsl.ReadFile("/tmp/lal/hls/test110/playlist.m3u8")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []byte("m3u8"), b)
+
+	// 删除过期fragment
+	assert.Equal(t, nil, fsl.Remove("/tmp/lal/hls/test110/test110-1620540712084-0.ts"))
+	fsl.Flush()
+	_, ok = fake.get("hls/test110/test110-1620540712084-0.ts")
+	assert.Equal(t, false, ok)
+
+	// 删除整个目录，fake每页返回2个，覆盖翻页的逻辑
+	for i := 0; i < 3; i++ {
+		_ = fsl.WriteFile(fmt.Sprintf("/tmp/lal/hls/test110/test110-1620540712084-%d.ts", i), []byte("ts"), 0666)
+	}
+	_ = fsl.WriteFile("/tmp/lal/hls/test1100/playlist.m3u8", []byte("m3u8"), 0666)
+	fsl.Flush()
+	assert.Equal(t, 6, fake.count())
+	_ = fsl.RemoveAll("/tmp/lal/hls/test110")
+	fsl.Flush()
+	assert.Equal(t, 1, fake.count())
+	_, ok = fake.get("hls/test1100/playlist.m3u8")
+	assert.Equal(t, true, ok)
+}
+
+func TestFslS3UploadFile(t *testing.T) {
+	fake := newFakeS3(t, "lal")
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	fsl := hls.NewFslS3(hls.S3Config{
+		Endpoint:                srv.URL,
+		Bucket:                  "lal",
+		AccessKey:               "ak",
+		SecretKey:               "sk",
+		MultipartThresholdBytes: 1024,
+		PartSizeBytes:           400,
+	}, "/tmp/lal/hls/", filesystemlayer.NewFslMemory())
+	defer fsl.Dispose()
+
+	dir := t.TempDir()
+	small := filepath.Join(dir, "test110-1620540712.ts")
+	big := filepath.Join(dir, "test110-1620540712.flv")
+	bigData := make([]byte, 2000)
+	for i := range bigData {
+		bigData[i] = byte(i)
+	}
+	assert.Equal(t, nil, os.WriteFile(small, []byte("ts"), 0666))
+	assert.Equal(t, nil, os.WriteFile(big, bigData, 0666))
+
+	fsl.UploadFile(small, "record/test110-1620540712.ts")
+	fsl.UploadFile(big, "record/test110-1620540712.flv")
+	fsl.Flush()
+
+	b, _ := fake.get("record/test110-1620540712.ts")
+	assert.Equal(t, []byte("ts"), b)
+	b, _ = fake.get("record/test110-1620540712.flv")
+	assert.Equal(t, bigData, b)
+	assert.Equal(t, 5, fake.partCount)
+	assert.Equal(t, uint64(0), fsl.Stat().FailedTaskNum)
+}
+
+func TestFslS3QueueAndDispose(t *testing.T) {
+	fake := newFakeS3(t, "lal")
+	fake.hold = make(chan struct{})
+	fake.entered = make(chan struct{}, 16)
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	defer close(fake.hold)
+
+	fsl := hls.NewFslS3(hls.S3Config{
+		Endpoint:        srv.URL,
+		Bucket:          "lal",
+		AccessKey:       "ak",
+		SecretKey:       "sk",
+		UploadWorkerNum: 1,
+		UploadQueueSize: 2,
+	}, "/tmp/lal/hls/", filesystemlayer.NewFslMemory())
+
+	// 第一个任务阻塞在worker中，之后队列中最多保留2个任务，更早的被丢弃
+	_ = fsl.WriteFile("/tmp/lal/hls/test110/0.ts", []byte("ts"), 0666)
+	<-fake.entered
+	for i := 1; i <= 4; i++ {
+		_ = fsl.WriteFile(fmt.Sprintf("/tmp/lal/hls/test110/%d.ts", i), []byte("ts"), 0666)
+	}
+	stat := fsl.Stat()
+	assert.Equal(t, 2, stat.PendingTaskNum)
+	assert.Equal(t, uint64(2), stat.DroppedTaskNum)
+
+	// Dispose中断正在进行的请求，丢弃队列中的任务
+	fsl.Dispose()
+	fsl.Flush()
+	stat = fsl.Stat()
+	assert.Equal(t, 0, stat.PendingTaskNum)
+	assert.Equal(t, uint64(4), stat.DroppedTaskNum)
+	assert.Equal(t, uint64(1), stat.FailedTaskNum)
+
+	// Dispose之后本地缓存依然可用，上传任务直接丢弃
+	assert.Equal(t, nil, fsl.WriteFile("/tmp/lal/hls/test110/5.ts", []byte("ts"), 0666))
+	b, err := fsl.ReadFile("/tmp/lal/hls/test110/5.ts")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []byte("ts"), b)
+	fsl.Flush()
+	assert.Equal(t, uint64(5), fsl.Stat().DroppedTaskNum)
+	assert.Equal(t, 0, fake.count())
+}
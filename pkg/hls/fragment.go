@@ -9,14 +9,24 @@
 package hls
 
 import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+
 	"github.com/q191201771/naza/pkg/filesystemlayer"
 )
 
 type Fragment struct {
 	fp filesystemlayer.IFile
+
+	// 加密使用，为nil表示不加密
+	cbc    cipher.BlockMode
+	remain []byte // 不足一个block的数据，等后续数据凑齐一个block后再加密
 }
 
 func (f *Fragment) OpenFile(filename string) (err error) {
+	f.cbc = nil
+	f.remain = nil
 	f.fp, err = fslCtx.Create(filename)
 	if err != nil {
 		return
@@ -24,11 +34,49 @@ func (f *Fragment) OpenFile(filename string) (err error) {
 	return
 }
 
+// OpenEncryptedFile 打开文件，后续写入的数据使用AES-128-CBC加密
+func (f *Fragment) OpenEncryptedFile(filename string, key []byte, iv []byte) (err error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return
+	}
+	if err = f.OpenFile(filename); err != nil {
+		return
+	}
+	f.cbc = cipher.NewCBCEncrypter(block, iv)
+	return
+}
+
 func (f *Fragment) WriteFile(b []byte) (err error) {
-	_, err = f.fp.Write(b)
+	if f.cbc == nil {
+		_, err = f.fp.Write(b)
+		return
+	}
+
+	f.remain = append(f.remain, b...)
+	n := len(f.remain) / aes.BlockSize * aes.BlockSize
+	if n == 0 {
+		return
+	}
+	out := make([]byte, n)
+	f.cbc.CryptBlocks(out, f.remain[:n])
+	f.remain = append(f.remain[:0], f.remain[n:]...)
+	_, err = f.fp.Write(out)
 	return
 }
 
 func (f *Fragment) CloseFile() error {
+	if f.cbc != nil {
+		// PKCS7填充，数据长度正好是block整数倍时，填充一个完整的block
+		padding := aes.BlockSize - len(f.remain)
+		last := append(f.remain, bytes.Repeat([]byte{byte(padding)}, padding)...)
+		f.cbc.CryptBlocks(last, last)
+		f.cbc = nil
+		f.remain = nil
+		if _, err := f.fp.Write(last); err != nil {
+			_ = f.fp.Close()
+			return err
+		}
+	}
 	return f.fp.Close()
 }
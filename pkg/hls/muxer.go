@@ -11,9 +11,11 @@ package hls
 import (
 	"bytes"
 	"fmt"
+	"sync"
 
 	"github.com/q191201771/naza/pkg/nazaerrors"
 
+	"github.com/q191201771/lal/pkg/aac"
 	"github.com/q191201771/lal/pkg/mpegts"
 
 	"github.com/q191201771/lal/pkg/base"
@@ -40,6 +42,21 @@ type MuxerConfig struct {
 	FragmentNum        int    `json:"fragment_num"`
 	DeleteThreshold    int    `json:"delete_threshold"`
 	CleanupMode        int    `json:"cleanup_mode"` // TODO chef: lalserver的模式1的逻辑是在上层做的，应该重构到hls模块中
+
+	// AlignFragmentFlag
+	//
+	// 为true时，切片边界对齐到时间戳为 FragmentDurationMs 整数倍的位置（之后的第一个I帧），而不是从上一个切片开始计算时长。
+	// 同一个源输出的多个码率的流（时间戳以及I帧位置一致）由此得到对齐的切片边界，ABR时使用
+	AlignFragmentFlag bool `json:"align_fragment_flag"`
+
+	// EncryptFlag 是否加密TS切片，见 encrypt.go
+	EncryptFlag bool `json:"encrypt_flag"`
+
+	// EncryptMethod EncryptMethodXxx，为空时和 EncryptMethodAes128 相同
+	EncryptMethod string `json:"encrypt_method"`
+
+	// KeyRotateFragmentNum 每多少个切片轮换一次密钥，为0表示不轮换
+	KeyRotateFragmentNum int `json:"key_rotate_fragment_num"`
 }
 
 const (
@@ -81,6 +98,20 @@ type Muxer struct {
 	frags  []fragmentInfo // frags TS文件的固定大小环形队列，记录TS的信息
 
 	patpmt []byte
+
+	// 加密相关，见 encrypt.go
+	keyIndex     int            // 当前使用的密钥序号，key为nil时无意义
+	key          []byte         // 当前使用的密钥
+	keyRefs      map[int]int    // keyIndex -> 使用该密钥并且文件还存在的切片数量
+	keyMutex     sync.Mutex     // 保护 readyKeys 和 fetchingKeys ，这两个字段会被获取密钥的协程修改
+	readyKeys    map[int][]byte // 已经获取到，还没有开始使用的密钥
+	fetchingKeys map[int]bool   // 正在获取的密钥
+
+	// SAMPLE-AES相关，见 sample_aes.go
+	sampleAes       bool   // 当前流是否使用SAMPLE-AES，由 EncryptMethod 和流的编码格式决定
+	sampleAesHasAac bool   // 流中是否有AAC
+	asc             []byte // 从ADTS头中获取，写入PMT
+	fragIv          []byte // 当前切片使用的IV
 }
 
 // 记录fragment的一些信息，注意，写m3u8文件时可能还需要用到历史fragment的信息
@@ -89,6 +120,8 @@ type fragmentInfo struct {
 	duration float64 // 当前fragment中数据的时长，单位秒
 	discont  bool    // #EXT-X-DISCONTINUITY
 	filename string
+	keyLine  string // #EXT-X-KEY，为空表示没有加密
+	keyIndex int    // keyLine不为空时有效
 }
 
 // NewMuxer
@@ -111,6 +144,9 @@ func NewMuxer(streamName string, config *MuxerConfig, observer IMuxerObserver) *
 		recordPlayListFilenameBak: recordPlaylistFilenameBak,
 		config:                    config,
 		observer:                  observer,
+		keyRefs:                   make(map[int]int),
+		readyKeys:                 make(map[int][]byte),
+		fetchingKeys:              make(map[int]bool),
 	}
 	m.makeFrags()
 	Log.Infof("[%s] lifecycle new hls muxer. muxer=%p, streamName=%s", uk, m, streamName)
@@ -120,6 +156,10 @@ func NewMuxer(streamName string, config *MuxerConfig, observer IMuxerObserver) *
 func (m *Muxer) Start() {
 	Log.Infof("[%s] start hls muxer.", m.UniqueKey)
 	m.ensureDir()
+
+	if m.isEncryptEnabled() {
+		m.fetchKeyAsync(0)
+	}
 }
 
 func (m *Muxer) Dispose() {
@@ -127,6 +167,13 @@ func (m *Muxer) Dispose() {
 	if err := m.closeFragment(true); err != nil {
 		Log.Errorf("[%s] close fragment error. err=%+v", m.UniqueKey, err)
 	}
+
+	for keyIndex := range m.keyRefs {
+		activeKeys.remove(makeKeyFileName(m.streamName, keyIndex), m)
+	}
+	if m.key != nil {
+		activeKeys.remove(makeKeyFileName(m.streamName, m.keyIndex), m)
+	}
 }
 
 // ---------------------------------------------------------------------------------------------------------------------
@@ -146,10 +193,27 @@ func (m *Muxer) OnTsPackets(tsPackets []byte, frame *mpegts.Frame, boundary bool
 
 func (m *Muxer) FeedPatPmt(b []byte) {
 	m.patpmt = b
+
+	if m.isEncryptEnabled() && m.config.EncryptMethod == EncryptMethodSampleAes {
+		m.sampleAes, m.sampleAesHasAac = checkSampleAes(b)
+		if !m.sampleAes {
+			Log.Warnf("[%s] stream not support SAMPLE-AES, use AES-128 instead.", m.UniqueKey)
+		}
+	}
 }
 
 func (m *Muxer) FeedMpegts(tsPackets []byte, frame *mpegts.Frame, boundary bool) {
 	//Log.Debugf("> FeedMpegts. boundary=%v, frame=%p, sid=%d", boundary, frame, frame.Sid)
+	if m.sampleAes && frame.Sid == mpegts.StreamIdAudio && m.asc == nil {
+		// 第一个切片需要等获取到asc后再开启，见 openFragment
+		asc, err := aac.MakeAscWithAdtsHeader(frame.Raw)
+		if err != nil {
+			Log.Errorf("[%s] make asc failed. err=%+v", m.UniqueKey, err)
+			return
+		}
+		m.asc = asc
+	}
+
 	if frame.Sid == mpegts.StreamIdAudio {
 		// TODO(chef): 为什么音频用pts，视频用dts
 		if err := m.updateFragment(frame.Pts, boundary, frame); err != nil {
@@ -174,6 +238,14 @@ func (m *Muxer) FeedMpegts(tsPackets []byte, frame *mpegts.Frame, boundary bool)
 		//Log.Debugf("[%s] WriteFrame V. dts=%d, len=%d", m.UniqueKey, frame.Dts, len(frame.Raw))
 	}
 
+	if m.sampleAes {
+		var err error
+		if tsPackets, err = m.packSampleAes(frame); err != nil {
+			Log.Errorf("[%s] sample aes encrypt failed. err=%+v", m.UniqueKey, err)
+			return
+		}
+	}
+
 	if err := m.fragment.WriteFile(tsPackets); err != nil {
 		Log.Errorf("[%s] fragment write error. err=%+v", m.UniqueKey, err)
 		return
@@ -237,8 +309,12 @@ func (m *Muxer) updateFragment(ts uint64, boundary bool, frame *mpegts.Frame) er
 		}
 		discont = false
 
-		// 已经有TS切片，切片时长没有达到设置的阈值，则不开启新的切片
-		if f.duration < float64(m.config.FragmentDurationMs)/1000 {
+		// 已经有TS切片，切片时长没有达到设置的阈值（或者没有跨过对齐的边界），则不开启新的切片
+		if m.config.AlignFragmentFlag {
+			if !m.isCrossAlignBoundary(ts) {
+				return nil
+			}
+		} else if f.duration < float64(m.config.FragmentDurationMs)/1000 {
 			return nil
 		}
 	}
@@ -274,11 +350,40 @@ func (m *Muxer) openFragment(ts uint64, discont bool) error {
 	filename := PathStrategy.GetTsFileName(m.streamName, id, int(Clock.Now().UnixNano()/1e6))
 	filenameWithPath := PathStrategy.GetTsFileNameWithPath(m.outPath, filename)
 
-	if err := m.fragment.OpenFile(filenameWithPath); err != nil {
-		return err
+	patpmt := m.patpmt
+	var keyLine string
+	if m.isEncryptEnabled() {
+		if !m.updateKey(id) {
+			// 还没有获取到第一个密钥，不开启切片
+			Log.Warnf("[%s] hls key not ready yet. keyIndex=%d", m.UniqueKey, m.wantKeyIndex(id))
+			return nil
+		}
+
+		if m.sampleAes {
+			if m.asc == nil && m.sampleAesHasAac {
+				// 还没有获取到asc，不开启切片
+				return nil
+			}
+			m.fragIv = makeIv(id)
+			patpmt = packSampleAesPmt(m.patpmt, m.asc)
+			if err := m.fragment.OpenFile(filenameWithPath); err != nil {
+				return err
+			}
+			keyLine = makeExtXKey(EncryptMethodSampleAes, m.streamName, m.keyIndex, id)
+		} else {
+			if err := m.fragment.OpenEncryptedFile(filenameWithPath, m.key, makeIv(id)); err != nil {
+				return err
+			}
+			keyLine = makeExtXKey(EncryptMethodAes128, m.streamName, m.keyIndex, id)
+		}
+		m.keyRefs[m.keyIndex]++
+	} else {
+		if err := m.fragment.OpenFile(filenameWithPath); err != nil {
+			return err
+		}
 	}
 
-	if err := m.fragment.WriteFile(m.patpmt); err != nil {
+	if err := m.fragment.WriteFile(patpmt); err != nil {
 		return err
 	}
 
@@ -289,6 +394,8 @@ func (m *Muxer) openFragment(ts uint64, discont bool) error {
 	frag.id = id
 	frag.filename = filename
 	frag.duration = 0
+	frag.keyLine = keyLine
+	frag.keyIndex = m.keyIndex
 
 	m.fragTs = ts
 
@@ -344,6 +451,9 @@ func (m *Muxer) closeFragment(isLast bool) error {
 			if err := fslCtx.Remove(filenameWithPath); err != nil {
 				Log.Warnf("[%s] remove stale fragment file failed. filename=%s, err=%+v", m.UniqueKey, filenameWithPath, err)
 			}
+			if frag.keyLine != "" {
+				m.releaseKey(frag.keyIndex)
+			}
 		}
 	}
 
@@ -372,7 +482,7 @@ func (m *Muxer) writeRecordPlaylist() {
 		m.recordMaxFragDuration = currFrag.duration + 0.5
 	}
 
-	fragLines := fmt.Sprintf("#EXTINF:%.3f,\n%s\n", currFrag.duration, currFrag.filename)
+	fragLines := fmt.Sprintf("%s#EXTINF:%.3f,\n%s\n", currFrag.keyLine, currFrag.duration, currFrag.filename)
 
 	content, err := fslCtx.ReadFile(m.recordPlayListFilename)
 	if err == nil {
@@ -395,7 +505,7 @@ func (m *Muxer) writeRecordPlaylist() {
 		// m3u8文件不存在
 		var buf bytes.Buffer
 		buf.WriteString("#EXTM3U\n")
-		buf.WriteString("#EXT-X-VERSION:3\n")
+		buf.WriteString(fmt.Sprintf("#EXT-X-VERSION:%d\n", m.playlistVersion()))
 		buf.WriteString(fmt.Sprintf("#EXT-X-TARGETDURATION:%d\n", int(m.recordMaxFragDuration)))
 		buf.WriteString(fmt.Sprintf("#EXT-X-MEDIA-SEQUENCE:%d\n\n", 0))
 
@@ -426,7 +536,7 @@ func (m *Muxer) writePlaylist(isLast bool) {
 	// TODO chef 优化这块buffer的构造
 	var buf bytes.Buffer
 	buf.WriteString("#EXTM3U\n")
-	buf.WriteString("#EXT-X-VERSION:3\n")
+	buf.WriteString(fmt.Sprintf("#EXT-X-VERSION:%d\n", m.playlistVersion()))
 	buf.WriteString("#EXT-X-ALLOW-CACHE:NO\n")
 	buf.WriteString(fmt.Sprintf("#EXT-X-TARGETDURATION:%d\n", int(maxFrag)))
 	buf.WriteString(fmt.Sprintf("#EXT-X-MEDIA-SEQUENCE:%d\n\n", m.extXMediaSeq()))
@@ -436,6 +546,7 @@ func (m *Muxer) writePlaylist(isLast bool) {
 			buf.WriteString("#EXT-X-DISCONTINUITY\n")
 		}
 
+		buf.WriteString(frag.keyLine)
 		buf.WriteString(fmt.Sprintf("#EXTINF:%.3f,\n%s\n", frag.duration, frag.filename))
 	})
 
@@ -448,6 +559,23 @@ func (m *Muxer) writePlaylist(isLast bool) {
 	}
 }
 
+// isCrossAlignBoundary 当前时间戳和当前分片的初始时间戳是否处于不同的对齐区间
+func (m *Muxer) isCrossAlignBoundary(ts uint64) bool {
+	unit := uint64(m.config.FragmentDurationMs * 90)
+	if unit == 0 {
+		return true
+	}
+	return ts/unit != m.fragTs/unit
+}
+
+// playlistVersion SAMPLE-AES要求EXT-X-VERSION不小于5
+func (m *Muxer) playlistVersion() int {
+	if m.sampleAes {
+		return 5
+	}
+	return 3
+}
+
 func (m *Muxer) ensureDir() {
 	// 注意，如果路径已经存在，则啥也不干
 	err := fslCtx.MkdirAll(m.outPath, 0777)
@@ -456,6 +584,134 @@ func (m *Muxer) ensureDir() {
 
 // ---------------------------------------------------------------------------------------------------------------------
 
+func (m *Muxer) isEncryptEnabled() bool {
+	return m.config.EncryptFlag
+}
+
+// wantKeyIndex 切片应该使用的密钥序号
+func (m *Muxer) wantKeyIndex(fragmentId int) int {
+	if m.config.KeyRotateFragmentNum > 0 {
+		return fragmentId / m.config.KeyRotateFragmentNum
+	}
+	return 0
+}
+
+// updateKey 开启切片前调用，切换到切片应该使用的密钥
+//
+// 如果需要轮换密钥，但是新的密钥还没有获取到，则继续使用当前的密钥
+//
+// @return: 还没有任何可用的密钥时返回false
+func (m *Muxer) updateKey(fragmentId int) bool {
+	want := m.wantKeyIndex(fragmentId)
+	if m.key != nil && m.keyIndex == want {
+		return true
+	}
+
+	m.keyMutex.Lock()
+	key, ok := m.readyKeys[want]
+	for keyIndex := range m.readyKeys {
+		if keyIndex <= want {
+			delete(m.readyKeys, keyIndex)
+		}
+	}
+	m.keyMutex.Unlock()
+
+	if !ok {
+		m.fetchKeyAsync(want)
+		if m.key != nil {
+			Log.Warnf("[%s] hls key not ready, keep using previous key. keyIndex=%d, want=%d", m.UniqueKey, m.keyIndex, want)
+		}
+		return m.key != nil
+	}
+
+	if m.key != nil && m.keyRefs[m.keyIndex] == 0 {
+		activeKeys.remove(makeKeyFileName(m.streamName, m.keyIndex), m)
+	}
+	m.keyIndex = want
+	m.key = key
+	activeKeys.add(makeKeyFileName(m.streamName, want), m, key)
+
+	// 提前获取下一个密钥
+	if m.config.KeyRotateFragmentNum > 0 {
+		m.fetchKeyAsync(want + 1)
+	}
+	return true
+}
+
+// fetchKeyAsync 在后台协程中通过 KeyProvider 获取密钥，获取成功后放入 readyKeys
+func (m *Muxer) fetchKeyAsync(keyIndex int) {
+	provider := KeyProvider
+	if provider == nil {
+		// 密钥始终不可用，不会开启切片
+		Log.Errorf("[%s] hls encrypt enabled but key provider not exist. keyIndex=%d", m.UniqueKey, keyIndex)
+		return
+	}
+
+	m.keyMutex.Lock()
+	defer m.keyMutex.Unlock()
+	if _, ok := m.readyKeys[keyIndex]; ok || m.fetchingKeys[keyIndex] {
+		return
+	}
+	m.fetchingKeys[keyIndex] = true
+
+	go func() {
+		key, err := provider.GetKey(m.streamName, keyIndex)
+		if err == nil && len(key) != KeySize {
+			err = nazaerrors.Wrap(base.ErrHlsKey, fmt.Sprintf("len=%d", len(key)))
+		}
+
+		m.keyMutex.Lock()
+		defer m.keyMutex.Unlock()
+		delete(m.fetchingKeys, keyIndex)
+		if err != nil {
+			// 下次开启切片时会重新获取
+			Log.Errorf("[%s] get hls key failed. keyIndex=%d, err=%+v", m.UniqueKey, keyIndex, err)
+			return
+		}
+		m.readyKeys[keyIndex] = key
+	}()
+}
+
+// releaseKey 使用该密钥的切片文件被删除
+func (m *Muxer) releaseKey(keyIndex int) {
+	m.keyRefs[keyIndex]--
+	if m.keyRefs[keyIndex] > 0 {
+		return
+	}
+	delete(m.keyRefs, keyIndex)
+	if m.key != nil && m.keyIndex == keyIndex {
+		return
+	}
+	activeKeys.remove(makeKeyFileName(m.streamName, keyIndex), m)
+}
+
+// packSampleAes 加密帧数据后重新打包成TS
+//
+// 注意，不修改 frame ，使用 Muxer 自己的continuity counter
+func (m *Muxer) packSampleAes(frame *mpegts.Frame) ([]byte, error) {
+	var err error
+	f := *frame
+	if f.Sid == mpegts.StreamIdAudio {
+		if f.Raw, err = sampleAesEncryptAac(frame.Raw, m.key, m.fragIv); err != nil {
+			return nil, err
+		}
+		f.Cc = m.audioCc
+		b := f.Pack()
+		m.audioCc = f.Cc
+		return b, nil
+	}
+
+	if f.Raw, err = sampleAesEncryptAvc(frame.Raw, m.key, m.fragIv); err != nil {
+		return nil, err
+	}
+	f.Cc = m.videoCc
+	b := f.Pack()
+	m.videoCc = f.Cc
+	return b, nil
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
 func (m *Muxer) fragsCapacity() int {
 	return m.config.FragmentNum + m.config.DeleteThreshold + 1
 }
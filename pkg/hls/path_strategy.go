@@ -110,6 +110,8 @@ type DefaultPathStrategy struct {
 // /hls/test110/record.m3u8               -> record.m3u8               test110    m3u8     {rootOutPath}/test110/record.m3u8
 // /hls/test110/test110-1620540712084-.ts -> test110-1620540712084-.ts test110    ts       {rootOutPath/test110/test110-1620540712084-.ts
 // /hls/test110-1620540712084-.ts         -> test110-1620540712084-.ts test110    ts       {rootOutPath/test110/test110-1620540712084-.ts
+// /hls/test110-1620540712084-.vtt        -> test110-1620540712084-.vtt test110   vtt      {rootOutPath/test110/test110-1620540712084-.vtt
+// /hls/test110-key-0.key                 -> test110-key-0.key         test110    key      {rootOutPath/test110/test110-key-0.key（不会读取该文件）
 func (dps *DefaultPathStrategy) GetRequestInfo(urlCtx base.UrlContext, rootOutPath string) (ri RequestInfo) {
 	filename := urlCtx.LastItemOfPath
 	filetype := urlCtx.GetFileType()
@@ -124,7 +126,7 @@ func (dps *DefaultPathStrategy) GetRequestInfo(urlCtx base.UrlContext, rootOutPa
 			ri.StreamName = fileNameWithoutType
 			ri.FileNameWithPath = filepath.Join(rootOutPath, ri.StreamName, playlistM3u8FileName)
 		}
-	} else if filetype == "ts" {
+	} else if filetype == "ts" || filetype == "vtt" || filetype == keyFileType {
 		ri.StreamName = dps.getStreamNameFromTsFileName(filename)
 		ri.FileNameWithPath = filepath.Join(rootOutPath, ri.StreamName, filename)
 	}
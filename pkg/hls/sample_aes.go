@@ -0,0 +1,252 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package hls
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+
+	"github.com/q191201771/lal/pkg/aac"
+	"github.com/q191201771/lal/pkg/avc"
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/lal/pkg/mpegts"
+	"github.com/q191201771/naza/pkg/bele"
+	"github.com/q191201771/naza/pkg/nazaerrors"
+)
+
+// sample_aes.go
+//
+// SAMPLE-AES加密，见<MPEG-2 Stream Encryption Format for HTTP Live Streaming>
+//
+// 只支持H264和AAC，其他编码的流（比如H265、MP3、Opus）没有对应的stream type，退化为 EncryptMethodAes128。
+//
+// H264: nal type为1和5，并且长度大于48字节的nal需要加密。
+// 去掉防竞争字节后，前32字节不加密，之后循环：加密16字节（剩余数据不足17字节时不加密），不加密min(144, 剩余数据)字节。
+// 加密后重新插入防竞争字节。每个nal重置IV。
+//
+// AAC: 每个ADTS帧的ADTS头以及之后的16字节不加密，之后所有完整的16字节块加密，剩余不足16字节的部分不加密。每帧重置IV。
+//
+// PMT中的stream type以及descriptor也需要相应修改，见 packSampleAesPmt 。
+
+const (
+	streamTypeSampleAesAvc uint8 = 0xdb
+	streamTypeSampleAesAac uint8 = 0xcf
+
+	sampleAesAvcLeader     = 32
+	sampleAesAvcMinNalSize = 48
+	sampleAesAvcClearBlock = 144
+	sampleAesAacLeader     = 16
+
+	privateDataIndicatorAvc uint32 = 'z'<<24 | 'a'<<16 | 'v'<<8 | 'c'
+	privateDataIndicatorAac uint32 = 'a'<<24 | 'a'<<16 | 'c'<<8 | 'd'
+	formatIdentifierApad    uint32 = 'a'<<24 | 'p'<<16 | 'a'<<8 | 'd'
+	audioTypeZaac           uint32 = 'z'<<24 | 'a'<<16 | 'a'<<8 | 'c'
+)
+
+// checkSampleAes
+//
+// @param patpmt: PAT+PMT
+//
+// @return supported: 流中所有的stream type都支持SAMPLE-AES
+// @return hasAac:    流中是否有AAC
+func checkSampleAes(patpmt []byte) (supported bool, hasAac bool) {
+	if len(patpmt) < 2*188 {
+		return false, false
+	}
+	pmt := mpegts.ParsePmt(patpmt[188+5:])
+	if len(pmt.ProgramElements) == 0 {
+		return false, false
+	}
+	for _, ppe := range pmt.ProgramElements {
+		switch ppe.StreamType {
+		case mpegts.StreamTypeAvc:
+		case mpegts.StreamTypeAac:
+			hasAac = true
+		default:
+			return false, false
+		}
+	}
+	return true, hasAac
+}
+
+// packSampleAesPmt 将PAT+PMT中的PMT替换为SAMPLE-AES对应的PMT
+//
+// @param asc: 流中有AAC时需要
+func packSampleAesPmt(patpmt []byte, asc []byte) []byte {
+	pmt := mpegts.ParsePmt(patpmt[188+5:])
+
+	var pes []mpegts.PmtProgramElement
+	for _, ppe := range pmt.ProgramElements {
+		switch ppe.StreamType {
+		case mpegts.StreamTypeAvc:
+			pes = append(pes, mpegts.PmtProgramElement{
+				StreamType: streamTypeSampleAesAvc,
+				Pid:        ppe.Pid,
+				Descriptors: []mpegts.Descriptor{
+					{
+						Length: 4,
+						Tag:    mpegts.DescriptorTagPrivateDataIndicator,
+						PrivateDataIndicator: mpegts.DescriptorPrivateDataIndicator{
+							PrivateDataIndicator: privateDataIndicatorAvc,
+						},
+					},
+				},
+			})
+		case mpegts.StreamTypeAac:
+			// audio_setup_information: audio_type(4) priming(2) version(1) setup_data_length(1) setup_data
+			setup := make([]byte, 8+len(asc))
+			bele.BePutUint32(setup, audioTypeZaac)
+			setup[6] = 1
+			setup[7] = uint8(len(asc))
+			copy(setup[8:], asc)
+
+			pes = append(pes, mpegts.PmtProgramElement{
+				StreamType: streamTypeSampleAesAac,
+				Pid:        ppe.Pid,
+				Descriptors: []mpegts.Descriptor{
+					{
+						Length: 4,
+						Tag:    mpegts.DescriptorTagPrivateDataIndicator,
+						PrivateDataIndicator: mpegts.DescriptorPrivateDataIndicator{
+							PrivateDataIndicator: privateDataIndicatorAac,
+						},
+					},
+					{
+						Length: uint8(4 + len(setup)),
+						Tag:    mpegts.DescriptorTagRegistration,
+						Registration: mpegts.DescriptorRegistration{
+							FormatIdentifier:             formatIdentifierApad,
+							AdditionalIdentificationInfo: setup,
+						},
+					},
+				},
+			})
+		}
+	}
+
+	out := make([]byte, 0, 2*188)
+	out = append(out, patpmt[:188]...)
+	return append(out, mpegts.PackPmtWithProgramElements(pes)...)
+}
+
+// sampleAesEncryptAvc
+//
+// @param annexb: 函数调用结束后，内部不持有该内存块，也不修改该内存块
+//
+// @return: 新申请的内存块
+func sampleAesEncryptAvc(annexb []byte, key, iv []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(annexb)+len(annexb)/64)
+	err = avc.IterateNaluAnnexb(annexb, func(nal []byte) {
+		if len(nal) == 0 {
+			return
+		}
+		out = append(out, avc.NaluStartCode4...)
+
+		t := avc.ParseNaluType(nal[0])
+		if (t != avc.NaluTypeSlice && t != avc.NaluTypeIdrSlice) || len(nal) <= sampleAesAvcMinNalSize {
+			out = append(out, nal...)
+			return
+		}
+
+		rbsp := removeEmulationPrevention(nal)
+		cbc := cipher.NewCBCEncrypter(block, iv)
+		for pos := sampleAesAvcLeader; pos < len(rbsp); {
+			if len(rbsp)-pos > aes.BlockSize {
+				cbc.CryptBlocks(rbsp[pos:pos+aes.BlockSize], rbsp[pos:pos+aes.BlockSize])
+				pos += aes.BlockSize
+			}
+			pos += sampleAesAvcClearBlock
+		}
+		out = appendEmulationPrevention(out, rbsp)
+	})
+	return out, err
+}
+
+// sampleAesEncryptAac
+//
+// @param adtsFrames: 一个或多个ADTS帧。函数调用结束后，内部不持有该内存块，也不修改该内存块
+//
+// @return: 新申请的内存块
+func sampleAesEncryptAac(adtsFrames []byte, key, iv []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	out := append([]byte(nil), adtsFrames...)
+	for pos := 0; pos+aac.AdtsHeaderLength <= len(out); {
+		ctx, err := aac.NewAdtsHeaderContext(out[pos:])
+		if err != nil {
+			return nil, err
+		}
+		frameLen := int(ctx.AdtsLength)
+		if frameLen < aac.AdtsHeaderLength || pos+frameLen > len(out) {
+			return nil, nazaerrors.Wrap(base.ErrHls, fmt.Sprintf("invalid adts frame length. pos=%d, len=%d", pos, frameLen))
+		}
+		headerLen := aac.AdtsHeaderLength
+		if out[pos+1]&0x1 == 0 {
+			// protection_absent为0，有2字节的CRC
+			headerLen += 2
+		}
+
+		frame := out[pos+headerLen : pos+frameLen]
+		if len(frame) > sampleAesAacLeader {
+			n := (len(frame) - sampleAesAacLeader) / aes.BlockSize * aes.BlockSize
+			enc := frame[sampleAesAacLeader : sampleAesAacLeader+n]
+			cipher.NewCBCEncrypter(block, iv).CryptBlocks(enc, enc)
+		}
+		pos += frameLen
+	}
+	return out, nil
+}
+
+// removeEmulationPrevention 去掉nal中的防竞争字节（0x000003中的0x03）
+//
+// @return: 新申请的内存块
+func removeEmulationPrevention(nal []byte) []byte {
+	out := make([]byte, 0, len(nal))
+	zeroNum := 0
+	for _, b := range nal {
+		if zeroNum >= 2 && b == 0x03 {
+			zeroNum = 0
+			continue
+		}
+		if b == 0 {
+			zeroNum++
+		} else {
+			zeroNum = 0
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// appendEmulationPrevention 将rbsp插入防竞争字节后追加到out
+func appendEmulationPrevention(out []byte, rbsp []byte) []byte {
+	zeroNum := 0
+	for _, b := range rbsp {
+		if zeroNum >= 2 && b <= 0x03 {
+			out = append(out, 0x03)
+			zeroNum = 0
+		}
+		if b == 0 {
+			zeroNum++
+		} else {
+			zeroNum = 0
+		}
+		out = append(out, b)
+	}
+	return out
+}
@@ -34,6 +34,8 @@ type ServerHandler struct {
 	mutex             sync.Mutex
 	subSessionTimeout time.Duration
 	subSessionHashKey string
+
+	masterPlaylistProvider IMasterPlaylistProvider
 }
 
 func NewServerHandler(outPath, urlPattern, subSessionHashKey string, subSessionTimeoutMs int, observer IHlsServerHandlerObserver) *ServerHandler {
@@ -62,6 +64,11 @@ func (s *ServerHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 	s.ServeHTTPWithUrlCtx(resp, req, urlCtx)
 }
 
+// SetMasterPlaylistProvider 设置后，支持ABR的master playlist请求，见 abr.go
+func (s *ServerHandler) SetMasterPlaylistProvider(provider IMasterPlaylistProvider) {
+	s.masterPlaylistProvider = provider
+}
+
 func (s *ServerHandler) ServeHTTPWithUrlCtx(resp http.ResponseWriter, req *http.Request, urlCtx base.UrlContext) {
 	//Log.Debugf("%+v", req)
 
@@ -76,6 +83,16 @@ func (s *ServerHandler) ServeHTTPWithUrlCtx(resp http.ResponseWriter, req *http.
 	filename := urlCtx.LastItemOfPath
 	filetype := urlCtx.GetFileType()
 
+	// master playlist不创建sub session，由variant的m3u8请求创建
+	if filetype == "m3u8" && s.serveMasterPlaylistIfExist(resp, urlCtx) {
+		return
+	}
+
+	if filetype == keyFileType {
+		s.serveKey(resp, urlCtx)
+		return
+	}
+
 	// 如果开启了hls sub session功能
 	if s.isSubSessionModeEnable() {
 		sessionIdHash = urlObj.Query().Get("session_id")
@@ -129,7 +146,7 @@ func (s *ServerHandler) ServeHTTPWithUrlCtx(resp http.ResponseWriter, req *http.
 	ri := PathStrategy.GetRequestInfo(urlCtx, s.outPath)
 	//Log.Debugf("%+v", ri)
 
-	if filename == "" || (filetype != "m3u8" && filetype != "ts") || ri.StreamName == "" || ri.FileNameWithPath == "" {
+	if filename == "" || (filetype != "m3u8" && filetype != "ts" && filetype != "vtt") || ri.StreamName == "" || ri.FileNameWithPath == "" {
 		err = errors.New(fmt.Sprintf("invalid hls request. url=%+v, request=%+v", urlCtx, ri))
 		Log.Warnf(err.Error())
 		resp.WriteHeader(http.StatusFound)
@@ -152,9 +169,16 @@ func (s *ServerHandler) ServeHTTPWithUrlCtx(resp http.ResponseWriter, req *http.
 		if sessionIdHash != "" {
 			content = bytes.ReplaceAll(content, []byte(".ts"), []byte(".ts?session_id="+sessionIdHash))
 		}
+		// 给密钥请求都携带上m3u8请求的参数，使得密钥请求可以使用和m3u8请求相同的鉴权
+		if urlCtx.RawQuery != "" {
+			content = bytes.ReplaceAll(content, []byte("."+keyFileType+"\""), []byte("."+keyFileType+"?"+urlCtx.RawQuery+"\""))
+		}
 	case "ts":
 		resp.Header().Add("Content-Type", "video/mp2t")
 		resp.Header().Add("Server", base.LalHlsTsServer)
+	case "vtt":
+		resp.Header().Add("Content-Type", "text/vtt")
+		resp.Header().Add("Server", base.LalHlsTsServer)
 	}
 	resp.Header().Add("Cache-Control", "no-cache")
 	base.AddCorsHeaders2HlsIfNeeded(resp)
@@ -170,6 +194,52 @@ func (s *ServerHandler) ServeHTTPWithUrlCtx(resp http.ResponseWriter, req *http.
 	return
 }
 
+// serveMasterPlaylistIfExist 如果请求的是ABR的master playlist，则回复并返回true
+func (s *ServerHandler) serveMasterPlaylistIfExist(resp http.ResponseWriter, urlCtx base.UrlContext) bool {
+	if s.masterPlaylistProvider == nil {
+		return false
+	}
+
+	var ladderName string
+	if urlCtx.LastItemOfPath == masterM3u8FileName {
+		uriItems := strings.Split(urlCtx.Path, "/")
+		if len(uriItems) < 2 {
+			return false
+		}
+		ladderName = uriItems[len(uriItems)-2]
+	} else {
+		ladderName = urlCtx.GetFilenameWithoutType()
+	}
+
+	content, exist := s.masterPlaylistProvider.GetMasterPlaylist(ladderName)
+	if !exist {
+		return false
+	}
+
+	resp.Header().Add("Content-Type", "application/x-mpegurl")
+	resp.Header().Add("Server", base.LalHlsM3u8Server)
+	resp.Header().Add("Cache-Control", "no-cache")
+	base.AddCorsHeaders2HlsIfNeeded(resp)
+	_, _ = resp.Write(content)
+	return true
+}
+
+// serveKey 回复加密使用的密钥，只回复 Muxer 登记的密钥，见 encrypt.go
+func (s *ServerHandler) serveKey(resp http.ResponseWriter, urlCtx base.UrlContext) {
+	key, ok := activeKeys.get(urlCtx.LastItemOfPath)
+	if !ok {
+		Log.Warnf("hls key not found. url=%+v", urlCtx)
+		resp.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	resp.Header().Add("Content-Type", "application/octet-stream")
+	resp.Header().Add("Server", base.LalHlsTsServer)
+	resp.Header().Add("Cache-Control", "no-cache")
+	base.AddCorsHeaders2HlsIfNeeded(resp)
+	_, _ = resp.Write(key)
+}
+
 // getSubSession 获取 SubSession，如果不存在，返回nil
 func (s *ServerHandler) getSubSession(sessionIdHash string) *SubSession {
 	s.mutex.Lock()
@@ -0,0 +1,236 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package hls
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/q191201771/lal/pkg/base"
+)
+
+// subtitle.go
+//
+// WebVTT字幕的HLS输出
+//
+// 字幕以外部推送的方式输入（见 SubtitleCue），由对应视频流的时间戳驱动切片，
+// 每个切片生成一个vtt文件，并生成一个只包含vtt文件的playlist.m3u8，供master playlist中的`EXT-X-MEDIA:TYPE=SUBTITLES`引用。
+//
+// 字幕的时间戳和视频流的时间戳（也即TS中的PTS/90）使用同一个时间轴，
+// 所以vtt文件中使用`X-TIMESTAMP-MAP=MPEGTS:0,LOCAL:00:00:00.000`
+
+// SubtitleCue 一条字幕
+type SubtitleCue struct {
+	StartMs uint64
+	EndMs   uint64
+	Text    string
+}
+
+// SubtitleStreamName 字幕输出的流名称，也即字幕文件所在子目录的名称
+//
+// language 来自http api，会拼接到文件路径中，所以需要通过 CheckRenditionLanguage 的检查
+func SubtitleStreamName(streamName string, language string) (string, error) {
+	if err := CheckRenditionLanguage(language); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s_sub_%s", streamName, language), nil
+}
+
+type SubtitleMuxer struct {
+	UniqueKey string
+
+	streamName          string // const after init
+	outPath             string // const after init
+	playlistFilename    string // const after init
+	playlistFilenameBak string // const after init
+
+	config *MuxerConfig
+
+	cues []SubtitleCue // 还没有完全写入切片的字幕
+
+	opened     bool
+	fragStart  uint64 // 当前切片的开始时间，毫秒，对齐到 FragmentDurationMs 的整数倍
+	nextId     int
+	discont    bool
+	frags      []fragmentInfo // 当前playlist中的切片
+	staleFrags []fragmentInfo // 已经移出playlist，等待删除的切片
+}
+
+func NewSubtitleMuxer(streamName string, config *MuxerConfig) *SubtitleMuxer {
+	uk := base.GenUkHlsMuxer()
+	op := PathStrategy.GetMuxerOutPath(config.OutPath, streamName)
+	playlistFilename := PathStrategy.GetLiveM3u8FileName(op, streamName)
+	m := &SubtitleMuxer{
+		UniqueKey:           uk,
+		streamName:          streamName,
+		outPath:             op,
+		playlistFilename:    playlistFilename,
+		playlistFilenameBak: fmt.Sprintf("%s.bak", playlistFilename),
+		config:              config,
+	}
+	Log.Infof("[%s] lifecycle new hls subtitle muxer. muxer=%p, streamName=%s", uk, m, streamName)
+	return m
+}
+
+func (m *SubtitleMuxer) Start() {
+	Log.Infof("[%s] start hls subtitle muxer.", m.UniqueKey)
+	err := fslCtx.MkdirAll(m.outPath, 0777)
+	Log.Assert(nil, err)
+}
+
+func (m *SubtitleMuxer) Dispose() {
+	Log.Infof("[%s] lifecycle dispose hls subtitle muxer.", m.UniqueKey)
+	if m.opened {
+		m.closeFragment()
+		m.writePlaylist(true)
+	}
+}
+
+func (m *SubtitleMuxer) StreamName() string {
+	return m.streamName
+}
+
+func (m *SubtitleMuxer) OutPath() string {
+	return m.outPath
+}
+
+// FeedCue 输入一条字幕
+//
+// 注意，如果字幕的结束时间早于当前切片的开始时间，则丢弃
+func (m *SubtitleMuxer) FeedCue(cue SubtitleCue) {
+	if cue.EndMs <= cue.StartMs {
+		Log.Warnf("[%s] invalid subtitle cue. cue=%+v", m.UniqueKey, cue)
+		return
+	}
+	if m.opened && cue.EndMs <= m.fragStart {
+		Log.Warnf("[%s] subtitle cue too late, drop it. fragStart=%d, cue=%+v", m.UniqueKey, m.fragStart, cue)
+		return
+	}
+	m.cues = append(m.cues, cue)
+}
+
+// FeedTimestamp 输入视频流的时间戳，驱动字幕切片
+//
+// @param ms: 视频流的时间戳，毫秒
+func (m *SubtitleMuxer) FeedTimestamp(ms uint64) {
+	duration := uint64(m.config.FragmentDurationMs)
+	if duration == 0 {
+		return
+	}
+	aligned := ms / duration * duration
+
+	if !m.opened {
+		m.openFragment(aligned, true)
+		return
+	}
+
+	if aligned == m.fragStart {
+		return
+	}
+
+	// 正常情况下时间戳前进一个切片，否则认为时间戳发生了跳跃
+	discont := aligned != m.fragStart+duration
+	if discont {
+		Log.Warnf("[%s] subtitle timestamp jump. fragStart=%d, ms=%d", m.UniqueKey, m.fragStart, ms)
+	}
+	m.closeFragment()
+	m.writePlaylist(false)
+	m.openFragment(aligned, discont)
+}
+
+// ----- private -------------------------------------------------------------------------------------------------------
+
+func (m *SubtitleMuxer) openFragment(start uint64, discont bool) {
+	m.opened = true
+	m.fragStart = start
+	m.discont = discont
+}
+
+func (m *SubtitleMuxer) closeFragment() {
+	m.opened = false
+
+	fragEnd := m.fragStart + uint64(m.config.FragmentDurationMs)
+
+	var buf bytes.Buffer
+	buf.WriteString("WEBVTT\n")
+	buf.WriteString("X-TIMESTAMP-MAP=MPEGTS:0,LOCAL:00:00:00.000\n\n")
+
+	var remain []SubtitleCue
+	for _, cue := range m.cues {
+		if cue.StartMs < fragEnd && cue.EndMs > m.fragStart {
+			buf.WriteString(fmt.Sprintf("%s --> %s\n%s\n\n", formatVttTime(cue.StartMs), formatVttTime(cue.EndMs), cue.Text))
+		}
+		if cue.EndMs > fragEnd {
+			remain = append(remain, cue)
+		}
+	}
+	m.cues = remain
+
+	filename := strings.TrimSuffix(PathStrategy.GetTsFileName(m.streamName, m.nextId, int(Clock.Now().UnixNano()/1e6)), ".ts") + ".vtt"
+	filenameWithPath := PathStrategy.GetTsFileNameWithPath(m.outPath, filename)
+	if err := fslCtx.WriteFile(filenameWithPath, buf.Bytes(), 0666); err != nil {
+		Log.Errorf("[%s] write vtt file error. err=%+v", m.UniqueKey, err)
+		return
+	}
+
+	m.frags = append(m.frags, fragmentInfo{
+		id:       m.nextId,
+		duration: float64(m.config.FragmentDurationMs) / 1000,
+		discont:  m.discont,
+		filename: filename,
+	})
+	m.nextId++
+
+	if len(m.frags) > m.config.FragmentNum {
+		m.staleFrags = append(m.staleFrags, m.frags[0])
+		m.frags = m.frags[1:]
+	}
+	if m.config.CleanupMode == CleanupModeAsap && len(m.staleFrags) > m.config.DeleteThreshold {
+		filenameWithPath := PathStrategy.GetTsFileNameWithPath(m.outPath, m.staleFrags[0].filename)
+		if err := fslCtx.Remove(filenameWithPath); err != nil {
+			Log.Warnf("[%s] remove stale vtt file failed. filename=%s, err=%+v", m.UniqueKey, filenameWithPath, err)
+		}
+		m.staleFrags = m.staleFrags[1:]
+	}
+}
+
+func (m *SubtitleMuxer) writePlaylist(isLast bool) {
+	if len(m.frags) == 0 {
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("#EXTM3U\n")
+	buf.WriteString("#EXT-X-VERSION:3\n")
+	buf.WriteString("#EXT-X-ALLOW-CACHE:NO\n")
+	buf.WriteString(fmt.Sprintf("#EXT-X-TARGETDURATION:%d\n", int(math.Ceil(float64(m.config.FragmentDurationMs)/1000))))
+	buf.WriteString(fmt.Sprintf("#EXT-X-MEDIA-SEQUENCE:%d\n\n", m.frags[0].id))
+
+	for _, frag := range m.frags {
+		if frag.discont {
+			buf.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		buf.WriteString(fmt.Sprintf("#EXTINF:%.3f,\n%s\n", frag.duration, frag.filename))
+	}
+
+	if isLast {
+		buf.WriteString("#EXT-X-ENDLIST\n")
+	}
+
+	if err := writeM3u8File(buf.Bytes(), m.playlistFilename, m.playlistFilenameBak); err != nil {
+		Log.Errorf("[%s] write subtitle m3u8 file error. err=%+v", m.UniqueKey, err)
+	}
+}
+
+// formatVttTime 毫秒转换为WebVTT的时间格式，比如 01:02:03.456
+func formatVttTime(ms uint64) string {
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
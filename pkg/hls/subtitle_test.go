@@ -0,0 +1,78 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package hls_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/lal/pkg/hls"
+	"github.com/q191201771/naza/pkg/assert"
+)
+
+func TestSubtitleMuxer(t *testing.T) {
+	config := hls.MuxerConfig{
+		OutPath:            t.TempDir(),
+		FragmentDurationMs: 2000,
+		FragmentNum:        3,
+	}
+	m := hls.NewSubtitleMuxer("test110_sub_en", &config)
+	m.Start()
+
+	m.FeedTimestamp(1000)
+	m.FeedCue(hls.SubtitleCue{StartMs: 1500, EndMs: 2500, Text: "hello"})
+	m.FeedCue(hls.SubtitleCue{StartMs: 4100, EndMs: 4900, Text: "world"})
+	m.FeedTimestamp(2040)
+	m.FeedTimestamp(4040)
+	m.FeedTimestamp(6040)
+
+	playlist, err := hls.ReadFile(filepath.Join(m.OutPath(), "playlist.m3u8"))
+	assert.Equal(t, nil, err)
+	lines := strings.Split(string(playlist), "\n")
+	assert.Equal(t, "#EXT-X-TARGETDURATION:2", lines[3])
+	assert.Equal(t, "#EXT-X-MEDIA-SEQUENCE:0", lines[4])
+
+	var vttList []string
+	for _, line := range lines {
+		if strings.HasSuffix(line, ".vtt") {
+			vttList = append(vttList, line)
+		}
+	}
+	assert.Equal(t, 3, len(vttList))
+
+	// [0, 2000) [2000, 4000) 都包含hello，[4000, 6000) 包含world
+	golden := []string{"hello", "hello", "world"}
+	for i, vtt := range vttList {
+		content, err := hls.ReadFile(filepath.Join(m.OutPath(), vtt))
+		assert.Equal(t, nil, err)
+		assert.Equal(t, true, strings.HasPrefix(string(content), "WEBVTT\nX-TIMESTAMP-MAP=MPEGTS:0,LOCAL:00:00:00.000\n"))
+		assert.Equal(t, true, strings.Contains(string(content), golden[i]))
+	}
+	content, _ := hls.ReadFile(filepath.Join(m.OutPath(), vttList[2]))
+	assert.Equal(t, true, strings.Contains(string(content), "00:00:04.100 --> 00:00:04.900"))
+
+	m.Dispose()
+}
+
+func TestSubtitleStreamName(t *testing.T) {
+	name, err := hls.SubtitleStreamName("test110", "zh-Hans")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "test110_sub_zh-Hans", name)
+
+	for _, language := range []string{"", "../../x", "en/us", "en_us", "a\nb", "abcdefghijklmnopq"} {
+		_, err = hls.SubtitleStreamName("test110", language)
+		assert.Equal(t, true, errors.Is(err, base.ErrHlsRendition))
+	}
+
+	assert.Equal(t, nil, hls.CheckRenditionName("English"))
+	assert.IsNotNil(t, hls.CheckRenditionName("a\",URI=\"x"))
+}
@@ -164,6 +164,7 @@ var _ logic.ILalServer = &logic.ServerManager{}
 var _ rtmp.IServerObserver = &logic.ServerManager{}
 var _ logic.IHttpServerHandlerObserver = &logic.ServerManager{}
 var _ rtsp.IServerObserver = &logic.ServerManager{}
+var _ rtsp.IServerPlayControlObserver = &logic.ServerManager{}
 var _ logic.IGroupCreator = &logic.ServerManager{}
 var _ logic.IGroupObserver = &logic.ServerManager{}
 
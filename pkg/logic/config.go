@@ -16,6 +16,7 @@ import (
 
 	"github.com/q191201771/lal/pkg/base"
 	"github.com/q191201771/lal/pkg/hls"
+	"github.com/q191201771/lal/pkg/rtmp"
 	"github.com/q191201771/lal/pkg/rtsp"
 	"github.com/q191201771/naza/pkg/nazajson"
 	"github.com/q191201771/naza/pkg/nazalog"
@@ -26,6 +27,14 @@ const (
 	defaultHttpflvUrlPattern = "/live/"
 	defaultHttptsUrlPattern  = "/live/"
 	defaultHlsUrlPattern     = "/hls/"
+
+	defaultHlsKeyServerTimeoutMs = 3000
+)
+
+const (
+	HlsKeyProviderTypeMemory = "memory"
+	HlsKeyProviderTypeFile   = "file"
+	HlsKeyProviderTypeHttp   = "http"
 )
 
 type Config struct {
@@ -40,6 +49,7 @@ type Config struct {
 	RecordConfig          RecordConfig          `json:"record"`
 	RelayPushConfig       RelayPushConfig       `json:"relay_push"`
 	StaticRelayPullConfig StaticRelayPullConfig `json:"static_relay_pull"`
+	Gb28181Config         Gb28181Config         `json:"gb28181"`
 
 	HttpApiConfig    HttpApiConfig    `json:"http_api"`
 	ServerId         string           `json:"server_id"`
@@ -60,6 +70,18 @@ type RtmpConfig struct {
 	GopNum               int    `json:"gop_num"` // TODO(chef): refactor 更名为gop_cache_num
 	SingleGopMaxFrameNum int    `json:"single_gop_max_frame_num"`
 	MergeWriteSize       int    `json:"merge_write_size"`
+
+	// connect阶段的adobe、llnw鉴权，见 rtmp.ServerAuth
+	AuthEnable bool   `json:"auth_enable"`
+	AuthMod    string `json:"auth_mod"` // rtmp.AuthModAdobe 或 rtmp.AuthModLlnw
+	UserName   string `json:"username"`
+	PassWord   string `json:"password"`
+
+	// 本端发送数据时的chunk size，通过SetChunkSize通知对端，为0时使用默认值 rtmp.LocalChunkSize
+	// 取值范围为 rtmp.MinChunkSize ~ rtmp.MaxChunkSize 。rtmp sub session以及rtmp转推都使用这个值
+	ChunkSize int `json:"chunk_size"`
+	// 是否压缩发送给rtmp sub session的音视频数据的chunk header，见 rtmp.NewChunkDivider
+	CompressChunkHeader bool `json:"compress_chunk_header"`
 }
 
 type InSessionConfig struct {
@@ -92,6 +114,28 @@ type HlsConfig struct {
 	hls.MuxerConfig
 	SubSessionTimeoutMs int    `json:"sub_session_timeout_ms"`
 	SubSessionHashKey   string `json:"sub_session_hash_key"`
+
+	AbrLadders []AbrLadderConfig `json:"abr_ladders"`
+
+	// EncryptStreamNameList 开启 EncryptFlag 时，只加密列表中的流，为空时加密所有流
+	EncryptStreamNameList []string `json:"encrypt_stream_name_list"`
+
+	// 加密使用的密钥来源，见 hls/encrypt.go
+	KeyProviderType    string `json:"key_provider_type"` // HlsKeyProviderTypeXxx，为空时和memory相同
+	KeyFile            string `json:"key_file"`
+	KeyServerUrl       string `json:"key_server_url"`
+	KeyServerTimeoutMs int    `json:"key_server_timeout_ms"`
+
+	// 上传到S3兼容的对象存储，见 hls/filesystemlayer_s3.go
+	S3 hls.S3Config `json:"s3"`
+}
+
+// AbrLadderConfig 将多个流绑定为一个ABR的master playlist，见 hls/abr.go
+type AbrLadderConfig struct {
+	Name           string                  `json:"name"`
+	StreamNameList []string                `json:"stream_name_list"`
+	AudioList      []base.AbrRenditionInfo `json:"audio_list"`
+	SubtitleList   []base.AbrRenditionInfo `json:"subtitle_list"`
 }
 
 type RtspConfig struct {
@@ -102,9 +146,15 @@ type RtspConfig struct {
 	RtspsCertFile       string `json:"rtsps_cert_file"`
 	RtspsKeyFile        string `json:"rtsps_key_file"`
 	OutWaitKeyFrameFlag bool   `json:"out_wait_key_frame_flag"`
+	AacLatm             bool   `json:"aac_latm"`               // rtmp转rtsp时aac使用MP4A-LATM格式，见 remux.Rtmp2RtspRemuxerOption
+	AacAggregationNum   int    `json:"aac_aggregation_num"`    // rtmp转rtsp时每多少帧aac合并打包，为0或1时不合并
+	AvSyncWaitSrMaxMs   int64  `json:"av_sync_wait_sr_max_ms"` // 输入流等待rtcp sr对齐音视频的最大时长，会增加起播延迟，见 rtsp.AvSyncWaitSrMaxMs
 	WsRtspEnable        bool   `json:"ws_rtsp_enable"`
 	WsRtspAddr          string `json:"ws_rtsp_addr"`
+	HttpTunnelEnable    bool   `json:"http_tunnel_enable"` // RTSP over HTTP隧道
+	HttpTunnelAddr      string `json:"http_tunnel_addr"`
 	rtsp.ServerAuthConfig
+	rtsp.ServerTransportConfig
 }
 
 type RecordConfig struct {
@@ -117,6 +167,9 @@ type RecordConfig struct {
 type RelayPushConfig struct {
 	Enable   bool     `json:"enable"`
 	AddrList []string `json:"addr_list"`
+
+	// 是否压缩转推的音视频数据的chunk header，见 rtmp.NewChunkDivider
+	CompressChunkHeader bool `json:"compress_chunk_header"`
 }
 
 type StaticRelayPullConfig struct {
@@ -124,6 +177,18 @@ type StaticRelayPullConfig struct {
 	Addr   string `json:"addr"`
 }
 
+// Gb28181Config GB28181的SIP信令服务，见 gb28181.SipServer
+type Gb28181Config struct {
+	Enable               bool   `json:"enable"`
+	SipAddr              string `json:"sip_addr"`
+	SipId                string `json:"sip_id"`
+	Realm                string `json:"realm"`
+	Password             string `json:"password"`
+	LocalIp              string `json:"local_ip"`
+	KeepaliveTimeoutMs   int    `json:"keepalive_timeout_ms"`
+	TransactionTimeoutMs int    `json:"transaction_timeout_ms"`
+}
+
 type HttpApiConfig struct {
 	Enable bool   `json:"enable"`
 	Addr   string `json:"addr"`
@@ -326,6 +391,15 @@ func LoadConfAndInitLog(rawContent []byte) *Config {
 		config.HttpflvConfig.UrlPattern = urlPattern
 	}
 
+	// rtmp chunk size超出范围时使用默认值
+	if config.RtmpConfig.ChunkSize == 0 {
+		config.RtmpConfig.ChunkSize = rtmp.LocalChunkSize
+	} else if config.RtmpConfig.ChunkSize < rtmp.MinChunkSize || config.RtmpConfig.ChunkSize > rtmp.MaxChunkSize {
+		Log.Warnf("fix config. rtmp.chunk_size %d is out of range [%d, %d] -> %d",
+			config.RtmpConfig.ChunkSize, rtmp.MinChunkSize, rtmp.MaxChunkSize, rtmp.LocalChunkSize)
+		config.RtmpConfig.ChunkSize = rtmp.LocalChunkSize
+	}
+
 	// 打印配置文件中的元素内容，以及解析后的最终值
 	// 把配置文件原始内容中的换行去掉，使得打印日志时紧凑一些
 	lines := strings.Split(string(rawContent), "\n")
@@ -54,6 +54,7 @@ import (
 //    customizePubSession.WithOnRtmpMsg -> OnReadRtmpAvMsg(enter Lock) -> [dummyAudioFilter] -> broadcastByRtmpMsg -> rtmp, http-flv
 //                                                                                                                 -> rtmp2RtspRemuxer -> rtsp
 //                                                                                                                 -> rtmp2MpegtsRemuxer -> ts, hls
+//                                                                                                                 -> rtmp2AvPacketRemuxer -> ps push
 //
 // ---------------------------------------------------------------------------------------------------------------------
 // rtspPullSession ->
@@ -99,6 +100,8 @@ type Group struct {
 	rtsp2RtmpRemuxer    *remux.AvPacket2RtmpRemuxer // TODO(chef): [refactor] 重命名为avPacket2RtmpRemuxer，因为除了rtsp，customize pub和gb28181 pub都是 202208
 	rtmp2RtspRemuxer    *remux.Rtmp2RtspRemuxer
 	rtmp2MpegtsRemuxer  *remux.Rtmp2MpegtsRemuxer
+	// ps push使用
+	rtmp2AvPacketRemuxer *remux.Rtmp2AvPacketRemuxer
 	// pull
 	pullProxy *pullProxy
 	// rtmp pub使用 TODO(chef): [doc] 更新这个注释，是共同使用 202210
@@ -123,10 +126,16 @@ type Group struct {
 	rtspSubSessionSet    map[*rtsp.SubSession]struct{} // 注意，使用这个容器时，一定要注意 session 的 Stage 属性
 	hlsSubSessionSet     map[*hls.SubSession]struct{}
 	// push
-	pushEnable    bool
-	url2PushProxy map[string]*pushProxy
+	pushEnable       bool
+	url2PushProxy    map[string]*pushProxy
+	psPushSessionSet map[*gb28181.PushSession]struct{}
 	// hls
 	hlsMuxer *hls.Muxer
+	// abr使用，RFC6381格式的codecs
+	hlsVideoCodecs string
+	hlsAudioCodecs string
+	// hls字幕，language -> muxer
+	hlsSubtitleMuxers map[string]*hls.SubtitleMuxer
 	// record
 	recordFlv    *httpflv.FlvFileWriter
 	recordMpegts *mpegts.FileWriter
@@ -163,6 +172,7 @@ func NewGroup(appName string, streamName string, config *Config, option GroupOpt
 		httptsSubSessionSet:        make(map[*httpts.SubSession]struct{}),
 		rtspSubSessionSet:          make(map[*rtsp.SubSession]struct{}),
 		hlsSubSessionSet:           make(map[*hls.SubSession]struct{}),
+		psPushSessionSet:           make(map[*gb28181.PushSession]struct{}),
 		rtmpGopCache:               remux.NewGopCache("rtmp", uk, config.RtmpConfig.GopNum, config.RtmpConfig.SingleGopMaxFrameNum),
 		httpflvGopCache:            remux.NewGopCache("httpflv", uk, config.HttpflvConfig.GopNum, config.HttpflvConfig.SingleGopMaxFrameNum),
 		httptsGopCache:             remux.NewGopCacheMpegts(uk, config.HttptsConfig.GopNum, config.HttptsConfig.SingleGopMaxFrameNum),
@@ -255,6 +265,11 @@ func (group *Group) Dispose() {
 	}
 	group.httptsSubSessionSet = nil
 
+	for session := range group.psPushSessionSet {
+		session.Dispose()
+	}
+	group.psPushSessionSet = nil
+
 	group.delIn()
 }
 
@@ -322,6 +337,13 @@ func (group *Group) GetStat(maxsub int) base.StatGroup {
 		}
 		group.stat.StatSubs = append(group.stat.StatSubs, base.Session2StatSub(s))
 	}
+	for s := range group.psPushSessionSet {
+		statSubCount++
+		if statSubCount > maxsub {
+			break
+		}
+		group.stat.StatSubs = append(group.stat.StatSubs, base.Session2StatSub(s))
+	}
 
 	group.stat.GetFpsFrom(&group.inVideoFpsRecords, time.Now().Unix())
 
@@ -357,6 +379,13 @@ func (group *Group) KickSession(sessionId string) bool {
 			group.psPubSession.Dispose()
 			return true
 		}
+	} else if strings.HasPrefix(sessionId, base.UkPrePsPushSession) {
+		for s := range group.psPushSessionSet {
+			if s.UniqueKey() == sessionId {
+				s.Dispose()
+				return true
+			}
+		}
 	} else if strings.HasPrefix(sessionId, base.UkPreFlvSubSession) {
 		// TODO chef: 考虑数据结构改成sessionIdzuokey的map
 		for s := range group.httpflvSubSessionSet {
@@ -425,7 +454,7 @@ func (group *Group) OutSessionNum() int {
 		}
 	}
 	return len(group.rtmpSubSessionSet) + len(group.rtspSubSessionSet) +
-		len(group.httpflvSubSessionSet) + len(group.httptsSubSessionSet) + pushNum
+		len(group.httpflvSubSessionSet) + len(group.httptsSubSessionSet) + len(group.psPushSessionSet) + pushNum
 }
 
 // ---------------------------------------------------------------------------------------------------------------------
@@ -505,6 +534,12 @@ func (group *Group) disposeInactiveSessions(tickCount uint32) {
 			}
 		}
 	}
+	for session := range group.psPushSessionSet {
+		if _, writeAlive := session.IsAlive(); !writeAlive {
+			Log.Warnf("[%s] session timeout. session=%s", group.UniqueKey, session.UniqueKey())
+			session.Dispose()
+		}
+	}
 }
 
 // updateAllSessionStat 更新所有session的状态
@@ -540,6 +575,9 @@ func (group *Group) updateAllSessionStat() {
 			session.UpdateStat(calcSessionStatIntervalSec)
 		}
 	}
+	for session := range group.psPushSessionSet {
+		session.UpdateStat(calcSessionStatIntervalSec)
+	}
 }
 
 func (group *Group) hasPubSession() bool {
@@ -557,6 +595,9 @@ func (group *Group) hasSubSession() bool {
 }
 
 func (group *Group) hasPushSession() bool {
+	if len(group.psPushSessionSet) != 0 {
+		return true
+	}
 	for _, item := range group.url2PushProxy {
 		if item.isPushing && item.pushSession != nil {
 			return true
@@ -596,6 +637,17 @@ func (group *Group) shouldStartRtspRemuxer() bool {
 	return group.config.RtspConfig.Enable || group.config.RtspConfig.RtspsEnable
 }
 
+func (group *Group) newRtmp2RtspRemuxer() *remux.Rtmp2RtspRemuxer {
+	return remux.NewRtmp2RtspRemuxer(
+		group.onSdpFromRemux,
+		group.onRtpPacketFromRemux,
+		func(option *remux.Rtmp2RtspRemuxerOption) {
+			option.AacLatm = group.config.RtspConfig.AacLatm
+			option.AacAggregationNum = group.config.RtspConfig.AacAggregationNum
+		},
+	)
+}
+
 func (group *Group) shouldStartMpegtsRemuxer() bool {
 	return (group.config.HlsConfig.Enable || group.config.HlsConfig.EnableHttps) ||
 		(group.config.HttptsConfig.Enable || group.config.HttptsConfig.EnableHttps) ||
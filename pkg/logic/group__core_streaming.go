@@ -21,6 +21,7 @@ import (
 	"github.com/q191201771/lal/pkg/avc"
 	"github.com/q191201771/lal/pkg/base"
 	"github.com/q191201771/lal/pkg/hevc"
+	"github.com/q191201771/lal/pkg/hls"
 	"github.com/q191201771/lal/pkg/remux"
 	"github.com/q191201771/lal/pkg/rtprtcp"
 	"github.com/q191201771/lal/pkg/sdp"
@@ -197,6 +198,7 @@ func (group *Group) broadcastByRtmpMsg(msg base.RtmpMsg) {
 
 	// 设置好用于发送的 rtmp 头部信息
 	lazyRtmpChunkDivider.Init(msg)
+	lazyRtmpChunkDivider.SetChunkSize(group.config.RtmpConfig.ChunkSize)
 	lazyRtmpMsg2FlvTag.Init(msg)
 
 	// # 数据有效性检查
@@ -240,6 +242,13 @@ func (group *Group) broadcastByRtmpMsg(msg base.RtmpMsg) {
 		group.rtmp2RtspRemuxer.FeedRtmpMsg(msg)
 	}
 
+	// # ps push
+	// 注意，没有ps push session时，也需要喂入seq header，供后续加入的session使用
+	if group.rtmp2AvPacketRemuxer != nil &&
+		(len(group.psPushSessionSet) != 0 || msg.IsVideoKeySeqHeader() || msg.IsAacSeqHeader()) {
+		_ = group.rtmp2AvPacketRemuxer.FeedRtmpMsg(msg, nil)
+	}
+
 	if group.customizeHookSessionContext != nil {
 		group.customizeHookSessionContext.OnMsg(msg)
 	}
@@ -405,6 +414,7 @@ func (group *Group) broadcastByRtmpMsg(msg base.RtmpMsg) {
 			case base.RtmpSoundFormatAac:
 				if msg.IsAacSeqHeader() {
 					group.stat.AudioCodec = base.AudioCodecAac
+					group.hlsAudioCodecs = hls.MakeAacCodecs(msg.Payload[2:])
 				}
 			case base.RtmpSoundFormatG711U:
 				group.stat.AudioCodec = base.AudioCodecG711U
@@ -412,6 +422,9 @@ func (group *Group) broadcastByRtmpMsg(msg base.RtmpMsg) {
 				group.stat.AudioCodec = base.AudioCodecG711A
 			case base.RtmpSoundFormatOpus:
 				group.stat.AudioCodec = base.AudioCodecOpus
+			case base.RtmpSoundFormatMp3, base.RtmpSoundFormatMp38k:
+				group.stat.AudioCodec = base.AudioCodecMp3
+				group.hlsAudioCodecs = hls.MakeMp3Codecs()
 			}
 		}
 	}
@@ -432,6 +445,7 @@ func (group *Group) broadcastByRtmpMsg(msg base.RtmpMsg) {
 				if err == nil {
 					group.stat.VideoHeight = int(ctx.Height)
 					group.stat.VideoWidth = int(ctx.Width)
+					group.hlsVideoCodecs = hls.MakeAvcCodecs(sps)
 				}
 			}
 		}
@@ -445,11 +459,16 @@ func (group *Group) broadcastByRtmpMsg(msg base.RtmpMsg) {
 			}
 
 			if err == nil {
-				var ctx hevc.Context
+				// 注意，ParseSps内部对flags做与运算，所以初始值需要全部置1
+				ctx := hevc.Context{
+					GeneralProfileCompatibilityFlags: 0xffffffff,
+					GeneralConstraintIndicatorFlags:  0xffffffffffff,
+				}
 				err = hevc.ParseSps(sps, &ctx)
 				if err == nil {
 					group.stat.VideoHeight = int(ctx.PicHeightInLumaSamples)
 					group.stat.VideoWidth = int(ctx.PicWidthInLumaSamples)
+					group.hlsVideoCodecs = hls.MakeHevcCodecs(&ctx)
 				}
 			}
 		}
@@ -457,6 +476,13 @@ func (group *Group) broadcastByRtmpMsg(msg base.RtmpMsg) {
 	if msg.Header.MsgTypeId == base.RtmpTypeIdVideo {
 		group.inVideoFpsRecords.Add(nazalog.Clock.Now().Unix(), 1)
 	}
+
+	// # 用视频的时间戳驱动hls字幕切片
+	if msg.Header.MsgTypeId == base.RtmpTypeIdVideo {
+		for _, m := range group.hlsSubtitleMuxers {
+			m.FeedTimestamp(uint64(msg.Header.TimestampAbs))
+		}
+	}
 }
 
 // ---------------------------------------------------------------------------------------------------------------------
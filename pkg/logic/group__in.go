@@ -35,10 +35,7 @@ func (group *Group) AddCustomizePubSession(streamName string) (ICustomizePubSess
 	group.addIn()
 
 	if group.shouldStartRtspRemuxer() {
-		group.rtmp2RtspRemuxer = remux.NewRtmp2RtspRemuxer(
-			group.onSdpFromRemux,
-			group.onRtpPacketFromRemux,
-		)
+		group.rtmp2RtspRemuxer = group.newRtmp2RtspRemuxer()
 	}
 
 	group.customizePubSession.WithOnRtmpMsg(group.OnReadRtmpAvMsg)
@@ -62,10 +59,7 @@ func (group *Group) AddRtmpPubSession(session *rtmp.ServerSession) error {
 	group.addIn()
 
 	if group.shouldStartRtspRemuxer() {
-		group.rtmp2RtspRemuxer = remux.NewRtmp2RtspRemuxer(
-			group.onSdpFromRemux,
-			group.onRtpPacketFromRemux,
-		)
+		group.rtmp2RtspRemuxer = group.newRtmp2RtspRemuxer()
 	}
 
 	session.SetPubSessionObserver(group)
@@ -130,19 +124,23 @@ func (group *Group) StartRtpPub(req base.ApiCtrlStartRtpPubReq) (ret base.ApiCtr
 	group.rtsp2RtmpRemuxer.WithOnRtmpMsg(group.onRtmpMsgFromRemux)
 
 	if group.shouldStartRtspRemuxer() {
-		group.rtmp2RtspRemuxer = remux.NewRtmp2RtspRemuxer(
-			group.onSdpFromRemux,
-			group.onRtpPacketFromRemux,
-		)
+		group.rtmp2RtspRemuxer = group.newRtmp2RtspRemuxer()
 	}
 
-	port, err := pubSession.Listen(req.Port, req.IsTcpFlag != 0)
-	if err != nil {
-		group.delPsPubSession(pubSession)
-
-		ret.ErrorCode = base.ErrorCodeListenUdpPortFail
-		ret.Desp = err.Error()
-		return
+	var port int
+	if req.RemoteAddr != "" {
+		// tcp主动模式，连接在RunLoop中建立，连接失败时RunLoop返回，session随之删除
+		pubSession.Connect(req.RemoteAddr)
+	} else {
+		var err error
+		port, err = pubSession.Listen(req.Port, req.IsTcpFlag != 0)
+		if err != nil {
+			group.delPsPubSession(pubSession)
+
+			ret.ErrorCode = base.ErrorCodeListenUdpPortFail
+			ret.Desp = err.Error()
+			return
+		}
 	}
 
 	go func() {
@@ -174,10 +172,7 @@ func (group *Group) AddRtmpPullSession(session *rtmp.PullSession) error {
 	group.addIn()
 
 	if group.shouldStartRtspRemuxer() {
-		group.rtmp2RtspRemuxer = remux.NewRtmp2RtspRemuxer(
-			group.onSdpFromRemux,
-			group.onRtpPacketFromRemux,
-		)
+		group.rtmp2RtspRemuxer = group.newRtmp2RtspRemuxer()
 	}
 
 	var info base.PullStartInfo
@@ -226,6 +221,35 @@ func (group *Group) AddRtspPullSession(session *rtsp.PullSession) error {
 	return nil
 }
 
+// StopRtpPub
+//
+// @param sessionId: 为空时不检查
+//
+// @return 被结束的session的id，没有找到时返回false
+func (group *Group) StopRtpPub(sessionId string) (string, bool) {
+	group.mutex.Lock()
+	defer group.mutex.Unlock()
+
+	if group.psPubSession == nil || (sessionId != "" && group.psPubSession.UniqueKey() != sessionId) {
+		return "", false
+	}
+	// Dispose后RunLoop退出，由RunLoop所在的协程将session从group中删除
+	sessionId = group.psPubSession.UniqueKey()
+	group.psPubSession.Dispose()
+	return sessionId, true
+}
+
+// GetRtpPubStat 没有rtp pub session时返回false
+func (group *Group) GetRtpPubStat() (base.StatRtpPub, bool) {
+	group.mutex.Lock()
+	defer group.mutex.Unlock()
+
+	if group.psPubSession == nil {
+		return base.StatRtpPub{}, false
+	}
+	return group.psPubSession.GetRtpPubStat(), true
+}
+
 // ---------------------------------------------------------------------------------------------------------------------
 
 func (group *Group) DelPsPubSession(session *gb28181.PubSession) {
@@ -357,6 +381,8 @@ func (group *Group) addIn() {
 		nazalog.Debugf("[%s] [%s] NewRtmp2MpegtsRemuxer in group.", group.UniqueKey, group.rtmp2MpegtsRemuxer.UniqueKey())
 	}
 
+	group.rtmp2AvPacketRemuxer = remux.NewRtmp2AvPacketRemuxer().WithOnAvPacket(group.onAvPacketFromRtmp2AvPacketRemuxer)
+
 	if group.config.InSessionConfig.AddDummyAudioEnable {
 		group.dummyAudioFilter = remux.NewDummyAudioFilter(group.UniqueKey, group.config.InSessionConfig.AddDummyAudioWaitAudioMs, group.broadcastByRtmpMsg)
 	}
@@ -395,6 +421,7 @@ func (group *Group) delIn() {
 	group.psPubSession = nil
 	group.rtsp2RtmpRemuxer = nil
 	group.rtmp2RtspRemuxer = nil
+	group.rtmp2AvPacketRemuxer = nil
 	group.dummyAudioFilter = nil
 
 	if group.psPubDumpFile != nil {
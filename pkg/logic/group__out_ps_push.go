@@ -0,0 +1,43 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package logic
+
+import (
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/lal/pkg/gb28181"
+)
+
+// AddPsPushSession 将已经 Start 的 gb28181.PushSession 加入group，并启动它的RunLoop
+func (group *Group) AddPsPushSession(session *gb28181.PushSession) {
+	Log.Debugf("[%s] [%s] add ps PushSession into group.", group.UniqueKey, session.UniqueKey())
+	group.mutex.Lock()
+	defer group.mutex.Unlock()
+	group.psPushSessionSet[session] = struct{}{}
+
+	go func() {
+		runErr := session.RunLoop()
+		Log.Debugf("[%s] [%s] ps PushSession run loop exit, err=%v", group.UniqueKey, session.UniqueKey(), runErr)
+		group.DelPsPushSession(session)
+	}()
+}
+
+func (group *Group) DelPsPushSession(session *gb28181.PushSession) {
+	Log.Debugf("[%s] [%s] del ps PushSession from group.", group.UniqueKey, session.UniqueKey())
+	group.mutex.Lock()
+	defer group.mutex.Unlock()
+	delete(group.psPushSessionSet, session)
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+func (group *Group) onAvPacketFromRtmp2AvPacketRemuxer(pkt base.AvPacket, arg interface{}) {
+	for session := range group.psPushSessionSet {
+		session.FeedAvPacket(pkt)
+	}
+}
@@ -9,6 +9,7 @@
 package logic
 
 import (
+	"github.com/q191201771/lal/pkg/base"
 	"github.com/q191201771/lal/pkg/hls"
 	"github.com/q191201771/lal/pkg/httpflv"
 	"github.com/q191201771/lal/pkg/httpts"
@@ -101,6 +102,27 @@ func (group *Group) HandleNewRtspSubSessionPlay(session *rtsp.SubSession) {
 	group.addSub()
 }
 
+// HandleRtspSubSessionPlayControl 直播流只支持暂停和恢复，不支持seek和倍速
+func (group *Group) HandleRtspSubSessionPlayControl(session *rtsp.SubSession) error {
+	ctrl := session.PlayControl()
+	Log.Debugf("[%s] [%s] rtsp sub play control. paused=%v, ctrl=%+v", group.UniqueKey, session.UniqueKey(), session.IsPaused(), ctrl)
+
+	if session.IsPaused() {
+		return nil
+	}
+	if ctrl.Scale != 0 && ctrl.Scale != 1 {
+		return base.ErrRtspScaleNotSupported
+	}
+
+	group.mutex.Lock()
+	defer group.mutex.Unlock()
+
+	// 暂停期间丢弃了数据，恢复后从关键帧开始发送
+	session.ShouldWaitVideoKeyFrame = group.stat.VideoCodec != ""
+	session.SetPlayControl(rtsp.PlayControl{RangeFlag: true, Start: rtsp.NptNow, End: rtsp.NptEnd})
+	return nil
+}
+
 func (group *Group) DelRtmpSubSession(session *rtmp.ServerSession) {
 	group.mutex.Lock()
 	defer group.mutex.Unlock()
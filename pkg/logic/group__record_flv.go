@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"path/filepath"
 
+	"github.com/q191201771/lal/pkg/hls"
 	"github.com/q191201771/lal/pkg/httpflv"
 )
 
@@ -46,7 +47,10 @@ func (group *Group) stopRecordFlvIfNeeded() {
 	}
 
 	if group.recordFlv != nil {
-		_ = group.recordFlv.Dispose()
+		filename := group.recordFlv.Name()
+		if err := group.recordFlv.Dispose(); err == nil {
+			hls.UploadRecordFile(filename)
+		}
 		group.recordFlv = nil
 	}
 }
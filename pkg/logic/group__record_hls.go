@@ -22,7 +22,9 @@ func (group *Group) startHlsIfNeeded() {
 		return
 	}
 
-	group.hlsMuxer = hls.NewMuxer(group.streamName, &group.config.HlsConfig.MuxerConfig, group)
+	muxerConfig := group.config.HlsConfig.MuxerConfig
+	muxerConfig.EncryptFlag = muxerConfig.EncryptFlag && isHlsEncryptStream(&group.config.HlsConfig, group.streamName)
+	group.hlsMuxer = hls.NewMuxer(group.streamName, &muxerConfig, group)
 	group.hlsMuxer.Start()
 }
 
@@ -36,4 +38,104 @@ func (group *Group) stopHlsIfNeeded() {
 		group.observer.CleanupHlsIfNeeded(group.appName, group.streamName, group.hlsMuxer.OutPath())
 		group.hlsMuxer = nil
 	}
+
+	for _, m := range group.hlsSubtitleMuxers {
+		m.Dispose()
+		group.observer.CleanupHlsIfNeeded(group.appName, m.StreamName(), m.OutPath())
+	}
+	group.hlsSubtitleMuxers = nil
+}
+
+// HasHlsSubtitle language 对应的字幕输出是否存在，字幕输出在第一次推送字幕时创建，见 PushHlsSubtitle
+func (group *Group) HasHlsSubtitle(language string) bool {
+	group.mutex.Lock()
+	defer group.mutex.Unlock()
+	_, ok := group.hlsSubtitleMuxers[language]
+	return ok
+}
+
+// PushHlsSubtitle 输入一条字幕
+//
+// 注意，调用方需要保证 language 通过 hls.CheckRenditionLanguage 的检查
+//
+// @return 如果hls没有开启，返回false
+func (group *Group) PushHlsSubtitle(language string, cue hls.SubtitleCue) bool {
+	group.mutex.Lock()
+	defer group.mutex.Unlock()
+	m := group.ensureHlsSubtitle(language)
+	if m == nil {
+		return false
+	}
+	m.FeedCue(cue)
+	return true
+}
+
+func (group *Group) ensureHlsSubtitle(language string) *hls.SubtitleMuxer {
+	if group.hlsMuxer == nil {
+		return nil
+	}
+	if m, ok := group.hlsSubtitleMuxers[language]; ok {
+		return m
+	}
+	streamName, err := hls.SubtitleStreamName(group.streamName, language)
+	if err != nil {
+		Log.Warnf("[%s] invalid subtitle language. err=%+v", group.UniqueKey, err)
+		return nil
+	}
+	if group.hlsSubtitleMuxers == nil {
+		group.hlsSubtitleMuxers = make(map[string]*hls.SubtitleMuxer)
+	}
+	m := hls.NewSubtitleMuxer(streamName, &group.config.HlsConfig.MuxerConfig)
+	m.Start()
+	group.hlsSubtitleMuxers[language] = m
+	return m
+}
+
+// GetAbrVariant 获取ABR master playlist中该流对应的variant信息
+//
+// @return ok: 如果该流还不能播放（比如hls还没有开启，还没有收到视频seq header，码率还没有统计出来），返回false
+func (group *Group) GetAbrVariant() (variant hls.AbrVariant, ok bool) {
+	group.mutex.Lock()
+	defer group.mutex.Unlock()
+
+	if group.hlsMuxer == nil || group.hlsVideoCodecs == "" {
+		return
+	}
+
+	var bitrateKbits int
+	if group.rtmpPubSession != nil {
+		bitrateKbits = group.rtmpPubSession.GetStat().BitrateKbits
+	} else if group.rtspPubSession != nil {
+		bitrateKbits = group.rtspPubSession.GetStat().BitrateKbits
+	} else if group.psPubSession != nil {
+		bitrateKbits = group.psPubSession.GetStat().BitrateKbits
+	} else {
+		bitrateKbits = group.getStatPull().BitrateKbits
+	}
+	if bitrateKbits == 0 {
+		return
+	}
+
+	variant.StreamName = group.streamName
+	variant.Bandwidth = bitrateKbits * 1024
+	variant.Width = group.stat.VideoWidth
+	variant.Height = group.stat.VideoHeight
+	variant.Codecs = group.hlsVideoCodecs
+	if group.hlsAudioCodecs != "" {
+		variant.Codecs += "," + group.hlsAudioCodecs
+	}
+	return variant, true
+}
+
+// isHlsEncryptStream EncryptStreamNameList 为空时所有流都加密
+func isHlsEncryptStream(config *HlsConfig, streamName string) bool {
+	if len(config.EncryptStreamNameList) == 0 {
+		return true
+	}
+	for _, name := range config.EncryptStreamNameList {
+		if name == streamName {
+			return true
+		}
+	}
+	return false
 }
@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"path/filepath"
 
+	"github.com/q191201771/lal/pkg/hls"
 	"github.com/q191201771/lal/pkg/mpegts"
 )
 
@@ -39,7 +40,10 @@ func (group *Group) stopRecordMpegtsIfNeeded() {
 	}
 
 	if group.recordMpegts != nil {
-		_ = group.recordMpegts.Dispose()
+		filename := group.recordMpegts.Name()
+		if err := group.recordMpegts.Dispose(); err == nil {
+			hls.UploadRecordFile(filename)
+		}
 		group.recordMpegts = nil
 	}
 }
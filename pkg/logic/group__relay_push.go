@@ -99,6 +99,9 @@ func (group *Group) startPushIfNeeded() {
 			pushSession := rtmp.NewPushSession(func(option *rtmp.PushSessionOption) {
 				option.PushTimeoutMs = RelayPushTimeoutMs
 				option.WriteAvTimeoutMs = RelayPushWriteAvTimeoutMs
+				// 转推的数据和rtmp sub session共用同一份chunk，所以chunk size需要一致
+				option.ChunkSize = group.config.RtmpConfig.ChunkSize
+				option.CompressChunkHeader = group.config.RelayPushConfig.CompressChunkHeader
 			})
 			err := pushSession.Start(u2)
 			if err != nil {
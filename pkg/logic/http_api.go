@@ -22,6 +22,7 @@ import (
 	"github.com/q191201771/naza/pkg/nazahttp"
 
 	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/lal/pkg/gb28181"
 )
 
 //go:embed http_an__lal.html
@@ -57,12 +58,25 @@ func (h *HttpApiServer) RunLoop() error {
 	mux.HandleFunc("/api/stat/group", h.statGroupHandler)
 	mux.HandleFunc("/api/stat/all_group", h.statAllGroupHandler)
 	mux.HandleFunc("/api/stat/lal_info", h.statLalInfoHandler)
+	mux.HandleFunc("/api/stat/gb28181_devices", h.statGb28181DevicesHandler)
+	mux.HandleFunc("/api/stat/all_rtp_pub", h.statAllRtpPubHandler)
 
 	mux.HandleFunc("/api/ctrl/start_relay_pull", h.ctrlStartRelayPullHandler)
 	mux.HandleFunc("/api/ctrl/stop_relay_pull", h.ctrlStopRelayPullHandler)
 	mux.HandleFunc("/api/ctrl/kick_session", h.ctrlKickSessionHandler)
 	mux.HandleFunc("/api/ctrl/start_rtp_pub", h.ctrlStartRtpPubHandler)
+	mux.HandleFunc("/api/ctrl/stop_rtp_pub", h.ctrlStopRtpPubHandler)
+	mux.HandleFunc("/api/ctrl/start_rtp_push", h.ctrlStartRtpPushHandler)
+	mux.HandleFunc("/api/ctrl/gb28181_invite", h.ctrlGb28181InviteHandler)
+	mux.HandleFunc("/api/ctrl/gb28181_playback", h.ctrlGb28181PlaybackHandler)
+	mux.HandleFunc("/api/ctrl/gb28181_download", h.ctrlGb28181DownloadHandler)
+	mux.HandleFunc("/api/ctrl/gb28181_playback_scale", h.ctrlGb28181PlaybackScaleHandler)
+	mux.HandleFunc("/api/ctrl/gb28181_bye", h.ctrlGb28181ByeHandler)
+	mux.HandleFunc("/api/ctrl/onvif_discover", h.ctrlOnvifDiscoverHandler)
 	mux.HandleFunc("/api/ctrl/add_ip_blacklist", h.ctrlAddIpBlacklistHandler)
+	mux.HandleFunc("/api/ctrl/add_abr_ladder", h.ctrlAddAbrLadderHandler)
+	mux.HandleFunc("/api/ctrl/del_abr_ladder", h.ctrlDelAbrLadderHandler)
+	mux.HandleFunc("/api/ctrl/push_subtitle", h.ctrlPushSubtitleHandler)
 	// 所有没有注册路由的走下面这个处理函数
 	mux.HandleFunc("/", h.notFoundHandler)
 
@@ -91,6 +105,29 @@ func (h *HttpApiServer) statAllGroupHandler(w http.ResponseWriter, req *http.Req
 	feedback(v, w)
 }
 
+func (h *HttpApiServer) statGb28181DevicesHandler(w http.ResponseWriter, req *http.Request) {
+	var v base.ApiStatGb28181DevicesResp
+	devices, enable := h.sm.StatGb28181Devices()
+	if !enable {
+		v.ErrorCode = base.ErrorCodeGb28181NotEnable
+		v.Desp = base.DespGb28181NotEnable
+		feedback(v, w)
+		return
+	}
+	v.ErrorCode = base.ErrorCodeSucc
+	v.Desp = base.DespSucc
+	v.Data.Devices = devices
+	feedback(v, w)
+}
+
+func (h *HttpApiServer) statAllRtpPubHandler(w http.ResponseWriter, req *http.Request) {
+	var v base.ApiStatAllRtpPubResp
+	v.ErrorCode = base.ErrorCodeSucc
+	v.Desp = base.DespSucc
+	v.Data.RtpPubs = h.sm.StatAllRtpPub()
+	feedback(v, w)
+}
+
 func (h *HttpApiServer) statGroupHandler(w http.ResponseWriter, req *http.Request) {
 	var v base.ApiStatGroupResp
 
@@ -217,6 +254,178 @@ func (h *HttpApiServer) ctrlStartRtpPubHandler(w http.ResponseWriter, req *http.
 	feedback(resp, w)
 }
 
+func (h *HttpApiServer) ctrlStopRtpPubHandler(w http.ResponseWriter, req *http.Request) {
+	var v base.ApiCtrlStopRtpPubResp
+	var info base.ApiCtrlStopRtpPubReq
+
+	_, err := unmarshalRequestJsonBody(req, &info, "stream_name")
+	if err != nil {
+		Log.Warnf("http api stop rtp pub error. err=%+v", err)
+		v.ErrorCode = base.ErrorCodeParamMissing
+		v.Desp = base.DespParamMissing
+		feedback(v, w)
+		return
+	}
+
+	Log.Infof("http api stop rtp pub. req info=%+v", info)
+
+	resp := h.sm.CtrlStopRtpPub(info)
+	feedback(resp, w)
+}
+
+func (h *HttpApiServer) ctrlStartRtpPushHandler(w http.ResponseWriter, req *http.Request) {
+	var v base.ApiCtrlStartRtpPushResp
+	var info base.ApiCtrlStartRtpPushReq
+
+	j, err := unmarshalRequestJsonBody(req, &info, "stream_name")
+	if err != nil {
+		Log.Warnf("http api start rtp push error. err=%+v", err)
+		v.ErrorCode = base.ErrorCodeParamMissing
+		v.Desp = base.DespParamMissing
+		feedback(v, w)
+		return
+	}
+
+	if !j.Exist("mode") {
+		info.Mode = gb28181.MediaModeUdp
+	}
+	if info.Mode != gb28181.MediaModeTcpPassive && info.RemoteAddr == "" {
+		Log.Warnf("http api start rtp push error. remote_addr missing. req info=%+v", info)
+		v.ErrorCode = base.ErrorCodeParamMissing
+		v.Desp = base.DespParamMissing
+		feedback(v, w)
+		return
+	}
+
+	Log.Infof("http api start rtp push. req info=%+v", info)
+
+	resp := h.sm.CtrlStartRtpPush(info)
+	feedback(resp, w)
+}
+
+func (h *HttpApiServer) ctrlGb28181InviteHandler(w http.ResponseWriter, req *http.Request) {
+	var v base.ApiCtrlGb28181InviteResp
+	var info base.ApiCtrlGb28181InviteReq
+
+	j, err := unmarshalRequestJsonBody(req, &info, "device_id", "channel_id")
+	if err != nil {
+		Log.Warnf("http api gb28181 invite error. err=%+v", err)
+		v.ErrorCode = base.ErrorCodeParamMissing
+		v.Desp = base.DespParamMissing
+		feedback(v, w)
+		return
+	}
+
+	if !j.Exist("timeout_ms") {
+		info.TimeoutMs = DefaultApiCtrlStartRtpPubReqTimeoutMs
+	}
+
+	Log.Infof("http api gb28181 invite. req info=%+v", info)
+
+	resp := h.sm.CtrlGb28181Invite(info)
+	feedback(resp, w)
+}
+
+func (h *HttpApiServer) ctrlGb28181PlaybackHandler(w http.ResponseWriter, req *http.Request) {
+	info, ok := h.parseGb28181PlaybackReq(w, req)
+	if !ok {
+		return
+	}
+
+	Log.Infof("http api gb28181 playback. req info=%+v", info)
+
+	resp := h.sm.CtrlGb28181Playback(info)
+	feedback(resp, w)
+}
+
+func (h *HttpApiServer) ctrlGb28181DownloadHandler(w http.ResponseWriter, req *http.Request) {
+	info, ok := h.parseGb28181PlaybackReq(w, req)
+	if !ok {
+		return
+	}
+
+	Log.Infof("http api gb28181 download. req info=%+v", info)
+
+	resp := h.sm.CtrlGb28181Download(info)
+	feedback(resp, w)
+}
+
+// parseGb28181PlaybackReq 参数错误时内部回复http，并返回false
+func (h *HttpApiServer) parseGb28181PlaybackReq(w http.ResponseWriter, req *http.Request) (info base.ApiCtrlGb28181PlaybackReq, ok bool) {
+	var v base.ApiCtrlGb28181InviteResp
+
+	j, err := unmarshalRequestJsonBody(req, &info, "device_id", "channel_id", "start_time", "end_time")
+	if err != nil || info.EndTime <= info.StartTime {
+		Log.Warnf("http api gb28181 playback error. info=%+v, err=%+v", info, err)
+		v.ErrorCode = base.ErrorCodeParamMissing
+		v.Desp = base.DespParamMissing
+		feedback(v, w)
+		return info, false
+	}
+
+	if !j.Exist("timeout_ms") {
+		info.TimeoutMs = DefaultApiCtrlStartRtpPubReqTimeoutMs
+	}
+	return info, true
+}
+
+func (h *HttpApiServer) ctrlGb28181PlaybackScaleHandler(w http.ResponseWriter, req *http.Request) {
+	var v base.ApiCtrlGb28181PlaybackScaleResp
+	var info base.ApiCtrlGb28181PlaybackScaleReq
+
+	_, err := unmarshalRequestJsonBody(req, &info, "stream_name", "scale")
+	if err != nil || info.Scale <= 0 {
+		Log.Warnf("http api gb28181 playback scale error. info=%+v, err=%+v", info, err)
+		v.ErrorCode = base.ErrorCodeParamMissing
+		v.Desp = base.DespParamMissing
+		feedback(v, w)
+		return
+	}
+
+	Log.Infof("http api gb28181 playback scale. req info=%+v", info)
+
+	resp := h.sm.CtrlGb28181PlaybackScale(info)
+	feedback(resp, w)
+}
+
+func (h *HttpApiServer) ctrlGb28181ByeHandler(w http.ResponseWriter, req *http.Request) {
+	var v base.ApiCtrlGb28181ByeResp
+	var info base.ApiCtrlGb28181ByeReq
+
+	_, err := unmarshalRequestJsonBody(req, &info, "stream_name")
+	if err != nil {
+		Log.Warnf("http api gb28181 bye error. err=%+v", err)
+		v.ErrorCode = base.ErrorCodeParamMissing
+		v.Desp = base.DespParamMissing
+		feedback(v, w)
+		return
+	}
+
+	Log.Infof("http api gb28181 bye. req info=%+v", info)
+
+	resp := h.sm.CtrlGb28181Bye(info)
+	feedback(resp, w)
+}
+
+func (h *HttpApiServer) ctrlOnvifDiscoverHandler(w http.ResponseWriter, req *http.Request) {
+	var v base.ApiCtrlOnvifDiscoverResp
+	var info base.ApiCtrlOnvifDiscoverReq
+
+	_, err := unmarshalRequestJsonBody(req, &info)
+	if err != nil {
+		Log.Warnf("http api onvif discover error. err=%+v", err)
+		v.ErrorCode = base.ErrorCodeParamMissing
+		v.Desp = base.DespParamMissing
+		feedback(v, w)
+		return
+	}
+
+	Log.Infof("http api onvif discover. req info=%+v", info)
+
+	resp := h.sm.CtrlOnvifDiscover(info)
+	feedback(resp, w)
+}
+
 func (h *HttpApiServer) ctrlAddIpBlacklistHandler(w http.ResponseWriter, req *http.Request) {
 	var v base.ApiCtrlAddIpBlacklistResp
 	var info base.ApiCtrlAddIpBlacklistReq
@@ -236,6 +445,63 @@ func (h *HttpApiServer) ctrlAddIpBlacklistHandler(w http.ResponseWriter, req *ht
 	feedback(resp, w)
 }
 
+func (h *HttpApiServer) ctrlAddAbrLadderHandler(w http.ResponseWriter, req *http.Request) {
+	var v base.ApiCtrlAddAbrLadderResp
+	var info base.ApiCtrlAddAbrLadderReq
+
+	_, err := unmarshalRequestJsonBody(req, &info, "name", "stream_name_list")
+	if err != nil {
+		Log.Warnf("http api add abr ladder error. err=%+v", err)
+		v.ErrorCode = base.ErrorCodeParamMissing
+		v.Desp = base.DespParamMissing
+		feedback(v, w)
+		return
+	}
+
+	Log.Infof("http api add abr ladder. req info=%+v", info)
+
+	resp := h.sm.CtrlAddAbrLadder(info)
+	feedback(resp, w)
+}
+
+func (h *HttpApiServer) ctrlDelAbrLadderHandler(w http.ResponseWriter, req *http.Request) {
+	var v base.ApiCtrlDelAbrLadderResp
+	var info base.ApiCtrlDelAbrLadderReq
+
+	_, err := unmarshalRequestJsonBody(req, &info, "name")
+	if err != nil {
+		Log.Warnf("http api del abr ladder error. err=%+v", err)
+		v.ErrorCode = base.ErrorCodeParamMissing
+		v.Desp = base.DespParamMissing
+		feedback(v, w)
+		return
+	}
+
+	Log.Infof("http api del abr ladder. req info=%+v", info)
+
+	resp := h.sm.CtrlDelAbrLadder(info)
+	feedback(resp, w)
+}
+
+func (h *HttpApiServer) ctrlPushSubtitleHandler(w http.ResponseWriter, req *http.Request) {
+	var v base.ApiCtrlPushSubtitleResp
+	var info base.ApiCtrlPushSubtitleReq
+
+	_, err := unmarshalRequestJsonBody(req, &info, "stream_name", "language", "start_ms", "end_ms", "text")
+	if err != nil {
+		Log.Warnf("http api push subtitle error. err=%+v", err)
+		v.ErrorCode = base.ErrorCodeParamMissing
+		v.Desp = base.DespParamMissing
+		feedback(v, w)
+		return
+	}
+
+	Log.Debugf("http api push subtitle. req info=%+v", info)
+
+	resp := h.sm.CtrlPushSubtitle(info)
+	feedback(resp, w)
+}
+
 func (h *HttpApiServer) webUIHandler(w http.ResponseWriter, req *http.Request) {
 	t, err := template.New("webUI").Parse(webUITpl)
 	if err != nil {
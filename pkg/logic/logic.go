@@ -10,6 +10,7 @@ package logic
 
 import (
 	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/lal/pkg/rtmp"
 )
 
 // ---------------------------------------------------------------------------------------------------------------------
@@ -137,6 +138,12 @@ type Option struct {
 	//
 	// 这是一个同步接口，回调函数处于内部的处理逻辑与锁中
 	Authentication IAuthentication
+
+	// RtmpAuthCredentialStore
+	//
+	// 配置文件中开启rtmp鉴权（rtmp.auth_enable）时，用户密码的来源。
+	// 如果为nil，则使用配置文件中的rtmp.username和rtmp.password
+	RtmpAuthCredentialStore rtmp.IAuthCredentialStore
 }
 
 var defaultOption = Option{
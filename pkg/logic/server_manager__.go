@@ -21,12 +21,14 @@ import (
 	"github.com/q191201771/naza/pkg/taskpool"
 
 	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/lal/pkg/gb28181"
 	"github.com/q191201771/lal/pkg/hls"
 	"github.com/q191201771/lal/pkg/httpflv"
 	"github.com/q191201771/lal/pkg/httpts"
 	"github.com/q191201771/lal/pkg/rtmp"
 	"github.com/q191201771/lal/pkg/rtsp"
 	"github.com/q191201771/naza/pkg/defertaskthread"
+	"github.com/q191201771/naza/pkg/nazaerrors"
 	//"github.com/felixge/fgprof"
 )
 
@@ -39,14 +41,16 @@ type ServerManager struct {
 	httpServerHandler *HttpServerHandler
 	hlsServerHandler  *hls.ServerHandler
 
-	rtmpServer    *rtmp.Server
-	rtmpsServer   *rtmp.Server
-	rtspServer    *rtsp.Server
-	rtspsServer   *rtsp.Server
-	httpApiServer *HttpApiServer
-	pprofServer   *http.Server
-	wsrtspServer  *rtsp.WebsocketServer
-	exitChan      chan struct{}
+	rtmpServer       *rtmp.Server
+	rtmpsServer      *rtmp.Server
+	rtspServer       *rtsp.Server
+	rtspsServer      *rtsp.Server
+	httpApiServer    *HttpApiServer
+	pprofServer      *http.Server
+	wsrtspServer     *rtsp.WebsocketServer
+	httpTunnelServer *rtsp.HttpTunnelServer
+	sipServer        *gb28181.SipServer
+	exitChan         chan struct{}
 
 	mutex        sync.Mutex
 	groupManager IGroupManager
@@ -56,12 +60,24 @@ type ServerManager struct {
 	notifyHandlerThread taskpool.Pool
 
 	ipBlacklist IpBlacklist
+
+	abrLadders map[string]base.ApiCtrlAddAbrLadderReq // ladder name -> ladder
+
+	gb28181Dialogs map[string]gb28181Dialog // stream name -> dialog
+}
+
+// gb28181Dialog 通过 gb28181.SipServer 邀请的一路流
+type gb28181Dialog struct {
+	callId    string
+	sessionId string // PubSession
 }
 
 func NewServerManager(modOption ...ModOption) *ServerManager {
 	sm := &ServerManager{
 		serverStartTime: base.ReadableNowTime(),
 		exitChan:        make(chan struct{}, 1),
+		abrLadders:      make(map[string]base.ApiCtrlAddAbrLadderReq),
+		gb28181Dialogs:  make(map[string]gb28181Dialog),
 	}
 	sm.groupManager = NewSimpleGroupManager(sm)
 
@@ -89,6 +105,13 @@ Doc: %s
 		Log.Infof("hls use memory as disk.")
 		hls.SetUseMemoryAsDiskFlag(true)
 	}
+	if sm.config.HlsConfig.Enable && sm.config.HlsConfig.S3.Enable {
+		hls.UseS3Storage(sm.config.HlsConfig.S3, sm.config.HlsConfig.OutPath)
+	}
+
+	if sm.config.RtspConfig.AvSyncWaitSrMaxMs > 0 {
+		rtsp.AvSyncWaitSrMaxMs = sm.config.RtspConfig.AvSyncWaitSrMaxMs
+	}
 
 	if sm.config.RecordConfig.EnableFlv {
 		if err := os.MkdirAll(sm.config.RecordConfig.FlvOutPath, 0777); err != nil {
@@ -110,22 +133,94 @@ Doc: %s
 		sm.httpServerManager = base.NewHttpServerManager()
 		sm.httpServerHandler = NewHttpServerHandler(sm)
 		sm.hlsServerHandler = hls.NewServerHandler(sm.config.HlsConfig.OutPath, sm.config.HlsConfig.UrlPattern, sm.config.HlsConfig.SubSessionHashKey, sm.config.HlsConfig.SubSessionTimeoutMs, sm)
+		sm.hlsServerHandler.SetMasterPlaylistProvider(sm)
+
+		// 业务方可能在外部设置了自己的实现
+		//
+		// 开启加密时密钥来源必须可用，否则直接退出，避免输出未加密的切片
+		if hls.KeyProvider == nil {
+			p, err := newHlsKeyProvider(&sm.config.HlsConfig)
+			if err != nil {
+				if sm.config.HlsConfig.EncryptFlag {
+					Log.Errorf("create hls key provider failed. err=%+v", err)
+					base.OsExitAndWaitPressIfWindows(1)
+				}
+				Log.Warnf("create hls key provider failed, encrypt disabled so ignore it. err=%+v", err)
+			}
+			hls.KeyProvider = p
+		}
 	}
 
+	for _, ladder := range sm.config.HlsConfig.AbrLadders {
+		err := sm.addAbrLadder(base.ApiCtrlAddAbrLadderReq{
+			Name:           ladder.Name,
+			StreamNameList: ladder.StreamNameList,
+			AudioList:      ladder.AudioList,
+			SubtitleList:   ladder.SubtitleList,
+		})
+		if err != nil {
+			Log.Errorf("invalid abr ladder config, ignore it. name=%s, err=%+v", ladder.Name, err)
+		}
+	}
+
+	var rtmpAuth *rtmp.ServerAuth
+	if sm.config.RtmpConfig.AuthEnable {
+		store := sm.option.RtmpAuthCredentialStore
+		if store == nil {
+			store = rtmp.SingleUserCredentialStore{User: sm.config.RtmpConfig.UserName, Password: sm.config.RtmpConfig.PassWord}
+		}
+		rtmpAuth = rtmp.NewServerAuth(sm.config.RtmpConfig.AuthMod, store)
+	}
 	if sm.config.RtmpConfig.Enable {
 		sm.rtmpServer = rtmp.NewServer(sm.config.RtmpConfig.Addr, sm)
+		sm.rtmpServer.SetAuth(rtmpAuth)
+		sm.rtmpServer.SetChunkSize(sm.config.RtmpConfig.ChunkSize)
+		sm.rtmpServer.SetCompressChunkHeader(sm.config.RtmpConfig.CompressChunkHeader)
 	}
 	if sm.config.RtmpConfig.RtmpsEnable {
 		sm.rtmpsServer = rtmp.NewServer(sm.config.RtmpConfig.RtmpsAddr, sm)
+		sm.rtmpsServer.SetAuth(rtmpAuth)
+		sm.rtmpsServer.SetChunkSize(sm.config.RtmpConfig.ChunkSize)
+		sm.rtmpsServer.SetCompressChunkHeader(sm.config.RtmpConfig.CompressChunkHeader)
 	}
 	if sm.config.RtspConfig.Enable {
-		sm.rtspServer = rtsp.NewServer(sm.config.RtspConfig.Addr, sm, sm.config.RtspConfig.ServerAuthConfig)
+		sm.rtspServer = rtsp.NewServer(sm.config.RtspConfig.Addr, sm, sm.config.RtspConfig.ServerAuthConfig, sm.config.RtspConfig.ServerTransportConfig)
 	}
 	if sm.config.RtspConfig.RtspsEnable {
-		sm.rtspsServer = rtsp.NewServer(sm.config.RtspConfig.RtspsAddr, sm, sm.config.RtspConfig.ServerAuthConfig)
+		sm.rtspsServer = rtsp.NewServer(sm.config.RtspConfig.RtspsAddr, sm, sm.config.RtspConfig.ServerAuthConfig, sm.config.RtspConfig.ServerTransportConfig)
 	}
 	if sm.config.RtspConfig.WsRtspEnable {
-		sm.wsrtspServer = rtsp.NewWebsocketServer(sm.config.RtspConfig.WsRtspAddr, sm, sm.config.RtspConfig.ServerAuthConfig)
+		sm.wsrtspServer = rtsp.NewWebsocketServer(sm.config.RtspConfig.WsRtspAddr, sm, sm.config.RtspConfig.ServerAuthConfig, sm.config.RtspConfig.ServerTransportConfig)
+	}
+	if sm.config.RtspConfig.HttpTunnelEnable {
+		sm.httpTunnelServer = rtsp.NewHttpTunnelServer(sm.config.RtspConfig.HttpTunnelAddr, sm, sm.config.RtspConfig.ServerAuthConfig, sm.config.RtspConfig.ServerTransportConfig)
+	}
+	// 各rtsp server使用相同的传输层配置，共用UDP端口池
+	primary := sm.rtspServer
+	if primary == nil {
+		primary = sm.rtspsServer
+	}
+	if primary != nil {
+		if sm.rtspsServer != nil && sm.rtspsServer != primary {
+			sm.rtspsServer.ShareTransport(primary)
+		}
+		if sm.wsrtspServer != nil {
+			sm.wsrtspServer.ShareTransport(primary)
+		}
+		if sm.httpTunnelServer != nil {
+			sm.httpTunnelServer.ShareTransport(primary)
+		}
+	}
+	if sm.config.Gb28181Config.Enable {
+		sm.sipServer = gb28181.NewSipServer(gb28181.SipServerConfig{
+			Addr:                 sm.config.Gb28181Config.SipAddr,
+			ServerId:             sm.config.Gb28181Config.SipId,
+			Realm:                sm.config.Gb28181Config.Realm,
+			Password:             sm.config.Gb28181Config.Password,
+			LocalIp:              sm.config.Gb28181Config.LocalIp,
+			KeepaliveTimeoutMs:   sm.config.Gb28181Config.KeepaliveTimeoutMs,
+			TransactionTimeoutMs: sm.config.Gb28181Config.TransactionTimeoutMs,
+		}).WithOnBye(sm.onGb28181Bye)
 	}
 	if sm.config.HttpApiConfig.Enable {
 		sm.httpApiServer = NewHttpApiServer(sm.config.HttpApiConfig.Addr, sm)
@@ -265,6 +360,28 @@ func (sm *ServerManager) RunLoop() error {
 		}()
 	}
 
+	if sm.httpTunnelServer != nil {
+		if err := sm.httpTunnelServer.Listen(); err != nil {
+			return err
+		}
+		go func() {
+			if err := sm.httpTunnelServer.RunLoop(); err != nil {
+				Log.Error(err)
+			}
+		}()
+	}
+
+	if sm.sipServer != nil {
+		if err := sm.sipServer.Listen(); err != nil {
+			return err
+		}
+		go func() {
+			if err := sm.sipServer.RunLoop(); err != nil {
+				Log.Error(err)
+			}
+		}()
+	}
+
 	if sm.httpApiServer != nil {
 		if err := sm.httpApiServer.Listen(); err != nil {
 			return err
@@ -310,6 +427,9 @@ func (sm *ServerManager) RunLoop() error {
 				tickCount%uint32(sm.config.DebugConfig.LogGroupIntervalSec) == 0 {
 				groupNum := sm.groupManager.Len()
 				Log.Debugf("DEBUG_GROUP_LOG: group size=%d", groupNum)
+				if stat, ok := hls.GetS3Stat(); ok {
+					Log.Debugf("DEBUG_GROUP_LOG: s3 stat=%+v", stat)
+				}
 				if sm.config.DebugConfig.LogGroupMaxGroupNum > 0 {
 					var loggedGroupCount int
 					sm.groupManager.Iterate(func(group *Group) bool {
@@ -354,6 +474,14 @@ func (sm *ServerManager) Dispose() {
 		sm.rtspsServer.Dispose()
 	}
 
+	if sm.httpTunnelServer != nil {
+		sm.httpTunnelServer.Dispose()
+	}
+
+	if sm.sipServer != nil {
+		sm.sipServer.Dispose()
+	}
+
 	if sm.httpServerManager != nil {
 		sm.httpServerManager.Dispose()
 	}
@@ -373,11 +501,33 @@ func (sm *ServerManager) Dispose() {
 	})
 	sm.mutex.Unlock()
 
+	hls.DisposeS3Storage()
+
 	sm.exitChan <- struct{}{}
 }
 
 // ---------------------------------------------------------------------------------------------------------------------
 
+// onGb28181Bye 设备主动结束推流，或者历史视频回放、下载结束
+func (sm *ServerManager) onGb28181Bye(callId string) {
+	sm.mutex.Lock()
+	var streamName, sessionId string
+	for k, v := range sm.gb28181Dialogs {
+		if v.callId == callId {
+			streamName, sessionId = k, v.sessionId
+			delete(sm.gb28181Dialogs, k)
+			break
+		}
+	}
+	sm.mutex.Unlock()
+
+	if streamName != "" {
+		sm.CtrlKickSession(base.ApiCtrlKickSessionReq{StreamName: streamName, SessionId: sessionId})
+	}
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
 func (sm *ServerManager) AddCustomizePubSession(streamName string) (ICustomizePubSessionContext, error) {
 	sm.mutex.Lock()
 	defer sm.mutex.Unlock()
@@ -648,6 +798,17 @@ func (sm *ServerManager) OnNewRtspSubSessionPlay(session *rtsp.SubSession) error
 	return nil
 }
 
+func (sm *ServerManager) OnRtspSubSessionPlayControl(session *rtsp.SubSession) error {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	group := sm.getGroup(session.AppName(), session.StreamName())
+	if group == nil {
+		return base.ErrGroupNotFound
+	}
+	return group.HandleRtspSubSessionPlayControl(session)
+}
+
 func (sm *ServerManager) OnDelRtspSubSession(session *rtsp.SubSession) {
 	sm.mutex.Lock()
 	defer sm.mutex.Unlock()
@@ -702,6 +863,68 @@ func (sm *ServerManager) OnDelHlsSubSession(session *hls.SubSession) {
 	sm.nhOnSubStop(info)
 }
 
+// ----- implement hls.IMasterPlaylistProvider interface ---------------------------------------------------------------
+
+func (sm *ServerManager) GetMasterPlaylist(ladderName string) (content []byte, exist bool) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	ladder, exist := sm.abrLadders[ladderName]
+	if !exist {
+		return nil, false
+	}
+
+	// 只写入当前可以播放的流
+	var variants []hls.AbrVariant
+	for _, streamName := range ladder.StreamNameList {
+		group := sm.getGroup("", streamName)
+		if group == nil {
+			continue
+		}
+		if variant, ok := group.GetAbrVariant(); ok {
+			variants = append(variants, variant)
+		}
+	}
+
+	var renditions []hls.AbrRendition
+	for _, item := range ladder.AudioList {
+		r := hls.AbrRendition{
+			Type:     hls.AbrRenditionTypeAudio,
+			Name:     item.Name,
+			Language: item.Language,
+			Default:  item.Default,
+		}
+		if item.StreamName != "" {
+			group := sm.getGroup("", item.StreamName)
+			if group == nil || !group.IsHlsMuxerAlive() {
+				continue
+			}
+			r.Uri = hls.MakeRenditionUri(item.StreamName)
+		}
+		renditions = append(renditions, r)
+	}
+	// 注意，这里只读取，不创建字幕输出，字幕输出在第一次推送字幕时创建
+	for _, item := range ladder.SubtitleList {
+		group := sm.getGroup("", item.StreamName)
+		if group == nil || !group.HasHlsSubtitle(item.Language) {
+			continue
+		}
+		streamName, err := hls.SubtitleStreamName(item.StreamName, item.Language)
+		if err != nil {
+			continue
+		}
+		renditions = append(renditions, hls.AbrRendition{
+			Type:     hls.AbrRenditionTypeSubtitles,
+			Name:     item.Name,
+			Language: item.Language,
+			Default:  item.Default,
+			Uri:      hls.MakeRenditionUri(streamName),
+		})
+	}
+
+	return hls.PackMasterPlaylist(variants, renditions), true
+}
+
 // ----- implement IGroupCreator interface -----------------------------------------------------------------------------
 
 func (sm *ServerManager) CreateGroup(appName string, streamName string) *Group {
@@ -789,6 +1012,56 @@ func (sm *ServerManager) getGroup(appName string, streamName string) *Group {
 	return sm.groupManager.GetGroup(appName, streamName)
 }
 
+// 注意，函数内部不加锁，由调用方保证加锁进入
+func (sm *ServerManager) addAbrLadder(ladder base.ApiCtrlAddAbrLadderReq) error {
+	for _, item := range ladder.AudioList {
+		if err := hls.CheckRenditionName(item.Name); err != nil {
+			return err
+		}
+		if item.Language != "" {
+			if err := hls.CheckRenditionLanguage(item.Language); err != nil {
+				return err
+			}
+		}
+	}
+	for _, item := range ladder.SubtitleList {
+		if err := hls.CheckRenditionName(item.Name); err != nil {
+			return err
+		}
+		if err := hls.CheckRenditionLanguage(item.Language); err != nil {
+			return err
+		}
+	}
+
+	if !sm.config.HlsConfig.AlignFragmentFlag {
+		Log.Warnf("hls.align_fragment_flag is false, fragment boundaries of abr ladder may not be aligned. ladder=%s", ladder.Name)
+	}
+	Log.Infof("add abr ladder. ladder=%+v", ladder)
+	sm.abrLadders[ladder.Name] = ladder
+	return nil
+}
+
+// newHlsKeyProvider 根据配置创建密钥来源，类型非法或者密钥文件读取失败时返回错误
+func newHlsKeyProvider(config *HlsConfig) (hls.IKeyProvider, error) {
+	switch config.KeyProviderType {
+	case HlsKeyProviderTypeFile:
+		p, err := hls.NewStaticFileKeyProvider(config.KeyFile)
+		if err != nil {
+			return nil, nazaerrors.Wrap(err, fmt.Sprintf("file=%s", config.KeyFile))
+		}
+		return p, nil
+	case HlsKeyProviderTypeHttp:
+		timeoutMs := config.KeyServerTimeoutMs
+		if timeoutMs == 0 {
+			timeoutMs = defaultHlsKeyServerTimeoutMs
+		}
+		return hls.NewHttpKeyProvider(config.KeyServerUrl, timeoutMs), nil
+	case "", HlsKeyProviderTypeMemory:
+		return hls.NewMemoryKeyProvider(), nil
+	}
+	return nil, nazaerrors.Wrap(base.ErrHlsKey, fmt.Sprintf("invalid key provider type. type=%s", config.KeyProviderType))
+}
+
 func (sm *ServerManager) serveHls(writer http.ResponseWriter, req *http.Request) {
 	urlCtx, err := base.ParseUrl(base.ParseHttpRequest(req), 80)
 	if err != nil {
@@ -796,7 +1069,8 @@ func (sm *ServerManager) serveHls(writer http.ResponseWriter, req *http.Request)
 		return
 	}
 
-	if urlCtx.GetFileType() == "m3u8" {
+	// 注意，加密时密钥请求和m3u8请求使用相同的鉴权
+	if urlCtx.GetFileType() == "m3u8" || urlCtx.GetFileType() == "key" {
 		// TODO(chef): [refactor] 需要整理，这里使用 hls.PathStrategy 不太好 202207
 		streamName := hls.PathStrategy.GetRequestInfo(urlCtx, sm.config.HlsConfig.OutPath).StreamName
 		if err = sm.option.Authentication.OnHls(streamName, urlCtx.RawQuery); err != nil {
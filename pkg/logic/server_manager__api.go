@@ -9,9 +9,18 @@
 package logic
 
 import (
+	"fmt"
 	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/lal/pkg/gb28181"
+	"github.com/q191201771/lal/pkg/hls"
+	"github.com/q191201771/lal/pkg/onvif"
 	"github.com/q191201771/naza/pkg/bininfo"
 	"math"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 )
 
 // server_manager__api.go
@@ -156,3 +165,493 @@ func (sm *ServerManager) CtrlStartRtpPub(info base.ApiCtrlStartRtpPubReq) (ret b
 
 	return
 }
+
+// CtrlStopRtpPub 结束rtp pub session，如果该session是通过 CtrlGb28181Invite 创建的，同时向设备发送BYE
+func (sm *ServerManager) CtrlStopRtpPub(info base.ApiCtrlStopRtpPubReq) (ret base.ApiCtrlStopRtpPubResp) {
+	sm.mutex.Lock()
+	g := sm.getGroup("", info.StreamName)
+	if g == nil {
+		sm.mutex.Unlock()
+		ret.ErrorCode = base.ErrorCodeGroupNotFound
+		ret.Desp = base.DespGroupNotFound
+		return
+	}
+	sessionId, ok := g.StopRtpPub(info.SessionId)
+	dialog, exist := sm.gb28181Dialogs[info.StreamName]
+	if ok && exist && dialog.sessionId == sessionId {
+		delete(sm.gb28181Dialogs, info.StreamName)
+	} else {
+		exist = false
+	}
+	sm.mutex.Unlock()
+
+	if !ok {
+		ret.ErrorCode = base.ErrorCodeSessionNotFound
+		ret.Desp = base.DespSessionNotFound
+		return
+	}
+
+	// SIP请求是阻塞的，不持有锁
+	if exist && sm.sipServer != nil {
+		if err := sm.sipServer.Bye(dialog.callId); err != nil {
+			Log.Warnf("gb28181 bye failed. stream=%s, err=%+v", info.StreamName, err)
+		}
+	}
+
+	ret.ErrorCode = base.ErrorCodeSucc
+	ret.Desp = base.DespSucc
+	ret.Data.SessionId = sessionId
+	return
+}
+
+func (sm *ServerManager) StatAllRtpPub() (pubs []base.StatRtpPub) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	sm.groupManager.Iterate(func(group *Group) bool {
+		if stat, ok := group.GetRtpPubStat(); ok {
+			pubs = append(pubs, stat)
+		}
+		return true
+	})
+	return
+}
+
+// CtrlStartRtpPush 将group中的流打包成ps over rtp，发送给上级平台
+//
+// 注意，tcp主动模式下建立连接是阻塞的，所以建立连接时不持有锁
+func (sm *ServerManager) CtrlStartRtpPush(info base.ApiCtrlStartRtpPushReq) (ret base.ApiCtrlStartRtpPushResp) {
+	sm.mutex.Lock()
+	g := sm.getGroup("", info.StreamName)
+	sm.mutex.Unlock()
+	if g == nil {
+		ret.ErrorCode = base.ErrorCodeGroupNotFound
+		ret.Desp = base.DespGroupNotFound
+		return
+	}
+
+	session := gb28181.NewPushSession().WithStreamName(info.StreamName).WithSsrc(info.Ssrc)
+	port, err := session.Start(info.Mode, info.RemoteAddr, info.LocalPort)
+	if err != nil {
+		Log.Errorf("start rtp push failed. req=%+v, err=%+v", info, err)
+		ret.ErrorCode = base.ErrorCodeStartRtpPushFail
+		ret.Desp = err.Error()
+		return
+	}
+
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	if g = sm.getGroup("", info.StreamName); g == nil {
+		_ = session.Dispose()
+		ret.ErrorCode = base.ErrorCodeGroupNotFound
+		ret.Desp = base.DespGroupNotFound
+		return
+	}
+	g.AddPsPushSession(session)
+
+	ret.ErrorCode = base.ErrorCodeSucc
+	ret.Desp = base.DespSucc
+	ret.Data.StreamName = info.StreamName
+	ret.Data.SessionId = session.UniqueKey()
+	ret.Data.LocalPort = port
+	return
+}
+
+// CtrlGb28181Invite 先启动 gb28181.PubSession 监听端口，再通过SIP邀请设备向该端口推流
+//
+// # TCP主动模式时顺序相反，先通过SIP邀请设备，再由 gb28181.PubSession 连接设备在SDP应答中给出的媒体地址
+//
+// 注意，SIP请求是阻塞的，所以这里不持有锁
+func (sm *ServerManager) CtrlGb28181Invite(info base.ApiCtrlGb28181InviteReq) (ret base.ApiCtrlGb28181InviteResp) {
+	if sm.sipServer == nil {
+		ret.ErrorCode = base.ErrorCodeGb28181NotEnable
+		ret.Desp = base.DespGb28181NotEnable
+		return
+	}
+	if info.StreamName == "" {
+		info.StreamName = info.ChannelId
+	}
+
+	// 同一个流重复邀请时，先结束之前的
+	sm.mutex.Lock()
+	_, exist := sm.gb28181Dialogs[info.StreamName]
+	sm.mutex.Unlock()
+	if exist {
+		sm.CtrlGb28181Bye(base.ApiCtrlGb28181ByeReq{StreamName: info.StreamName})
+	}
+
+	return sm.gb28181Invite(info, gb28181.InviteParam{})
+}
+
+// CtrlGb28181Playback 历史视频回放，流程同 CtrlGb28181Invite
+//
+// 回放结束时（设备发送MediaStatus 121），结束dialog以及PubSession，临时的group随之被回收
+func (sm *ServerManager) CtrlGb28181Playback(info base.ApiCtrlGb28181PlaybackReq) (ret base.ApiCtrlGb28181InviteResp) {
+	return sm.gb28181History(info, gb28181.InviteSessionNamePlayback)
+}
+
+// CtrlGb28181Download 历史视频下载，同 CtrlGb28181Playback
+func (sm *ServerManager) CtrlGb28181Download(info base.ApiCtrlGb28181PlaybackReq) (ret base.ApiCtrlGb28181InviteResp) {
+	return sm.gb28181History(info, gb28181.InviteSessionNameDownload)
+}
+
+// CtrlGb28181PlaybackScale 控制历史视频回放的倍速
+//
+// 注意，SIP请求是阻塞的，所以这里不持有锁
+func (sm *ServerManager) CtrlGb28181PlaybackScale(info base.ApiCtrlGb28181PlaybackScaleReq) (ret base.ApiCtrlGb28181PlaybackScaleResp) {
+	if sm.sipServer == nil {
+		ret.ErrorCode = base.ErrorCodeGb28181NotEnable
+		ret.Desp = base.DespGb28181NotEnable
+		return
+	}
+
+	sm.mutex.Lock()
+	dialog, exist := sm.gb28181Dialogs[info.StreamName]
+	sm.mutex.Unlock()
+	if !exist {
+		ret.ErrorCode = base.ErrorCodeSessionNotFound
+		ret.Desp = base.DespSessionNotFound
+		return
+	}
+
+	if err := sm.sipServer.SetScale(dialog.callId, info.Scale); err != nil {
+		Log.Errorf("gb28181 set scale failed. req=%+v, err=%+v", info, err)
+		ret.ErrorCode = base.ErrorCodeGb28181ScaleFail
+		ret.Desp = err.Error()
+		return
+	}
+
+	ret.ErrorCode = base.ErrorCodeSucc
+	ret.Desp = base.DespSucc
+	return
+}
+
+func (sm *ServerManager) gb28181History(info base.ApiCtrlGb28181PlaybackReq, sessionName string) (ret base.ApiCtrlGb28181InviteResp) {
+	if sm.sipServer == nil {
+		ret.ErrorCode = base.ErrorCodeGb28181NotEnable
+		ret.Desp = base.DespGb28181NotEnable
+		return
+	}
+	if info.StreamName == "" {
+		// 流名称中包含设备、通道以及时间范围，保证不同的回放请求使用不同的group
+		info.StreamName = fmt.Sprintf("%s_%s_%s_%d_%d",
+			info.DeviceId, info.ChannelId, strings.ToLower(sessionName), info.StartTime, info.EndTime)
+	}
+
+	sm.mutex.Lock()
+	_, exist := sm.gb28181Dialogs[info.StreamName]
+	sm.mutex.Unlock()
+	if exist {
+		sm.CtrlGb28181Bye(base.ApiCtrlGb28181ByeReq{StreamName: info.StreamName})
+	}
+
+	return sm.gb28181Invite(base.ApiCtrlGb28181InviteReq{
+		DeviceId:        info.DeviceId,
+		ChannelId:       info.ChannelId,
+		StreamName:      info.StreamName,
+		IsTcpFlag:       info.IsTcpFlag,
+		TimeoutMs:       info.TimeoutMs,
+		IsTcpActiveFlag: info.IsTcpActiveFlag,
+	}, gb28181.InviteParam{
+		SessionName:   sessionName,
+		StartTime:     info.StartTime,
+		EndTime:       info.EndTime,
+		DownloadSpeed: info.DownloadSpeed,
+	})
+}
+
+// gb28181Invite
+//
+// @param param: 历史视频相关的字段由调用方填写，其他字段内部填写
+func (sm *ServerManager) gb28181Invite(info base.ApiCtrlGb28181InviteReq, param gb28181.InviteParam) (ret base.ApiCtrlGb28181InviteResp) {
+	param.DeviceId = info.DeviceId
+	param.ChannelId = info.ChannelId
+
+	if info.IsTcpActiveFlag != 0 {
+		return sm.gb28181InviteTcpActive(info, param)
+	}
+
+	rtpResp := sm.CtrlStartRtpPub(base.ApiCtrlStartRtpPubReq{
+		StreamName: info.StreamName,
+		TimeoutMs:  info.TimeoutMs,
+		IsTcpFlag:  info.IsTcpFlag,
+	})
+	if rtpResp.ErrorCode != base.ErrorCodeSucc {
+		ret.ApiRespBasic = rtpResp.ApiRespBasic
+		return
+	}
+
+	param.MediaPort = rtpResp.Data.Port
+	param.IsTcpFlag = info.IsTcpFlag != 0
+	inviteResult, err := sm.sipServer.Invite(param)
+	if err != nil {
+		Log.Errorf("gb28181 invite failed. req=%+v, err=%+v", info, err)
+		sm.CtrlKickSession(base.ApiCtrlKickSessionReq{StreamName: info.StreamName, SessionId: rtpResp.Data.SessionId})
+		ret.ErrorCode = base.ErrorCodeGb28181InviteFail
+		ret.Desp = err.Error()
+		return
+	}
+	callId := inviteResult.CallId
+
+	sm.mutex.Lock()
+	sm.gb28181Dialogs[info.StreamName] = gb28181Dialog{
+		callId:    callId,
+		sessionId: rtpResp.Data.SessionId,
+	}
+	sm.mutex.Unlock()
+
+	ret.ErrorCode = base.ErrorCodeSucc
+	ret.Desp = base.DespSucc
+	ret.Data.StreamName = info.StreamName
+	ret.Data.SessionId = rtpResp.Data.SessionId
+	ret.Data.Port = rtpResp.Data.Port
+	ret.Data.CallId = callId
+	return
+}
+
+func (sm *ServerManager) gb28181InviteTcpActive(info base.ApiCtrlGb28181InviteReq, param gb28181.InviteParam) (ret base.ApiCtrlGb28181InviteResp) {
+	param.MediaPort = 9 // 主动模式下本端不监听，按RFC4145填写discard端口
+	param.IsTcpFlag = true
+	param.IsTcpActiveFlag = true
+	inviteResult, err := sm.sipServer.Invite(param)
+	if err != nil {
+		Log.Errorf("gb28181 invite failed. req=%+v, err=%+v", info, err)
+		if inviteResult.CallId != "" {
+			_ = sm.sipServer.Bye(inviteResult.CallId)
+		}
+		ret.ErrorCode = base.ErrorCodeGb28181InviteFail
+		ret.Desp = err.Error()
+		return
+	}
+
+	rtpResp := sm.CtrlStartRtpPub(base.ApiCtrlStartRtpPubReq{
+		StreamName: info.StreamName,
+		TimeoutMs:  info.TimeoutMs,
+		RemoteAddr: net.JoinHostPort(inviteResult.MediaIp, strconv.Itoa(inviteResult.MediaPort)),
+	})
+	if rtpResp.ErrorCode != base.ErrorCodeSucc {
+		_ = sm.sipServer.Bye(inviteResult.CallId)
+		ret.ApiRespBasic = rtpResp.ApiRespBasic
+		return
+	}
+
+	sm.mutex.Lock()
+	sm.gb28181Dialogs[info.StreamName] = gb28181Dialog{
+		callId:    inviteResult.CallId,
+		sessionId: rtpResp.Data.SessionId,
+	}
+	sm.mutex.Unlock()
+
+	ret.ErrorCode = base.ErrorCodeSucc
+	ret.Desp = base.DespSucc
+	ret.Data.StreamName = info.StreamName
+	ret.Data.SessionId = rtpResp.Data.SessionId
+	ret.Data.CallId = inviteResult.CallId
+	return
+}
+
+func (sm *ServerManager) CtrlGb28181Bye(info base.ApiCtrlGb28181ByeReq) (ret base.ApiCtrlGb28181ByeResp) {
+	if sm.sipServer == nil {
+		ret.ErrorCode = base.ErrorCodeGb28181NotEnable
+		ret.Desp = base.DespGb28181NotEnable
+		return
+	}
+
+	sm.mutex.Lock()
+	dialog, exist := sm.gb28181Dialogs[info.StreamName]
+	delete(sm.gb28181Dialogs, info.StreamName)
+	sm.mutex.Unlock()
+	if !exist {
+		ret.ErrorCode = base.ErrorCodeSessionNotFound
+		ret.Desp = base.DespSessionNotFound
+		return
+	}
+
+	// 即使BYE失败（比如设备已经离线），也结束本地的PubSession
+	if err := sm.sipServer.Bye(dialog.callId); err != nil {
+		Log.Warnf("gb28181 bye failed. stream=%s, err=%+v", info.StreamName, err)
+	}
+	sm.CtrlKickSession(base.ApiCtrlKickSessionReq{StreamName: info.StreamName, SessionId: dialog.sessionId})
+
+	ret.ErrorCode = base.ErrorCodeSucc
+	ret.Desp = base.DespSucc
+	return
+}
+
+// StatGb28181Devices
+//
+// @return enable: 没有开启gb28181时返回false
+func (sm *ServerManager) StatGb28181Devices() (devices []base.StatGb28181Device, enable bool) {
+	if sm.sipServer == nil {
+		return nil, false
+	}
+	return sm.sipServer.GetDevices(), true
+}
+
+// CtrlOnvifDiscover 阻塞函数，搜索设备以及查询profile期间不持有锁
+func (sm *ServerManager) CtrlOnvifDiscover(info base.ApiCtrlOnvifDiscoverReq) (ret base.ApiCtrlOnvifDiscoverResp) {
+	matches, err := onvif.Discover(func(option *onvif.DiscoverOption) {
+		if info.TimeoutMs > 0 {
+			option.TimeoutMs = info.TimeoutMs
+		}
+	})
+	if err != nil {
+		ret.ErrorCode = base.ErrorCodeOnvifDiscoverFail
+		ret.Desp = err.Error()
+		return
+	}
+
+	devices := make([]base.StatOnvifDevice, len(matches))
+	var wg sync.WaitGroup
+	for i := range matches {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			devices[i] = sm.onvifQueryDevice(matches[i], info)
+		}(i)
+	}
+	wg.Wait()
+
+	ret.ErrorCode = base.ErrorCodeSucc
+	ret.Desp = base.DespSucc
+	ret.Data.Devices = devices
+	return
+}
+
+func (sm *ServerManager) onvifQueryDevice(match onvif.ProbeMatch, info base.ApiCtrlOnvifDiscoverReq) (device base.StatOnvifDevice) {
+	device.EndpointAddress = match.EndpointAddress
+	device.XAddr = match.XAddrs[0]
+	device.Name = match.Name()
+	device.Hardware = match.Hardware()
+
+	client := onvif.NewClient(device.XAddr, info.Username, info.Password, 0)
+	profiles, err := client.GetProfiles()
+	if err != nil {
+		Log.Warnf("onvif get profiles failed. xaddr=%s, err=%+v", device.XAddr, err)
+		device.Error = err.Error()
+		return
+	}
+
+	for _, p := range profiles {
+		sp := base.StatOnvifProfile{
+			Token:    p.Token,
+			Name:     p.Name,
+			Encoding: p.Encoding,
+			Width:    p.Width,
+			Height:   p.Height,
+		}
+		if sp.StreamUri, err = client.GetStreamUri(p.Token); err != nil {
+			Log.Warnf("onvif get stream uri failed. xaddr=%s, token=%s, err=%+v", device.XAddr, p.Token, err)
+		}
+		device.Profiles = append(device.Profiles, sp)
+	}
+
+	// 只对第一个profile（一般是主码流）拉流
+	if info.AutoPullFlag && len(device.Profiles) > 0 && device.Profiles[0].StreamUri != "" {
+		sp := &device.Profiles[0]
+		pullInfo := base.ApiCtrlStartRelayPullReq{
+			Url:                      onvifPullUrl(sp.StreamUri, info.Username, info.Password),
+			StreamName:               onvifStreamName(info.StreamNamePrefix, device.XAddr, sp.Token),
+			PullTimeoutMs:            DefaultApiCtrlStartRelayPullReqPullTimeoutMs,
+			PullRetryNum:             base.PullRetryNumNever,
+			AutoStopPullAfterNoOutMs: base.AutoStopPullAfterNoOutMsNever,
+			RtspMode:                 base.RtspModeTcp,
+		}
+		resp := sm.CtrlStartRelayPull(pullInfo)
+		if resp.ErrorCode != base.ErrorCodeSucc {
+			Log.Warnf("onvif auto pull failed. url=%s, desp=%s", sp.StreamUri, resp.Desp)
+		} else {
+			sp.StreamName = resp.Data.StreamName
+			sp.SessionId = resp.Data.SessionId
+		}
+	}
+	return
+}
+
+// onvifPullUrl 设备返回的rtsp地址一般不带用户名密码，拉流时将ONVIF的用户名密码带上
+func onvifPullUrl(streamUri, username, password string) string {
+	if username == "" {
+		return streamUri
+	}
+	u, err := url.Parse(streamUri)
+	if err != nil || u.User != nil {
+		return streamUri
+	}
+	u.User = url.UserPassword(username, password)
+	return u.String()
+}
+
+// onvifStreamName 格式为 {prefix}{host}_{token}，host中的`.`和`:`替换为`_`
+func onvifStreamName(prefix, xaddr, token string) string {
+	host := xaddr
+	if u, err := url.Parse(xaddr); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	host = strings.NewReplacer(".", "_", ":", "_").Replace(host)
+	return prefix + host + "_" + token
+}
+
+func (sm *ServerManager) CtrlAddAbrLadder(info base.ApiCtrlAddAbrLadderReq) (ret base.ApiCtrlAddAbrLadderResp) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	if err := sm.addAbrLadder(info); err != nil {
+		Log.Warnf("add abr ladder failed. err=%+v", err)
+		ret.ErrorCode = base.ErrorCodeParamInvalid
+		ret.Desp = base.DespParamInvalid
+		return
+	}
+
+	ret.ErrorCode = base.ErrorCodeSucc
+	ret.Desp = base.DespSucc
+	ret.Data.Name = info.Name
+	return
+}
+
+func (sm *ServerManager) CtrlDelAbrLadder(info base.ApiCtrlDelAbrLadderReq) (ret base.ApiCtrlDelAbrLadderResp) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	if _, exist := sm.abrLadders[info.Name]; !exist {
+		ret.ErrorCode = base.ErrorCodeAbrLadderNotFound
+		ret.Desp = base.DespAbrLadderNotFound
+		return
+	}
+	delete(sm.abrLadders, info.Name)
+
+	ret.ErrorCode = base.ErrorCodeSucc
+	ret.Desp = base.DespSucc
+	return
+}
+
+func (sm *ServerManager) CtrlPushSubtitle(info base.ApiCtrlPushSubtitleReq) (ret base.ApiCtrlPushSubtitleResp) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	if err := hls.CheckRenditionLanguage(info.Language); err != nil {
+		ret.ErrorCode = base.ErrorCodeParamInvalid
+		ret.Desp = base.DespParamInvalid
+		return
+	}
+
+	g := sm.getGroup("", info.StreamName)
+	if g == nil {
+		ret.ErrorCode = base.ErrorCodeGroupNotFound
+		ret.Desp = base.DespGroupNotFound
+		return
+	}
+
+	cue := hls.SubtitleCue{
+		StartMs: info.StartMs,
+		EndMs:   info.EndMs,
+		Text:    info.Text,
+	}
+	if !g.PushHlsSubtitle(info.Language, cue) {
+		ret.ErrorCode = base.ErrorCodeHlsNotEnable
+		ret.Desp = base.DespHlsNotEnable
+		return
+	}
+
+	ret.ErrorCode = base.ErrorCodeSucc
+	ret.Desp = base.DespSucc
+	return
+}
@@ -0,0 +1,38 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package logic
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/q191201771/naza/pkg/assert"
+)
+
+func TestNewHlsKeyProvider(t *testing.T) {
+	p, err := newHlsKeyProvider(&HlsConfig{})
+	assert.Equal(t, nil, err)
+	assert.IsNotNil(t, p)
+
+	// 密钥文件不存在
+	p, err = newHlsKeyProvider(&HlsConfig{KeyProviderType: HlsKeyProviderTypeFile, KeyFile: filepath.Join(t.TempDir(), "notexist.key")})
+	assert.IsNotNil(t, err)
+	assert.Equal(t, nil, p)
+
+	// 密钥文件内容非法
+	filename := filepath.Join(t.TempDir(), "bad.key")
+	assert.Equal(t, nil, os.WriteFile(filename, []byte("bad"), 0666))
+	p, err = newHlsKeyProvider(&HlsConfig{KeyProviderType: HlsKeyProviderTypeFile, KeyFile: filename})
+	assert.IsNotNil(t, err)
+	assert.Equal(t, nil, p)
+
+	_, err = newHlsKeyProvider(&HlsConfig{KeyProviderType: "notexist"})
+	assert.IsNotNil(t, err)
+}
@@ -0,0 +1,243 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package mpa
+
+import (
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/naza/pkg/nazabits"
+	"github.com/q191201771/naza/pkg/nazaerrors"
+)
+
+// MPA(MPEG Audio)，也即MPEG-1/MPEG-2 Audio Layer I/II/III，常见的mp2、mp3
+//
+// e.g. flv SoundFormat=2(mp3), ts stream type 0x03/0x04, rtp pt=14 (rfc2250, rfc3551)
+//
+// 每一帧都以4字节的帧头开始，帧与帧之间没有额外的封装，所以帧头的信息可以直接从数据中获取，
+// 不像aac那样需要asc或adts。
+
+const (
+	FrameHeaderLength = 4
+
+	VersionMpeg25 uint8 = 0 // MPEG Version 2.5 (非官方扩展)
+	VersionMpeg2  uint8 = 2 // MPEG Version 2 (ISO/IEC 13818-3)
+	VersionMpeg1  uint8 = 3 // MPEG Version 1 (ISO/IEC 11172-3)
+
+	LayerIII uint8 = 1
+	LayerII  uint8 = 2
+	LayerI   uint8 = 3
+
+	ChannelModeStereo      uint8 = 0
+	ChannelModeJointStereo uint8 = 1
+	ChannelModeDualChannel uint8 = 2
+	ChannelModeMono        uint8 = 3
+)
+
+// 单位kbit/s，下标为bitrate_index，0表示free format，15为非法值
+var (
+	bitrateTableV1L1  = [16]int{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, -1}
+	bitrateTableV1L2  = [16]int{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, -1}
+	bitrateTableV1L3  = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, -1}
+	bitrateTableV2L1  = [16]int{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, -1}
+	bitrateTableV2L23 = [16]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, -1}
+
+	samplingFrequencyTableV1  = [3]int{44100, 48000, 32000}
+	samplingFrequencyTableV2  = [3]int{22050, 24000, 16000}
+	samplingFrequencyTableV25 = [3]int{11025, 12000, 8000}
+)
+
+// FrameHeader
+//
+// <ISO_IEC_11172-3> <2.4.1.3 Header>
+// <ISO_IEC_13818-3> <2.4.1.3 Header>
+// ----------------------------------
+// syncword           [11b] 全部为1（标准中为12位，最后1位被MPEG 2.5复用为版本号）
+// version            [2b]  0=MPEG 2.5 1=reserved 2=MPEG 2 3=MPEG 1
+// layer              [2b]  0=reserved 1=Layer III 2=Layer II 3=Layer I
+// protection_bit     [1b]  0=帧头后跟随16位的crc
+// bitrate_index      [4b]
+// sampling_frequency [2b]
+// padding_bit        [1b]
+// private_bit        [1b]
+// mode               [2b]  0=stereo 1=joint stereo 2=dual channel 3=single channel
+// mode_extension     [2b]
+// copyright          [1b]
+// original/copy      [1b]
+// emphasis           [2b]
+type FrameHeader struct {
+	Version           uint8
+	Layer             uint8
+	ProtectionAbsent  uint8
+	BitrateIndex      uint8
+	SamplingFrequency int // 单位Hz
+	Padding           uint8
+	ChannelMode       uint8
+}
+
+// ParseFrameHeader
+//
+// @param b: 以帧头开始的mpa数据，至少4字节。函数调用结束后，内部不持有该内存块。
+func ParseFrameHeader(b []byte) (h FrameHeader, err error) {
+	err = h.Unpack(b)
+	return
+}
+
+func (h *FrameHeader) Unpack(b []byte) error {
+	if len(b) < FrameHeaderLength {
+		return nazaerrors.Wrap(base.ErrShortBuffer)
+	}
+
+	br := nazabits.NewBitReader(b)
+	syncword, _ := br.ReadBits16(11)
+	if syncword != 0x7FF {
+		return nazaerrors.Wrap(base.ErrMpa)
+	}
+	h.Version, _ = br.ReadBits8(2)
+	h.Layer, _ = br.ReadBits8(2)
+	h.ProtectionAbsent, _ = br.ReadBits8(1)
+	h.BitrateIndex, _ = br.ReadBits8(4)
+	samplingFrequencyIndex, _ := br.ReadBits8(2)
+	h.Padding, _ = br.ReadBits8(1)
+	_, _ = br.ReadBits8(1) // private_bit
+	h.ChannelMode, _ = br.ReadBits8(2)
+
+	if h.Version == 1 || h.Layer == 0 || h.BitrateIndex == 0xF || samplingFrequencyIndex == 3 {
+		return nazaerrors.Wrap(base.ErrMpa)
+	}
+
+	switch h.Version {
+	case VersionMpeg1:
+		h.SamplingFrequency = samplingFrequencyTableV1[samplingFrequencyIndex]
+	case VersionMpeg2:
+		h.SamplingFrequency = samplingFrequencyTableV2[samplingFrequencyIndex]
+	case VersionMpeg25:
+		h.SamplingFrequency = samplingFrequencyTableV25[samplingFrequencyIndex]
+	}
+	return nil
+}
+
+// IsLsf 是否为低采样率扩展（MPEG 2或MPEG 2.5），ts中对应stream type 0x04
+func (h *FrameHeader) IsLsf() bool {
+	return h.Version != VersionMpeg1
+}
+
+// Bitrate 单位kbit/s，0表示free format
+func (h *FrameHeader) Bitrate() int {
+	if h.Version == VersionMpeg1 {
+		switch h.Layer {
+		case LayerI:
+			return bitrateTableV1L1[h.BitrateIndex]
+		case LayerII:
+			return bitrateTableV1L2[h.BitrateIndex]
+		default:
+			return bitrateTableV1L3[h.BitrateIndex]
+		}
+	}
+	if h.Layer == LayerI {
+		return bitrateTableV2L1[h.BitrateIndex]
+	}
+	return bitrateTableV2L23[h.BitrateIndex]
+}
+
+func (h *FrameHeader) Channels() int {
+	if h.ChannelMode == ChannelModeMono {
+		return 1
+	}
+	return 2
+}
+
+// SamplesPerFrame 每帧包含的采样数
+func (h *FrameHeader) SamplesPerFrame() int {
+	switch h.Layer {
+	case LayerI:
+		return 384
+	case LayerII:
+		return 1152
+	default:
+		if h.IsLsf() {
+			return 576
+		}
+		return 1152
+	}
+}
+
+// FrameLength 包含帧头在内的整帧大小，free format时返回0
+func (h *FrameHeader) FrameLength() int {
+	bitrate := h.Bitrate()
+	if bitrate <= 0 || h.SamplingFrequency == 0 {
+		return 0
+	}
+
+	if h.Layer == LayerI {
+		return (12*bitrate*1000/h.SamplingFrequency + int(h.Padding)) * 4
+	}
+	return h.SamplesPerFrame()/8*bitrate*1000/h.SamplingFrequency + int(h.Padding)
+}
+
+// FrameDurationMs 每帧时长，单位毫秒
+func (h *FrameHeader) FrameDurationMs() float64 {
+	if h.SamplingFrequency == 0 {
+		return 0
+	}
+	return float64(h.SamplesPerFrame()) * 1000 / float64(h.SamplingFrequency)
+}
+
+// PackRtmpSoundHeader
+//
+// 生成rtmp/flv audio tag的第一个字节（SoundFormat、SoundRate、SoundSize、SoundType）
+//
+// 注意，flv中SoundRate只能表示5.5k、11k、22k、44k，其他采样率按最接近的值填写，播放器实际以mpa帧头为准。
+// 8k采样率使用专门的SoundFormat 14（MP3 8-Khz）。
+func (h *FrameHeader) PackRtmpSoundHeader() uint8 {
+	soundFormat := base.RtmpSoundFormatMp3
+	if h.SamplingFrequency == 8000 {
+		soundFormat = base.RtmpSoundFormatMp38k
+	}
+
+	var soundRate uint8
+	switch {
+	case h.SamplingFrequency < 11025:
+		soundRate = 0
+	case h.SamplingFrequency < 22050:
+		soundRate = 1
+	case h.SamplingFrequency < 44100:
+		soundRate = 2
+	default:
+		soundRate = 3
+	}
+
+	var soundType uint8
+	if h.Channels() == 2 {
+		soundType = 1
+	}
+
+	// SoundSize固定为16bit
+	return soundFormat<<4 | soundRate<<2 | 1<<1 | soundType
+}
+
+// SplitFrames
+//
+// 将包含一个或多个mpa帧的数据切分成单独的帧，遇到free format或不完整的帧时，剩余部分作为最后一帧返回
+//
+// @return frames: 引用的是输入参数`b`的内存块
+func SplitFrames(b []byte) (frames [][]byte, err error) {
+	for len(b) > 0 {
+		var h FrameHeader
+		if err = h.Unpack(b); err != nil {
+			return
+		}
+		l := h.FrameLength()
+		if l == 0 || l > len(b) {
+			frames = append(frames, b)
+			return
+		}
+		frames = append(frames, b[:l])
+		b = b[l:]
+	}
+	return
+}
@@ -0,0 +1,83 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package mpa_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/lal/pkg/mpa"
+	"github.com/q191201771/naza/pkg/assert"
+)
+
+func TestParseFrameHeader(t *testing.T) {
+	// MPEG 1 Layer III, 128kbit/s, 44100Hz, stereo
+	h, err := mpa.ParseFrameHeader([]byte{0xFF, 0xFB, 0x90, 0x00})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, mpa.VersionMpeg1, h.Version)
+	assert.Equal(t, mpa.LayerIII, h.Layer)
+	assert.Equal(t, 44100, h.SamplingFrequency)
+	assert.Equal(t, 128, h.Bitrate())
+	assert.Equal(t, 2, h.Channels())
+	assert.Equal(t, 1152, h.SamplesPerFrame())
+	assert.Equal(t, 417, h.FrameLength())
+	assert.Equal(t, false, h.IsLsf())
+	assert.Equal(t, uint8(0x2F), h.PackRtmpSoundHeader())
+
+	// MPEG 2 Layer III, 64kbit/s, 24000Hz, mono
+	h, err = mpa.ParseFrameHeader([]byte{0xFF, 0xF3, 0x84, 0xC0})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, mpa.VersionMpeg2, h.Version)
+	assert.Equal(t, 24000, h.SamplingFrequency)
+	assert.Equal(t, 64, h.Bitrate())
+	assert.Equal(t, 1, h.Channels())
+	assert.Equal(t, 576, h.SamplesPerFrame())
+	assert.Equal(t, 192, h.FrameLength())
+	assert.Equal(t, true, h.IsLsf())
+	assert.Equal(t, float64(24), h.FrameDurationMs())
+
+	// MPEG 1 Layer II, 192kbit/s, 48000Hz
+	h, err = mpa.ParseFrameHeader([]byte{0xFF, 0xFD, 0xA4, 0x00})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, mpa.LayerII, h.Layer)
+	assert.Equal(t, 48000, h.SamplingFrequency)
+	assert.Equal(t, 576, h.FrameLength())
+
+	// MPEG 2.5 Layer III, 8000Hz
+	h, err = mpa.ParseFrameHeader([]byte{0xFF, 0xE3, 0x28, 0xC0})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, mpa.VersionMpeg25, h.Version)
+	assert.Equal(t, 8000, h.SamplingFrequency)
+	assert.Equal(t, base.RtmpSoundFormatMp38k, h.PackRtmpSoundHeader()>>4)
+
+	// 非法数据
+	_, err = mpa.ParseFrameHeader([]byte{0xFF, 0xFB, 0x90})
+	assert.Equal(t, true, errors.Is(err, base.ErrShortBuffer))
+	_, err = mpa.ParseFrameHeader([]byte{0xFF, 0x0B, 0x90, 0x00})
+	assert.Equal(t, true, errors.Is(err, base.ErrMpa))
+	_, err = mpa.ParseFrameHeader([]byte{0xFF, 0xFB, 0xFC, 0x00})
+	assert.Equal(t, true, errors.Is(err, base.ErrMpa))
+}
+
+func TestSplitFrames(t *testing.T) {
+	frame := make([]byte, 192)
+	copy(frame, []byte{0xFF, 0xF3, 0x84, 0xC0})
+
+	var b []byte
+	b = append(b, frame...)
+	b = append(b, frame...)
+	b = append(b, frame[:100]...)
+	frames, err := mpa.SplitFrames(b)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 3, len(frames))
+	assert.Equal(t, frame, frames[0])
+	assert.Equal(t, frame, frames[1])
+	assert.Equal(t, frame[:100], frames[2])
+}
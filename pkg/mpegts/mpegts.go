@@ -33,15 +33,19 @@ const (
 const (
 	// StreamTypeUnknown ... -----------------------------------------------------------------------------
 	// <iso13818-1.pdf> <Table 2-29 Stream type assignments> <page 66/174>
+	// 0x03 MPA  (ISO/IEC 11172-3 Audio)
+	// 0x04 MPA  (ISO/IEC 13818-3 Audio)
 	// 0x0F AAC  (ISO/IEC 13818-7 Audio with ADTS transport syntax)
 	// 0x1B AVC  (video stream as defined in ITU-T Rec. H.264 | ISO/IEC 14496-10 Video)
 	// 0x24 HEVC (HEVC video stream as defined in Rec. ITU-T H.265 | ISO/IEC 23008-2  MPEG-H Part 2)
 	// -----------------------------------------------------------------------------
-	StreamTypeUnknown uint8 = 0x00
-	StreamTypePrivate uint8 = 0x06
-	StreamTypeAac     uint8 = 0x0F
-	StreamTypeAvc     uint8 = 0x1B
-	StreamTypeHevc    uint8 = 0x24
+	StreamTypeUnknown    uint8 = 0x00
+	StreamTypeMpeg1Audio uint8 = 0x03
+	StreamTypeMpeg2Audio uint8 = 0x04
+	StreamTypePrivate    uint8 = 0x06
+	StreamTypeAac        uint8 = 0x0F
+	StreamTypeAvc        uint8 = 0x1B
+	StreamTypeHevc       uint8 = 0x24
 )
 
 // PES
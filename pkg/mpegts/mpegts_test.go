@@ -11,7 +11,9 @@ package mpegts_test
 import (
 	"testing"
 
+	"github.com/q191201771/lal/pkg/base"
 	"github.com/q191201771/lal/pkg/innertest"
+	"github.com/q191201771/naza/pkg/assert"
 
 	"github.com/q191201771/lal/pkg/mpegts"
 )
@@ -153,3 +155,46 @@ func TestParseFixedTsPacket(t *testing.T) {
 	pmt := mpegts.ParsePmt(FixedFragmentHeader[188+5:])
 	mpegts.Log.Debugf("%+v", pmt)
 }
+
+func TestPackPmtMpa(t *testing.T) {
+	pmt := mpegts.ParsePmt(mpegts.PackPmt(int(base.RtmpCodecIdAvc), int(base.RtmpSoundFormatMp3))[5:])
+	assert.Equal(t, mpegts.StreamTypeMpeg1Audio, pmt.SearchPid(mpegts.PidAudio).StreamType)
+
+	pmt = mpegts.ParsePmt(mpegts.PackPmtWithAudioStreamType(int(base.RtmpCodecIdAvc), int(base.RtmpSoundFormatMp3), mpegts.StreamTypeMpeg2Audio)[5:])
+	assert.Equal(t, mpegts.StreamTypeAvc, pmt.SearchPid(mpegts.PidVideo).StreamType)
+	assert.Equal(t, mpegts.StreamTypeMpeg2Audio, pmt.SearchPid(mpegts.PidAudio).StreamType)
+}
+
+func TestPackPmtWithProgramElements(t *testing.T) {
+	// 带descriptor时，ParsePmt需要正确跳过descriptor
+	pmt := mpegts.ParsePmt(mpegts.PackPmt(int(base.RtmpCodecIdAvc), int(base.RtmpSoundFormatOpus))[5:])
+	assert.Equal(t, 2, len(pmt.ProgramElements))
+	assert.Equal(t, uint16(10), pmt.SearchPid(mpegts.PidAudio).Length)
+
+	b := mpegts.PackPmtWithProgramElements([]mpegts.PmtProgramElement{
+		{
+			StreamType: 0xdb,
+			Pid:        mpegts.PidVideo,
+			Descriptors: []mpegts.Descriptor{
+				{
+					Length: 4,
+					Tag:    mpegts.DescriptorTagPrivateDataIndicator,
+					PrivateDataIndicator: mpegts.DescriptorPrivateDataIndicator{
+						PrivateDataIndicator: 0x7a617663,
+					},
+				},
+			},
+		},
+		{
+			StreamType: 0xcf,
+			Pid:        mpegts.PidAudio,
+		},
+	})
+	assert.Equal(t, 188, len(b))
+	assert.Equal(t, []byte{0x0f, 0x04, 'z', 'a', 'v', 'c'}, b[5+12+5:5+12+5+6])
+	pmt = mpegts.ParsePmt(b[5:])
+	assert.Equal(t, 2, len(pmt.ProgramElements))
+	assert.Equal(t, uint8(0xdb), pmt.SearchPid(mpegts.PidVideo).StreamType)
+	assert.Equal(t, uint16(6), pmt.SearchPid(mpegts.PidVideo).Length)
+	assert.Equal(t, uint8(0xcf), pmt.SearchPid(mpegts.PidAudio).StreamType)
+}
@@ -177,11 +177,11 @@ func (frame *Frame) Pack() []byte {
 			wpos += 5
 
 			// 写入PTS的值
-			packPts(packet[wpos:], flags>>6, frame.Pts+delay)
+			PackPts(packet[wpos:], flags>>6, frame.Pts+delay)
 			wpos += 5
 			// 写入DTS的值
 			if frame.Pts != frame.Dts {
-				packPts(packet[wpos:], 1, frame.Dts+delay)
+				PackPts(packet[wpos:], 1, frame.Dts+delay)
 				wpos += 5
 			}
 
@@ -275,8 +275,10 @@ func packPcr(out []byte, pcr uint64) {
 	//out[5] = uint8(pcrLow)
 }
 
-// 注意，除PTS外，DTS也使用这个函数打包
-func packPts(out []byte, fb uint8, pts uint64) {
+// PackPts 打包pes头中5字节的PTS或DTS字段，fb为高4位的前缀标志
+//
+// 注意，除PTS外，DTS也使用这个函数打包，ps流(见package gb28181)也复用这个函数
+func PackPts(out []byte, fb uint8, pts uint64) {
 	var val uint64
 	out[0] = (fb << 4) | (uint8(pts>>30) & 0x07) | 1
 
@@ -84,9 +84,11 @@ func ParsePmt(b []byte) (pmt Pmt) {
 	if pmt.pil != 0 {
 		Log.Warn(pmt.pil)
 		_, _ = br.ReadBytes(uint(pmt.pil))
+		length -= pmt.pil
 	}
 
-	for i := uint16(0); i < length; i += 5 {
+	// 注意，descriptor的内容直接跳过，不解析
+	for i := uint16(0); i+5 <= length; {
 		var ppe PmtProgramElement
 		ppe.StreamType, _ = br.ReadBits8(8)
 		_, _ = br.ReadBits8(3)
@@ -94,10 +96,10 @@ func ParsePmt(b []byte) (pmt Pmt) {
 		_, _ = br.ReadBits8(4)
 		ppe.Length, _ = br.ReadBits16(12)
 		if ppe.Length != 0 {
-			Log.Warn(ppe.Length)
-			_, _ = br.ReadBits32(uint(ppe.Length))
+			_, _ = br.ReadBytes(uint(ppe.Length))
 		}
 		pmt.ProgramElements = append(pmt.ProgramElements, ppe)
+		i += 5 + ppe.Length
 	}
 
 	return
@@ -113,16 +115,16 @@ func (pmt *Pmt) SearchPid(pid uint16) *PmtProgramElement {
 }
 
 func PackPmt(videoCodecId, audioCodecId int) []byte {
-	ts := make([]byte, 188)
-	tsheader := []byte{0x47, 0x50, 0x01, 0x10}
-	copy(ts, tsheader)
+	return PackPmtWithAudioStreamType(videoCodecId, audioCodecId, StreamTypeUnknown)
+}
 
-	psi := NewPsi()
-	psi.sectionData.header.tableId = TsPsiIdPms
-	psi.sectionData.header.sectionSyntaxIndicator = 1
-	psi.sectionData.section.tableIdExtension = 1
-	psi.sectionData.section.currentNextIndicator = 1
-	psi.sectionData.pmtData.pcrPid = 0x100
+// PackPmtWithAudioStreamType
+//
+// @param audioStreamType: 音频的stream type无法只通过rtmp的SoundFormat确定时（比如mp3可能是0x03或0x04），由调用方指定。
+//
+//	如果为 StreamTypeUnknown ，则通过`audioCodecId`推导。
+func PackPmtWithAudioStreamType(videoCodecId, audioCodecId int, audioStreamType uint8) []byte {
+	var pes []PmtProgramElement
 
 	videoStreamType := StreamTypeUnknown
 	if videoCodecId == int(base.RtmpCodecIdAvc) {
@@ -132,17 +134,23 @@ func PackPmt(videoCodecId, audioCodecId int) []byte {
 	}
 
 	if videoStreamType != StreamTypeUnknown {
-		psi.sectionData.pmtData.pes = append(psi.sectionData.pmtData.pes, PmtProgramElement{
+		pes = append(pes, PmtProgramElement{
 			StreamType: videoStreamType,
 			Pid:        PidVideo,
 		})
 	}
 
-	audioStreamType := StreamTypeUnknown
-	if audioCodecId == int(base.RtmpSoundFormatAac) {
-		audioStreamType = StreamTypeAac
-	} else if audioCodecId == int(base.RtmpSoundFormatOpus) {
-		audioStreamType = StreamTypePrivate
+	if audioStreamType == StreamTypeUnknown {
+		if audioCodecId == int(base.RtmpSoundFormatAac) {
+			audioStreamType = StreamTypeAac
+		} else if audioCodecId == int(base.RtmpSoundFormatOpus) {
+			audioStreamType = StreamTypePrivate
+		} else if audioCodecId == int(base.RtmpSoundFormatMp3) {
+			audioStreamType = StreamTypeMpeg1Audio
+		} else if audioCodecId == int(base.RtmpSoundFormatMp38k) {
+			// 8k采样率只存在于MPEG 2.5
+			audioStreamType = StreamTypeMpeg2Audio
+		}
 	}
 
 	if audioStreamType != StreamTypeUnknown {
@@ -173,9 +181,28 @@ func PackPmt(videoCodecId, audioCodecId int) []byte {
 			pmtEle.Descriptors = append(pmtEle.Descriptors, descriptor...)
 		}
 
-		psi.sectionData.pmtData.pes = append(psi.sectionData.pmtData.pes, pmtEle)
+		pes = append(pes, pmtEle)
 	}
 
+	return PackPmtWithProgramElements(pes)
+}
+
+// PackPmtWithProgramElements 使用调用方指定的stream type和descriptor打包PMT，比如SAMPLE-AES加密时
+//
+// 注意，PCR使用 PidVideo
+func PackPmtWithProgramElements(pes []PmtProgramElement) []byte {
+	ts := make([]byte, 188)
+	tsheader := []byte{0x47, 0x50, 0x01, 0x10}
+	copy(ts, tsheader)
+
+	psi := NewPsi()
+	psi.sectionData.header.tableId = TsPsiIdPms
+	psi.sectionData.header.sectionSyntaxIndicator = 1
+	psi.sectionData.section.tableIdExtension = 1
+	psi.sectionData.section.currentNextIndicator = 1
+	psi.sectionData.pmtData.pcrPid = 0x100
+	psi.sectionData.pmtData.pes = pes
+
 	psilen, psiData := psi.Pack()
 	copy(ts[4:], psiData)
 
@@ -215,6 +215,8 @@ func (psi *PsiSection) calcDescriptorLength(d Descriptor) uint8 {
 		return psi.calcDescriptorRegistrationLength(d.Registration)
 	case DescriptorTagExtension:
 		return psi.calcDescriptorExtensionLength(d.Extension)
+	case DescriptorTagPrivateDataIndicator:
+		return 4
 	}
 
 	return 0
@@ -281,6 +283,8 @@ func (psi *PsiSection) writeDescriptor(bw *nazabits.BitWriter, d Descriptor) {
 		psi.writeDescriptorRegistration(bw, d.Registration)
 	case DescriptorTagExtension:
 		psi.writeDescriptorExtension(bw, d.Extension)
+	case DescriptorTagPrivateDataIndicator:
+		psi.writeDescriptorPrivateDataIndicator(bw, d.PrivateDataIndicator)
 	}
 }
 
@@ -305,11 +309,17 @@ func (psi *PsiSection) writeDescriptorExtension(bw *nazabits.BitWriter, d Descri
 	}
 }
 
+func (psi *PsiSection) writeDescriptorPrivateDataIndicator(bw *nazabits.BitWriter, d DescriptorPrivateDataIndicator) {
+	bw.WriteBits16(16, uint16((d.PrivateDataIndicator>>16)&0xFFFF))
+	bw.WriteBits16(16, uint16(d.PrivateDataIndicator&0xFFFF))
+}
+
 type Descriptor struct {
-	Length       uint8
-	Tag          uint8
-	Registration DescriptorRegistration
-	Extension    DescriptorExtension
+	Length               uint8
+	Tag                  uint8
+	Registration         DescriptorRegistration
+	Extension            DescriptorExtension
+	PrivateDataIndicator DescriptorPrivateDataIndicator
 }
 
 type DescriptorRegistration struct {
@@ -317,6 +327,10 @@ type DescriptorRegistration struct {
 	FormatIdentifier             uint32
 }
 
+type DescriptorPrivateDataIndicator struct {
+	PrivateDataIndicator uint32
+}
+
 type DescriptorExtension struct {
 	SupplementaryAudio DescriptorExtensionSupplementaryAudio
 	Tag                uint8
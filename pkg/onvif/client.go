@@ -0,0 +1,241 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package onvif
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/naza/pkg/nazaerrors"
+)
+
+// client.go
+//
+// SOAP客户端，鉴权使用WS-Security UsernameToken（PasswordDigest）
+
+// Profile Media服务中的一个profile，通常对应设备的主码流、子码流
+type Profile struct {
+	Token    string
+	Name     string
+	Encoding string // H264、H265等
+	Width    int
+	Height   int
+}
+
+type Client struct {
+	xaddr    string
+	username string
+	password string
+
+	mediaXAddr string
+	httpClient *http.Client
+}
+
+// NewClient
+//
+// @param xaddr:     Device服务的地址，见 ProbeMatch.XAddrs
+// @param username:  为空时不鉴权
+// @param timeoutMs: 单个请求的超时时间，为0时使用默认值5000
+func NewClient(xaddr, username, password string, timeoutMs int) *Client {
+	if timeoutMs <= 0 {
+		timeoutMs = defaultClientTimeoutMs
+	}
+	return &Client{
+		xaddr:      xaddr,
+		username:   username,
+		password:   password,
+		httpClient: &http.Client{Timeout: time.Duration(timeoutMs) * time.Millisecond},
+	}
+}
+
+// GetProfiles 阻塞函数
+//
+// 注意，第一次调用时，内部会先通过GetCapabilities获取Media服务的地址
+func (c *Client) GetProfiles() ([]Profile, error) {
+	c.resolveMediaXAddr()
+
+	var resp struct {
+		Body struct {
+			GetProfilesResponse struct {
+				Profiles []struct {
+					Token                     string `xml:"token,attr"`
+					Name                      string `xml:"Name"`
+					VideoEncoderConfiguration struct {
+						Encoding   string `xml:"Encoding"`
+						Resolution struct {
+							Width  int `xml:"Width"`
+							Height int `xml:"Height"`
+						} `xml:"Resolution"`
+					} `xml:"VideoEncoderConfiguration"`
+				} `xml:"Profiles"`
+			} `xml:"GetProfilesResponse"`
+		} `xml:"Body"`
+	}
+	if err := c.call(c.mediaXAddr, `<GetProfiles xmlns="http://www.onvif.org/ver10/media/wsdl"/>`, &resp); err != nil {
+		return nil, err
+	}
+
+	var profiles []Profile
+	for _, item := range resp.Body.GetProfilesResponse.Profiles {
+		profiles = append(profiles, Profile{
+			Token:    item.Token,
+			Name:     item.Name,
+			Encoding: item.VideoEncoderConfiguration.Encoding,
+			Width:    item.VideoEncoderConfiguration.Resolution.Width,
+			Height:   item.VideoEncoderConfiguration.Resolution.Height,
+		})
+	}
+	return profiles, nil
+}
+
+// GetStreamUri 阻塞函数，获取profile对应的RTSP地址（RTP-Unicast over RTSP）
+func (c *Client) GetStreamUri(profileToken string) (string, error) {
+	c.resolveMediaXAddr()
+
+	var resp struct {
+		Body struct {
+			GetStreamUriResponse struct {
+				MediaUri struct {
+					Uri string `xml:"Uri"`
+				} `xml:"MediaUri"`
+			} `xml:"GetStreamUriResponse"`
+		} `xml:"Body"`
+	}
+	body := fmt.Sprintf(`<GetStreamUri xmlns="http://www.onvif.org/ver10/media/wsdl">`+
+		`<StreamSetup><Stream xmlns="http://www.onvif.org/ver10/schema">RTP-Unicast</Stream>`+
+		`<Transport xmlns="http://www.onvif.org/ver10/schema"><Protocol>RTSP</Protocol></Transport></StreamSetup>`+
+		`<ProfileToken>%s</ProfileToken></GetStreamUri>`, xmlEscape(profileToken))
+	if err := c.call(c.mediaXAddr, body, &resp); err != nil {
+		return "", err
+	}
+	uri := strings.TrimSpace(resp.Body.GetStreamUriResponse.MediaUri.Uri)
+	if uri == "" {
+		return "", nazaerrors.Wrap(base.ErrOnvif, "empty stream uri")
+	}
+	return uri, nil
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// resolveMediaXAddr 获取失败时使用Device服务的地址，大部分设备的服务都在同一个地址上
+func (c *Client) resolveMediaXAddr() {
+	if c.mediaXAddr != "" {
+		return
+	}
+	c.mediaXAddr = c.xaddr
+
+	var resp struct {
+		Body struct {
+			GetCapabilitiesResponse struct {
+				Capabilities struct {
+					Media struct {
+						XAddr string `xml:"XAddr"`
+					} `xml:"Media"`
+				} `xml:"Capabilities"`
+			} `xml:"GetCapabilitiesResponse"`
+		} `xml:"Body"`
+	}
+	body := `<GetCapabilities xmlns="http://www.onvif.org/ver10/device/wsdl"><Category>Media</Category></GetCapabilities>`
+	if err := c.call(c.xaddr, body, &resp); err != nil {
+		Log.Warnf("onvif get capabilities failed, use device xaddr. xaddr=%s, err=%+v", c.xaddr, err)
+		return
+	}
+	if xaddr := strings.TrimSpace(resp.Body.GetCapabilitiesResponse.Capabilities.Media.XAddr); xaddr != "" {
+		c.mediaXAddr = xaddr
+	}
+}
+
+// call 发送SOAP请求，并将响应解析到 out 中
+func (c *Client) call(xaddr string, body string, out interface{}) error {
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>`)
+	buf.WriteString(`<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope">`)
+	if c.username != "" {
+		buf.WriteString(`<s:Header>`)
+		buf.WriteString(packUsernameToken(c.username, c.password, makeNonce(), time.Now().UTC()))
+		buf.WriteString(`</s:Header>`)
+	}
+	buf.WriteString(`<s:Body>`)
+	buf.WriteString(body)
+	buf.WriteString(`</s:Body></s:Envelope>`)
+
+	resp, err := c.httpClient.Post(xaddr, "application/soap+xml; charset=utf-8", &buf)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var fault struct {
+		Body struct {
+			Fault *struct {
+				Reason string `xml:"Reason>Text"`
+			} `xml:"Fault"`
+		} `xml:"Body"`
+	}
+	if xml.Unmarshal(b, &fault) == nil && fault.Body.Fault != nil {
+		return nazaerrors.Wrap(base.ErrOnvif, fmt.Sprintf("soap fault. status=%d, reason=%s", resp.StatusCode, fault.Body.Fault.Reason))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nazaerrors.Wrap(base.ErrOnvif, fmt.Sprintf("http status=%d", resp.StatusCode))
+	}
+	return xml.Unmarshal(b, out)
+}
+
+// packUsernameToken WS-Security UsernameToken Profile 1.0，PasswordDigest = Base64(SHA1(nonce + created + password))
+func packUsernameToken(username, password string, nonce []byte, created time.Time) string {
+	createdStr := created.Format("2006-01-02T15:04:05.000Z")
+	h := sha1.New()
+	h.Write(nonce)
+	h.Write([]byte(createdStr))
+	h.Write([]byte(password))
+	digest := base64.StdEncoding.EncodeToString(h.Sum(nil))
+
+	return fmt.Sprintf(`<Security s:mustUnderstand="1" xmlns="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd">`+
+		`<UsernameToken><Username>%s</Username>`+
+		`<Password Type="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-username-token-profile-1.0#PasswordDigest">%s</Password>`+
+		`<Nonce EncodingType="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-soap-message-security-1.0#Base64Binary">%s</Nonce>`+
+		`<Created xmlns="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd">%s</Created>`+
+		`</UsernameToken></Security>`,
+		xmlEscape(username), digest, base64.StdEncoding.EncodeToString(nonce), createdStr)
+}
+
+func makeNonce() []byte {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return b
+}
+
+// newUuid 随机的UUID（version 4）
+func newUuid() string {
+	b := makeNonce()
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
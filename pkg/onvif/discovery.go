@@ -0,0 +1,180 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package onvif
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// discovery.go
+//
+// WS-Discovery（ONVIF Core Specification 7.3），在本地网段组播Probe，收集设备回复的ProbeMatch
+
+type DiscoverOption struct {
+	TimeoutMs int    // 等待设备回复的时长，默认3000
+	Addr      string // Probe的目的地址，默认 DefaultDiscoveryAddr
+}
+
+var defaultDiscoverOption = DiscoverOption{
+	TimeoutMs: defaultDiscoverTimeoutMs,
+	Addr:      DefaultDiscoveryAddr,
+}
+
+type ModDiscoverOption func(option *DiscoverOption)
+
+// ProbeMatch 一个设备的回复
+type ProbeMatch struct {
+	EndpointAddress string   // 设备的唯一标识，比如urn:uuid:xxx
+	XAddrs          []string // Device服务的地址
+	Scopes          []string
+}
+
+// Name 设备名称，来自scope `onvif://www.onvif.org/name/xxx`
+func (m ProbeMatch) Name() string {
+	return m.scope("onvif://www.onvif.org/name/")
+}
+
+// Hardware 设备型号，来自scope `onvif://www.onvif.org/hardware/xxx`
+func (m ProbeMatch) Hardware() string {
+	return m.scope("onvif://www.onvif.org/hardware/")
+}
+
+// Discover 阻塞直到超时，返回超时前收到的所有设备（按EndpointAddress去重）
+func Discover(modOptions ...ModDiscoverOption) ([]ProbeMatch, error) {
+	opt := defaultDiscoverOption
+	for _, fn := range modOptions {
+		fn(&opt)
+	}
+
+	raddr, err := net.ResolveUDPAddr("udp4", opt.Addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	messageId := "uuid:" + newUuid()
+	if _, err = conn.WriteToUDP(packProbe(messageId), raddr); err != nil {
+		return nil, err
+	}
+
+	var matches []ProbeMatch
+	deadline := time.Now().Add(time.Duration(opt.TimeoutMs) * time.Millisecond)
+	if err = conn.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 65535)
+	for {
+		n, from, rErr := conn.ReadFromUDP(buf)
+		if rErr != nil {
+			// 超时
+			break
+		}
+		relatesTo, items, pErr := parseProbeMatches(buf[:n])
+		if pErr != nil {
+			Log.Debugf("parse probe matches failed. from=%s, err=%+v", from.String(), pErr)
+			continue
+		}
+		if relatesTo != "" && relatesTo != messageId {
+			continue
+		}
+		for _, item := range items {
+			if !containsMatch(matches, item.EndpointAddress) {
+				Log.Debugf("onvif device discovered. from=%s, match=%+v", from.String(), item)
+				matches = append(matches, item)
+			}
+		}
+	}
+	return matches, nil
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+func (m ProbeMatch) scope(prefix string) string {
+	for _, s := range m.Scopes {
+		if strings.HasPrefix(s, prefix) {
+			v, err := url.PathUnescape(s[len(prefix):])
+			if err != nil {
+				return s[len(prefix):]
+			}
+			return v
+		}
+	}
+	return ""
+}
+
+func containsMatch(matches []ProbeMatch, endpointAddress string) bool {
+	for _, m := range matches {
+		if m.EndpointAddress == endpointAddress {
+			return true
+		}
+	}
+	return false
+}
+
+func packProbe(messageId string) []byte {
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>`+
+		`<e:Envelope xmlns:e="http://www.w3.org/2003/05/soap-envelope" xmlns:w="http://schemas.xmlsoap.org/ws/2004/08/addressing" `+
+		`xmlns:d="http://schemas.xmlsoap.org/ws/2005/04/discovery" xmlns:dn="http://www.onvif.org/ver10/network/wsdl">`+
+		`<e:Header>`+
+		`<w:MessageID>%s</w:MessageID>`+
+		`<w:To e:mustUnderstand="true">urn:schemas-xmlsoap-org:ws:2005:04:discovery</w:To>`+
+		`<w:Action e:mustUnderstand="true">http://schemas.xmlsoap.org/ws/2005/04/discovery/Probe</w:Action>`+
+		`</e:Header>`+
+		`<e:Body><d:Probe><d:Types>dn:NetworkVideoTransmitter</d:Types></d:Probe></e:Body>`+
+		`</e:Envelope>`, messageId))
+}
+
+type probeMatchesEnvelope struct {
+	Header struct {
+		RelatesTo string `xml:"RelatesTo"`
+	} `xml:"Header"`
+	Body struct {
+		ProbeMatches struct {
+			Items []struct {
+				EndpointReference struct {
+					Address string `xml:"Address"`
+				} `xml:"EndpointReference"`
+				Scopes string `xml:"Scopes"`
+				XAddrs string `xml:"XAddrs"`
+			} `xml:"ProbeMatch"`
+		} `xml:"ProbeMatches"`
+	} `xml:"Body"`
+}
+
+func parseProbeMatches(b []byte) (relatesTo string, matches []ProbeMatch, err error) {
+	var env probeMatchesEnvelope
+	if err = xml.Unmarshal(b, &env); err != nil {
+		return
+	}
+	relatesTo = strings.TrimSpace(env.Header.RelatesTo)
+	for _, item := range env.Body.ProbeMatches.Items {
+		m := ProbeMatch{
+			EndpointAddress: strings.TrimSpace(item.EndpointReference.Address),
+			XAddrs:          strings.Fields(item.XAddrs),
+			Scopes:          strings.Fields(item.Scopes),
+		}
+		if len(m.XAddrs) == 0 {
+			continue
+		}
+		if m.EndpointAddress == "" {
+			m.EndpointAddress = m.XAddrs[0]
+		}
+		matches = append(matches, m)
+	}
+	return
+}
@@ -0,0 +1,29 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package onvif
+
+import (
+	"github.com/q191201771/naza/pkg/nazalog"
+)
+
+// 只实现了发现IP摄像头并获取其RTSP地址所需要的部分：
+// - WS-Discovery，见 Discover
+// - Device以及Media服务的GetCapabilities、GetProfiles、GetStreamUri，见 Client
+
+var (
+	Log = nazalog.GetGlobalLogger()
+)
+
+const (
+	// DefaultDiscoveryAddr WS-Discovery的组播地址
+	DefaultDiscoveryAddr = "239.255.255.250:3702"
+
+	defaultDiscoverTimeoutMs = 3000
+	defaultClientTimeoutMs   = 5000
+)
@@ -0,0 +1,150 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package onvif
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/q191201771/naza/pkg/assert"
+)
+
+const testProbeMatchesTmpl = `<?xml version="1.0" encoding="UTF-8"?>
+<SOAP-ENV:Envelope xmlns:SOAP-ENV="http://www.w3.org/2003/05/soap-envelope" xmlns:wsa="http://schemas.xmlsoap.org/ws/2004/08/addressing" xmlns:d="http://schemas.xmlsoap.org/ws/2005/04/discovery">
+<SOAP-ENV:Header><wsa:RelatesTo>%s</wsa:RelatesTo></SOAP-ENV:Header>
+<SOAP-ENV:Body><d:ProbeMatches><d:ProbeMatch>
+<wsa:EndpointReference><wsa:Address>urn:uuid:device-1</wsa:Address></wsa:EndpointReference>
+<d:Types>dn:NetworkVideoTransmitter</d:Types>
+<d:Scopes>onvif://www.onvif.org/type/video_encoder onvif://www.onvif.org/name/IPC%%20Cam onvif://www.onvif.org/hardware/DS-2CD</d:Scopes>
+<d:XAddrs>http://192.168.1.64/onvif/device_service http://[fe80::1]/onvif/device_service</d:XAddrs>
+</d:ProbeMatch></d:ProbeMatches></SOAP-ENV:Body></SOAP-ENV:Envelope>`
+
+func TestParseProbeMatches(t *testing.T) {
+	relatesTo, matches, err := parseProbeMatches([]byte(fmt.Sprintf(testProbeMatchesTmpl, "uuid:abc")))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "uuid:abc", relatesTo)
+	assert.Equal(t, 1, len(matches))
+	assert.Equal(t, "urn:uuid:device-1", matches[0].EndpointAddress)
+	assert.Equal(t, 2, len(matches[0].XAddrs))
+	assert.Equal(t, "http://192.168.1.64/onvif/device_service", matches[0].XAddrs[0])
+	assert.Equal(t, "IPC Cam", matches[0].Name())
+	assert.Equal(t, "DS-2CD", matches[0].Hardware())
+}
+
+// TestDiscover 本地模拟一个设备回复ProbeMatch，并且重复回复以及回复不相关的消息
+func TestDiscover(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.Equal(t, nil, err)
+	defer conn.Close()
+
+	go func() {
+		buf := make([]byte, 65535)
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		m := regexp.MustCompile(`<w:MessageID>(.*?)</w:MessageID>`).FindSubmatch(buf[:n])
+		if m == nil {
+			return
+		}
+		_, _ = conn.WriteToUDP([]byte(fmt.Sprintf(testProbeMatchesTmpl, "uuid:other")), from)
+		_, _ = conn.WriteToUDP([]byte(fmt.Sprintf(testProbeMatchesTmpl, m[1])), from)
+		_, _ = conn.WriteToUDP([]byte(fmt.Sprintf(testProbeMatchesTmpl, m[1])), from)
+	}()
+
+	matches, err := Discover(func(option *DiscoverOption) {
+		option.Addr = conn.LocalAddr().String()
+		option.TimeoutMs = 300
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(matches))
+	assert.Equal(t, "urn:uuid:device-1", matches[0].EndpointAddress)
+}
+
+func TestClient(t *testing.T) {
+	var (
+		username = "admin"
+		password = "pass&word"
+	)
+	var srvUrl string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body := string(b)
+
+		// 校验PasswordDigest
+		nonce, _ := base64.StdEncoding.DecodeString(submatch(body, `<Nonce[^>]*>(.*?)</Nonce>`))
+		created := submatch(body, `<Created[^>]*>(.*?)</Created>`)
+		h := sha1.New()
+		h.Write(nonce)
+		h.Write([]byte(created))
+		h.Write([]byte(password))
+		if submatch(body, `<Username>(.*?)</Username>`) != username ||
+			submatch(body, `<Password[^>]*>(.*?)</Password>`) != base64.StdEncoding.EncodeToString(h.Sum(nil)) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"><s:Body><s:Fault>`+
+				`<s:Reason><s:Text>Sender not Authorized</s:Text></s:Reason></s:Fault></s:Body></s:Envelope>`)
+			return
+		}
+
+		switch {
+		case strings.Contains(body, "<GetCapabilities"):
+			assert.Equal(t, "/onvif/device_service", r.URL.Path)
+			_, _ = fmt.Fprintf(w, `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"><s:Body><tds:GetCapabilitiesResponse xmlns:tds="http://www.onvif.org/ver10/device/wsdl">`+
+				`<tds:Capabilities><tt:Media xmlns:tt="http://www.onvif.org/ver10/schema"><tt:XAddr>%s/onvif/media_service</tt:XAddr></tt:Media></tds:Capabilities>`+
+				`</tds:GetCapabilitiesResponse></s:Body></s:Envelope>`, srvUrl)
+		case strings.Contains(body, "<GetProfiles"):
+			assert.Equal(t, "/onvif/media_service", r.URL.Path)
+			_, _ = io.WriteString(w, `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope" xmlns:tt="http://www.onvif.org/ver10/schema"><s:Body><trt:GetProfilesResponse xmlns:trt="http://www.onvif.org/ver10/media/wsdl">`+
+				`<trt:Profiles token="Profile_1"><tt:Name>mainStream</tt:Name><tt:VideoEncoderConfiguration><tt:Encoding>H264</tt:Encoding><tt:Resolution><tt:Width>1920</tt:Width><tt:Height>1080</tt:Height></tt:Resolution></tt:VideoEncoderConfiguration></trt:Profiles>`+
+				`<trt:Profiles token="Profile_2"><tt:Name>subStream</tt:Name><tt:VideoEncoderConfiguration><tt:Encoding>H265</tt:Encoding><tt:Resolution><tt:Width>640</tt:Width><tt:Height>360</tt:Height></tt:Resolution></tt:VideoEncoderConfiguration></trt:Profiles>`+
+				`</trt:GetProfilesResponse></s:Body></s:Envelope>`)
+		case strings.Contains(body, "<GetStreamUri"):
+			assert.Equal(t, "Profile_2", submatch(body, `<ProfileToken>(.*?)</ProfileToken>`))
+			_, _ = io.WriteString(w, `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"><s:Body><trt:GetStreamUriResponse xmlns:trt="http://www.onvif.org/ver10/media/wsdl">`+
+				`<trt:MediaUri><tt:Uri xmlns:tt="http://www.onvif.org/ver10/schema">rtsp://192.168.1.64:554/Streaming/Channels/102</tt:Uri></trt:MediaUri>`+
+				`</trt:GetStreamUriResponse></s:Body></s:Envelope>`)
+		}
+	}))
+	defer srv.Close()
+	srvUrl = srv.URL
+
+	c := NewClient(srv.URL+"/onvif/device_service", username, password, 0)
+	profiles, err := c.GetProfiles()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []Profile{
+		{Token: "Profile_1", Name: "mainStream", Encoding: "H264", Width: 1920, Height: 1080},
+		{Token: "Profile_2", Name: "subStream", Encoding: "H265", Width: 640, Height: 360},
+	}, profiles)
+
+	uri, err := c.GetStreamUri("Profile_2")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "rtsp://192.168.1.64:554/Streaming/Channels/102", uri)
+
+	// 密码错误时返回SOAP Fault
+	c = NewClient(srv.URL+"/onvif/device_service", username, "wrong", 0)
+	_, err = c.GetProfiles()
+	assert.IsNotNil(t, err)
+	assert.Equal(t, true, strings.Contains(err.Error(), "Sender not Authorized"))
+}
+
+func submatch(s string, expr string) string {
+	m := regexp.MustCompile(expr).FindStringSubmatch(s)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
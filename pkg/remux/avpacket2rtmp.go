@@ -13,6 +13,7 @@ import (
 	"github.com/q191201771/lal/pkg/avc"
 	"github.com/q191201771/lal/pkg/base"
 	"github.com/q191201771/lal/pkg/hevc"
+	"github.com/q191201771/lal/pkg/mpa"
 	"github.com/q191201771/lal/pkg/rtmp"
 	"github.com/q191201771/lal/pkg/rtprtcp"
 	"github.com/q191201771/lal/pkg/sdp"
@@ -334,6 +335,19 @@ func (r *AvPacket2RtmpRemuxer) FeedAvPacket(pkt base.AvPacket) {
 		copy(payload[1:], pkt.Payload)
 		r.emitRtmpAvMsg(true, payload, pkt.Timestamp)
 
+	case base.AvPacketPtMp2:
+		// mpa帧自带帧头，SoundRate等信息从帧头中获取
+		h, err := mpa.ParseFrameHeader(pkt.Payload)
+		if err != nil {
+			Log.Errorf("parse mpa frame header failed. err=%+v", err)
+			return
+		}
+		length := len(pkt.Payload) + 1
+		payload := make([]byte, length)
+		payload[0] = h.PackRtmpSoundHeader()
+		copy(payload[1:], pkt.Payload)
+		r.emitRtmpAvMsg(true, payload, pkt.Timestamp)
+
 	default:
 		Log.Warnf("unsupported packet. type=%d", pkt.PayloadType)
 	}
@@ -15,4 +15,5 @@ var _ iRtmp2MpegtsFilterObserver = &Rtmp2MpegtsRemuxer{}
 const (
 	pcmDefaultSampleRate  = 8000
 	opusDefaultSampleRate = 48000
+	mpaRtpClockRate       = 90000
 )
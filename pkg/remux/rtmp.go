@@ -39,6 +39,7 @@ func MakeDefaultRtmpHeader(in base.RtmpHeader) (out base.RtmpHeader) {
 // LazyRtmpChunkDivider 在必要时，有且仅有一次做切分成chunk的操作
 type LazyRtmpChunkDivider struct {
 	msg              base.RtmpMsg
+	chunkSize        int
 	chunksWithSdf    []byte
 	chunksWithoutSdf []byte
 }
@@ -47,6 +48,18 @@ func (lcd *LazyRtmpChunkDivider) Init(msg base.RtmpMsg) {
 	lcd.msg = msg
 }
 
+// SetChunkSize 切割chunk时使用的chunk size，需要和接收数据的session发送给对端的值一致。不设置时使用 rtmp.LocalChunkSize
+func (lcd *LazyRtmpChunkDivider) SetChunkSize(chunkSize int) {
+	lcd.chunkSize = chunkSize
+}
+
+func (lcd *LazyRtmpChunkDivider) message2Chunks(message []byte, header *base.RtmpHeader) []byte {
+	if lcd.chunkSize == 0 {
+		return rtmp.Message2Chunks(message, header)
+	}
+	return rtmp.Message2ChunksWithSize(message, header, lcd.chunkSize)
+}
+
 func (lcd *LazyRtmpChunkDivider) GetEnsureWithSdf() []byte {
 	if lcd.chunksWithSdf == nil {
 		var msg []byte
@@ -55,11 +68,11 @@ func (lcd *LazyRtmpChunkDivider) GetEnsureWithSdf() []byte {
 			msg2.Payload, _ = rtmp.MetadataEnsureWithSdf(msg2.Payload)
 			msg2.Header.MsgLen = uint32(len(msg2.Payload))
 			msg2.Header = MakeDefaultRtmpHeader(msg2.Header)
-			lcd.chunksWithSdf = rtmp.Message2Chunks(msg2.Payload, &msg2.Header)
+			lcd.chunksWithSdf = lcd.message2Chunks(msg2.Payload, &msg2.Header)
 		} else {
 			msg = lcd.msg.Payload
 			h := MakeDefaultRtmpHeader(lcd.msg.Header)
-			lcd.chunksWithSdf = rtmp.Message2Chunks(msg, &h)
+			lcd.chunksWithSdf = lcd.message2Chunks(msg, &h)
 		}
 	}
 	return lcd.chunksWithSdf
@@ -73,11 +86,11 @@ func (lcd *LazyRtmpChunkDivider) GetEnsureWithoutSdf() []byte {
 			msg2.Payload, _ = rtmp.MetadataEnsureWithoutSdf(msg2.Payload)
 			msg2.Header.MsgLen = uint32(len(msg2.Payload))
 			msg2.Header = MakeDefaultRtmpHeader(msg2.Header)
-			lcd.chunksWithoutSdf = rtmp.Message2Chunks(msg2.Payload, &msg2.Header)
+			lcd.chunksWithoutSdf = lcd.message2Chunks(msg2.Payload, &msg2.Header)
 		} else {
 			msg = lcd.msg.Payload
 			h := MakeDefaultRtmpHeader(lcd.msg.Header)
-			lcd.chunksWithoutSdf = rtmp.Message2Chunks(msg, &h)
+			lcd.chunksWithoutSdf = lcd.message2Chunks(msg, &h)
 		}
 	}
 	return lcd.chunksWithoutSdf
@@ -9,22 +9,24 @@
 package remux
 
 import (
+	"github.com/q191201771/lal/pkg/aac"
 	"github.com/q191201771/lal/pkg/base"
 	"github.com/q191201771/lal/pkg/h2645"
 )
 
 // TODO(chef): 该文件处于开发阶段，请不要直接使用
-// TODO(chef): 支持音频 202206
 
 // Rtmp2AvPacketRemuxer
 //
 // 用途：
 // - 将rtmp流中的视频转换成ffmpeg可解码的格式
+// - 将rtmp流中的音频转换成携带adts头的AAC，或者G711裸数据
 type Rtmp2AvPacketRemuxer struct {
 	option     Rtmp2AvPacketRemuxerOption
 	onAvPacket func(pkt base.AvPacket, arg interface{})
 
 	spspps []byte // annexb格式
+	ascCtx *aac.AscContext
 }
 
 type Rtmp2AvPacketRemuxerOption struct {
@@ -62,6 +64,8 @@ func (r *Rtmp2AvPacketRemuxer) FeedRtmpMsg(msg base.RtmpMsg, arg interface{}) er
 	switch msg.Header.MsgTypeId {
 	case base.RtmpTypeIdVideo:
 		return r.feedVideo(msg, arg)
+	case base.RtmpTypeIdAudio:
+		return r.feedAudio(msg, arg)
 	}
 	return nil
 }
@@ -173,6 +177,47 @@ func (r *Rtmp2AvPacketRemuxer) feedVideo(msg base.RtmpMsg, arg interface{}) erro
 	return err
 }
 
+func (r *Rtmp2AvPacketRemuxer) feedAudio(msg base.RtmpMsg, arg interface{}) error {
+	if len(msg.Payload) <= 2 {
+		return nil
+	}
+
+	pkt := base.AvPacket{
+		Timestamp: int64(msg.Header.TimestampAbs),
+		Pts:       int64(msg.Header.TimestampAbs),
+	}
+
+	switch msg.AudioCodecId() {
+	case base.RtmpSoundFormatAac:
+		var err error
+		if msg.IsAacSeqHeader() {
+			r.ascCtx, err = aac.NewAscContext(msg.Payload[2:])
+			return err
+		}
+		if r.ascCtx == nil {
+			return nil
+		}
+		raw := msg.Payload[2:]
+		pkt.PayloadType = base.AvPacketPtAac
+		pkt.Payload = make([]byte, aac.AdtsHeaderLength+len(raw))
+		if err = r.ascCtx.PackToAdtsHeader(pkt.Payload, len(raw)); err != nil {
+			return err
+		}
+		copy(pkt.Payload[aac.AdtsHeaderLength:], raw)
+	case base.RtmpSoundFormatG711A:
+		pkt.PayloadType = base.AvPacketPtG711A
+		pkt.Payload = append([]byte(nil), msg.Payload[1:]...)
+	case base.RtmpSoundFormatG711U:
+		pkt.PayloadType = base.AvPacketPtG711U
+		pkt.Payload = append([]byte(nil), msg.Payload[1:]...)
+	default:
+		return nil
+	}
+
+	r.onAvPacket(pkt, arg)
+	return nil
+}
+
 // ---------------------------------------------------------------------------------------------------------------------
 
 func defaultOnAvPacket(pkt base.AvPacket, arg interface{}) {
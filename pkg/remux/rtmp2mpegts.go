@@ -177,7 +177,7 @@ func (s *Rtmp2MpegtsRemuxer) onPatPmt(b []byte) {
 func (s *Rtmp2MpegtsRemuxer) onPop(msg base.RtmpMsg) {
 	switch msg.Header.MsgTypeId {
 	case base.RtmpTypeIdAudio:
-		if msg.AudioCodecId() != base.RtmpSoundFormatAac && msg.AudioCodecId() != base.RtmpSoundFormatOpus {
+		if msg.AudioCodecId() != base.RtmpSoundFormatAac && msg.AudioCodecId() != base.RtmpSoundFormatOpus && !msg.IsMp3() {
 			return
 		}
 		s.feedAudio(msg)
@@ -420,6 +420,17 @@ func (s *Rtmp2MpegtsRemuxer) feedAudio(msg base.RtmpMsg) {
 		adtsHeader := s.ascCtx.PackAdtsHeader(int(msg.Header.MsgLen - 2))
 		s.audioCacheFrames = append(s.audioCacheFrames, adtsHeader...)
 		s.audioCacheFrames = append(s.audioCacheFrames, msg.Payload[2:]...)
+	} else if msg.IsMp3() {
+		// mpa帧自带帧头，可以直接作为PES的负载，和aac一样做合并缓存
+		if !s.audioCacheEmpty() && s.audioCacheFirstFramePts+maxAudioCacheDelayByAudio < pts {
+			s.FlushAudio()
+		}
+
+		if s.audioCacheEmpty() {
+			s.audioCacheFirstFramePts = pts
+		}
+
+		s.audioCacheFrames = append(s.audioCacheFrames, msg.Payload[1:]...)
 	} else {
 		s.audioCacheFirstFramePts = pts
 		s.audioCacheFrames = append(s.audioCacheFrames, msg.Payload[1:]...)
@@ -10,6 +10,7 @@ package remux
 
 import (
 	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/lal/pkg/mpa"
 	"github.com/q191201771/lal/pkg/mpegts"
 )
 
@@ -25,9 +26,10 @@ type rtmp2MpegtsFilter struct {
 	data       []base.RtmpMsg
 	observer   iRtmp2MpegtsFilterObserver
 
-	audioCodecId int
-	videoCodecId int
-	done         bool
+	audioCodecId    int
+	audioStreamType uint8 // mp3需要通过帧头区分MPEG 1和MPEG 2，其他情况为 mpegts.StreamTypeUnknown
+	videoCodecId    int
+	done            bool
 }
 
 type iRtmp2MpegtsFilterObserver interface {
@@ -49,12 +51,13 @@ type iRtmp2MpegtsFilterObserver interface {
 // @param maxMsgSize: 最大缓存多少个包
 func newRtmp2MpegtsFilter(maxMsgSize int, observer iRtmp2MpegtsFilterObserver) *rtmp2MpegtsFilter {
 	return &rtmp2MpegtsFilter{
-		maxMsgSize:   maxMsgSize,
-		data:         make([]base.RtmpMsg, maxMsgSize)[0:0],
-		observer:     observer,
-		audioCodecId: -1,
-		videoCodecId: -1,
-		done:         false,
+		maxMsgSize:      maxMsgSize,
+		data:            make([]base.RtmpMsg, maxMsgSize)[0:0],
+		observer:        observer,
+		audioCodecId:    -1,
+		audioStreamType: mpegts.StreamTypeUnknown,
+		videoCodecId:    -1,
+		done:            false,
 	}
 }
 
@@ -72,6 +75,15 @@ func (q *rtmp2MpegtsFilter) Push(msg base.RtmpMsg) {
 	switch msg.Header.MsgTypeId {
 	case base.RtmpTypeIdAudio:
 		q.audioCodecId = int(msg.Payload[0] >> 4)
+		if msg.IsMp3() {
+			if h, err := mpa.ParseFrameHeader(msg.Payload[1:]); err == nil {
+				if h.IsLsf() {
+					q.audioStreamType = mpegts.StreamTypeMpeg2Audio
+				} else {
+					q.audioStreamType = mpegts.StreamTypeMpeg1Audio
+				}
+			}
+		}
 	case base.RtmpTypeIdVideo:
 		q.videoCodecId = int(msg.VideoCodecId())
 	}
@@ -91,7 +103,7 @@ func (q *rtmp2MpegtsFilter) Push(msg base.RtmpMsg) {
 
 func (q *rtmp2MpegtsFilter) drain() {
 	patpmt := mpegts.PackPat()
-	patpmt = append(patpmt, mpegts.PackPmt(q.videoCodecId, q.audioCodecId)...)
+	patpmt = append(patpmt, mpegts.PackPmtWithAudioStreamType(q.videoCodecId, q.audioCodecId, q.audioStreamType)...)
 	q.observer.onPatPmt(patpmt)
 
 	for i := range q.data {
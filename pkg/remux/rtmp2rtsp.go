@@ -49,20 +49,39 @@ type Rtmp2RtspRemuxer struct {
 	videoSsrc   uint32
 	audioPacker *rtprtcp.RtpPacker
 	videoPacker *rtprtcp.RtpPacker
+
+	option   Rtmp2RtspRemuxerOption
+	aacCache []base.AvPacket // 等待合并打包的aac帧，见 Rtmp2RtspRemuxerOption.AacAggregationNum
+}
+
+type Rtmp2RtspRemuxerOption struct {
+	// AacLatm 为true时aac使用rfc3016 MP4A-LATM格式，否则使用rfc3640 MPEG4-GENERIC格式
+	AacLatm bool
+
+	// AacAggregationNum 每多少帧aac合并打包成rtp包（超过rtp包大小时会拆成多个rtp包），为0或1时不合并
+	// 注意，合并会增加音频的延时
+	AacAggregationNum int
 }
 
+type ModRtmp2RtspRemuxerOption func(option *Rtmp2RtspRemuxerOption)
+
 type OnSdp func(sdpCtx sdp.LogicContext)
 type OnRtpPacket func(pkt rtprtcp.RtpPacket)
 
 // NewRtmp2RtspRemuxer @param onSdp:       每次回调为独立的内存块，回调结束后，内部不再使用该内存块
 // @param onRtpPacket: 每次回调为独立的内存块，回调结束后，内部不再使用该内存块
-func NewRtmp2RtspRemuxer(onSdp OnSdp, onRtpPacket OnRtpPacket) *Rtmp2RtspRemuxer {
+func NewRtmp2RtspRemuxer(onSdp OnSdp, onRtpPacket OnRtpPacket, modOptions ...ModRtmp2RtspRemuxerOption) *Rtmp2RtspRemuxer {
+	var option Rtmp2RtspRemuxerOption
+	for _, fn := range modOptions {
+		fn(&option)
+	}
 	return &Rtmp2RtspRemuxer{
 		onSdp:           onSdp,
 		onRtpPacket:     onRtpPacket,
 		audioPt:         base.AvPacketPtUnknown,
 		videoPt:         base.AvPacketPtUnknown,
 		audioSampleRate: -1,
+		option:          option,
 	}
 }
 
@@ -81,6 +100,8 @@ func (r *Rtmp2RtspRemuxer) FeedRtmpMsg(msg base.RtmpMsg) {
 					r.audioPt = base.AvPacketPtG711A
 				case base.RtmpSoundFormatOpus:
 					r.audioPt = base.AvPacketPtOpus
+				case base.RtmpSoundFormatMp3, base.RtmpSoundFormatMp38k:
+					r.audioPt = base.AvPacketPtMp2
 				}
 			}
 			if samplerate, ok := meta.Find("audiosamplerate").(float64); ok {
@@ -110,6 +131,8 @@ func (r *Rtmp2RtspRemuxer) FeedRtmpMsg(msg base.RtmpMsg) {
 				if r.audioSampleRate < 0 {
 					r.audioSampleRate = opusDefaultSampleRate
 				}
+			case base.RtmpSoundFormatMp3, base.RtmpSoundFormatMp38k:
+				r.audioPt = base.AvPacketPtMp2
 			}
 		}
 	case base.RtmpTypeIdVideo:
@@ -205,6 +228,7 @@ func (r *Rtmp2RtspRemuxer) doAnalyze() {
 			AudioPt:           r.audioPt,
 			Asc:               r.asc,
 			SamplingFrequency: r.audioSampleRate,
+			AacLatm:           r.option.AacLatm,
 		}
 		ctx, err := sdp.Pack(videoInfo, audioInfo)
 		Log.Assert(nil, err)
@@ -243,12 +267,23 @@ func (r *Rtmp2RtspRemuxer) remux(msg base.RtmpMsg) {
 	case base.RtmpTypeIdAudio:
 		packer = r.getAudioPacker()
 		if packer != nil {
-			if msg.AudioCodecId() == base.RtmpSoundFormatG711A || msg.AudioCodecId() == base.RtmpSoundFormatG711U || msg.AudioCodecId() == base.RtmpSoundFormatOpus {
+			if msg.AudioCodecId() == base.RtmpSoundFormatG711A || msg.AudioCodecId() == base.RtmpSoundFormatG711U || msg.AudioCodecId() == base.RtmpSoundFormatOpus || msg.IsMp3() {
 				rtppkts = packer.Pack(base.AvPacket{
 					Timestamp:   int64(msg.Header.TimestampAbs),
 					PayloadType: r.audioPt,
 					Payload:     msg.Payload[1:],
 				})
+			} else if r.option.AacAggregationNum > 1 {
+				// 函数调用结束后不能持有msg的内存块，所以拷贝
+				r.aacCache = append(r.aacCache, base.AvPacket{
+					Timestamp:   int64(msg.Header.TimestampAbs),
+					PayloadType: r.audioPt,
+					Payload:     append([]byte(nil), msg.Payload[2:]...),
+				})
+				if len(r.aacCache) >= r.option.AacAggregationNum {
+					rtppkts = packer.PackAggregation(r.aacCache)
+					r.aacCache = nil
+				}
 			} else {
 				rtppkts = packer.Pack(base.AvPacket{
 					Timestamp:   int64(msg.Header.TimestampAbs),
@@ -304,6 +339,10 @@ func (r *Rtmp2RtspRemuxer) getAudioPacker() *rtprtcp.RtpPacker {
 		case base.AvPacketPtOpus:
 			pp := rtprtcp.NewRtpPackerPayloadOpus()
 			r.audioPacker = rtprtcp.NewRtpPacker(pp, r.audioSampleRate, r.audioSsrc)
+		case base.AvPacketPtMp2:
+			// rfc2250 mpa的rtp时间戳的时钟频率固定为90000
+			pp := rtprtcp.NewRtpPackerPayloadMpa()
+			r.audioPacker = rtprtcp.NewRtpPacker(pp, mpaRtpClockRate, r.audioSsrc)
 		case base.AvPacketPtAac:
 			if r.asc == nil {
 				return nil
@@ -319,7 +358,9 @@ func (r *Rtmp2RtspRemuxer) getAudioPacker() *rtprtcp.RtpPacker {
 				Log.Errorf("get sampling frequency failed. err=%+v, asc=%s", err, hex.Dump(r.asc))
 			}
 
-			pp := rtprtcp.NewRtpPackerPayloadAac()
+			pp := rtprtcp.NewRtpPackerPayloadAac(func(option *rtprtcp.RtpPackerPayloadAacOption) {
+				option.Latm = r.option.AacLatm
+			})
 			r.audioPacker = rtprtcp.NewRtpPacker(pp, clockRate, r.audioSsrc)
 		}
 	}
@@ -39,6 +39,9 @@ const (
 	Amf0TypeMarkerLongString  = uint8(0x0c)
 	Amf0TypeMarkerUnsupported = uint8(0x0d)
 
+	// Amf0TypeMarkerAvmplusObject 表示之后的一个值使用amf3编码，见 Amf3
+	Amf0TypeMarkerAvmplusObject = uint8(0x11)
+
 	// 还没用到的类型
 	//Amf0TypeMarkerMovieclip   = uint8(0x04)
 	//Amf0TypeMarkerReference   = uint8(0x07)
@@ -183,6 +186,10 @@ func (amf0) WriteObject(writer io.Writer, opa ObjectPairArray) error {
 			if err := Amf0.WriteBoolean(writer, opa[i].Value.(bool)); err != nil {
 				return err
 			}
+		case ObjectPairArray:
+			if err := Amf0.WriteObject(writer, opa[i].Value.(ObjectPairArray)); err != nil {
+				return err
+			}
 		default:
 			Log.Panicf("unknown value type. i=%d, v=%+v", i, opa[i].Value)
 		}
@@ -443,6 +450,16 @@ func (amf0) read(b []byte, index int, k string, ops ObjectPairArray) (ObjectPair
 			return nil, 0, err
 		}
 		index += l
+	case Amf0TypeMarkerAvmplusObject:
+		v, l, err := Amf3.ReadValue(b[index+1:])
+		if err != nil {
+			return nil, 0, err
+		}
+		// 和amf0保持一致，null、undefined不加入
+		if v != nil {
+			ops = append(ops, ObjectPair{k, v})
+		}
+		index += 1 + l
 	default:
 		Log.Errorf("unknown type. vt=%d, hex=%s, %s", vt, hex.Dump(nazabytes.Prefix(b, 4096)), hex.Dump(nazabytes.Prefix(b[index:], 4096)))
 		return ops, index, base.NewErrAmfInvalidType(vt)
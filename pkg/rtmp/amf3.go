@@ -0,0 +1,714 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtmp
+
+// amf3.go
+// @pure
+// 提供amf3格式的编码与解码的操作
+//
+// 解码得到的值的类型：
+// - Undefined、Null: nil
+// - False、True: bool
+// - Integer、Double、Date: float64，和amf0的Number保持一致，Date为距离1970的毫秒数
+// - String、XmlDocument、Xml: string
+// - ByteArray: []byte
+// - Object、Array: ObjectPairArray，Array的dense部分的Key为""
+//
+// 解码时引用会被展开（多处引用同一个对象时，得到的是同一个值），为了防止恶意数据通过引用构造出指数级膨胀的结果，
+// 或者通过深度嵌套耗尽栈空间，解码时限制嵌套深度以及展开后的节点数量，见 amf3MaxDepth 和 amf3MaxNodes
+
+import (
+	"bytes"
+	"io"
+	"math"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/naza/pkg/bele"
+	"github.com/q191201771/naza/pkg/nazaerrors"
+)
+
+const (
+	Amf3TypeMarkerUndefined   = uint8(0x00)
+	Amf3TypeMarkerNull        = uint8(0x01)
+	Amf3TypeMarkerFalse       = uint8(0x02)
+	Amf3TypeMarkerTrue        = uint8(0x03)
+	Amf3TypeMarkerInteger     = uint8(0x04)
+	Amf3TypeMarkerDouble      = uint8(0x05)
+	Amf3TypeMarkerString      = uint8(0x06)
+	Amf3TypeMarkerXmlDocument = uint8(0x07)
+	Amf3TypeMarkerDate        = uint8(0x08)
+	Amf3TypeMarkerArray       = uint8(0x09)
+	Amf3TypeMarkerObject      = uint8(0x0a)
+	Amf3TypeMarkerXml         = uint8(0x0b)
+	Amf3TypeMarkerByteArray   = uint8(0x0c)
+
+	// 还没用到的类型
+	//Amf3TypeMarkerVectorInt    = uint8(0x0d)
+	//Amf3TypeMarkerVectorUint   = uint8(0x0e)
+	//Amf3TypeMarkerVectorDouble = uint8(0x0f)
+	//Amf3TypeMarkerVectorObject = uint8(0x10)
+	//Amf3TypeMarkerDictionary   = uint8(0x11)
+)
+
+const (
+	amf3IntegerMin = -(1 << 28)
+	amf3IntegerMax = 1<<28 - 1
+
+	amf3U29Max = 1<<29 - 1
+
+	// amf3MaxDepth 解码时Object、Array的最大嵌套深度
+	amf3MaxDepth = 64
+
+	// amf3MaxNodes 解码时展开引用后的最大节点数量，每个值计为一个节点，字符串、ByteArray额外按每 amf3NodeBytes 字节计为一个节点
+	amf3MaxNodes  = 1 << 16
+	amf3NodeBytes = 64
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+type amf3 struct{}
+
+var Amf3 amf3
+
+// WriteU29 写入amf3的变长整型U29，不包含类型
+func (amf3) WriteU29(writer io.Writer, val uint32) error {
+	val &= amf3U29Max
+	var b []byte
+	switch {
+	case val < 0x80:
+		b = []byte{byte(val)}
+	case val < 0x4000:
+		b = []byte{byte(val>>7) | 0x80, byte(val & 0x7f)}
+	case val < 0x200000:
+		b = []byte{byte(val>>14) | 0x80, byte(val>>7) | 0x80, byte(val & 0x7f)}
+	default:
+		b = []byte{byte(val>>22) | 0x80, byte(val>>15) | 0x80, byte(val>>8) | 0x80, byte(val)}
+	}
+	_, err := writer.Write(b)
+	return err
+}
+
+func (amf3) WriteUndefined(writer io.Writer) error {
+	_, err := writer.Write([]byte{Amf3TypeMarkerUndefined})
+	return err
+}
+
+func (amf3) WriteNull(writer io.Writer) error {
+	_, err := writer.Write([]byte{Amf3TypeMarkerNull})
+	return err
+}
+
+func (amf3) WriteBoolean(writer io.Writer, b bool) error {
+	v := Amf3TypeMarkerFalse
+	if b {
+		v = Amf3TypeMarkerTrue
+	}
+	_, err := writer.Write([]byte{v})
+	return err
+}
+
+// WriteInteger 超出29位有符号整型范围时，使用Double写入
+func (amf3) WriteInteger(writer io.Writer, val int) error {
+	if val < amf3IntegerMin || val > amf3IntegerMax {
+		return Amf3.WriteDouble(writer, float64(val))
+	}
+	if _, err := writer.Write([]byte{Amf3TypeMarkerInteger}); err != nil {
+		return err
+	}
+	return Amf3.WriteU29(writer, uint32(val))
+}
+
+func (amf3) WriteDouble(writer io.Writer, val float64) error {
+	if _, err := writer.Write([]byte{Amf3TypeMarkerDouble}); err != nil {
+		return err
+	}
+	return bele.WriteBe(writer, val)
+}
+
+func (amf3) WriteString(writer io.Writer, val string) error {
+	if _, err := writer.Write([]byte{Amf3TypeMarkerString}); err != nil {
+		return err
+	}
+	return Amf3.writeStringWithoutType(writer, val)
+}
+
+func (amf3) WriteByteArray(writer io.Writer, val []byte) error {
+	if _, err := writer.Write([]byte{Amf3TypeMarkerByteArray}); err != nil {
+		return err
+	}
+	if err := Amf3.WriteU29(writer, uint32(len(val))<<1|1); err != nil {
+		return err
+	}
+	_, err := writer.Write(val)
+	return err
+}
+
+// WriteObject 写入匿名的动态对象，也即没有类名和sealed成员，所有成员都是动态成员
+func (amf3) WriteObject(writer io.Writer, opa ObjectPairArray) error {
+	// U29O-traits: traits内联，dynamic，sealed成员个数为0
+	if _, err := writer.Write([]byte{Amf3TypeMarkerObject, 0x0b}); err != nil {
+		return err
+	}
+	// 类名为空
+	if err := Amf3.writeStringWithoutType(writer, ""); err != nil {
+		return err
+	}
+	for _, op := range opa {
+		if op.Key == "" {
+			continue
+		}
+		if err := Amf3.writeStringWithoutType(writer, op.Key); err != nil {
+			return err
+		}
+		if err := Amf3.WriteValue(writer, op.Value); err != nil {
+			return err
+		}
+	}
+	return Amf3.writeStringWithoutType(writer, "")
+}
+
+// WriteArray Key为""的成员写入dense部分，其他写入associative部分
+func (amf3) WriteArray(writer io.Writer, opa ObjectPairArray) error {
+	var dense []interface{}
+	for _, op := range opa {
+		if op.Key == "" {
+			dense = append(dense, op.Value)
+		}
+	}
+
+	if _, err := writer.Write([]byte{Amf3TypeMarkerArray}); err != nil {
+		return err
+	}
+	if err := Amf3.WriteU29(writer, uint32(len(dense))<<1|1); err != nil {
+		return err
+	}
+	for _, op := range opa {
+		if op.Key == "" {
+			continue
+		}
+		if err := Amf3.writeStringWithoutType(writer, op.Key); err != nil {
+			return err
+		}
+		if err := Amf3.WriteValue(writer, op.Value); err != nil {
+			return err
+		}
+	}
+	if err := Amf3.writeStringWithoutType(writer, ""); err != nil {
+		return err
+	}
+	for _, v := range dense {
+		if err := Amf3.WriteValue(writer, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteValue 根据 val 的类型写入，ObjectPairArray按Object写入
+func (amf3) WriteValue(writer io.Writer, val interface{}) error {
+	switch v := val.(type) {
+	case nil:
+		return Amf3.WriteNull(writer)
+	case bool:
+		return Amf3.WriteBoolean(writer, v)
+	case int:
+		return Amf3.WriteInteger(writer, v)
+	case float64:
+		return Amf3.WriteDouble(writer, v)
+	case string:
+		return Amf3.WriteString(writer, v)
+	case []byte:
+		return Amf3.WriteByteArray(writer, v)
+	case ObjectPairArray:
+		return Amf3.WriteObject(writer, v)
+	}
+	return nazaerrors.Wrap(base.ErrAmfInvalidType)
+}
+
+func (amf3) writeStringWithoutType(writer io.Writer, val string) error {
+	// 写入时不使用引用表
+	if err := Amf3.WriteU29(writer, uint32(len(val))<<1|1); err != nil {
+		return err
+	}
+	_, err := writer.Write([]byte(val))
+	return err
+}
+
+// ----------------------------------------------------------------------------
+
+// ReadValue 从 b 中读取一个amf3的值，返回值的类型见文件头部的说明
+//
+// 注意，每次调用使用独立的引用表，这和amf0中通过avmplus-object-marker切换至amf3的场景一致
+//
+// @return int: 读取时从 b 消耗的字节大小
+func (amf3) ReadValue(b []byte) (interface{}, int, error) {
+	d := amf3Decoder{b: b}
+	v, err := d.readValue()
+	if err != nil {
+		return nil, 0, err
+	}
+	return v, d.index, nil
+}
+
+// ReadU29 读取amf3的变长整型U29
+func (amf3) ReadU29(b []byte) (uint32, int, error) {
+	var val uint32
+	for i := 0; i < 4; i++ {
+		if i >= len(b) {
+			return 0, 0, nazaerrors.Wrap(base.ErrAmfTooShort)
+		}
+		if i == 3 {
+			return val<<8 | uint32(b[i]), 4, nil
+		}
+		val = val<<7 | uint32(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return val, i + 1, nil
+		}
+	}
+	// 不会执行到这里
+	return val, 4, nil
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+type amf3Traits struct {
+	className   string
+	dynamic     bool
+	sealedNames []string
+}
+
+type amf3Decoder struct {
+	b     []byte
+	index int
+
+	// 引用表
+	strs     []string
+	objs     []interface{}
+	objNodes []int // objs中每个值展开后的节点数量，-1表示还在解码中
+	traits   []amf3Traits
+
+	depth int
+	nodes int
+}
+
+// addNodes 累加展开后的节点数量，超过 amf3MaxNodes 时返回错误
+func (d *amf3Decoder) addNodes(n int) error {
+	d.nodes += n
+	if d.nodes > amf3MaxNodes {
+		return nazaerrors.Wrap(base.ErrAmfTooComplex)
+	}
+	return nil
+}
+
+func (d *amf3Decoder) addObj(v interface{}, nodes int) {
+	d.objs = append(d.objs, v)
+	d.objNodes = append(d.objNodes, nodes)
+}
+
+func (d *amf3Decoder) readValue() (interface{}, error) {
+	if len(d.b)-d.index < 1 {
+		return nil, nazaerrors.Wrap(base.ErrAmfTooShort)
+	}
+	marker := d.b[d.index]
+	d.index++
+
+	if err := d.addNodes(1); err != nil {
+		return nil, err
+	}
+
+	switch marker {
+	case Amf3TypeMarkerUndefined, Amf3TypeMarkerNull:
+		return nil, nil
+	case Amf3TypeMarkerFalse:
+		return false, nil
+	case Amf3TypeMarkerTrue:
+		return true, nil
+	case Amf3TypeMarkerInteger:
+		u29, err := d.readU29()
+		if err != nil {
+			return nil, err
+		}
+		// 29位有符号整型
+		v := int32(u29<<3) >> 3
+		return float64(v), nil
+	case Amf3TypeMarkerDouble:
+		return d.readDouble()
+	case Amf3TypeMarkerString:
+		return d.readString()
+	case Amf3TypeMarkerXmlDocument, Amf3TypeMarkerXml:
+		return d.readXml()
+	case Amf3TypeMarkerDate:
+		return d.readDate()
+	case Amf3TypeMarkerArray:
+		return d.readArray()
+	case Amf3TypeMarkerObject:
+		return d.readObject()
+	case Amf3TypeMarkerByteArray:
+		return d.readByteArray()
+	}
+	return nil, base.NewErrAmfInvalidType(marker)
+}
+
+func (d *amf3Decoder) readU29() (uint32, error) {
+	v, l, err := Amf3.ReadU29(d.b[d.index:])
+	if err != nil {
+		return 0, err
+	}
+	d.index += l
+	return v, nil
+}
+
+func (d *amf3Decoder) readDouble() (float64, error) {
+	if len(d.b)-d.index < 8 {
+		return 0, nazaerrors.Wrap(base.ErrAmfTooShort)
+	}
+	v := math.Float64frombits(bele.BeUint64(d.b[d.index:]))
+	d.index += 8
+	return v, nil
+}
+
+// readRef 读取U29，最低位为0时表示引用
+//
+// @return ref: 是否为引用
+// @return val: ref为true时为引用表的下标，否则为去除最低位后的值
+func (d *amf3Decoder) readRef() (ref bool, val uint32, err error) {
+	u29, err := d.readU29()
+	if err != nil {
+		return false, 0, err
+	}
+	return u29&1 == 0, u29 >> 1, nil
+}
+
+func (d *amf3Decoder) readBytes(n int) ([]byte, error) {
+	if len(d.b)-d.index < n {
+		return nil, nazaerrors.Wrap(base.ErrAmfTooShort)
+	}
+	v := d.b[d.index : d.index+n]
+	d.index += n
+	return v, nil
+}
+
+// getObj 获取引用的对象，引用的对象展开后的节点数量计入总数，引用还在解码中的对象（也即循环引用）时返回错误
+func (d *amf3Decoder) getObj(index uint32) (interface{}, error) {
+	if int(index) >= len(d.objs) || d.objNodes[index] < 0 {
+		return nil, nazaerrors.Wrap(base.ErrAmfInvalidReference)
+	}
+	// 引用本身已经计为一个节点
+	if err := d.addNodes(d.objNodes[index] - 1); err != nil {
+		return nil, err
+	}
+	return d.objs[index], nil
+}
+
+// beginContainer 开始解码Object或Array，在引用表中占位，成员中引用自身时视为非法引用
+//
+// @return startNodes: 开始解码时的节点数量，用于结束时计算容器展开后的节点数量
+func (d *amf3Decoder) beginContainer() (objIndex int, startNodes int, err error) {
+	d.depth++
+	if d.depth > amf3MaxDepth {
+		return 0, 0, nazaerrors.Wrap(base.ErrAmfTooComplex)
+	}
+	objIndex = len(d.objs)
+	d.addObj(nil, -1)
+	return objIndex, d.nodes, nil
+}
+
+func (d *amf3Decoder) endContainer(objIndex int, startNodes int, ops ObjectPairArray) {
+	d.depth--
+	d.objs[objIndex] = ops
+	// 容器自身的一个节点在readValue中计入，在startNodes之前
+	d.objNodes[objIndex] = d.nodes - startNodes + 1
+}
+
+func (d *amf3Decoder) readString() (string, error) {
+	ref, val, err := d.readRef()
+	if err != nil {
+		return "", err
+	}
+	if ref {
+		if int(val) >= len(d.strs) {
+			return "", nazaerrors.Wrap(base.ErrAmfInvalidReference)
+		}
+		return d.strs[val], d.addNodes(len(d.strs[val]) / amf3NodeBytes)
+	}
+	b, err := d.readBytes(int(val))
+	if err != nil {
+		return "", err
+	}
+	if err = d.addNodes(len(b) / amf3NodeBytes); err != nil {
+		return "", err
+	}
+	s := string(b)
+	// 空字符串不加入引用表
+	if s != "" {
+		d.strs = append(d.strs, s)
+	}
+	return s, nil
+}
+
+func (d *amf3Decoder) readXml() (interface{}, error) {
+	ref, val, err := d.readRef()
+	if err != nil {
+		return nil, err
+	}
+	if ref {
+		return d.getObj(val)
+	}
+	b, err := d.readBytes(int(val))
+	if err != nil {
+		return nil, err
+	}
+	nodes := 1 + len(b)/amf3NodeBytes
+	if err = d.addNodes(nodes - 1); err != nil {
+		return nil, err
+	}
+	s := string(b)
+	d.addObj(s, nodes)
+	return s, nil
+}
+
+func (d *amf3Decoder) readDate() (interface{}, error) {
+	ref, val, err := d.readRef()
+	if err != nil {
+		return nil, err
+	}
+	if ref {
+		return d.getObj(val)
+	}
+	v, err := d.readDouble()
+	if err != nil {
+		return nil, err
+	}
+	d.addObj(v, 1)
+	return v, nil
+}
+
+func (d *amf3Decoder) readByteArray() (interface{}, error) {
+	ref, val, err := d.readRef()
+	if err != nil {
+		return nil, err
+	}
+	if ref {
+		return d.getObj(val)
+	}
+	b, err := d.readBytes(int(val))
+	if err != nil {
+		return nil, err
+	}
+	nodes := 1 + len(b)/amf3NodeBytes
+	if err = d.addNodes(nodes - 1); err != nil {
+		return nil, err
+	}
+	v := append([]byte(nil), b...)
+	d.addObj(v, nodes)
+	return v, nil
+}
+
+func (d *amf3Decoder) readArray() (interface{}, error) {
+	ref, val, err := d.readRef()
+	if err != nil {
+		return nil, err
+	}
+	if ref {
+		return d.getObj(val)
+	}
+
+	objIndex, startNodes, err := d.beginContainer()
+	if err != nil {
+		return nil, err
+	}
+
+	var ops ObjectPairArray
+	for {
+		k, err := d.readString()
+		if err != nil {
+			return nil, err
+		}
+		if k == "" {
+			break
+		}
+		v, err := d.readValue()
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, ObjectPair{k, v})
+	}
+	for i := uint32(0); i < val; i++ {
+		v, err := d.readValue()
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, ObjectPair{"", v})
+	}
+
+	d.endContainer(objIndex, startNodes, ops)
+	return ops, nil
+}
+
+func (d *amf3Decoder) readObject() (interface{}, error) {
+	ref, val, err := d.readRef()
+	if err != nil {
+		return nil, err
+	}
+	if ref {
+		return d.getObj(val)
+	}
+
+	var traits amf3Traits
+	if val&1 == 0 {
+		// traits引用
+		if int(val>>1) >= len(d.traits) {
+			return nil, nazaerrors.Wrap(base.ErrAmfInvalidReference)
+		}
+		traits = d.traits[val>>1]
+	} else {
+		if val&2 != 0 {
+			// externalizable对象的格式由具体的类决定，无法通用解析
+			return nil, base.NewErrAmfInvalidType(Amf3TypeMarkerObject)
+		}
+		traits.dynamic = val&4 != 0
+		traits.className, err = d.readString()
+		if err != nil {
+			return nil, err
+		}
+		for i := uint32(0); i < val>>3; i++ {
+			name, err := d.readString()
+			if err != nil {
+				return nil, err
+			}
+			traits.sealedNames = append(traits.sealedNames, name)
+		}
+		d.traits = append(d.traits, traits)
+	}
+
+	objIndex, startNodes, err := d.beginContainer()
+	if err != nil {
+		return nil, err
+	}
+
+	var ops ObjectPairArray
+	for _, name := range traits.sealedNames {
+		v, err := d.readValue()
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, ObjectPair{name, v})
+	}
+	if traits.dynamic {
+		for {
+			k, err := d.readString()
+			if err != nil {
+				return nil, err
+			}
+			if k == "" {
+				break
+			}
+			v, err := d.readValue()
+			if err != nil {
+				return nil, err
+			}
+			ops = append(ops, ObjectPair{k, v})
+		}
+	}
+
+	d.endContainer(objIndex, startNodes, ops)
+	return ops, nil
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// readAmf0OrAvmplus 读取amf0的值，如果是avmplus-object-marker（amf0中切换为amf3编码），则按amf3读取
+//
+// RtmpTypeIdCommandMessageAmf3、RtmpTypeIdDataMessageAmf3类型的message中会出现这种情况
+func readAmf0OrAvmplus[T any](b []byte, readAmf0 func(b []byte) (T, int, error)) (T, int, error) {
+	if len(b) == 0 || b[0] != Amf0TypeMarkerAvmplusObject {
+		return readAmf0(b)
+	}
+	var zero T
+	v, l, err := Amf3.ReadValue(b[1:])
+	if err != nil {
+		return zero, 0, err
+	}
+	t, ok := v.(T)
+	if !ok {
+		return zero, 0, base.NewErrAmfInvalidType(Amf0TypeMarkerAvmplusObject)
+	}
+	return t, l + 1, nil
+}
+
+// amf3DataMessageToAmf0 将RtmpTypeIdDataMessageAmf3类型的message转换为RtmpTypeIdMetadata（也即amf0）类型的message
+//
+// RtmpTypeIdDataMessageAmf3的第一个字节固定为0，之后为amf0编码，其中的值可能通过avmplus-object-marker切换为amf3编码
+func amf3DataMessageToAmf0(b []byte) ([]byte, error) {
+	if len(b) < 1 {
+		return nil, nazaerrors.Wrap(base.ErrAmfTooShort)
+	}
+	b = b[1:]
+
+	var out bytes.Buffer
+	index := 0
+	for index < len(b) {
+		if b[index] != Amf0TypeMarkerAvmplusObject {
+			_, next, err := Amf0.read(b, index, "", nil)
+			if err != nil {
+				return nil, err
+			}
+			out.Write(b[index:next])
+			index = next
+			continue
+		}
+
+		v, l, err := Amf3.ReadValue(b[index+1:])
+		if err != nil {
+			return nil, err
+		}
+		if err = writeAmf0Value(&out, v); err != nil {
+			return nil, err
+		}
+		index += 1 + l
+	}
+	return out.Bytes(), nil
+}
+
+// writeAmf0Value 将amf3解码得到的值按amf0写入
+func writeAmf0Value(writer io.Writer, val interface{}) error {
+	switch v := val.(type) {
+	case nil:
+		return Amf0.WriteNull(writer)
+	case bool:
+		return Amf0.WriteBoolean(writer, v)
+	case float64:
+		return Amf0.WriteNumber(writer, v)
+	case string:
+		return Amf0.WriteString(writer, v)
+	case ObjectPairArray:
+		if _, err := writer.Write([]byte{Amf0TypeMarkerObject}); err != nil {
+			return err
+		}
+		for _, op := range v {
+			// amf0的object不支持dense成员，忽略
+			if op.Key == "" {
+				continue
+			}
+			if err := bele.WriteBe(writer, uint16(len(op.Key))); err != nil {
+				return err
+			}
+			if _, err := writer.Write([]byte(op.Key)); err != nil {
+				return err
+			}
+			if err := writeAmf0Value(writer, op.Value); err != nil {
+				return err
+			}
+		}
+		_, err := writer.Write(Amf0TypeMarkerObjectEndBytes)
+		return err
+	}
+	// 比如ByteArray，amf0中没有对应的类型
+	return nazaerrors.Wrap(base.ErrAmfInvalidType)
+}
@@ -0,0 +1,201 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtmp
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/naza/pkg/assert"
+)
+
+func TestAmf3_U29(t *testing.T) {
+	cases := []struct {
+		v uint32
+		b []byte
+	}{
+		{0, []byte{0x00}},
+		{0x7f, []byte{0x7f}},
+		{0x80, []byte{0x81, 0x00}},
+		{0x3fff, []byte{0xff, 0x7f}},
+		{0x4000, []byte{0x81, 0x80, 0x00}},
+		{0x1fffff, []byte{0xff, 0xff, 0x7f}},
+		{0x200000, []byte{0x80, 0xc0, 0x80, 0x00}},
+		{0x1fffffff, []byte{0xff, 0xff, 0xff, 0xff}},
+	}
+	for _, item := range cases {
+		out := &bytes.Buffer{}
+		assert.Equal(t, nil, Amf3.WriteU29(out, item.v))
+		assert.Equal(t, item.b, out.Bytes())
+		v, l, err := Amf3.ReadU29(item.b)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, item.v, v)
+		assert.Equal(t, len(item.b), l)
+	}
+
+	_, _, err := Amf3.ReadU29([]byte{0x81})
+	assert.IsNotNil(t, err)
+}
+
+func TestAmf3_WriteValue_ReadValue(t *testing.T) {
+	cases := []interface{}{
+		nil,
+		true,
+		false,
+		float64(0),
+		float64(-1),
+		float64(1 << 28),
+		1.5,
+		"",
+		"abc",
+		[]byte{1, 2, 3},
+		ObjectPairArray{
+			{Key: "app", Value: "live"},
+			{Key: "objectEncoding", Value: float64(3)},
+			{Key: "sub", Value: ObjectPairArray{{Key: "app", Value: "live"}}},
+		},
+	}
+	for _, item := range cases {
+		out := &bytes.Buffer{}
+		assert.Equal(t, nil, Amf3.WriteValue(out, item))
+		v, l, err := Amf3.ReadValue(out.Bytes())
+		assert.Equal(t, nil, err)
+		assert.Equal(t, item, v)
+		assert.Equal(t, out.Len(), l)
+	}
+
+	// int写入时使用Integer，读取时为float64
+	out := &bytes.Buffer{}
+	assert.Equal(t, nil, Amf3.WriteValue(out, -2))
+	assert.Equal(t, []byte{Amf3TypeMarkerInteger, 0xff, 0xff, 0xff, 0xfe}, out.Bytes())
+	v, _, err := Amf3.ReadValue(out.Bytes())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, float64(-2), v)
+
+	out.Reset()
+	assert.Equal(t, nil, Amf3.WriteArray(out, ObjectPairArray{{Key: "", Value: "a"}, {Key: "k", Value: true}, {Key: "", Value: "b"}}))
+	v, _, err = Amf3.ReadValue(out.Bytes())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, ObjectPairArray{{Key: "k", Value: true}, {Key: "", Value: "a"}, {Key: "", Value: "b"}}, v)
+}
+
+func TestAmf3_ReadValue_Reference(t *testing.T) {
+	// 两个对象使用同一个sealed traits，字符串和traits都使用引用
+	b := []byte{
+		Amf3TypeMarkerArray, 0x05, 0x01, // dense部分2个成员，associative部分为空
+		Amf3TypeMarkerObject, 0x13, 0x07, 'F', 'o', 'o', 0x03, 'a', // traits内联，sealed成员1个"a"，类名"Foo"
+		Amf3TypeMarkerString, 0x05, 'h', 'i',
+		Amf3TypeMarkerObject, 0x01, // traits引用0
+		Amf3TypeMarkerString, 0x04, // 字符串引用2，也即"hi"
+	}
+	v, l, err := Amf3.ReadValue(b)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, len(b), l)
+	obj := ObjectPairArray{{Key: "a", Value: "hi"}}
+	assert.Equal(t, ObjectPairArray{{Key: "", Value: obj}, {Key: "", Value: obj}}, v)
+
+	// 对象引用
+	b = []byte{
+		Amf3TypeMarkerArray, 0x05, 0x01,
+		Amf3TypeMarkerByteArray, 0x03, 0x09,
+		Amf3TypeMarkerByteArray, 0x02, // 对象引用1，引用0是外层的数组
+	}
+	v, _, err = Amf3.ReadValue(b)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, ObjectPairArray{{Key: "", Value: []byte{9}}, {Key: "", Value: []byte{9}}}, v)
+
+	// 非法引用
+	_, _, err = Amf3.ReadValue([]byte{Amf3TypeMarkerString, 0x02})
+	assert.IsNotNil(t, err)
+	_, _, err = Amf3.ReadValue([]byte{Amf3TypeMarkerObject, 0x05})
+	assert.IsNotNil(t, err)
+	// 长度不够
+	_, _, err = Amf3.ReadValue([]byte{Amf3TypeMarkerString, 0x07, 'a'})
+	assert.IsNotNil(t, err)
+}
+
+func TestAmf3DataMessageToAmf0(t *testing.T) {
+	// 0 + "@setDataFrame"(amf0) + "onMetaData"(amf0) + avmplus object
+	in := &bytes.Buffer{}
+	in.WriteByte(0)
+	_ = Amf0.WriteString(in, "@setDataFrame")
+	_ = Amf0.WriteString(in, "onMetaData")
+	in.WriteByte(Amf0TypeMarkerAvmplusObject)
+	_ = Amf3.WriteObject(in, ObjectPairArray{{Key: "width", Value: 1280}, {Key: "encoder", Value: "wowza"}})
+
+	out, err := amf3DataMessageToAmf0(in.Bytes())
+	assert.Equal(t, nil, err)
+
+	expected := &bytes.Buffer{}
+	_ = Amf0.WriteString(expected, "@setDataFrame")
+	_ = Amf0.WriteString(expected, "onMetaData")
+	_ = Amf0.WriteObject(expected, ObjectPairArray{{Key: "width", Value: float64(1280)}, {Key: "encoder", Value: "wowza"}})
+	assert.Equal(t, expected.Bytes(), out)
+
+	stream := NewStream()
+	stream.header.MsgTypeId = base.RtmpTypeIdDataMessageAmf3
+	stream.msg.buff.Write(in.Bytes())
+	amf0Stream, err := stream.amf3DataToAmf0()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, base.RtmpTypeIdMetadata, amf0Stream.toAvMsg().Header.MsgTypeId)
+	assert.Equal(t, expected.Bytes(), amf0Stream.toAvMsg().Payload)
+	assert.Equal(t, base.RtmpTypeIdDataMessageAmf3, stream.header.MsgTypeId)
+
+	// amf0中的avmplus成员
+	opa, _, err := Amf0.ReadObject([]byte{Amf0TypeMarkerObject, 0, 1, 'k', Amf0TypeMarkerAvmplusObject, Amf3TypeMarkerTrue, 0, 0, Amf0TypeMarkerObjectEnd})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, ObjectPairArray{{Key: "k", Value: true}}, opa)
+}
+
+func TestAmf3_ReadValue_Limit(t *testing.T) {
+	// 每个Array包含两个指向前一个Array的引用，展开后为指数级
+	n := 40
+	buf := &bytes.Buffer{}
+	buf.WriteByte(Amf3TypeMarkerArray)
+	_ = Amf3.WriteU29(buf, uint32(n+1)<<1|1)
+	buf.Write([]byte{0x01, Amf3TypeMarkerArray, 0x01, 0x01})
+	for i := 1; i <= n; i++ {
+		buf.Write([]byte{Amf3TypeMarkerArray, 0x05, 0x01, Amf3TypeMarkerArray})
+		_ = Amf3.WriteU29(buf, uint32(i)<<1)
+		buf.WriteByte(Amf3TypeMarkerArray)
+		_ = Amf3.WriteU29(buf, uint32(i)<<1)
+	}
+	_, _, err := Amf3.ReadValue(buf.Bytes())
+	assert.Equal(t, true, errors.Is(err, base.ErrAmfTooComplex))
+
+	// 同样的数据，数量较少时正常展开
+	_, _, err = Amf3.ReadValue([]byte{Amf3TypeMarkerArray, 0x05, 0x01, Amf3TypeMarkerArray, 0x01, 0x01, Amf3TypeMarkerArray, 0x02})
+	assert.Equal(t, nil, err)
+
+	// 嵌套过深
+	var b []byte
+	for i := 0; i < amf3MaxDepth+1; i++ {
+		b = append(b, Amf3TypeMarkerArray, 0x03, 0x01)
+	}
+	b = append(b, Amf3TypeMarkerNull)
+	_, _, err = Amf3.ReadValue(b)
+	assert.Equal(t, true, errors.Is(err, base.ErrAmfTooComplex))
+	_, _, err = Amf3.ReadValue(b[3:])
+	assert.Equal(t, nil, err)
+
+	// 循环引用
+	_, _, err = Amf3.ReadValue([]byte{Amf3TypeMarkerArray, 0x03, 0x01, Amf3TypeMarkerArray, 0x00})
+	assert.Equal(t, true, errors.Is(err, base.ErrAmfInvalidReference))
+
+	// amf3DataMessageToAmf0时同样受限
+	in := []byte{0, Amf0TypeMarkerAvmplusObject}
+	for i := 0; i < amf3MaxDepth+1; i++ {
+		in = append(in, Amf3TypeMarkerObject, 0x0b, 0x01, 0x03, 'k')
+	}
+	in = append(in, Amf3TypeMarkerNull)
+	_, err = amf3DataMessageToAmf0(in)
+	assert.Equal(t, true, errors.Is(err, base.ErrAmfTooComplex))
+}
@@ -0,0 +1,324 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtmp
+
+// auth.go
+// rtmp connect阶段的adobe鉴权与llnw（Limelight）鉴权
+//
+// 交互流程（每一步之后客户端都会断开连接并重新发起connect，鉴权参数追加在connect的app和tcUrl后面）：
+//
+// 1. 客户端connect不带鉴权参数，服务端回复_error，description为
+//    `[ AccessManager.Reject ] : [ code=403 need auth; authmod=adobe ] : `
+// 2. 客户端connect追加 `?authmod=adobe&user=<user>` ，服务端回复_error，description为
+//    adobe: `[ AccessManager.Reject ] : [ authmod=adobe ] : ?reason=needauth&user=<user>&salt=<salt>&challenge=<challenge>&opaque=<opaque>`
+//    llnw:  `[ AccessManager.Reject ] : [ authmod=llnw ] : ?reason=needauth&nonce=<nonce>`
+// 3. 客户端connect追加计算得到的response，见 AdobeAuthResponse 和 LlnwAuthResponse ，服务端校验通过后回复_result
+//
+// 兼容ffmpeg、OBS以及Wowza、Akamai等服务端的实现
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/q191201771/naza/pkg/nazamd5"
+)
+
+const (
+	AuthModAdobe = "adobe"
+	AuthModLlnw  = "llnw"
+)
+
+const (
+	authChallengeExpire = 60 * time.Second
+	authChallengeMaxNum = 4096 // 最多保存的挑战参数个数，超过时淘汰最早的
+
+	llnwRealm  = "live"
+	llnwMethod = "publish"
+	llnwQop    = "auth"
+	llnwNc     = "00000001"
+)
+
+// IAuthCredentialStore 鉴权使用的用户密码来源，业务方可以自己实现，比如从数据库中读取
+type IAuthCredentialStore interface {
+	// GetPassword
+	//
+	// @return exist: 用户不存在时返回false
+	GetPassword(appName, user string) (password string, exist bool)
+}
+
+// SingleUserCredentialStore 只有一个用户的 IAuthCredentialStore
+type SingleUserCredentialStore struct {
+	User     string
+	Password string
+}
+
+func (s SingleUserCredentialStore) GetPassword(appName, user string) (string, bool) {
+	if user != s.User {
+		return "", false
+	}
+	return s.Password, true
+}
+
+// AdobeAuthResponse
+//
+// response = base64(md5(base64(md5(user + salt + password)) + opaque + clientChallenge))
+// opaque为空时使用challenge替代
+func AdobeAuthResponse(user, password, salt, challenge, opaque, clientChallenge string) string {
+	h := md5.Sum([]byte(user + salt + password))
+	hashStr := base64.StdEncoding.EncodeToString(h[:])
+	if opaque == "" {
+		opaque = challenge
+	}
+	h = md5.Sum([]byte(hashStr + opaque + clientChallenge))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// LlnwAuthResponse 和http digest类似，realm、method、qop固定
+//
+// @param app: connect中不包含鉴权参数的app
+func LlnwAuthResponse(user, password, nonce, cnonce, nc, app string) string {
+	ha1 := nazamd5.Md5([]byte(user + ":" + llnwRealm + ":" + password))
+
+	// 和ffmpeg的实现保持一致，只使用app的第一级，并且app没有多级时追加/_definst_
+	path := app
+	if pos := strings.IndexAny(path, "/?"); pos != -1 {
+		path = path[:pos]
+	}
+	if !strings.Contains(app, "/") {
+		path += "/_definst_"
+	}
+	ha2 := nazamd5.Md5([]byte(llnwMethod + ":/" + path))
+
+	return nazamd5.Md5([]byte(ha1 + ":" + nonce + ":" + nc + ":" + cnonce + ":" + llnwQop + ":" + ha2))
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// ServerAuth rtmp服务端鉴权，同一个 Server 的所有 ServerSession 共用
+//
+// 鉴权过程中客户端会多次重连，所以下发的挑战参数保存在这里
+type ServerAuth struct {
+	mod   string
+	store IAuthCredentialStore
+
+	mutex          sync.Mutex
+	challenges     map[string]authChallenge // key: authmod + opaque或nonce
+	challengeOrder []authChallengeKey       // 按添加顺序排列，过期时间相同，所以也是按过期时间排列
+}
+
+type authChallengeKey struct {
+	key        string
+	expireTime time.Time
+}
+
+type authChallenge struct {
+	user       string
+	salt       string
+	challenge  string
+	expireTime time.Time
+}
+
+// NewServerAuth
+//
+// @param mod: 客户端没有指定authmod时，要求客户端使用的鉴权方式， AuthModAdobe 或 AuthModLlnw ，为空时为 AuthModAdobe
+func NewServerAuth(mod string, store IAuthCredentialStore) *ServerAuth {
+	if mod != AuthModLlnw {
+		mod = AuthModAdobe
+	}
+	return &ServerAuth{
+		mod:        mod,
+		store:      store,
+		challenges: make(map[string]authChallenge),
+	}
+}
+
+// Check
+//
+// @param app:   connect中不包含鉴权参数的app
+// @param query: connect的app中`?`之后的鉴权参数
+//
+// @return description: 鉴权没有通过时，回复给客户端的_error中的description
+// @return ok:          鉴权是否通过
+func (a *ServerAuth) Check(app, query string) (description string, ok bool) {
+	params := parseAuthParams(query)
+	mod := params["authmod"]
+	user := params["user"]
+	if (mod != AuthModAdobe && mod != AuthModLlnw) || user == "" {
+		return fmt.Sprintf("[ AccessManager.Reject ] : [ code=403 need auth; authmod=%s ] : ", a.mod), false
+	}
+
+	reject := func(reason string) string {
+		return fmt.Sprintf("[ AccessManager.Reject ] : [ authmod=%s ] : ?reason=%s", mod, reason)
+	}
+
+	password, exist := a.store.GetPassword(app, user)
+	if !exist {
+		return reject("nosuchuser"), false
+	}
+
+	switch mod {
+	case AuthModAdobe:
+		if params["response"] == "" {
+			c := authChallenge{
+				user:      user,
+				salt:      randomAuthString(),
+				challenge: randomAuthString(),
+			}
+			opaque := randomAuthString()
+			a.addChallenge(mod+opaque, c)
+			return fmt.Sprintf("%s&user=%s&salt=%s&challenge=%s&opaque=%s", reject("needauth"), user, c.salt, c.challenge, opaque), false
+		}
+
+		opaque := params["opaque"]
+		c, exist := a.popChallenge(mod + opaque)
+		if !exist || c.user != user {
+			return reject("authfailed"), false
+		}
+		if !equalAuthResponse(AdobeAuthResponse(user, password, c.salt, c.challenge, opaque, params["challenge"]), params["response"]) {
+			return reject("authfailed"), false
+		}
+	case AuthModLlnw:
+		if params["response"] == "" {
+			nonce := randomAuthString()
+			a.addChallenge(mod+nonce, authChallenge{user: user})
+			return fmt.Sprintf("%s&nonce=%s", reject("needauth"), nonce), false
+		}
+
+		nonce := params["nonce"]
+		c, exist := a.popChallenge(mod + nonce)
+		if !exist || c.user != user {
+			return reject("authfailed"), false
+		}
+		if !equalAuthResponse(LlnwAuthResponse(user, password, nonce, params["cnonce"], params["nc"], app), params["response"]) {
+			return reject("authfailed"), false
+		}
+	}
+	return "", true
+}
+
+func (a *ServerAuth) addChallenge(key string, c authChallenge) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	// 只需要从头部淘汰过期的，以及超过个数限制的
+	now := time.Now()
+	n := 0
+	for ; n < len(a.challengeOrder); n++ {
+		item := a.challengeOrder[n]
+		if !now.After(item.expireTime) && len(a.challengeOrder)-n < authChallengeMaxNum {
+			break
+		}
+		// 已经被popChallenge取走的不再存在
+		if v, exist := a.challenges[item.key]; exist && v.expireTime.Equal(item.expireTime) {
+			delete(a.challenges, item.key)
+		}
+	}
+	a.challengeOrder = a.challengeOrder[n:]
+
+	c.expireTime = now.Add(authChallengeExpire)
+	a.challenges[key] = c
+	a.challengeOrder = append(a.challengeOrder, authChallengeKey{key: key, expireTime: c.expireTime})
+}
+
+// popChallenge 挑战参数只能使用一次
+func (a *ServerAuth) popChallenge(key string) (authChallenge, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	c, exist := a.challenges[key]
+	if !exist {
+		return c, false
+	}
+	delete(a.challenges, key)
+	if time.Now().After(c.expireTime) {
+		return c, false
+	}
+	return c, true
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// authParamKeys 鉴权过程中客户端追加的参数
+var authParamKeys = map[string]bool{
+	"authmod":   true,
+	"user":      true,
+	"challenge": true,
+	"response":  true,
+	"opaque":    true,
+	"nonce":     true,
+	"cnonce":    true,
+	"nc":        true,
+}
+
+// splitAuthQuery 将connect中的app或tcUrl拆分为不包含鉴权参数的部分和鉴权参数
+//
+// 鉴权参数可能跟在`?`后面，也可能跟在业务方自己的参数后面，比如 `live?token=1&authmod=adobe&user=chef` ，
+// 此时返回 `live?token=1` 和 `authmod=adobe&user=chef`
+func splitAuthQuery(s string) (string, string) {
+	pos := strings.Index(s, "?")
+	if pos == -1 {
+		return s, ""
+	}
+	if _, exist := parseAuthParams(s[pos+1:])["authmod"]; !exist {
+		return s, ""
+	}
+
+	var others, auths []string
+	for _, item := range strings.Split(s[pos+1:], "&") {
+		if item == "" {
+			continue
+		}
+		key := item
+		if i := strings.Index(item, "="); i != -1 {
+			key = item[:i]
+		}
+		if k, err := url.QueryUnescape(key); err == nil && authParamKeys[k] {
+			auths = append(auths, item)
+		} else {
+			others = append(others, item)
+		}
+	}
+	if len(others) == 0 {
+		return s[:pos], strings.Join(auths, "&")
+	}
+	return s[:pos+1] + strings.Join(others, "&"), strings.Join(auths, "&")
+}
+
+// parseAuthParams
+//
+// 注意，response等字段是base64，可能包含`+`，并且通常没有经过url编码，所以解析前先将`+`转义，避免被解析成空格
+func parseAuthParams(s string) map[string]string {
+	values, _ := url.ParseQuery(strings.ReplaceAll(strings.TrimPrefix(s, "?"), "+", "%2B"))
+	params := make(map[string]string, len(values))
+	for k, v := range values {
+		if len(v) != 0 {
+			params[k] = v[0]
+		}
+	}
+	return params
+}
+
+// equalAuthResponse 使用固定时间的比较，避免通过响应时间猜测response
+func equalAuthResponse(expected, actual string) bool {
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(actual)) == 1
+}
+
+func randomAuthString() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
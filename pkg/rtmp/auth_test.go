@@ -0,0 +1,147 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtmp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/naza/pkg/assert"
+)
+
+type testAuthServerObserver struct {
+	testServerSessionObserver
+}
+
+func (o *testAuthServerObserver) OnDelRtmpPubSession(session *ServerSession) {
+}
+
+func (o *testAuthServerObserver) OnDelRtmpSubSession(session *ServerSession) {
+}
+
+func TestServerAuth(t *testing.T) {
+	store := SingleUserCredentialStore{User: "chef", Password: "pwd"}
+
+	// adobe
+	a := NewServerAuth("", store)
+	desc, ok := a.Check("live", "")
+	assert.Equal(t, false, ok)
+	assert.Equal(t, "[ AccessManager.Reject ] : [ code=403 need auth; authmod=adobe ] : ", desc)
+
+	desc, ok = a.Check("live", "authmod=adobe&user=nobody")
+	assert.Equal(t, false, ok)
+	assert.Equal(t, true, strings.HasSuffix(desc, "?reason=nosuchuser"))
+
+	desc, ok = a.Check("live", "authmod=adobe&user=chef")
+	assert.Equal(t, false, ok)
+	params := parseAuthParams(desc[strings.Index(desc, "?reason=needauth"):])
+	assert.Equal(t, "needauth", params["reason"])
+	assert.Equal(t, "chef", params["user"])
+	response := AdobeAuthResponse("chef", "pwd", params["salt"], params["challenge"], params["opaque"], "abcd1234")
+	query := fmt.Sprintf("authmod=adobe&user=chef&challenge=abcd1234&response=%s&opaque=%s", response, params["opaque"])
+	_, ok = a.Check("live", query)
+	assert.Equal(t, true, ok)
+	// 挑战参数只能使用一次
+	desc, ok = a.Check("live", query)
+	assert.Equal(t, false, ok)
+	assert.Equal(t, true, strings.HasSuffix(desc, "?reason=authfailed"))
+
+	// llnw
+	a = NewServerAuth(AuthModLlnw, store)
+	desc, _ = a.Check("live", "")
+	assert.Equal(t, "[ AccessManager.Reject ] : [ code=403 need auth; authmod=llnw ] : ", desc)
+	desc, ok = a.Check("live", "authmod=llnw&user=chef")
+	assert.Equal(t, false, ok)
+	params = parseAuthParams(desc[strings.Index(desc, "?reason=needauth"):])
+	response = LlnwAuthResponse("chef", "wrong", params["nonce"], "cn", llnwNc, "live")
+	desc, ok = a.Check("live", fmt.Sprintf("authmod=llnw&user=chef&nonce=%s&cnonce=cn&nc=%s&response=%s", params["nonce"], llnwNc, response))
+	assert.Equal(t, false, ok)
+	assert.Equal(t, true, strings.HasSuffix(desc, "?reason=authfailed"))
+
+	desc, _ = a.Check("live", "authmod=llnw&user=chef")
+	params = parseAuthParams(desc[strings.Index(desc, "?reason=needauth"):])
+	response = LlnwAuthResponse("chef", "pwd", params["nonce"], "cn", llnwNc, "live")
+	_, ok = a.Check("live", fmt.Sprintf("authmod=llnw&user=chef&nonce=%s&cnonce=cn&nc=%s&response=%s", params["nonce"], llnwNc, response))
+	assert.Equal(t, true, ok)
+}
+
+func TestSplitAuthQuery(t *testing.T) {
+	app, query := splitAuthQuery("live")
+	assert.Equal(t, "live", app)
+	assert.Equal(t, "", query)
+	app, query = splitAuthQuery("live?token=1")
+	assert.Equal(t, "live?token=1", app)
+	assert.Equal(t, "", query)
+	app, query = splitAuthQuery("live?authmod=adobe&user=chef")
+	assert.Equal(t, "live", app)
+	assert.Equal(t, "authmod=adobe&user=chef", query)
+	app, query = splitAuthQuery("rtmp://127.0.0.1/live?token=1&authmod=adobe&user=chef&response=a+b/c==&k=v")
+	assert.Equal(t, "rtmp://127.0.0.1/live?token=1&k=v", app)
+	assert.Equal(t, "authmod=adobe&user=chef&response=a+b/c==", query)
+
+	// base64中的`+`不会被解析成空格，url编码过的值正常解析
+	params := parseAuthParams(query)
+	assert.Equal(t, "a+b/c==", params["response"])
+	assert.Equal(t, "a+b/c==", parseAuthParams("?response=a%2Bb%2Fc%3D%3D")["response"])
+}
+
+func TestServerAuth_ChallengeLimit(t *testing.T) {
+	a := NewServerAuth("", SingleUserCredentialStore{User: "chef", Password: "pwd"})
+	for i := 0; i < authChallengeMaxNum+100; i++ {
+		a.addChallenge(fmt.Sprintf("k%d", i), authChallenge{user: "chef"})
+	}
+	assert.Equal(t, authChallengeMaxNum, len(a.challenges))
+	assert.Equal(t, authChallengeMaxNum, len(a.challengeOrder))
+	_, exist := a.popChallenge("k0")
+	assert.Equal(t, false, exist)
+	_, exist = a.popChallenge(fmt.Sprintf("k%d", authChallengeMaxNum+99))
+	assert.Equal(t, true, exist)
+
+	// 过期的被淘汰
+	expired := time.Now().Add(-time.Second)
+	for i, item := range a.challengeOrder {
+		a.challengeOrder[i].expireTime = expired
+		if c, exist := a.challenges[item.key]; exist {
+			c.expireTime = expired
+			a.challenges[item.key] = c
+		}
+	}
+	a.addChallenge("new", authChallenge{user: "chef"})
+	assert.Equal(t, 1, len(a.challenges))
+	assert.Equal(t, 1, len(a.challengeOrder))
+}
+
+func TestServerAuth_PushSession(t *testing.T) {
+	for _, mod := range []string{AuthModAdobe, AuthModLlnw} {
+		server := NewServer("127.0.0.1:0", &testAuthServerObserver{})
+		server.SetAuth(NewServerAuth(mod, SingleUserCredentialStore{User: "chef", Password: "pwd"}))
+		assert.Equal(t, nil, server.Listen())
+		go server.RunLoop()
+		addr := server.ln.Addr().String()
+
+		ps := NewPushSession()
+		err := ps.Start(fmt.Sprintf("rtmp://chef:pwd@%s/live/test", addr))
+		assert.Equal(t, nil, err)
+		_ = ps.Dispose()
+
+		ps = NewPushSession()
+		err = ps.Start(fmt.Sprintf("rtmp://chef:wrong@%s/live/test", addr))
+		assert.Equal(t, true, errors.Is(err, base.ErrRtmpAuth))
+
+		ps = NewPushSession()
+		err = ps.Start(fmt.Sprintf("rtmp://%s/live/test", addr))
+		assert.Equal(t, true, errors.Is(err, base.ErrRtmpAuth))
+
+		server.Dispose()
+	}
+}
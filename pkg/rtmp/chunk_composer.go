@@ -25,7 +25,7 @@ import (
 // 读取chunk，并合并chunk，生成message返回给上层
 type ChunkComposer struct {
 	peerChunkSize   uint32
-	reuseBufferFlag bool // TODO(chef): [fix] RtmpTypeIdAggregateMessage时，reuseBufferFlag==false的处理 202206
+	reuseBufferFlag bool
 
 	csid2stream map[int]*Stream
 }
@@ -242,7 +242,9 @@ func (c *ChunkComposer) RunLoop(reader io.Reader, cb OnCompleteMessage) error {
 					if stream.msg.Len() < aggregateStream.header.MsgLen {
 						return base.NewErrRtmpShortBuffer(int(aggregateStream.header.MsgLen), int(stream.msg.Len()), "parse rtmp aggregate sub message body")
 					}
+					// sub message引用聚合消息的内存块，所有sub message回调结束后，聚合消息的内存块和普通消息一样按 reuseBufferFlag 处理
 					aggregateStream.msg.buff = nazabytes.NewBufferRefBytes(stream.msg.buff.Peek(int(aggregateStream.header.MsgLen)))
+					aggregateStream.msg.Flush(aggregateStream.header.MsgLen)
 					stream.msg.Skip(aggregateStream.header.MsgLen)
 
 					// sub message回调给上层
@@ -260,12 +262,12 @@ func (c *ChunkComposer) RunLoop(reader io.Reader, cb OnCompleteMessage) error {
 				if err := cb(stream); err != nil {
 					return err
 				}
+			}
 
-				if c.reuseBufferFlag {
-					stream.msg.Reset()
-				} else {
-					stream.msg.ResetAndFree()
-				}
+			if c.reuseBufferFlag {
+				stream.msg.Reset()
+			} else {
+				stream.msg.ResetAndFree()
 			}
 		}
 
@@ -94,3 +94,50 @@ func TestChunkComposer(t *testing.T) {
 		break
 	}
 }
+
+func TestChunkComposerAggregate(t *testing.T) {
+	makeChunk := func(typeId uint8, ts uint32, payload []byte) []byte {
+		b := []byte{4, byte(ts >> 16), byte(ts >> 8), byte(ts), 0, 0, byte(len(payload)), typeId, 1, 0, 0, 0}
+		b[5] = byte(len(payload) >> 8)
+		return append(b, payload...)
+	}
+	makeSubMessage := func(typeId uint8, ts uint32, payload []byte) []byte {
+		b := []byte{typeId, 0, 0, byte(len(payload)), byte(ts >> 16), byte(ts >> 8), byte(ts), byte(ts >> 24), 0, 0, 1}
+		b = append(b, payload...)
+		size := uint32(11 + len(payload))
+		return append(b, byte(size>>24), byte(size>>16), byte(size>>8), byte(size))
+	}
+
+	var aggregate []byte
+	aggregate = append(aggregate, makeSubMessage(base.RtmpTypeIdVideo, 500, []byte{1, 2, 3})...)
+	aggregate = append(aggregate, makeSubMessage(base.RtmpTypeIdAudio, 520, []byte{4, 5})...)
+
+	for _, reuse := range []bool{false, true} {
+		rb := &bytes.Buffer{}
+		rb.Write(makeChunk(base.RtmpTypeIdAggregateMessage, 1000, aggregate))
+		rb.Write(makeChunk(base.RtmpTypeIdVideo, 1040, []byte{6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6}))
+
+		cc := NewChunkComposer()
+		cc.SetReuseBufferFlag(reuse)
+
+		var msgs []base.RtmpMsg
+		err := cc.RunLoop(rb, func(stream *Stream) error {
+			msg := stream.toAvMsg()
+			if reuse {
+				msg = msg.Clone()
+			}
+			msgs = append(msgs, msg)
+			return nil
+		})
+		assert.IsNotNil(t, err)
+		assert.Equal(t, 3, len(msgs))
+		assert.Equal(t, base.RtmpTypeIdVideo, msgs[0].Header.MsgTypeId)
+		assert.Equal(t, uint32(1000), msgs[0].Header.TimestampAbs)
+		assert.Equal(t, []byte{1, 2, 3}, msgs[0].Payload)
+		assert.Equal(t, base.RtmpTypeIdAudio, msgs[1].Header.MsgTypeId)
+		assert.Equal(t, uint32(1020), msgs[1].Header.TimestampAbs)
+		assert.Equal(t, []byte{4, 5}, msgs[1].Payload)
+		assert.Equal(t, uint32(1040), msgs[2].Header.TimestampAbs)
+		assert.Equal(t, 27, len(msgs[2].Payload))
+	}
+}
@@ -19,69 +19,163 @@ import (
 
 type ChunkDivider struct {
 	localChunkSize int
+
+	// 每个csid上一个音视频message的信息，用于压缩下一个message第一个chunk的header
+	// 为nil时表示不压缩，也即 Message2Chunks 等全局函数使用的方式
+	csid2state map[int]*chunkStreamState
+}
+
+// chunkStreamState 本端在某个csid上发送的上一个message
+type chunkStreamState struct {
+	header base.RtmpHeader
+	fmt    uint8
+	// 第一个chunk header中的时间戳字段，fmt0为绝对时间戳，其他为相对时间戳
+	timestamp uint32
 }
 
-var defaultChunkDivider = ChunkDivider{
-	localChunkSize: LocalChunkSize,
+// NewChunkDivider
+//
+// 和 Message2Chunks 等全局函数每个message的第一个chunk都使用fmt0不同，
+// 这里会参考同一个csid上的上一个message，使用fmt1、fmt2、fmt3压缩新message第一个chunk的header。
+//
+// 由于压缩依赖对端已经收到的数据，所以：
+// 1. 一个 ChunkDivider 只能用于一个连接，连接上的音视频数据都需要经过它切割或压缩
+// 2. 只压缩音视频message，信令、metadata等其他message依然使用fmt0
+//
+// @param chunkSize: 本端切割chunk的大小，需要和通过SetChunkSize发送给对端的值一致，一般就是 LocalChunkSize
+func NewChunkDivider(chunkSize int) *ChunkDivider {
+	return &ChunkDivider{
+		localChunkSize: chunkSize,
+		csid2state:     make(map[int]*chunkStreamState),
+	}
 }
 
 // Message2Chunks
 //
+// 注意，新的 message 的第一个 chunk 始终使用 fmt0 格式，没有参考前一个 message，
+// 所以返回的内存块可以发送给多个连接，比如lalserver中的所有rtmp sub session共用。
+//
 // @return 返回的内存块由内部申请，不依赖参数<message>内存块
 func Message2Chunks(message []byte, header *base.RtmpHeader) []byte {
-	return defaultChunkDivider.Message2Chunks(message, header)
+	return message2Chunks(message, header, 0, header.TimestampAbs, LocalChunkSize)
+}
+
+// Message2ChunksWithSize 和 Message2Chunks 相同，只是使用参数<chunkSize>切割，而不是 LocalChunkSize
+func Message2ChunksWithSize(message []byte, header *base.RtmpHeader, chunkSize int) []byte {
+	return message2Chunks(message, header, 0, header.TimestampAbs, chunkSize)
 }
 
 // Message2ChunksV
 //
 // @param message: 待打包的message支持放在多个字节切片中
 func Message2ChunksV(message net.Buffers, header *base.RtmpHeader) []byte {
-	return defaultChunkDivider.Message2ChunksV(message, header)
+	return message2ChunksV(message, header, 0, header.TimestampAbs, LocalChunkSize)
 }
 
-// Message2Chunks
-//
-// TODO chef: [opt] 新的 message 的第一个 chunk 始终使用 fmt0 格式，没有参考前一个 message
 func (d *ChunkDivider) Message2Chunks(message []byte, header *base.RtmpHeader) []byte {
-	return message2Chunks(message, header, nil, d.localChunkSize)
+	fmt, timestamp := d.calcFmt(header)
+	d.update(header, fmt, timestamp)
+	return message2Chunks(message, header, fmt, timestamp, d.localChunkSize)
 }
 
 func (d *ChunkDivider) Message2ChunksV(message net.Buffers, header *base.RtmpHeader) []byte {
-	return message2ChunksV(message, header, nil, d.localChunkSize)
+	fmt, timestamp := d.calcFmt(header)
+	d.update(header, fmt, timestamp)
+	return message2ChunksV(message, header, fmt, timestamp, d.localChunkSize)
 }
 
-// ---------------------------------------------------------------------------------------------------------------------
-
-// @param 返回头的大小
-func calcHeader(header *base.RtmpHeader, prevHeader *base.RtmpHeader, out []byte) int {
-	var index int
+// CompressChunks 压缩 Message2Chunks 全局函数生成的chunk数据
+//
+// 只改写每个message第一个chunk的header，payload以及后续的chunk引用参数<chunks>的内存块，不发生拷贝，
+// 所以多个连接共用同一份chunk数据时，每个连接可以使用自己的 ChunkDivider 压缩后再发送。
+//
+// @param chunks: 1~n个完整message的chunk数据，切割时的chunk size需要和 ChunkDivider 的一致。
+// 如果解析失败，后续数据原样返回，并且清空所有csid的状态。
+//
+// @return 可以直接使用writev发送
+func (d *ChunkDivider) CompressChunks(chunks []byte) net.Buffers {
+	var out net.Buffers
+	for pos := 0; pos < len(chunks); {
+		header, headerLen, totalLen, ok := parseFmt0Message(chunks[pos:], d.localChunkSize)
+		if !ok {
+			out = append(out, chunks[pos:])
+			d.Reset()
+			break
+		}
 
-	// 计算fmt和timestamp
-	fmt := uint8(0)
-	var timestamp uint32
-	if prevHeader == nil {
-		timestamp = header.TimestampAbs
-	} else {
-		if header.MsgStreamId == prevHeader.MsgStreamId {
-			fmt++
-			if header.MsgLen == prevHeader.MsgLen && header.MsgTypeId == prevHeader.MsgTypeId {
-				fmt++
-				if header.TimestampAbs == prevHeader.TimestampAbs {
-					fmt++
-				}
-			}
-			if header.TimestampAbs > maxTimestampInMessageHeader {
-				// 将数据打包成rtmp chunk发送给vlc，时间戳超过3字节最大范围时，
-				// vlc认为fmt0和fmt3两种格式，都需要携带扩展时间戳字段，并且该时间戳字段必须使用绝对时间戳。
-				timestamp = header.TimestampAbs
-			} else {
-				timestamp = header.TimestampAbs - prevHeader.TimestampAbs
-			}
+		fmt, timestamp := d.calcFmt(&header)
+		d.update(&header, fmt, timestamp)
+		if fmt == 0 {
+			out = append(out, chunks[pos:pos+totalLen])
 		} else {
-			timestamp = header.TimestampAbs
+			h := make([]byte, maxHeaderSize)
+			n := writeChunkHeader(h, &header, fmt, timestamp)
+			out = append(out, h[:n], chunks[pos+headerLen:pos+totalLen])
 		}
+		pos += totalLen
+	}
+	return out
+}
+
+// Reset 清空所有csid的状态，之后每个csid的第一个message都使用fmt0
+func (d *ChunkDivider) Reset() {
+	if d.csid2state != nil {
+		d.csid2state = make(map[int]*chunkStreamState)
+	}
+}
+
+// calcFmt 计算新message第一个chunk的fmt，以及header中的时间戳字段
+func (d *ChunkDivider) calcFmt(header *base.RtmpHeader) (fmt uint8, timestamp uint32) {
+	if d.csid2state == nil {
+		return 0, header.TimestampAbs
+	}
+	prev, exist := d.csid2state[header.Csid]
+	if !exist ||
+		(header.MsgTypeId != base.RtmpTypeIdAudio && header.MsgTypeId != base.RtmpTypeIdVideo) ||
+		header.MsgStreamId != prev.header.MsgStreamId ||
+		header.TimestampAbs < prev.header.TimestampAbs ||
+		header.TimestampAbs >= maxTimestampInMessageHeader {
+		// 时间戳回退或者需要扩展时间戳时，使用fmt0携带绝对时间戳，避免对端对相对时间戳的处理不一致
+		return 0, header.TimestampAbs
 	}
 
+	delta := header.TimestampAbs - prev.header.TimestampAbs
+	if header.MsgLen != prev.header.MsgLen || header.MsgTypeId != prev.header.MsgTypeId {
+		return 1, delta
+	}
+	// fmt3作为新message的第一个chunk时，时间戳增量沿用上一个chunk header中的时间戳字段。
+	// 上一个是fmt0时，该字段是绝对时间戳，为了兼容性，这种情况不使用fmt3
+	if prev.fmt != 0 && delta == prev.timestamp {
+		return 3, delta
+	}
+	return 2, delta
+}
+
+func (d *ChunkDivider) update(header *base.RtmpHeader, fmt uint8, timestamp uint32) {
+	if d.csid2state == nil {
+		return
+	}
+	if header.MsgTypeId != base.RtmpTypeIdAudio && header.MsgTypeId != base.RtmpTypeIdVideo {
+		delete(d.csid2state, header.Csid)
+		return
+	}
+	d.csid2state[header.Csid] = &chunkStreamState{
+		header:    *header,
+		fmt:       fmt,
+		timestamp: timestamp,
+	}
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// writeChunkHeader
+//
+// @param timestamp: chunk header中的时间戳字段，fmt0为绝对时间戳，fmt1、fmt2为相对时间戳，fmt3时只用于判断是否需要携带扩展时间戳
+//
+// @return 返回头的大小
+func writeChunkHeader(out []byte, header *base.RtmpHeader, fmt uint8, timestamp uint32) int {
+	var index int
+
 	// 设置fmt
 	out[index] = fmt << 6
 
@@ -105,7 +199,7 @@ func calcHeader(header *base.RtmpHeader, prevHeader *base.RtmpHeader, out []byte
 
 	// 设置timestamp msgLen msgTypeId msgStreamId
 	if fmt <= 2 {
-		if timestamp > maxTimestampInMessageHeader {
+		if timestamp >= maxTimestampInMessageHeader {
 			bele.BePutUint24(out[index:], maxTimestampInMessageHeader)
 		} else {
 			bele.BePutUint24(out[index:], timestamp)
@@ -126,7 +220,9 @@ func calcHeader(header *base.RtmpHeader, prevHeader *base.RtmpHeader, out []byte
 	}
 
 	// 设置扩展时间戳
-	if timestamp > maxTimestampInMessageHeader {
+	// 将数据打包成rtmp chunk发送给vlc，时间戳超过3字节最大范围时，
+	// vlc认为fmt0和fmt3两种格式，都需要携带扩展时间戳字段，并且该时间戳字段必须使用绝对时间戳。
+	if timestamp >= maxTimestampInMessageHeader {
 		bele.BePutUint32(out[index:], timestamp)
 		index += 4
 	}
@@ -134,7 +230,64 @@ func calcHeader(header *base.RtmpHeader, prevHeader *base.RtmpHeader, out []byte
 	return index
 }
 
-func message2Chunks(message []byte, header *base.RtmpHeader, prevHeader *base.RtmpHeader, chunkSize int) []byte {
+// parseFmt0Message 解析 Message2Chunks 全局函数生成的一个message的chunk数据
+//
+// @return headerLen: 第一个chunk的header大小
+// @return totalLen:  这个message所有chunk的总大小
+func parseFmt0Message(b []byte, chunkSize int) (header base.RtmpHeader, headerLen int, totalLen int, ok bool) {
+	if len(b) < 1 || b[0]>>6 != 0 {
+		return
+	}
+
+	basicLen := 1
+	header.Csid = int(b[0] & 0x3f)
+	switch header.Csid {
+	case 0:
+		basicLen = 2
+	case 1:
+		basicLen = 3
+	}
+	if len(b) < basicLen+11 {
+		return
+	}
+	switch header.Csid {
+	case 0:
+		header.Csid = 64 + int(b[1])
+	case 1:
+		header.Csid = 64 + int(b[1]) + int(b[2])*256
+	}
+
+	index := basicLen
+	header.TimestampAbs = bele.BeUint24(b[index:])
+	header.MsgLen = bele.BeUint24(b[index+3:])
+	header.MsgTypeId = b[index+6]
+	header.MsgStreamId = int(bele.LeUint32(b[index+7:]))
+	index += 11
+
+	extLen := 0
+	if header.TimestampAbs == maxTimestampInMessageHeader {
+		extLen = 4
+		if len(b) < index+extLen {
+			return
+		}
+		header.TimestampAbs = bele.BeUint32(b[index:])
+		index += extLen
+	}
+	headerLen = index
+
+	numOfChunk := (int(header.MsgLen) + chunkSize - 1) / chunkSize
+	if numOfChunk == 0 {
+		return
+	}
+	totalLen = headerLen + int(header.MsgLen) + (numOfChunk-1)*(basicLen+extLen)
+	if len(b) < totalLen {
+		return
+	}
+	ok = true
+	return
+}
+
+func message2Chunks(message []byte, header *base.RtmpHeader, fmt uint8, timestamp uint32, chunkSize int) []byte {
 	//if header.Csid < minCsid || header.Csid > maxCsid {
 	//	return nil, ErrRtmp
 	//}
@@ -160,7 +313,7 @@ func message2Chunks(message []byte, header *base.RtmpHeader, prevHeader *base.Rt
 	// NOTICE 和srs交互时，发现srs要求message中的非第一个chunk不能使用fmt0
 	// 将message切割成chunk放入chunk body中
 	for i := 0; i < numOfChunk; i++ {
-		headLen := calcHeader(header, prevHeader, out[index:])
+		headLen := writeChunkHeader(out[index:], header, fmt, timestamp)
 		index += headLen
 
 		if i != numOfChunk-1 {
@@ -170,7 +323,7 @@ func message2Chunks(message []byte, header *base.RtmpHeader, prevHeader *base.Rt
 			copy(out[index:], message[i*chunkSize:i*chunkSize+lastChunkSize])
 			index += lastChunkSize
 		}
-		prevHeader = header
+		fmt = 3
 	}
 
 	return out[:index]
@@ -183,7 +336,7 @@ func message2Chunks(message []byte, header *base.RtmpHeader, prevHeader *base.Rt
 func copyBufferFromBuffers(out []byte, bs net.Buffers, pos int, length int) {
 }
 
-func message2ChunksV(message net.Buffers, header *base.RtmpHeader, prevHeader *base.RtmpHeader, chunkSize int) []byte {
+func message2ChunksV(message net.Buffers, header *base.RtmpHeader, fmt uint8, timestamp uint32, chunkSize int) []byte {
 	var totalLen int
 	for _, b := range message {
 		totalLen += len(b)
@@ -205,7 +358,7 @@ func message2ChunksV(message net.Buffers, header *base.RtmpHeader, prevHeader *b
 	// NOTICE 和srs交互时，发现srs要求message中的非第一个chunk不能使用fmt0
 	// 将message切割成chunk放入chunk body中
 	for i := 0; i < numOfChunk; i++ {
-		headLen := calcHeader(header, prevHeader, out[index:])
+		headLen := writeChunkHeader(out[index:], header, fmt, timestamp)
 		index += headLen
 
 		if i != numOfChunk-1 {
@@ -217,7 +370,7 @@ func message2ChunksV(message net.Buffers, header *base.RtmpHeader, prevHeader *b
 			copyBufferFromBuffers(out[index:], message, i*chunkSize, lastChunkSize)
 			index += lastChunkSize
 		}
-		prevHeader = header
+		fmt = 3
 	}
 
 	return out[:index]
@@ -9,10 +9,14 @@
 package rtmp
 
 import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
 	"github.com/q191201771/lal/pkg/base"
 	"github.com/q191201771/naza/pkg/assert"
 	"github.com/q191201771/naza/pkg/bele"
-	"testing"
 )
 
 func TestMessage2Chunks(t *testing.T) {
@@ -71,3 +75,172 @@ func TestMessage2Chunks(t *testing.T) {
 		assert.Equal(t, exp, m)
 	}
 }
+
+// newTestChunkDividerMsgs 模拟一段音视频流：视频25fps，aac音频44.1khz，并穿插metadata、时间戳回退以及扩展时间戳
+func newTestChunkDividerMsgs() []base.RtmpMsg {
+	var msgs []base.RtmpMsg
+	add := func(csid int, typeid uint8, ts uint32, size int) {
+		payload := make([]byte, size)
+		for i := range payload {
+			payload[i] = byte(len(msgs) + i)
+		}
+		msgs = append(msgs, base.RtmpMsg{
+			Header: base.RtmpHeader{
+				Csid:         csid,
+				MsgLen:       uint32(size),
+				MsgTypeId:    typeid,
+				MsgStreamId:  Msid1,
+				TimestampAbs: ts,
+			},
+			Payload: payload,
+		})
+	}
+
+	add(CsidAmf, base.RtmpTypeIdMetadata, 0, 100)
+	for i := 0; i < 100; i++ {
+		videoSize := 1000 + (i*7919)%3000
+		if i%50 == 0 {
+			videoSize = 20000 // 关键帧，超过chunk size
+		}
+		add(CsidVideo, base.RtmpTypeIdVideo, uint32(i*40), videoSize)
+		add(CsidAudio, base.RtmpTypeIdAudio, uint32(i*1024*1000/44100), 300)
+		add(CsidAudio, base.RtmpTypeIdAudio, uint32((i*1024+512)*1000/44100), 300)
+	}
+	add(CsidAmf, base.RtmpTypeIdMetadata, 4000, 120)
+	add(CsidVideo, base.RtmpTypeIdVideo, 3000, 500) // 时间戳回退
+	add(CsidVideo, base.RtmpTypeIdVideo, 3040, 500)
+	add(CsidVideo, base.RtmpTypeIdVideo, maxTimestampInMessageHeader+10, 5000) // 扩展时间戳
+	add(CsidVideo, base.RtmpTypeIdVideo, maxTimestampInMessageHeader+50, 5000)
+	add(CsidVideo, base.RtmpTypeIdVideo, maxTimestampInMessageHeader+90, 500)
+	return msgs
+}
+
+func composeTestChunks(t *testing.T, b []byte, chunkSize int) []base.RtmpMsg {
+	var out []base.RtmpMsg
+	cc := NewChunkComposer()
+	cc.SetPeerChunkSize(uint32(chunkSize))
+	err := cc.RunLoop(bytes.NewReader(b), func(stream *Stream) error {
+		msg := stream.toAvMsg()
+		msg.Payload = append([]byte(nil), msg.Payload...)
+		out = append(out, msg)
+		return nil
+	})
+	assert.Equal(t, io.EOF, err)
+	return out
+}
+
+func TestChunkDivider(t *testing.T) {
+	msgs := newTestChunkDividerMsgs()
+
+	var fmt0, compressed, compressed2 []byte
+	d := NewChunkDivider(LocalChunkSize)
+	d2 := NewChunkDivider(LocalChunkSize)
+	for _, msg := range msgs {
+		chunks := Message2Chunks(msg.Payload, &msg.Header)
+		fmt0 = append(fmt0, chunks...)
+		compressed = append(compressed, d.Message2Chunks(msg.Payload, &msg.Header)...)
+		for _, b := range d2.CompressChunks(chunks) {
+			compressed2 = append(compressed2, b...)
+		}
+	}
+	// 两种方式压缩的结果相同
+	assert.Equal(t, compressed, compressed2)
+	assert.Equal(t, true, len(compressed) < len(fmt0))
+
+	for _, b := range [][]byte{fmt0, compressed} {
+		out := composeTestChunks(t, b, LocalChunkSize)
+		assert.Equal(t, len(msgs), len(out))
+		for i := range msgs {
+			out[i].Header.Csid = msgs[i].Header.Csid
+			assert.Equal(t, msgs[i].Header, out[i].Header)
+			assert.Equal(t, msgs[i].Payload, out[i].Payload)
+		}
+	}
+
+	// 多个message合并在一起压缩
+	d2.Reset()
+	var compressed3 []byte
+	for _, b := range d2.CompressChunks(fmt0) {
+		compressed3 = append(compressed3, b...)
+	}
+	assert.Equal(t, compressed, compressed3)
+
+	// 检查第一个chunk使用的fmt
+	d.Reset()
+	h := base.RtmpHeader{Csid: CsidAudio, MsgLen: 2, MsgTypeId: base.RtmpTypeIdAudio, MsgStreamId: Msid1, TimestampAbs: 100}
+	assert.Equal(t, byte(0x06), d.Message2Chunks([]byte{1, 2}, &h)[0])
+	h.TimestampAbs = 123
+	assert.Equal(t, []byte{0x86, 0, 0, 23, 1, 2}, d.Message2Chunks([]byte{1, 2}, &h))
+	h.TimestampAbs = 146
+	assert.Equal(t, []byte{0xc6, 1, 2}, d.Message2Chunks([]byte{1, 2}, &h))
+	h.MsgLen = 3
+	h.TimestampAbs = 170
+	assert.Equal(t, []byte{0x46, 0, 0, 24, 0, 0, 3, 8, 1, 2, 3}, d.Message2Chunks([]byte{1, 2, 3}, &h))
+
+	// 非 Message2Chunks 生成的数据原样返回
+	assert.Equal(t, net.Buffers{{0xc6, 1, 2}}, d.CompressChunks([]byte{0xc6, 1, 2}))
+}
+
+func TestChunkDividerWithChunkSize(t *testing.T) {
+	msgs := newTestChunkDividerMsgs()
+
+	var fmt0, compressed []byte
+	d := NewChunkDivider(MinChunkSize)
+	for _, msg := range msgs {
+		chunks := Message2ChunksWithSize(msg.Payload, &msg.Header, MinChunkSize)
+		fmt0 = append(fmt0, chunks...)
+		for _, b := range d.CompressChunks(chunks) {
+			compressed = append(compressed, b...)
+		}
+	}
+	assert.Equal(t, true, len(compressed) < len(fmt0))
+	for _, b := range [][]byte{fmt0, compressed} {
+		out := composeTestChunks(t, b, MinChunkSize)
+		assert.Equal(t, len(msgs), len(out))
+		for i := range msgs {
+			assert.Equal(t, msgs[i].Payload, out[i].Payload)
+		}
+	}
+
+	assert.Equal(t, LocalChunkSize, normalizeChunkSize(0))
+	assert.Equal(t, LocalChunkSize, normalizeChunkSize(MinChunkSize-1))
+	assert.Equal(t, LocalChunkSize, normalizeChunkSize(MaxChunkSize+1))
+	assert.Equal(t, MaxChunkSize, normalizeChunkSize(MaxChunkSize))
+}
+
+func benchmarkChunkDivider(b *testing.B, compress bool) {
+	msgs := newTestChunkDividerMsgs()
+	var chunksList [][]byte
+	var payloadLen int
+	for _, msg := range msgs {
+		chunksList = append(chunksList, Message2Chunks(msg.Payload, &msg.Header))
+		payloadLen += len(msg.Payload)
+	}
+
+	var totalLen int
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		totalLen = 0
+		d := NewChunkDivider(LocalChunkSize)
+		for _, chunks := range chunksList {
+			if !compress {
+				totalLen += len(chunks)
+				continue
+			}
+			for _, v := range d.CompressChunks(chunks) {
+				totalLen += len(v)
+			}
+		}
+	}
+	b.ReportMetric(float64(totalLen-payloadLen)/float64(len(msgs)), "header-B/msg")
+}
+
+// BenchmarkChunkDivider_Fmt0 和 BenchmarkChunkDivider_Compress 对比每个message的chunk header平均大小，
+// 比如: fmt0约12.1字节，压缩后约5.2字节
+func BenchmarkChunkDivider_Fmt0(b *testing.B) {
+	benchmarkChunkDivider(b, false)
+}
+
+func BenchmarkChunkDivider_Compress(b *testing.B) {
+	benchmarkChunkDivider(b, true)
+}
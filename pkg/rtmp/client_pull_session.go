@@ -39,6 +39,19 @@ type PullSessionOption struct {
 	// 业务方可以通过这个字段自定义 tls.Config
 	// 注意，如果使用rtmps并且该字段为nil，那么内部会使用 base.DefaultTlsConfigClient 生成 tls.Config
 	TlsConfig *tls.Config
+
+	// FcSubscribeEnable play之前是否发送FCSubscribe信令，部分CDN要求
+	FcSubscribeEnable bool
+
+	// ReconnectEnable 拉流成功之后连接断开时自动重连，重连成功后继续回调音视频数据，不需要创建新的 PullSession
+	// 具体见 ClientSessionOption 中的说明
+	ReconnectEnable        bool
+	ReconnectIntervalMs    int
+	ReconnectMaxIntervalMs int
+	ReconnectMaxTimes      int
+
+	// RedirectForwardCredentials 重定向到其他host:port时是否携带原地址的用户名密码，见 ClientSessionOption 中的说明
+	RedirectForwardCredentials bool
 }
 
 var defaultPullSessionOption = PullSessionOption{
@@ -48,6 +61,12 @@ var defaultPullSessionOption = PullSessionOption{
 	HandshakeComplexFlag:       false,
 	PeerWinAckSize:             0,
 	ReuseReadMessageBufferFlag: true,
+	FcSubscribeEnable:          false,
+	ReconnectEnable:            false,
+	ReconnectIntervalMs:        1000,
+	ReconnectMaxIntervalMs:     30000,
+	ReconnectMaxTimes:          0,
+	RedirectForwardCredentials: false,
 }
 
 type ModPullSessionOption func(option *PullSessionOption)
@@ -66,6 +85,13 @@ func NewPullSession(modOptions ...ModPullSessionOption) *PullSession {
 			option.HandshakeComplexFlag = opt.HandshakeComplexFlag
 			option.PeerWinAckSize = opt.PeerWinAckSize
 			option.ReuseReadMessageBufferFlag = opt.ReuseReadMessageBufferFlag
+			option.TlsConfig = opt.TlsConfig
+			option.FcSubscribeEnable = opt.FcSubscribeEnable
+			option.ReconnectEnable = opt.ReconnectEnable
+			option.ReconnectIntervalMs = opt.ReconnectIntervalMs
+			option.ReconnectMaxIntervalMs = opt.ReconnectMaxIntervalMs
+			option.ReconnectMaxTimes = opt.ReconnectMaxTimes
+			option.RedirectForwardCredentials = opt.RedirectForwardCredentials
 		}),
 	}
 }
@@ -10,13 +10,23 @@ package rtmp
 
 import (
 	"crypto/tls"
+	"sync"
+
 	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/naza/pkg/bele"
 )
 
 type PushSession struct {
 	IsFresh bool
 
 	core *ClientSession
+
+	// 开启自动重连时，缓存最新的metadata和seq header，重连成功后补发，并且丢弃视频数据直到关键帧
+	cacheMutex        sync.Mutex
+	metadata          []byte
+	videoSeqHeader    []byte
+	aacSeqHeader      []byte
+	waitVideoKeyFrame bool
 }
 
 type PushSessionOption struct {
@@ -35,14 +45,38 @@ type PushSessionOption struct {
 	// 业务方可以通过这个字段自定义 tls.Config
 	// 注意，如果使用rtmps并且该字段为nil，那么内部会使用 base.DefaultTlsConfigClient 生成 tls.Config
 	TlsConfig *tls.Config
+
+	// ReconnectEnable 推流成功之后连接断开时自动重连，重连成功后继续推流，不需要创建新的 PushSession
+	// 具体见 ClientSessionOption 中的说明
+	ReconnectEnable        bool
+	ReconnectIntervalMs    int
+	ReconnectMaxIntervalMs int
+	ReconnectMaxTimes      int
+
+	// RedirectForwardCredentials 重定向到其他host:port时是否携带原地址的用户名密码，见 ClientSessionOption 中的说明
+	RedirectForwardCredentials bool
+
+	// ChunkSize 见 ClientSessionOption 中的说明
+	ChunkSize int
+
+	// CompressChunkHeader 是否压缩音视频数据的chunk header，见 NewChunkDivider
+	// 开启后， Write 的参数需要是 Message2ChunksWithSize 生成的完整的message
+	CompressChunkHeader bool
 }
 
 var defaultPushSessionOption = PushSessionOption{
-	PushTimeoutMs:        10000,
-	WriteAvTimeoutMs:     0,
-	WriteBufSize:         0,
-	WriteChanSize:        0,
-	HandshakeComplexFlag: false,
+	PushTimeoutMs:              10000,
+	WriteAvTimeoutMs:           0,
+	WriteBufSize:               0,
+	WriteChanSize:              0,
+	HandshakeComplexFlag:       false,
+	ReconnectEnable:            false,
+	ReconnectIntervalMs:        1000,
+	ReconnectMaxIntervalMs:     30000,
+	ReconnectMaxTimes:          0,
+	ChunkSize:                  0,
+	CompressChunkHeader:        false,
+	RedirectForwardCredentials: false,
 }
 
 type ModPushSessionOption func(option *PushSessionOption)
@@ -52,7 +86,7 @@ func NewPushSession(modOptions ...ModPushSessionOption) *PushSession {
 	for _, fn := range modOptions {
 		fn(&opt)
 	}
-	return &PushSession{
+	s := &PushSession{
 		IsFresh: true,
 		core: NewClientSession(base.SessionTypeRtmpPush, func(option *ClientSessionOption) {
 			option.DoTimeoutMs = opt.PushTimeoutMs
@@ -60,8 +94,18 @@ func NewPushSession(modOptions ...ModPushSessionOption) *PushSession {
 			option.WriteBufSize = opt.WriteBufSize
 			option.WriteChanSize = opt.WriteChanSize
 			option.HandshakeComplexFlag = opt.HandshakeComplexFlag
+			option.TlsConfig = opt.TlsConfig
+			option.ReconnectEnable = opt.ReconnectEnable
+			option.ReconnectIntervalMs = opt.ReconnectIntervalMs
+			option.ReconnectMaxIntervalMs = opt.ReconnectMaxIntervalMs
+			option.ReconnectMaxTimes = opt.ReconnectMaxTimes
+			option.RedirectForwardCredentials = opt.RedirectForwardCredentials
+			option.ChunkSize = opt.ChunkSize
+			option.CompressChunkHeader = opt.CompressChunkHeader
 		}),
 	}
+	s.core.onReconnect = s.onReconnect
+	return s
 }
 
 // Start 阻塞直到和对端完成推流前，握手部分的工作（也即收到RTMP Publish response），或者发生错误
@@ -83,6 +127,9 @@ func (s *PushSession) Push(rawUrl string) error {
 func (s *PushSession) Write(b []byte) error {
 	// TODO(chef): [opt] 使用Write函数时确保metadata有@SetDataFrame 202207
 
+	if s.core.option.ReconnectEnable && !s.cacheOrDrop(b) {
+		return nil
+	}
 	return s.core.Write(b)
 }
 
@@ -91,7 +138,7 @@ func (s *PushSession) Write(b []byte) error {
 // 内部会根据 msg 的包头字段和包体数据，打包成 rtmp chunk 格式的数据，然后发送。
 // 如果想要自己控制打包过程，请使用 Write 函数直接发送数据。
 func (s *PushSession) WriteMsg(msg base.RtmpMsg) error {
-	return s.Write(Message2Chunks(msg.Payload, &msg.Header))
+	return s.Write(Message2ChunksWithSize(msg.Payload, &msg.Header, s.core.option.ChunkSize))
 }
 
 // Flush 将缓存的数据立即刷新发送
@@ -165,3 +212,105 @@ func (s *PushSession) UpdateStat(intervalSec uint32) {
 func (s *PushSession) IsAlive() (readAlive, writeAlive bool) {
 	return s.core.IsAlive()
 }
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+func (s *PushSession) onReconnect() {
+	s.cacheMutex.Lock()
+	defer s.cacheMutex.Unlock()
+
+	for _, b := range [][]byte{s.metadata, s.videoSeqHeader, s.aacSeqHeader} {
+		if b != nil {
+			_ = s.core.writeWhenReconnect(b)
+		}
+	}
+	_ = s.core.conn.Flush()
+	s.waitVideoKeyFrame = s.videoSeqHeader != nil
+}
+
+// cacheOrDrop 缓存metadata和seq header
+//
+// @return 重连后还没有收到视频关键帧时，视频数据返回false，表示需要丢弃
+func (s *PushSession) cacheOrDrop(b []byte) bool {
+	typeId, payload, ok := peekFirstChunkPayload(b)
+	if !ok {
+		return true
+	}
+	msg := base.RtmpMsg{
+		Header:  base.RtmpHeader{MsgTypeId: typeId},
+		Payload: payload,
+	}
+
+	s.cacheMutex.Lock()
+	defer s.cacheMutex.Unlock()
+
+	switch typeId {
+	case base.RtmpTypeIdMetadata:
+		s.metadata = append([]byte(nil), b...)
+	case base.RtmpTypeIdVideo:
+		if len(payload) < 5 {
+			return true
+		}
+		if msg.IsVideoKeySeqHeader() {
+			s.videoSeqHeader = append([]byte(nil), b...)
+			return true
+		}
+		if s.waitVideoKeyFrame {
+			if !msg.IsVideoKeyNalu() {
+				return false
+			}
+			s.waitVideoKeyFrame = false
+		}
+	case base.RtmpTypeIdAudio:
+		if len(payload) >= 2 && msg.IsAacSeqHeader() {
+			s.aacSeqHeader = append([]byte(nil), b...)
+		}
+	}
+	return true
+}
+
+// peekFirstChunkPayload 解析一个message序列化后的chunk数据，获取message类型以及第一个chunk中的payload
+//
+// 只支持第一个chunk为fmt0或fmt1
+func peekFirstChunkPayload(b []byte) (typeId uint8, payload []byte, ok bool) {
+	if len(b) < 1 {
+		return
+	}
+	fmt := b[0] >> 6
+	index := 1
+	switch b[0] & 0x3f {
+	case 0:
+		index = 2
+	case 1:
+		index = 3
+	}
+
+	var headerSize int
+	switch fmt {
+	case 0:
+		headerSize = 11
+	case 1:
+		headerSize = 7
+	default:
+		return
+	}
+	if len(b) < index+headerSize {
+		return
+	}
+	timestamp := bele.BeUint24(b[index:])
+	msgLen := bele.BeUint24(b[index+3:])
+	typeId = b[index+6]
+	index += headerSize
+	if timestamp == maxTimestampInMessageHeader {
+		index += 4
+	}
+	if len(b) < index {
+		return
+	}
+
+	payload = b[index:]
+	if uint32(len(payload)) > msgLen {
+		payload = payload[:msgLen]
+	}
+	return typeId, payload, true
+}
@@ -10,10 +10,9 @@ package rtmp
 
 import (
 	"context"
-	"crypto/md5"
 	"crypto/tls"
-	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net"
 	"strings"
@@ -24,6 +23,7 @@ import (
 
 	"github.com/q191201771/naza/pkg/bele"
 	"github.com/q191201771/naza/pkg/connection"
+	"github.com/q191201771/naza/pkg/nazaerrors"
 )
 
 // ClientSession rtmp 客户端类型连接的底层实现
@@ -34,13 +34,19 @@ type ClientSession struct {
 	// 只有PullSession使用
 	onReadRtmpAvMsg OnReadRtmpAvMsg
 
+	// 开启自动重连时，重连成功并且收到publish或play的结果后回调，此时上层调用 Write 写入的数据依然会被丢弃，
+	// 回调中可以使用 writeWhenReconnect 补发metadata、seq header等
+	onReconnect func()
+
 	option ClientSessionOption
 
 	packer        *MessagePacker
 	chunkComposer *ChunkComposer
+	chunkDivider  *ChunkDivider // 为nil时不压缩发送的音视频数据的chunk header，每个连接使用新的
 	urlCtx        base.UrlContext
 	hc            IHandshakeClient
 
+	connMutex             sync.Mutex // 开启自动重连时，读取协程会替换conn，所以其他协程访问conn时需要加锁
 	conn                  connection.Connection
 	doResultChan          chan struct{}
 	errChan               chan error
@@ -55,13 +61,20 @@ type ClientSession struct {
 	seqNum      uint32
 
 	disposeOnce sync.Once
-	authInfo    AuthInfo
-}
+	disposeChan chan struct{}
+	doneChan    chan error // 开启自动重连时 WaitChan 返回的chan
+
+	// connect阶段服务端要求鉴权时，追加在app和tcUrl后面的鉴权参数，见 auth.go
+	authQuery string
+	authTried bool
 
-type AuthInfo struct {
-	challenge string
-	salt      string
-	opaque    string
+	// connect阶段服务端返回重定向时，重定向后的地址
+	redirectUrlCtx *base.UrlContext
+	redirectCount  int
+
+	hasStartSucc       bool // Start 是否成功过，成功之后连接断开才会自动重连
+	reconnecting       bool // 是否处于断线重连中，重连期间上层写入的数据会被丢弃
+	reconnectFailCount int  // 连续重连失败的次数
 }
 
 type ClientSessionOption struct {
@@ -89,6 +102,36 @@ type ClientSessionOption struct {
 	// 业务方可以通过这个字段自定义 tls.Config
 	// 注意，如果使用rtmps并且该字段为nil，那么内部会使用 base.DefaultTlsConfigClient 生成 tls.Config
 	TlsConfig *tls.Config
+
+	// FcSubscribeEnable
+	// 拉流时，是否在play之前发送FCSubscribe信令，部分CDN要求先发送FCSubscribe才能拉流
+	FcSubscribeEnable bool
+
+	// RedirectForwardCredentials
+	//
+	// 服务端返回重定向，并且重定向地址没有携带用户名密码时，是否使用原地址的用户名密码鉴权。
+	// 重定向地址的host:port和原地址相同时总是使用，不同时只有该字段为true才使用，避免用户名密码泄露给任意的重定向地址
+	RedirectForwardCredentials bool
+
+	// ReconnectEnable
+	//
+	// Start 成功之后，连接断开时是否自动重连。
+	// 重连使用同一个session对象，期间 WaitChan 不会返回，直到调用 Dispose 或者连续重连失败的次数达到 ReconnectMaxTimes 。
+	// 重连期间写入的数据会被丢弃。
+	ReconnectEnable bool
+	// ReconnectIntervalMs 第一次重连前等待的时间，之后每次失败翻倍，直到 ReconnectMaxIntervalMs
+	ReconnectIntervalMs    int
+	ReconnectMaxIntervalMs int
+	// ReconnectMaxTimes 连续重连失败的最大次数，如果为0，则一直重连
+	ReconnectMaxTimes int
+
+	// ChunkSize 本端发送数据时的chunk size，为0或者超出 MinChunkSize ~ MaxChunkSize 的范围时使用 LocalChunkSize
+	// 注意，推流时 Write 的参数需要使用相同的chunk size切割，见 Message2ChunksWithSize
+	ChunkSize int
+
+	// CompressChunkHeader 推流时是否压缩发送的音视频数据的chunk header，见 NewChunkDivider
+	// 开启后， Write 的参数需要是 Message2ChunksWithSize 生成的完整的message
+	CompressChunkHeader bool
 }
 
 var defaultClientSessOption = ClientSessionOption{
@@ -101,8 +144,22 @@ var defaultClientSessOption = ClientSessionOption{
 	HandshakeComplexFlag:       false,
 	PeerWinAckSize:             0,
 	ReuseReadMessageBufferFlag: true,
+	FcSubscribeEnable:          false,
+	RedirectForwardCredentials: false,
+	ReconnectEnable:            false,
+	ReconnectIntervalMs:        1000,
+	ReconnectMaxIntervalMs:     30000,
+	ReconnectMaxTimes:          0,
+	ChunkSize:                  0,
+	CompressChunkHeader:        false,
 }
 
+// 最多跟随的重定向次数，避免服务端之间循环重定向
+const maxRedirectCount = 3
+
+// errClientSessionReconnect 鉴权或者重定向时，需要关闭当前连接并立即发起新的连接
+var errClientSessionReconnect = errors.New("lal.rtmp: client session reconnect")
+
 type ModClientSessionOption func(option *ClientSessionOption)
 
 // NewClientSession @param t: session的类型，只能是推或者拉
@@ -122,22 +179,23 @@ func NewClientSession(sessionType base.SessionType, modOptions ...ModClientSessi
 	if option.TlsConfig == nil {
 		option.TlsConfig = base.DefaultTlsConfigClient()
 	}
-
-	cc := NewChunkComposer()
-	cc.SetReuseBufferFlag(option.ReuseReadMessageBufferFlag)
+	option.ChunkSize = normalizeChunkSize(option.ChunkSize)
 
 	s := &ClientSession{
 		onDoResult:                 defaultOnPullResult,
 		onReadRtmpAvMsg:            defaultOnReadRtmpAvMsg,
+		onReconnect:                defaultOnReconnect,
 		option:                     option,
 		doResultChan:               make(chan struct{}, 1),
 		packer:                     NewMessagePacker(),
-		chunkComposer:              cc,
 		sessionStat:                base.NewBasicSessionStat(sessionType, ""),
 		debugLogReadUserCtrlMsgMax: 5,
 		hc:                         hc,
 		errChan:                    make(chan error, 1),
+		disposeChan:                make(chan struct{}),
+		doneChan:                   make(chan error, 1),
 	}
+	s.packer.chunkSize = option.ChunkSize
 	Log.Infof("[%s] lifecycle new rtmp ClientSession. session=%p", s.UniqueKey(), s)
 	return s
 }
@@ -170,18 +228,54 @@ func (s *ClientSession) Start(rawUrl string) error {
 }
 
 func (s *ClientSession) Write(msg []byte) error {
-	if s.conn == nil {
+	s.connMutex.Lock()
+	conn, reconnecting := s.conn, s.reconnecting
+	var bs net.Buffers
+	if !reconnecting && s.chunkDivider != nil {
+		// 和替换conn时重置 chunkDivider 互斥
+		bs = s.chunkDivider.CompressChunks(msg)
+	}
+	s.connMutex.Unlock()
+
+	if reconnecting {
+		return nil
+	}
+	if conn == nil {
 		return base.ErrSessionNotStarted
 	}
-	_, err := s.conn.Write(msg)
+	if bs != nil {
+		_, err := conn.Writev(bs)
+		return err
+	}
+	_, err := conn.Write(msg)
 	return err
 }
 
 func (s *ClientSession) Flush() error {
-	if s.conn == nil {
+	s.connMutex.Lock()
+	conn, reconnecting := s.conn, s.reconnecting
+	s.connMutex.Unlock()
+
+	if reconnecting {
+		return nil
+	}
+	if conn == nil {
 		return base.ErrSessionNotStarted
 	}
-	return s.conn.Flush()
+	return conn.Flush()
+}
+
+// writeWhenReconnect 只在 onReconnect 回调中使用，不受重连期间丢弃数据的限制
+func (s *ClientSession) writeWhenReconnect(msg []byte) error {
+	if s.chunkDivider != nil {
+		s.connMutex.Lock()
+		bs := s.chunkDivider.CompressChunks(msg)
+		s.connMutex.Unlock()
+		_, err := s.conn.Writev(bs)
+		return err
+	}
+	_, err := s.conn.Write(msg)
+	return err
 }
 
 // ---------------------------------------------------------------------------------------------------------------------
@@ -195,7 +289,10 @@ func (s *ClientSession) Dispose() error {
 
 // WaitChan 文档请参考： IClientSessionLifecycle interface
 func (s *ClientSession) WaitChan() <-chan error {
-	return s.conn.Done()
+	if s.option.ReconnectEnable {
+		return s.doneChan
+	}
+	return s.getConn().Done()
 }
 
 // ---------------------------------------------------------------------------------------------------------------------
@@ -223,47 +320,117 @@ func (s *ClientSession) UniqueKey() string {
 // ----- ISessionStat --------------------------------------------------------------------------------------------------
 
 func (s *ClientSession) GetStat() base.StatSession {
-	return s.sessionStat.GetStatWithConn(s.conn)
+	return s.sessionStat.GetStatWithConn(s.getConn())
 }
 
 func (s *ClientSession) UpdateStat(intervalSec uint32) {
-	s.sessionStat.UpdateStatWitchConn(s.conn, intervalSec)
+	s.sessionStat.UpdateStatWitchConn(s.getConn(), intervalSec)
 }
 
 func (s *ClientSession) IsAlive() (readAlive, writeAlive bool) {
-	return s.sessionStat.IsAliveWitchConn(s.conn)
+	return s.sessionStat.IsAliveWitchConn(s.getConn())
 }
 
 // ---------------------------------------------------------------------------------------------------------------------
 
-func (s *ClientSession) connect() {
+func (s *ClientSession) connect() error {
 	if err := s.tcpConnect(); err != nil {
-		s.errChan <- err
-		return
+		return err
 	}
 
 	if err := s.handshake(); err != nil {
-		s.errChan <- err
-		return
+		return err
 	}
 
-	Log.Infof("[%s] > W SetChunkSize %d.", s.UniqueKey(), LocalChunkSize)
-	if err := s.packer.writeChunkSize(s.conn, LocalChunkSize); err != nil {
-		s.errChan <- err
-		return
+	Log.Infof("[%s] > W SetChunkSize %d.", s.UniqueKey(), s.option.ChunkSize)
+	if err := s.packer.writeChunkSize(s.conn, s.option.ChunkSize); err != nil {
+		return err
 	}
 
-	Log.Infof("[%s] > W connect('%s'). tcUrl=%s", s.UniqueKey(), s.appName(), s.tcUrl())
-	if err := s.packer.writeConnect(s.conn, s.appName(), s.tcUrl(), s.sessionStat.BaseType() == base.SessionBaseTypePushStr); err != nil {
-		s.errChan <- err
-		return
+	Log.Infof("[%s] > W connect('%s'). tcUrl=%s", s.UniqueKey(), s.appName()+s.authQuery, s.tcUrl()+s.authQuery)
+	return s.packer.writeConnect(s.conn, s.appName()+s.authQuery, s.tcUrl()+s.authQuery, s.sessionStat.BaseType() == base.SessionBaseTypePushStr)
+}
+
+// runLoop 建立连接并读取数据，鉴权、重定向以及自动重连都在这里发起新的连接，阻塞直到session结束
+func (s *ClientSession) runLoop() {
+	for {
+		err := s.connect()
+		if err == nil {
+			err = s.runReadLoop()
+		}
+		if conn := s.getConn(); conn != nil {
+			_ = conn.Close()
+		}
+
+		if s.isDisposed() {
+			return
+		}
+
+		if errors.Is(err, errClientSessionReconnect) {
+			continue
+		}
+
+		// 还没有收到publish或play的结果，比如鉴权失败，通知 Start 尽快返回，而不是等待超时
+		if !s.hasStartSucc {
+			select {
+			case s.errChan <- err:
+			default:
+			}
+			_ = s.dispose(err)
+			return
+		}
+
+		// 鉴权失败重连也无法恢复
+		if !s.option.ReconnectEnable || errors.Is(err, base.ErrRtmpAuth) || !s.waitReconnect(err) {
+			_ = s.dispose(err)
+			return
+		}
+	}
+}
+
+// waitReconnect 连接断开后，等待一段时间再重连
+//
+// @return 是否需要重连，调用了 Dispose 或者连续重连失败的次数达到上限时返回false
+func (s *ClientSession) waitReconnect(err error) bool {
+	if s.option.ReconnectMaxTimes > 0 && s.reconnectFailCount >= s.option.ReconnectMaxTimes {
+		Log.Warnf("[%s] reconnect fail too many times. count=%d", s.UniqueKey(), s.reconnectFailCount)
+		return false
+	}
+
+	interval := s.option.ReconnectIntervalMs
+	for i := 0; i < s.reconnectFailCount && interval < s.option.ReconnectMaxIntervalMs; i++ {
+		interval *= 2
+	}
+	if interval > s.option.ReconnectMaxIntervalMs {
+		interval = s.option.ReconnectMaxIntervalMs
+	}
+	s.reconnectFailCount++
+
+	Log.Warnf("[%s] connection lost, reconnect after %dms. count=%d, err=%+v", s.UniqueKey(), interval, s.reconnectFailCount, err)
+
+	s.connMutex.Lock()
+	s.reconnecting = true
+	s.connMutex.Unlock()
+
+	select {
+	case <-s.disposeChan:
+		return false
+	case <-time.After(time.Duration(interval) * time.Millisecond):
 	}
 
-	s.runReadLoop()
+	// 从原始地址开始，重新走一遍重定向和鉴权的流程
+	s.redirectUrlCtx = nil
+	s.redirectCount = 0
+	s.authQuery = ""
+	s.authTried = false
+	s.hasNotifyDoResultSucc = false
+	s.recvLastAck = 0
+	s.seqNum = 0
+	return true
 }
 
 func (s *ClientSession) doContext(ctx context.Context) error {
-	go s.connect()
+	go s.runLoop()
 
 	select {
 	case <-ctx.Done():
@@ -286,41 +453,82 @@ func (s *ClientSession) parseUrl(rawUrl string) (err error) {
 	return
 }
 
+// connUrlCtx 建立连接使用的地址，发生重定向时为重定向后的地址
+func (s *ClientSession) connUrlCtx() *base.UrlContext {
+	if s.redirectUrlCtx != nil {
+		return s.redirectUrlCtx
+	}
+	return &s.urlCtx
+}
+
 func (s *ClientSession) tcUrl() string {
-	return fmt.Sprintf("%s://%s/%s", s.urlCtx.Scheme, s.urlCtx.StdHost, s.urlCtx.PathWithoutLastItem)
+	urlCtx := s.connUrlCtx()
+	return fmt.Sprintf("%s://%s/%s", urlCtx.Scheme, urlCtx.StdHost, urlCtx.PathWithoutLastItem)
 }
 func (s *ClientSession) appName() string {
-	return s.urlCtx.PathWithoutLastItem
+	return s.connUrlCtx().PathWithoutLastItem
 }
 
 func (s *ClientSession) streamNameWithRawQuery() string {
-	if s.urlCtx.RawQuery == "" {
-		return s.urlCtx.LastItemOfPath
+	urlCtx := s.connUrlCtx()
+	if urlCtx.RawQuery == "" {
+		return urlCtx.LastItemOfPath
+	}
+	return fmt.Sprintf("%s?%s", urlCtx.LastItemOfPath, urlCtx.RawQuery)
+}
+
+func (s *ClientSession) getConn() connection.Connection {
+	s.connMutex.Lock()
+	defer s.connMutex.Unlock()
+	return s.conn
+}
+
+func (s *ClientSession) isDisposed() bool {
+	select {
+	case <-s.disposeChan:
+		return true
+	default:
+		return false
 	}
-	return fmt.Sprintf("%s?%s", s.urlCtx.LastItemOfPath, s.urlCtx.RawQuery)
 }
 
 func (s *ClientSession) tcpConnect() error {
 	Log.Infof("[%s] > tcp connect.", s.UniqueKey())
 	var err error
 
-	s.sessionStat.SetRemoteAddr(s.urlCtx.HostWithPort)
+	urlCtx := s.connUrlCtx()
+	s.sessionStat.SetRemoteAddr(urlCtx.HostWithPort)
 
 	var conn net.Conn
-	if s.urlCtx.Scheme == "rtmps" {
-		if conn, err = tls.Dial("tcp", s.urlCtx.HostWithPort, s.option.TlsConfig); err != nil {
+	if urlCtx.Scheme == "rtmps" {
+		if conn, err = tls.Dial("tcp", urlCtx.HostWithPort, s.option.TlsConfig); err != nil {
 			return err
 		}
 	} else {
-		if conn, err = net.Dial("tcp", s.urlCtx.HostWithPort); err != nil {
+		if conn, err = net.Dial("tcp", urlCtx.HostWithPort); err != nil {
 			return err
 		}
 	}
 
+	// 每个连接使用新的 ChunkComposer ，避免上一个连接残留的chunk状态
+	s.chunkComposer = NewChunkComposer()
+	s.chunkComposer.SetReuseBufferFlag(s.option.ReuseReadMessageBufferFlag)
+
+	s.connMutex.Lock()
 	s.conn = connection.New(conn, func(option *connection.Option) {
 		option.ReadBufSize = s.option.ReadBufSize
 		option.WriteChanFullBehavior = connection.WriteChanFullBehaviorBlock
 	})
+	if s.option.CompressChunkHeader && s.sessionStat.BaseType() == base.SessionBaseTypePushStr {
+		s.chunkDivider = NewChunkDivider(s.option.ChunkSize)
+	}
+	s.connMutex.Unlock()
+
+	// 建立连接的过程中调用了 Dispose
+	if s.isDisposed() {
+		_ = s.conn.Close()
+		return base.ErrSessionNotStarted
+	}
 	return nil
 }
 
@@ -347,10 +555,8 @@ func (s *ClientSession) handshake() error {
 	return nil
 }
 
-func (s *ClientSession) runReadLoop() {
-	if err := s.chunkComposer.RunLoop(s.conn, s.doMsg); err != nil {
-		_ = s.dispose(err)
-	}
+func (s *ClientSession) runReadLoop() error {
+	return s.chunkComposer.RunLoop(s.conn, s.doMsg)
 }
 
 func (s *ClientSession) doMsg(stream *Stream) error {
@@ -367,8 +573,12 @@ func (s *ClientSession) doMsg(stream *Stream) error {
 		return s.doProtocolControlMessage(stream)
 	case base.RtmpTypeIdCommandMessageAmf0:
 		return s.doCommandMessage(stream)
+	case base.RtmpTypeIdCommandMessageAmf3:
+		return s.doCommandAmf3Message(stream)
 	case base.RtmpTypeIdMetadata:
 		return s.doDataMessageAmf0(stream)
+	case base.RtmpTypeIdDataMessageAmf3:
+		return s.doDataMessageAmf3(stream)
 	case base.RtmpTypeIdAck:
 		return s.doAck(stream)
 	case base.RtmpTypeIdUserControl:
@@ -421,6 +631,23 @@ func (s *ClientSession) doDataMessageAmf0(stream *Stream) error {
 	return nil
 }
 
+func (s *ClientSession) doDataMessageAmf3(stream *Stream) error {
+	amf0Stream, err := stream.amf3DataToAmf0()
+	if err != nil {
+		return err
+	}
+	return s.doDataMessageAmf0(amf0Stream)
+}
+
+func (s *ClientSession) doCommandAmf3Message(stream *Stream) error {
+	// 去除前面的0就是Amf0的数据，其中的值可能通过avmplus-object-marker切换为amf3编码
+	if stream.msg.Len() < 1 {
+		return base.NewErrRtmpShortBuffer(1, 0, "ClientSession::doCommandAmf3Message")
+	}
+	stream.msg.Skip(1)
+	return s.doCommandMessage(stream)
+}
+
 func (s *ClientSession) doCommandMessage(stream *Stream) error {
 	cmd, err := stream.msg.readStringWithType()
 	if err != nil {
@@ -441,6 +668,8 @@ func (s *ClientSession) doCommandMessage(stream *Stream) error {
 		return s.doOnStatusMessage(stream, tid)
 	case "_error":
 		return s.doErrorMessage(stream, tid)
+	case "onFCSubscribe":
+		Log.Infof("[%s] < R onFCSubscribe.", s.UniqueKey())
 	default:
 		Log.Errorf("[%s] read unknown command message. cmd=%s, %s", s.UniqueKey(), cmd, stream.toDebugString())
 	}
@@ -456,75 +685,113 @@ func (s *ClientSession) doErrorMessage(stream *Stream, tid int) error {
 	if err != nil {
 		return err
 	}
-	if s.sessionStat.BaseType() == base.SessionBaseTypePushStr {
-		description, err := infos.FindString("description")
-		if err != nil {
+	if redirect := findRedirect(infos); redirect != "" {
+		return s.dealRedirect(redirect)
+	}
+	description, err := infos.FindString("description")
+	if err != nil {
+		if s.sessionStat.BaseType() == base.SessionBaseTypePushStr {
 			return err
 		}
-
+		return nil
+	}
+	// 拉流时只处理鉴权相关的错误
+	if s.sessionStat.BaseType() == base.SessionBaseTypePushStr || strings.Contains(description, "authmod=") {
 		return s.dealErrorMessage(description)
 	}
 
 	return nil
 }
 
-func (s *ClientSession) parseAuthorityInfo(auth string) {
-	// 解析salt、challenge、opaque字段
-	res := strings.Split(auth, "&")
-	for _, info := range res {
-		if pos := strings.IndexAny(info, "="); pos > 0 {
-			switch info[:pos] {
-			case "salt":
-				s.authInfo.salt = info[pos+1:]
-			case "challenge":
-				s.authInfo.challenge = info[pos+1:]
-			case "opaque":
-				s.authInfo.opaque = info[pos+1:]
+// dealErrorMessage 处理connect阶段服务端要求的adobe或llnw鉴权，见 auth.go
+func (s *ClientSession) dealErrorMessage(description string) (err error) {
+	if !strings.Contains(description, "authmod=") {
+		return fmt.Errorf("invalid errmessage: %s", description)
+	}
+	if strings.Contains(description, "?reason=authfailed") || strings.Contains(description, "?reason=nosuchuser") {
+		return nazaerrors.Wrap(base.ErrRtmpAuth, description)
+	}
+	if s.connUrlCtx().Username == "" {
+		return nazaerrors.Wrap(base.ErrRtmpAuth, "need username and password. "+description)
+	}
+	// 只尝试一次，避免服务端一直要求鉴权时无限重连
+	if s.authTried {
+		return nazaerrors.Wrap(base.ErrRtmpAuth, description)
+	}
+
+	mod := AuthModAdobe
+	if strings.Contains(description, "authmod="+AuthModLlnw) {
+		mod = AuthModLlnw
+	}
+	user := s.connUrlCtx().Username
+	password := s.connUrlCtx().Password
+
+	if strings.Contains(description, "code=403 need auth") {
+		s.authQuery = fmt.Sprintf("?authmod=%s&user=%s", mod, user)
+	} else if pos := strings.Index(description, "?reason=needauth"); pos != -1 {
+		params := parseAuthParams(description[pos:])
+		switch mod {
+		case AuthModAdobe:
+			clientChallenge := randomAuthString()
+			response := AdobeAuthResponse(user, password, params["salt"], params["challenge"], params["opaque"], clientChallenge)
+			s.authQuery = fmt.Sprintf("?authmod=%s&user=%s&challenge=%s&response=%s", mod, user, clientChallenge, response)
+			if params["opaque"] != "" {
+				s.authQuery += "&opaque=" + params["opaque"]
 			}
+		case AuthModLlnw:
+			cnonce := randomAuthString()
+			response := LlnwAuthResponse(user, password, params["nonce"], cnonce, llnwNc, s.appName())
+			s.authQuery = fmt.Sprintf("?authmod=%s&user=%s&nonce=%s&cnonce=%s&nc=%s&response=%s", mod, user, params["nonce"], cnonce, llnwNc, response)
 		}
+		s.authTried = true
+	} else {
+		return fmt.Errorf("invalid errmessage: %s", description)
 	}
-}
 
-func (s *ClientSession) dealErrorMessage(description string) (err error) {
-	if strings.Contains(description, "code=403 need auth") {
-		// app和tcUrl需要加上streamid、authmod、user
-		s.urlCtx.PathWithoutLastItem = fmt.Sprintf("%s/%s?authmod=adobe&user=%s", s.urlCtx.PathWithoutLastItem, s.urlCtx.LastItemOfPath, s.urlCtx.Username)
-
-		//关闭上一次连接并发起新的连接
-		s.conn.Close()
-		s.connect()
-	} else if strings.Contains(description, "?reason=needauth") {
-		descriptions := strings.Split(description, ":")
-		if len(descriptions) != 3 {
-			err = fmt.Errorf("inavlid message: %s", description)
-			return err
-		} else {
-			descriptions[2] = strings.Replace(descriptions[2], " ", "", -1)
-			replacestr := fmt.Sprintf("?reason=needauth&user=%s&", s.urlCtx.Username)
-			authinfo := strings.Replace(descriptions[2], replacestr, "", -1)
+	// 关闭上一次连接并发起新的连接
+	Log.Infof("[%s] reconnect with auth. authmod=%s", s.UniqueKey(), mod)
+	return errClientSessionReconnect
+}
 
-			s.parseAuthorityInfo(authinfo)
+// dealRedirect 处理connect阶段服务端返回的重定向，使用重定向后的地址发起新的连接
+//
+// @param redirect: 重定向后的tcUrl，比如 rtmp://127.0.0.1:1935/live
+func (s *ClientSession) dealRedirect(redirect string) error {
+	if s.redirectCount >= maxRedirectCount {
+		return fmt.Errorf("too many redirects. count=%d, redirect=%s", s.redirectCount, redirect)
+	}
 
-			// base64(md5(username|salt|password))作为新的salt1
-			mds := md5.Sum([]byte(s.urlCtx.Username + s.authInfo.salt + s.urlCtx.Password))
-			salt1 := base64.StdEncoding.EncodeToString(mds[:])
+	urlCtx, err := base.ParseRtmpUrl(fmt.Sprintf("%s/%s", strings.TrimSuffix(redirect, "/"), s.streamNameWithRawQuery()))
+	if err != nil {
+		return nazaerrors.Wrap(err, redirect)
+	}
+	// 重定向地址没有携带用户名密码时，使用原地址的用户名密码鉴权，见 ClientSessionOption.RedirectForwardCredentials
+	if urlCtx.Username == "" && (urlCtx.HostWithPort == s.urlCtx.HostWithPort || s.option.RedirectForwardCredentials) {
+		urlCtx.Username = s.urlCtx.Username
+		urlCtx.Password = s.urlCtx.Password
+	}
 
-			// response = base64(md5(salt1|opaque|challenge))
-			mds1 := md5.Sum([]byte(salt1 + s.authInfo.opaque + s.authInfo.challenge))
-			response := base64.StdEncoding.EncodeToString(mds1[:])
+	s.redirectCount++
+	s.redirectUrlCtx = &urlCtx
+	s.authQuery = ""
+	s.authTried = false
 
-			// app和tcUrl需要加上challenge、response、opaque字段
-			s.urlCtx.PathWithoutLastItem = fmt.Sprintf("%s&challenge=%s&response=%s&opaque=%s", s.urlCtx.PathWithoutLastItem, s.authInfo.challenge, response, s.authInfo.opaque)
+	Log.Infof("[%s] < R _error() redirect. redirect=%s, count=%d", s.UniqueKey(), redirect, s.redirectCount)
+	return errClientSessionReconnect
+}
 
-			// 关闭前一个连接并发起新的连接
-			s.conn.Close()
-			s.connect()
-		}
-	} else {
-		err = fmt.Errorf("invalid errmessage: %s", description)
-		return err
+// findRedirect 服务端通过connect的_error返回重定向时，重定向地址在info对象的ex.redirect中，
+// 比如 {level: "error", code: "NetConnection.Connect.Rejected", ex: {code: 302, redirect: "rtmp://host/app"}}
+func findRedirect(infos ObjectPairArray) string {
+	ex, ok := infos.Find("ex").(ObjectPairArray)
+	if !ok {
+		return ""
 	}
-	return nil
+	redirect, err := ex.FindString("redirect")
+	if err != nil {
+		return ""
+	}
+	return redirect
 }
 
 func (s *ClientSession) doOnStatusMessage(stream *Stream, tid int) error {
@@ -579,6 +846,12 @@ func (s *ClientSession) doResultMessage(stream *Stream, tid int) error {
 		switch code {
 		case "NetConnection.Connect.Success":
 			Log.Infof("[%s] < R _result(\"NetConnection.Connect.Success\").", s.UniqueKey())
+			if s.option.FcSubscribeEnable && s.sessionStat.BaseType() == base.SessionBaseTypePullStr {
+				Log.Infof("[%s] > W FCSubscribe('%s').", s.UniqueKey(), s.streamNameWithRawQuery())
+				if err := s.packer.writeFcSubscribe(s.conn, s.streamNameWithRawQuery()); err != nil {
+					return err
+				}
+			}
 			Log.Infof("[%s] > W createStream().", s.UniqueKey())
 			if err := s.packer.writeCreateStream(s.conn); err != nil {
 				return err
@@ -608,6 +881,8 @@ func (s *ClientSession) doResultMessage(stream *Stream, tid int) error {
 				return err
 			}
 		}
+	case tidClientFcSubscribe:
+		Log.Infof("[%s] < R _result() of FCSubscribe.", s.UniqueKey())
 	default:
 		Log.Errorf("[%s] unknown tid. tid=%d", s.UniqueKey(), tid)
 	}
@@ -677,6 +952,18 @@ func (s *ClientSession) notifyDoResultSucc() {
 	s.conn.ModReadTimeoutMs(s.option.ReadAvTimeoutMs)
 	s.conn.ModWriteTimeoutMs(s.option.WriteAvTimeoutMs)
 
+	if s.hasStartSucc {
+		Log.Infof("[%s] reconnect succ.", s.UniqueKey())
+		s.reconnectFailCount = 0
+		s.onReconnect()
+
+		s.connMutex.Lock()
+		s.reconnecting = false
+		s.connMutex.Unlock()
+		return
+	}
+	s.hasStartSucc = true
+
 	s.onDoResult()
 	s.doResultChan <- struct{}{}
 }
@@ -685,11 +972,15 @@ func (s *ClientSession) dispose(err error) error {
 	var retErr error
 	s.disposeOnce.Do(func() {
 		Log.Infof("[%s] lifecycle dispose rtmp ClientSession. err=%+v", s.UniqueKey(), err)
-		if s.conn == nil {
+		close(s.disposeChan)
+		s.doneChan <- err
+
+		conn := s.getConn()
+		if conn == nil {
 			retErr = base.ErrSessionNotStarted
 			return
 		}
-		retErr = s.conn.Close()
+		retErr = conn.Close()
 	})
 	return retErr
 }
@@ -697,6 +988,9 @@ func (s *ClientSession) dispose(err error) error {
 func defaultOnPullResult() {
 }
 
+func defaultOnReconnect() {
+}
+
 func defaultOnReadRtmpAvMsg(msg base.RtmpMsg) {
 
 }
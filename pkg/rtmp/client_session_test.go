@@ -0,0 +1,238 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtmp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/naza/pkg/assert"
+)
+
+type testReconnectServerObserver struct {
+	testAuthServerObserver
+
+	pubSessionChan chan *ServerSession
+	avMsgChan      chan base.RtmpMsg
+}
+
+func (o *testReconnectServerObserver) OnNewRtmpPubSession(session *ServerSession) error {
+	session.SetPubSessionObserver(o)
+	o.pubSessionChan <- session
+	return nil
+}
+
+func (o *testReconnectServerObserver) OnReadRtmpAvMsg(msg base.RtmpMsg) {
+	msg.Payload = append([]byte(nil), msg.Payload...)
+	o.avMsgChan <- msg
+}
+
+// runTestRedirectServer 对所有connect都回复重定向到 redirect
+func runTestRedirectServer(t *testing.T, redirect string) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Equal(t, nil, err)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				var hs HandshakeServer
+				if hs.ReadC0C1(conn) != nil || hs.WriteS0S1S2(conn) != nil || hs.ReadC2(conn) != nil {
+					return
+				}
+				packer := NewMessagePacker()
+				if packer.writeChunkSize(conn, LocalChunkSize) != nil {
+					return
+				}
+				_ = NewChunkComposer().RunLoop(conn, func(stream *Stream) error {
+					if stream.header.MsgTypeId != base.RtmpTypeIdCommandMessageAmf0 {
+						return nil
+					}
+					packer.b.ModWritePos(12)
+					_ = Amf0.WriteString(packer.b, "_error")
+					_ = Amf0.WriteNumber(packer.b, float64(tidClientConnect))
+					_ = Amf0.WriteNull(packer.b)
+					_ = Amf0.WriteObject(packer.b, ObjectPairArray{
+						{Key: "level", Value: "error"},
+						{Key: "code", Value: "NetConnection.Connect.Rejected"},
+						{Key: "description", Value: "Connection failed: Application rejected connection."},
+						{Key: "ex", Value: ObjectPairArray{
+							{Key: "code", Value: 302},
+							{Key: "redirect", Value: redirect},
+						}},
+					})
+					_ = packer.ChunkAndWrite(conn, csidOverConnection, base.RtmpTypeIdCommandMessageAmf0, 0)
+					return nil
+				})
+			}()
+		}
+	}()
+	return ln
+}
+
+func TestClientSession_Redirect(t *testing.T) {
+	server := NewServer("127.0.0.1:0", &testAuthServerObserver{})
+	assert.Equal(t, nil, server.Listen())
+	go server.RunLoop()
+	defer server.Dispose()
+
+	ln := runTestRedirectServer(t, fmt.Sprintf("rtmp://%s/live", server.ln.Addr().String()))
+	defer ln.Close()
+
+	ps := NewPushSession()
+	err := ps.Start(fmt.Sprintf("rtmp://%s/edge/test", ln.Addr().String()))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "edge", ps.AppName())
+	_ = ps.Dispose()
+
+	pull := NewPullSession(func(option *PullSessionOption) {
+		option.FcSubscribeEnable = true
+	})
+	err = pull.Start(fmt.Sprintf("rtmp://%s/edge/test", ln.Addr().String()))
+	assert.Equal(t, nil, err)
+	_ = pull.Dispose()
+
+	// 循环重定向
+	loop := runTestRedirectServer(t, "")
+	defer loop.Close()
+	ps = NewPushSession()
+	err = ps.Start(fmt.Sprintf("rtmp://%s/edge/test", loop.Addr().String()))
+	assert.IsNotNil(t, err)
+}
+
+func TestClientSession_RedirectCredentials(t *testing.T) {
+	server := NewServer("127.0.0.1:0", &testAuthServerObserver{})
+	server.SetAuth(NewServerAuth(AuthModAdobe, SingleUserCredentialStore{User: "chef", Password: "pwd"}))
+	assert.Equal(t, nil, server.Listen())
+	go server.RunLoop()
+	defer server.Dispose()
+
+	// 重定向到其他host:port时，默认不携带原地址的用户名密码
+	ln := runTestRedirectServer(t, fmt.Sprintf("rtmp://%s/live", server.ln.Addr().String()))
+	defer ln.Close()
+	url := fmt.Sprintf("rtmp://chef:pwd@%s/edge/test", ln.Addr().String())
+
+	ps := NewPushSession()
+	err := ps.Start(url)
+	assert.Equal(t, true, errors.Is(err, base.ErrRtmpAuth))
+
+	ps = NewPushSession(func(option *PushSessionOption) {
+		option.RedirectForwardCredentials = true
+	})
+	err = ps.Start(url)
+	assert.Equal(t, nil, err)
+	_ = ps.Dispose()
+}
+
+func TestPushSession_Reconnect(t *testing.T) {
+	observer := &testReconnectServerObserver{
+		pubSessionChan: make(chan *ServerSession, 8),
+		avMsgChan:      make(chan base.RtmpMsg, 64),
+	}
+	server := NewServer("127.0.0.1:0", observer)
+	assert.Equal(t, nil, server.Listen())
+	go server.RunLoop()
+	defer server.Dispose()
+
+	ps := NewPushSession(func(option *PushSessionOption) {
+		option.ReconnectEnable = true
+		option.ReconnectIntervalMs = 10
+	})
+	err := ps.Start(fmt.Sprintf("rtmp://%s/live/test", server.ln.Addr().String()))
+	assert.Equal(t, nil, err)
+	pubSession := <-observer.pubSessionChan
+
+	newVideoMsg := func(b ...byte) base.RtmpMsg {
+		return base.RtmpMsg{
+			Header:  base.RtmpHeader{Csid: csidOverStream, MsgLen: uint32(len(b)), MsgTypeId: base.RtmpTypeIdVideo, MsgStreamId: Msid1},
+			Payload: b,
+		}
+	}
+	seqHeader := newVideoMsg(0x17, 0, 0, 0, 0, 1)
+	keyFrame := newVideoMsg(0x17, 1, 0, 0, 0, 2)
+	interFrame := newVideoMsg(0x27, 1, 0, 0, 0, 3)
+
+	assert.Equal(t, nil, ps.WriteMsg(seqHeader))
+	assert.Equal(t, nil, ps.WriteMsg(keyFrame))
+	assert.Equal(t, nil, ps.Flush())
+	assert.Equal(t, seqHeader.Payload, (<-observer.avMsgChan).Payload)
+	assert.Equal(t, keyFrame.Payload, (<-observer.avMsgChan).Payload)
+
+	// 服务端断开连接，客户端自动重连，并补发seq header
+	_ = pubSession.Dispose()
+	<-observer.pubSessionChan
+	assert.Equal(t, seqHeader.Payload, (<-observer.avMsgChan).Payload)
+	for {
+		ps.core.connMutex.Lock()
+		reconnecting := ps.core.reconnecting
+		ps.core.connMutex.Unlock()
+		if !reconnecting {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// 重连后丢弃关键帧之前的视频数据
+	assert.Equal(t, nil, ps.WriteMsg(interFrame))
+	assert.Equal(t, nil, ps.WriteMsg(keyFrame))
+	assert.Equal(t, nil, ps.WriteMsg(interFrame))
+	assert.Equal(t, nil, ps.Flush())
+	assert.Equal(t, keyFrame.Payload, (<-observer.avMsgChan).Payload)
+	assert.Equal(t, interFrame.Payload, (<-observer.avMsgChan).Payload)
+
+	select {
+	case <-ps.WaitChan():
+		t.Fatal("push session should not be done")
+	default:
+	}
+
+	_ = ps.Dispose()
+	select {
+	case err = <-ps.WaitChan():
+		assert.Equal(t, nil, err)
+	case <-time.After(time.Second):
+		t.Fatal("wait push session done timeout")
+	}
+}
+
+func TestPushSession_ReconnectMaxTimes(t *testing.T) {
+	observer := &testReconnectServerObserver{
+		pubSessionChan: make(chan *ServerSession, 8),
+		avMsgChan:      make(chan base.RtmpMsg, 64),
+	}
+	server := NewServer("127.0.0.1:0", observer)
+	assert.Equal(t, nil, server.Listen())
+	go server.RunLoop()
+
+	ps := NewPushSession(func(option *PushSessionOption) {
+		option.ReconnectEnable = true
+		option.ReconnectIntervalMs = 10
+		option.ReconnectMaxTimes = 2
+	})
+	err := ps.Start(fmt.Sprintf("rtmp://%s/live/test", server.ln.Addr().String()))
+	assert.Equal(t, nil, err)
+	pubSession := <-observer.pubSessionChan
+
+	// 服务端关闭后，重连失败次数达到上限
+	server.Dispose()
+	_ = pubSession.Dispose()
+	select {
+	case err = <-ps.WaitChan():
+		assert.Equal(t, true, err != nil && !errors.Is(err, base.ErrRtmpAuth))
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait push session done timeout")
+	}
+}
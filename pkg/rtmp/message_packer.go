@@ -25,12 +25,14 @@ const (
 
 // MessagePacker 打包并发送 rtmp 信令
 type MessagePacker struct {
-	b *Buffer
+	b         *Buffer
+	chunkSize int // 需要和发送给对端的SetChunkSize的值一致
 }
 
 func NewMessagePacker() *MessagePacker {
 	return &MessagePacker{
-		b: NewBuffer(256),
+		b:         NewBuffer(256),
+		chunkSize: LocalChunkSize,
 	}
 }
 
@@ -55,7 +57,7 @@ func writeSingleChunkHeader(out []byte, csid int, bodyLen int, typeid uint8, str
 func (packer *MessagePacker) ChunkAndWrite(writer io.Writer, csid int, typeid uint8, streamid int) error {
 	bodyLen := packer.b.Len() - 12
 
-	if bodyLen <= LocalChunkSize {
+	if bodyLen <= packer.chunkSize {
 		// 如果一个chunk就够放（大部分信令都是这种情况），我们直接在buffer前面预留的空间写入chunk header内容，避免造成拷贝
 		writeSingleChunkHeader(packer.b.Bytes(), csid, bodyLen, typeid, streamid)
 		_, err := packer.b.WriteTo(writer)
@@ -68,7 +70,7 @@ func (packer *MessagePacker) ChunkAndWrite(writer io.Writer, csid int, typeid ui
 	h.MsgTypeId = typeid
 	h.MsgStreamId = streamid
 	h.TimestampAbs = 0
-	chunks := Message2Chunks(packer.b.Bytes()[12:], &h)
+	chunks := Message2ChunksWithSize(packer.b.Bytes()[12:], &h, packer.chunkSize)
 	packer.b.Reset()
 	_, err := writer.Write(chunks)
 	return err
@@ -149,6 +151,23 @@ func (packer *MessagePacker) writeConnectResult(writer io.Writer, tid int, objec
 	return packer.ChunkAndWrite(writer, csidOverConnection, base.RtmpTypeIdCommandMessageAmf0, 0)
 }
 
+// writeConnectReject connect鉴权失败时回复_error，鉴权参数在 description 中，见 auth.go
+func (packer *MessagePacker) writeConnectReject(writer io.Writer, tid int, description string) error {
+	packer.b.ModWritePos(12)
+
+	_ = Amf0.WriteString(packer.b, "_error")
+	_ = Amf0.WriteNumber(packer.b, float64(tid))
+	_ = Amf0.WriteNull(packer.b)
+	objs := []ObjectPair{
+		{Key: "level", Value: "error"},
+		{Key: "code", Value: "NetConnection.Connect.Rejected"},
+		{Key: "description", Value: description},
+	}
+	_ = Amf0.WriteObject(packer.b, objs)
+
+	return packer.ChunkAndWrite(writer, csidOverConnection, base.RtmpTypeIdCommandMessageAmf0, 0)
+}
+
 func (packer *MessagePacker) writeCreateStream(writer io.Writer) error {
 	packer.b.ModWritePos(12)
 
@@ -183,6 +202,18 @@ func (packer *MessagePacker) writePlay(writer io.Writer, streamName string, stre
 	return packer.ChunkAndWrite(writer, csidOverStream, base.RtmpTypeIdCommandMessageAmf0, streamid)
 }
 
+// writeFcSubscribe 部分CDN（比如Akamai、Limelight）要求拉流时在play之前发送
+func (packer *MessagePacker) writeFcSubscribe(writer io.Writer, streamName string) error {
+	packer.b.ModWritePos(12)
+
+	_ = Amf0.WriteString(packer.b, "FCSubscribe")
+	_ = Amf0.WriteNumber(packer.b, float64(tidClientFcSubscribe))
+	_ = Amf0.WriteNull(packer.b)
+	_ = Amf0.WriteString(packer.b, streamName)
+
+	return packer.ChunkAndWrite(writer, csidOverConnection, base.RtmpTypeIdCommandMessageAmf0, 0)
+}
+
 func (packer *MessagePacker) writePublish(writer io.Writer, appName string, streamName string, streamid int) error {
 	packer.b.ModWritePos(12)
 
@@ -236,6 +267,22 @@ func (packer *MessagePacker) writeOnStatusPlay(writer io.Writer, streamid int) e
 	return packer.ChunkAndWrite(writer, csidOverStream, base.RtmpTypeIdCommandMessageAmf0, streamid)
 }
 
+func (packer *MessagePacker) writeOnFcSubscribe(writer io.Writer, streamName string) error {
+	packer.b.ModWritePos(12)
+
+	_ = Amf0.WriteString(packer.b, "onFCSubscribe")
+	_ = Amf0.WriteNumber(packer.b, 0)
+	_ = Amf0.WriteNull(packer.b)
+	objs := []ObjectPair{
+		{Key: "level", Value: "status"},
+		{Key: "code", Value: "NetStream.Play.Start"},
+		{Key: "description", Value: streamName},
+	}
+	_ = Amf0.WriteObject(packer.b, objs)
+
+	return packer.ChunkAndWrite(writer, csidOverConnection, base.RtmpTypeIdCommandMessageAmf0, 0)
+}
+
 func (packer *MessagePacker) writeStreamIsRecorded(writer io.Writer, streamid uint32) error {
 	packer.b.ModWritePos(12)
 
@@ -26,6 +26,7 @@ const (
 	tidClientCreateStream = 2
 	tidClientPlay         = 3
 	tidClientPublish      = 3
+	tidClientFcSubscribe  = 4
 )
 
 // basic header 3 | message header 11 | extended ts 4
@@ -36,6 +37,24 @@ const maxTimestampInMessageHeader uint32 = 0xFFFFFF
 
 const defaultChunkSize = 128 // 未收到对端设置chunk size时的默认值
 
+// 本端chunk size的合法范围，超出范围时使用 LocalChunkSize
+const (
+	MinChunkSize = 128
+	MaxChunkSize = 65536
+)
+
+// normalizeChunkSize 为0或者超出范围时返回 LocalChunkSize
+func normalizeChunkSize(chunkSize int) int {
+	if chunkSize == 0 {
+		return LocalChunkSize
+	}
+	if chunkSize < MinChunkSize || chunkSize > MaxChunkSize {
+		Log.Warnf("invalid chunk size, use default. chunk size=%d, default=%d", chunkSize, LocalChunkSize)
+		return LocalChunkSize
+	}
+	return chunkSize
+}
+
 const (
 	//MSID0 = 0 // 所有除 publish、play、onStatus 之外的信令
 
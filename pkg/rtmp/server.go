@@ -40,6 +40,10 @@ type Server struct {
 	addr     string
 	observer IServerObserver
 	ln       net.Listener
+	auth     *ServerAuth
+
+	chunkSize           int
+	compressChunkHeader bool
 }
 
 func NewServer(addr string, observer IServerObserver) *Server {
@@ -49,6 +53,23 @@ func NewServer(addr string, observer IServerObserver) *Server {
 	}
 }
 
+// SetAuth 开启connect阶段的adobe或llnw鉴权，见 auth.go
+func (server *Server) SetAuth(auth *ServerAuth) {
+	server.auth = auth
+}
+
+// SetChunkSize 设置本端发送数据时的chunk size，为0或者超出 MinChunkSize ~ MaxChunkSize 的范围时使用 LocalChunkSize
+//
+// 注意，发送给sub session的音视频数据需要使用相同的chunk size切割，见 Message2ChunksWithSize
+func (server *Server) SetChunkSize(chunkSize int) {
+	server.chunkSize = normalizeChunkSize(chunkSize)
+}
+
+// SetCompressChunkHeader 是否压缩发送给sub session的音视频数据的chunk header，见 NewChunkDivider
+func (server *Server) SetCompressChunkHeader(enable bool) {
+	server.compressChunkHeader = enable
+}
+
 func (server *Server) Listen() (err error) {
 	if server.ln, err = net.Listen("tcp", server.addr); err != nil {
 		return
@@ -94,6 +115,12 @@ func (server *Server) Dispose() {
 func (server *Server) handleTcpConnect(conn net.Conn) {
 	Log.Infof("accept a rtmp connection. remoteAddr=%s", conn.RemoteAddr().String())
 	session := NewServerSession(server, conn)
+	session.auth = server.auth
+	if server.chunkSize != 0 {
+		session.chunkSize = server.chunkSize
+		session.packer.chunkSize = server.chunkSize
+	}
+	session.compressChunkHeader = server.compressChunkHeader
 	_ = session.RunLoop()
 
 	if session.DisposeByObserverFlag {
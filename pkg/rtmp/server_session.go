@@ -65,9 +65,14 @@ type ServerSession struct {
 	rawQuery               string //const after set
 
 	observer      IServerSessionObserver
+	auth          *ServerAuth // 为nil时不鉴权
 	hs            HandshakeServer
 	chunkComposer *ChunkComposer
 	packer        *MessagePacker
+	chunkDivider  *ChunkDivider // 为nil时不压缩发送的音视频数据的chunk header，只有sub类型使用
+
+	chunkSize           int  // 本端发送数据时的chunk size
+	compressChunkHeader bool // 成为sub类型时，是否创建 chunkDivider
 
 	conn        connection.Connection
 	sessionStat base.BasicSessionStat
@@ -107,6 +112,7 @@ func NewServerSession(observer IServerSessionObserver, conn net.Conn) *ServerSes
 		observer:                observer,
 		chunkComposer:           NewChunkComposer(),
 		packer:                  NewMessagePacker(),
+		chunkSize:               LocalChunkSize,
 		IsFresh:                 true,
 		ShouldWaitVideoKeyFrame: true,
 	}
@@ -127,11 +133,22 @@ func (s *ServerSession) RunLoop() (err error) {
 }
 
 func (s *ServerSession) Write(msg []byte) error {
+	if s.chunkDivider != nil {
+		_, err := s.conn.Writev(s.chunkDivider.CompressChunks(msg))
+		return err
+	}
 	_, err := s.conn.Write(msg)
 	return err
 }
 
 func (s *ServerSession) Writev(msgs net.Buffers) error {
+	if s.chunkDivider != nil {
+		var bs net.Buffers
+		for _, msg := range msgs {
+			bs = append(bs, s.chunkDivider.CompressChunks(msg)...)
+		}
+		msgs = bs
+	}
 	_, err := s.conn.Writev(msgs)
 	return err
 }
@@ -230,6 +247,8 @@ func (s *ServerSession) doMsg(stream *Stream) error {
 		err = s.doCommandAmf3Message(stream)
 	case base.RtmpTypeIdMetadata:
 		err = s.doDataMessageAmf0(stream)
+	case base.RtmpTypeIdDataMessageAmf3:
+		err = s.doDataMessageAmf3(stream)
 	case base.RtmpTypeIdAck:
 		err = s.doAck(stream)
 	case base.RtmpTypeIdUserControl:
@@ -354,6 +373,8 @@ func (s *ServerSession) doCommandMessage(stream *Stream) error {
 		return s.doPublish(tid, stream)
 	case "play":
 		return s.doPlay(tid, stream)
+	case "FCSubscribe":
+		return s.doFcSubscribe(tid, stream)
 	case "releaseStream":
 		fallthrough
 	case "FCPublish":
@@ -371,10 +392,21 @@ func (s *ServerSession) doCommandMessage(stream *Stream) error {
 }
 
 func (s *ServerSession) doCommandAmf3Message(stream *Stream) error {
-	//去除前面的0就是Amf0的数据
+	// 去除前面的0就是Amf0的数据，其中的值可能通过avmplus-object-marker切换为amf3编码
+	if stream.msg.Len() < 1 {
+		return base.NewErrRtmpShortBuffer(1, 0, "ServerSession::doCommandAmf3Message")
+	}
 	stream.msg.Skip(1)
 	return s.doCommandMessage(stream)
 }
+
+func (s *ServerSession) doDataMessageAmf3(stream *Stream) error {
+	amf0Stream, err := stream.amf3DataToAmf0()
+	if err != nil {
+		return err
+	}
+	return s.doDataMessageAmf0(amf0Stream)
+}
 func (s *ServerSession) writeAcknowledgementIfNeeded(stream *Stream) error {
 	if s.peerWinAckSize <= 0 {
 		return nil
@@ -412,7 +444,10 @@ func (s *ServerSession) doConnect(tid int, stream *Stream) error {
 	}
 	Log.Infof("[%s] < R connect('%s'). tcUrl=%s", s.UniqueKey(), s.appName, s.tcUrl)
 
-	s.observer.OnRtmpConnect(s, val)
+	// 客户端会把鉴权参数追加在app和tcUrl后面，不管是否开启鉴权，都去掉
+	var authQuery string
+	s.appName, authQuery = splitAuthQuery(s.appName)
+	s.tcUrl, _ = splitAuthQuery(s.tcUrl)
 
 	Log.Infof("[%s] > W Window Acknowledgement Size %d.", s.UniqueKey(), windowAcknowledgementSize)
 	if err := s.packer.writeWinAckSize(s.conn, windowAcknowledgementSize); err != nil {
@@ -424,11 +459,24 @@ func (s *ServerSession) doConnect(tid int, stream *Stream) error {
 		return err
 	}
 
-	Log.Infof("[%s] > W SetChunkSize %d.", s.UniqueKey(), LocalChunkSize)
-	if err := s.packer.writeChunkSize(s.conn, LocalChunkSize); err != nil {
+	Log.Infof("[%s] > W SetChunkSize %d.", s.UniqueKey(), s.chunkSize)
+	if err := s.packer.writeChunkSize(s.conn, s.chunkSize); err != nil {
 		return err
 	}
 
+	// 鉴权失败回复的_error可能超过默认的chunk size，所以在SetChunkSize之后
+	if s.auth != nil {
+		if description, ok := s.auth.Check(s.appName, authQuery); !ok {
+			Log.Infof("[%s] > W _error('NetConnection.Connect.Rejected'). description=%s", s.UniqueKey(), description)
+			if err := s.packer.writeConnectReject(s.conn, tid, description); err != nil {
+				return err
+			}
+			return nazaerrors.Wrap(base.ErrRtmpAuth, description)
+		}
+	}
+
+	s.observer.OnRtmpConnect(s, val)
+
 	Log.Infof("[%s] > W _result('NetConnection.Connect.Success').", s.UniqueKey())
 	oe, err := val.FindNumber("objectEncoding")
 	if oe != 0 && oe != 3 {
@@ -490,6 +538,20 @@ func (s *ServerSession) doPublish(tid int, stream *Stream) (err error) {
 	return err
 }
 
+func (s *ServerSession) doFcSubscribe(tid int, stream *Stream) error {
+	if err := stream.msg.readNull(); err != nil {
+		return err
+	}
+	streamName, err := stream.msg.readStringWithType()
+	if err != nil {
+		return err
+	}
+	Log.Infof("[%s] < R FCSubscribe('%s').", s.UniqueKey(), streamName)
+
+	Log.Infof("[%s] > W onFCSubscribe().", s.UniqueKey())
+	return s.packer.writeOnFcSubscribe(s.conn, streamName)
+}
+
 func (s *ServerSession) doPlay(tid int, stream *Stream) (err error) {
 	if err = stream.msg.readNull(); err != nil {
 		return err
@@ -521,6 +583,10 @@ func (s *ServerSession) doPlay(tid int, stream *Stream) (err error) {
 		return err
 	}
 	s.sessionStat.SetBaseType(base.SessionBaseTypeSubStr)
+	if s.compressChunkHeader {
+		// 在通知上层之前创建，之后上层才会调用 Write
+		s.chunkDivider = NewChunkDivider(s.chunkSize)
+	}
 
 	// 回复完信令后修改 connection 的属性
 	s.modConnProps()
@@ -51,6 +51,25 @@ func (stream *Stream) toAvMsg() base.RtmpMsg {
 	}
 }
 
+// amf3DataToAmf0 将RtmpTypeIdDataMessageAmf3类型的message转换为RtmpTypeIdMetadata类型，转换结果使用新的 Stream ，
+// 不修改当前 Stream ，因为后续chunk的header可能依赖当前 Stream 的header
+func (stream *Stream) amf3DataToAmf0() (*Stream, error) {
+	b, err := amf3DataMessageToAmf0(stream.msg.buff.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	out := &Stream{
+		header: stream.header,
+		msg: StreamMsg{
+			buff: nazabytes.NewBufferRefBytes(b),
+		},
+	}
+	out.msg.Flush(uint32(len(b)))
+	out.header.MsgTypeId = base.RtmpTypeIdMetadata
+	out.header.MsgLen = uint32(len(b))
+	return out, nil
+}
+
 // ----- StreamMsg -----------------------------------------------------------------------------------------------------
 
 type StreamMsg struct {
@@ -84,12 +103,12 @@ func (msg *StreamMsg) ResetAndFree() {
 }
 
 func (msg *StreamMsg) peekStringWithType() (string, error) {
-	str, _, err := Amf0.ReadString(msg.buff.Bytes())
+	str, _, err := readAmf0OrAvmplus(msg.buff.Bytes(), Amf0.ReadString)
 	return str, err
 }
 
 func (msg *StreamMsg) readStringWithType() (string, error) {
-	str, l, err := Amf0.ReadString(msg.buff.Bytes())
+	str, l, err := readAmf0OrAvmplus(msg.buff.Bytes(), Amf0.ReadString)
 	if err == nil {
 		msg.Skip(uint32(l))
 	}
@@ -97,7 +116,7 @@ func (msg *StreamMsg) readStringWithType() (string, error) {
 }
 
 func (msg *StreamMsg) readNumberWithType() (int, error) {
-	val, l, err := Amf0.ReadNumber(msg.buff.Bytes())
+	val, l, err := readAmf0OrAvmplus(msg.buff.Bytes(), Amf0.ReadNumber)
 	if err == nil {
 		msg.Skip(uint32(l))
 	}
@@ -105,7 +124,7 @@ func (msg *StreamMsg) readNumberWithType() (int, error) {
 }
 
 func (msg *StreamMsg) readObjectWithType() (ObjectPairArray, error) {
-	opa, l, err := Amf0.ReadObject(msg.buff.Bytes())
+	opa, l, err := readAmf0OrAvmplus(msg.buff.Bytes(), Amf0.ReadObject)
 	if err == nil {
 		msg.Skip(uint32(l))
 	}
@@ -113,7 +132,19 @@ func (msg *StreamMsg) readObjectWithType() (ObjectPairArray, error) {
 }
 
 func (msg *StreamMsg) readNull() error {
-	l, err := Amf0.ReadNull(msg.buff.Bytes())
+	b := msg.buff.Bytes()
+	if len(b) > 0 && b[0] == Amf0TypeMarkerAvmplusObject {
+		v, l, err := Amf3.ReadValue(b[1:])
+		if err == nil && v != nil {
+			err = base.NewErrAmfInvalidType(Amf0TypeMarkerAvmplusObject)
+		}
+		if err == nil {
+			msg.Skip(uint32(l + 1))
+		}
+		return err
+	}
+
+	l, err := Amf0.ReadNull(b)
 	if err == nil {
 		msg.Skip(uint32(l))
 	}
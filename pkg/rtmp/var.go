@@ -26,7 +26,8 @@ var (
 
 	// LocalChunkSize
 	//
-	// 本端（包括Server Session和Client Session）设置的chunk size，本端发送数据时切割chunk包时使用
+	// 本端（包括Server Session和Client Session）默认的chunk size，本端发送数据时切割chunk包时使用
+	// 每个session可以单独设置，见 Server.SetChunkSize 以及 ClientSessionOption.ChunkSize
 	// （对端发送数据时的chunk size由对端决定，和本变量没有关系）
 	//
 	// 注意，这个值不应该设置的太小，原因有两方面：
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/q191201771/lal/pkg/rtprtcp"
+	"github.com/q191201771/naza/pkg/assert"
 )
 
 func TestMswLsw2UnixNano(t *testing.T) {
@@ -21,3 +22,12 @@ func TestMswLsw2UnixNano(t *testing.T) {
 	tt := time.Unix(int64(u/1e9), int64(u%1e9))
 	rtprtcp.Log.Debug(tt.String())
 }
+
+func TestUnixNano2Ntp(t *testing.T) {
+	u := uint64(1700000000123456789)
+	ntp := rtprtcp.UnixNano2Ntp(u)
+	assert.Equal(t, uint64(1700000000+2208988800), ntp>>32)
+	// 低32位的精度约为0.23纳秒，往返转换时截断误差不超过1纳秒
+	back := rtprtcp.Ntp2UnixNano(ntp)
+	assert.Equal(t, true, u-back <= 1)
+}
@@ -0,0 +1,126 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtprtcp
+
+import (
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/naza/pkg/bele"
+)
+
+// ------------------------------------------------------
+// rfc4585 6.1 Common Packet Format for Feedback Messages
+// rfc4585 6.2.1 Generic NACK
+// ------------------------------------------------------
+//
+//        0                   1                   2                   3
+//        0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+//        +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// header |V=2|P| FMT=1   |  PT=RTPFB=205 |             length            |
+//        +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//        |                  SSRC of packet sender                        |
+//        +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//        |                  SSRC of media source                         |
+//        +=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+
+// FCI    |            PID                |             BLP               |
+//        +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//        :                              ...                              :
+//
+// PID: 丢失的包的序号
+// BLP: 从低位开始，第i位为1表示序号PID+i+1的包也丢失了
+
+const (
+	RtcpPacketTypeRtpfb = 205 // 0xcd Transport layer feedback message
+
+	RtcpFmtGenericNack = 1
+)
+
+type Nack struct {
+	SenderSsrc uint32
+	MediaSsrc  uint32
+	Seqs       []uint16 // 丢失的包的序号
+}
+
+// ParseNack rfc4585 6.2.1
+//
+// @param b rtcp包，包含包头
+func ParseNack(b []byte) (Nack, error) {
+	var n Nack
+	if len(b) < 12 {
+		return n, base.ErrRtpRtcpShortBuffer
+	}
+	h := ParseRtcpHeader(b)
+	length := (int(h.Length) + 1) * 4
+	if len(b) < length {
+		return n, base.ErrRtpRtcpShortBuffer
+	}
+
+	n.SenderSsrc = bele.BeUint32(b[4:])
+	n.MediaSsrc = bele.BeUint32(b[8:])
+	for i := 12; i+4 <= length; i += 4 {
+		pid := bele.BeUint16(b[i:])
+		blp := bele.BeUint16(b[i+2:])
+		n.Seqs = append(n.Seqs, pid)
+		for j := 0; j < 16; j++ {
+			if blp&(1<<j) != 0 {
+				n.Seqs = append(n.Seqs, pid+uint16(j+1))
+			}
+		}
+	}
+	return n, nil
+}
+
+// Pack 调用方保证Seqs按发送顺序从小到大排列
+func (n *Nack) Pack() []byte {
+	var fci []byte
+	for i := 0; i < len(n.Seqs); {
+		pid := n.Seqs[i]
+		var blp uint16
+		i++
+		for ; i < len(n.Seqs); i++ {
+			diff := SubSeq(n.Seqs[i], pid)
+			if diff <= 0 || diff > 16 {
+				break
+			}
+			blp |= 1 << (diff - 1)
+		}
+		item := make([]byte, 4)
+		bele.BePutUint16(item, pid)
+		bele.BePutUint16(item[2:], blp)
+		fci = append(fci, item...)
+	}
+
+	b := make([]byte, 12+len(fci))
+
+	var h RtcpHeader
+	h.Version = RtcpVersion
+	h.CountOrFormat = RtcpFmtGenericNack
+	h.PacketType = RtcpPacketTypeRtpfb
+	h.Length = uint16(len(b)/4 - 1)
+	h.PackTo(b)
+
+	bele.BePutUint32(b[4:], n.SenderSsrc)
+	bele.BePutUint32(b[8:], n.MediaSsrc)
+	copy(b[12:], fci)
+	return b
+}
+
+// SplitRtcpPackets 将compound rtcp包（比如RR后面跟着NACK）拆分成单个的rtcp包，长度不合法的部分被丢弃
+func SplitRtcpPackets(b []byte) [][]byte {
+	var ret [][]byte
+	for len(b) >= RtcpHeaderLength {
+		h := ParseRtcpHeader(b)
+		length := (int(h.Length) + 1) * 4
+		if len(b) < length {
+			break
+		}
+		ret = append(ret, b[:length])
+		b = b[length:]
+	}
+	return ret
+}
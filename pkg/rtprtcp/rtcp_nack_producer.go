@@ -0,0 +1,129 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtprtcp
+
+import (
+	"sort"
+	"time"
+)
+
+// 通过收到的rtp包的序号检测丢包，产生rtcp nack包，请求对端重传
+
+type NackProducerOption struct {
+	MaxMissingNum   int // 等待重传的包的最大数量，序号跳变超过该值时（比如对端重启），不再请求重传之前的包
+	MaxRetryNum     int // 每个丢失的包最多请求重传的次数
+	RetryIntervalMs int // 同一个包两次请求重传的最小间隔
+}
+
+var defaultNackProducerOption = NackProducerOption{
+	MaxMissingNum:   512,
+	MaxRetryNum:     3,
+	RetryIntervalMs: 50,
+}
+
+type ModNackProducerOption func(option *NackProducerOption)
+
+type NackProducer struct {
+	option NackProducerOption
+
+	mediaSsrc uint32
+	maxSeq    int32
+	missing   map[uint16]*nackItem
+
+	requestedCount uint64
+}
+
+type nackItem struct {
+	retryNum   int
+	lastSentMs int64
+}
+
+func NewNackProducer(modOptions ...ModNackProducerOption) *NackProducer {
+	option := defaultNackProducerOption
+	for _, fn := range modOptions {
+		fn(&option)
+	}
+	return &NackProducer{
+		option:  option,
+		maxSeq:  -1,
+		missing: make(map[uint16]*nackItem),
+	}
+}
+
+// FeedRtpPacket 每次收到rtp包，都将ssrc和seq序号传入这个函数
+func (p *NackProducer) FeedRtpPacket(ssrc uint32, seq uint16) {
+	if p.maxSeq == -1 || ssrc != p.mediaSsrc {
+		p.reset(ssrc, seq)
+		return
+	}
+
+	diff := SubSeq(seq, uint16(p.maxSeq))
+	if diff <= 0 {
+		// 重传或乱序的包到达
+		delete(p.missing, seq)
+		return
+	}
+	if diff-1+len(p.missing) > p.option.MaxMissingNum {
+		p.reset(ssrc, seq)
+		return
+	}
+	for s := uint16(p.maxSeq) + 1; s != seq; s++ {
+		p.missing[s] = &nackItem{}
+	}
+	p.maxSeq = int32(seq)
+}
+
+// Produce 每次收到rtp包后调用
+//
+// @return: nack包的二进制数据，没有需要请求重传的包时返回nil
+func (p *NackProducer) Produce() []byte {
+	if len(p.missing) == 0 {
+		return nil
+	}
+
+	nowMs := time.Now().UnixNano() / 1e6
+	var seqs []uint16
+	for seq, item := range p.missing {
+		if item.retryNum >= p.option.MaxRetryNum {
+			delete(p.missing, seq)
+			continue
+		}
+		if item.retryNum > 0 && nowMs-item.lastSentMs < int64(p.option.RetryIntervalMs) {
+			continue
+		}
+		item.retryNum++
+		item.lastSentMs = nowMs
+		seqs = append(seqs, seq)
+	}
+	if len(seqs) == 0 {
+		return nil
+	}
+
+	sort.Slice(seqs, func(i, j int) bool {
+		return SubSeq(seqs[i], seqs[j]) < 0
+	})
+	p.requestedCount += uint64(len(seqs))
+
+	nack := Nack{
+		MediaSsrc: p.mediaSsrc,
+		Seqs:      seqs,
+	}
+	return nack.Pack()
+}
+
+// RequestedCount 请求重传的包的累计数量，同一个包请求多次时累计多次
+func (p *NackProducer) RequestedCount() uint64 {
+	return p.requestedCount
+}
+
+func (p *NackProducer) reset(ssrc uint32, seq uint16) {
+	p.mediaSsrc = ssrc
+	p.maxSeq = int32(seq)
+	p.missing = make(map[uint16]*nackItem)
+}
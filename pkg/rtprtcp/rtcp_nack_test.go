@@ -0,0 +1,102 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtprtcp_test
+
+import (
+	"testing"
+
+	"github.com/q191201771/lal/pkg/rtprtcp"
+	"github.com/q191201771/naza/pkg/assert"
+)
+
+func TestNack(t *testing.T) {
+	// 65534、65535、0跨越翻转，和17相差超过16，分成两个FCI
+	nack := rtprtcp.Nack{SenderSsrc: 1, MediaSsrc: 2, Seqs: []uint16{65534, 65535, 0, 3, 17}}
+	b := nack.Pack()
+	assert.Equal(t, 20, len(b))
+	h := rtprtcp.ParseRtcpHeader(b)
+	assert.Equal(t, uint8(rtprtcp.RtcpPacketTypeRtpfb), h.PacketType)
+	assert.Equal(t, uint8(rtprtcp.RtcpFmtGenericNack), h.CountOrFormat)
+	assert.Equal(t, uint16(4), h.Length)
+
+	parsed, err := rtprtcp.ParseNack(b)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, nack, parsed)
+
+	_, err = rtprtcp.ParseNack(b[:16])
+	assert.IsNotNil(t, err)
+
+	// compound包：RR + NACK
+	rr := []byte{0x81, 0xc9, 0x00, 0x07, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	pkts := rtprtcp.SplitRtcpPackets(append(rr, b...))
+	assert.Equal(t, 2, len(pkts))
+	assert.Equal(t, rr, pkts[0])
+	assert.Equal(t, b, pkts[1])
+	assert.Equal(t, 1, len(rtprtcp.SplitRtcpPackets(append(rr, b[:8]...))))
+}
+
+func TestNackProducer(t *testing.T) {
+	p := rtprtcp.NewNackProducer(func(option *rtprtcp.NackProducerOption) {
+		option.MaxMissingNum = 8
+		option.MaxRetryNum = 2
+		option.RetryIntervalMs = 0
+	})
+
+	p.FeedRtpPacket(100, 65533)
+	assert.Equal(t, nil, p.Produce())
+
+	// 丢失65534、65535、1
+	p.FeedRtpPacket(100, 0)
+	p.FeedRtpPacket(100, 2)
+	nack, err := rtprtcp.ParseNack(p.Produce())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, uint32(100), nack.MediaSsrc)
+	assert.Equal(t, []uint16{65534, 65535, 1}, nack.Seqs)
+
+	// 收到重传的65535
+	p.FeedRtpPacket(100, 65535)
+	nack, _ = rtprtcp.ParseNack(p.Produce())
+	assert.Equal(t, []uint16{65534, 1}, nack.Seqs)
+
+	// 超过重传次数
+	assert.Equal(t, nil, p.Produce())
+	assert.Equal(t, uint64(5), p.RequestedCount())
+
+	// 序号跳变过大，不再请求重传
+	p.FeedRtpPacket(100, 100)
+	assert.Equal(t, nil, p.Produce())
+
+	// ssrc变化
+	p.FeedRtpPacket(100, 101)
+	p.FeedRtpPacket(200, 103)
+	assert.Equal(t, nil, p.Produce())
+}
+
+func TestRtpPacketHistory(t *testing.T) {
+	h := rtprtcp.NewRtpPacketHistory(4)
+	for seq := uint16(0); seq < 6; seq++ {
+		var pkt rtprtcp.RtpPacket
+		pkt.Header.Ssrc = 1
+		pkt.Header.Seq = seq
+		pkt.Raw = []byte{byte(seq)}
+		h.Put(pkt)
+	}
+
+	pkt, ok := h.Get(1, 5)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []byte{5}, pkt.Raw)
+	_, ok = h.Get(1, 1) // 被覆盖
+	assert.Equal(t, false, ok)
+	_, ok = h.Get(2, 5) // ssrc不匹配
+	assert.Equal(t, false, ok)
+
+	var nilHistory *rtprtcp.RtpPacketHistory
+	_, ok = nilHistory.Get(1, 5)
+	assert.Equal(t, false, ok)
+}
@@ -0,0 +1,76 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtprtcp
+
+import "time"
+
+// 通过发送的rtp包，产生rtcp sr包，对端可以通过sr中ntp和rtp时间戳的对应关系对齐音视频
+
+type SrProducer struct {
+	clockRate int
+
+	ssrc           uint32
+	lastTimestamp  uint32 // 最近一次发送的rtp包的时间戳
+	lastUnixNano   int64  // 最近一次发送rtp包时的本地时间
+	pktCnt         uint32
+	octetCnt       uint32
+	lastProducedMs int64
+}
+
+func NewSrProducer(clockRate int) *SrProducer {
+	return &SrProducer{
+		clockRate: clockRate,
+	}
+}
+
+// FeedRtpPacket 每次发送rtp包，都将rtp包传入这个函数
+func (p *SrProducer) FeedRtpPacket(pkt RtpPacket) {
+	p.ssrc = pkt.Header.Ssrc
+	p.lastTimestamp = pkt.Header.Timestamp
+	p.lastUnixNano = time.Now().UnixNano()
+	p.pktCnt++
+
+	payloadOffset := int(pkt.Header.payloadOffset)
+	if payloadOffset == 0 {
+		payloadOffset = RtpFixedHeaderLength
+	}
+	if len(pkt.Raw) > payloadOffset {
+		p.octetCnt += uint32(len(pkt.Raw) - payloadOffset)
+	}
+}
+
+// Produce 距离上次产生sr包超过intervalMs时，产生sr包
+//
+// @return: sr包的二进制数据，还没有发送过rtp包或者没到时间时返回nil
+func (p *SrProducer) Produce(intervalMs int) []byte {
+	if p.pktCnt == 0 {
+		return nil
+	}
+
+	now := time.Now().UnixNano()
+	nowMs := now / 1e6
+	if p.lastProducedMs != 0 && nowMs-p.lastProducedMs < int64(intervalMs) {
+		return nil
+	}
+	p.lastProducedMs = nowMs
+
+	// 用最近一次发送的rtp包的时间戳，加上距离发送时经过的时间，推算当前时刻对应的rtp时间戳
+	elapsed := (now - p.lastUnixNano) * int64(p.clockRate) / 1e9
+	ntp := UnixNano2Ntp(uint64(now))
+
+	sr := Sr{
+		SenderSsrc: p.ssrc,
+		Msw:        uint32(ntp >> 32),
+		Lsw:        uint32(ntp),
+		Timestamp:  p.lastTimestamp + uint32(elapsed),
+		PktCnt:     p.pktCnt,
+		OctetCnt:   p.octetCnt,
+	}
+	return sr.Pack()
+}
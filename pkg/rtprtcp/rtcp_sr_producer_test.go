@@ -0,0 +1,49 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtprtcp_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/q191201771/lal/pkg/rtprtcp"
+	"github.com/q191201771/naza/pkg/assert"
+)
+
+func TestSrProducer(t *testing.T) {
+	p := rtprtcp.NewSrProducer(90000)
+	assert.Equal(t, nil, p.Produce(0))
+
+	h := rtprtcp.MakeDefaultRtpHeader()
+	h.Ssrc = 1000
+	h.Timestamp = 90000
+	p.FeedRtpPacket(rtprtcp.MakeRtpPacket(h, make([]byte, 100)))
+	h.Timestamp = 93600
+	p.FeedRtpPacket(rtprtcp.MakeRtpPacket(h, make([]byte, 50)))
+
+	before := uint64(time.Now().UnixNano())
+	b := p.Produce(1000)
+	assert.IsNotNil(t, b)
+	h2 := rtprtcp.ParseRtcpHeader(b)
+	assert.Equal(t, uint8(rtprtcp.RtcpPacketTypeSr), h2.PacketType)
+	assert.Equal(t, 28, (int(h2.Length)+1)*4)
+
+	sr := rtprtcp.ParseSr(b)
+	assert.Equal(t, uint32(1000), sr.SenderSsrc)
+	assert.Equal(t, uint32(2), sr.PktCnt)
+	assert.Equal(t, uint32(150), sr.OctetCnt)
+	// 产生sr的时间距离最后一次发送rtp包很近，推算的rtp时间戳差值很小
+	assert.Equal(t, true, sr.Timestamp >= 93600 && sr.Timestamp < 93600+900)
+	ntpUnixNano := rtprtcp.MswLsw2UnixNano(uint64(sr.Msw), uint64(sr.Lsw))
+	assert.Equal(t, true, ntpUnixNano+1e6 >= before && ntpUnixNano < before+1e9)
+
+	// 没到间隔
+	assert.Equal(t, nil, p.Produce(1000))
+	assert.IsNotNil(t, p.Produce(0))
+}
@@ -40,6 +40,11 @@ const (
 	NaluTypeHevcFua = 49
 )
 
+// mpa的格式：
+//
+// rfc2250 3.5 MPEG Audio-specific header，每个rtp包的payload前有4字节的头
+const mpaHeaderSize = 4
+
 // CompareSeq 比较序号的值，内部处理序号翻转问题，见单元测试中的例子
 //
 // @return
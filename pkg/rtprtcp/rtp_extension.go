@@ -0,0 +1,264 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtprtcp
+
+import (
+	"fmt"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/naza/pkg/bele"
+	"github.com/q191201771/naza/pkg/nazaerrors"
+)
+
+// ---------------------------------------------------------
+// rfc8285 4.2 One-Byte Header
+// ---------------------------------------------------------
+//
+//  0                   1                   2                   3
+//  0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |       0xBE    |    0xDE       |           length=3            |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |  ID   | L=0   |     data      |  ID   |  L=1  |   data...
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//       ...data   |    0 (pad)    |    0 (pad)    |  ID   | L=3   |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |                          data                                 |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//
+// L为data的字节数减1，ID取值1~14
+//
+// ---------------------------------------------------------
+// rfc8285 4.3 Two-Byte Header
+// ---------------------------------------------------------
+//
+//  0                   1                   2                   3
+//  0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |       0x10    |    0x00       |           length=3            |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |      ID       |     L=0       |     ID        |     L=1       |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |       data    |    0 (pad)    |       ID      |      L=4      |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |                          data                                 |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//
+// L为data的字节数，ID取值1~255，profile的低4位为appbits
+
+const (
+	RtpExtensionProfileOneByte = 0xBEDE
+	RtpExtensionProfileTwoByte = 0x1000 // 低4位为appbits，判断时需要屏蔽
+
+	rtpExtensionProfileTwoByteMask = 0xFFF0
+)
+
+// 常用的rtp header extension的uri，通过sdp中的`a=extmap`和id对应
+const (
+	RtpExtensionUriAbsSendTime      = "http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time"
+	RtpExtensionUriAbsCaptureTime   = "http://www.webrtc.org/experiments/rtp-hdrext/abs-capture-time"
+	RtpExtensionUriTransportCc      = "http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01"
+	RtpExtensionUriVideoOrientation = "urn:3gpp:video-orientation"
+)
+
+type RtpExtension struct {
+	Id    uint8
+	Value []byte // 引用的是包体的内存
+}
+
+// IsRtpExtensionProfileRfc8285 profile是否为one-byte或two-byte格式，其他profile的extension内容由应用自定义，无法解析
+func IsRtpExtensionProfileRfc8285(profile uint16) bool {
+	return profile == RtpExtensionProfileOneByte || profile&rtpExtensionProfileTwoByteMask == RtpExtensionProfileTwoByte
+}
+
+// ParseRtpExtensions
+//
+// @param profile: rtp头中的extension profile
+// @param b:       extension的内容，不包含profile和length，见 RtpHeader.Extensions
+func ParseRtpExtensions(profile uint16, b []byte) ([]RtpExtension, error) {
+	var ret []RtpExtension
+	err := walkRtpExtensions(profile, b, func(id uint8, value []byte) bool {
+		ret = append(ret, RtpExtension{Id: id, Value: value})
+		return true
+	})
+	return ret, err
+}
+
+// walkRtpExtensions 按顺序遍历extension，不申请内存，onExtension返回false时停止遍历
+func walkRtpExtensions(profile uint16, b []byte, onExtension func(id uint8, value []byte) bool) error {
+	oneByte := profile == RtpExtensionProfileOneByte
+	for i := 0; i < len(b); {
+		// padding
+		if b[i] == 0 {
+			i++
+			continue
+		}
+
+		var id uint8
+		var length int
+		if oneByte {
+			id = b[i] >> 4
+			length = int(b[i]&0xF) + 1
+			// id为15时停止解析
+			if id == 15 {
+				break
+			}
+			i++
+		} else {
+			if i+2 > len(b) {
+				return base.ErrRtpRtcpShortBuffer
+			}
+			id = b[i]
+			length = int(b[i+1])
+			i += 2
+		}
+
+		if i+length > len(b) {
+			return base.ErrRtpRtcpShortBuffer
+		}
+		if !onExtension(id, b[i:i+length]) {
+			return nil
+		}
+		i += length
+	}
+	return nil
+}
+
+// PackRtpExtensions 所有id和长度都满足one-byte格式时使用one-byte格式，否则使用two-byte格式
+//
+// id为0，或者值的长度超过255字节时，two-byte格式也无法表示，返回错误
+//
+// @return b: 不包含profile和length，长度已补齐为4的倍数
+func PackRtpExtensions(exts []RtpExtension) (profile uint16, b []byte, err error) {
+	profile = RtpExtensionProfileOneByte
+	for _, ext := range exts {
+		if ext.Id == 0 || len(ext.Value) > 255 {
+			return 0, nil, nazaerrors.Wrap(base.ErrRtpExtensionInvalid, fmt.Sprintf("id=%d, len=%d", ext.Id, len(ext.Value)))
+		}
+		if ext.Id > 14 || len(ext.Value) < 1 || len(ext.Value) > 16 {
+			profile = RtpExtensionProfileTwoByte
+		}
+	}
+
+	for _, ext := range exts {
+		if profile == RtpExtensionProfileOneByte {
+			b = append(b, ext.Id<<4|uint8(len(ext.Value)-1))
+		} else {
+			b = append(b, ext.Id, uint8(len(ext.Value)))
+		}
+		b = append(b, ext.Value...)
+	}
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// RtpExtensionRegistry 通过sdp中的`a=extmap`，在id和uri之间相互查找，并解析常用extension的值
+type RtpExtensionRegistry struct {
+	id2Uri map[uint8]string
+	uri2Id map[string]uint8
+}
+
+// NewRtpExtensionRegistry
+//
+// @param extMap: key为id，value为uri，见 sdp.LogicContext 的 AudioExtMap 和 VideoExtMap
+func NewRtpExtensionRegistry(extMap map[int]string) *RtpExtensionRegistry {
+	r := &RtpExtensionRegistry{
+		id2Uri: make(map[uint8]string),
+		uri2Id: make(map[string]uint8),
+	}
+	for id, uri := range extMap {
+		if id < 1 || id > 255 {
+			continue
+		}
+		r.id2Uri[uint8(id)] = uri
+		r.uri2Id[uri] = uint8(id)
+	}
+	return r
+}
+
+func (r *RtpExtensionRegistry) Id(uri string) (uint8, bool) {
+	id, ok := r.uri2Id[uri]
+	return id, ok
+}
+
+func (r *RtpExtensionRegistry) Uri(id uint8) (string, bool) {
+	uri, ok := r.id2Uri[id]
+	return uri, ok
+}
+
+// Get 获取rtp包中uri对应的extension的值
+func (r *RtpExtensionRegistry) Get(h *RtpHeader, uri string) ([]byte, bool) {
+	id, ok := r.uri2Id[uri]
+	if !ok {
+		return nil, false
+	}
+	return h.GetExtension(id)
+}
+
+// AbsSendTime 6.18定点数格式的秒数，24位
+func (r *RtpExtensionRegistry) AbsSendTime(h *RtpHeader) (uint32, bool) {
+	v, ok := r.Get(h, RtpExtensionUriAbsSendTime)
+	if !ok || len(v) != 3 {
+		return 0, false
+	}
+	return bele.BeUint24(v), true
+}
+
+// TransportCcSeq transport-wide的序号
+func (r *RtpExtensionRegistry) TransportCcSeq(h *RtpHeader) (uint16, bool) {
+	v, ok := r.Get(h, RtpExtensionUriTransportCc)
+	if !ok || len(v) != 2 {
+		return 0, false
+	}
+	return bele.BeUint16(v), true
+}
+
+type AbsCaptureTime struct {
+	Timestamp uint64 // 采集时的ntp时间戳，64位定点数格式
+
+	EstimatedCaptureClockOffsetFlag bool
+	EstimatedCaptureClockOffset     int64 // 32.32定点数格式的秒数，EstimatedCaptureClockOffsetFlag为true时有效
+}
+
+func (r *RtpExtensionRegistry) AbsCaptureTime(h *RtpHeader) (AbsCaptureTime, bool) {
+	var ret AbsCaptureTime
+	v, ok := r.Get(h, RtpExtensionUriAbsCaptureTime)
+	if !ok || (len(v) != 8 && len(v) != 16) {
+		return ret, false
+	}
+	ret.Timestamp = bele.BeUint64(v)
+	if len(v) == 16 {
+		ret.EstimatedCaptureClockOffsetFlag = true
+		ret.EstimatedCaptureClockOffset = int64(bele.BeUint64(v[8:]))
+	}
+	return ret, true
+}
+
+// VideoOrientation 3GPP TS 26.114 7.4.5 Coordination of Video Orientation
+type VideoOrientation struct {
+	Camera   bool // false为前置摄像头，true为后置摄像头
+	Flip     bool // 是否水平翻转
+	Rotation int  // 顺时针旋转的角度，取值0、90、180、270
+}
+
+func (r *RtpExtensionRegistry) VideoOrientation(h *RtpHeader) (VideoOrientation, bool) {
+	var ret VideoOrientation
+	v, ok := r.Get(h, RtpExtensionUriVideoOrientation)
+	if !ok || len(v) < 1 {
+		return ret, false
+	}
+	ret.Camera = v[0]&0x8 != 0
+	ret.Flip = v[0]&0x4 != 0
+	ret.Rotation = int(v[0]&0x3) * 90
+	return ret, true
+}
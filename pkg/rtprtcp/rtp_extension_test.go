@@ -0,0 +1,135 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtprtcp_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/lal/pkg/rtprtcp"
+	"github.com/q191201771/naza/pkg/assert"
+)
+
+func TestParseRtpExtensions(t *testing.T) {
+	// testRtpData中的extension为one-byte格式，id=3，transport-cc序号为2
+	h, err := rtprtcp.ParseRtpHeader(testRtpData)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, uint16(rtprtcp.RtpExtensionProfileOneByte), h.ExtensionProfile)
+	exts, err := rtprtcp.ParseRtpExtensions(h.ExtensionProfile, h.Extensions)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(exts))
+	assert.Equal(t, uint8(3), exts[0].Id)
+	assert.Equal(t, []byte{0x00, 0x02}, exts[0].Value)
+	v, ok := h.GetExtension(3)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []byte{0x00, 0x02}, v)
+
+	r := rtprtcp.NewRtpExtensionRegistry(map[int]string{3: rtprtcp.RtpExtensionUriTransportCc})
+	seq, ok := r.TransportCcSeq(&h)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, uint16(2), seq)
+	_, ok = r.AbsSendTime(&h)
+	assert.Equal(t, false, ok)
+
+	// two-byte
+	exts, err = rtprtcp.ParseRtpExtensions(0x1001, []byte{0x10, 0x00, 0x20, 0x02, 0xAB, 0xCD, 0x00, 0x00})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []rtprtcp.RtpExtension{{Id: 16, Value: []byte{}}, {Id: 32, Value: []byte{0xAB, 0xCD}}}, exts)
+
+	_, err = rtprtcp.ParseRtpExtensions(rtprtcp.RtpExtensionProfileOneByte, []byte{0x13, 0x01})
+	assert.IsNotNil(t, err)
+
+	// extension格式错误时，rtp包仍然可以正常解析，只是获取不到extension
+	h = rtprtcp.MakeDefaultRtpHeader()
+	h.ExtensionProfile = rtprtcp.RtpExtensionProfileOneByte
+	h.Extensions = []byte{0x13, 0x01, 0x00, 0x00}
+	pkt, err := rtprtcp.ParseRtpPacket(rtprtcp.MakeRtpPacket(h, []byte{0xAA}).Raw)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []byte{0xAA}, pkt.Body())
+	_, ok = pkt.Header.GetExtension(1)
+	assert.Equal(t, false, ok)
+
+	// id为15时停止解析
+	exts, err = rtprtcp.ParseRtpExtensions(rtprtcp.RtpExtensionProfileOneByte, []byte{0x10, 0x01, 0xF0, 0x00})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(exts))
+}
+
+func TestPackRtpExtensions(t *testing.T) {
+	extMap := map[int]string{
+		1: rtprtcp.RtpExtensionUriAbsSendTime,
+		2: rtprtcp.RtpExtensionUriAbsCaptureTime,
+		3: rtprtcp.RtpExtensionUriVideoOrientation,
+	}
+	r := rtprtcp.NewRtpExtensionRegistry(extMap)
+	id, ok := r.Id(rtprtcp.RtpExtensionUriVideoOrientation)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, uint8(3), id)
+	uri, ok := r.Uri(2)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, rtprtcp.RtpExtensionUriAbsCaptureTime, uri)
+
+	h := rtprtcp.MakeDefaultRtpHeader()
+	h.PacketType = 96
+	h.Seq = 100
+	h.Timestamp = 9000
+	h.Ssrc = 0x11223344
+	h.Csrc = []uint32{0x55667788, 0x99AABBCC}
+	exts := []rtprtcp.RtpExtension{
+		{Id: 1, Value: []byte{0x01, 0x02, 0x03}},
+		{Id: 2, Value: []byte{1, 2, 3, 4, 5, 6, 7, 8, 0, 0, 0, 0, 0, 0, 0, 1}},
+		{Id: 3, Value: []byte{0x0D}},
+	}
+	assert.Equal(t, nil, h.SetExtensions(exts))
+	payload := []byte{0xAA, 0xBB}
+	pkt := rtprtcp.MakeRtpPacket(h, payload)
+	// 12 + 2*4 + 4 + (4+17+2 补齐到24)
+	assert.Equal(t, 48, pkt.Header.HeaderLength())
+	assert.Equal(t, 48+len(payload), len(pkt.Raw))
+	assert.Equal(t, payload, pkt.Body())
+
+	pkt2, err := rtprtcp.ParseRtpPacket(pkt.Raw)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, uint8(2), pkt2.Header.CsrcCount)
+	assert.Equal(t, h.Csrc, pkt2.Header.Csrc)
+	assert.Equal(t, uint8(1), pkt2.Header.Extension)
+	assert.Equal(t, uint16(rtprtcp.RtpExtensionProfileOneByte), pkt2.Header.ExtensionProfile)
+	exts2, err := rtprtcp.ParseRtpExtensions(pkt2.Header.ExtensionProfile, pkt2.Header.Extensions)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, exts, exts2)
+	assert.Equal(t, payload, pkt2.Body())
+
+	ast, ok := r.AbsSendTime(&pkt2.Header)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, uint32(0x010203), ast)
+	act, ok := r.AbsCaptureTime(&pkt2.Header)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, uint64(0x0102030405060708), act.Timestamp)
+	assert.Equal(t, true, act.EstimatedCaptureClockOffsetFlag)
+	assert.Equal(t, int64(1), act.EstimatedCaptureClockOffset)
+	vo, ok := r.VideoOrientation(&pkt2.Header)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, rtprtcp.VideoOrientation{Camera: true, Flip: true, Rotation: 90}, vo)
+
+	// id超过14时使用two-byte格式
+	profile, b, err := rtprtcp.PackRtpExtensions([]rtprtcp.RtpExtension{{Id: 20, Value: []byte{0x01}}})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, uint16(rtprtcp.RtpExtensionProfileTwoByte), profile)
+	assert.Equal(t, []byte{20, 1, 0x01, 0x00}, b)
+
+	// 值超过255字节或者id为0时无法打包
+	_, _, err = rtprtcp.PackRtpExtensions([]rtprtcp.RtpExtension{{Id: 20, Value: make([]byte, 256)}})
+	assert.Equal(t, true, errors.Is(err, base.ErrRtpExtensionInvalid))
+	_, _, err = rtprtcp.PackRtpExtensions([]rtprtcp.RtpExtension{{Id: 0, Value: []byte{0x01}}})
+	assert.Equal(t, true, errors.Is(err, base.ErrRtpExtensionInvalid))
+	h = rtprtcp.MakeDefaultRtpHeader()
+	assert.IsNotNil(t, h.SetExtensions([]rtprtcp.RtpExtension{{Id: 20, Value: make([]byte, 256)}}))
+	assert.Equal(t, true, h.Extensions == nil)
+}
@@ -0,0 +1,367 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtprtcp
+
+import (
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/naza/pkg/bele"
+)
+
+// rtp_fec.go
+//
+// rfc5109 ULPFEC，只使用level 0，即每个fec包对一组媒体包的全部内容做异或
+//
+// fec包作为一路单独的流发送（rfc5109 14.1）：使用单独的payload type、ssrc和seq。
+// 接收时也兼容fec包和媒体包使用相同ssrc的发送端
+//
+// ----------------------
+// rfc5109 7.3 FEC Header
+// ----------------------
+//
+//  0                   1                   2                   3
+//  0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |E|L|P|X|  CC   |M| PT recovery |            SN base            |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |                          TS recovery                          |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |        length recovery        |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//
+// ----------------------------
+// rfc5109 7.4 FEC Level Header
+// ----------------------------
+//
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |       Protection Length       |             mask              |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |              mask cont. (present only when L = 1)             |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//
+// mask从最高位开始，第i位为1表示序号为SN base+i的包被保护
+
+const (
+	FecHeaderLength = 10
+
+	// FecMaxGroupSize 一个fec包最多保护的包数，L=1时mask为48位
+	FecMaxGroupSize = 48
+
+	fecLevelHeaderLengthShort = 4
+	fecLevelHeaderLengthLong  = 8
+	fecShortMaskSize          = 16
+
+	fecDecoderMaxMediaNum = 1024
+	fecDecoderMaxFecNum   = 64
+)
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+type FecEncoder struct {
+	payloadType uint8
+	ssrc        uint32
+	groupSize   int
+
+	seq   uint16
+	group []RtpPacket
+}
+
+// NewFecEncoder
+//
+// @param payloadType: fec包的payload type，需要和sdp中`a=rtpmap:<pt> ulpfec/<clock rate>`一致
+// @param ssrc:        fec流的ssrc，需要和媒体流的不同
+// @param groupSize:   每多少个媒体包产生一个fec包，取值范围[1, FecMaxGroupSize]
+func NewFecEncoder(payloadType uint8, ssrc uint32, groupSize int) *FecEncoder {
+	if groupSize < 1 {
+		groupSize = 1
+	}
+	if groupSize > FecMaxGroupSize {
+		groupSize = FecMaxGroupSize
+	}
+	return &FecEncoder{
+		payloadType: payloadType,
+		ssrc:        ssrc,
+		groupSize:   groupSize,
+	}
+}
+
+// FeedRtpPacket 每发送一个媒体rtp包，调用一次该函数
+//
+// @return 凑够一组时返回fec包，否则ok为false
+func (e *FecEncoder) FeedRtpPacket(pkt RtpPacket) (fecPkt RtpPacket, ok bool) {
+	// ssrc变化，或者序号跨度超出mask的表示范围时，丢弃之前的包，重新分组
+	if len(e.group) != 0 {
+		first := e.group[0].Header
+		if first.Ssrc != pkt.Header.Ssrc || SubSeq(pkt.Header.Seq, first.Seq) <= 0 || SubSeq(pkt.Header.Seq, first.Seq) >= FecMaxGroupSize {
+			e.group = e.group[:0]
+		}
+	}
+	e.group = append(e.group, pkt)
+	if len(e.group) < e.groupSize {
+		return
+	}
+
+	h := MakeDefaultRtpHeader()
+	h.PacketType = e.payloadType
+	h.Seq = e.seq
+	h.Timestamp = pkt.Header.Timestamp
+	h.Ssrc = e.ssrc
+	e.seq++
+
+	fecPkt = MakeRtpPacket(h, makeUlpfecPayload(e.group))
+	e.group = e.group[:0]
+	return fecPkt, true
+}
+
+func makeUlpfecPayload(group []RtpPacket) []byte {
+	snBase := group[0].Header.Seq
+	span := 0
+	protectionLength := 0
+	for _, pkt := range group {
+		if n := SubSeq(pkt.Header.Seq, snBase) + 1; n > span {
+			span = n
+		}
+		if n := len(pkt.Raw) - RtpFixedHeaderLength; n > protectionLength {
+			protectionLength = n
+		}
+	}
+
+	levelHeaderLength := fecLevelHeaderLengthShort
+	if span > fecShortMaskSize {
+		levelHeaderLength = fecLevelHeaderLengthLong
+	}
+	out := make([]byte, FecHeaderLength+levelHeaderLength+protectionLength)
+
+	// E=0，先设置L，异或时依赖L确定payload的位置
+	if levelHeaderLength == fecLevelHeaderLengthLong {
+		out[0] = 0x40
+	}
+	var mask uint64
+	for _, pkt := range group {
+		fecXorBitString(out, pkt.Raw)
+		mask |= 1 << (63 - SubSeq(pkt.Header.Seq, snBase))
+	}
+	bele.BePutUint16(out[2:], snBase)
+
+	level := out[FecHeaderLength:]
+	bele.BePutUint16(level, uint16(protectionLength))
+	bele.BePutUint16(level[2:], uint16(mask>>48))
+	if levelHeaderLength == fecLevelHeaderLengthLong {
+		bele.BePutUint32(level[4:], uint32(mask>>16))
+	}
+	return out
+}
+
+// fecXorBitString 将rtp包的bit string异或到fec payload中，见rfc5109 7.3
+//
+// 异或的内容为：P、X、CC、M、PT、TS、长度（不包含12字节的固定头），以及固定头之后的全部数据
+func fecXorBitString(fec []byte, rtp []byte) {
+	fec[0] ^= rtp[0] & 0x3F
+	fec[1] ^= rtp[1]
+	for i := 0; i < 4; i++ {
+		fec[4+i] ^= rtp[4+i]
+	}
+	length := uint16(len(rtp) - RtpFixedHeaderLength)
+	fec[8] ^= byte(length >> 8)
+	fec[9] ^= byte(length)
+
+	payload := fec[FecHeaderLength+fecLevelHeaderLength(fec):]
+	for i, c := range rtp[RtpFixedHeaderLength:] {
+		if i >= len(payload) {
+			break
+		}
+		payload[i] ^= c
+	}
+}
+
+func fecLevelHeaderLength(fec []byte) int {
+	if fec[0]&0x40 != 0 {
+		return fecLevelHeaderLengthLong
+	}
+	return fecLevelHeaderLengthShort
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// FecDecoder 缓存最近收到的媒体包和fec包，当一个fec包保护的包中只丢失了一个时，恢复出该包
+//
+// 媒体包按(ssrc, seq)缓存，fec包和媒体包ssrc不同时，认为它保护的是最近收到的媒体包所在的流
+type FecDecoder struct {
+	media         map[fecMediaKey][]byte
+	mediaOrder    []fecMediaKey
+	hasMedia      bool
+	lastMediaSsrc uint32
+	fecs          []fecItem
+
+	recoveredCount uint64
+}
+
+type fecMediaKey struct {
+	ssrc uint32
+	seq  uint16
+}
+
+type fecItem struct {
+	ssrc    uint32 // fec包的ssrc
+	snBase  uint16
+	seqs    []uint16
+	payload []byte // 包含fec header和level header
+}
+
+func NewFecDecoder() *FecDecoder {
+	return &FecDecoder{
+		media: make(map[fecMediaKey][]byte),
+	}
+}
+
+// FeedRtpPacket 每收到一个媒体rtp包，调用一次该函数
+//
+// @param b: 函数调用结束后，内部不持有该内存块
+//
+// @return 恢复出的rtp包
+func (d *FecDecoder) FeedRtpPacket(b []byte) [][]byte {
+	if len(b) < RtpFixedHeaderLength {
+		return nil
+	}
+	d.putMedia(b)
+	return d.tryRecover()
+}
+
+// FeedFecPacket 每收到一个fec包，调用一次该函数
+//
+// @return 恢复出的rtp包
+func (d *FecDecoder) FeedFecPacket(pkt RtpPacket) ([][]byte, error) {
+	body := pkt.Body()
+	if len(body) < FecHeaderLength+fecLevelHeaderLengthShort || len(body) < FecHeaderLength+fecLevelHeaderLength(body) {
+		return nil, base.ErrRtpRtcpShortBuffer
+	}
+	levelHeaderLength := fecLevelHeaderLength(body)
+	level := body[FecHeaderLength:]
+	if len(body) < FecHeaderLength+levelHeaderLength+int(bele.BeUint16(level)) {
+		return nil, base.ErrRtpRtcpShortBuffer
+	}
+
+	item := fecItem{
+		ssrc:    pkt.Header.Ssrc,
+		snBase:  bele.BeUint16(body[2:]),
+		payload: append([]byte(nil), body[:FecHeaderLength+levelHeaderLength+int(bele.BeUint16(level))]...),
+	}
+	mask := uint64(bele.BeUint16(level[2:])) << 48
+	if levelHeaderLength == fecLevelHeaderLengthLong {
+		mask |= uint64(bele.BeUint32(level[4:])) << 16
+	}
+	for i := 0; i < FecMaxGroupSize; i++ {
+		if mask&(1<<(63-i)) != 0 {
+			item.seqs = append(item.seqs, item.snBase+uint16(i))
+		}
+	}
+
+	d.fecs = append(d.fecs, item)
+	if len(d.fecs) > fecDecoderMaxFecNum {
+		d.fecs = d.fecs[1:]
+	}
+	return d.tryRecover(), nil
+}
+
+// RecoveredCount 累计恢复的包数
+func (d *FecDecoder) RecoveredCount() uint64 {
+	return d.recoveredCount
+}
+
+func (d *FecDecoder) putMedia(b []byte) {
+	key := fecMediaKey{ssrc: bele.BeUint32(b[8:]), seq: bele.BeUint16(b[2:])}
+	d.hasMedia = true
+	d.lastMediaSsrc = key.ssrc
+	if _, exist := d.media[key]; exist {
+		return
+	}
+	d.media[key] = append([]byte(nil), b...)
+	d.mediaOrder = append(d.mediaOrder, key)
+	if len(d.mediaOrder) > fecDecoderMaxMediaNum {
+		delete(d.media, d.mediaOrder[0])
+		d.mediaOrder = d.mediaOrder[1:]
+	}
+}
+
+// tryRecover 恢复出的包也会参与后续的恢复
+func (d *FecDecoder) tryRecover() [][]byte {
+	var ret [][]byte
+	for {
+		recovered := false
+		remain := d.fecs[:0]
+		for _, item := range d.fecs {
+			ssrc, ok := d.mediaSsrc(item)
+			if !ok {
+				remain = append(remain, item)
+				continue
+			}
+			var missing []uint16
+			for _, seq := range item.seqs {
+				if _, ok := d.media[fecMediaKey{ssrc: ssrc, seq: seq}]; !ok {
+					missing = append(missing, seq)
+				}
+			}
+			switch len(missing) {
+			case 0:
+				// 全部收到，不再需要
+			case 1:
+				b := d.recover(item, ssrc, missing[0])
+				d.putMedia(b)
+				d.recoveredCount++
+				ret = append(ret, b)
+				recovered = true
+			default:
+				remain = append(remain, item)
+			}
+		}
+		d.fecs = remain
+		if !recovered {
+			return ret
+		}
+	}
+}
+
+// mediaSsrc fec包保护的媒体流的ssrc
+//
+// fec包和媒体包ssrc相同时直接使用，否则是单独的fec流，使用最近收到的媒体包的ssrc。
+// 还没有收到过媒体包时，ok为false
+func (d *FecDecoder) mediaSsrc(item fecItem) (ssrc uint32, ok bool) {
+	for _, seq := range item.seqs {
+		if _, exist := d.media[fecMediaKey{ssrc: item.ssrc, seq: seq}]; exist {
+			return item.ssrc, true
+		}
+	}
+	return d.lastMediaSsrc, d.hasMedia
+}
+
+// recover rfc5109 8.2 Recovering the RTP Header and Payload
+func (d *FecDecoder) recover(item fecItem, ssrc uint32, seq uint16) []byte {
+	levelHeaderLength := fecLevelHeaderLength(item.payload)
+	protectionLength := len(item.payload) - FecHeaderLength - levelHeaderLength
+
+	bitString := make([]byte, len(item.payload))
+	copy(bitString, item.payload)
+	for _, s := range item.seqs {
+		if s != seq {
+			fecXorBitString(bitString, d.media[fecMediaKey{ssrc: ssrc, seq: s}])
+		}
+	}
+
+	length := int(bele.BeUint16(bitString[8:]))
+	if length > protectionLength {
+		length = protectionLength
+	}
+	b := make([]byte, RtpFixedHeaderLength+length)
+	b[0] = DefaultRtpVersion<<6 | bitString[0]&0x3F
+	b[1] = bitString[1]
+	bele.BePutUint16(b[2:], seq)
+	copy(b[4:8], bitString[4:8])
+	bele.BePutUint32(b[8:], ssrc)
+	copy(b[RtpFixedHeaderLength:], bitString[FecHeaderLength+levelHeaderLength:])
+	return b
+}
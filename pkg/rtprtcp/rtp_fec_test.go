@@ -0,0 +1,131 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtprtcp_test
+
+import (
+	"testing"
+
+	"github.com/q191201771/lal/pkg/rtprtcp"
+	"github.com/q191201771/naza/pkg/assert"
+)
+
+func TestFec(t *testing.T) {
+	for _, groupSize := range []int{1, 5, 20} {
+		encoder := rtprtcp.NewFecEncoder(127, 2000, groupSize)
+		decoder := rtprtcp.NewFecDecoder()
+
+		// fec流使用单独的ssrc，需要先收到过媒体包
+		first := rtprtcp.MakeDefaultRtpHeader()
+		first.PacketType = 96
+		first.Seq = 65529
+		first.Ssrc = 1000
+		decoder.FeedRtpPacket(rtprtcp.MakeRtpPacket(first, []byte{1}).Raw)
+
+		var pkts []rtprtcp.RtpPacket
+		var fecPkts []rtprtcp.RtpPacket
+		for i := 0; i < groupSize*2; i++ {
+			h := rtprtcp.MakeDefaultRtpHeader()
+			h.PacketType = 96
+			h.Seq = uint16(65530 + i) // 包含序号回绕
+			h.Timestamp = uint32(3000 * i)
+			h.Ssrc = 1000
+			if i%3 == 0 {
+				h.Mark = 1
+			}
+			payload := make([]byte, 10+i*7)
+			for j := range payload {
+				payload[j] = byte(i + j)
+			}
+			pkt := rtprtcp.MakeRtpPacket(h, payload)
+			pkts = append(pkts, pkt)
+			if fecPkt, ok := encoder.FeedRtpPacket(pkt); ok {
+				assert.Equal(t, uint8(127), fecPkt.Header.PacketType)
+				assert.Equal(t, uint32(2000), fecPkt.Header.Ssrc)
+				fecPkts = append(fecPkts, fecPkt)
+			}
+		}
+		assert.Equal(t, 2, len(fecPkts))
+
+		// 每组丢失一个包
+		for g := 0; g < 2; g++ {
+			lost := g*groupSize + groupSize/2
+			for i := g * groupSize; i < (g+1)*groupSize; i++ {
+				if i != lost {
+					assert.Equal(t, 0, len(decoder.FeedRtpPacket(pkts[i].Raw)))
+				}
+			}
+			fecPkt, err := rtprtcp.ParseRtpPacket(fecPkts[g].Raw)
+			assert.Equal(t, nil, err)
+			recovered, err := decoder.FeedFecPacket(fecPkt)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, 1, len(recovered))
+			assert.Equal(t, pkts[lost].Raw, recovered[0])
+		}
+		assert.Equal(t, uint64(2), decoder.RecoveredCount())
+	}
+
+	// 丢失两个包时无法恢复，fec包先于媒体包到达时，后续收到媒体包时恢复
+	encoder := rtprtcp.NewFecEncoder(127, 2000, 4)
+	decoder := rtprtcp.NewFecDecoder()
+	var pkts []rtprtcp.RtpPacket
+	var fecPkt rtprtcp.RtpPacket
+	for i := 0; i < 4; i++ {
+		h := rtprtcp.MakeDefaultRtpHeader()
+		h.PacketType = 96
+		h.Seq = uint16(i)
+		pkt := rtprtcp.MakeRtpPacket(h, []byte{byte(i), 1, 2, 3})
+		pkts = append(pkts, pkt)
+		fecPkt, _ = encoder.FeedRtpPacket(pkt)
+	}
+	recovered, err := decoder.FeedFecPacket(fecPkt)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(recovered))
+	decoder.FeedRtpPacket(pkts[0].Raw)
+	decoder.FeedRtpPacket(pkts[1].Raw)
+	recovered = decoder.FeedRtpPacket(pkts[3].Raw)
+	assert.Equal(t, 1, len(recovered))
+	assert.Equal(t, pkts[2].Raw, recovered[0])
+
+	// 媒体包按(ssrc, seq)缓存，其他ssrc的相同seq的包不影响恢复
+	encoder = rtprtcp.NewFecEncoder(127, 2000, 2)
+	decoder = rtprtcp.NewFecDecoder()
+	pkts = nil
+	for i := 0; i < 2; i++ {
+		h := rtprtcp.MakeDefaultRtpHeader()
+		h.PacketType = 96
+		h.Seq = uint16(100 + i)
+		h.Ssrc = 1000
+		pkt := rtprtcp.MakeRtpPacket(h, []byte{byte(i), 1, 2, 3})
+		pkts = append(pkts, pkt)
+		fecPkt, _ = encoder.FeedRtpPacket(pkt)
+	}
+	other := rtprtcp.MakeDefaultRtpHeader()
+	other.PacketType = 96
+	other.Seq = 101
+	other.Ssrc = 3000
+	decoder.FeedRtpPacket(rtprtcp.MakeRtpPacket(other, []byte{9, 9}).Raw)
+	decoder.FeedRtpPacket(pkts[0].Raw)
+	recovered, err = decoder.FeedFecPacket(fecPkt)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(recovered))
+	assert.Equal(t, pkts[1].Raw, recovered[0])
+
+	// 兼容fec包和媒体包ssrc相同的发送端
+	encoder = rtprtcp.NewFecEncoder(127, 1000, 2)
+	decoder = rtprtcp.NewFecDecoder()
+	for _, pkt := range pkts {
+		fecPkt, _ = encoder.FeedRtpPacket(pkt)
+	}
+	decoder.FeedRtpPacket(pkts[1].Raw)
+	decoder.FeedRtpPacket(rtprtcp.MakeRtpPacket(other, []byte{9, 9}).Raw)
+	recovered, err = decoder.FeedFecPacket(fecPkt)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(recovered))
+	assert.Equal(t, pkts[0].Raw, recovered[0])
+}
@@ -75,6 +75,38 @@ func (r *RtpPacker) Pack(pkt base.AvPacket) (out []RtpPacket) {
 	return
 }
 
+// PackAggregation 将多帧尽可能合并打包，payload packer没有实现 IRtpPackerPayloadAggregator 时，逐帧调用 Pack
+//
+// 每个rtp包使用包中第一帧的时间戳，包中最后一帧完整结束时mark位为1
+func (r *RtpPacker) PackAggregation(pkts []base.AvPacket) (out []RtpPacket) {
+	aggregator, ok := r.payloadPacker.(IRtpPackerPayloadAggregator)
+	if !ok {
+		for _, pkt := range pkts {
+			out = append(out, r.Pack(pkt)...)
+		}
+		return
+	}
+
+	ins := make([][]byte, len(pkts))
+	for i := range pkts {
+		ins[i] = pkts[i].Payload
+	}
+	payloads, index := aggregator.PackAggregation(ins, r.option.MaxPayloadSize)
+	for i, payload := range payloads {
+		pkt := pkts[index[i]]
+		h := MakeDefaultRtpHeader()
+		if i == len(payloads)-1 || index[i+1] != index[i] {
+			h.Mark = 1
+		}
+		h.PacketType = uint8(pkt.PayloadType)
+		h.Seq = r.genSeq()
+		h.Timestamp = uint32(float64(pkt.Timestamp) * float64(r.clockRate) / 1000)
+		h.Ssrc = r.ssrc
+		out = append(out, MakeRtpPacket(h, payload))
+	}
+	return
+}
+
 func (r *RtpPacker) genSeq() (ret uint16) {
 	ret = r.seq
 	r.seq++
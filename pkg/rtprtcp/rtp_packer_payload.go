@@ -14,4 +14,19 @@ type IRtpPackerPayload interface {
 	Pack(in []byte, maxSize int) (out [][]byte)
 }
 
-var _ IRtpPackerPayload = &RtpPackerPayloadAvcHevc{}
+// IRtpPackerPayloadAggregator 支持将多帧打包进一个rtp包的payload packer实现该接口，见 RtpPacker.PackAggregation
+type IRtpPackerPayloadAggregator interface {
+	// PackAggregation
+	//
+	// @param ins: 时间上连续的多帧
+	//
+	// @return out:   rtp payload
+	// @return index: 与out一一对应，表示该payload中第一帧（或分片所属的帧）在ins中的下标
+	//
+	PackAggregation(ins [][]byte, maxSize int) (out [][]byte, index []int)
+}
+
+var (
+	_ IRtpPackerPayload           = &RtpPackerPayloadAvcHevc{}
+	_ IRtpPackerPayloadAggregator = &RtpPackerPayloadAac{}
+)
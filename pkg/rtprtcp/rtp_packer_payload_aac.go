@@ -8,38 +8,179 @@
 
 package rtprtcp
 
+import "github.com/q191201771/naza/pkg/bele"
+
+// rfc3640 AAC-hbr模式下AU-header中各字段所占的位数，和 sdp.Pack 生成的a=fmtp保持一致
+const (
+	aacHbrSizeLength       = 13
+	aacHbrIndexLength      = 3
+	aacHbrIndexDeltaLength = 3
+
+	aacHbrMaxAuSize = 1<<aacHbrSizeLength - 1
+)
+
+type RtpPackerPayloadAacOption struct {
+	// Latm 为true时使用rfc3016 MP4A-LATM格式，否则使用rfc3640 MPEG4-GENERIC的AAC-hbr格式
+	Latm bool
+}
+
+var defaultRtpPackerPayloadAacOption = RtpPackerPayloadAacOption{
+	Latm: false,
+}
+
+type ModRtpPackerPayloadAacOption func(option *RtpPackerPayloadAacOption)
+
 type RtpPackerPayloadAac struct {
+	option RtpPackerPayloadAacOption
 }
 
-func NewRtpPackerPayloadAac() *RtpPackerPayloadAac {
-	return &RtpPackerPayloadAac{}
+func NewRtpPackerPayloadAac(modOptions ...ModRtpPackerPayloadAacOption) *RtpPackerPayloadAac {
+	option := defaultRtpPackerPayloadAacOption
+	for _, fn := range modOptions {
+		fn(&option)
+	}
+	return &RtpPackerPayloadAac{
+		option: option,
+	}
 }
 
+// Pack 一帧打成一个rtp包，帧大于maxSize时分片
 func (r *RtpPackerPayloadAac) Pack(in []byte, maxSize int) (out [][]byte) {
 	if in == nil || maxSize <= 0 {
 		return
 	}
-	// TODO(chef): 目前只支持一帧打成一个rtp包，不支持多帧打成一个rtp包，也不支持一帧跨越两个rtp包
+	out, _ = r.PackAggregation([][]byte{in}, maxSize)
+	return
+}
+
+// PackAggregation 见 IRtpPackerPayloadAggregator
+//
+// 多个连续的帧在不超过maxSize的前提下打成一个rtp包，单帧大于maxSize时分片，分片不和其他帧合并
+func (r *RtpPackerPayloadAac) PackAggregation(ins [][]byte, maxSize int) (out [][]byte, index []int) {
+	if maxSize <= 0 {
+		return
+	}
+	if r.option.Latm {
+		return packAacLatm(ins, maxSize)
+	}
+	return packAacGeneric(ins, maxSize)
+}
+
+// packAacGeneric
+//
+// 协议方面可以参考RtpUnpackerAac那边的代码和注释
+//
+// 简单来说，aac的rtp包分为三个部分，
+// 第一部分描述了au头的总位数，
+// 第二部分是au头的数组，每个au头固定两字节，au头可以解析出每帧的大小
+// 第三部分是帧数据的数组
+//
+// 分片时，每个分片都包含一个au头，au头中的大小为整帧的大小
+//
+// au头中的大小字段只有13位，大于 aacHbrMaxAuSize 的帧无法表示，直接丢弃
+func packAacGeneric(ins [][]byte, maxSize int) (out [][]byte, index []int) {
+	const auHeaderSize = 2
+
+	for i := 0; i < len(ins); {
+		in := ins[i]
+		if len(in) > aacHbrMaxAuSize {
+			Log.Errorf("aac frame size bigger than max au size, drop it. len(in)=%d", len(in))
+			i++
+			continue
+		}
 
-	// 协议方面可以参考RtpUnpackerAac那边的代码和注释
-	//
-	// 简单来说，aac的rtp包分为三个部分，
-	// 第一部分描述了au头的个数，
-	// 第二部分是au头的数组，每个au头固定两字节，au头可以解析出每帧的大小
-	// 第三部分是帧数据的数组
+		if 2+auHeaderSize+len(in) > maxSize {
+			chunkSize := maxSize - 2 - auHeaderSize
+			if chunkSize <= 0 {
+				return
+			}
+			for pos := 0; pos < len(in); pos += chunkSize {
+				end := pos + chunkSize
+				if end > len(in) {
+					end = len(in)
+				}
+				item := make([]byte, 2+auHeaderSize+end-pos)
+				bele.BePutUint16(item, auHeaderSize*8)
+				bele.BePutUint16(item[2:], uint16(len(in)<<aacHbrIndexLength))
+				copy(item[2+auHeaderSize:], in[pos:end])
+				out = append(out, item)
+				index = append(index, i)
+			}
+			i++
+			continue
+		}
 
-	if len(in) > maxSize {
-		Log.Warnf("frame size bigger than rtp payload size while packing. len(in)=%d, maxSize=%d", len(in), maxSize)
+		// 尽可能多的合并
+		j := i
+		total := 2
+		for j < len(ins) && len(ins[j]) <= aacHbrMaxAuSize && total+auHeaderSize+len(ins[j]) <= maxSize {
+			total += auHeaderSize + len(ins[j])
+			j++
+		}
+		item := make([]byte, total)
+		bele.BePutUint16(item, uint16((j-i)*auHeaderSize*8))
+		pos := 2 + (j-i)*auHeaderSize
+		for k := i; k < j; k++ {
+			// index和indexdelta都为0
+			bele.BePutUint16(item[2+(k-i)*auHeaderSize:], uint16(len(ins[k])<<aacHbrIndexLength))
+			pos += copy(item[pos:], ins[k])
+		}
+		out = append(out, item)
+		index = append(index, i)
+		i = j
+	}
+	return
+}
+
+// packAacLatm
+//
+// rfc3016 4.1 RTP Packet Format
+//
+// 每帧打包成一个audioMuxElement，由于sdp中cpresent=0，所以audioMuxElement只包含PayloadLengthInfo和PayloadMux：
+// PayloadLengthInfo由若干个0xFF以及最后一个小于0xFF的字节组成，累加得到帧的大小
+//
+// 一个rtp包可以包含多个audioMuxElement，一个audioMuxElement也可以分片到多个rtp包中，最后一个分片的mark位为1
+func packAacLatm(ins [][]byte, maxSize int) (out [][]byte, index []int) {
+	elements := make([][]byte, len(ins))
+	for i, in := range ins {
+		element := make([]byte, 0, len(in)/255+1+len(in))
+		for n := len(in); ; n -= 255 {
+			if n < 255 {
+				element = append(element, uint8(n))
+				break
+			}
+			element = append(element, 0xFF)
+		}
+		elements[i] = append(element, in...)
 	}
 
-	auHeadersLength := 2 // auHeaderSize * nbAuHeaders = 2 * 1
+	for i := 0; i < len(elements); {
+		if len(elements[i]) > maxSize {
+			for pos := 0; pos < len(elements[i]); pos += maxSize {
+				end := pos + maxSize
+				if end > len(elements[i]) {
+					end = len(elements[i])
+				}
+				out = append(out, elements[i][pos:end])
+				index = append(index, i)
+			}
+			i++
+			continue
+		}
 
-	item := make([]byte, 4+len(in))
-	item[0] = 0
-	item[1] = uint8(auHeadersLength * 8)
-	item[2] = uint8(len(in) >> 5)
-	item[3] = uint8((len(in) & 0x1F) << 3)
-	copy(item[4:], in)
-	out = append(out, item)
+		j := i
+		total := 0
+		for j < len(elements) && total+len(elements[j]) <= maxSize {
+			total += len(elements[j])
+			j++
+		}
+		item := make([]byte, 0, total)
+		for k := i; k < j; k++ {
+			item = append(item, elements[k]...)
+		}
+		out = append(out, item)
+		index = append(index, i)
+		i = j
+	}
 	return
 }
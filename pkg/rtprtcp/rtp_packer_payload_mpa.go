@@ -0,0 +1,85 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtprtcp
+
+import (
+	"github.com/q191201771/lal/pkg/mpa"
+	"github.com/q191201771/naza/pkg/bele"
+)
+
+// RtpPackerPayloadMpa
+//
+// rfc2250 3.5 MPEG Audio-specific header
+//
+//	0                   1                   2                   3
+//	0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//	|             MBZ               |          Frag_offset          |
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//
+// 输入的数据可以包含一个或多个完整的mpa帧，先按帧切分，
+// 多个连续的完整帧在不超过maxSize的前提下合并成一个rtp包，此时Frag_offset为0，
+// 单帧超过maxSize时拆分成多个rtp包，分片不和其他帧合并，Frag_offset为分片在该帧中的偏移
+type RtpPackerPayloadMpa struct {
+}
+
+func NewRtpPackerPayloadMpa() *RtpPackerPayloadMpa {
+	return &RtpPackerPayloadMpa{}
+}
+
+func (r *RtpPackerPayloadMpa) Pack(in []byte, maxSize int) (out [][]byte) {
+	if in == nil || maxSize <= mpaHeaderSize {
+		return
+	}
+
+	// 无法解析的剩余部分作为一帧处理
+	frames, err := mpa.SplitFrames(in)
+	if err != nil {
+		n := 0
+		for _, frame := range frames {
+			n += len(frame)
+		}
+		frames = append(frames, in[n:])
+	}
+
+	maxFragSize := maxSize - mpaHeaderSize
+	for i := 0; i < len(frames); {
+		frame := frames[i]
+		if len(frame) > maxFragSize {
+			for offset := 0; offset < len(frame); offset += maxFragSize {
+				fragSize := len(frame) - offset
+				if fragSize > maxFragSize {
+					fragSize = maxFragSize
+				}
+
+				item := make([]byte, mpaHeaderSize+fragSize)
+				bele.BePutUint16(item[2:], uint16(offset))
+				copy(item[mpaHeaderSize:], frame[offset:offset+fragSize])
+				out = append(out, item)
+			}
+			i++
+			continue
+		}
+
+		// 尽可能多的合并
+		j := i
+		total := mpaHeaderSize
+		for j < len(frames) && total+len(frames[j]) <= maxSize {
+			total += len(frames[j])
+			j++
+		}
+		item := make([]byte, mpaHeaderSize, total)
+		for k := i; k < j; k++ {
+			item = append(item, frames[k]...)
+		}
+		out = append(out, item)
+		i = j
+	}
+	return
+}
@@ -66,9 +66,9 @@ type RtpHeader struct {
 
 	ExtensionProfile uint16
 
-	// Extensions 包含了整个extension，引用的是包体的内存
+	// Extensions 包含了整个extension，不包含profile和length，长度为4的倍数。解析时引用的是包体的内存
 	//
-	// TODO(chef): [opt] 后续考虑解析extension中的单独个item存储至结构体中 202211
+	// 解析时不按rfc8285拆分，需要时通过 GetExtension 或 ParseRtpExtensions 获取
 	Extensions []byte
 
 	payloadOffset uint32 // body部分，真正数据部分的起始位置
@@ -82,14 +82,70 @@ type RtpPacket struct {
 	positionType uint8
 }
 
+// HeaderLength 打包后header的长度，包含csrc和extension
+func (h *RtpHeader) HeaderLength() int {
+	n := RtpFixedHeaderLength + 4*len(h.Csrc)
+	if h.Extensions != nil {
+		n += 4 + len(h.Extensions)
+	}
+	return n
+}
+
+// PackTo 调用方保证<out>的长度不小于 HeaderLength
+//
+// CsrcCount和Extension由Csrc以及Extensions决定
 func (h *RtpHeader) PackTo(out []byte) {
+	h.CsrcCount = uint8(len(h.Csrc))
+	h.Extension = 0
+	if h.Extensions != nil {
+		h.Extension = 1
+	}
+
 	out[0] = h.CsrcCount | (h.Extension << 4) | (h.Padding << 5) | (h.Version << 6)
 	out[1] = h.PacketType | (h.Mark << 7)
 	bele.BePutUint16(out[2:], h.Seq)
 	bele.BePutUint32(out[4:], h.Timestamp)
 	bele.BePutUint32(out[8:], h.Ssrc)
 
-	// TODO(chef): pack csrc 202210
+	offset := RtpFixedHeaderLength
+	for _, csrc := range h.Csrc {
+		bele.BePutUint32(out[offset:], csrc)
+		offset += 4
+	}
+
+	if h.Extensions != nil {
+		bele.BePutUint16(out[offset:], h.ExtensionProfile)
+		bele.BePutUint16(out[offset+2:], uint16(len(h.Extensions)/4))
+		copy(out[offset+4:], h.Extensions)
+	}
+}
+
+// SetExtensions 按rfc8285打包extension，设置 ExtensionProfile 和 Extensions
+func (h *RtpHeader) SetExtensions(exts []RtpExtension) error {
+	profile, b, err := PackRtpExtensions(exts)
+	if err != nil {
+		return err
+	}
+	h.ExtensionProfile = profile
+	h.Extensions = b
+	return nil
+}
+
+// GetExtension 通过id获取extension的值，返回的值引用的是 Extensions 的内存
+//
+// 每次调用时从 Extensions 中查找，不申请内存。profile不是rfc8285格式，或者extension格式错误时返回false
+func (h *RtpHeader) GetExtension(id uint8) (value []byte, ok bool) {
+	if h.Extensions == nil || !IsRtpExtensionProfileRfc8285(h.ExtensionProfile) {
+		return nil, false
+	}
+	_ = walkRtpExtensions(h.ExtensionProfile, h.Extensions, func(extId uint8, extValue []byte) bool {
+		if extId == id {
+			value, ok = extValue, true
+			return false
+		}
+		return true
+	})
+	return
 }
 
 func MakeDefaultRtpHeader() RtpHeader {
@@ -104,9 +160,11 @@ func MakeDefaultRtpHeader() RtpHeader {
 
 func MakeRtpPacket(h RtpHeader, payload []byte) (pkt RtpPacket) {
 	pkt.Header = h
-	pkt.Raw = make([]byte, RtpFixedHeaderLength+len(payload))
+	headerLength := pkt.Header.HeaderLength()
+	pkt.Raw = make([]byte, headerLength+len(payload))
 	pkt.Header.PackTo(pkt.Raw)
-	copy(pkt.Raw[RtpFixedHeaderLength:], payload)
+	pkt.Header.payloadOffset = uint32(headerLength)
+	copy(pkt.Raw[headerLength:], payload)
 	return
 }
 
@@ -140,7 +198,6 @@ func ParseRtpHeader(b []byte) (h RtpHeader, err error) {
 		offset += 4
 	}
 
-	// TODO 按照RFC-5285-RTP-Header-Extensions去解析
 	if h.Extension != 0 {
 		if offset+4 > len(b) {
 			return h, base.ErrRtpRtcpShortBuffer
@@ -156,6 +213,7 @@ func ParseRtpHeader(b []byte) (h RtpHeader, err error) {
 			return h, base.ErrRtpRtcpShortBuffer
 		}
 
+		// extension的内容格式错误不影响媒体数据，解析时不检查，见 GetExtension
 		h.Extensions = b[offset : offset+int(4*extensionLength)]
 		offset += int(4 * extensionLength)
 	}
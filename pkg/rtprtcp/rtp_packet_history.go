@@ -0,0 +1,48 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtprtcp
+
+import "sync"
+
+// RtpPacketHistory 按序号缓存最近发送的rtp包，收到nack时用于重传
+//
+// 只保存引用，调用方保证Put之后不再修改rtp包的内存块
+type RtpPacketHistory struct {
+	mu    sync.Mutex
+	items []RtpPacket
+}
+
+// NewRtpPacketHistory
+//
+// @param size: 缓存的包的数量
+func NewRtpPacketHistory(size int) *RtpPacketHistory {
+	return &RtpPacketHistory{
+		items: make([]RtpPacket, size),
+	}
+}
+
+func (h *RtpPacketHistory) Put(pkt RtpPacket) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.items[int(pkt.Header.Seq)%len(h.items)] = pkt
+}
+
+// Get 包已经被覆盖或者ssrc不匹配时返回false，h为nil时也返回false
+func (h *RtpPacketHistory) Get(ssrc uint32, seq uint16) (RtpPacket, bool) {
+	if h == nil {
+		return RtpPacket{}, false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	pkt := h.items[int(seq)%len(h.items)]
+	if pkt.Raw == nil || pkt.Header.Seq != seq || pkt.Header.Ssrc != ssrc {
+		return RtpPacket{}, false
+	}
+	return pkt, true
+}
@@ -20,8 +20,10 @@ var (
 	_ IRtpUnpacker         = &RtpUnpackContainer{}
 	_ IRtpUnpackContainer  = &RtpUnpackContainer{}
 	_ IRtpUnpackerProtocol = &RtpUnpackerAac{}
+	_ IRtpUnpackerProtocol = &RtpUnpackerAacLatm{}
 	_ IRtpUnpackerProtocol = &RtpUnpackerAvcHevc{}
 	_ IRtpUnpackerProtocol = &RtpUnpackerRaw{}
+	_ IRtpUnpackerProtocol = &RtpUnpackerMpa{}
 )
 
 type IRtpUnpacker interface {
@@ -62,6 +64,8 @@ type IRtpUnpackerProtocol interface {
 //		AAC:
 //		  返回的是raw frame，一个AvPacket只包含一帧。
 //		  引用的是接收到的RTP包中的内存块。
+//		MPA(mp2、mp3):
+//		  带帧头的mpa帧，一个AvPacket只包含一帧。
 //		AVC或HEVC:
 //		  AVCC格式，每个NAL前包含4字节NAL的长度。
 //		  新申请的内存块，回调结束后，内部不再使用该内存块。
@@ -69,17 +73,29 @@ type IRtpUnpackerProtocol interface {
 //		  假如sps和pps是一个stapA包，则合并结果为一个AvPacket。
 type OnAvPacket func(pkt base.AvPacket)
 
-// DefaultRtpUnpackerFactory 目前支持AVC，HEVC和AAC MPEG4-GENERIC，业务方也可以自己实现IRtpUnpackerProtocol，甚至是IRtpUnpackContainer
-func DefaultRtpUnpackerFactory(payloadType base.AvPacketPt, clockRate int, maxSize int, onAvPacket OnAvPacket) IRtpUnpacker {
+// DefaultRtpUnpackerFactory 目前支持AVC，HEVC，AAC MPEG4-GENERIC，AAC MP4A-LATM，MPA，G711和OPUS，业务方也可以自己实现IRtpUnpackerProtocol，甚至是IRtpUnpackContainer
+//
+// @param modAacOptions: 只对AAC有效，一般使用sdp中的参数设置，见 sdp.LogicContext 的 AacLatm 等字段
+func DefaultRtpUnpackerFactory(payloadType base.AvPacketPt, clockRate int, maxSize int, onAvPacket OnAvPacket, modAacOptions ...ModRtpUnpackerAacOption) IRtpUnpacker {
 	nazalog.Debugf("DefaultRtpUnpackerFactory. type=%d, clockRate=%d, maxSize=%d", payloadType, clockRate, maxSize)
 	var protocol IRtpUnpackerProtocol
 	switch payloadType {
 	case base.AvPacketPtAac:
-		protocol = NewRtpUnpackerAac(payloadType, clockRate, onAvPacket)
+		var aacOption RtpUnpackerAacOption
+		for _, fn := range modAacOptions {
+			fn(&aacOption)
+		}
+		if aacOption.Latm {
+			protocol = NewRtpUnpackerAacLatm(payloadType, clockRate, onAvPacket)
+		} else {
+			protocol = NewRtpUnpackerAac(payloadType, clockRate, onAvPacket, modAacOptions...)
+		}
 	case base.AvPacketPtG711U:
 		fallthrough
 	case base.AvPacketPtG711A, base.AvPacketPtOpus:
 		protocol = NewRtpUnpackerRaw(payloadType, clockRate, onAvPacket)
+	case base.AvPacketPtMp2:
+		protocol = NewRtpUnpackerMpa(payloadType, clockRate, onAvPacket)
 	case base.AvPacketPtAvc:
 		fallthrough
 	case base.AvPacketPtHevc:
@@ -10,19 +10,45 @@ package rtprtcp
 
 import (
 	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/naza/pkg/nazabits"
+	"github.com/q191201771/naza/pkg/nazaerrors"
 )
 
+type RtpUnpackerAacOption struct {
+	// rfc3640 AU-header中各字段所占的位数，和sdp中a=fmtp的sizelength、indexlength、indexdeltalength对应，
+	// SizeLength为0时使用AAC-hbr模式的13、3、3
+	SizeLength       int
+	IndexLength      int
+	IndexDeltaLength int
+
+	// Latm 为true时，按rfc3016 MP4A-LATM格式解析，见 RtpUnpackerAacLatm
+	Latm bool
+}
+
+type ModRtpUnpackerAacOption func(option *RtpUnpackerAacOption)
+
 type RtpUnpackerAac struct {
 	payloadType base.AvPacketPt
 	clockRate   int
 	onAvPacket  OnAvPacket
+	option      RtpUnpackerAacOption
 }
 
-func NewRtpUnpackerAac(payloadType base.AvPacketPt, clockRate int, onAvPacket OnAvPacket) *RtpUnpackerAac {
+func NewRtpUnpackerAac(payloadType base.AvPacketPt, clockRate int, onAvPacket OnAvPacket, modOptions ...ModRtpUnpackerAacOption) *RtpUnpackerAac {
+	var option RtpUnpackerAacOption
+	for _, fn := range modOptions {
+		fn(&option)
+	}
+	if option.SizeLength <= 0 {
+		option.SizeLength = aacHbrSizeLength
+		option.IndexLength = aacHbrIndexLength
+		option.IndexDeltaLength = aacHbrIndexDeltaLength
+	}
 	return &RtpUnpackerAac{
 		payloadType: payloadType,
 		clockRate:   clockRate,
 		onAvPacket:  onAvPacket,
+		option:      option,
 	}
 }
 
@@ -67,7 +93,14 @@ func (unpacker *RtpUnpackerAac) TryUnpackOne(list *RtpPacketList) (unpackedFlag
 	}
 	b := p.Packet.Body()
 
-	aus := parseAu(b)
+	aus, err := parseAu(b, unpacker.option)
+	if err != nil {
+		// 无效的包，直接丢弃
+		Log.Warnf("parse au failed. err=%+v", err)
+		list.Head.Next = p.Next
+		list.Size--
+		return true, p.Packet.Header.Seq
+	}
 
 	// 只有一个描述
 	if len(aus) == 1 {
@@ -115,8 +148,8 @@ func (unpacker *RtpUnpackerAac) TryUnpackOne(list *RtpPacketList) (unpackedFlag
 
 			// 注意，非第一个fragment，也会包含au，au的size和第一个fragment里au的size应该相等
 			b = p.Packet.Body()
-			aus := parseAu(b)
-			if len(aus) != 1 {
+			aus, err := parseAu(b, unpacker.option)
+			if err != nil || len(aus) != 1 {
 				Log.Errorf("shall be a single fragment. len(aus)=%d", len(aus))
 				return false, 0
 			}
@@ -154,6 +187,9 @@ func (unpacker *RtpUnpackerAac) TryUnpackOne(list *RtpPacketList) (unpackedFlag
 
 	// more complete access unit
 	for i := range aus {
+		if aus[i].pos+aus[i].size > uint32(len(b)) {
+			break
+		}
 		var outPkt base.AvPacket
 		outPkt.PayloadType = unpacker.payloadType
 		outPkt.Timestamp = int64(p.Packet.Header.Timestamp / uint32(unpacker.clockRate/1000))
@@ -173,41 +209,48 @@ type au struct {
 	pos  uint32 // 相对rtp body的位置
 }
 
-func parseAu(b []byte) (ret []au) {
-	// TODO(chef): [fix] 解析b时，没有判断长度有效性 202207
-
+// parseAu 只支持AU-header中包含size和index（或indexdelta）的情况，不支持CTS、DTS等可选字段
+func parseAu(b []byte, option RtpUnpackerAacOption) (ret []au, err error) {
 	// AU Header Section
-	var auHeadersLength uint32
-	auHeadersLength = uint32(b[0])<<8 + uint32(b[1])
-	auHeadersLength = (auHeadersLength + 7) / 8
-
-	// TODO chef: 这里的2是写死的，正常是外部传入auSize和auIndex所占位数的和
-	const auHeaderSize = 2
-	nbAuHeaders := uint32(auHeadersLength) / auHeaderSize // 有多少个AU-Header
-
-	pauh := uint32(2)                  // AU Header pos
-	pau := uint32(2) + auHeadersLength // AU pos
+	if len(b) < 2 {
+		return nil, nazaerrors.Wrap(base.ErrRtpRtcpShortBuffer)
+	}
+	auHeadersLengthBits := uint32(b[0])<<8 | uint32(b[1])
+	auHeadersLength := (auHeadersLengthBits + 7) / 8
+	if 2+auHeadersLength > uint32(len(b)) {
+		return nil, nazaerrors.Wrap(base.ErrRtpRtcpShortBuffer)
+	}
 
-	for i := uint32(0); i < nbAuHeaders; i++ {
-		// TODO chef: auSize和auIndex所在的位数是写死的13bit，3bit，标准的做法应该从外部传入，比如从sdp中获取后传入
-		auSize := uint32(b[pauh])<<8 | uint32(b[pauh+1]&0xF8) // 13bit
-		auSize /= 8
+	br := nazabits.NewBitReader(b[2 : 2+auHeadersLength])
+	pau := 2 + auHeadersLength // AU pos
+	for consumed := uint32(0); ; {
 		// 注意，fragment时，auIndex并不可靠。见TestAacCase1
-		//auIndex := b[pauh+1] & 0x7
-		//Log.Debugf("~ %d %d", auSize, auIndex)
+		indexLength := uint32(option.IndexDeltaLength)
+		if len(ret) == 0 {
+			indexLength = uint32(option.IndexLength)
+		}
+		if consumed+uint32(option.SizeLength)+indexLength > auHeadersLengthBits {
+			break
+		}
+		auSize, _ := br.ReadBits32(uint(option.SizeLength))
+		if indexLength != 0 {
+			_ = br.SkipBits(uint(indexLength))
+		}
+		consumed += uint32(option.SizeLength) + indexLength
 
 		ret = append(ret, au{
 			size: auSize,
 			pos:  pau,
 		})
-
-		pauh += 2
 		pau += auSize
 	}
+	if len(ret) == 0 {
+		return nil, nazaerrors.Wrap(base.ErrRtpRtcpShortBuffer)
+	}
 
-	if (nbAuHeaders > 1 && pau != uint32(len(b))) ||
-		(nbAuHeaders == 1 && pau < uint32(len(b))) {
-		Log.Warnf("rtp packet size invalid. nbAuHeaders=%d, pau=%d, len(b)=%d, auHeadersLength=%d", nbAuHeaders, pau, len(b), auHeadersLength)
+	if (len(ret) > 1 && pau != uint32(len(b))) ||
+		(len(ret) == 1 && pau < uint32(len(b))) {
+		Log.Warnf("rtp packet size invalid. nbAuHeaders=%d, pau=%d, len(b)=%d, auHeadersLength=%d", len(ret), pau, len(b), auHeadersLength)
 	}
 
 	return
@@ -0,0 +1,100 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtprtcp
+
+import (
+	"github.com/q191201771/lal/pkg/base"
+)
+
+// RtpUnpackerAacLatm rfc3016 MP4A-LATM，打包方式见 packAacLatm
+//
+// 只支持sdp中cpresent=0，并且每个audioMuxElement只包含一帧的情况
+type RtpUnpackerAacLatm struct {
+	payloadType base.AvPacketPt
+	clockRate   int
+	onAvPacket  OnAvPacket
+}
+
+func NewRtpUnpackerAacLatm(payloadType base.AvPacketPt, clockRate int, onAvPacket OnAvPacket) *RtpUnpackerAacLatm {
+	return &RtpUnpackerAacLatm{
+		payloadType: payloadType,
+		clockRate:   clockRate,
+		onAvPacket:  onAvPacket,
+	}
+}
+
+func (unpacker *RtpUnpackerAacLatm) CalcPositionIfNeeded(pkt *RtpPacket) {
+	// noop
+}
+
+func (unpacker *RtpUnpackerAacLatm) TryUnpackOne(list *RtpPacketList) (unpackedFlag bool, unpackedSeq uint16) {
+	p := list.Head.Next // first
+	if p == nil {
+		return false, 0
+	}
+
+	// 收集同一个audioMuxElement的所有分片，最后一个分片的mark位为1
+	// 注意，有的流不设置mark位，所以时间戳变化时也认为结束
+	timestamp := p.Packet.Header.Timestamp
+	last := p
+	packetCount := 1
+	for last.Packet.Header.Mark != 1 {
+		next := last.Next
+		if next == nil || SubSeq(next.Packet.Header.Seq, last.Packet.Header.Seq) != 1 {
+			return false, 0
+		}
+		if next.Packet.Header.Timestamp != timestamp {
+			break
+		}
+		last = next
+		packetCount++
+	}
+
+	var b []byte
+	if packetCount == 1 {
+		b = p.Packet.Body()
+	} else {
+		for q := p; ; q = q.Next {
+			b = append(b, q.Packet.Body()...)
+			if q == last {
+				break
+			}
+		}
+	}
+
+	// 一个rtp包可能包含多个audioMuxElement
+	for i := 0; len(b) != 0; i++ {
+		var length int
+		pos := 0
+		for pos < len(b) {
+			length += int(b[pos])
+			pos++
+			if b[pos-1] != 0xFF {
+				break
+			}
+		}
+		if pos+length > len(b) {
+			Log.Warnf("latm payload length invalid. length=%d, remain=%d", length, len(b)-pos)
+			break
+		}
+
+		var outPkt base.AvPacket
+		outPkt.PayloadType = unpacker.payloadType
+		outPkt.Timestamp = int64(timestamp / uint32(unpacker.clockRate/1000))
+		outPkt.Timestamp += int64(uint32(i * (1024 * 1000) / unpacker.clockRate))
+		outPkt.Payload = b[pos : pos+length]
+		unpacker.onAvPacket(outPkt)
+
+		b = b[pos+length:]
+	}
+
+	list.Head.Next = last.Next
+	list.Size -= packetCount
+	return true, last.Packet.Header.Seq
+}
@@ -0,0 +1,128 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtprtcp
+
+import (
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/lal/pkg/mpa"
+	"github.com/q191201771/naza/pkg/bele"
+)
+
+// RtpUnpackerMpa mp2、mp3的rtp解包，格式见 RtpPackerPayloadMpa
+type RtpUnpackerMpa struct {
+	payloadType base.AvPacketPt
+	clockRate   int
+	onAvPacket  OnAvPacket
+}
+
+func NewRtpUnpackerMpa(payloadType base.AvPacketPt, clockRate int, onAvPacket OnAvPacket) *RtpUnpackerMpa {
+	return &RtpUnpackerMpa{
+		payloadType: payloadType,
+		clockRate:   clockRate,
+		onAvPacket:  onAvPacket,
+	}
+}
+
+func (unpacker *RtpUnpackerMpa) CalcPositionIfNeeded(pkt *RtpPacket) {
+	// noop
+}
+
+func (unpacker *RtpUnpackerMpa) TryUnpackOne(list *RtpPacketList) (unpackedFlag bool, unpackedSeq uint16) {
+	// rfc2250 3.5
+	//
+	// 一个rtp包可以包含一个或多个完整的帧，也可以只包含一个帧的分片。
+	// 同一帧的分片使用相同的timestamp，Frag_offset为分片在帧中的偏移。
+
+	p := list.Head.Next // first
+	if p == nil {
+		return false, 0
+	}
+	b := p.Packet.Body()
+
+	// 丢弃非法的包，以及丢失了第一个分片的帧的剩余分片
+	if len(b) <= mpaHeaderSize || bele.BeUint16(b[2:]) != 0 {
+		Log.Warnf("invalid mpa rtp packet, drop it. len=%d", len(b))
+		list.Head.Next = p.Next
+		list.Size--
+		return true, p.Packet.Header.Seq
+	}
+
+	h, err := mpa.ParseFrameHeader(b[mpaHeaderSize:])
+	if err != nil {
+		Log.Warnf("parse mpa frame header failed, drop it. err=%+v", err)
+		list.Head.Next = p.Next
+		list.Size--
+		return true, p.Packet.Header.Seq
+	}
+
+	timestamp := p.Packet.Header.Timestamp
+	totalSize := h.FrameLength()
+
+	// 一个或多个完整的帧（free format时无法计算帧长，认为整个包就是完整的帧）
+	if totalSize == 0 || totalSize <= len(b[mpaHeaderSize:]) {
+		frames, _ := mpa.SplitFrames(b[mpaHeaderSize:])
+		for i := range frames {
+			var outPkt base.AvPacket
+			outPkt.PayloadType = unpacker.payloadType
+			outPkt.Timestamp = int64(timestamp/uint32(unpacker.clockRate/1000)) + int64(float64(i)*h.FrameDurationMs())
+			outPkt.Payload = frames[i]
+			unpacker.onAvPacket(outPkt)
+		}
+
+		list.Head.Next = p.Next
+		list.Size--
+		return true, p.Packet.Header.Seq
+	}
+
+	// fragmented
+	var as [][]byte
+	as = append(as, b[mpaHeaderSize:])
+	cacheSize := len(b[mpaHeaderSize:])
+
+	seq := p.Packet.Header.Seq
+	packetCount := 1
+	for p = p.Next; p != nil; p = p.Next {
+		if SubSeq(p.Packet.Header.Seq, seq) != 1 {
+			return false, 0
+		}
+		if p.Packet.Header.Timestamp != timestamp {
+			Log.Errorf("fragments of the same frame shall have the same timestamp. first=%d, curr=%d",
+				timestamp, p.Packet.Header.Timestamp)
+			return false, 0
+		}
+
+		b = p.Packet.Body()
+		if len(b) <= mpaHeaderSize || int(bele.BeUint16(b[2:])) != cacheSize {
+			Log.Errorf("invalid mpa fragment. len=%d, cacheSize=%d", len(b), cacheSize)
+			return false, 0
+		}
+
+		packetCount++
+		seq = p.Packet.Header.Seq
+		as = append(as, b[mpaHeaderSize:])
+		cacheSize += len(b[mpaHeaderSize:])
+		if cacheSize < totalSize {
+			continue
+		}
+
+		var outPkt base.AvPacket
+		outPkt.PayloadType = unpacker.payloadType
+		outPkt.Timestamp = int64(timestamp / uint32(unpacker.clockRate/1000))
+		for _, a := range as {
+			outPkt.Payload = append(outPkt.Payload, a...)
+		}
+		unpacker.onAvPacket(outPkt)
+
+		list.Head.Next = p.Next
+		list.Size -= packetCount
+		return true, seq
+	}
+
+	return false, 0
+}
@@ -201,12 +201,12 @@ func testHelperAddPrefixLength(in []byte) (out []byte) {
 
 // ---------------------------------------------------------------------------------------------------------------------
 
-func testHelperUnpack(payloadType base.AvPacketPt, clockRate int, maxSize int, rtpPackets []RtpPacket) []base.AvPacket {
+func testHelperUnpack(payloadType base.AvPacketPt, clockRate int, maxSize int, rtpPackets []RtpPacket, modAacOptions ...ModRtpUnpackerAacOption) []base.AvPacket {
 	var outPkts []base.AvPacket
 	unpacker := DefaultRtpUnpackerFactory(payloadType, clockRate, maxSize, func(pkt base.AvPacket) {
 		//Log.Debugf("%s", hex.EncodeToString(pkt.Payload))
 		outPkts = append(outPkts, pkt)
-	})
+	}, modAacOptions...)
 	for _, pkt := range rtpPackets {
 		unpacker.Feed(pkt)
 	}
@@ -234,3 +234,151 @@ func testHelperHexstream2rtppacket(hexPacket string) (pkt RtpPacket, err error)
 	pkt, err = ParseRtpPacket(raw)
 	return
 }
+
+func TestMpaPackUnpack(t *testing.T) {
+	// MPEG 1 Layer III, 128kbit/s, 44100Hz，帧长417
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+	for i := 4; i < len(frame); i++ {
+		frame[i] = uint8(i)
+	}
+
+	// 一帧一个rtp包
+	packer := NewRtpPacker(NewRtpPackerPayloadMpa(), 90000, 1)
+	pkts := packer.Pack(base.AvPacket{Timestamp: 1000, PayloadType: base.AvPacketPtMp2, Payload: frame})
+	assert.Equal(t, 1, len(pkts))
+	assert.Equal(t, []base.AvPacket{
+		{Timestamp: 1000, PayloadType: base.AvPacketPtMp2, Payload: frame},
+	}, testHelperUnpack(base.AvPacketPtMp2, 90000, 128, pkts))
+
+	// 一帧拆分成多个rtp包
+	packer = NewRtpPacker(NewRtpPackerPayloadMpa(), 90000, 1, func(option *RtpPackerOption) {
+		option.MaxPayloadSize = 150
+	})
+	pkts = packer.Pack(base.AvPacket{Timestamp: 1000, PayloadType: base.AvPacketPtMp2, Payload: frame})
+	assert.Equal(t, 3, len(pkts))
+	assert.Equal(t, uint16(146), bele.BeUint16(pkts[1].Body()[2:]))
+	assert.Equal(t, []base.AvPacket{
+		{Timestamp: 1000, PayloadType: base.AvPacketPtMp2, Payload: frame},
+	}, testHelperUnpack(base.AvPacketPtMp2, 90000, 128, pkts))
+
+	// 丢失第一个分片时，剩余分片被丢弃
+	assert.Equal(t, 0, len(testHelperUnpack(base.AvPacketPtMp2, 90000, 128, pkts[1:])))
+}
+
+func TestMpaPackMultiFrames(t *testing.T) {
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+	for i := 4; i < len(frame); i++ {
+		frame[i] = uint8(i)
+	}
+	var in []byte
+	for i := 0; i < 3; i++ {
+		in = append(in, frame...)
+	}
+
+	// 每帧单独分片，Frag_offset相对于所在帧
+	packer := NewRtpPacker(NewRtpPackerPayloadMpa(), 90000, 1, func(option *RtpPackerOption) {
+		option.MaxPayloadSize = 150
+	})
+	pkts := packer.Pack(base.AvPacket{Timestamp: 1000, PayloadType: base.AvPacketPtMp2, Payload: in})
+	assert.Equal(t, 9, len(pkts))
+	for i, pkt := range pkts {
+		assert.Equal(t, uint16(i%3*146), bele.BeUint16(pkt.Body()[2:]))
+	}
+	assert.Equal(t, []base.AvPacket{
+		{Timestamp: 1000, PayloadType: base.AvPacketPtMp2, Payload: frame},
+		{Timestamp: 1000, PayloadType: base.AvPacketPtMp2, Payload: frame},
+		{Timestamp: 1000, PayloadType: base.AvPacketPtMp2, Payload: frame},
+	}, testHelperUnpack(base.AvPacketPtMp2, 90000, 128, pkts))
+
+	// 完整的帧合并，不拆分到多个包中
+	packer = NewRtpPacker(NewRtpPackerPayloadMpa(), 90000, 1, func(option *RtpPackerOption) {
+		option.MaxPayloadSize = 1000
+	})
+	pkts = packer.Pack(base.AvPacket{Timestamp: 1000, PayloadType: base.AvPacketPtMp2, Payload: in})
+	assert.Equal(t, 2, len(pkts))
+	assert.Equal(t, 4+2*len(frame), len(pkts[0].Body()))
+	assert.Equal(t, uint16(0), bele.BeUint16(pkts[1].Body()[2:]))
+	assert.Equal(t, frame, pkts[1].Body()[4:])
+}
+
+func TestAacPackUnpack(t *testing.T) {
+	const clockRate = 48000
+	var frames []base.AvPacket
+	for i, size := range []int{100, 250, 120, 700} {
+		frame := make([]byte, size)
+		for j := range frame {
+			frame[j] = uint8(i + j)
+		}
+		// 和unpacker中根据1024个采样计算的时间戳保持一致
+		frames = append(frames, base.AvPacket{Timestamp: int64(1000 + i*21), PayloadType: base.AvPacketPtAac, Payload: frame})
+	}
+
+	for _, latm := range []bool{false, true} {
+		modPackerOption := func(option *RtpPackerPayloadAacOption) {
+			option.Latm = latm
+		}
+		modUnpackerOption := func(option *RtpUnpackerAacOption) {
+			option.Latm = latm
+		}
+
+		// 一帧一个rtp包
+		packer := NewRtpPacker(NewRtpPackerPayloadAac(modPackerOption), clockRate, 1)
+		var pkts []RtpPacket
+		for _, frame := range frames {
+			pkts = append(pkts, packer.Pack(frame)...)
+		}
+		assert.Equal(t, 4, len(pkts))
+		assert.Equal(t, frames, testHelperUnpack(base.AvPacketPtAac, clockRate, 128, pkts, modUnpackerOption))
+
+		// 多帧合并，大于rtp包大小的帧分片
+		packer = NewRtpPacker(NewRtpPackerPayloadAac(modPackerOption), clockRate, 1, func(option *RtpPackerOption) {
+			option.MaxPayloadSize = 500
+		})
+		pkts = packer.PackAggregation(frames)
+		assert.Equal(t, 3, len(pkts))
+		assert.Equal(t, uint32(1000*48), pkts[0].Header.Timestamp)
+		assert.Equal(t, uint8(1), pkts[0].Header.Mark)
+		assert.Equal(t, uint8(0), pkts[1].Header.Mark)
+		assert.Equal(t, uint8(1), pkts[2].Header.Mark)
+		assert.Equal(t, pkts[1].Header.Timestamp, pkts[2].Header.Timestamp)
+		assert.Equal(t, frames, testHelperUnpack(base.AvPacketPtAac, clockRate, 128, pkts, modUnpackerOption))
+
+		// 丢失第一个分片时，剩余分片被丢弃
+		assert.Equal(t, frames[:3], testHelperUnpack(base.AvPacketPtAac, clockRate, 128, []RtpPacket{pkts[0], pkts[2]}, modUnpackerOption))
+
+		// 大于13位au size的帧，MPEG4-GENERIC下丢弃，MP4A-LATM下正常分片
+		big := base.AvPacket{Timestamp: 2000, PayloadType: base.AvPacketPtAac, Payload: make([]byte, 8192)}
+		pkts = packer.PackAggregation([]base.AvPacket{frames[0], big, frames[1]})
+		if latm {
+			assert.Equal(t, []base.AvPacket{frames[0], big, frames[1]}, testHelperUnpack(base.AvPacketPtAac, clockRate, 128, pkts, modUnpackerOption))
+		} else {
+			assert.Equal(t, []base.AvPacket{frames[0], frames[1]}, testHelperUnpack(base.AvPacketPtAac, clockRate, 128, pkts, modUnpackerOption))
+		}
+	}
+}
+
+func TestAacAuHeaderParam(t *testing.T) {
+	// AAC-lbr模式，sizelength=6，indexlength=2，indexdeltalength=2，一个包两帧
+	frame1 := []byte{1, 2, 3}
+	frame2 := []byte{4, 5, 6, 7, 8}
+	payload := []byte{0x00, 0x10, uint8(len(frame1) << 2), uint8(len(frame2) << 2)}
+	payload = append(payload, frame1...)
+	payload = append(payload, frame2...)
+
+	h := MakeDefaultRtpHeader()
+	h.PacketType = uint8(base.AvPacketPtAac)
+	h.Timestamp = 16000
+	h.Mark = 1
+	pkt := MakeRtpPacket(h, payload)
+	out := testHelperUnpack(base.AvPacketPtAac, 16000, 128, []RtpPacket{pkt}, func(option *RtpUnpackerAacOption) {
+		option.SizeLength = 6
+		option.IndexLength = 2
+		option.IndexDeltaLength = 2
+	})
+	assert.Equal(t, 2, len(out))
+	assert.Equal(t, frame1, out[0].Payload)
+	assert.Equal(t, frame2, out[1].Payload)
+	assert.Equal(t, int64(1064), out[1].Timestamp)
+}
@@ -0,0 +1,509 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtprtcp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"hash"
+	"sync"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/naza/pkg/bele"
+	"github.com/q191201771/naza/pkg/nazaerrors"
+)
+
+// srtp.go
+//
+// rfc3711 SRTP，支持AES-CM + HMAC-SHA1，以及rfc7714 AES-GCM
+//
+// SRTP:
+// +----------------------------+---------------------------+-----------------+
+// |         rtp header         |   encrypted rtp payload   |  auth tag（CM）  |
+// +----------------------------+---------------------------+-----------------+
+//
+// SRTCP:
+// +----------------------------+---------------------------+-----------------+-----------------+
+// | rtcp header + sender ssrc  |   encrypted rtcp payload  |  E + srtcp index | auth tag（CM）   |
+// +----------------------------+---------------------------+-----------------+-----------------+
+//
+// GCM模式下，auth tag包含在加密后的payload的末尾
+//
+// 解密时使用rfc3711 3.3.2的滑动窗口做重放保护，每个SSRC一个窗口，RTP使用48位的index（ROC+SEQ），RTCP使用SRTCP index
+//
+// 目前只支持kdr为0，不支持MKI
+
+// SrtpProfile 取值和rfc4568 SDES中的crypto-suite名称一致，见 sdp.ACrypto
+const (
+	SrtpProfileAesCm128HmacSha1_80 = "AES_CM_128_HMAC_SHA1_80"
+	SrtpProfileAesCm128HmacSha1_32 = "AES_CM_128_HMAC_SHA1_32"
+	SrtpProfileAeadAes128Gcm       = "AEAD_AES_128_GCM"
+	SrtpProfileAeadAes256Gcm       = "AEAD_AES_256_GCM"
+)
+
+const (
+	srtpLabelRtpEncryption  = 0x00
+	srtpLabelRtpAuth        = 0x01
+	srtpLabelRtpSalt        = 0x02
+	srtpLabelRtcpEncryption = 0x03
+	srtpLabelRtcpAuth       = 0x04
+	srtpLabelRtcpSalt       = 0x05
+
+	srtpAuthKeyLength = 20
+	srtcpIndexLength  = 4
+	srtcpEBit         = 0x80000000
+	srtcpIndexMask    = 0x7FFFFFFF
+
+	srtpReplayWindowSize = 64
+)
+
+type srtpProfileInfo struct {
+	keyLength  int
+	saltLength int
+	tagLength  int // rtp的auth tag长度，GCM时为gcm tag的长度
+	gcm        bool
+}
+
+var srtpProfiles = map[string]srtpProfileInfo{
+	SrtpProfileAesCm128HmacSha1_80: {keyLength: 16, saltLength: 14, tagLength: 10},
+	SrtpProfileAesCm128HmacSha1_32: {keyLength: 16, saltLength: 14, tagLength: 4},
+	SrtpProfileAeadAes128Gcm:       {keyLength: 16, saltLength: 12, tagLength: 16, gcm: true},
+	SrtpProfileAeadAes256Gcm:       {keyLength: 32, saltLength: 12, tagLength: 16, gcm: true},
+}
+
+// SrtpMasterKeyLength profile对应的master key和master salt的长度
+func SrtpMasterKeyLength(profile string) (keyLength, saltLength int, err error) {
+	info, ok := srtpProfiles[profile]
+	if !ok {
+		return 0, 0, nazaerrors.Wrap(base.ErrSrtpUnsupportedProfile, profile)
+	}
+	return info.keyLength, info.saltLength, nil
+}
+
+// SrtpContext 一个master key对应一个context，同时用于加密本端发送的和解密对端发送的rtp、rtcp包
+//
+// 注意，两个方向上的ROC等状态是分开维护的。协程安全
+type SrtpContext struct {
+	profile srtpProfileInfo
+
+	rtpBlock  cipher.Block
+	rtpSalt   []byte
+	rtpGcm    cipher.AEAD
+	rtpAuth   hash.Hash
+	rtcpBlock cipher.Block
+	rtcpSalt  []byte
+	rtcpGcm   cipher.AEAD
+	rtcpAuth  hash.Hash
+
+	mu             sync.Mutex
+	encRtpStates   map[uint32]*srtpSsrcState
+	decRtpStates   map[uint32]*srtpSsrcState
+	encRtcpIndex   uint32
+	decRtcpWindows map[uint32]*srtpReplayWindow
+}
+
+type srtpSsrcState struct {
+	roc     uint32
+	lastSeq uint16
+	window  srtpReplayWindow // 只有解密时使用
+}
+
+// NewSrtpContext
+//
+// @param profile:    取值见 SrtpProfileAesCm128HmacSha1_80 等
+// @param masterKey:  长度见 SrtpMasterKeyLength
+// @param masterSalt: 长度见 SrtpMasterKeyLength
+func NewSrtpContext(profile string, masterKey, masterSalt []byte) (*SrtpContext, error) {
+	info, ok := srtpProfiles[profile]
+	if !ok {
+		return nil, nazaerrors.Wrap(base.ErrSrtpUnsupportedProfile, profile)
+	}
+	if len(masterKey) != info.keyLength || len(masterSalt) != info.saltLength {
+		return nil, nazaerrors.Wrap(base.ErrSrtpUnsupportedProfile, "invalid master key or salt length")
+	}
+
+	c := &SrtpContext{
+		profile:        info,
+		encRtpStates:   make(map[uint32]*srtpSsrcState),
+		decRtpStates:   make(map[uint32]*srtpSsrcState),
+		decRtcpWindows: make(map[uint32]*srtpReplayWindow),
+	}
+
+	var err error
+	if c.rtpBlock, c.rtpSalt, c.rtpAuth, err = c.deriveKeys(masterKey, masterSalt, srtpLabelRtpEncryption, srtpLabelRtpAuth, srtpLabelRtpSalt); err != nil {
+		return nil, err
+	}
+	if c.rtcpBlock, c.rtcpSalt, c.rtcpAuth, err = c.deriveKeys(masterKey, masterSalt, srtpLabelRtcpEncryption, srtpLabelRtcpAuth, srtpLabelRtcpSalt); err != nil {
+		return nil, err
+	}
+	if info.gcm {
+		if c.rtpGcm, err = cipher.NewGCM(c.rtpBlock); err != nil {
+			return nil, err
+		}
+		if c.rtcpGcm, err = cipher.NewGCM(c.rtcpBlock); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// EncryptRtp 不修改参数<b>的内存，返回新的内存块
+func (c *SrtpContext) EncryptRtp(b []byte) ([]byte, error) {
+	h, err := ParseRtpHeader(b)
+	if err != nil {
+		return nil, err
+	}
+	headerLength := int(h.payloadOffset)
+
+	c.mu.Lock()
+	roc := c.estimateRoc(c.encRtpStates, h.Ssrc, h.Seq)
+	c.updateRoc(c.encRtpStates, h.Ssrc, h.Seq, roc)
+	c.mu.Unlock()
+
+	if c.profile.gcm {
+		out := make([]byte, headerLength, len(b)+c.profile.tagLength)
+		copy(out, b[:headerLength])
+		iv := c.rtpGcmIv(h.Ssrc, roc, h.Seq)
+		return c.rtpGcm.Seal(out, iv, b[headerLength:], b[:headerLength]), nil
+	}
+
+	out := make([]byte, len(b), len(b)+c.profile.tagLength)
+	copy(out, b[:headerLength])
+	iv := c.rtpCmIv(h.Ssrc, roc, h.Seq)
+	cipher.NewCTR(c.rtpBlock, iv).XORKeyStream(out[headerLength:], b[headerLength:])
+	return append(out, c.rtpTag(out, roc)...), nil
+}
+
+// DecryptRtp 不修改参数<b>的内存，返回新的内存块
+func (c *SrtpContext) DecryptRtp(b []byte) ([]byte, error) {
+	if len(b) < RtpFixedHeaderLength+c.profile.tagLength {
+		return nil, base.ErrRtpRtcpShortBuffer
+	}
+	// 保证header不会和末尾的tag重叠，多保留1字节是因为payload可能为空
+	h, err := ParseRtpHeader(b[:len(b)-c.profile.tagLength+1])
+	if err != nil {
+		return nil, err
+	}
+	headerLength := int(h.payloadOffset)
+
+	// 先检查重放，避免对重放的包做认证计算
+	c.mu.Lock()
+	roc := c.estimateRoc(c.decRtpStates, h.Ssrc, h.Seq)
+	index := uint64(roc)<<16 | uint64(h.Seq)
+	if s, ok := c.decRtpStates[h.Ssrc]; ok && !s.window.check(index) {
+		c.mu.Unlock()
+		return nil, base.ErrSrtpReplayed
+	}
+	c.mu.Unlock()
+
+	var out []byte
+	if c.profile.gcm {
+		out = make([]byte, headerLength, len(b)-c.profile.tagLength)
+		copy(out, b[:headerLength])
+		iv := c.rtpGcmIv(h.Ssrc, roc, h.Seq)
+		if out, err = c.rtpGcm.Open(out, iv, b[headerLength:], b[:headerLength]); err != nil {
+			return nil, nazaerrors.Wrap(base.ErrSrtpAuthFailed, err.Error())
+		}
+	} else {
+		authPortion := b[:len(b)-c.profile.tagLength]
+		tag := b[len(authPortion):]
+		if subtle.ConstantTimeCompare(c.rtpTag(authPortion, roc), tag) != 1 {
+			return nil, base.ErrSrtpAuthFailed
+		}
+		out = make([]byte, len(authPortion))
+		copy(out, b[:headerLength])
+		iv := c.rtpCmIv(h.Ssrc, roc, h.Seq)
+		cipher.NewCTR(c.rtpBlock, iv).XORKeyStream(out[headerLength:], authPortion[headerLength:])
+	}
+
+	// 认证通过后才更新状态。并发解密相同的包时，只有一个成功
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.decRtpStates[h.Ssrc]; ok && !s.window.check(index) {
+		return nil, base.ErrSrtpReplayed
+	}
+	c.updateRoc(c.decRtpStates, h.Ssrc, h.Seq, roc)
+	c.decRtpStates[h.Ssrc].window.update(index)
+	return out, nil
+}
+
+// EncryptRtcp 不修改参数<b>的内存，返回新的内存块
+func (c *SrtpContext) EncryptRtcp(b []byte) ([]byte, error) {
+	if len(b) < 8 {
+		return nil, base.ErrRtpRtcpShortBuffer
+	}
+	ssrc := bele.BeUint32(b[4:])
+
+	c.mu.Lock()
+	c.encRtcpIndex = (c.encRtcpIndex + 1) & srtcpIndexMask
+	index := c.encRtcpIndex
+	c.mu.Unlock()
+
+	eIndex := make([]byte, srtcpIndexLength)
+	bele.BePutUint32(eIndex, index|srtcpEBit)
+
+	if c.profile.gcm {
+		out := make([]byte, 8, len(b)+c.profile.tagLength+srtcpIndexLength)
+		copy(out, b[:8])
+		aad := append(append([]byte{}, b[:8]...), eIndex...)
+		out = c.rtcpGcm.Seal(out, c.rtcpGcmIv(ssrc, index), b[8:], aad)
+		return append(out, eIndex...), nil
+	}
+
+	out := make([]byte, len(b), len(b)+srtcpIndexLength+c.rtcpTagLength())
+	copy(out, b[:8])
+	cipher.NewCTR(c.rtcpBlock, c.rtcpCmIv(ssrc, index)).XORKeyStream(out[8:], b[8:])
+	out = append(out, eIndex...)
+	return append(out, c.rtcpTag(out)...), nil
+}
+
+// DecryptRtcp 不修改参数<b>的内存，返回新的内存块
+func (c *SrtpContext) DecryptRtcp(b []byte) ([]byte, error) {
+	tagLength := c.rtcpTagLength()
+	if c.profile.gcm {
+		tagLength = 0 // 包含在加密后的数据中
+	}
+	if len(b) < 8+srtcpIndexLength+tagLength {
+		return nil, base.ErrRtpRtcpShortBuffer
+	}
+	ssrc := bele.BeUint32(b[4:])
+	authPortion := b[:len(b)-tagLength]
+	eIndex := authPortion[len(authPortion)-srtcpIndexLength:]
+	index := bele.BeUint32(eIndex) & srtcpIndexMask
+	encrypted := bele.BeUint32(eIndex)&srtcpEBit != 0
+	body := authPortion[8 : len(authPortion)-srtcpIndexLength]
+
+	c.mu.Lock()
+	window, ok := c.decRtcpWindows[ssrc]
+	replayed := ok && !window.check(uint64(index))
+	c.mu.Unlock()
+	if replayed {
+		return nil, base.ErrSrtpReplayed
+	}
+
+	var out []byte
+	if c.profile.gcm {
+		if !encrypted {
+			return nil, nazaerrors.Wrap(base.ErrSrtpAuthFailed, "unencrypted srtcp not supported")
+		}
+		out = make([]byte, 8, 8+len(body))
+		copy(out, b[:8])
+		aad := append(append([]byte{}, b[:8]...), eIndex...)
+		var err error
+		if out, err = c.rtcpGcm.Open(out, c.rtcpGcmIv(ssrc, index), body, aad); err != nil {
+			return nil, nazaerrors.Wrap(base.ErrSrtpAuthFailed, err.Error())
+		}
+	} else {
+		if subtle.ConstantTimeCompare(c.rtcpTag(authPortion), b[len(authPortion):]) != 1 {
+			return nil, base.ErrSrtpAuthFailed
+		}
+		out = make([]byte, 8+len(body))
+		copy(out, b[:8])
+		if encrypted {
+			cipher.NewCTR(c.rtcpBlock, c.rtcpCmIv(ssrc, index)).XORKeyStream(out[8:], body)
+		} else {
+			copy(out[8:], body)
+		}
+	}
+
+	// 认证通过后才创建、更新窗口
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	window, ok = c.decRtcpWindows[ssrc]
+	if !ok {
+		window = &srtpReplayWindow{}
+		c.decRtcpWindows[ssrc] = window
+	}
+	if !window.check(uint64(index)) {
+		return nil, base.ErrSrtpReplayed
+	}
+	window.update(uint64(index))
+	return out, nil
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// deriveKeys rfc3711 4.3.1 Key Derivation Algorithm，kdr为0
+func (c *SrtpContext) deriveKeys(masterKey, masterSalt []byte, encLabel, authLabel, saltLabel byte) (block cipher.Block, salt []byte, auth hash.Hash, err error) {
+	prf, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return
+	}
+	if block, err = aes.NewCipher(srtpDeriveKey(prf, masterSalt, encLabel, c.profile.keyLength)); err != nil {
+		return
+	}
+	salt = srtpDeriveKey(prf, masterSalt, saltLabel, c.profile.saltLength)
+	if !c.profile.gcm {
+		auth = hmac.New(sha1.New, srtpDeriveKey(prf, masterSalt, authLabel, srtpAuthKeyLength))
+	}
+	return
+}
+
+// srtpDeriveKey x = (label * 2^48) XOR master_salt，使用AES-CM，IV = x * 2^16
+//
+// GCM的master salt为96位，同样在末尾补0
+func srtpDeriveKey(prf cipher.Block, masterSalt []byte, label byte, n int) []byte {
+	iv := make([]byte, aes.BlockSize)
+	copy(iv, masterSalt)
+	iv[7] ^= label
+	out := make([]byte, n)
+	cipher.NewCTR(prf, iv).XORKeyStream(out, out)
+	return out
+}
+
+// rtpCmIv rfc3711 4.1.1 IV = (k_s * 2^16) XOR (SSRC * 2^64) XOR (i * 2^16)
+func (c *SrtpContext) rtpCmIv(ssrc uint32, roc uint32, seq uint16) []byte {
+	iv := make([]byte, aes.BlockSize)
+	copy(iv, c.rtpSalt)
+	xorUint32(iv[4:], ssrc)
+	xorUint32(iv[8:], roc)
+	iv[12] ^= byte(seq >> 8)
+	iv[13] ^= byte(seq)
+	return iv
+}
+
+func (c *SrtpContext) rtcpCmIv(ssrc uint32, index uint32) []byte {
+	iv := make([]byte, aes.BlockSize)
+	copy(iv, c.rtcpSalt)
+	xorUint32(iv[4:], ssrc)
+	xorUint32(iv[10:], index)
+	return iv
+}
+
+// rtpGcmIv rfc7714 8.1 IV = (00 00 || SSRC || ROC || SEQ) XOR salt
+func (c *SrtpContext) rtpGcmIv(ssrc uint32, roc uint32, seq uint16) []byte {
+	iv := make([]byte, 12)
+	copy(iv, c.rtpSalt)
+	xorUint32(iv[2:], ssrc)
+	xorUint32(iv[6:], roc)
+	iv[10] ^= byte(seq >> 8)
+	iv[11] ^= byte(seq)
+	return iv
+}
+
+// rtcpGcmIv rfc7714 9.1 IV = (00 00 || SSRC || 00 00 || 0 + SRTCP index) XOR salt
+func (c *SrtpContext) rtcpGcmIv(ssrc uint32, index uint32) []byte {
+	iv := make([]byte, 12)
+	copy(iv, c.rtcpSalt)
+	xorUint32(iv[2:], ssrc)
+	xorUint32(iv[8:], index)
+	return iv
+}
+
+// rtpTag rfc3711 4.2 HMAC-SHA1(M || ROC)
+func (c *SrtpContext) rtpTag(authPortion []byte, roc uint32) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rocBuf := make([]byte, 4)
+	bele.BePutUint32(rocBuf, roc)
+	c.rtpAuth.Reset()
+	c.rtpAuth.Write(authPortion)
+	c.rtpAuth.Write(rocBuf)
+	return c.rtpAuth.Sum(nil)[:c.profile.tagLength]
+}
+
+func (c *SrtpContext) rtcpTag(authPortion []byte) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rtcpAuth.Reset()
+	c.rtcpAuth.Write(authPortion)
+	return c.rtcpAuth.Sum(nil)[:c.rtcpTagLength()]
+}
+
+// rtcpTagLength SRTCP的auth tag固定为80位，见rfc3711 5.2
+func (c *SrtpContext) rtcpTagLength() int {
+	if c.profile.gcm {
+		return c.profile.tagLength
+	}
+	return 10
+}
+
+// estimateRoc rfc3711 3.3.1 以及 Appendix A，调用方保证持有锁
+func (c *SrtpContext) estimateRoc(states map[uint32]*srtpSsrcState, ssrc uint32, seq uint16) uint32 {
+	s, ok := states[ssrc]
+	if !ok {
+		return 0
+	}
+	if s.lastSeq < 32768 {
+		if int(seq)-int(s.lastSeq) > 32768 && s.roc > 0 {
+			return s.roc - 1
+		}
+	} else {
+		if int(s.lastSeq)-32768 > int(seq) {
+			return s.roc + 1
+		}
+	}
+	return s.roc
+}
+
+// updateRoc 调用方保证持有锁
+func (c *SrtpContext) updateRoc(states map[uint32]*srtpSsrcState, ssrc uint32, seq uint16, roc uint32) {
+	s, ok := states[ssrc]
+	if !ok {
+		states[ssrc] = &srtpSsrcState{roc: roc, lastSeq: seq}
+		return
+	}
+	if roc > s.roc || (roc == s.roc && seq > s.lastSeq) {
+		s.roc = roc
+		s.lastSeq = seq
+	}
+}
+
+// srtpReplayWindow rfc3711 3.3.2 重放保护的滑动窗口
+//
+// 记录收到的最大index，以及最大index之前的 srtpReplayWindowSize 个index是否已经收到。
+// 比窗口更旧的包直接丢弃
+type srtpReplayWindow struct {
+	inited   bool
+	maxIndex uint64
+	bitmap   uint64 // 第i位表示 maxIndex-i 已经收到
+}
+
+// check 返回false表示重放或者太旧的包
+func (w *srtpReplayWindow) check(index uint64) bool {
+	if !w.inited || index > w.maxIndex {
+		return true
+	}
+	delta := w.maxIndex - index
+	if delta >= srtpReplayWindowSize {
+		return false
+	}
+	return w.bitmap&(1<<delta) == 0
+}
+
+// update 认证通过后调用
+func (w *srtpReplayWindow) update(index uint64) {
+	if !w.inited {
+		w.inited = true
+		w.maxIndex = index
+		w.bitmap = 1
+		return
+	}
+	if index > w.maxIndex {
+		delta := index - w.maxIndex
+		if delta >= srtpReplayWindowSize {
+			w.bitmap = 1
+		} else {
+			w.bitmap = w.bitmap<<delta | 1
+		}
+		w.maxIndex = index
+		return
+	}
+	w.bitmap |= 1 << (w.maxIndex - index)
+}
+
+func xorUint32(b []byte, v uint32) {
+	b[0] ^= byte(v >> 24)
+	b[1] ^= byte(v >> 16)
+	b[2] ^= byte(v >> 8)
+	b[3] ^= byte(v)
+}
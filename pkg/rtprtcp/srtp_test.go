@@ -0,0 +1,259 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtprtcp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/naza/pkg/assert"
+)
+
+func TestSrtpDeriveKey(t *testing.T) {
+	// rfc3711 B.3 Key Derivation Test Vectors
+	masterKey, _ := hex.DecodeString("E1F97A0D3E018BE0D64FA32C06DE4139")
+	masterSalt, _ := hex.DecodeString("0EC675AD498AFEEBB6960B3AABE6")
+	prf, err := aes.NewCipher(masterKey)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "c61e7a93744f39ee10734afe3ff7a087", hex.EncodeToString(srtpDeriveKey(prf, masterSalt, srtpLabelRtpEncryption, 16)))
+	assert.Equal(t, "30cbbc08863d8c85d49db34a9ae1", hex.EncodeToString(srtpDeriveKey(prf, masterSalt, srtpLabelRtpSalt, 14)))
+	assert.Equal(t, "cebe321f6ff7716b6fd4ab49af256a156d38baa4", hex.EncodeToString(srtpDeriveKey(prf, masterSalt, srtpLabelRtpAuth, 20)))
+}
+
+func TestSrtpContext(t *testing.T) {
+	for _, profile := range []string{SrtpProfileAesCm128HmacSha1_80, SrtpProfileAesCm128HmacSha1_32, SrtpProfileAeadAes128Gcm, SrtpProfileAeadAes256Gcm} {
+		keyLength, saltLength, err := SrtpMasterKeyLength(profile)
+		assert.Equal(t, nil, err)
+		key := make([]byte, keyLength)
+		salt := make([]byte, saltLength)
+		for i := range key {
+			key[i] = byte(i)
+		}
+		for i := range salt {
+			salt[i] = byte(0xA0 + i)
+		}
+		sender, err := NewSrtpContext(profile, key, salt)
+		assert.Equal(t, nil, err)
+		receiver, err := NewSrtpContext(profile, key, salt)
+		assert.Equal(t, nil, err)
+
+		// 包含序号回绕，验证ROC
+		for _, seq := range []uint16{65534, 65535, 0, 1} {
+			h := MakeDefaultRtpHeader()
+			h.PacketType = 96
+			h.Seq = seq
+			h.Timestamp = 1000
+			h.Ssrc = 0x12345678
+			pkt := MakeRtpPacket(h, []byte("hello srtp"))
+
+			enc, err := sender.EncryptRtp(pkt.Raw)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, pkt.Raw[:RtpFixedHeaderLength], enc[:RtpFixedHeaderLength])
+			assert.Equal(t, len(pkt.Raw)+sender.profile.tagLength, len(enc))
+			assert.Equal(t, false, string(enc[RtpFixedHeaderLength:len(pkt.Raw)]) == "hello srtp")
+
+			dec, err := receiver.DecryptRtp(enc)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, pkt.Raw, dec)
+
+			// 篡改后认证失败
+			enc[RtpFixedHeaderLength] ^= 1
+			_, err = receiver.DecryptRtp(enc)
+			assert.IsNotNil(t, err)
+		}
+		assert.Equal(t, uint32(1), sender.encRtpStates[0x12345678].roc)
+		assert.Equal(t, uint32(1), receiver.decRtpStates[0x12345678].roc)
+
+		rr := []byte{0x81, 0xc9, 0x00, 0x07, 0x11, 0x22, 0x33, 0x44,
+			0x12, 0x34, 0x56, 0x78, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+		for i := 0; i < 2; i++ {
+			enc, err := receiver.EncryptRtcp(rr)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, rr[:8], enc[:8])
+			dec, err := sender.DecryptRtcp(enc)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, rr, dec)
+
+			enc[len(enc)-1] ^= 1
+			_, err = sender.DecryptRtcp(enc)
+			assert.IsNotNil(t, err)
+		}
+	}
+
+	_, err := NewSrtpContext("NOT_EXIST", nil, nil)
+	assert.IsNotNil(t, err)
+	_, err = NewSrtpContext(SrtpProfileAesCm128HmacSha1_80, make([]byte, 16), make([]byte, 12))
+	assert.IsNotNil(t, err)
+
+	// 不同的key之间无法解密
+	c1, _ := NewSrtpContext(SrtpProfileAesCm128HmacSha1_80, make([]byte, 16), make([]byte, 14))
+	c2, _ := NewSrtpContext(SrtpProfileAesCm128HmacSha1_80, append(make([]byte, 15), 1), make([]byte, 14))
+	h := MakeDefaultRtpHeader()
+	h.Ssrc = 1
+	enc, err := c1.EncryptRtp(MakeRtpPacket(h, []byte{1, 2, 3}).Raw)
+	assert.Equal(t, nil, err)
+	_, err = c2.DecryptRtp(enc)
+	assert.Equal(t, base.ErrSrtpAuthFailed, err)
+}
+
+// newTestSrtpSessionContext 直接使用session key和session salt创建context，跳过key derivation，用于已知答案测试
+func newTestSrtpSessionContext(t *testing.T, profile string, key, salt []byte) *SrtpContext {
+	block, err := aes.NewCipher(key)
+	assert.Equal(t, nil, err)
+	c := &SrtpContext{
+		profile:        srtpProfiles[profile],
+		rtpBlock:       block,
+		rtpSalt:        salt,
+		rtcpBlock:      block,
+		rtcpSalt:       salt,
+		encRtpStates:   make(map[uint32]*srtpSsrcState),
+		decRtpStates:   make(map[uint32]*srtpSsrcState),
+		decRtcpWindows: make(map[uint32]*srtpReplayWindow),
+	}
+	if c.profile.gcm {
+		c.rtpGcm, err = cipher.NewGCM(block)
+		assert.Equal(t, nil, err)
+		c.rtcpGcm = c.rtpGcm
+	}
+	return c
+}
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestSrtpAesCmKeystream(t *testing.T) {
+	// rfc3711 B.2 AES-CM Test Vectors
+	c := newTestSrtpSessionContext(t, SrtpProfileAesCm128HmacSha1_80,
+		mustDecodeHex("2B7E151628AED2A6ABF7158809CF4F3C"),
+		mustDecodeHex("F0F1F2F3F4F5F6F7F8F9FAFBFCFD"))
+	iv := c.rtpCmIv(0, 0, 0)
+	assert.Equal(t, mustDecodeHex("F0F1F2F3F4F5F6F7F8F9FAFBFCFD0000"), iv)
+
+	keystream := make([]byte, 0xff02*aes.BlockSize)
+	cipher.NewCTR(c.rtpBlock, iv).XORKeyStream(keystream, keystream)
+	block := func(i int) []byte {
+		return keystream[i*aes.BlockSize : (i+1)*aes.BlockSize]
+	}
+	assert.Equal(t, mustDecodeHex("E03EAD0935C95E80E166B16DD92B4EB4"), block(0))
+	assert.Equal(t, mustDecodeHex("D23513162B02D0F72A43A2FE4A5F97AB"), block(1))
+	assert.Equal(t, mustDecodeHex("41E95B3BB0A2E8DD477901E4FCA894C0"), block(2))
+	assert.Equal(t, mustDecodeHex("EC8CDF7398607CB0F2D21675EA9EA1E4"), block(0xfeff))
+	assert.Equal(t, mustDecodeHex("362B7C3C6773516318A077D7FC5073AE"), block(0xff00))
+	assert.Equal(t, mustDecodeHex("6A2CC3787889374FBEB4C81B17BA6C44"), block(0xff01))
+}
+
+func TestSrtpAeadAesGcm(t *testing.T) {
+	key := mustDecodeHex("000102030405060708090a0b0c0d0e0f")
+	salt := mustDecodeHex("517569642070726f2071756f")
+
+	// rfc7714 16.1.1 RTP
+	rtp := mustDecodeHex("8040f17b 8041f8d3 5501a0b2 47616c6c 69612065 7374206f 6d6e6973 20646976 69736120 696e2070 61727465 73207472 6573")
+	srtp := mustDecodeHex("8040f17b 8041f8d3 5501a0b2 f24de3a3 fb34de6c acba861c 9d7e4bca be633bd5 0d294e6f 42a5f47a 51c7d19b 36de3adf 8833899d 7f27beb1 6a9152cf 765ee439 0cce")
+	sender := newTestSrtpSessionContext(t, SrtpProfileAeadAes128Gcm, key, salt)
+	enc, err := sender.EncryptRtp(rtp)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, srtp, enc)
+	receiver := newTestSrtpSessionContext(t, SrtpProfileAeadAes128Gcm, key, salt)
+	dec, err := receiver.DecryptRtp(srtp)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, rtp, dec)
+
+	// rfc7714 17.1.1 RTCP
+	rtcp := mustDecodeHex("81c8000d 4d617273 4e545031 4e545032 52545020 0000042a 0000e930 4c756e61 deadbeef deadbeef deadbeef deadbeef deadbeef")
+	srtcp := mustDecodeHex("81c8000d 4d617273 63e94885 dcdab67c a727d766 2f6b7e99 7ff5c0f7 6c06f32d c676a5f1 730d6fda 4ce09b46 86303ded 0bb9275b c84aa458 96cf4d2f c5abf872 45d9eade 800005d4")
+	sender.encRtcpIndex = 0x5d4 - 1
+	enc, err = sender.EncryptRtcp(rtcp)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, srtcp, enc)
+	dec, err = receiver.DecryptRtcp(srtcp)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, rtcp, dec)
+}
+
+func TestSrtpReplay(t *testing.T) {
+	key := make([]byte, 16)
+	salt := make([]byte, 14)
+	sender, _ := NewSrtpContext(SrtpProfileAesCm128HmacSha1_80, key, salt)
+	receiver, _ := NewSrtpContext(SrtpProfileAesCm128HmacSha1_80, key, salt)
+
+	encs := make(map[uint16][]byte)
+	for _, seq := range []uint16{65400, 65530, 65535, 0, 10} {
+		h := MakeDefaultRtpHeader()
+		h.Seq = seq
+		h.Ssrc = 1
+		enc, err := sender.EncryptRtp(MakeRtpPacket(h, []byte{1, 2, 3}).Raw)
+		assert.Equal(t, nil, err)
+		encs[seq] = enc
+	}
+
+	_, err := receiver.DecryptRtp(encs[65535])
+	assert.Equal(t, nil, err)
+	// 重放
+	_, err = receiver.DecryptRtp(encs[65535])
+	assert.Equal(t, base.ErrSrtpReplayed, err)
+	// ROC增加后，窗口内的乱序包
+	_, err = receiver.DecryptRtp(encs[0])
+	assert.Equal(t, nil, err)
+	_, err = receiver.DecryptRtp(encs[65530])
+	assert.Equal(t, nil, err)
+	_, err = receiver.DecryptRtp(encs[65530])
+	assert.Equal(t, base.ErrSrtpReplayed, err)
+	_, err = receiver.DecryptRtp(encs[65535])
+	assert.Equal(t, base.ErrSrtpReplayed, err)
+	// 比窗口更旧的包
+	_, err = receiver.DecryptRtp(encs[65400])
+	assert.Equal(t, base.ErrSrtpReplayed, err)
+	// 窗口向前滑动
+	_, err = receiver.DecryptRtp(encs[10])
+	assert.Equal(t, nil, err)
+	_, err = receiver.DecryptRtp(encs[10])
+	assert.Equal(t, base.ErrSrtpReplayed, err)
+
+	// 认证失败的包不更新窗口
+	h := MakeDefaultRtpHeader()
+	h.Seq = 300
+	h.Ssrc = 1
+	enc, _ := sender.EncryptRtp(MakeRtpPacket(h, []byte{1, 2, 3}).Raw)
+	enc[len(enc)-1] ^= 1
+	_, err = receiver.DecryptRtp(enc)
+	assert.Equal(t, base.ErrSrtpAuthFailed, err)
+	enc[len(enc)-1] ^= 1
+	_, err = receiver.DecryptRtp(enc)
+	assert.Equal(t, nil, err)
+
+	// SRTCP index
+	sr := []byte{0x80, 0xc8, 0x00, 0x06, 0x11, 0x22, 0x33, 0x44,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	var srtcps [][]byte
+	for i := 0; i < srtpReplayWindowSize+2; i++ {
+		enc, err := sender.EncryptRtcp(sr)
+		assert.Equal(t, nil, err)
+		srtcps = append(srtcps, enc)
+	}
+	_, err = receiver.DecryptRtcp(srtcps[1])
+	assert.Equal(t, nil, err)
+	_, err = receiver.DecryptRtcp(srtcps[1])
+	assert.Equal(t, base.ErrSrtpReplayed, err)
+	_, err = receiver.DecryptRtcp(srtcps[0])
+	assert.Equal(t, nil, err)
+	_, err = receiver.DecryptRtcp(srtcps[len(srtcps)-1])
+	assert.Equal(t, nil, err)
+	_, err = receiver.DecryptRtcp(srtcps[0])
+	assert.Equal(t, base.ErrSrtpReplayed, err)
+}
@@ -0,0 +1,152 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtsp
+
+import (
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/lal/pkg/rtprtcp"
+)
+
+// avSyncMaxOffsetMs 计算出的音视频偏移超过该值时，认为对端的sr不可信（比如ntp时间没有同步），不做对齐
+const avSyncMaxOffsetMs = 10000
+
+// avSyncMaxPendingNum 等待sr期间最多缓存的包数量，超过后不再等待
+const avSyncMaxPendingNum = 4096
+
+// avSync
+//
+// AvPacketQueue 会让音频和视频的时间戳各自从0开始，丢失了音视频之间原有的偏移。
+// 本模块根据音频和视频各自的rtcp sr中ntp和rtp时间戳的对应关系，计算出音频和视频第一个包在ntp时间上的差值，
+// 交给 AvPacketQueue 补偿到较晚开始的一路上。
+//
+// 为了让所有包都使用相同的偏移，在音频和视频的sr都收到之前，包缓存在本模块中，不交给 AvPacketQueue 。
+// 缓存的时长超过 AvSyncWaitSrMaxMs ，或者数量超过 avSyncMaxPendingNum 时，不再等待，不做对齐。
+// 由于偏移在第一个包交给 AvPacketQueue 之前设置，`firstTs`也即 AvPacketQueue 中时间戳的基准。
+type avSync struct {
+	queue *AvPacketQueue
+
+	audio avSyncStream
+	video avSyncStream
+
+	done    bool
+	pending []base.AvPacket
+}
+
+type avSyncStream struct {
+	clockRate int
+	srFlag    bool
+	srNtpMs   int64
+	srTsMs    int64 // sr中的rtp时间戳换算成毫秒，换算方式和 rtprtcp 中生成 base.AvPacket 的时间戳保持一致
+	firstTs   int64 // 第一个 base.AvPacket 的时间戳，-1表示还没有收到
+	lastTs    int64
+}
+
+func newAvSync(audioClockRate, videoClockRate int, queue *AvPacketQueue) *avSync {
+	return &avSync{
+		queue: queue,
+		audio: avSyncStream{clockRate: audioClockRate, firstTs: -1},
+		video: avSyncStream{clockRate: videoClockRate, firstTs: -1},
+		// 时间戳精度不足毫秒时无法对齐
+		done: AvSyncWaitSrMaxMs <= 0 || audioClockRate < 1000 || videoClockRate < 1000,
+	}
+}
+
+func (s *avSync) onSr(isAudio bool, sr rtprtcp.Sr) {
+	if s.done {
+		return
+	}
+
+	stream := &s.video
+	if isAudio {
+		stream = &s.audio
+	}
+	stream.srFlag = true
+	stream.srNtpMs = int64(rtprtcp.MswLsw2UnixNano(uint64(sr.Msw), uint64(sr.Lsw)) / 1e6)
+	stream.srTsMs = int64(sr.Timestamp / uint32(stream.clockRate/1000))
+	s.tryFlush()
+}
+
+// feed 代替 AvPacketQueue.Feed 调用
+func (s *avSync) feed(pkt base.AvPacket) {
+	if s.done {
+		s.queue.Feed(pkt)
+		return
+	}
+
+	stream := &s.video
+	if pkt.IsAudio() {
+		stream = &s.audio
+	}
+	if stream.firstTs == -1 {
+		stream.firstTs = pkt.Timestamp
+	}
+	stream.lastTs = pkt.Timestamp
+	s.pending = append(s.pending, pkt)
+	s.tryFlush()
+}
+
+// tryFlush 条件满足时，设置偏移，并将缓存的包交给 AvPacketQueue
+func (s *avSync) tryFlush() {
+	if s.audio.ready() && s.video.ready() {
+		if audioOffsetMs, videoOffsetMs, ok := s.calc(); ok {
+			Log.Infof("av sync by rtcp sr. audioOffsetMs=%d, videoOffsetMs=%d", audioOffsetMs, videoOffsetMs)
+			s.queue.SetSyncOffset(audioOffsetMs, videoOffsetMs)
+		}
+	} else if len(s.pending) >= avSyncMaxPendingNum || s.audio.waitMs() >= AvSyncWaitSrMaxMs || s.video.waitMs() >= AvSyncWaitSrMaxMs {
+		Log.Warnf("wait rtcp sr timeout, no av sync. audioSr=%t, videoSr=%t, pending=%d", s.audio.srFlag, s.video.srFlag, len(s.pending))
+	} else {
+		return
+	}
+
+	s.done = true
+	for _, pkt := range s.pending {
+		s.queue.Feed(pkt)
+	}
+	s.pending = nil
+}
+
+// calc 计算音频和视频第一个包的偏移
+//
+// @return ok: 为false表示偏移不可信
+func (s *avSync) calc() (audioOffsetMs, videoOffsetMs int64, ok bool) {
+	diff := s.video.ntpMs(s.video.firstTs) - s.audio.ntpMs(s.audio.firstTs)
+	if diff > avSyncMaxOffsetMs || diff < -avSyncMaxOffsetMs {
+		Log.Warnf("av sync offset too large, ignore. diff=%d", diff)
+		return 0, 0, false
+	}
+	if diff > 0 {
+		return 0, diff, true
+	}
+	return -diff, 0, true
+}
+
+func (s *avSyncStream) ready() bool {
+	return s.srFlag && s.firstTs != -1
+}
+
+// waitMs 已经缓存的包的时长
+func (s *avSyncStream) waitMs() int64 {
+	if s.firstTs == -1 {
+		return 0
+	}
+	return s.lastTs - s.firstTs
+}
+
+// ntpMs 将 base.AvPacket 的时间戳换算成ntp时间（毫秒）
+func (s *avSyncStream) ntpMs(ts int64) int64 {
+	// rtp时间戳是32位的，换算成毫秒后在wrap处翻转
+	wrap := int64(1<<32) / int64(s.clockRate/1000)
+	diff := (ts - s.srTsMs) % wrap
+	if diff > wrap/2 {
+		diff -= wrap
+	} else if diff < -wrap/2 {
+		diff += wrap
+	}
+	return s.srNtpMs + diff
+}
@@ -0,0 +1,151 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtsp
+
+import (
+	"testing"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/lal/pkg/rtprtcp"
+	"github.com/q191201771/naza/pkg/assert"
+)
+
+func testMakeSr(unixMs int64, rtpTs uint32) rtprtcp.Sr {
+	ntp := rtprtcp.UnixNano2Ntp(uint64(unixMs) * 1e6)
+	return rtprtcp.Sr{Msw: uint32(ntp >> 32), Lsw: uint32(ntp), Timestamp: rtpTs}
+}
+
+func TestAvSync(t *testing.T) {
+	defer func(v int64) {
+		AvSyncWaitSrMaxMs = v
+	}(AvSyncWaitSrMaxMs)
+	AvSyncWaitSrMaxMs = 5000
+
+	var out []base.AvPacket
+	q := NewAvPacketQueue(func(pkt base.AvPacket) {
+		out = append(out, pkt)
+	})
+	s := newAvSync(48000, 90000, q)
+
+	// 音视频数据先到，sr后到。音频第一个包的时间戳为1000ms，对应ntp时间 10000ms+200ms
+	for i := int64(0); i < 10; i++ {
+		s.feed(base.AvPacket{PayloadType: base.AvPacketPtAac, Timestamp: 1000 + i*20})
+	}
+	// 视频第一个包的时间戳为5000ms，对应ntp时间 10000ms+300ms
+	for i := int64(0); i < 5; i++ {
+		s.feed(base.AvPacket{PayloadType: base.AvPacketPtAvc, Timestamp: 5000 + i*40})
+	}
+	s.onSr(true, testMakeSr(10000, 800*48))
+	// 只收到一路sr时，不输出
+	assert.Equal(t, 0, len(out))
+	s.onSr(false, testMakeSr(10000, 4700*90))
+
+	// 所有的包都使用相同的偏移：视频从100ms开始
+	var audioTs, videoTs []int64
+	for _, pkt := range out {
+		if pkt.IsAudio() {
+			audioTs = append(audioTs, pkt.Timestamp)
+		} else {
+			videoTs = append(videoTs, pkt.Timestamp)
+		}
+	}
+	assert.Equal(t, []int64{0, 20, 40, 60, 80, 100, 120, 140, 160, 180}, audioTs)
+	// 视频180还在 AvPacketQueue 中等待音频
+	assert.Equal(t, []int64{100, 140}, videoTs)
+
+	// 之后的包直接交给 AvPacketQueue
+	s.feed(base.AvPacket{PayloadType: base.AvPacketPtAac, Timestamp: 1200})
+	s.feed(base.AvPacket{PayloadType: base.AvPacketPtAvc, Timestamp: 5200})
+	assert.Equal(t, int64(180), out[len(out)-2].Timestamp)
+	assert.Equal(t, true, out[len(out)-2].IsVideo())
+	assert.Equal(t, int64(200), out[len(out)-1].Timestamp)
+	assert.Equal(t, true, out[len(out)-1].IsAudio())
+
+	// 之后再收到sr不影响
+	s.onSr(false, testMakeSr(20000, 4700*90))
+	assert.Equal(t, true, s.done)
+
+	// rtp时间戳翻转
+	wrap := int64(1<<32) / 90
+	stream := avSyncStream{clockRate: 90000, srFlag: true, srNtpMs: 10000, srTsMs: wrap - 100}
+	assert.Equal(t, int64(10150), stream.ntpMs(50))
+	stream.srTsMs = 50
+	assert.Equal(t, int64(9850), stream.ntpMs(wrap-100))
+}
+
+func TestAvSyncNoSync(t *testing.T) {
+	defer func(v int64) {
+		AvSyncWaitSrMaxMs = v
+	}(AvSyncWaitSrMaxMs)
+	AvSyncWaitSrMaxMs = 5000
+
+	var out []base.AvPacket
+	newQueue := func() *AvPacketQueue {
+		out = nil
+		return NewAvPacketQueue(func(pkt base.AvPacket) {
+			out = append(out, pkt)
+		})
+	}
+
+	// 偏移过大，不做对齐
+	s := newAvSync(48000, 90000, newQueue())
+	s.feed(base.AvPacket{PayloadType: base.AvPacketPtAac, Timestamp: 0})
+	s.feed(base.AvPacket{PayloadType: base.AvPacketPtAvc, Timestamp: 0})
+	s.onSr(true, testMakeSr(10000, 0))
+	s.onSr(false, testMakeSr(100000, 0))
+	assert.Equal(t, true, s.done)
+	assert.Equal(t, 1, len(out))
+	assert.Equal(t, int64(0), out[0].Timestamp)
+
+	// 等待sr超时，不做对齐
+	s = newAvSync(48000, 90000, newQueue())
+	s.onSr(true, testMakeSr(10000, 0))
+	for ts := int64(0); ts < AvSyncWaitSrMaxMs; ts += 40 {
+		s.feed(base.AvPacket{PayloadType: base.AvPacketPtAac, Timestamp: ts})
+		s.feed(base.AvPacket{PayloadType: base.AvPacketPtAvc, Timestamp: 1000 + ts})
+	}
+	assert.Equal(t, false, s.done)
+	assert.Equal(t, 0, len(out))
+	s.feed(base.AvPacket{PayloadType: base.AvPacketPtAvc, Timestamp: 1000 + AvSyncWaitSrMaxMs})
+	assert.Equal(t, true, s.done)
+	assert.Equal(t, true, len(out) > 0)
+	assert.Equal(t, int64(0), out[0].Timestamp)
+
+	// 时间戳精度不足毫秒时，不等待
+	s = newAvSync(48000, 900, newQueue())
+	assert.Equal(t, true, s.done)
+
+	// 默认不等待
+	AvSyncWaitSrMaxMs = 0
+	s = newAvSync(48000, 90000, newQueue())
+	assert.Equal(t, true, s.done)
+}
+
+func TestAvPacketQueueSyncOffset(t *testing.T) {
+	var out []base.AvPacket
+	q := NewAvPacketQueue(func(pkt base.AvPacket) {
+		out = append(out, pkt)
+	})
+	q.SetSyncOffset(0, 100)
+	q.Feed(base.AvPacket{PayloadType: base.AvPacketPtAvc, Timestamp: 5000})
+	q.Feed(base.AvPacket{PayloadType: base.AvPacketPtAac, Timestamp: 1000})
+	q.Feed(base.AvPacket{PayloadType: base.AvPacketPtAac, Timestamp: 1120})
+	assert.Equal(t, 2, len(out))
+	assert.Equal(t, base.AvPacketPtAac, out[0].PayloadType)
+	assert.Equal(t, int64(0), out[0].Timestamp)
+	assert.Equal(t, base.AvPacketPtAvc, out[1].PayloadType)
+	assert.Equal(t, int64(100), out[1].Timestamp)
+
+	// Feed之后设置的偏移被忽略
+	q.SetSyncOffset(0, 200)
+	q.Feed(base.AvPacket{PayloadType: base.AvPacketPtAvc, Timestamp: 5040})
+	q.Feed(base.AvPacket{PayloadType: base.AvPacketPtAac, Timestamp: 1200})
+	assert.Equal(t, int64(120), out[2].Timestamp)
+	assert.Equal(t, int64(140), out[3].Timestamp)
+}
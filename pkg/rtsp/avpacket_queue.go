@@ -44,6 +44,11 @@ type AvPacketQueue struct {
 	videoPrevModTs      int64
 	audioPrevIntervalTs int64
 	videoPrevIntervalTs int64
+
+	// 见 SetSyncOffset
+	audioSyncOffsetMs int64
+	videoSyncOffsetMs int64
+	fedFlag           bool
 }
 
 func NewAvPacketQueue(onAvPacket OnAvPacket) *AvPacketQueue {
@@ -64,6 +69,19 @@ func NewAvPacketQueue(onAvPacket OnAvPacket) *AvPacketQueue {
 	}
 }
 
+// SetSyncOffset 给音频或视频的时间戳增加偏移，用于按照对端的rtcp sr对齐音视频，见 avSync
+//
+// 注意，偏移只能是非负值，保证时间戳不回退。
+// 只能在第一次调用 Feed 之前设置，保证所有输出的时间戳使用相同的偏移，之后的调用会被忽略
+func (a *AvPacketQueue) SetSyncOffset(audioOffsetMs, videoOffsetMs int64) {
+	if a.fedFlag {
+		Log.Warnf("[AVQ] set sync offset after feed, ignore. audioOffsetMs=%d, videoOffsetMs=%d", audioOffsetMs, videoOffsetMs)
+		return
+	}
+	a.audioSyncOffsetMs = audioOffsetMs
+	a.videoSyncOffsetMs = videoOffsetMs
+}
+
 func (a *AvPacketQueue) adjustTsHandleRotate(pkt *base.AvPacket) {
 	// TODO(chef): [refactor] adjustTsXxx 这一层换成fn这一层 202305
 	fn := func(prevOriginTs, prevModTs, prevIntervalTs *int64, isAudio bool) {
@@ -112,10 +130,12 @@ func (a *AvPacketQueue) adjustTsHandleRotate(pkt *base.AvPacket) {
 	if pkt.IsVideo() {
 		fn(&a.videoPrevOriginTs, &a.videoPrevModTs, &a.videoPrevIntervalTs, false)
 
+		pkt.Timestamp += a.videoSyncOffsetMs
 		_ = a.videoQueue.PushBack(*pkt)
 	} else {
 		fn(&a.audioPrevOriginTs, &a.audioPrevModTs, &a.audioPrevIntervalTs, true)
 
+		pkt.Timestamp += a.audioSyncOffsetMs
 		_ = a.audioQueue.PushBack(*pkt)
 	}
 }
@@ -136,6 +156,7 @@ func (a *AvPacketQueue) adjustTs(pkt *base.AvPacket) {
 
 		// 根据基准调节
 		pkt.Timestamp -= a.videoBaseTs
+		pkt.Timestamp += a.videoSyncOffsetMs
 
 		_ = a.videoQueue.PushBack(*pkt)
 	} else {
@@ -148,12 +169,15 @@ func (a *AvPacketQueue) adjustTs(pkt *base.AvPacket) {
 			a.audioBaseTs = pkt.Timestamp
 		}
 		pkt.Timestamp -= a.audioBaseTs
+		pkt.Timestamp += a.audioSyncOffsetMs
 		_ = a.audioQueue.PushBack(*pkt)
 	}
 }
 
 // Feed 注意，调用方保证，音频相较于音频，视频相较于视频，时间戳是线性递增的。
 func (a *AvPacketQueue) Feed(pkt base.AvPacket) {
+	a.fedFlag = true
+
 	//Log.Debugf("[AVQ] Feed. t=%d, ts=%d, Q(%d,%d), %s, base(%d,%d)", pkt.PayloadType, pkt.Timestamp, a.audioQueue.Size(), a.videoQueue.Size(), packetsReadable(peekQueuePackets(a)), a.audioBaseTs, a.videoBaseTs)
 
 	if TimestampFilterHandleRotateFlag {
@@ -13,6 +13,7 @@ import (
 	"net"
 	"sync"
 
+	"github.com/q191201771/naza/pkg/bele"
 	"github.com/q191201771/naza/pkg/nazaatomic"
 
 	"github.com/q191201771/naza/pkg/nazabytes"
@@ -59,14 +60,35 @@ type BaseInSession struct {
 	videoRtpChannel  int
 	videoRtcpChannel int
 
+	symmetricRtpFlag bool   // UDP模式下是否丢弃非第一个包源地址的包，见 udpLatch
+	symmetricRtpIp   net.IP // 只锁定该ip的源地址，为nil时不限制
+
+	// SETUP为RTP/SAVP时不为nil，见 SetupSrtp
+	audioSrtp *rtprtcp.SrtpContext
+	videoSrtp *rtprtcp.SrtpContext
+
 	sessionStat base.BasicSessionStat
 
 	mu              sync.Mutex
 	sdpCtx          sdp.LogicContext // const after set
 	avPacketQueue   *AvPacketQueue
+	avSync          *avSync // 和 avPacketQueue 同时存在
 	audioRrProducer *rtprtcp.RrProducer
 	videoRrProducer *rtprtcp.RrProducer
 
+	// 只有UDP模式使用
+	audioNackProducer *rtprtcp.NackProducer
+	videoNackProducer *rtprtcp.NackProducer
+
+	// sdp中有ulpfec时不为nil，见 fec.go
+	audioFecDecoder *rtprtcp.FecDecoder
+	videoFecDecoder *rtprtcp.FecDecoder
+
+	// sdp中有`a=extmap`时不为nil，用于解析rtp header extension
+	audioExtRegistry *rtprtcp.RtpExtensionRegistry
+	videoExtRegistry *rtprtcp.RtpExtensionRegistry
+	videoOrientation rtprtcp.VideoOrientation
+
 	audioUnpacker rtprtcp.IRtpUnpacker
 	videoUnpacker rtprtcp.IRtpUnpacker
 
@@ -107,7 +129,12 @@ func (session *BaseInSession) InitWithSdp(sdpCtx sdp.LogicContext) {
 	session.mu.Unlock()
 
 	if session.sdpCtx.IsAudioUnpackable() {
-		session.audioUnpacker = rtprtcp.DefaultRtpUnpackerFactory(session.sdpCtx.GetAudioPayloadTypeBase(), session.sdpCtx.AudioClockRate, unpackerItemMaxSize, session.onAvPacketUnpacked)
+		session.audioUnpacker = rtprtcp.DefaultRtpUnpackerFactory(session.sdpCtx.GetAudioPayloadTypeBase(), session.sdpCtx.AudioClockRate, unpackerItemMaxSize, session.onAvPacketUnpacked, func(option *rtprtcp.RtpUnpackerAacOption) {
+			option.Latm = session.sdpCtx.AacLatm
+			option.SizeLength = session.sdpCtx.AacSizeLength
+			option.IndexLength = session.sdpCtx.AacIndexLength
+			option.IndexDeltaLength = session.sdpCtx.AacIndexDeltaLength
+		})
 	} else {
 		Log.Warnf("[%s] audio unpacker not support for this type yet. logicCtx=%+v", session.UniqueKey(), session.sdpCtx)
 	}
@@ -119,11 +146,28 @@ func (session *BaseInSession) InitWithSdp(sdpCtx sdp.LogicContext) {
 
 	session.audioRrProducer = rtprtcp.NewRrProducer(session.sdpCtx.AudioClockRate)
 	session.videoRrProducer = rtprtcp.NewRrProducer(session.sdpCtx.VideoClockRate)
+	if BaseInSessionNackFlag {
+		session.audioNackProducer = rtprtcp.NewNackProducer()
+		session.videoNackProducer = rtprtcp.NewNackProducer()
+	}
+	if session.sdpCtx.AudioFecPayloadType != 0 {
+		session.audioFecDecoder = rtprtcp.NewFecDecoder()
+	}
+	if session.sdpCtx.VideoFecPayloadType != 0 {
+		session.videoFecDecoder = rtprtcp.NewFecDecoder()
+	}
+	if session.sdpCtx.AudioExtMap != nil {
+		session.audioExtRegistry = rtprtcp.NewRtpExtensionRegistry(session.sdpCtx.AudioExtMap)
+	}
+	if session.sdpCtx.VideoExtMap != nil {
+		session.videoExtRegistry = rtprtcp.NewRtpExtensionRegistry(session.sdpCtx.VideoExtMap)
+	}
 
 	if session.sdpCtx.IsAudioUnpackable() && session.sdpCtx.IsVideoUnpackable() {
 		session.mu.Lock()
 		if BaseInSessionTimestampFilterFlag {
 			session.avPacketQueue = NewAvPacketQueue(session.onAvPacket)
+			session.avSync = newAvSync(session.sdpCtx.AudioClockRate, session.sdpCtx.VideoClockRate, session.avPacketQueue)
 		}
 		session.mu.Unlock()
 	}
@@ -154,12 +198,35 @@ func (session *BaseInSession) SetupWithConn(uri string, rtpConn, rtcpConn *nazan
 		return nazaerrors.Wrap(base.ErrRtsp)
 	}
 
-	go rtpConn.RunLoop(session.onReadRtpPacket)
-	go rtcpConn.RunLoop(session.onReadRtcpPacket)
+	if session.symmetricRtpFlag {
+		go rtpConn.RunLoop(newUdpLatch(session.UniqueKey(), session.symmetricRtpIp).wrap(session.onReadRtpPacket))
+		go rtcpConn.RunLoop(newUdpLatch(session.UniqueKey(), session.symmetricRtpIp).wrap(session.onReadRtcpPacket))
+	} else {
+		go rtpConn.RunLoop(session.onReadRtpPacket)
+		go rtcpConn.RunLoop(session.onReadRtcpPacket)
+	}
 
 	return nil
 }
 
+// SetupSrtp 使用sdp中uri对应的a=crypto作为密钥，调用方保证在SetupWithConn或SetupWithChannel之前调用
+func (session *BaseInSession) SetupSrtp(uri string) error {
+	crypto, ok := srtpCryptoOfUri(session.sdpCtx, uri)
+	if !ok {
+		return nazaerrors.Wrap(base.ErrRtspUnsupportedTransport, "srtp crypto not exist")
+	}
+	c, err := newSrtpContextWithCrypto(crypto)
+	if err != nil {
+		return err
+	}
+	if session.sdpCtx.IsAudioUri(uri) {
+		session.audioSrtp = c
+	} else {
+		session.videoSrtp = c
+	}
+	return nil
+}
+
 func (session *BaseInSession) SetupWithChannel(uri string, rtpChannel, rtcpChannel int) error {
 	if session.sdpCtx.IsAudioUri(uri) {
 		session.audioRtpChannel = rtpChannel
@@ -197,6 +264,12 @@ func (session *BaseInSession) GetSdp() sdp.LogicContext {
 	return session.sdpCtx
 }
 
+// GetExtensionRegistry sdp中没有对应媒体的`a=extmap`时为nil，
+// 业务方可以在 IBaseInSessionObserver.OnRtpPacket 中用来获取rtp包中的extension
+func (session *BaseInSession) GetExtensionRegistry() (audio, video *rtprtcp.RtpExtensionRegistry) {
+	return session.audioExtRegistry, session.videoExtRegistry
+}
+
 func (session *BaseInSession) HandleInterleavedPacket(b []byte, channel int) {
 	switch channel {
 	case session.audioRtpChannel:
@@ -231,7 +304,22 @@ func (session *BaseInSession) WriteRtpRtcpDummy() {
 // ----- ISessionStat --------------------------------------------------------------------------------------------------
 
 func (session *BaseInSession) GetStat() base.StatSession {
-	return session.sessionStat.GetStat()
+	stat := session.sessionStat.GetStat()
+	session.mu.Lock()
+	if session.audioNackProducer != nil {
+		stat.NackRequestedPackets += session.audioNackProducer.RequestedCount()
+	}
+	if session.videoNackProducer != nil {
+		stat.NackRequestedPackets += session.videoNackProducer.RequestedCount()
+	}
+	if session.audioFecDecoder != nil {
+		stat.FecRecoveredPackets += session.audioFecDecoder.RecoveredCount()
+	}
+	if session.videoFecDecoder != nil {
+		stat.FecRecoveredPackets += session.videoFecDecoder.RecoveredCount()
+	}
+	session.mu.Unlock()
+	return stat
 }
 
 func (session *BaseInSession) UpdateStat(intervalSec uint32) {
@@ -254,7 +342,7 @@ func (session *BaseInSession) onAvPacketUnpacked(pkt base.AvPacket) {
 	defer session.mu.Unlock()
 
 	if session.avPacketQueue != nil {
-		session.avPacketQueue.Feed(pkt)
+		session.avSync.feed(pkt)
 	} else {
 		session.observer.OnAvPacket(pkt)
 	}
@@ -299,6 +387,12 @@ func (session *BaseInSession) handleRtcpPacket(b []byte, rAddr *net.UDPAddr) err
 		return nazaerrors.Wrap(base.ErrRtsp)
 	}
 
+	b, err := srtpDecryptRtcp(b, session.audioSrtp, session.videoSrtp)
+	if err != nil {
+		Log.Warnf("[%s] decrypt srtcp failed. err=%+v", session.UniqueKey(), err)
+		return err
+	}
+
 	packetType := b[1]
 
 	switch packetType {
@@ -312,6 +406,12 @@ func (session *BaseInSession) handleRtcpPacket(b []byte, rAddr *net.UDPAddr) err
 		case session.audioSsrc.Load():
 			session.mu.Lock()
 			rrBuf = session.audioRrProducer.Produce(sr.GetMiddleNtp())
+			if rrBuf != nil {
+				rrBuf, _ = srtpEncryptRtcp(session.audioSrtp, rrBuf)
+			}
+			if session.avSync != nil {
+				session.avSync.onSr(true, sr)
+			}
 			session.mu.Unlock()
 			if rrBuf != nil {
 				if rAddr != nil {
@@ -324,6 +424,12 @@ func (session *BaseInSession) handleRtcpPacket(b []byte, rAddr *net.UDPAddr) err
 		case session.videoSsrc.Load():
 			session.mu.Lock()
 			rrBuf = session.videoRrProducer.Produce(sr.GetMiddleNtp())
+			if rrBuf != nil {
+				rrBuf, _ = srtpEncryptRtcp(session.videoSrtp, rrBuf)
+			}
+			if session.avSync != nil {
+				session.avSync.onSr(false, sr)
+			}
 			session.mu.Unlock()
 			if rrBuf != nil {
 				if rAddr != nil {
@@ -358,11 +464,31 @@ func (session *BaseInSession) handleRtpPacket(b []byte) error {
 	}
 
 	packetType := int(b[1] & 0x7F)
+	if session.isFecPayloadType(packetType) {
+		return session.handleFecPacket(b, packetType)
+	}
 	if !session.sdpCtx.IsPayloadTypeOrigin(packetType) {
 		//Log.Errorf("[%s] handleRtpPacket but type invalid. type=%d", session.UniqueKey(), packetType)
 		return nazaerrors.Wrap(base.ErrRtsp)
 	}
 
+	srtp := session.videoSrtp
+	if session.sdpCtx.IsAudioPayloadTypeOrigin(packetType) {
+		srtp = session.audioSrtp
+	}
+	if srtp != nil {
+		var err error
+		if b, err = srtp.DecryptRtp(b); err != nil {
+			Log.Warnf("[%s] decrypt srtp failed. err=%+v", session.UniqueKey(), err)
+			return err
+		}
+	}
+
+	return session.handlePlainRtpPacket(b, packetType)
+}
+
+// handlePlainRtpPacket 处理解密后的，或者通过fec恢复的rtp包
+func (session *BaseInSession) handlePlainRtpPacket(b []byte, packetType int) error {
 	h, err := rtprtcp.ParseRtpHeader(b)
 	if err != nil {
 		Log.Errorf("[%s] handleRtpPacket invalid rtp packet. err=%+v", session.UniqueKey(), err)
@@ -373,6 +499,18 @@ func (session *BaseInSession) handleRtpPacket(b []byte) error {
 	pkt.Header = h
 	pkt.Raw = b
 
+	fecDecoder := session.videoFecDecoder
+	if session.sdpCtx.IsAudioPayloadTypeOrigin(packetType) {
+		fecDecoder = session.audioFecDecoder
+	}
+	var recovered [][]byte
+	if fecDecoder != nil {
+		session.mu.Lock()
+		recovered = fecDecoder.FeedRtpPacket(b)
+		session.mu.Unlock()
+	}
+	defer session.handleRecoveredRtpPackets(recovered)
+
 	// 接收数据时，保证了sdp的原始类型对应
 	if session.sdpCtx.IsAudioPayloadTypeOrigin(packetType) {
 		if session.dumpReadAudioRtp.ShouldDump() {
@@ -385,6 +523,7 @@ func (session *BaseInSession) handleRtpPacket(b []byte) error {
 		session.mu.Lock()
 		session.audioRrProducer.FeedRtpPacket(h.Seq)
 		session.mu.Unlock()
+		session.writeNack(session.audioNackProducer, session.audioSrtp, session.audioRtcpConn, h)
 
 		if session.audioUnpacker != nil {
 			session.audioUnpacker.Feed(pkt)
@@ -399,7 +538,9 @@ func (session *BaseInSession) handleRtpPacket(b []byte) error {
 		session.observer.OnRtpPacket(pkt)
 		session.mu.Lock()
 		session.videoRrProducer.FeedRtpPacket(h.Seq)
+		session.checkVideoOrientation(&h)
 		session.mu.Unlock()
+		session.writeNack(session.videoNackProducer, session.videoSrtp, session.videoRtcpConn, h)
 
 		if session.videoUnpacker != nil {
 			session.videoUnpacker.Feed(pkt)
@@ -411,6 +552,84 @@ func (session *BaseInSession) handleRtpPacket(b []byte) error {
 	return nil
 }
 
+// checkVideoOrientation 视频方向发生变化时打印日志
+func (session *BaseInSession) checkVideoOrientation(h *rtprtcp.RtpHeader) {
+	if session.videoExtRegistry == nil {
+		return
+	}
+	vo, ok := session.videoExtRegistry.VideoOrientation(h)
+	if !ok || vo == session.videoOrientation {
+		return
+	}
+	Log.Infof("[%s] video orientation changed. %+v -> %+v", session.UniqueKey(), session.videoOrientation, vo)
+	session.videoOrientation = vo
+}
+
+func (session *BaseInSession) isFecPayloadType(packetType int) bool {
+	return (session.audioFecDecoder != nil && packetType == session.sdpCtx.AudioFecPayloadType) ||
+		(session.videoFecDecoder != nil && packetType == session.sdpCtx.VideoFecPayloadType)
+}
+
+func (session *BaseInSession) handleFecPacket(b []byte, packetType int) error {
+	pkt, err := rtprtcp.ParseRtpPacket(b)
+	if err != nil {
+		return err
+	}
+
+	// 音频和视频的fec使用相同的payload type时，通过ssrc区分
+	decoder, srtp := session.videoFecDecoder, session.videoSrtp
+	if packetType == session.sdpCtx.AudioFecPayloadType &&
+		(packetType != session.sdpCtx.VideoFecPayloadType || pkt.Header.Ssrc == session.audioSsrc.Load()) {
+		decoder, srtp = session.audioFecDecoder, session.audioSrtp
+	}
+	if decoder == nil || srtp != nil {
+		return nil
+	}
+
+	session.mu.Lock()
+	recovered, err := decoder.FeedFecPacket(pkt)
+	session.mu.Unlock()
+	if err != nil {
+		Log.Warnf("[%s] invalid fec packet. err=%+v", session.UniqueKey(), err)
+		return err
+	}
+	session.handleRecoveredRtpPackets(recovered)
+	return nil
+}
+
+func (session *BaseInSession) handleRecoveredRtpPackets(recovered [][]byte) {
+	for _, b := range recovered {
+		packetType := int(b[1] & 0x7F)
+		if !session.sdpCtx.IsPayloadTypeOrigin(packetType) {
+			continue
+		}
+		Log.Debugf("[%s] recover rtp packet by fec. seq=%d", session.UniqueKey(), bele.BeUint16(b[2:]))
+		_ = session.handlePlainRtpPacket(b, packetType)
+	}
+}
+
+// writeNack UDP模式下检测丢包，并向对端发送nack请求重传
+func (session *BaseInSession) writeNack(producer *rtprtcp.NackProducer, srtp *rtprtcp.SrtpContext, rtcpConn *nazanet.UdpConnection, h rtprtcp.RtpHeader) {
+	if producer == nil || rtcpConn == nil {
+		return
+	}
+
+	session.mu.Lock()
+	producer.FeedRtpPacket(h.Ssrc, h.Seq)
+	nackBuf := producer.Produce()
+	session.mu.Unlock()
+
+	if nackBuf != nil {
+		var err error
+		if nackBuf, err = srtpEncryptRtcp(srtp, nackBuf); err != nil {
+			return
+		}
+		if err = rtcpConn.Write(nackBuf); err == nil {
+			session.sessionStat.AddWriteBytes(len(nackBuf))
+		}
+	}
+}
+
 func (session *BaseInSession) dispose(err error) error {
 	var retErr error
 	session.disposeOnce.Do(func() {
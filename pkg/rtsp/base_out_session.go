@@ -39,6 +39,32 @@ type BaseOutSession struct {
 	videoRtpChannel  int
 	videoRtcpChannel int
 
+	// UDP模式下往对端第一个包的源地址发送，见 udpLatch
+	symmetricRtpFlag bool
+	symmetricRtpIp   net.IP // 只锁定该ip的源地址，为nil时不限制
+	audioRtpLatch    *udpLatch
+	videoRtpLatch    *udpLatch
+
+	// UDP模式下缓存最近发送的rtp包，收到对端的nack时重传
+	audioRtpHistory        *rtprtcp.RtpPacketHistory
+	videoRtpHistory        *rtprtcp.RtpPacketHistory
+	nackRequestedCount     nazaatomic.Uint64
+	nackRetransmittedCount nazaatomic.Uint64
+
+	// UDP模式下，sdp中有ulpfec且没有使用SRTP时，发送fec包，见 fec.go
+	fecGroupSize    int
+	audioFecSsrc    uint32 // fec流使用单独的ssrc，在sdp中声明
+	videoFecSsrc    uint32
+	audioFecEncoder *rtprtcp.FecEncoder
+	videoFecEncoder *rtprtcp.FecEncoder
+
+	audioSrProducer *rtprtcp.SrProducer
+	videoSrProducer *rtprtcp.SrProducer
+
+	// SETUP为RTP/SAVP时不为nil，见 SetupSrtp
+	audioSrtp *rtprtcp.SrtpContext
+	videoSrtp *rtprtcp.SrtpContext
+
 	sessionStat base.BasicSessionStat
 
 	// only for debug log
@@ -60,6 +86,8 @@ func NewBaseOutSession(sessionType base.SessionType, cmdSession IInterleavedPack
 		videoRtpChannel:  -1,
 		debugLogMaxCount: 3,
 		waitChan:         make(chan error, 1),
+		audioFecSsrc:     newFecSsrc(),
+		videoFecSsrc:     newFecSsrc(),
 	}
 	Log.Infof("[%s] lifecycle new rtsp BaseOutSession. session=%p", s.UniqueKey(), s)
 	return s
@@ -67,25 +95,60 @@ func NewBaseOutSession(sessionType base.SessionType, cmdSession IInterleavedPack
 
 func (session *BaseOutSession) InitWithSdp(sdpCtx sdp.LogicContext) {
 	session.sdpCtx = sdpCtx
+	session.audioSrProducer = rtprtcp.NewSrProducer(sdpCtx.AudioClockRate)
+	session.videoSrProducer = rtprtcp.NewSrProducer(sdpCtx.VideoClockRate)
 }
 
 func (session *BaseOutSession) SetupWithConn(uri string, rtpConn, rtcpConn *nazanet.UdpConnection) error {
+	var latch *udpLatch
+	if session.symmetricRtpFlag {
+		latch = newUdpLatch(session.UniqueKey(), session.symmetricRtpIp)
+	}
+
 	if session.sdpCtx.IsAudioUri(uri) {
 		session.audioRtpConn = rtpConn
 		session.audioRtcpConn = rtcpConn
+		session.audioRtpLatch = latch
+		session.audioRtpHistory = rtprtcp.NewRtpPacketHistory(rtpPacketHistorySize)
+		session.audioFecEncoder = session.newFecEncoder(session.sdpCtx.AudioFecPayloadType, session.audioFecSsrc, session.audioSrtp)
 	} else if session.sdpCtx.IsVideoUri(uri) {
 		session.videoRtpConn = rtpConn
 		session.videoRtcpConn = rtcpConn
+		session.videoRtpLatch = latch
+		session.videoRtpHistory = rtprtcp.NewRtpPacketHistory(rtpPacketHistorySize)
+		session.videoFecEncoder = session.newFecEncoder(session.sdpCtx.VideoFecPayloadType, session.videoFecSsrc, session.videoSrtp)
 	} else {
 		return nazaerrors.Wrap(base.ErrRtsp)
 	}
 
-	go rtpConn.RunLoop(session.onReadRtpPacket)
+	if latch != nil {
+		go rtpConn.RunLoop(latch.wrap(session.onReadRtpPacket))
+	} else {
+		go rtpConn.RunLoop(session.onReadRtpPacket)
+	}
 	go rtcpConn.RunLoop(session.onReadRtcpPacket)
 
 	return nil
 }
 
+// SetupSrtp 使用sdp中uri对应的a=crypto作为密钥，调用方保证在SetupWithConn或SetupWithChannel之前调用
+func (session *BaseOutSession) SetupSrtp(uri string) error {
+	crypto, ok := srtpCryptoOfUri(session.sdpCtx, uri)
+	if !ok {
+		return nazaerrors.Wrap(base.ErrRtspUnsupportedTransport, "srtp crypto not exist")
+	}
+	c, err := newSrtpContextWithCrypto(crypto)
+	if err != nil {
+		return err
+	}
+	if session.sdpCtx.IsAudioUri(uri) {
+		session.audioSrtp = c
+	} else {
+		session.videoSrtp = c
+	}
+	return nil
+}
+
 func (session *BaseOutSession) SetupWithChannel(uri string, rtpChannel, rtcpChannel int) error {
 	if session.sdpCtx.IsAudioUri(uri) {
 		session.audioRtpChannel = rtpChannel
@@ -144,11 +207,22 @@ func (session *BaseOutSession) WriteRtpPacket(packet rtprtcp.RtpPacket) error {
 			session.loggedWriteAudioRtpCount++
 		}
 
+		raw, encErr := srtpEncryptRtp(session.audioSrtp, packet.Raw)
+		if encErr != nil {
+			return encErr
+		}
 		if session.audioRtpConn != nil {
-			err = session.audioRtpConn.Write(packet.Raw)
+			session.audioRtpHistory.Put(packet)
+			err = session.audioRtpLatch.write(session.audioRtpConn, raw)
+			if err == nil {
+				session.writeFec(session.audioFecEncoder, packet, session.audioRtpLatch, session.audioRtpConn)
+			}
 		}
 		if session.audioRtpChannel != -1 {
-			err = session.cmdSession.WriteInterleavedPacket(packet.Raw, session.audioRtpChannel)
+			err = session.cmdSession.WriteInterleavedPacket(raw, session.audioRtpChannel)
+		}
+		if err == nil {
+			session.writeSr(packet, session.audioSrProducer, session.audioSrtp, session.audioRtcpConn, session.audioRtpChannel, session.audioRtcpChannel)
 		}
 	} else if session.sdpCtx.IsVideoPayloadTypeOrigin(t) {
 		if session.loggedWriteVideoRtpCount < session.debugLogMaxCount {
@@ -156,11 +230,22 @@ func (session *BaseOutSession) WriteRtpPacket(packet rtprtcp.RtpPacket) error {
 			session.loggedWriteVideoRtpCount++
 		}
 
+		raw, encErr := srtpEncryptRtp(session.videoSrtp, packet.Raw)
+		if encErr != nil {
+			return encErr
+		}
 		if session.videoRtpConn != nil {
-			err = session.videoRtpConn.Write(packet.Raw)
+			session.videoRtpHistory.Put(packet)
+			err = session.videoRtpLatch.write(session.videoRtpConn, raw)
+			if err == nil {
+				session.writeFec(session.videoFecEncoder, packet, session.videoRtpLatch, session.videoRtpConn)
+			}
 		}
 		if session.videoRtpChannel != -1 {
-			err = session.cmdSession.WriteInterleavedPacket(packet.Raw, session.videoRtpChannel)
+			err = session.cmdSession.WriteInterleavedPacket(raw, session.videoRtpChannel)
+		}
+		if err == nil {
+			session.writeSr(packet, session.videoSrProducer, session.videoSrtp, session.videoRtcpConn, session.videoRtpChannel, session.videoRtcpChannel)
 		}
 	} else {
 		Log.Errorf("[%s] write rtp packet but type invalid. type=%d", session.UniqueKey(), t)
@@ -176,7 +261,10 @@ func (session *BaseOutSession) WriteRtpPacket(packet rtprtcp.RtpPacket) error {
 // ----- ISessionStat --------------------------------------------------------------------------------------------------
 
 func (session *BaseOutSession) GetStat() base.StatSession {
-	return session.sessionStat.GetStat()
+	stat := session.sessionStat.GetStat()
+	stat.NackRequestedPackets = session.nackRequestedCount.Load()
+	stat.NackRetransmittedPackets = session.nackRetransmittedCount.Load()
+	return stat
 }
 
 func (session *BaseOutSession) UpdateStat(intervalSec uint32) {
@@ -194,7 +282,8 @@ func (session *BaseOutSession) UniqueKey() string {
 }
 
 func (session *BaseOutSession) onReadRtpPacket(b []byte, rAddr *net.UDPAddr, err error) bool {
-	// TODO(chef): [fix] 在收到rtp和rtcp的地方，加入stat统计 202205
+	// 对端发送的打洞包或rtcp，用于 ServerCommandSession 的UDP保活检查
+	session.sessionStat.AddReadBytes(len(b))
 
 	if session.loggedReadRtpCount.Load() < int32(session.debugLogMaxCount) {
 		Log.Debugf("[%s] LOGPACKET. read rtp=%s", session.UniqueKey(), hex.Dump(nazabytes.Prefix(b, 32)))
@@ -204,15 +293,107 @@ func (session *BaseOutSession) onReadRtpPacket(b []byte, rAddr *net.UDPAddr, err
 }
 
 func (session *BaseOutSession) onReadRtcpPacket(b []byte, rAddr *net.UDPAddr, err error) bool {
-	// TODO chef: impl me
+	session.sessionStat.AddReadBytes(len(b))
 
 	if session.loggedReadRtcpCount.Load() < int32(session.debugLogMaxCount) {
 		Log.Debugf("[%s] LOGPACKET. read rtcp=%s", session.UniqueKey(), hex.Dump(nazabytes.Prefix(b, 32)))
 		session.loggedReadRtcpCount.Increment()
 	}
+
+	b, err = srtpDecryptRtcp(b, session.audioSrtp, session.videoSrtp)
+	if err != nil {
+		Log.Warnf("[%s] decrypt srtcp failed. err=%+v", session.UniqueKey(), err)
+		return true
+	}
+
+	// TODO chef: 处理rr
+	for _, item := range rtprtcp.SplitRtcpPackets(b) {
+		h := rtprtcp.ParseRtcpHeader(item)
+		if h.PacketType == rtprtcp.RtcpPacketTypeRtpfb && h.CountOrFormat == rtprtcp.RtcpFmtGenericNack {
+			session.handleNack(item)
+		}
+	}
 	return true
 }
 
+// writeSr 定时向对端发送rtcp sr，UDP模式使用rtcp连接，TCP模式使用interleaved的rtcp channel
+func (session *BaseOutSession) writeSr(packet rtprtcp.RtpPacket, producer *rtprtcp.SrProducer, srtp *rtprtcp.SrtpContext, rtcpConn *nazanet.UdpConnection, rtpChannel, rtcpChannel int) {
+	if producer == nil {
+		return
+	}
+	producer.FeedRtpPacket(packet)
+	srBuf := producer.Produce(rtcpSrIntervalMs)
+	if srBuf == nil {
+		return
+	}
+	srBuf, err := srtpEncryptRtcp(srtp, srBuf)
+	if err != nil {
+		return
+	}
+
+	if rtcpConn != nil {
+		err = rtcpConn.Write(srBuf)
+	}
+	if rtpChannel != -1 {
+		err = session.cmdSession.WriteInterleavedPacket(srBuf, rtcpChannel)
+	}
+	if err == nil {
+		session.sessionStat.AddWriteBytes(len(srBuf))
+	}
+}
+
+func (session *BaseOutSession) newFecEncoder(payloadType int, ssrc uint32, srtp *rtprtcp.SrtpContext) *rtprtcp.FecEncoder {
+	if session.fecGroupSize == 0 || payloadType == 0 || srtp != nil {
+		return nil
+	}
+	return rtprtcp.NewFecEncoder(uint8(payloadType), ssrc, session.fecGroupSize)
+}
+
+// writeFec 每凑够一组媒体包，发送一个fec包
+func (session *BaseOutSession) writeFec(encoder *rtprtcp.FecEncoder, packet rtprtcp.RtpPacket, latch *udpLatch, rtpConn *nazanet.UdpConnection) {
+	if encoder == nil {
+		return
+	}
+	fecPkt, ok := encoder.FeedRtpPacket(packet)
+	if !ok {
+		return
+	}
+	if err := latch.write(rtpConn, fecPkt.Raw); err == nil {
+		session.sessionStat.AddWriteBytes(len(fecPkt.Raw))
+	}
+}
+
+func (session *BaseOutSession) handleNack(b []byte) {
+	nack, err := rtprtcp.ParseNack(b)
+	if err != nil {
+		Log.Warnf("[%s] parse nack failed. err=%+v", session.UniqueKey(), err)
+		return
+	}
+
+	session.nackRequestedCount.Add(uint64(len(nack.Seqs)))
+	for _, seq := range nack.Seqs {
+		var raw []byte
+		var err error
+		// 只重传UDP模式的包，TCP interleaved模式本身是可靠传输，不需要重传，此时rtpConn也为nil
+		pkt, ok := session.audioRtpHistory.Get(nack.MediaSsrc, seq)
+		if ok && session.audioRtpConn != nil {
+			if raw, err = srtpEncryptRtp(session.audioSrtp, pkt.Raw); err == nil {
+				err = session.audioRtpLatch.write(session.audioRtpConn, raw)
+			}
+		} else if pkt, ok = session.videoRtpHistory.Get(nack.MediaSsrc, seq); ok && session.videoRtpConn != nil {
+			if raw, err = srtpEncryptRtp(session.videoSrtp, pkt.Raw); err == nil {
+				err = session.videoRtpLatch.write(session.videoRtpConn, raw)
+			}
+		} else {
+			continue
+		}
+		if err == nil {
+			session.nackRetransmittedCount.Increment()
+			session.sessionStat.AddWriteBytes(len(pkt.Raw))
+		}
+	}
+}
+
 func (session *BaseOutSession) dispose(err error) error {
 	var retErr error
 	session.disposeOnce.Do(func() {
@@ -0,0 +1,109 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtsp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/lal/pkg/rtprtcp"
+	"github.com/q191201771/lal/pkg/sdp"
+	"github.com/q191201771/naza/pkg/assert"
+	"github.com/q191201771/naza/pkg/nazanet"
+)
+
+// TestBaseOutSessionNack 模拟UDP拉流端丢包后发送nack，BaseOutSession 从缓存中重传
+func TestBaseOutSessionNack(t *testing.T) {
+	sdpCtx, err := sdp.ParseSdp2LogicContext([]byte(testSdp))
+	assert.Equal(t, nil, err)
+
+	// 对端
+	peerRtp, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.Equal(t, nil, err)
+	defer peerRtp.Close()
+	peerRtcp, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.Equal(t, nil, err)
+	defer peerRtcp.Close()
+
+	rtpConn, err := nazanet.NewUdpConnection(func(option *nazanet.UdpConnectionOption) {
+		option.LAddr = "127.0.0.1:0"
+		option.RAddr = peerRtp.LocalAddr().String()
+	})
+	assert.Equal(t, nil, err)
+	rtcpc, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.Equal(t, nil, err)
+	rtcpConn, err := nazanet.NewUdpConnection(func(option *nazanet.UdpConnectionOption) {
+		option.Conn = rtcpc
+		option.RAddr = peerRtcp.LocalAddr().String()
+	})
+	assert.Equal(t, nil, err)
+
+	session := NewBaseOutSession(base.SessionTypeRtspSub, nil)
+	defer session.Dispose()
+	session.InitWithSdp(sdpCtx)
+	assert.Equal(t, nil, session.SetupWithConn("rtsp://127.0.0.1/live/test/streamid=0", rtpConn, rtcpConn))
+
+	h := rtprtcp.MakeDefaultRtpHeader()
+	h.PacketType = 96
+	h.Ssrc = 1000
+	for seq := uint16(10); seq < 15; seq++ {
+		h.Seq = seq
+		assert.Equal(t, nil, session.WriteRtpPacket(rtprtcp.MakeRtpPacket(h, []byte{byte(seq)})))
+	}
+	buf := make([]byte, 1500)
+	for i := 0; i < 5; i++ {
+		_, err = peerRtp.Read(buf)
+		assert.Equal(t, nil, err)
+	}
+
+	// 请求重传11、13，以及一个已经不在缓存中的序号
+	nack := rtprtcp.Nack{MediaSsrc: 1000, Seqs: []uint16{11, 13, 2000}}
+	_, err = peerRtcp.WriteTo(nack.Pack(), rtcpc.LocalAddr())
+	assert.Equal(t, nil, err)
+
+	_ = peerRtp.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for _, expected := range []uint16{11, 13} {
+		n, err := peerRtp.Read(buf)
+		assert.Equal(t, nil, err)
+		pkt, err := rtprtcp.ParseRtpPacket(buf[:n])
+		assert.Equal(t, nil, err)
+		assert.Equal(t, expected, pkt.Header.Seq)
+	}
+
+	stat := session.GetStat()
+	assert.Equal(t, uint64(3), stat.NackRequestedPackets)
+	assert.Equal(t, uint64(2), stat.NackRetransmittedPackets)
+}
+
+// TestBaseOutSessionNackInterleaved TCP interleaved模式下没有UDP连接，收到nack时不重传
+func TestBaseOutSessionNackInterleaved(t *testing.T) {
+	sdpCtx, err := sdp.ParseSdp2LogicContext([]byte(testSdp))
+	assert.Equal(t, nil, err)
+
+	session := NewBaseOutSession(base.SessionTypeRtspSub, nil)
+	defer session.Dispose()
+	session.InitWithSdp(sdpCtx)
+	assert.Equal(t, nil, session.SetupWithChannel("rtsp://127.0.0.1/live/test/streamid=0", 0, 1))
+
+	h := rtprtcp.MakeDefaultRtpHeader()
+	h.PacketType = 96
+	h.Ssrc = 1000
+	h.Seq = 10
+	session.videoRtpHistory = rtprtcp.NewRtpPacketHistory(rtpPacketHistorySize)
+	session.videoRtpHistory.Put(rtprtcp.MakeRtpPacket(h, []byte{1}))
+
+	nack := rtprtcp.Nack{MediaSsrc: 1000, Seqs: []uint16{10}}
+	session.handleNack(nack.Pack())
+
+	stat := session.GetStat()
+	assert.Equal(t, uint64(1), stat.NackRequestedPackets)
+	assert.Equal(t, uint64(0), stat.NackRetransmittedPackets)
+}
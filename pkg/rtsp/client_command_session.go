@@ -13,6 +13,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -42,6 +43,7 @@ const (
 type ClientCommandSessionOption struct {
 	DoTimeoutMs int
 	OverTcp     bool
+	PlayControl PlayControl // 拉流时第一个PLAY信令携带的Range和Scale，比如从点播文件的指定位置开始播放
 }
 
 var defaultClientCommandSessionOption = ClientCommandSessionOption{
@@ -76,7 +78,10 @@ type ClientCommandSession struct {
 
 	cseq                        int
 	methodGetParameterSupported bool
+	sessionTimeoutSec           int // 对端在SETUP回复的Session头中携带的timeout参数，没有时为0
 	auth                        Auth
+	cmdMutex                    sync.Mutex                           // 开始读循环后，保活、PAUSE、PLAY等信令可能在不同的协程中发送
+	pendingResp                 map[int]chan nazahttp.HttpRespMsgCtx // key: CSeq，等待读循环收到回复
 
 	sdpCtx sdp.LogicContext
 
@@ -94,10 +99,11 @@ func NewClientCommandSession(t ClientCommandSessionType, uniqueKey string, obser
 		fn(&option)
 	}
 	s := &ClientCommandSession{
-		t:         t,
-		uniqueKey: uniqueKey,
-		observer:  observer,
-		option:    option,
+		t:           t,
+		uniqueKey:   uniqueKey,
+		observer:    observer,
+		option:      option,
+		pendingResp: make(map[int]chan nazahttp.HttpRespMsgCtx),
 	}
 	Log.Infof("[%s] lifecycle new rtsp ClientCommandSession. session=%p", uniqueKey, s)
 	return s
@@ -136,6 +142,23 @@ func (session *ClientCommandSession) WaitChan() <-chan error {
 	return session.conn.Done()
 }
 
+// Pause only for PullSession，阻塞直到收到对端回复
+func (session *ClientCommandSession) Pause() error {
+	_, err := session.writeCmdWaitResp(MethodPause, session.urlCtx.RawUrlWithoutUserInfo, nil)
+	return err
+}
+
+// Play only for PullSession，PAUSE之后恢复播放，或者seek、调整倍速。阻塞直到收到对端回复
+//
+// @return 对端回复的Range和Scale
+func (session *ClientCommandSession) Play(ctrl PlayControl) (PlayControl, error) {
+	ctx, err := session.writeCmdWaitResp(MethodPlay, session.urlCtx.RawUrlWithoutUserInfo, playControlHeaders(ctrl))
+	if err != nil {
+		return PlayControl{}, err
+	}
+	return ParsePlayControl(ctx.Headers.Get(HeaderRange), ctx.Headers.Get(HeaderScale))
+}
+
 // ---------------------------------------------------------------------------------------------------------------------
 
 func (session *ClientCommandSession) WriteInterleavedPacket(packet []byte, channel int) error {
@@ -246,50 +269,15 @@ func (session *ClientCommandSession) runReadLoop() {
 		_ = session.dispose(loopErr)
 	}()
 
-	if !session.methodGetParameterSupported {
-		// TCP模式，需要收取数据进行处理
-		if session.option.OverTcp {
-			var r = bufio.NewReader(session.conn)
-			for {
-				isInterleaved, packet, channel, err := readInterleaved(r)
-				if err != nil {
-					loopErr = err
-					return
-				}
-				if isInterleaved {
-					session.observer.OnInterleavedPacket(packet, int(channel))
-				}
-			}
-		}
-
-		// not over tcp
-		// 接收TCP对端关闭FIN信号
-		dummy := make([]byte, 1)
-		_, err := session.conn.Read(dummy)
-		loopErr = err
-		return
-	}
-
 	// 对端支持get_parameter，需要定时向对端发送get_parameter进行保活
+	if session.methodGetParameterSupported {
+		go session.runKeepaliveLoop()
+	}
 
-	Log.Debugf("[%s] start get_parameter timer.", session.uniqueKey)
 	var r = bufio.NewReader(session.conn)
-	t := time.NewTicker(writeGetParameterIntervalMs * time.Millisecond)
-	defer t.Stop()
-
-	if session.option.OverTcp {
-		for {
-			select {
-			case <-t.C:
-				session.cseq++
-				if err := session.writeCmd(MethodGetParameter, session.urlCtx.RawUrlWithoutUserInfo, nil, ""); err != nil {
-					loopErr = err
-					return
-				}
-			default:
-				// noop
-			}
-
+	for {
+		// TCP模式，需要收取数据进行处理，rtp、rtcp数据和信令的回复混在一起
+		if session.option.OverTcp {
 			isInterleaved, packet, channel, err := readInterleaved(r)
 			if err != nil {
 				loopErr = err
@@ -297,26 +285,58 @@ func (session *ClientCommandSession) runReadLoop() {
 			}
 			if isInterleaved {
 				session.observer.OnInterleavedPacket(packet, int(channel))
-			} else {
-				if _, err := nazahttp.ReadHttpResponseMessage(r); err != nil {
-					loopErr = err
-					return
-				}
+				continue
 			}
 		}
+
+		// 保活、PAUSE、PLAY等信令的回复
+		// UDP模式下，也用于接收TCP对端关闭FIN信号
+		ctx, err := nazahttp.ReadHttpResponseMessage(r)
+		if err != nil {
+			loopErr = err
+			return
+		}
+		session.onAsyncResponse(ctx)
+	}
+}
+
+func (session *ClientCommandSession) runKeepaliveLoop() {
+	// 对端的超时时间比较短时，保活间隔取超时时间的一半
+	intervalMs := writeGetParameterIntervalMs
+	if session.sessionTimeoutSec > 0 && session.sessionTimeoutSec*1000/2 < intervalMs {
+		intervalMs = session.sessionTimeoutSec * 1000 / 2
 	}
 
-	// not over tcp
+	Log.Debugf("[%s] start get_parameter timer. intervalMs=%d", session.uniqueKey, intervalMs)
+	t := time.NewTicker(time.Duration(intervalMs) * time.Millisecond)
+	defer t.Stop()
 	for {
 		select {
+		case <-session.conn.Done():
+			return
 		case <-t.C:
-			session.cseq++
-			if _, err := session.writeCmdReadResp(MethodGetParameter, session.urlCtx.RawUrlWithoutUserInfo, nil, ""); err != nil {
-				loopErr = err
+			if err := session.writeCmd(MethodGetParameter, session.urlCtx.RawUrlWithoutUserInfo, nil, ""); err != nil {
 				return
 			}
 		}
+	}
+}
+
+func (session *ClientCommandSession) onAsyncResponse(ctx nazahttp.HttpRespMsgCtx) {
+	Log.Debugf("[%s] < read response. code=%s, reason=%s, headers=%+v", session.uniqueKey, ctx.StatusCode, ctx.Reason, ctx.Headers)
+
+	cseq, _ := strconv.Atoi(ctx.Headers.Get(HeaderCSeq))
+	session.cmdMutex.Lock()
+	ch, ok := session.pendingResp[cseq]
+	delete(session.pendingResp, cseq)
+	session.cmdMutex.Unlock()
 
+	if ok {
+		ch <- ctx
+		return
+	}
+	if ctx.StatusCode != "200" {
+		Log.Warnf("[%s] read response but status not ok. code=%s, reason=%s", session.uniqueKey, ctx.StatusCode, ctx.Reason)
 	}
 }
 
@@ -447,6 +467,10 @@ func (session *ClientCommandSession) writeOneSetup(setupUri string) error {
 		htv = fmt.Sprintf(HeaderTransportClientRecordTmpl, lRtpPort, lRtcpPort)
 	case CcstPullSession:
 		htv = fmt.Sprintf(HeaderTransportClientPlayTmpl, lRtpPort, lRtcpPort)
+		// 对端在sdp中携带了密钥时使用SRTP
+		if _, ok := srtpCryptoOfUri(session.sdpCtx, setupUri); ok {
+			htv = toSrtpTransport(htv)
+		}
 	}
 	headers := map[string]string{
 		HeaderTransport: htv,
@@ -462,7 +486,7 @@ func (session *ClientCommandSession) writeOneSetup(setupUri string) error {
 		return err
 	}
 
-	session.sessionId = strings.Split(ctx.Headers.Get(HeaderSession), ";")[0]
+	session.sessionId, session.sessionTimeoutSec = parseSessionHeader(ctx.Headers.Get(HeaderSession))
 
 	rRtpPort, rRtcpPort, err := parseServerPort(ctx.Headers.Get(HeaderTransport))
 	var rtpRAddr, rtcpRAddr string
@@ -523,6 +547,10 @@ func (session *ClientCommandSession) writeOneSetupTcp(setupUri string) error {
 		htv = fmt.Sprintf(HeaderTransportClientRecordTcpTmpl, rtpChannel, rtcpChannel)
 	case CcstPullSession:
 		htv = fmt.Sprintf(HeaderTransportClientPlayTcpTmpl, rtpChannel, rtcpChannel)
+		// 对端在sdp中携带了密钥时使用SRTP
+		if _, ok := srtpCryptoOfUri(session.sdpCtx, setupUri); ok {
+			htv = toSrtpTransport(htv)
+		}
 	}
 	headers := map[string]string{
 		HeaderTransport: htv,
@@ -537,7 +565,7 @@ func (session *ClientCommandSession) writeOneSetupTcp(setupUri string) error {
 		return err
 	}
 
-	session.sessionId = strings.Split(ctx.Headers.Get(HeaderSession), ";")[0]
+	session.sessionId, session.sessionTimeoutSec = parseSessionHeader(ctx.Headers.Get(HeaderSession))
 
 	// TODO chef: 这里没有解析回传的channel id了，因为我假定了它和request中的是一致的
 	session.observer.OnSetupWithChannel(setupUri, rtpChannel, rtcpChannel)
@@ -545,8 +573,9 @@ func (session *ClientCommandSession) writeOneSetupTcp(setupUri string) error {
 }
 
 func (session *ClientCommandSession) writePlay() error {
-	headers := map[string]string{
-		HeaderRange: HeaderRangeDefault,
+	headers := playControlHeaders(session.option.PlayControl)
+	if _, exist := headers[HeaderRange]; !exist {
+		headers[HeaderRange] = HeaderRangeDefault
 	}
 	_, err := session.writeCmdReadResp(MethodPlay, session.urlCtx.RawUrlWithoutUserInfo, headers, "")
 	return err
@@ -561,7 +590,20 @@ func (session *ClientCommandSession) writeRecord() error {
 }
 
 func (session *ClientCommandSession) writeCmd(method, uri string, headers map[string]string, body string) error {
+	return session.writeCmdWithRespChan(method, uri, headers, body, nil)
+}
+
+// writeCmdWithRespChan
+//
+// @param respChan: 不为nil时，读循环收到该信令的回复后写入respChan
+func (session *ClientCommandSession) writeCmdWithRespChan(method, uri string, headers map[string]string, body string, respChan chan nazahttp.HttpRespMsgCtx) error {
+	session.cmdMutex.Lock()
+	defer session.cmdMutex.Unlock()
+
 	session.cseq++
+	if respChan != nil {
+		session.pendingResp[session.cseq] = respChan
+	}
 	if headers == nil {
 		headers = make(map[string]string)
 	}
@@ -588,6 +630,39 @@ func (session *ClientCommandSession) writeCmd(method, uri string, headers map[st
 	return err
 }
 
+// writeCmdWaitResp 开始读循环后使用，由读循环接收回复
+//
+// @return 回复的状态码不是200时返回错误
+func (session *ClientCommandSession) writeCmdWaitResp(method, uri string, headers map[string]string) (ctx nazahttp.HttpRespMsgCtx, err error) {
+	if session.conn == nil {
+		return ctx, base.ErrSessionNotStarted
+	}
+
+	respChan := make(chan nazahttp.HttpRespMsgCtx, 1)
+	if err = session.writeCmdWithRespChan(method, uri, headers, "", respChan); err != nil {
+		return
+	}
+
+	var timeoutChan <-chan time.Time
+	if session.option.DoTimeoutMs != 0 {
+		t := time.NewTimer(time.Duration(session.option.DoTimeoutMs) * time.Millisecond)
+		defer t.Stop()
+		timeoutChan = t.C
+	}
+	select {
+	case ctx = <-respChan:
+	case <-session.conn.Done():
+		return ctx, base.ErrSessionNotStarted
+	case <-timeoutChan:
+		return ctx, nazaerrors.Wrap(base.ErrRtsp, method+" timeout")
+	}
+
+	if ctx.StatusCode != "200" {
+		err = nazaerrors.Wrap(base.ErrRtsp, fmt.Sprintf("%s failed. code=%s, reason=%s", method, ctx.StatusCode, ctx.Reason))
+	}
+	return
+}
+
 // @param headers 可以为nil
 // @param body 可以为空
 func (session *ClientCommandSession) writeCmdReadResp(method, uri string, headers map[string]string, body string) (ctx nazahttp.HttpRespMsgCtx, err error) {
@@ -614,6 +689,17 @@ func (session *ClientCommandSession) writeCmdReadResp(method, uri string, header
 	return
 }
 
+func playControlHeaders(ctrl PlayControl) map[string]string {
+	headers := make(map[string]string)
+	if v := ctrl.RangeHeader(); v != "" {
+		headers[HeaderRange] = v
+	}
+	if v := ctrl.ScaleHeader(); v != "" {
+		headers[HeaderScale] = v
+	}
+	return headers
+}
+
 func (session *ClientCommandSession) dispose(err error) error {
 	var retErr error
 	session.disposeOnce.Do(func() {
@@ -12,6 +12,7 @@ import (
 	"sync"
 
 	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/lal/pkg/rtprtcp"
 	"github.com/q191201771/lal/pkg/sdp"
 	"github.com/q191201771/naza/pkg/nazaerrors"
 	"github.com/q191201771/naza/pkg/nazanet"
@@ -27,6 +28,8 @@ type PullSessionOption struct {
 	PullTimeoutMs int
 
 	OverTcp bool // 是否使用interleaved模式，也即是否通过rtsp command tcp连接传输rtp/rtcp数据
+
+	PlayControl PlayControl // 第一个PLAY信令携带的Range和Scale，零值时使用`npt=0.000-`
 }
 
 var defaultPullSessionOption = PullSessionOption{
@@ -62,6 +65,7 @@ func NewPullSession(observer IPullSessionObserver, modOptions ...ModPullSessionO
 	cmdSession := NewClientCommandSession(CcstPullSession, baseInSession.UniqueKey(), s, func(opt *ClientCommandSessionOption) {
 		opt.DoTimeoutMs = option.PullTimeoutMs
 		opt.OverTcp = option.OverTcp
+		opt.PlayControl = option.PlayControl
 	})
 	s.baseInSession = baseInSession
 	s.cmdSession = cmdSession
@@ -137,6 +141,22 @@ func (session *PullSession) GetSdp() sdp.LogicContext {
 	return session.baseInSession.GetSdp()
 }
 
+func (session *PullSession) GetExtensionRegistry() (audio, video *rtprtcp.RtpExtensionRegistry) {
+	return session.baseInSession.GetExtensionRegistry()
+}
+
+// Pause 暂停播放，需要在Start成功后调用，阻塞直到收到对端回复
+func (session *PullSession) Pause() error {
+	return session.cmdSession.Pause()
+}
+
+// Play 暂停后恢复播放，也可用于seek或调整倍速，需要在Start成功后调用，阻塞直到收到对端回复
+//
+// @return 对端回复的Range和Scale
+func (session *PullSession) Play(ctrl PlayControl) (PlayControl, error) {
+	return session.cmdSession.Play(ctrl)
+}
+
 // ---------------------------------------------------------------------------------------------------------------------
 // IClientSessionLifecycle interface
 // ---------------------------------------------------------------------------------------------------------------------
@@ -222,11 +242,13 @@ func (session *PullSession) OnDescribeResponse(sdpCtx sdp.LogicContext) {
 
 // OnSetupWithConn callback by ClientCommandSession
 func (session *PullSession) OnSetupWithConn(uri string, rtpConn, rtcpConn *nazanet.UdpConnection) {
+	session.setupSrtp(uri)
 	_ = session.baseInSession.SetupWithConn(uri, rtpConn, rtcpConn)
 }
 
 // OnSetupWithChannel callback by ClientCommandSession
 func (session *PullSession) OnSetupWithChannel(uri string, rtpChannel, rtcpChannel int) {
+	session.setupSrtp(uri)
 	_ = session.baseInSession.SetupWithChannel(uri, rtpChannel, rtcpChannel)
 }
 
@@ -251,6 +273,16 @@ func (session *PullSession) WriteInterleavedPacket(packet []byte, channel int) e
 
 // ---------------------------------------------------------------------------------------------------------------------
 
+// setupSrtp 和 ClientCommandSession 中SETUP使用RTP/SAVP的判断条件保持一致
+func (session *PullSession) setupSrtp(uri string) {
+	if _, ok := srtpCryptoOfUri(session.baseInSession.GetSdp(), uri); !ok {
+		return
+	}
+	if err := session.baseInSession.SetupSrtp(uri); err != nil {
+		Log.Warnf("[%s] setup srtp failed. err=%+v", session.UniqueKey(), err)
+	}
+}
+
 func (session *PullSession) dispose(err error) error {
 	var retErr error
 	session.disposeOnce.Do(func() {
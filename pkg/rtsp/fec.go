@@ -0,0 +1,69 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtsp
+
+import (
+	"math/rand"
+
+	"github.com/q191201771/lal/pkg/sdp"
+)
+
+// fec.go
+//
+// UDP模式下的rfc5109 ULPFEC，fec包和媒体包使用同一个rtp连接，通过payload type区分，fec流使用单独的ssrc：
+//
+// - sub: 开启 ServerTransportConfig.FecEnable 后，在DESCRIBE的回复中携带`a=rtpmap:<pt> ulpfec/<clock rate>`，
+//        以及fec流的`a=ssrc:<ssrc> cname:<cname>`，
+//        拉流端SETUP使用UDP时，每 ServerTransportConfig.FecGroupSize 个媒体包发送一个fec包
+// - pub、pull: sdp中有ulpfec时，使用收到的fec包恢复丢失的媒体包
+//
+// 使用SRTP时不发送、不处理fec包（fec在加密前还是加密后计算需要两端约定，目前不支持）
+
+const (
+	defaultFecGroupSize = 10
+
+	fecCname = "lal"
+)
+
+// newFecSsrc 随机生成fec流的ssrc，不为0
+func newFecSsrc() uint32 {
+	for {
+		if ssrc := rand.Uint32(); ssrc != 0 {
+			return ssrc
+		}
+	}
+}
+
+// chooseFecPayloadType 为音频、视频分别选取一个没有被使用的动态payload type，sdp中已经有ulpfec时返回0，表示不需要追加
+//
+// sdp中没有对应的媒体时，返回的值不会被使用，见 sdp.AppendFecRtpMap
+func chooseFecPayloadType(sdpCtx sdp.LogicContext) (audioPt, videoPt int) {
+	used := func(pt int) bool {
+		return sdpCtx.IsPayloadTypeOrigin(pt) || pt == sdpCtx.AudioFecPayloadType || pt == sdpCtx.VideoFecPayloadType
+	}
+	next := func(from int) int {
+		for pt := from; pt >= 96; pt-- {
+			if !used(pt) {
+				return pt
+			}
+		}
+		return 0
+	}
+
+	if sdpCtx.AudioFecPayloadType == 0 {
+		audioPt = next(127)
+	}
+	if sdpCtx.VideoFecPayloadType == 0 {
+		videoPt = next(127)
+		if videoPt == audioPt {
+			videoPt = next(audioPt - 1)
+		}
+	}
+	return
+}
@@ -0,0 +1,102 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtsp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/lal/pkg/rtprtcp"
+	"github.com/q191201771/lal/pkg/sdp"
+	"github.com/q191201771/naza/pkg/assert"
+	"github.com/q191201771/naza/pkg/nazanet"
+)
+
+func TestChooseFecPayloadType(t *testing.T) {
+	sdpCtx, err := sdp.ParseSdp2LogicContext([]byte(testSdp))
+	assert.Equal(t, nil, err)
+	audioPt, videoPt := chooseFecPayloadType(sdpCtx)
+	assert.Equal(t, 127, audioPt)
+	assert.Equal(t, 126, videoPt)
+
+	// sdp中已经有ulpfec时不再追加
+	sdpCtx, err = sdp.ParseSdp2LogicContext(sdp.AppendFecRtpMap([]byte(testSdp), 0, videoPt))
+	assert.Equal(t, nil, err)
+	_, videoPt = chooseFecPayloadType(sdpCtx)
+	assert.Equal(t, 0, videoPt)
+}
+
+// TestBaseSessionFec BaseOutSession 通过UDP发送媒体包和fec包，丢弃其中一个媒体包后交给 BaseInSession ，恢复出丢失的包
+func TestBaseSessionFec(t *testing.T) {
+	sdpCtx, err := sdp.ParseSdp2LogicContext(sdp.AppendFecRtpMap([]byte(testSdp), 0, 127))
+	assert.Equal(t, nil, err)
+	uri := "rtsp://127.0.0.1/live/test/streamid=0"
+
+	peerRtp, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.Equal(t, nil, err)
+	defer peerRtp.Close()
+	rtpConn, err := nazanet.NewUdpConnection(func(option *nazanet.UdpConnectionOption) {
+		option.LAddr = "127.0.0.1:0"
+		option.RAddr = peerRtp.LocalAddr().String()
+	})
+	assert.Equal(t, nil, err)
+	rtcpConn, err := nazanet.NewUdpConnection(func(option *nazanet.UdpConnectionOption) {
+		option.LAddr = "127.0.0.1:0"
+	})
+	assert.Equal(t, nil, err)
+
+	outSession := NewBaseOutSession(base.SessionTypeRtspSub, nil)
+	defer outSession.Dispose()
+	outSession.fecGroupSize = 4
+	outSession.InitWithSdp(sdpCtx)
+	assert.Equal(t, nil, outSession.SetupWithConn(uri, rtpConn, rtcpConn))
+
+	observer := &testSrtpPullObserver{rtpChan: make(chan rtprtcp.RtpPacket, 16)}
+	inSession := NewBaseInSessionWithObserver(base.SessionTypeRtspPub, nil, observer)
+	inSession.InitWithSdp(sdpCtx)
+	assert.Equal(t, nil, inSession.SetupWithChannel(uri, 0, 1))
+
+	var pkts []rtprtcp.RtpPacket
+	h := rtprtcp.MakeDefaultRtpHeader()
+	h.PacketType = 96
+	h.Ssrc = 1000
+	for seq := uint16(10); seq < 14; seq++ {
+		h.Seq = seq
+		pkt := rtprtcp.MakeRtpPacket(h, []byte{0x65, byte(seq), 0x01, 0x02})
+		pkts = append(pkts, pkt)
+		assert.Equal(t, nil, outSession.WriteRtpPacket(pkt))
+	}
+
+	// 4个媒体包加1个fec包，丢弃seq为12的包
+	buf := make([]byte, 1500)
+	_ = peerRtp.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for i := 0; i < 5; i++ {
+		n, err := peerRtp.Read(buf)
+		assert.Equal(t, nil, err)
+		pkt, err := rtprtcp.ParseRtpPacket(buf[:n])
+		assert.Equal(t, nil, err)
+		if pkt.Header.PacketType == 96 && pkt.Header.Seq == 12 {
+			continue
+		}
+		inSession.HandleInterleavedPacket(append([]byte(nil), buf[:n]...), 0)
+	}
+
+	var seqs []uint16
+	for len(observer.rtpChan) != 0 {
+		pkt := <-observer.rtpChan
+		seqs = append(seqs, pkt.Header.Seq)
+		if pkt.Header.Seq == 12 {
+			assert.Equal(t, pkts[2].Raw, pkt.Raw)
+		}
+	}
+	assert.Equal(t, []uint16{10, 11, 13, 12}, seqs)
+	assert.Equal(t, uint64(1), inSession.GetStat().FecRecoveredPackets)
+}
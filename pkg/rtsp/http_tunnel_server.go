@@ -0,0 +1,338 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtsp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/q191201771/lal/pkg/base"
+)
+
+// http_tunnel_server.go
+//
+// RTSP over HTTP（QuickTime的GET/POST隧道），用于只放行HTTP的网络环境
+//
+// 1. 客户端先发送GET请求，服务端回复200后保持连接，之后服务端发送的RTSP回复以及interleaved的rtp、rtcp数据都通过该连接原样发送
+// 2. 客户端再发送携带相同x-sessioncookie的POST请求，之后客户端发送的RTSP信令（以及interleaved数据）都经过base64编码后通过该连接发送，服务端不回复
+//
+// GET、POST两条连接配对后，组合成一个net.Conn交给 ServerCommandSession ，后续流程和普通的RTSP TCP连接一致
+
+const (
+	HeaderXSessionCookie           = "x-sessioncookie"
+	HeaderContentTypeRtspTunnelled = "application/x-rtsp-tunnelled"
+
+	// httpTunnelPairTimeout GET请求之后等待POST请求的超时时间
+	httpTunnelPairTimeout = 10 * time.Second
+
+	// 读取GET、POST请求头的超时时间，连接被接管后不再生效
+	httpTunnelReadHeaderTimeout = 5 * time.Second
+	httpTunnelReadTimeout       = 10 * time.Second
+
+	// httpTunnelMaxPendingGetNum 最多同时等待POST请求配对的GET连接数，超过时回复503
+	httpTunnelMaxPendingGetNum = 1024
+)
+
+var httpTunnelServiceUnavailableResponse = "HTTP/1.0 503 Service Unavailable\r\n" +
+	"Server: " + base.LalRtspOptionsResponseServer + "\r\n" +
+	"Connection: close\r\n" +
+	"\r\n"
+
+var httpTunnelGetResponse = "HTTP/1.0 200 OK\r\n" +
+	"Server: " + base.LalRtspOptionsResponseServer + "\r\n" +
+	"Connection: close\r\n" +
+	"Cache-Control: no-store\r\n" +
+	"Pragma: no-cache\r\n" +
+	"Content-Type: " + HeaderContentTypeRtspTunnelled + "\r\n" +
+	"\r\n"
+
+type HttpTunnelServer struct {
+	addr     string
+	observer IServerObserver
+
+	ln         net.Listener
+	auth       ServerAuthConfig
+	transport  *serverTransport
+	httpServer *http.Server
+
+	maxPendingGetNum int
+
+	mutex    sync.Mutex
+	getConns map[string]net.Conn // key: x-sessioncookie，等待POST请求配对的GET连接
+}
+
+func NewHttpTunnelServer(addr string, observer IServerObserver, auth ServerAuthConfig, transport ServerTransportConfig) *HttpTunnelServer {
+	s := &HttpTunnelServer{
+		addr:      addr,
+		observer:  observer,
+		auth:      auth,
+		transport: newServerTransport(transport),
+		getConns:  make(map[string]net.Conn),
+
+		maxPendingGetNum: httpTunnelMaxPendingGetNum,
+	}
+	s.httpServer = &http.Server{
+		Handler:           http.HandlerFunc(s.HandleHttpTunnel),
+		ReadHeaderTimeout: httpTunnelReadHeaderTimeout,
+		ReadTimeout:       httpTunnelReadTimeout,
+	}
+	return s
+}
+
+// ShareTransport 和 server 共用UDP端口池等传输层资源，避免同一个端口范围被两个池重复分配，调用方保证在Listen之前调用
+func (s *HttpTunnelServer) ShareTransport(server *Server) {
+	s.transport = server.transport
+}
+
+func (s *HttpTunnelServer) Listen() (err error) {
+	s.ln, err = net.Listen("tcp", s.addr)
+	if err != nil {
+		return
+	}
+	Log.Infof("start rtsp over http tunnel server listen. addr=%s", s.addr)
+	return
+}
+
+func (s *HttpTunnelServer) RunLoop() error {
+	return s.httpServer.Serve(s.ln)
+}
+
+func (s *HttpTunnelServer) HandleHttpTunnel(w http.ResponseWriter, r *http.Request) {
+	cookie := r.Header.Get(HeaderXSessionCookie)
+	if cookie == "" || (r.Method != http.MethodGet && r.Method != http.MethodPost) {
+		Log.Warnf("invalid rtsp over http request. method=%s, cookie=%s, raddr=%s", r.Method, cookie, r.RemoteAddr)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	conn, bio, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		Log.Errorf("hijack failed. err=%+v", err)
+		return
+	}
+	// 接管后的连接可能还带着http.Server设置的超时时间，清除掉，后续由 ServerCommandSession 管理
+	_ = conn.SetDeadline(time.Time{})
+
+	if r.Method == http.MethodGet {
+		s.handleGet(cookie, conn)
+		return
+	}
+
+	s.mutex.Lock()
+	getConn, ok := s.getConns[cookie]
+	delete(s.getConns, cookie)
+	s.mutex.Unlock()
+	if !ok {
+		Log.Warnf("rtsp over http post but get not exist. cookie=%s, raddr=%s", cookie, r.RemoteAddr)
+		_ = conn.Close()
+		return
+	}
+
+	// POST的body可能已经有一部分被读入bio中
+	tunnelConn := newHttpTunnelConn(getConn, conn, bio.Reader)
+	session := NewServerCommandSession(s, tunnelConn, s.auth, false, "")
+	session.transport = s.transport
+	s.observer.OnNewRtspSessionConnect(session)
+
+	runServerCommandSession(s.observer, session)
+}
+
+func (s *HttpTunnelServer) Dispose() {
+	if s.ln != nil {
+		if err := s.ln.Close(); err != nil {
+			Log.Error(err)
+		}
+	}
+
+	s.mutex.Lock()
+	for cookie, conn := range s.getConns {
+		_ = conn.Close()
+		delete(s.getConns, cookie)
+	}
+	s.mutex.Unlock()
+}
+
+// ----- ServerCommandSessionObserver ----------------------------------------------------------------------------------
+
+func (s *HttpTunnelServer) OnNewRtspPubSession(session *PubSession) error {
+	return s.observer.OnNewRtspPubSession(session)
+}
+
+func (s *HttpTunnelServer) OnNewRtspSubSessionDescribe(session *SubSession) (ok bool, sdp []byte) {
+	return s.observer.OnNewRtspSubSessionDescribe(session)
+}
+
+func (s *HttpTunnelServer) OnNewRtspSubSessionPlay(session *SubSession) error {
+	return s.observer.OnNewRtspSubSessionPlay(session)
+}
+
+func (s *HttpTunnelServer) OnRtspSubSessionPlayControl(session *SubSession) error {
+	return onPlayControl(s.observer, session)
+}
+
+func (s *HttpTunnelServer) OnDelRtspPubSession(session *PubSession) {
+	s.observer.OnDelRtspPubSession(session)
+}
+
+func (s *HttpTunnelServer) OnDelRtspSubSession(session *SubSession) {
+	s.observer.OnDelRtspSubSession(session)
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+func (s *HttpTunnelServer) handleGet(cookie string, conn net.Conn) {
+	s.mutex.Lock()
+	prev, ok := s.getConns[cookie]
+	if !ok && len(s.getConns) >= s.maxPendingGetNum {
+		s.mutex.Unlock()
+		Log.Warnf("rtsp over http too many pending get. num=%d, raddr=%s", s.maxPendingGetNum, conn.RemoteAddr().String())
+		_, _ = conn.Write([]byte(httpTunnelServiceUnavailableResponse))
+		_ = conn.Close()
+		return
+	}
+	if ok {
+		Log.Warnf("rtsp over http get cookie duplicated, close the previous one. cookie=%s", cookie)
+		_ = prev.Close()
+	}
+	s.getConns[cookie] = conn
+	s.mutex.Unlock()
+
+	if _, err := conn.Write([]byte(httpTunnelGetResponse)); err != nil {
+		s.mutex.Lock()
+		if c, ok := s.getConns[cookie]; ok && c == conn {
+			delete(s.getConns, cookie)
+		}
+		s.mutex.Unlock()
+		_ = conn.Close()
+		return
+	}
+
+	// 超时没有等到POST请求，关闭GET连接
+	time.AfterFunc(httpTunnelPairTimeout, func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if c, ok := s.getConns[cookie]; ok && c == conn {
+			Log.Warnf("rtsp over http wait post timeout. cookie=%s", cookie)
+			_ = conn.Close()
+			delete(s.getConns, cookie)
+		}
+	})
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// httpTunnelConn 把GET、POST两条连接组合成一个net.Conn，从POST读取并base64解码，向GET写入
+type httpTunnelConn struct {
+	getConn  net.Conn
+	postConn net.Conn
+	reader   *httpTunnelReader
+}
+
+func newHttpTunnelConn(getConn, postConn net.Conn, postReader io.Reader) *httpTunnelConn {
+	return &httpTunnelConn{
+		getConn:  getConn,
+		postConn: postConn,
+		reader:   &httpTunnelReader{r: postReader},
+	}
+}
+
+func (c *httpTunnelConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+func (c *httpTunnelConn) Write(b []byte) (int, error) {
+	return c.getConn.Write(b)
+}
+
+func (c *httpTunnelConn) Close() error {
+	err := c.postConn.Close()
+	if err2 := c.getConn.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+func (c *httpTunnelConn) LocalAddr() net.Addr {
+	return c.getConn.LocalAddr()
+}
+
+func (c *httpTunnelConn) RemoteAddr() net.Addr {
+	return c.getConn.RemoteAddr()
+}
+
+func (c *httpTunnelConn) SetDeadline(t time.Time) error {
+	if err := c.postConn.SetDeadline(t); err != nil {
+		return err
+	}
+	return c.getConn.SetDeadline(t)
+}
+
+func (c *httpTunnelConn) SetReadDeadline(t time.Time) error {
+	return c.postConn.SetReadDeadline(t)
+}
+
+func (c *httpTunnelConn) SetWriteDeadline(t time.Time) error {
+	return c.getConn.SetWriteDeadline(t)
+}
+
+// httpTunnelReader base64解码
+//
+// 客户端通常对每个RTSP信令单独编码，所以数据流中间可能出现padding，不能直接使用 base64.NewDecoder
+type httpTunnelReader struct {
+	r   io.Reader
+	buf [4096]byte
+	in  []byte // 还未解码的base64字符，长度不足4
+	out []byte // 已解码还未被读取的数据
+}
+
+func (hr *httpTunnelReader) Read(b []byte) (int, error) {
+	for len(hr.out) == 0 {
+		n, err := hr.r.Read(hr.buf[:])
+		for _, c := range hr.buf[:n] {
+			if c != '\r' && c != '\n' && c != ' ' && c != '\t' {
+				hr.in = append(hr.in, c)
+			}
+		}
+		if decodeErr := hr.decode(); decodeErr != nil {
+			return 0, decodeErr
+		}
+		if err != nil && len(hr.out) == 0 {
+			return 0, err
+		}
+	}
+
+	n := copy(b, hr.out)
+	hr.out = hr.out[n:]
+	return n, nil
+}
+
+func (hr *httpTunnelReader) decode() error {
+	in := hr.in[:len(hr.in)/4*4]
+	for len(in) != 0 {
+		// 每次解码到padding结束的位置
+		end := len(in)
+		if idx := bytes.IndexByte(in, '='); idx != -1 {
+			end = (idx/4 + 1) * 4
+		}
+		dst := make([]byte, base64.StdEncoding.DecodedLen(end))
+		n, err := base64.StdEncoding.Decode(dst, in[:end])
+		if err != nil {
+			return err
+		}
+		hr.out = append(hr.out, dst[:n]...)
+		in = in[end:]
+	}
+	hr.in = append(hr.in[:0], hr.in[len(hr.in)/4*4:]...)
+	return nil
+}
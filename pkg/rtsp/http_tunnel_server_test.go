@@ -0,0 +1,136 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtsp
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/q191201771/naza/pkg/assert"
+	"github.com/q191201771/naza/pkg/nazahttp"
+)
+
+func TestHttpTunnelServer(t *testing.T) {
+	observer := newTestServerObserver()
+	s := NewHttpTunnelServer("", observer, ServerAuthConfig{}, ServerTransportConfig{})
+	hs := httptest.NewServer(http.HandlerFunc(s.HandleHttpTunnel))
+	defer hs.Close()
+	addr := strings.TrimPrefix(hs.URL, "http://")
+	uri := fmt.Sprintf("rtsp://%s/live/test", addr)
+
+	// 没有配对的GET时，POST连接被关闭
+	postConn, err := net.Dial("tcp", addr)
+	assert.Equal(t, nil, err)
+	_, err = postConn.Write([]byte("POST /live/test HTTP/1.0\r\nx-sessioncookie: notexist\r\nContent-Length: 32767\r\n\r\n"))
+	assert.Equal(t, nil, err)
+	_ = postConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = bufio.NewReader(postConn).ReadByte()
+	assert.Equal(t, io.EOF, err)
+	postConn.Close()
+
+	getConn, err := net.Dial("tcp", addr)
+	assert.Equal(t, nil, err)
+	defer getConn.Close()
+	_, err = getConn.Write([]byte("GET /live/test HTTP/1.0\r\nx-sessioncookie: abc\r\nAccept: application/x-rtsp-tunnelled\r\n\r\n"))
+	assert.Equal(t, nil, err)
+	r := bufio.NewReader(getConn)
+	resp, err := nazahttp.ReadHttpResponseMessage(r)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "200", resp.StatusCode)
+	assert.Equal(t, HeaderContentTypeRtspTunnelled, resp.Headers.Get("Content-Type"))
+
+	postConn, err = net.Dial("tcp", addr)
+	assert.Equal(t, nil, err)
+	defer postConn.Close()
+	_, err = postConn.Write([]byte("POST /live/test HTTP/1.0\r\nx-sessioncookie: abc\r\nContent-Type: application/x-rtsp-tunnelled\r\nContent-Length: 32767\r\n\r\n"))
+	assert.Equal(t, nil, err)
+
+	// 每个信令单独编码，并且拆开发送
+	b64 := base64.StdEncoding.EncodeToString([]byte(PackRequest(MethodOptions, uri, map[string]string{HeaderCSeq: "1"}, "")))
+	b64 += base64.StdEncoding.EncodeToString([]byte(PackRequest(MethodDescribe, uri, map[string]string{HeaderCSeq: "2"}, "")))
+	for i := 0; i < len(b64); i += 7 {
+		end := i + 7
+		if end > len(b64) {
+			end = len(b64)
+		}
+		_, err = postConn.Write([]byte(b64[i:end]))
+		assert.Equal(t, nil, err)
+		time.Sleep(time.Millisecond)
+	}
+
+	resp, err = nazahttp.ReadHttpResponseMessage(r)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "200", resp.StatusCode)
+	assert.Equal(t, "1", resp.Headers.Get(HeaderCSeq))
+	assert.Equal(t, true, strings.Contains(resp.Headers.Get(HeaderPublic), MethodDescribe))
+	resp, err = nazahttp.ReadHttpResponseMessage(r)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "200", resp.StatusCode)
+	assert.Equal(t, "2", resp.Headers.Get(HeaderCSeq))
+	assert.Equal(t, testSdp, string(resp.Body))
+	subSession := <-observer.subSessionChan
+	assert.Equal(t, "test", subSession.StreamName())
+
+	// 客户端关闭POST连接后，GET连接也被关闭
+	postConn.Close()
+	_ = getConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = r.ReadByte()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestHttpTunnelReader(t *testing.T) {
+	hr := &httpTunnelReader{r: strings.NewReader("YQ==\r\nYmM=YmNk\r\nZQ==")}
+	b, err := io.ReadAll(hr)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "abcbcde", string(b))
+
+	// 连接断开时结尾不足4个的base64字符被丢弃
+	hr = &httpTunnelReader{r: strings.NewReader("YQ==YmNk\r\nZQ")}
+	b, err = io.ReadAll(hr)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "abcd", string(b))
+
+	hr = &httpTunnelReader{r: strings.NewReader("YQ=*")}
+	_, err = io.ReadAll(hr)
+	assert.IsNotNil(t, err)
+}
+
+func TestHttpTunnelServerMaxPendingGet(t *testing.T) {
+	s := NewHttpTunnelServer("", newTestServerObserver(), ServerAuthConfig{}, ServerTransportConfig{})
+	s.maxPendingGetNum = 1
+	hs := httptest.NewServer(http.HandlerFunc(s.HandleHttpTunnel))
+	defer hs.Close()
+	defer s.Dispose()
+	addr := strings.TrimPrefix(hs.URL, "http://")
+
+	get := func(cookie string) string {
+		conn, err := net.Dial("tcp", addr)
+		assert.Equal(t, nil, err)
+		defer conn.Close()
+		_, err = conn.Write([]byte("GET /live/test HTTP/1.0\r\nx-sessioncookie: " + cookie + "\r\nAccept: application/x-rtsp-tunnelled\r\n\r\n"))
+		assert.Equal(t, nil, err)
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		resp, err := nazahttp.ReadHttpResponseMessage(bufio.NewReader(conn))
+		assert.Equal(t, nil, err)
+		return resp.StatusCode
+	}
+
+	// 第一个GET占满等待队列，第二个GET被拒绝，相同cookie的GET替换掉之前的连接
+	assert.Equal(t, "200", get("a"))
+	assert.Equal(t, "503", get("b"))
+	assert.Equal(t, "200", get("a"))
+}
@@ -54,10 +54,22 @@ var ResponseRecordTmpl = "RTSP/1.0 200 OK\r\n" +
 
 // rfc2326 10.5 PLAY
 
-// ResponsePlayTmpl CSeq Date
+// ResponsePlayTmpl CSeq, Date, Session, Range, 可选的Scale头
 var ResponsePlayTmpl = "RTSP/1.0 200 OK\r\n" +
 	"CSeq: %s\r\n" +
 	"Date: %s\r\n" +
+	"Session: %s\r\n" +
+	"Range: %s\r\n" +
+	"%s" +
+	"\r\n"
+
+// rfc2326 10.6 PAUSE
+
+// ResponsePauseTmpl CSeq, Date, Session
+var ResponsePauseTmpl = "RTSP/1.0 200 OK\r\n" +
+	"CSeq: %s\r\n" +
+	"Date: %s\r\n" +
+	"Session: %s\r\n" +
 	"\r\n"
 
 // rfc2326 10.7 TEARDOWN
@@ -74,6 +86,46 @@ var ResponseAuthorizedTmpl = "RTSP/1.0 401 Unauthorized\r\n" +
 	"WWW-Authenticate: %s\r\n" +
 	"\r\n"
 
+// rfc2326 10.8 GET_PARAMETER, 10.9 SET_PARAMETER
+
+// ResponseParameterTmpl CSeq, Session
+var ResponseParameterTmpl = "RTSP/1.0 200 OK\r\n" +
+	"CSeq: %s\r\n" +
+	"Session: %s\r\n" +
+	"\r\n"
+
+// ResponseParameterNotUnderstoodTmpl rfc2326 11.3.8 451 Parameter Not Understood
+// CSeq, Session
+var ResponseParameterNotUnderstoodTmpl = "RTSP/1.0 451 Parameter Not Understood\r\n" +
+	"CSeq: %s\r\n" +
+	"Session: %s\r\n" +
+	"\r\n"
+
+// ResponseMethodNotValidInThisStateTmpl rfc2326 11.3.6 455 Method Not Valid in This State
+// CSeq
+var ResponseMethodNotValidInThisStateTmpl = "RTSP/1.0 455 Method Not Valid in This State\r\n" +
+	"CSeq: %s\r\n" +
+	"\r\n"
+
+// ResponseInvalidRangeTmpl rfc2326 11.3.10 457 Invalid Range
+// CSeq
+var ResponseInvalidRangeTmpl = "RTSP/1.0 457 Invalid Range\r\n" +
+	"CSeq: %s\r\n" +
+	"\r\n"
+
+// ResponseNotImplementedTmpl rfc2326 7.1.1 501 Not Implemented
+// CSeq
+var ResponseNotImplementedTmpl = "RTSP/1.0 501 Not Implemented\r\n" +
+	"CSeq: %s\r\n" +
+	"\r\n"
+
+// ResponseUnsupportedTransportTmpl rfc2326 11.3.14 461 Unsupported Transport
+// CSeq, Date
+var ResponseUnsupportedTransportTmpl = "RTSP/1.0 461 Unsupported Transport\r\n" +
+	"CSeq: %s\r\n" +
+	"Date: %s\r\n" +
+	"\r\n"
+
 func PackResponseOptions(cseq string) string {
 	return fmt.Sprintf(base.LalRtspResponseOptionsTmpl, cseq)
 }
@@ -87,19 +139,53 @@ func PackResponseDescribe(cseq, sdp string) string {
 	return fmt.Sprintf(ResponseDescribeTmpl, cseq, date, len(sdp), sdp)
 }
 
-func PackResponseSetup(cseq string, htv string) string {
+// PackResponseSetup
+//
+// @param timeoutSec: Session头中的timeout参数，为0时不携带
+func PackResponseSetup(cseq string, htv string, timeoutSec int) string {
 	date := time.Now().Format(time.RFC1123)
-
-	return fmt.Sprintf(ResponseSetupTmpl, cseq, date, sessionId, htv)
+	session := sessionId
+	if timeoutSec > 0 {
+		session = fmt.Sprintf("%s;timeout=%d", sessionId, timeoutSec)
+	}
+	return fmt.Sprintf(ResponseSetupTmpl, cseq, date, session, htv)
 }
 
 func PackResponseRecord(cseq string) string {
 	return fmt.Sprintf(ResponseRecordTmpl, cseq, sessionId)
 }
 
-func PackResponsePlay(cseq string) string {
+// PackResponsePlay
+//
+// @param ctrl: 没有Range时回复 HeaderRangeDefault
+func PackResponsePlay(cseq string, ctrl PlayControl) string {
 	date := time.Now().Format(time.RFC1123)
-	return fmt.Sprintf(ResponsePlayTmpl, cseq, date)
+	rangeValue := ctrl.RangeHeader()
+	if rangeValue == "" {
+		rangeValue = HeaderRangeDefault
+	}
+	var scale string
+	if v := ctrl.ScaleHeader(); v != "" {
+		scale = HeaderScale + ": " + v + "\r\n"
+	}
+	return fmt.Sprintf(ResponsePlayTmpl, cseq, date, sessionId, rangeValue, scale)
+}
+
+func PackResponsePause(cseq string) string {
+	date := time.Now().Format(time.RFC1123)
+	return fmt.Sprintf(ResponsePauseTmpl, cseq, date, sessionId)
+}
+
+func PackResponseMethodNotValidInThisState(cseq string) string {
+	return fmt.Sprintf(ResponseMethodNotValidInThisStateTmpl, cseq)
+}
+
+func PackResponseInvalidRange(cseq string) string {
+	return fmt.Sprintf(ResponseInvalidRangeTmpl, cseq)
+}
+
+func PackResponseNotImplemented(cseq string) string {
+	return fmt.Sprintf(ResponseNotImplementedTmpl, cseq)
 }
 
 func PackResponseTeardown(cseq string) string {
@@ -111,6 +197,23 @@ func PackResponseAuthorized(cseq, authenticate string) string {
 	return fmt.Sprintf(ResponseAuthorizedTmpl, cseq, date, authenticate)
 }
 
+func PackResponseGetParameter(cseq string) string {
+	return fmt.Sprintf(ResponseParameterTmpl, cseq, sessionId)
+}
+
+// PackResponseSetParameter 不支持设置任何参数，body为空时（一般用于保活）回复200，否则回复451
+func PackResponseSetParameter(cseq string, body []byte) string {
+	if len(body) != 0 {
+		return fmt.Sprintf(ResponseParameterNotUnderstoodTmpl, cseq, sessionId)
+	}
+	return fmt.Sprintf(ResponseParameterTmpl, cseq, sessionId)
+}
+
+func PackResponseUnsupportedTransport(cseq string) string {
+	date := time.Now().Format(time.RFC1123)
+	return fmt.Sprintf(ResponseUnsupportedTransportTmpl, cseq, date)
+}
+
 // PackRequest @param body 可以为空
 func PackRequest(method, uri string, headers map[string]string, body string) (ret string) {
 	ret = method + " " + uri + " RTSP/1.0\r\n"
@@ -0,0 +1,129 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtsp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/naza/pkg/nazaerrors"
+)
+
+// play_control.go
+//
+// PLAY信令中的Range（rfc2326 12.29，只支持npt）和Scale（rfc2326 12.34），用于点播类的RTSP回放
+
+const (
+	NptNow = float64(-1) // PlayControl.Start 取该值时表示`npt=now-`
+	NptEnd = float64(-1) // PlayControl.End 取该值时表示没有结束时间
+)
+
+// PlayControl
+//
+// 零值表示不携带Range和Scale
+type PlayControl struct {
+	RangeFlag bool    // 是否携带Range
+	Start     float64 // 单位秒，RangeFlag为true时有效，取值为 NptNow 时表示直播的当前位置
+	End       float64 // 单位秒，RangeFlag为true时有效，取值为 NptEnd 时表示播放到结尾
+
+	Scale float64 // 为0时表示不携带。1为正常速度，大于1为快进，0到1之间为慢放，负数为倒放
+}
+
+// ParsePlayControl 解析PLAY信令的Range和Scale头
+//
+// @param rangeValue: 比如`npt=10.5-`、`npt=now-`、`npt=0:01:02.5-0:02:00`，为空表示不携带
+// @param scaleValue: 比如`2.0`，为空表示不携带
+func ParsePlayControl(rangeValue, scaleValue string) (ctrl PlayControl, err error) {
+	if rangeValue != "" {
+		if ctrl.Start, ctrl.End, err = parseNptRange(rangeValue); err != nil {
+			return
+		}
+		ctrl.RangeFlag = true
+	}
+	if scaleValue != "" {
+		ctrl.Scale, err = strconv.ParseFloat(strings.TrimSpace(scaleValue), 64)
+		if err == nil && ctrl.Scale == 0 {
+			err = nazaerrors.Wrap(base.ErrRtsp, "invalid scale 0")
+		}
+	}
+	return
+}
+
+// RangeHeader 返回Range头的值，RangeFlag为false时返回空
+func (c PlayControl) RangeHeader() string {
+	if !c.RangeFlag {
+		return ""
+	}
+	start := "now"
+	if c.Start != NptNow {
+		start = strconv.FormatFloat(c.Start, 'f', 3, 64)
+	}
+	end := ""
+	if c.End != NptEnd {
+		end = strconv.FormatFloat(c.End, 'f', 3, 64)
+	}
+	return fmt.Sprintf("npt=%s-%s", start, end)
+}
+
+// ScaleHeader 返回Scale头的值，Scale为0时返回空
+func (c PlayControl) ScaleHeader() string {
+	if c.Scale == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(c.Scale, 'f', -1, 64)
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+func parseNptRange(v string) (start, end float64, err error) {
+	// 可能携带`;time=`参数，忽略
+	v = strings.TrimSpace(strings.Split(v, ";")[0])
+	if !strings.HasPrefix(v, "npt=") {
+		return 0, 0, nazaerrors.Wrap(base.ErrRtsp, "range not npt. range="+v)
+	}
+	items := strings.SplitN(v[len("npt="):], "-", 2)
+	if len(items) != 2 {
+		return 0, 0, nazaerrors.Wrap(base.ErrRtsp, "invalid npt range. range="+v)
+	}
+
+	// `npt=-20`的形式等价于从0开始
+	start, end = 0, NptEnd
+	if s := strings.TrimSpace(items[0]); s == "now" {
+		start = NptNow
+	} else if s != "" {
+		if start, err = parseNptTime(s); err != nil {
+			return
+		}
+	}
+	if s := strings.TrimSpace(items[1]); s != "" {
+		if end, err = parseNptTime(s); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// parseNptTime 支持`npt-sec`（比如`10.5`）以及`npt-hhmmss`（比如`0:01:02.5`）两种格式
+func parseNptTime(s string) (float64, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 1 && len(parts) != 3 {
+		return 0, nazaerrors.Wrap(base.ErrRtsp, "invalid npt time. time="+s)
+	}
+	var sec float64
+	for _, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil || v < 0 {
+			return 0, nazaerrors.Wrap(base.ErrRtsp, "invalid npt time. time="+s)
+		}
+		sec = sec*60 + v
+	}
+	return sec, nil
+}
@@ -35,8 +35,10 @@ const (
 	MethodSetup        = "SETUP"
 	MethodRecord       = "RECORD"
 	MethodPlay         = "PLAY"
+	MethodPause        = "PAUSE"
 	MethodTeardown     = "TEARDOWN"
 	MethodGetParameter = "GET_PARAMETER"
+	MethodSetParameter = "SET_PARAMETER"
 )
 
 const (
@@ -48,6 +50,7 @@ const (
 	HeaderTransport       = "Transport"
 	HeaderSession         = "Session"
 	HeaderRange           = "Range"
+	HeaderScale           = "Scale"
 	HeaderWwwAuthenticate = "WWW-Authenticate"
 	HeaderAuthorization   = "Authorization"
 	HeaderPublic          = "Public"
@@ -82,11 +85,20 @@ var (
 	// TODO chef: 参考协议标准，不要使用固定值
 	sessionId = "191201771"
 
+	// defaultServerSessionTimeoutSec 服务端在SETUP回复的Session头中携带的超时时间，见 ServerTransportConfig.SessionTimeoutSec
+	defaultServerSessionTimeoutSec = 60
+
 	minServerPort = uint16(30000)
 	maxServerPort = uint16(60000)
 
 	unpackerItemMaxSize = 1024
 
+	// rtpPacketHistorySize UDP模式的 BaseOutSession 为响应nack重传，每路流缓存的最近发送的rtp包的数量
+	rtpPacketHistorySize = 512
+
+	// rtcpSrIntervalMs BaseOutSession 发送rtcp sr的间隔
+	rtcpSrIntervalMs = 5000
+
 	serverCommandSessionReadBufSize   = 256
 	serverCommandSessionWriteChanSize = 1024
 
@@ -108,8 +120,8 @@ type IInterleavedPacketWriter interface {
 
 var availUdpConnPool *nazanet.AvailUdpConnPool
 
-// 传入远端IP，RtpPort，RtcpPort，创建两个对应的RTP和RTCP的UDP连接对象，以及对应的本端端口
-func initConnWithClientPort(rHost string, rRtpPort, rRtcpPort uint16) (rtpConn, rtcpConn *nazanet.UdpConnection, lRtpPort, lRtcpPort uint16, err error) {
+// 传入远端IP，RtpPort，RtcpPort，从 pool 中创建两个对应的RTP和RTCP的UDP连接对象，以及对应的本端端口
+func initConnWithClientPort(pool *nazanet.AvailUdpConnPool, rHost string, rRtpPort, rRtcpPort uint16) (rtpConn, rtcpConn *nazanet.UdpConnection, lRtpPort, lRtcpPort uint16, err error) {
 	// NOTICE
 	// 处理Pub时，
 	// 一路流的rtp端口和rtcp端口必须不同。
@@ -119,7 +131,7 @@ func initConnWithClientPort(rHost string, rRtpPort, rRtcpPort uint16) (rtpConn,
 	// 我目前在Acquire2这个函数里做了保证，绑定两个可用且连续的端口。
 
 	var rtpc, rtcpc *net.UDPConn
-	rtpc, lRtpPort, rtcpc, lRtcpPort, err = availUdpConnPool.Acquire2()
+	rtpc, lRtpPort, rtcpc, lRtcpPort, err = pool.Acquire2()
 	if err != nil {
 		return
 	}
@@ -140,6 +152,21 @@ func initConnWithClientPort(rHost string, rRtpPort, rRtcpPort uint16) (rtpConn,
 	return
 }
 
+// parseSessionHeader 解析Session头，比如`191201771;timeout=60`
+//
+// @return timeoutSec: 没有timeout参数时返回0
+func parseSessionHeader(v string) (id string, timeoutSec int) {
+	items := strings.Split(v, ";")
+	id = strings.TrimSpace(items[0])
+	for _, item := range items[1:] {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) == 2 && kv[0] == "timeout" {
+			timeoutSec, _ = strconv.Atoi(kv[1])
+		}
+	}
+	return
+}
+
 // 从setup消息的header中解析rtp rtcp channel
 func parseRtpRtcpChannel(setupTransport string) (rtp, rtcp uint16, err error) {
 	return parseTransport(setupTransport, TransportFieldInterleaved)
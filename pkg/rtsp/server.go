@@ -11,6 +11,10 @@ package rtsp
 import (
 	"crypto/tls"
 	"net"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/lal/pkg/rtprtcp"
+	"github.com/q191201771/naza/pkg/nazanet"
 )
 
 type IServerObserver interface {
@@ -50,6 +54,18 @@ type IServerObserver interface {
 	OnDelRtspSubSession(session *SubSession)
 }
 
+// IServerPlayControlObserver 可选接口，IServerObserver 的实现方同时实现该接口时，才支持PAUSE以及播放过程中的PLAY，
+// 否则回复501 Not Implemented
+type IServerPlayControlObserver interface {
+	// OnRtspSubSessionPlayControl
+	//
+	// @brief 收到PAUSE，以及PLAY之后再次收到PLAY（恢复播放、seek、调整倍速）时回调
+	//        通过 SubSession.IsPaused 以及 SubSession.PlayControl 获取控制参数
+	// @return 如果返回非nil，则回复对端错误，比如直播流不支持倍速
+	//
+	OnRtspSubSessionPlayControl(session *SubSession) error
+}
+
 type ServerAuthConfig struct {
 	AuthEnable bool   `json:"auth_enable"`
 	AuthMethod int    `json:"auth_method"`
@@ -57,22 +73,75 @@ type ServerAuthConfig struct {
 	PassWord   string `json:"password"`
 }
 
+const (
+	TransportPolicyAll = "all" // 不限制，TCP（interleaved）和UDP都可以，为空时也表示不限制
+	TransportPolicyTcp = "tcp" // 只允许TCP（interleaved），比如拉流端在NAT后面
+	TransportPolicyUdp = "udp" // 只允许UDP
+)
+
+// ServerTransportConfig 服务端SETUP阶段的传输方式策略，pub和sub分开控制
+//
+// 客户端请求的传输方式不被允许时，回复461 Unsupported Transport，客户端可以换一种传输方式再次SETUP
+type ServerTransportConfig struct {
+	PubTransport string `json:"pub_transport"` // 取值见 TransportPolicyAll 等
+	SubTransport string `json:"sub_transport"`
+
+	// UDP模式下本端rtp、rtcp端口的范围，为0时使用默认范围 [minServerPort, maxServerPort]
+	PubUdpPortMin uint16 `json:"pub_udp_port_min"`
+	PubUdpPortMax uint16 `json:"pub_udp_port_max"`
+	SubUdpPortMin uint16 `json:"sub_udp_port_min"`
+	SubUdpPortMax uint16 `json:"sub_udp_port_max"`
+
+	// SymmetricRtpFlag UDP模式下，锁定对端发来的第一个rtp（rtcp）包的源地址：
+	// pub丢弃其他地址的包，sub往该地址发送，而不是往SETUP中client_port对应的地址发送，用于对端在NAT后面的场景
+	SymmetricRtpFlag bool `json:"symmetric_rtp_flag"`
+
+	// SymmetricRtpAnyIpFlag 默认只锁定和RTSP信令连接相同ip的源地址，为true时锁定任意ip的第一个包的源地址，
+	// 用于对端的媒体数据和信令从不同出口ip发出的场景，见 udpLatch
+	SymmetricRtpAnyIpFlag bool `json:"symmetric_rtp_any_ip_flag"`
+
+	// SessionTimeoutSec 在SETUP回复的Session头中告知对端的超时时间，为0时使用默认值60
+	// UDP模式下，超过该时间没有收到对端的任何信令（比如GET_PARAMETER、SET_PARAMETER、OPTIONS）以及rtp、rtcp包，关闭session
+	SessionTimeoutSec int `json:"session_timeout_sec"`
+
+	// SrtpEnable 是否在sub的DESCRIBE回复中携带服务端生成的a=crypto，拉流端SETUP使用RTP/SAVP时，rtp、rtcp使用SRTP加密
+	// pub不受该配置影响，推流端在ANNOUNCE中携带a=crypto并使用RTP/SAVP时即使用SRTP
+	SrtpEnable bool `json:"srtp_enable"`
+
+	// SrtpProfile 服务端生成密钥时使用的crypto-suite，取值见 rtprtcp.SrtpProfileAesCm128HmacSha1_80 等，为空时使用AES_CM_128_HMAC_SHA1_80
+	SrtpProfile string `json:"srtp_profile"`
+
+	// FecEnable 是否在sub的DESCRIBE回复中携带ulpfec，拉流端SETUP使用UDP时，发送rfc5109 ULPFEC包
+	// pub不受该配置影响，推流端在ANNOUNCE中携带ulpfec时即使用fec包恢复丢失的媒体包
+	FecEnable bool `json:"fec_enable"`
+
+	// FecGroupSize 每多少个媒体包发送一个fec包，取值范围[1, 48]，为0时使用默认值10
+	FecGroupSize int `json:"fec_group_size"`
+}
+
 type Server struct {
 	addr     string
 	observer IServerObserver
 
-	ln   net.Listener
-	auth ServerAuthConfig
+	ln        net.Listener
+	auth      ServerAuthConfig
+	transport *serverTransport
 }
 
-func NewServer(addr string, observer IServerObserver, auth ServerAuthConfig) *Server {
+func NewServer(addr string, observer IServerObserver, auth ServerAuthConfig, transport ServerTransportConfig) *Server {
 	return &Server{
-		addr:     addr,
-		observer: observer,
-		auth:     auth,
+		addr:      addr,
+		observer:  observer,
+		auth:      auth,
+		transport: newServerTransport(transport),
 	}
 }
 
+// ShareTransport 见 HttpTunnelServer.ShareTransport
+func (s *Server) ShareTransport(server *Server) {
+	s.transport = server.transport
+}
+
 func (s *Server) Listen() (err error) {
 	s.ln, err = net.Listen("tcp", s.addr)
 	if err != nil {
@@ -129,6 +198,19 @@ func (s *Server) OnNewRtspSubSessionPlay(session *SubSession) error {
 	return s.observer.OnNewRtspSubSessionPlay(session)
 }
 
+func (s *Server) OnRtspSubSessionPlayControl(session *SubSession) error {
+	return onPlayControl(s.observer, session)
+}
+
+// onPlayControl 供 Server 等包装了 IServerObserver 的结构体使用，observer没有实现 IServerPlayControlObserver 时返回 base.ErrRtspNotImplemented
+func onPlayControl(observer IServerObserver, session *SubSession) error {
+	o, ok := observer.(IServerPlayControlObserver)
+	if !ok {
+		return base.ErrRtspNotImplemented
+	}
+	return o.OnRtspSubSessionPlayControl(session)
+}
+
 func (s *Server) OnDelRtspPubSession(session *PubSession) {
 	s.observer.OnDelRtspPubSession(session)
 }
@@ -139,19 +221,105 @@ func (s *Server) OnDelRtspSubSession(session *SubSession) {
 
 // ---------------------------------------------------------------------------------------------------------------------
 
-func (s *Server) handleTcpConnect(conn net.Conn) {
-	session := NewServerCommandSession(s, conn, s.auth, false, "")
-	s.observer.OnNewRtspSessionConnect(session)
-
+// runServerCommandSession Server、WebsocketServer、HttpTunnelServer共用，阻塞直到session结束，并通知上层销毁
+func runServerCommandSession(observer IServerObserver, session *ServerCommandSession) {
 	err := session.RunLoop()
 	Log.Info(err)
 
 	if session.pubSession != nil {
-		s.observer.OnDelRtspPubSession(session.pubSession)
+		observer.OnDelRtspPubSession(session.pubSession)
 		_ = session.pubSession.Dispose()
 	} else if session.subSession != nil {
-		s.observer.OnDelRtspSubSession(session.subSession)
+		observer.OnDelRtspSubSession(session.subSession)
 		_ = session.subSession.Dispose()
 	}
-	s.observer.OnDelRtspSession(session)
+	observer.OnDelRtspSession(session)
+}
+
+// serverTransport 同一个Server下的所有session共享
+type serverTransport struct {
+	conf    ServerTransportConfig
+	pubPool *nazanet.AvailUdpConnPool
+	subPool *nazanet.AvailUdpConnPool
+}
+
+func newServerTransport(conf ServerTransportConfig) *serverTransport {
+	t := &serverTransport{
+		conf: conf,
+	}
+	if conf.PubUdpPortMin != 0 && conf.PubUdpPortMax > conf.PubUdpPortMin {
+		t.pubPool = nazanet.NewAvailUdpConnPool(conf.PubUdpPortMin, conf.PubUdpPortMax)
+	}
+	if conf.SubUdpPortMin != 0 && conf.SubUdpPortMax > conf.SubUdpPortMin {
+		t.subPool = nazanet.NewAvailUdpConnPool(conf.SubUdpPortMin, conf.SubUdpPortMax)
+	}
+	return t
+}
+
+func (t *serverTransport) allow(isPub bool, isInterleaved bool) bool {
+	policy := t.conf.SubTransport
+	if isPub {
+		policy = t.conf.PubTransport
+	}
+	switch policy {
+	case TransportPolicyTcp:
+		return isInterleaved
+	case TransportPolicyUdp:
+		return !isInterleaved
+	}
+	return true
+}
+
+func (t *serverTransport) srtpProfile() string {
+	if t.conf.SrtpProfile == "" {
+		return rtprtcp.SrtpProfileAesCm128HmacSha1_80
+	}
+	return t.conf.SrtpProfile
+}
+
+// fecGroupSize 没有开启fec时返回0
+func (t *serverTransport) fecGroupSize() int {
+	if !t.conf.FecEnable {
+		return 0
+	}
+	if t.conf.FecGroupSize <= 0 {
+		return defaultFecGroupSize
+	}
+	return t.conf.FecGroupSize
+}
+
+// symmetricRtpIp udpLatch 允许锁定的源ip，不限制时返回nil
+//
+// @param host: RTSP信令连接的对端ip
+func (t *serverTransport) symmetricRtpIp(host string) net.IP {
+	if t.conf.SymmetricRtpAnyIpFlag {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+func (t *serverTransport) sessionTimeoutSec() int {
+	if t.conf.SessionTimeoutSec <= 0 {
+		return defaultServerSessionTimeoutSec
+	}
+	return t.conf.SessionTimeoutSec
+}
+
+func (t *serverTransport) udpConnPool(isPub bool) *nazanet.AvailUdpConnPool {
+	pool := t.subPool
+	if isPub {
+		pool = t.pubPool
+	}
+	if pool == nil {
+		return availUdpConnPool
+	}
+	return pool
+}
+
+func (s *Server) handleTcpConnect(conn net.Conn) {
+	session := NewServerCommandSession(s, conn, s.auth, false, "")
+	session.transport = s.transport
+	s.observer.OnNewRtspSessionConnect(session)
+
+	runServerCommandSession(s.observer, session)
 }
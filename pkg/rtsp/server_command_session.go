@@ -11,9 +11,11 @@ package rtsp
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"net"
 	"strings"
+	"time"
 
 	"github.com/q191201771/naza/pkg/nazaerrors"
 
@@ -63,6 +65,7 @@ type ServerCommandSession struct {
 	stat         base.StatSession
 	authConf     ServerAuthConfig
 	auth         Auth
+	transport    *serverTransport
 
 	pubSession *PubSession
 	subSession *SubSession
@@ -70,6 +73,9 @@ type ServerCommandSession struct {
 	describeSeq  string // only for sub session
 	isWebSocket  bool
 	websocketKey string
+
+	udpKeepaliveCheckFlag bool          // only for udp mode
+	closedChan            chan struct{} // 信令循环退出时关闭
 }
 
 func NewServerCommandSession(observer IServerCommandSessionObserver, conn net.Conn, authConf ServerAuthConfig, iswebsocket bool, websocketKey string) *ServerCommandSession {
@@ -84,6 +90,8 @@ func NewServerCommandSession(observer IServerCommandSessionObserver, conn net.Co
 		}),
 		isWebSocket:  iswebsocket,
 		websocketKey: websocketKey,
+		transport:    newServerTransport(ServerTransportConfig{}),
+		closedChan:   make(chan struct{}),
 	}
 
 	Log.Infof("[%s] lifecycle new rtsp ServerSession. session=%p, laddr=%s, raddr=%s, iswebsocket:%v", uk, s, conn.LocalAddr().String(), conn.RemoteAddr().String(), iswebsocket)
@@ -233,6 +241,15 @@ Loop:
 		case MethodPlay:
 			// sub
 			handleMsgErr = session.handlePlay(requestCtx)
+		case MethodPause:
+			// sub
+			handleMsgErr = session.handlePause(requestCtx)
+		case MethodGetParameter:
+			// pub, sub
+			handleMsgErr = session.handleGetParameter(requestCtx)
+		case MethodSetParameter:
+			// pub, sub
+			handleMsgErr = session.handleSetParameter(requestCtx)
 		case MethodTeardown:
 			// pub
 			handleMsgErr = session.handleTeardown(requestCtx)
@@ -247,6 +264,7 @@ Loop:
 	}
 
 	_ = session.conn.Close()
+	close(session.closedChan)
 	Log.Debugf("[%s] < handleTcpConnect.", session.uniqueKey)
 
 	return nil
@@ -334,6 +352,25 @@ func (session *ServerCommandSession) handleDescribe(requestCtx nazahttp.HttpReqM
 }
 
 func (session *ServerCommandSession) feedSdp(rawSdp []byte) error {
+	if session.transport.conf.SrtpEnable {
+		// 每个拉流端使用单独的密钥
+		audioCrypto, err1 := generateSrtpCrypto(session.transport.srtpProfile())
+		videoCrypto, err2 := generateSrtpCrypto(session.transport.srtpProfile())
+		if err := nazaerrors.CombineErrors(err1, err2); err != nil {
+			Log.Errorf("[%s] generate srtp crypto failed. err=%+v", session.uniqueKey, err)
+			return err
+		}
+		rawSdp = sdp.AppendACrypto(rawSdp, &audioCrypto, &videoCrypto)
+	}
+	if session.transport.conf.FecEnable {
+		if sdpCtx, err := sdp.ParseSdp2LogicContext(rawSdp); err == nil {
+			audioPt, videoPt := chooseFecPayloadType(sdpCtx)
+			rawSdp = sdp.AppendFecRtpMap(rawSdp, audioPt, videoPt)
+			out := session.subSession.baseOutSession
+			rawSdp = sdp.AppendFecSsrc(rawSdp, out.audioFecSsrc, out.videoFecSsrc, fecCname)
+		}
+	}
+
 	sdpCtx, _ := sdp.ParseSdp2LogicContext(rawSdp)
 	session.subSession.InitWithSdp(sdpCtx)
 
@@ -388,30 +425,51 @@ func (session *ServerCommandSession) handleSetup(requestCtx nazahttp.HttpReqMsgC
 	remoteAddr := session.conn.RemoteAddr().String()
 	host, _, _ := net.SplitHostPort(remoteAddr)
 
+	if session.pubSession == nil && session.subSession == nil {
+		Log.Errorf("[%s] setup but session not exist.", session.uniqueKey)
+		return nazaerrors.Wrap(base.ErrRtsp)
+	}
+	isPub := session.pubSession != nil
+
 	// 是否为interleaved模式
 	htv := requestCtx.Headers.Get(HeaderTransport)
-	if strings.Contains(htv, TransportFieldInterleaved) {
+	isInterleaved := strings.Contains(htv, TransportFieldInterleaved)
+
+	if !session.transport.allow(isPub, isInterleaved) {
+		Log.Warnf("[%s] setup transport not allowed. transport=%s, isPub=%v", session.uniqueKey, htv, isPub)
+		return session.writeResp(PackResponseUnsupportedTransport(requestCtx.Headers.Get(HeaderCSeq)))
+	}
+
+	if isSrtpTransport(htv) {
+		var err error
+		if isPub {
+			err = session.pubSession.baseInSession.SetupSrtp(requestCtx.Uri)
+		} else {
+			err = session.subSession.baseOutSession.SetupSrtp(requestCtx.Uri)
+		}
+		if err != nil {
+			Log.Warnf("[%s] setup srtp failed. transport=%s, err=%+v", session.uniqueKey, htv, err)
+			return session.writeResp(PackResponseUnsupportedTransport(requestCtx.Headers.Get(HeaderCSeq)))
+		}
+	}
+
+	if isInterleaved {
 		rtpChannel, rtcpChannel, err := parseRtpRtcpChannel(htv)
 		if err != nil {
 			Log.Errorf("[%s] parse rtp rtcp channel error. err=%+v", session.uniqueKey, err)
 			return err
 		}
-		if session.pubSession != nil {
-			if err := session.pubSession.SetupWithChannel(requestCtx.Uri, int(rtpChannel), int(rtcpChannel)); err != nil {
-				Log.Errorf("[%s] setup channel error. err=%+v", session.uniqueKey, err)
-				return err
-			}
-		} else if session.subSession != nil {
-			if err := session.subSession.SetupWithChannel(requestCtx.Uri, int(rtpChannel), int(rtcpChannel)); err != nil {
-				Log.Errorf("[%s] setup channel error. err=%+v", session.uniqueKey, err)
-				return err
-			}
+		if isPub {
+			err = session.pubSession.SetupWithChannel(requestCtx.Uri, int(rtpChannel), int(rtcpChannel))
 		} else {
-			Log.Errorf("[%s] setup but session not exist.", session.uniqueKey)
-			return nazaerrors.Wrap(base.ErrRtsp)
+			err = session.subSession.SetupWithChannel(requestCtx.Uri, int(rtpChannel), int(rtcpChannel))
+		}
+		if err != nil {
+			Log.Errorf("[%s] setup channel error. err=%+v", session.uniqueKey, err)
+			return err
 		}
 
-		resp := PackResponseSetup(requestCtx.Headers.Get(HeaderCSeq), htv)
+		resp := PackResponseSetup(requestCtx.Headers.Get(HeaderCSeq), htv, session.transport.sessionTimeoutSec())
 		if session.isWebSocket {
 			respLen := len([]byte(resp))
 			session.writeWsFrameHeader(respLen)
@@ -420,12 +478,12 @@ func (session *ServerCommandSession) handleSetup(requestCtx nazahttp.HttpReqMsgC
 		return err
 	}
 
-	rRtpPort, rRtcpPort, err := parseClientPort(requestCtx.Headers.Get(HeaderTransport))
+	rRtpPort, rRtcpPort, err := parseClientPort(htv)
 	if err != nil {
 		Log.Errorf("[%s] parseClientPort failed. err=%+v", session.uniqueKey, err)
 		return err
 	}
-	rtpConn, rtcpConn, lRtpPort, lRtcpPort, err := initConnWithClientPort(host, rRtpPort, rRtcpPort)
+	rtpConn, rtcpConn, lRtpPort, lRtcpPort, err := initConnWithClientPort(session.transport.udpConnPool(isPub), host, rRtpPort, rRtcpPort)
 	if err != nil {
 		Log.Errorf("[%s] initConnWithClientPort failed. err=%+v", session.uniqueKey, err)
 		return err
@@ -433,24 +491,34 @@ func (session *ServerCommandSession) handleSetup(requestCtx nazahttp.HttpReqMsgC
 	Log.Debugf("[%s] init conn. lRtpPort=%d, lRtcpPort=%d, rRtpPort=%d, rRtcpPort=%d",
 		session.uniqueKey, lRtpPort, lRtcpPort, rRtpPort, rRtcpPort)
 
-	if session.pubSession != nil {
+	if isPub {
+		session.pubSession.baseInSession.symmetricRtpFlag = session.transport.conf.SymmetricRtpFlag
+		session.pubSession.baseInSession.symmetricRtpIp = session.transport.symmetricRtpIp(host)
 		if err = session.pubSession.SetupWithConn(requestCtx.Uri, rtpConn, rtcpConn); err != nil {
 			Log.Errorf("[%s] setup conn error. err=%+v", session.uniqueKey, err)
 			return err
 		}
 		htv = fmt.Sprintf(HeaderTransportServerRecordTmpl, rRtpPort, rRtcpPort, lRtpPort, lRtcpPort)
-	} else if session.subSession != nil {
+	} else {
+		session.subSession.baseOutSession.symmetricRtpFlag = session.transport.conf.SymmetricRtpFlag
+		session.subSession.baseOutSession.symmetricRtpIp = session.transport.symmetricRtpIp(host)
+		session.subSession.baseOutSession.fecGroupSize = session.transport.fecGroupSize()
 		if err = session.subSession.SetupWithConn(requestCtx.Uri, rtpConn, rtcpConn); err != nil {
 			Log.Errorf("[%s] setup conn error. err=%+v", session.uniqueKey, err)
 			return err
 		}
 		htv = fmt.Sprintf(HeaderTransportServerPlayTmpl, rRtpPort, rRtcpPort, lRtpPort, lRtcpPort)
-	} else {
-		Log.Errorf("[%s] setup but session not exist.", session.uniqueKey)
-		return nazaerrors.Wrap(base.ErrRtsp)
+	}
+	if isSrtpTransport(requestCtx.Headers.Get(HeaderTransport)) {
+		htv = toSrtpTransport(htv)
+	}
+
+	if !session.udpKeepaliveCheckFlag {
+		session.udpKeepaliveCheckFlag = true
+		go session.runUdpKeepaliveCheck(isPub)
 	}
 
-	resp := PackResponseSetup(requestCtx.Headers.Get(HeaderCSeq), htv)
+	resp := PackResponseSetup(requestCtx.Headers.Get(HeaderCSeq), htv, session.transport.sessionTimeoutSec())
 	if session.isWebSocket {
 		respLen := len([]byte(resp))
 		session.writeWsFrameHeader(respLen)
@@ -475,13 +543,87 @@ func (session *ServerCommandSession) handlePlay(requestCtx nazahttp.HttpReqMsgCt
 		return base.ErrRtsp
 	}
 
+	cseq := requestCtx.Headers.Get(HeaderCSeq)
+	ctrl, err := ParsePlayControl(requestCtx.Headers.Get(HeaderRange), requestCtx.Headers.Get(HeaderScale))
+	if err != nil {
+		Log.Warnf("[%s] parse range or scale failed. err=%+v", session.uniqueKey, err)
+		return session.writeResp(PackResponseInvalidRange(cseq))
+	}
+	prevCtrl := session.subSession.playControl
+	session.subSession.playControl = ctrl
+
+	stage := session.subSession.Stage.Load()
+	if stage == SubSessionStageReadPlay || stage == SubSessionStagePause {
+		// 恢复播放、seek、调整倍速
+		if err := session.onPlayControl(); err != nil {
+			Log.Warnf("[%s] play control refused by observer. ctrl=%+v, err=%+v", session.uniqueKey, ctrl, err)
+			session.subSession.playControl = prevCtrl
+			if errors.Is(err, base.ErrRtspNotImplemented) {
+				return session.writeResp(PackResponseNotImplemented(cseq))
+			}
+			return session.writeResp(PackResponseInvalidRange(cseq))
+		}
+		session.subSession.Stage.Store(SubSessionStageReadPlay)
+		return session.writeResp(PackResponsePlay(cseq, session.subSession.playControl))
+	}
+
 	session.subSession.Stage.Store(SubSessionStageReadPlay)
 
 	// TODO(chef): [opt] 上层关闭，可以考虑回复非200状态码再关闭
 	if err := session.observer.OnNewRtspSubSessionPlay(session.subSession); err != nil {
 		return err
 	}
-	resp := PackResponsePlay(requestCtx.Headers.Get(HeaderCSeq))
+	return session.writeResp(PackResponsePlay(cseq, session.subSession.playControl))
+}
+
+func (session *ServerCommandSession) handlePause(requestCtx nazahttp.HttpReqMsgCtx) error {
+	Log.Infof("[%s] < R PAUSE", session.uniqueKey)
+
+	cseq := requestCtx.Headers.Get(HeaderCSeq)
+
+	// 推流，以及还没有开始播放时，不支持PAUSE
+	if session.subSession == nil {
+		return session.writeResp(PackResponseMethodNotValidInThisState(cseq))
+	}
+	stage := session.subSession.Stage.Load()
+	if stage != SubSessionStageReadPlay && stage != SubSessionStagePause {
+		return session.writeResp(PackResponseMethodNotValidInThisState(cseq))
+	}
+
+	session.subSession.Stage.Store(SubSessionStagePause)
+	if err := session.onPlayControl(); err != nil {
+		Log.Warnf("[%s] pause refused by observer. err=%+v", session.uniqueKey, err)
+		session.subSession.Stage.Store(stage)
+		if errors.Is(err, base.ErrRtspNotImplemented) {
+			return session.writeResp(PackResponseNotImplemented(cseq))
+		}
+		return session.writeResp(PackResponseMethodNotValidInThisState(cseq))
+	}
+	return session.writeResp(PackResponsePause(cseq))
+}
+
+// onPlayControl observer没有实现 IServerPlayControlObserver 时返回 base.ErrRtspNotImplemented
+func (session *ServerCommandSession) onPlayControl() error {
+	observer, ok := session.observer.(IServerPlayControlObserver)
+	if !ok {
+		return base.ErrRtspNotImplemented
+	}
+	return observer.OnRtspSubSessionPlayControl(session.subSession)
+}
+
+func (session *ServerCommandSession) handleGetParameter(requestCtx nazahttp.HttpReqMsgCtx) error {
+	Log.Debugf("[%s] < R GET_PARAMETER", session.uniqueKey)
+	return session.writeResp(PackResponseGetParameter(requestCtx.Headers.Get(HeaderCSeq)))
+}
+
+func (session *ServerCommandSession) handleSetParameter(requestCtx nazahttp.HttpReqMsgCtx) error {
+	Log.Debugf("[%s] < R SET_PARAMETER", session.uniqueKey)
+	return session.writeResp(PackResponseSetParameter(requestCtx.Headers.Get(HeaderCSeq), requestCtx.Body))
+}
+
+func (session *ServerCommandSession) handleTeardown(requestCtx nazahttp.HttpReqMsgCtx) error {
+	Log.Infof("[%s] < R TEARDOWN", session.uniqueKey)
+	resp := PackResponseTeardown(requestCtx.Headers.Get(HeaderCSeq))
 	if session.isWebSocket {
 		respLen := len([]byte(resp))
 		session.writeWsFrameHeader(respLen)
@@ -490,9 +632,46 @@ func (session *ServerCommandSession) handlePlay(requestCtx nazahttp.HttpReqMsgCt
 	return err
 }
 
-func (session *ServerCommandSession) handleTeardown(requestCtx nazahttp.HttpReqMsgCtx) error {
-	Log.Infof("[%s] < R TEARDOWN", session.uniqueKey)
-	resp := PackResponseTeardown(requestCtx.Headers.Get(HeaderCSeq))
+// runUdpKeepaliveCheck UDP模式下，rtp、rtcp不经过信令连接，对端异常退出时信令连接可能一直不断开，
+// 所以信令连接和rtp、rtcp都没有收到数据超过 ServerTransportConfig.SessionTimeoutSec 时，关闭信令连接，上层随之销毁session
+func (session *ServerCommandSession) runUdpKeepaliveCheck(isPub bool) {
+	timeout := time.Duration(session.transport.sessionTimeoutSec()) * time.Second
+	t := time.NewTicker(timeout / 4)
+	defer t.Stop()
+
+	var stat *base.BasicSessionStat
+	if isPub {
+		stat = &session.pubSession.baseInSession.sessionStat
+	} else {
+		stat = &session.subSession.baseOutSession.sessionStat
+	}
+	readBytesSum := func() uint64 {
+		return session.conn.GetStat().ReadBytesSum + stat.GetReadBytesSum()
+	}
+
+	prev := readBytesSum()
+	lastActive := time.Now()
+	for {
+		select {
+		case <-session.closedChan:
+			return
+		case now := <-t.C:
+			curr := readBytesSum()
+			if curr != prev {
+				prev = curr
+				lastActive = now
+				continue
+			}
+			if now.Sub(lastActive) >= timeout {
+				Log.Warnf("[%s] udp session keepalive timeout. timeout=%v", session.uniqueKey, timeout)
+				_ = session.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+func (session *ServerCommandSession) writeResp(resp string) error {
 	if session.isWebSocket {
 		respLen := len([]byte(resp))
 		session.writeWsFrameHeader(respLen)
@@ -13,6 +13,7 @@ import (
 	"github.com/q191201771/naza/pkg/nazanet"
 
 	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/lal/pkg/rtprtcp"
 	"github.com/q191201771/lal/pkg/sdp"
 )
 
@@ -66,6 +67,10 @@ func (session *PubSession) GetSdp() sdp.LogicContext {
 	return session.baseInSession.GetSdp()
 }
 
+func (session *PubSession) GetExtensionRegistry() (audio, video *rtprtcp.RtpExtensionRegistry) {
+	return session.baseInSession.GetExtensionRegistry()
+}
+
 func (session *PubSession) HandleInterleavedPacket(b []byte, channel int) {
 	session.baseInSession.HandleInterleavedPacket(b, channel)
 }
@@ -23,6 +23,7 @@ const (
 	SubSessionStageReadDescribe int32 = 0 // 初时阶段，已收到 describe
 	SubSessionStageWriteSdp           = 1 // 已发送 sdp
 	SubSessionStageReadPlay           = 2 // 已收到 play
+	SubSessionStagePause              = 3 // 已收到 pause，收到 play 后回到 SubSessionStageReadPlay
 )
 
 type SubSession struct {
@@ -33,6 +34,8 @@ type SubSession struct {
 	ShouldWaitVideoKeyFrame bool
 
 	Stage nazaatomic.Int32 // 见 SubSessionStageReadDescribe 等常量定义
+
+	playControl PlayControl
 }
 
 func NewSubSession(urlCtx base.UrlContext, cmdSession *ServerCommandSession) *SubSession {
@@ -61,6 +64,23 @@ func (session *SubSession) InitWithSdp(sdpCtx sdp.LogicContext) {
 	session.baseOutSession.InitWithSdp(sdpCtx)
 }
 
+// PlayControl 最近一次PLAY信令中的Range和Scale
+//
+// 注意，只在 IServerObserver 的 OnNewRtspSubSessionPlay、OnRtspSubSessionPlayControl 回调中使用
+func (session *SubSession) PlayControl() PlayControl {
+	return session.playControl
+}
+
+// SetPlayControl 在 IServerObserver 的 OnNewRtspSubSessionPlay、OnRtspSubSessionPlayControl 回调中调用，
+// 修改PLAY回复中的Range和Scale，比如直播流不支持seek，回复`npt=now-`
+func (session *SubSession) SetPlayControl(ctrl PlayControl) {
+	session.playControl = ctrl
+}
+
+func (session *SubSession) IsPaused() bool {
+	return session.Stage.Load() == SubSessionStagePause
+}
+
 func (session *SubSession) SetupWithConn(uri string, rtpConn, rtcpConn *nazanet.UdpConnection) error {
 	return session.baseOutSession.SetupWithConn(uri, rtpConn, rtcpConn)
 }
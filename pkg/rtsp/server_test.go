@@ -0,0 +1,408 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtsp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/lal/pkg/rtprtcp"
+	"github.com/q191201771/lal/pkg/sdp"
+	"github.com/q191201771/naza/pkg/assert"
+	"github.com/q191201771/naza/pkg/nazahttp"
+)
+
+var testSdp = "v=0\r\n" +
+	"o=- 0 0 IN IP4 127.0.0.1\r\n" +
+	"s=No Name\r\n" +
+	"c=IN IP4 127.0.0.1\r\n" +
+	"t=0 0\r\n" +
+	"m=video 0 RTP/AVP 96\r\n" +
+	"a=rtpmap:96 H264/90000\r\n" +
+	"a=fmtp:96 packetization-mode=1; sprop-parameter-sets=Z2QAIKzZQMApsBEAAAMAAQAAAwAyDxgxlg==,aOvssiw=; profile-level-id=640020\r\n" +
+	"a=control:streamid=0\r\n"
+
+// testBasicServerObserver 没有实现 IServerPlayControlObserver
+type testBasicServerObserver struct {
+	subSessionChan chan *SubSession
+}
+
+func (o *testBasicServerObserver) OnNewRtspSessionConnect(session *ServerCommandSession) {}
+func (o *testBasicServerObserver) OnDelRtspSession(session *ServerCommandSession)        {}
+func (o *testBasicServerObserver) OnNewRtspPubSession(session *PubSession) error         { return nil }
+func (o *testBasicServerObserver) OnDelRtspPubSession(session *PubSession)               {}
+func (o *testBasicServerObserver) OnNewRtspSubSessionDescribe(session *SubSession) (ok bool, sdp []byte) {
+	o.subSessionChan <- session
+	return true, []byte(testSdp)
+}
+func (o *testBasicServerObserver) OnNewRtspSubSessionPlay(session *SubSession) error { return nil }
+func (o *testBasicServerObserver) OnDelRtspSubSession(session *SubSession)           {}
+
+type testServerObserver struct {
+	testBasicServerObserver
+	onPlayControl func(session *SubSession) error
+}
+
+func newTestServerObserver() *testServerObserver {
+	return &testServerObserver{testBasicServerObserver: testBasicServerObserver{subSessionChan: make(chan *SubSession, 1)}}
+}
+
+func (o *testServerObserver) OnRtspSubSessionPlayControl(session *SubSession) error {
+	if o.onPlayControl != nil {
+		return o.onPlayControl(session)
+	}
+	return nil
+}
+
+func TestUdpLatch(t *testing.T) {
+	ip := net.ParseIP("192.168.1.10")
+	l := newUdpLatch("test", ip)
+	// 其他ip的包不锁定
+	assert.Equal(t, false, l.check(&net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000}))
+	assert.Equal(t, true, l.addr() == nil)
+	// 信令连接的ip，端口不限制
+	assert.Equal(t, true, l.check(&net.UDPAddr{IP: net.ParseIP("192.168.1.10"), Port: 6000}))
+	assert.Equal(t, 6000, l.addr().Port)
+	assert.Equal(t, false, l.check(&net.UDPAddr{IP: net.ParseIP("192.168.1.10"), Port: 6002}))
+
+	l = newUdpLatch("test", nil)
+	assert.Equal(t, true, l.check(&net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000}))
+	assert.Equal(t, false, l.check(&net.UDPAddr{IP: net.ParseIP("192.168.1.10"), Port: 5000}))
+
+	tr := newServerTransport(ServerTransportConfig{})
+	assert.Equal(t, true, ip.Equal(tr.symmetricRtpIp("192.168.1.10")))
+	tr = newServerTransport(ServerTransportConfig{SymmetricRtpAnyIpFlag: true})
+	assert.Equal(t, true, tr.symmetricRtpIp("192.168.1.10") == nil)
+}
+
+func TestServerTransportPolicy(t *testing.T) {
+	tr := newServerTransport(ServerTransportConfig{PubTransport: TransportPolicyTcp, SubTransport: TransportPolicyUdp})
+	assert.Equal(t, true, tr.allow(true, true))
+	assert.Equal(t, false, tr.allow(true, false))
+	assert.Equal(t, false, tr.allow(false, true))
+	assert.Equal(t, true, tr.allow(false, false))
+
+	tr = newServerTransport(ServerTransportConfig{})
+	assert.Equal(t, true, tr.allow(true, false))
+	assert.Equal(t, true, tr.allow(false, true))
+	assert.Equal(t, availUdpConnPool, tr.udpConnPool(true))
+}
+
+// TestServerSetup pub只允许TCP，sub使用独立的端口范围以及对称RTP
+func TestServerSetup(t *testing.T) {
+	observer := newTestServerObserver()
+	s := NewServer("127.0.0.1:0", observer, ServerAuthConfig{}, ServerTransportConfig{
+		PubTransport:     TransportPolicyTcp,
+		SubUdpPortMin:    41000,
+		SubUdpPortMax:    41100,
+		SymmetricRtpFlag: true,
+	})
+	assert.Equal(t, nil, s.Listen())
+	go s.RunLoop()
+	defer s.Dispose()
+
+	addr := s.ln.Addr().String()
+	uri := fmt.Sprintf("rtsp://%s/live/test", addr)
+
+	// pub
+	conn, err := net.Dial("tcp", addr)
+	assert.Equal(t, nil, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	resp := testRequest(t, conn, r, PackRequest(MethodAnnounce, uri, map[string]string{HeaderCSeq: "1", HeaderContentLength: fmt.Sprintf("%d", len(testSdp))}, testSdp))
+	assert.Equal(t, "200", resp.StatusCode)
+	resp = testRequest(t, conn, r, PackRequest(MethodSetup, uri+"/streamid=0", map[string]string{
+		HeaderCSeq:      "2",
+		HeaderTransport: "RTP/AVP/UDP;unicast;client_port=40000-40001;mode=record",
+	}, ""))
+	assert.Equal(t, "461", resp.StatusCode)
+	assert.Equal(t, "2", resp.Headers.Get(HeaderCSeq))
+	resp = testRequest(t, conn, r, PackRequest(MethodSetup, uri+"/streamid=0", map[string]string{
+		HeaderCSeq:      "3",
+		HeaderTransport: "RTP/AVP/TCP;unicast;interleaved=0-1;mode=record",
+	}, ""))
+	assert.Equal(t, "200", resp.StatusCode)
+
+	// sub
+	conn2, err := net.Dial("tcp", addr)
+	assert.Equal(t, nil, err)
+	defer conn2.Close()
+	r2 := bufio.NewReader(conn2)
+
+	resp = testRequest(t, conn2, r2, PackRequest(MethodDescribe, uri, map[string]string{HeaderCSeq: "1"}, ""))
+	assert.Equal(t, "200", resp.StatusCode)
+	subSession := <-observer.subSessionChan
+
+	// client_port是一个不存在的地址，模拟NAT后面的内网端口
+	resp = testRequest(t, conn2, r2, PackRequest(MethodSetup, uri+"/streamid=0", map[string]string{
+		HeaderCSeq:      "2",
+		HeaderTransport: "RTP/AVP/UDP;unicast;client_port=9-10",
+	}, ""))
+	assert.Equal(t, "200", resp.StatusCode)
+	serverRtpPort, _, err := parseTransport(resp.Headers.Get(HeaderTransport), TransportFieldServerPort)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, serverRtpPort >= 41000 && serverRtpPort <= 41100)
+
+	// 打洞包
+	punch, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.Equal(t, nil, err)
+	defer punch.Close()
+	_, err = punch.WriteToUDP(dummyRtpPacket, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: int(serverRtpPort)})
+	assert.Equal(t, nil, err)
+
+	h := rtprtcp.MakeDefaultRtpHeader()
+	h.PacketType = 96
+	pkt := rtprtcp.MakeRtpPacket(h, []byte{0x65, 0x01})
+	buf := make([]byte, 1500)
+	for i := 0; i < 50; i++ {
+		assert.Equal(t, nil, subSession.baseOutSession.WriteRtpPacket(pkt))
+		_ = punch.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+		n, _, err := punch.ReadFromUDP(buf)
+		if err == nil {
+			assert.Equal(t, pkt.Raw, buf[:n])
+			return
+		}
+	}
+	t.Fatal("rtp packet not received by latched addr")
+}
+
+func testRequest(t *testing.T, conn net.Conn, r *bufio.Reader, req string) nazahttp.HttpRespMsgCtx {
+	_, err := conn.Write([]byte(req))
+	assert.Equal(t, nil, err)
+	resp, err := nazahttp.ReadHttpResponseMessage(r)
+	assert.Equal(t, nil, err)
+	return resp
+}
+
+// TestServerKeepalive UDP模式下，保活信令以及超时关闭
+func TestServerKeepalive(t *testing.T) {
+	observer := newTestServerObserver()
+	s := NewServer("127.0.0.1:0", observer, ServerAuthConfig{}, ServerTransportConfig{SessionTimeoutSec: 1})
+	assert.Equal(t, nil, s.Listen())
+	go s.RunLoop()
+	defer s.Dispose()
+
+	addr := s.ln.Addr().String()
+	uri := fmt.Sprintf("rtsp://%s/live/test", addr)
+	conn, err := net.Dial("tcp", addr)
+	assert.Equal(t, nil, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	resp := testRequest(t, conn, r, PackRequest(MethodOptions, uri, map[string]string{HeaderCSeq: "1"}, ""))
+	assert.Equal(t, true, strings.Contains(resp.Headers.Get(HeaderPublic), MethodGetParameter))
+	resp = testRequest(t, conn, r, PackRequest(MethodDescribe, uri, map[string]string{HeaderCSeq: "2"}, ""))
+	assert.Equal(t, "200", resp.StatusCode)
+	<-observer.subSessionChan
+	resp = testRequest(t, conn, r, PackRequest(MethodSetup, uri+"/streamid=0", map[string]string{
+		HeaderCSeq:      "3",
+		HeaderTransport: "RTP/AVP/UDP;unicast;client_port=9-10",
+	}, ""))
+	assert.Equal(t, "200", resp.StatusCode)
+	id, timeoutSec := parseSessionHeader(resp.Headers.Get(HeaderSession))
+	assert.Equal(t, sessionId, id)
+	assert.Equal(t, 1, timeoutSec)
+
+	// 保活期间不会被关闭
+	for i := 0; i < 6; i++ {
+		time.Sleep(250 * time.Millisecond)
+		resp = testRequest(t, conn, r, PackRequest(MethodGetParameter, uri, map[string]string{HeaderCSeq: "4", HeaderSession: sessionId}, ""))
+		assert.Equal(t, "200", resp.StatusCode)
+	}
+	body := "volume: 10\r\n"
+	resp = testRequest(t, conn, r, PackRequest(MethodSetParameter, uri, map[string]string{
+		HeaderCSeq:          "5",
+		HeaderSession:       sessionId,
+		HeaderContentLength: fmt.Sprintf("%d", len(body)),
+	}, body))
+	assert.Equal(t, "451", resp.StatusCode)
+
+	// 停止保活后被关闭
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	_, err = r.ReadByte()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestParseSessionHeader(t *testing.T) {
+	id, timeoutSec := parseSessionHeader("12345678;timeout=30")
+	assert.Equal(t, "12345678", id)
+	assert.Equal(t, 30, timeoutSec)
+	id, timeoutSec = parseSessionHeader("12345678")
+	assert.Equal(t, "12345678", id)
+	assert.Equal(t, 0, timeoutSec)
+}
+
+func TestParsePlayControl(t *testing.T) {
+	ctrl, err := ParsePlayControl("npt=10.5-", "")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, PlayControl{RangeFlag: true, Start: 10.5, End: NptEnd}, ctrl)
+	assert.Equal(t, "npt=10.500-", ctrl.RangeHeader())
+	assert.Equal(t, "", ctrl.ScaleHeader())
+
+	ctrl, err = ParsePlayControl("npt=0:01:02.5-0:02:00;time=19970123T153600Z", "2.0")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, PlayControl{RangeFlag: true, Start: 62.5, End: 120, Scale: 2}, ctrl)
+	assert.Equal(t, "npt=62.500-120.000", ctrl.RangeHeader())
+	assert.Equal(t, "2", ctrl.ScaleHeader())
+
+	ctrl, err = ParsePlayControl("npt=now-", "-1")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, PlayControl{RangeFlag: true, Start: NptNow, End: NptEnd, Scale: -1}, ctrl)
+	assert.Equal(t, "npt=now-", ctrl.RangeHeader())
+
+	ctrl, err = ParsePlayControl("", "")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, PlayControl{}, ctrl)
+	assert.Equal(t, "", ctrl.RangeHeader())
+
+	for _, v := range []string{"clock=19961108T142300Z-", "npt=10", "npt=a-", "npt=1:2-", "npt=-1-"} {
+		_, err = ParsePlayControl(v, "")
+		assert.IsNotNil(t, err)
+	}
+	_, err = ParsePlayControl("", "0")
+	assert.IsNotNil(t, err)
+	_, err = ParsePlayControl("", "fast")
+	assert.IsNotNil(t, err)
+}
+
+func TestServerPlayControl(t *testing.T) {
+	observer := newTestServerObserver()
+	observer.onPlayControl = func(session *SubSession) error {
+		if session.PlayControl().Scale > 4 {
+			return base.ErrRtspScaleNotSupported
+		}
+		return nil
+	}
+	s := NewServer("127.0.0.1:0", observer, ServerAuthConfig{}, ServerTransportConfig{})
+	assert.Equal(t, nil, s.Listen())
+	go s.RunLoop()
+	defer s.Dispose()
+
+	addr := s.ln.Addr().String()
+	uri := fmt.Sprintf("rtsp://%s/live/test", addr)
+	conn, err := net.Dial("tcp", addr)
+	assert.Equal(t, nil, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	// 还没有开始播放时不支持PAUSE
+	resp := testRequest(t, conn, r, PackRequest(MethodPause, uri, map[string]string{HeaderCSeq: "1"}, ""))
+	assert.Equal(t, "455", resp.StatusCode)
+
+	resp = testRequest(t, conn, r, PackRequest(MethodDescribe, uri, map[string]string{HeaderCSeq: "2"}, ""))
+	assert.Equal(t, "200", resp.StatusCode)
+	subSession := <-observer.subSessionChan
+	resp = testRequest(t, conn, r, PackRequest(MethodSetup, uri+"/streamid=0", map[string]string{
+		HeaderCSeq:      "3",
+		HeaderTransport: "RTP/AVP/TCP;unicast;interleaved=0-1",
+	}, ""))
+	assert.Equal(t, "200", resp.StatusCode)
+
+	resp = testRequest(t, conn, r, PackRequest(MethodPlay, uri, map[string]string{HeaderCSeq: "4", HeaderRange: "npt=abc-"}, ""))
+	assert.Equal(t, "457", resp.StatusCode)
+	resp = testRequest(t, conn, r, PackRequest(MethodPlay, uri, map[string]string{HeaderCSeq: "5"}, ""))
+	assert.Equal(t, "200", resp.StatusCode)
+	assert.Equal(t, HeaderRangeDefault, resp.Headers.Get(HeaderRange))
+	assert.Equal(t, false, subSession.IsPaused())
+
+	resp = testRequest(t, conn, r, PackRequest(MethodPause, uri, map[string]string{HeaderCSeq: "6", HeaderSession: sessionId}, ""))
+	assert.Equal(t, "200", resp.StatusCode)
+	assert.Equal(t, true, subSession.IsPaused())
+
+	// 上层拒绝时保持暂停状态
+	resp = testRequest(t, conn, r, PackRequest(MethodPlay, uri, map[string]string{HeaderCSeq: "7", HeaderScale: "8"}, ""))
+	assert.Equal(t, "457", resp.StatusCode)
+	assert.Equal(t, true, subSession.IsPaused())
+
+	resp = testRequest(t, conn, r, PackRequest(MethodPlay, uri, map[string]string{HeaderCSeq: "8", HeaderRange: "npt=30-", HeaderScale: "2"}, ""))
+	assert.Equal(t, "200", resp.StatusCode)
+	assert.Equal(t, "npt=30.000-", resp.Headers.Get(HeaderRange))
+	assert.Equal(t, "2", resp.Headers.Get(HeaderScale))
+	assert.Equal(t, false, subSession.IsPaused())
+	assert.Equal(t, PlayControl{RangeFlag: true, Start: 30, End: NptEnd, Scale: 2}, subSession.PlayControl())
+}
+
+func TestServerPlayControlNotImplemented(t *testing.T) {
+	observer := &testBasicServerObserver{subSessionChan: make(chan *SubSession, 1)}
+	s := NewServer("127.0.0.1:0", observer, ServerAuthConfig{}, ServerTransportConfig{})
+	assert.Equal(t, nil, s.Listen())
+	go s.RunLoop()
+	defer s.Dispose()
+
+	addr := s.ln.Addr().String()
+	uri := fmt.Sprintf("rtsp://%s/live/test", addr)
+	conn, err := net.Dial("tcp", addr)
+	assert.Equal(t, nil, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	resp := testRequest(t, conn, r, PackRequest(MethodDescribe, uri, map[string]string{HeaderCSeq: "1"}, ""))
+	assert.Equal(t, "200", resp.StatusCode)
+	subSession := <-observer.subSessionChan
+	resp = testRequest(t, conn, r, PackRequest(MethodSetup, uri+"/streamid=0", map[string]string{
+		HeaderCSeq:      "2",
+		HeaderTransport: "RTP/AVP/TCP;unicast;interleaved=0-1",
+	}, ""))
+	assert.Equal(t, "200", resp.StatusCode)
+	resp = testRequest(t, conn, r, PackRequest(MethodPlay, uri, map[string]string{HeaderCSeq: "3"}, ""))
+	assert.Equal(t, "200", resp.StatusCode)
+
+	// 上层没有实现 IServerPlayControlObserver
+	resp = testRequest(t, conn, r, PackRequest(MethodPause, uri, map[string]string{HeaderCSeq: "4", HeaderSession: sessionId}, ""))
+	assert.Equal(t, "501", resp.StatusCode)
+	assert.Equal(t, false, subSession.IsPaused())
+	resp = testRequest(t, conn, r, PackRequest(MethodPlay, uri, map[string]string{HeaderCSeq: "5", HeaderScale: "2"}, ""))
+	assert.Equal(t, "501", resp.StatusCode)
+	assert.Equal(t, PlayControl{}, subSession.PlayControl())
+}
+
+type testPullObserver struct{}
+
+func (o *testPullObserver) OnSdp(sdpCtx sdp.LogicContext)     {}
+func (o *testPullObserver) OnRtpPacket(pkt rtprtcp.RtpPacket) {}
+func (o *testPullObserver) OnAvPacket(pkt base.AvPacket)      {}
+
+func TestPullSessionPlayControl(t *testing.T) {
+	observer := newTestServerObserver()
+	s := NewServer("127.0.0.1:0", observer, ServerAuthConfig{}, ServerTransportConfig{})
+	assert.Equal(t, nil, s.Listen())
+	go s.RunLoop()
+	defer s.Dispose()
+
+	pullSession := NewPullSession(&testPullObserver{}, func(option *PullSessionOption) {
+		option.PullTimeoutMs = 2000
+		option.OverTcp = true
+		option.PlayControl = PlayControl{RangeFlag: true, Start: 10, End: NptEnd}
+	})
+	assert.Equal(t, nil, pullSession.Start(fmt.Sprintf("rtsp://%s/live/test", s.ln.Addr().String())))
+	defer pullSession.Dispose()
+	subSession := <-observer.subSessionChan
+	assert.Equal(t, PlayControl{RangeFlag: true, Start: 10, End: NptEnd}, subSession.PlayControl())
+
+	assert.Equal(t, nil, pullSession.Pause())
+	assert.Equal(t, true, subSession.IsPaused())
+
+	ctrl, err := pullSession.Play(PlayControl{Scale: 2})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, float64(2), ctrl.Scale)
+	assert.Equal(t, false, subSession.IsPaused())
+
+	observer.onPlayControl = func(session *SubSession) error {
+		return base.ErrRtspScaleNotSupported
+	}
+	_, err = pullSession.Play(PlayControl{Scale: 8})
+	assert.IsNotNil(t, err)
+}
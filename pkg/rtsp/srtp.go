@@ -0,0 +1,118 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtsp
+
+import (
+	"crypto/rand"
+	"strings"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/lal/pkg/rtprtcp"
+	"github.com/q191201771/lal/pkg/sdp"
+	"github.com/q191201771/naza/pkg/nazaerrors"
+)
+
+// srtp.go
+//
+// SETUP中Transport为RTP/SAVP时，rtp、rtcp使用SRTP、SRTCP加密，密钥通过sdp中的a=crypto（SDES）交换：
+//
+// - pub: 密钥由推流端在ANNOUNCE的sdp中携带
+// - sub: 开启 ServerTransportConfig.SrtpEnable 后，服务端为每个拉流端生成密钥，在DESCRIBE的回复中携带
+//
+// 两个方向使用同一个密钥。由于密钥是明文传输的，应该配合RTSPS使用
+
+const (
+	TransportProtoAvp  = "RTP/AVP"
+	TransportProtoSavp = "RTP/SAVP"
+)
+
+func isSrtpTransport(setupTransport string) bool {
+	return strings.HasPrefix(setupTransport, TransportProtoSavp)
+}
+
+// toSrtpTransport RTP/AVP/UDP;unicast;... -> RTP/SAVP/UDP;unicast;...
+func toSrtpTransport(setupTransport string) string {
+	return strings.Replace(setupTransport, TransportProtoAvp, TransportProtoSavp, 1)
+}
+
+// srtpCryptoOfUri 获取uri对应的媒体的第一个支持的a=crypto
+func srtpCryptoOfUri(sdpCtx sdp.LogicContext, uri string) (sdp.ACrypto, bool) {
+	var l []sdp.ACrypto
+	if sdpCtx.IsAudioUri(uri) {
+		l = sdpCtx.AudioCryptoList
+	} else if sdpCtx.IsVideoUri(uri) {
+		l = sdpCtx.VideoCryptoList
+	}
+	for _, c := range l {
+		if keyLength, saltLength, err := rtprtcp.SrtpMasterKeyLength(c.Suite); err == nil && len(c.KeySalt) == keyLength+saltLength {
+			return c, true
+		}
+	}
+	return sdp.ACrypto{}, false
+}
+
+func newSrtpContextWithCrypto(c sdp.ACrypto) (*rtprtcp.SrtpContext, error) {
+	keyLength, _, err := rtprtcp.SrtpMasterKeyLength(c.Suite)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.KeySalt) < keyLength {
+		return nil, nazaerrors.Wrap(base.ErrSrtpUnsupportedProfile, "invalid inline key")
+	}
+	return rtprtcp.NewSrtpContext(c.Suite, c.KeySalt[:keyLength], c.KeySalt[keyLength:])
+}
+
+// generateSrtpCrypto 随机生成master key和master salt
+func generateSrtpCrypto(profile string) (sdp.ACrypto, error) {
+	keyLength, saltLength, err := rtprtcp.SrtpMasterKeyLength(profile)
+	if err != nil {
+		return sdp.ACrypto{}, err
+	}
+	c := sdp.ACrypto{
+		Tag:     1,
+		Suite:   profile,
+		KeySalt: make([]byte, keyLength+saltLength),
+	}
+	_, err = rand.Read(c.KeySalt)
+	return c, err
+}
+
+func srtpEncryptRtp(c *rtprtcp.SrtpContext, b []byte) ([]byte, error) {
+	if c == nil {
+		return b, nil
+	}
+	return c.EncryptRtp(b)
+}
+
+func srtpEncryptRtcp(c *rtprtcp.SrtpContext, b []byte) ([]byte, error) {
+	if c == nil {
+		return b, nil
+	}
+	return c.EncryptRtcp(b)
+}
+
+// srtpDecryptRtcp UDP模式下音频和视频的rtcp使用同一个回调，所以依次尝试，都为nil时表示没有使用SRTP
+func srtpDecryptRtcp(b []byte, contexts ...*rtprtcp.SrtpContext) ([]byte, error) {
+	var err error
+	used := false
+	for _, c := range contexts {
+		if c == nil {
+			continue
+		}
+		used = true
+		var out []byte
+		if out, err = c.DecryptRtcp(b); err == nil {
+			return out, nil
+		}
+	}
+	if !used {
+		return b, nil
+	}
+	return nil, err
+}
@@ -0,0 +1,134 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtsp
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/q191201771/lal/pkg/base"
+	"github.com/q191201771/lal/pkg/rtprtcp"
+	"github.com/q191201771/lal/pkg/sdp"
+	"github.com/q191201771/naza/pkg/assert"
+)
+
+type testSrtpPullObserver struct {
+	rtpChan chan rtprtcp.RtpPacket
+}
+
+func (o *testSrtpPullObserver) OnSdp(sdpCtx sdp.LogicContext) {}
+func (o *testSrtpPullObserver) OnRtpPacket(pkt rtprtcp.RtpPacket) {
+	o.rtpChan <- pkt
+}
+func (o *testSrtpPullObserver) OnAvPacket(pkt base.AvPacket) {}
+
+// TestServerSrtp 服务端在DESCRIBE中下发密钥，PullSession使用RTP/SAVP拉流
+func TestServerSrtp(t *testing.T) {
+	for _, profile := range []string{"", rtprtcp.SrtpProfileAeadAes128Gcm} {
+		observer := newTestServerObserver()
+		s := NewServer("127.0.0.1:0", observer, ServerAuthConfig{}, ServerTransportConfig{SrtpEnable: true, SrtpProfile: profile})
+		assert.Equal(t, nil, s.Listen())
+		go s.RunLoop()
+
+		pullObserver := &testSrtpPullObserver{rtpChan: make(chan rtprtcp.RtpPacket, 16)}
+		pullSession := NewPullSession(pullObserver, func(option *PullSessionOption) {
+			option.PullTimeoutMs = 2000
+			option.OverTcp = true
+		})
+		assert.Equal(t, nil, pullSession.Start(fmt.Sprintf("rtsp://%s/live/test", s.ln.Addr().String())))
+		subSession := <-observer.subSessionChan
+		assert.IsNotNil(t, subSession.baseOutSession.videoSrtp)
+		assert.IsNotNil(t, pullSession.baseInSession.videoSrtp)
+		assert.Equal(t, 1, len(pullSession.GetSdp().VideoCryptoList))
+
+		h := rtprtcp.MakeDefaultRtpHeader()
+		h.PacketType = 96
+		h.Ssrc = 1000
+		h.Seq = 1
+		pkt := rtprtcp.MakeRtpPacket(h, []byte{0x65, 0x01, 0x02})
+		assert.Equal(t, nil, subSession.baseOutSession.WriteRtpPacket(pkt))
+		select {
+		case received := <-pullObserver.rtpChan:
+			assert.Equal(t, pkt.Raw, received.Raw)
+		case <-time.After(2 * time.Second):
+			t.Fatal("srtp packet not received")
+		}
+
+		_ = pullSession.Dispose()
+		s.Dispose()
+	}
+}
+
+// TestServerSrtpUnsupported 没有密钥时，RTP/SAVP回复461
+func TestServerSrtpUnsupported(t *testing.T) {
+	observer := newTestServerObserver()
+	s := NewServer("127.0.0.1:0", observer, ServerAuthConfig{}, ServerTransportConfig{})
+	assert.Equal(t, nil, s.Listen())
+	go s.RunLoop()
+	defer s.Dispose()
+
+	addr := s.ln.Addr().String()
+	uri := fmt.Sprintf("rtsp://%s/live/test", addr)
+	conn, err := net.Dial("tcp", addr)
+	assert.Equal(t, nil, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	resp := testRequest(t, conn, r, PackRequest(MethodDescribe, uri, map[string]string{HeaderCSeq: "1"}, ""))
+	assert.Equal(t, "200", resp.StatusCode)
+	<-observer.subSessionChan
+	resp = testRequest(t, conn, r, PackRequest(MethodSetup, uri+"/streamid=0", map[string]string{
+		HeaderCSeq:      "2",
+		HeaderTransport: "RTP/SAVP/TCP;unicast;interleaved=0-1",
+	}, ""))
+	assert.Equal(t, "461", resp.StatusCode)
+	resp = testRequest(t, conn, r, PackRequest(MethodSetup, uri+"/streamid=0", map[string]string{
+		HeaderCSeq:      "3",
+		HeaderTransport: "RTP/AVP/TCP;unicast;interleaved=0-1",
+	}, ""))
+	assert.Equal(t, "200", resp.StatusCode)
+}
+
+func TestBaseInSessionSrtp(t *testing.T) {
+	crypto, err := generateSrtpCrypto(rtprtcp.SrtpProfileAesCm128HmacSha1_32)
+	assert.Equal(t, nil, err)
+	sdpCtx, err := sdp.ParseSdp2LogicContext(sdp.AppendACrypto([]byte(testSdp), nil, &crypto))
+	assert.Equal(t, nil, err)
+	uri := "rtsp://127.0.0.1/live/test/streamid=0"
+	assert.Equal(t, "RTP/SAVP/UDP;unicast;client_port=1-2", toSrtpTransport(fmt.Sprintf(HeaderTransportClientPlayTmpl, 1, 2)))
+
+	observer := &testSrtpPullObserver{rtpChan: make(chan rtprtcp.RtpPacket, 1)}
+	session := NewBaseInSessionWithObserver(base.SessionTypeRtspPub, nil, observer)
+	session.InitWithSdp(sdpCtx)
+	assert.Equal(t, nil, session.SetupSrtp(uri))
+	assert.Equal(t, nil, session.SetupWithChannel(uri, 0, 1))
+
+	c, err := newSrtpContextWithCrypto(crypto)
+	assert.Equal(t, nil, err)
+	h := rtprtcp.MakeDefaultRtpHeader()
+	h.PacketType = 96
+	h.Ssrc = 2000
+	pkt := rtprtcp.MakeRtpPacket(h, []byte{0x65, 0x01, 0x02})
+	enc, err := c.EncryptRtp(pkt.Raw)
+	assert.Equal(t, nil, err)
+
+	// 未加密以及被篡改的包被丢弃
+	session.HandleInterleavedPacket(pkt.Raw, 0)
+	enc[len(enc)-1] ^= 1
+	session.HandleInterleavedPacket(enc, 0)
+	assert.Equal(t, 0, len(observer.rtpChan))
+
+	enc[len(enc)-1] ^= 1
+	session.HandleInterleavedPacket(enc, 0)
+	received := <-observer.rtpChan
+	assert.Equal(t, pkt.Raw, received.Raw)
+}
@@ -0,0 +1,89 @@
+// Copyright 2026, Chef.  All rights reserved.
+// https://github.com/q191201771/lal
+//
+// Use of this source code is governed by a MIT-style license
+// that can be found in the License file.
+//
+// Author: Chef (191201771@qq.com)
+
+package rtsp
+
+import (
+	"net"
+	"sync"
+
+	"github.com/q191201771/naza/pkg/nazanet"
+)
+
+// udpLatch 对称RTP（symmetric RTP），锁定一个UDP连接上收到的第一个包的源地址
+//
+// 对端在NAT后面时，SETUP中client_port是对端的内网端口，往该地址发送数据对端收不到，
+// 需要等对端先发一个包过来（比如ffmpeg在PLAY之后会向server_port发送打洞包），再往该包的源地址发送
+//
+// 为了避免第三方抢先发包劫持媒体流，默认只锁定和RTSP信令连接相同ip的源地址，端口不限制（NAT会改变端口）
+type udpLatch struct {
+	uniqueKey string
+	allowIp   net.IP // 为nil时不限制源ip
+
+	mu    sync.Mutex
+	raddr *net.UDPAddr
+}
+
+// newUdpLatch
+//
+// @param allowIp: 只锁定该ip的源地址，一般为RTSP信令连接的对端ip，为nil时锁定第一个包的源地址
+func newUdpLatch(uniqueKey string, allowIp net.IP) *udpLatch {
+	return &udpLatch{
+		uniqueKey: uniqueKey,
+		allowIp:   allowIp,
+	}
+}
+
+// check 第一次调用时锁定地址，源ip和allowIp不同时不锁定
+//
+// @return 是否是锁定的地址，如果不是，上层应丢弃该包
+func (l *udpLatch) check(raddr *net.UDPAddr) bool {
+	if raddr == nil {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.raddr == nil {
+		if l.allowIp != nil && !l.allowIp.Equal(raddr.IP) {
+			return false
+		}
+		l.raddr = &net.UDPAddr{IP: raddr.IP, Port: raddr.Port, Zone: raddr.Zone}
+		Log.Infof("[%s] symmetric rtp latch. raddr=%s", l.uniqueKey, raddr.String())
+		return true
+	}
+	return l.raddr.Port == raddr.Port && l.raddr.IP.Equal(raddr.IP)
+}
+
+// addr 还没有锁定时返回nil
+func (l *udpLatch) addr() *net.UDPAddr {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.raddr
+}
+
+// wrap 包装 nazanet.UdpConnection.RunLoop 的回调，丢弃非锁定地址的包
+func (l *udpLatch) wrap(onRead nazanet.OnReadUdpPacket) nazanet.OnReadUdpPacket {
+	return func(b []byte, raddr *net.UDPAddr, err error) bool {
+		if err == nil && !l.check(raddr) {
+			Log.Debugf("[%s] drop packet not from latched addr. raddr=%s", l.uniqueKey, raddr.String())
+			return true
+		}
+		return onRead(b, raddr, err)
+	}
+}
+
+// write 已经锁定时往锁定的地址发送，否则往 conn 创建时的对端地址发送
+func (l *udpLatch) write(conn *nazanet.UdpConnection, b []byte) error {
+	if l != nil {
+		if raddr := l.addr(); raddr != nil {
+			return conn.Write2Addr(b, raddr)
+		}
+	}
+	return conn.Write(b)
+}
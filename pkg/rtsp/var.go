@@ -14,4 +14,13 @@ var Log = nazalog.GetGlobalLogger()
 
 // BaseInSessionTimestampFilterFlag 控制输入 BaseInSession 的音视频数据是否开启时间戳过滤器，也即经过 AvPacketQueue 处理
 var BaseInSessionTimestampFilterFlag = true
+
+// BaseInSessionNackFlag 控制UDP模式的输入 BaseInSession 检测到丢包时，是否向对端发送rtcp nack请求重传
+var BaseInSessionNackFlag = true
 var TimestampFilterHandleRotateFlag = true
+
+// AvSyncWaitSrMaxMs 输入 BaseInSession 开启时间戳过滤器时，最多等待多长时间（按音视频数据的时间戳计算）的音频和视频rtcp sr，用于对齐音视频，见 avSync
+//
+// 等待期间的音视频数据缓存在内存中，sr到达后一次性输出，所以会增加起播延迟，
+// 对端很晚才发送sr或者不发送sr时，延迟可达该值。默认为0，不等待，不做对齐
+var AvSyncWaitSrMaxMs int64 = 0
@@ -22,17 +22,24 @@ type WebsocketServer struct {
 
 	ln         net.Listener
 	auth       ServerAuthConfig
+	transport  *serverTransport
 	httpServer http.Server
 }
 
-func NewWebsocketServer(addr string, observer IServerObserver, auth ServerAuthConfig) *WebsocketServer {
+func NewWebsocketServer(addr string, observer IServerObserver, auth ServerAuthConfig, transport ServerTransportConfig) *WebsocketServer {
 	return &WebsocketServer{
-		addr:     addr,
-		observer: observer,
-		auth:     auth,
+		addr:      addr,
+		observer:  observer,
+		auth:      auth,
+		transport: newServerTransport(transport),
 	}
 }
 
+// ShareTransport 见 HttpTunnelServer.ShareTransport
+func (s *WebsocketServer) ShareTransport(server *Server) {
+	s.transport = server.transport
+}
+
 func (s *WebsocketServer) Listen() (err error) {
 	s.ln, err = net.Listen("tcp", s.addr)
 	if err != nil {
@@ -68,22 +75,12 @@ func (s *WebsocketServer) HandleWebsocket(w http.ResponseWriter, r *http.Request
 	}
 
 	session := NewServerCommandSession(s, conn, s.auth, isWebSocket, webSocketKey)
+	session.transport = s.transport
 	s.observer.OnNewRtspSessionConnect(session)
 
 	session.conn.Write(base.UpdateWebSocketHeader(webSocketKey, "rtsp"))
 
-	err = session.RunLoop()
-	Log.Info(err)
-
-	if session.pubSession != nil {
-		s.observer.OnDelRtspPubSession(session.pubSession)
-		_ = session.pubSession.Dispose()
-	} else if session.subSession != nil {
-		s.observer.OnDelRtspSubSession(session.subSession)
-		_ = session.subSession.Dispose()
-	}
-	s.observer.OnDelRtspSession(session)
-
+	runServerCommandSession(s.observer, session)
 }
 
 func (s *WebsocketServer) Dispose() {
@@ -109,6 +106,10 @@ func (s *WebsocketServer) OnNewRtspSubSessionPlay(session *SubSession) error {
 	return s.observer.OnNewRtspSubSessionPlay(session)
 }
 
+func (s *WebsocketServer) OnRtspSubSessionPlayControl(session *SubSession) error {
+	return onPlayControl(s.observer, session)
+}
+
 func (s *WebsocketServer) OnDelRtspPubSession(session *PubSession) {
 	s.observer.OnDelRtspPubSession(session)
 }
@@ -11,10 +11,12 @@ package sdp
 import (
 	"encoding/base64"
 	"encoding/hex"
+	"strconv"
 	"strings"
 
 	"github.com/q191201771/naza/pkg/nazaerrors"
 
+	"github.com/q191201771/lal/pkg/aac"
 	"github.com/q191201771/lal/pkg/base"
 )
 
@@ -34,6 +36,43 @@ func ParseAsc(a *AFmtPBase) ([]byte, error) {
 	return hex.DecodeString(v)
 }
 
+// ParseAacAuHeaderParam rfc3640 AU-header中各字段所占的位数，参数名不区分大小写，没有时为0
+func ParseAacAuHeaderParam(a *AFmtPBase) (sizeLength, indexLength, indexDeltaLength int) {
+	for k, v := range a.Parameters {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			continue
+		}
+		switch strings.ToLower(k) {
+		case "sizelength":
+			sizeLength = n
+		case "indexlength":
+			indexLength = n
+		case "indexdeltalength":
+			indexDeltaLength = n
+		}
+	}
+	return
+}
+
+// ParseLatmAsc rfc3016 从config（StreamMuxConfig）中获取asc
+//
+// 不支持cpresent为1，也即StreamMuxConfig在rtp包中传输的情况
+func ParseLatmAsc(a *AFmtPBase) ([]byte, error) {
+	if a.Parameters["cpresent"] == "1" {
+		return nil, nazaerrors.Wrap(base.ErrSdp, "latm cpresent=1 not supported")
+	}
+	v, ok := a.Parameters["config"]
+	if !ok {
+		return nil, nazaerrors.Wrap(base.ErrSdp)
+	}
+	smc, err := hex.DecodeString(v)
+	if err != nil {
+		return nil, err
+	}
+	return aac.ParseStreamMuxConfig(smc)
+}
+
 func ParseVpsSpsPps(a *AFmtPBase) (vps, sps, pps []byte, err error) {
 	v, ok := a.Parameters["sprop-vps"]
 	if !ok {
@@ -14,6 +14,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/q191201771/lal/pkg/aac"
 	"github.com/q191201771/lal/pkg/base"
 )
 
@@ -26,6 +27,7 @@ type AudioInfo struct {
 	AudioPt           base.AvPacketPt
 	SamplingFrequency int
 	Asc               []byte
+	AacLatm           bool // aac使用MP4A-LATM（rfc3016），否则使用MPEG4-GENERIC（rfc3640）
 }
 
 func Pack(videoInfo VideoInfo, audioInfo AudioInfo) (ctx LogicContext, err error) {
@@ -95,6 +97,24 @@ func buildAudioSdpInfo(audioInfo AudioInfo, streamid int) string {
 			return ""
 		}
 
+		if audioInfo.AacLatm {
+			ascCtx, err := aac.NewAscContext(audioInfo.Asc)
+			if err != nil {
+				return ""
+			}
+			smc, err := aac.MakeStreamMuxConfig(audioInfo.Asc)
+			if err != nil {
+				return ""
+			}
+			tmpl := `m=audio 0 RTP/AVP %d
+b=AS:128
+a=rtpmap:%d MP4A-LATM/%d/2
+a=fmtp:%d profile-level-id=1;object=%d;cpresent=0;config=%s
+a=control:streamid=%d
+`
+			return fmt.Sprintf(tmpl, base.AvPacketPtAac, base.AvPacketPtAac, audioInfo.SamplingFrequency, base.AvPacketPtAac, ascCtx.AudioObjectType, hex.EncodeToString(smc), streamid)
+		}
+
 		tmpl := `m=audio 0 RTP/AVP %d
 b=AS:128
 a=rtpmap:%d MPEG4-GENERIC/%d/2
@@ -120,7 +140,85 @@ a=rtpmap:%d opus/48000/2
 a=control:streamid=%d
 `
 		return fmt.Sprintf(tmpl, base.AvPacketPtOpus, base.AvPacketPtOpus, streamid)
+	} else if audioInfo.AudioPt == base.AvPacketPtMp2 {
+		// rfc3551 静态payload type 14，时钟频率固定为90000
+		tmpl := `m=audio 0 RTP/AVP %d
+a=rtpmap:%d MPA/90000
+a=control:streamid=%d
+`
+		return fmt.Sprintf(tmpl, base.AvPacketPtMp2, base.AvPacketPtMp2, streamid)
 	}
 
 	return ""
 }
+
+// AppendACrypto 在音频、视频的媒体描述的末尾追加a=crypto，参数为nil时不追加
+func AppendACrypto(rawSdp []byte, audio, video *ACrypto) []byte {
+	f := func(c *ACrypto) func(mLine string) (string, []string) {
+		return func(mLine string) (string, []string) {
+			if c == nil {
+				return mLine, nil
+			}
+			return mLine, []string{PackACrypto(*c)}
+		}
+	}
+	return modifyMediaDesc(rawSdp, f(audio), f(video))
+}
+
+// AppendFecRtpMap 为音频、视频追加ulpfec的payload type，参数为0时不追加
+//
+// 例子：
+// m=video 0 RTP/AVP 96 127
+// a=rtpmap:127 ulpfec/90000
+func AppendFecRtpMap(rawSdp []byte, audioPt, videoPt int) []byte {
+	// fec的时钟频率和被保护的媒体相同
+	ctx, err := ParseSdp2LogicContext(rawSdp)
+	if err != nil {
+		return rawSdp
+	}
+	f := func(pt int, clockRate int) func(mLine string) (string, []string) {
+		return func(mLine string) (string, []string) {
+			if pt == 0 {
+				return mLine, nil
+			}
+			return fmt.Sprintf("%s %d", mLine, pt), []string{fmt.Sprintf("a=rtpmap:%d %s/%d", pt, ARtpMapEncodingNameUlpfec, clockRate)}
+		}
+	}
+	return modifyMediaDesc(rawSdp, f(audioPt, ctx.AudioClockRate), f(videoPt, ctx.VideoClockRate))
+}
+
+// AppendFecSsrc 为音频、视频的ulpfec流追加`a=ssrc:<ssrc> cname:<cname>`（rfc5576），参数为0时不追加
+//
+// fec流使用单独的ssrc（rfc5109 14.1），媒体流的ssrc由rtp包决定，不在sdp中声明
+func AppendFecSsrc(rawSdp []byte, audioSsrc, videoSsrc uint32, cname string) []byte {
+	f := func(ssrc uint32) func(mLine string) (string, []string) {
+		return func(mLine string) (string, []string) {
+			if ssrc == 0 {
+				return mLine, nil
+			}
+			return mLine, []string{fmt.Sprintf("a=ssrc:%d cname:%s", ssrc, cname)}
+		}
+	}
+	return modifyMediaDesc(rawSdp, f(audioSsrc), f(videoSsrc))
+}
+
+// modifyMediaDesc 遍历音频、视频的媒体描述，回调中可以修改m行，以及返回需要追加在媒体描述末尾的行
+func modifyMediaDesc(rawSdp []byte, audio, video func(mLine string) (string, []string)) []byte {
+	lines := strings.Split(strings.TrimSuffix(string(rawSdp), "\r\n"), "\r\n")
+	var out []string
+	var pending []string
+	for _, line := range lines {
+		if strings.HasPrefix(line, "m=") {
+			out = append(out, pending...)
+			pending = nil
+			if strings.HasPrefix(line, "m=audio") {
+				line, pending = audio(line)
+			} else if strings.HasPrefix(line, "m=video") {
+				line, pending = video(line)
+			}
+		}
+		out = append(out, line)
+	}
+	out = append(out, pending...)
+	return []byte(strings.Join(out, "\r\n") + "\r\n")
+}
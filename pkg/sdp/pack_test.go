@@ -59,6 +59,16 @@ func TestSdpPack(t *testing.T) {
 		assert.Equal(t, avcsps, sdpctx.Sps)
 		assert.Equal(t, avcpps, sdpctx.Pps)
 		assert.Equal(t, asc, sdpctx.Asc)
+		assert.Equal(t, false, sdpctx.AacLatm)
+		assert.Equal(t, 13, sdpctx.AacSizeLength)
+
+		// aac使用MP4A-LATM
+		audio.AacLatm = true
+		sdpctx, err = Pack(video, audio)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, asc, sdpctx.Asc)
+		assert.Equal(t, true, sdpctx.AacLatm)
+		assert.Equal(t, true, sdpctx.IsAudioUnpackable())
 	}
 	{
 		// video和audio无效
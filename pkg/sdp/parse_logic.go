@@ -26,6 +26,24 @@ type LogicContext struct {
 	Sps []byte
 	Pps []byte
 
+	// 音频为aac时有效，见 rtprtcp.RtpUnpackerAacOption
+	AacLatm             bool // rtpmap为MP4A-LATM（rfc3016），否则为MPEG4-GENERIC（rfc3640）
+	AacSizeLength       int  // 以下三个为rfc3640 a=fmtp中的sizelength、indexlength、indexdeltalength，没有时为0
+	AacIndexLength      int
+	AacIndexDeltaLength int
+
+	// rtp header extension的id和uri的对应关系，key: id，没有时为nil，见 rtprtcp.NewRtpExtensionRegistry
+	AudioExtMap map[int]string
+	VideoExtMap map[int]string
+
+	// SDES的SRTP密钥，见 ACrypto ，没有时为空
+	AudioCryptoList []ACrypto
+	VideoCryptoList []ACrypto
+
+	// ulpfec的payload type，没有时为0，见 rtprtcp.FecDecoder
+	AudioFecPayloadType int
+	VideoFecPayloadType int
+
 	audioPayloadTypeBase base.AvPacketPt // lal内部定义的类型
 	videoPayloadTypeBase base.AvPacketPt
 
@@ -52,7 +70,7 @@ func (lc *LogicContext) IsPayloadTypeOrigin(t int) bool {
 }
 
 func (lc *LogicContext) IsAudioUnpackable() bool {
-	return (lc.audioPayloadTypeBase == base.AvPacketPtAac && lc.Asc != nil) || (lc.audioPayloadTypeBase == base.AvPacketPtG711A) || (lc.audioPayloadTypeBase == base.AvPacketPtG711U) || (lc.audioPayloadTypeBase == base.AvPacketPtOpus)
+	return (lc.audioPayloadTypeBase == base.AvPacketPtAac && lc.Asc != nil) || (lc.audioPayloadTypeBase == base.AvPacketPtG711A) || (lc.audioPayloadTypeBase == base.AvPacketPtG711U) || (lc.audioPayloadTypeBase == base.AvPacketPtOpus) || (lc.audioPayloadTypeBase == base.AvPacketPtMp2)
 }
 
 func (lc *LogicContext) IsVideoUnpackable() bool {
@@ -99,6 +117,17 @@ func (lc *LogicContext) makeSetupUri(uri string, aControl string) string {
 	return fmt.Sprintf("%s/%s", uri, aControl)
 }
 
+func makeExtMap(l []AExtMap) map[int]string {
+	if len(l) == 0 {
+		return nil
+	}
+	m := make(map[int]string, len(l))
+	for _, item := range l {
+		m[item.Id] = item.Uri
+	}
+	return m
+}
+
 func ParseSdp2LogicContext(b []byte) (LogicContext, error) {
 	var ret LogicContext
 
@@ -113,6 +142,11 @@ func ParseSdp2LogicContext(b []byte) (LogicContext, error) {
 			ret.hasAudio = true
 			ret.AudioClockRate = md.ARtpMap.ClockRate
 			ret.audioAControl = md.AControl.Value
+			ret.AudioExtMap = makeExtMap(md.AExtMapList)
+			ret.AudioCryptoList = md.ACryptoList
+			if md.AFecRtpMap != nil {
+				ret.AudioFecPayloadType = md.AFecRtpMap.PayloadType
+			}
 
 			ret.audioPayloadTypeOrigin = md.ARtpMap.PayloadType
 			if strings.EqualFold(md.ARtpMap.EncodingName, ARtpMapEncodingNameAac) {
@@ -122,9 +156,23 @@ func ParseSdp2LogicContext(b []byte) (LogicContext, error) {
 					if err != nil {
 						Log.Warnf("parse asc from afmtp failed. err=%+v", err)
 					}
+					ret.AacSizeLength, ret.AacIndexLength, ret.AacIndexDeltaLength = ParseAacAuHeaderParam(md.AFmtPBase)
 				} else {
 					Log.Warnf("aac afmtp not exist.")
 				}
+			} else if strings.EqualFold(md.ARtpMap.EncodingName, ARtpMapEncodingNameLatm) {
+				// 例子:a=rtpmap:96 MP4A-LATM/44100/2
+				//     a=fmtp:96 profile-level-id=15;object=2;cpresent=0;config=400024203fc0
+				ret.audioPayloadTypeBase = base.AvPacketPtAac
+				ret.AacLatm = true
+				if md.AFmtPBase != nil {
+					ret.Asc, err = ParseLatmAsc(md.AFmtPBase)
+					if err != nil {
+						Log.Warnf("parse asc from latm afmtp failed. err=%+v", err)
+					}
+				} else {
+					Log.Warnf("latm afmtp not exist.")
+				}
 			} else if strings.EqualFold(md.ARtpMap.EncodingName, ARtpMapEncodingNameG711A) {
 				// 例子:a=rtpmap:8 PCMA/8000/1
 				// rtmpmap中有PCMA字段表示G711A
@@ -133,6 +181,9 @@ func ParseSdp2LogicContext(b []byte) (LogicContext, error) {
 				ret.audioPayloadTypeBase = base.AvPacketPtG711U
 			} else if strings.EqualFold(md.ARtpMap.EncodingName, ArtpMapEncodingNameOpus) {
 				ret.audioPayloadTypeBase = base.AvPacketPtOpus
+			} else if strings.EqualFold(md.ARtpMap.EncodingName, ARtpMapEncodingNameMpa) {
+				// 例子:a=rtpmap:14 MPA/90000
+				ret.audioPayloadTypeBase = base.AvPacketPtMp2
 			} else {
 				// ffmpeg推流情况下不会填充rtpmap字段,m中pt值为8也可以表示是PCMA,采样率默认为8000Hz
 				// RFC3551中表明G711A固定pt值为8
@@ -149,10 +200,11 @@ func ParseSdp2LogicContext(b []byte) (LogicContext, error) {
 						ret.AudioClockRate = 8000
 					}
 				} else if md.M.PT == MediaDescPayloadTypeMp2 {
+					// RFC3551中MPA的时钟频率固定为90000，与实际采样率无关
 					ret.audioPayloadTypeBase = base.AvPacketPtMp2
 					ret.audioPayloadTypeOrigin = MediaDescPayloadTypeMp2
 					if ret.AudioClockRate == 0 {
-						ret.AudioClockRate = 8000
+						ret.AudioClockRate = 90000
 					}
 				} else {
 					if md.M.PT != 0 {
@@ -165,6 +217,11 @@ func ParseSdp2LogicContext(b []byte) (LogicContext, error) {
 			ret.hasVideo = true
 			ret.VideoClockRate = md.ARtpMap.ClockRate
 			ret.videoAControl = md.AControl.Value
+			ret.VideoExtMap = makeExtMap(md.AExtMapList)
+			ret.VideoCryptoList = md.ACryptoList
+			if md.AFecRtpMap != nil {
+				ret.VideoFecPayloadType = md.AFecRtpMap.PayloadType
+			}
 
 			ret.videoPayloadTypeOrigin = md.ARtpMap.PayloadType
 			switch md.ARtpMap.EncodingName {
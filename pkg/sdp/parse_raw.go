@@ -9,6 +9,8 @@
 package sdp
 
 import (
+	"encoding/base64"
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -21,15 +23,19 @@ type RawContext struct {
 }
 
 type MediaDesc struct {
-	M         M
-	ARtpMap   ARtpMap
-	AFmtPBase *AFmtPBase
-	AControl  AControl
+	M           M
+	ARtpMap     ARtpMap
+	AFecRtpMap  *ARtpMap // rfc5109 ulpfec的rtpmap，没有时为nil
+	AFmtPBase   *AFmtPBase
+	AControl    AControl
+	AExtMapList []AExtMap
+	ACryptoList []ACrypto
 }
 
 type M struct {
 	Media string
-	PT    int // 暂时只支持m只有一个pt值的情况
+	Proto string // 比如RTP/AVP，RTP/SAVP
+	PT    int    // 暂时只支持m只有一个pt值的情况
 }
 
 type ARtpMap struct {
@@ -48,6 +54,22 @@ type AControl struct {
 	Value string
 }
 
+// AExtMap rtp header extension的id和uri的对应关系
+type AExtMap struct {
+	Id         int
+	Direction  string // sendrecv、sendonly、recvonly、inactive，可能为空
+	Uri        string
+	Attributes string // 可能为空
+}
+
+// ACrypto rfc4568 SDES，SRTP的master key和master salt
+type ACrypto struct {
+	Tag           int
+	Suite         string // 取值见 rtprtcp.SrtpProfileAesCm128HmacSha1_80 等
+	KeySalt       []byte // inline中base64解码后的数据，master key在前，master salt在后
+	SessionParams string // 可能为空
+}
+
 // ParseSdp2RawContext 例子见单元测试
 func ParseSdp2RawContext(b []byte) (RawContext, error) {
 	lines := strings.Split(string(b), "\r\n")
@@ -89,6 +111,9 @@ func ParseM(s string) (ret M, err error) {
 		return ret, nazaerrors.Wrap(base.ErrSdp)
 	}
 	ret.Media = items[0]
+	if len(items) > 2 {
+		ret.Proto = items[2]
+	}
 	if len(items) > 3 {
 		ret.PT, _ = strconv.Atoi(items[3])
 	}
@@ -188,6 +213,87 @@ func ParseAControl(s string) (ret AControl, err error) {
 	return
 }
 
+// ParseAExtMap 例子见单元测试
+func ParseAExtMap(s string) (ret AExtMap, err error) {
+	// rfc 8285 8.  SDP Signaling Design
+	//
+	// a=extmap:<value>["/"<direction>] <URI> <extensionattributes>
+	//
+
+	if !strings.HasPrefix(s, "a=extmap:") {
+		err = nazaerrors.Wrap(base.ErrSdp)
+		return
+	}
+	items := strings.Fields(strings.TrimPrefix(s, "a=extmap:"))
+	if len(items) < 2 {
+		err = nazaerrors.Wrap(base.ErrSdp)
+		return
+	}
+	idDir := strings.SplitN(items[0], "/", 2)
+	ret.Id, err = strconv.Atoi(idDir[0])
+	if err != nil {
+		return
+	}
+	if ret.Id < 1 || ret.Id > 255 {
+		err = nazaerrors.Wrap(base.ErrSdp)
+		return
+	}
+	if len(idDir) == 2 {
+		ret.Direction = idDir[1]
+	}
+	ret.Uri = items[1]
+	ret.Attributes = strings.Join(items[2:], " ")
+	return
+}
+
+// ParseACrypto 例子见单元测试
+func ParseACrypto(s string) (ret ACrypto, err error) {
+	// rfc 4568 9.1.  Generic "Crypto" Attribute Grammar
+	//
+	// a=crypto:<tag> <crypto-suite> <key-params> [<session-params>]
+	// key-params: inline:<key||salt>["|" lifetime]["|" MKI ":" length]
+	//
+
+	if !strings.HasPrefix(s, "a=crypto:") {
+		err = nazaerrors.Wrap(base.ErrSdp)
+		return
+	}
+	items := strings.Fields(strings.TrimPrefix(s, "a=crypto:"))
+	if len(items) < 3 {
+		err = nazaerrors.Wrap(base.ErrSdp)
+		return
+	}
+	if ret.Tag, err = strconv.Atoi(items[0]); err != nil {
+		return
+	}
+	ret.Suite = items[1]
+
+	// 有多个key时只使用第一个
+	keyParam := strings.SplitN(items[2], ";", 2)[0]
+	if !strings.HasPrefix(keyParam, "inline:") {
+		err = nazaerrors.Wrap(base.ErrSdp)
+		return
+	}
+	keySalt := strings.SplitN(strings.TrimPrefix(keyParam, "inline:"), "|", 2)[0]
+	if ret.KeySalt, err = base64.StdEncoding.DecodeString(keySalt); err != nil {
+		// 有的实现没有base64的padding
+		if ret.KeySalt, err = base64.RawStdEncoding.DecodeString(keySalt); err != nil {
+			return
+		}
+	}
+	ret.SessionParams = strings.Join(items[3:], " ")
+	return
+}
+
+// PackACrypto 不包含结尾的\r\n
+func PackACrypto(c ACrypto) string {
+	s := fmt.Sprintf("a=crypto:%d %s inline:%s", c.Tag, c.Suite, base64.StdEncoding.EncodeToString(c.KeySalt))
+	if c.SessionParams != "" {
+		s += " " + c.SessionParams
+	}
+	return s
+}
+
 // ---------------------------------------------------------------------------------------------------------------------
 
 func parseSdp2RawContext(lines []string) (RawContext, error) {
@@ -217,6 +323,10 @@ func parseSdp2RawContext(lines []string) (RawContext, error) {
 			if md == nil {
 				continue
 			}
+			if strings.EqualFold(aRtpMap.EncodingName, ARtpMapEncodingNameUlpfec) {
+				md.AFecRtpMap = &aRtpMap
+				continue
+			}
 			md.ARtpMap = aRtpMap
 		}
 		if strings.HasPrefix(line, "a=fmtp") {
@@ -239,6 +349,26 @@ func parseSdp2RawContext(lines []string) (RawContext, error) {
 			}
 			md.AControl = aControl
 		}
+		if strings.HasPrefix(line, "a=extmap:") {
+			aExtMap, err := ParseAExtMap(line)
+			if err != nil {
+				return sdpCtx, err
+			}
+			if md == nil {
+				continue
+			}
+			md.AExtMapList = append(md.AExtMapList, aExtMap)
+		}
+		if strings.HasPrefix(line, "a=crypto:") {
+			aCrypto, err := ParseACrypto(line)
+			if err != nil {
+				return sdpCtx, err
+			}
+			if md == nil {
+				continue
+			}
+			md.ACryptoList = append(md.ACryptoList, aCrypto)
+		}
 	}
 	if md != nil {
 		sdpCtx.MediaDescList = append(sdpCtx.MediaDescList, *md)
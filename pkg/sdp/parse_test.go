@@ -703,3 +703,116 @@ a=control:streamid=1
 	assert.Equal(t, true, ctx.IsAudioPayloadTypeOrigin(8))
 	assert.Equal(t, true, ctx.IsAudioUnpackable())
 }
+
+func TestParseAExtMap(t *testing.T) {
+	golden := map[string]AExtMap{
+		"a=extmap:3 http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time": {
+			Id:  3,
+			Uri: "http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time",
+		},
+		"a=extmap:12/sendonly urn:3gpp:video-orientation attr1 attr2": {
+			Id:         12,
+			Direction:  "sendonly",
+			Uri:        "urn:3gpp:video-orientation",
+			Attributes: "attr1 attr2",
+		},
+	}
+	for in, out := range golden {
+		actual, err := ParseAExtMap(in)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, out, actual)
+	}
+
+	_, err := ParseAExtMap("a=extmap:0 urn:3gpp:video-orientation")
+	assert.IsNotNil(t, err)
+	_, err = ParseAExtMap("a=extmap:1")
+	assert.IsNotNil(t, err)
+
+	sdp := strings.ReplaceAll(goldenSdp, "a=control:streamid=0", "a=control:streamid=0\r\na=extmap:4 urn:3gpp:video-orientation")
+	ctx, err := ParseSdp2LogicContext([]byte(sdp))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, map[int]string{4: "urn:3gpp:video-orientation"}, ctx.VideoExtMap)
+	assert.Equal(t, true, ctx.AudioExtMap == nil)
+}
+
+func TestParseACrypto(t *testing.T) {
+	c, err := ParseACrypto("a=crypto:1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|2^20|1:32 KDR=1")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, c.Tag)
+	assert.Equal(t, "AES_CM_128_HMAC_SHA1_80", c.Suite)
+	assert.Equal(t, 30, len(c.KeySalt))
+	assert.Equal(t, "KDR=1", c.SessionParams)
+	assert.Equal(t, "a=crypto:1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR KDR=1", PackACrypto(c))
+
+	_, err = ParseACrypto("a=crypto:1 AES_CM_128_HMAC_SHA1_80")
+	assert.IsNotNil(t, err)
+	_, err = ParseACrypto("a=crypto:1 AES_CM_128_HMAC_SHA1_80 uri:xxx")
+	assert.IsNotNil(t, err)
+
+	audio := ACrypto{Tag: 1, Suite: "AES_CM_128_HMAC_SHA1_80", KeySalt: []byte{1, 2, 3}}
+	video := ACrypto{Tag: 1, Suite: "AEAD_AES_128_GCM", KeySalt: []byte{4, 5, 6}}
+	raw := AppendACrypto([]byte(goldenSdp), &audio, &video)
+	ctx, err := ParseSdp2LogicContext(raw)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []ACrypto{audio}, ctx.AudioCryptoList)
+	assert.Equal(t, []ACrypto{video}, ctx.VideoCryptoList)
+	assert.Equal(t, true, strings.HasSuffix(string(raw), "a=control:streamid=1\r\n"+PackACrypto(audio)+"\r\n"))
+}
+
+func TestFecRtpMap(t *testing.T) {
+	raw := AppendFecRtpMap([]byte(goldenSdp), 0, 127)
+	ctx, err := ParseSdp2LogicContext(raw)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, ctx.AudioFecPayloadType)
+	assert.Equal(t, 127, ctx.VideoFecPayloadType)
+	assert.Equal(t, true, ctx.IsVideoPayloadTypeOrigin(96))
+	assert.Equal(t, 90000, ctx.VideoClockRate)
+	assert.Equal(t, true, strings.Contains(string(raw), "m=video 0 RTP/AVP 96 127\r\n"))
+	assert.Equal(t, true, strings.Contains(string(raw), "a=rtpmap:127 ulpfec/90000\r\n"))
+
+	// fec流的ssrc
+	raw = AppendFecSsrc(raw, 0, 12345, "lal")
+	_, err = ParseSdp2LogicContext(raw)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, strings.Contains(string(raw), "a=ssrc:12345 cname:lal\r\nm=audio"))
+	assert.Equal(t, 1, strings.Count(string(raw), "a=ssrc:"))
+}
+
+func TestCaseAac(t *testing.T) {
+	// rfc3640 AAC-lbr模式，参数名大小写混合
+	golden := `v=0
+o=- 0 0 IN IP4 127.0.0.1
+s=No Name
+t=0 0
+m=audio 0 RTP/AVP 97
+a=rtpmap:97 MPEG4-GENERIC/16000/1
+a=fmtp:97 streamtype=5;profile-level-id=15;mode=AAC-lbr;config=1408;sizeLength=6;indexLength=2;indexDeltaLength=2
+a=control:streamid=0
+`
+	golden = strings.ReplaceAll(golden, "\n", "\r\n")
+	ctx, err := ParseSdp2LogicContext([]byte(golden))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, false, ctx.AacLatm)
+	assert.Equal(t, 6, ctx.AacSizeLength)
+	assert.Equal(t, 2, ctx.AacIndexLength)
+	assert.Equal(t, 2, ctx.AacIndexDeltaLength)
+
+	// rfc3016 MP4A-LATM
+	golden = `v=0
+o=- 0 0 IN IP4 127.0.0.1
+s=No Name
+t=0 0
+m=audio 0 RTP/AVP 96
+a=rtpmap:96 MP4A-LATM/44100/2
+a=fmtp:96 profile-level-id=15;object=2;cpresent=0;config=400024203fc0
+a=control:streamid=0
+`
+	golden = strings.ReplaceAll(golden, "\n", "\r\n")
+	ctx, err = ParseSdp2LogicContext([]byte(golden))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, ctx.AacLatm)
+	assert.Equal(t, []byte{0x12, 0x10}, ctx.Asc)
+	assert.Equal(t, base.AvPacketPtAac, ctx.GetAudioPayloadTypeBase())
+	assert.Equal(t, true, ctx.IsAudioPayloadTypeOrigin(96))
+	assert.Equal(t, true, ctx.IsAudioUnpackable())
+}
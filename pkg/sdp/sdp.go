@@ -16,9 +16,13 @@ const (
 	ARtpMapEncodingNameH265  = "H265"
 	ARtpMapEncodingNameH264  = "H264"
 	ARtpMapEncodingNameAac   = "MPEG4-GENERIC"
+	ARtpMapEncodingNameLatm  = "MP4A-LATM" // rfc3016
 	ARtpMapEncodingNameG711A = "PCMA"
 	ARtpMapEncodingNameG711U = "PCMU"
 	ArtpMapEncodingNameOpus  = "opus"
+	ARtpMapEncodingNameMpa   = "MPA"
+
+	ARtpMapEncodingNameUlpfec = "ulpfec" // rfc5109
 )
 
 const (